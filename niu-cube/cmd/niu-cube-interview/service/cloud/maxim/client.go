@@ -2,6 +2,7 @@ package maxim
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/qiniu/x/xlog"
@@ -32,8 +33,14 @@ func NewMaximClient(conf *utils.QiniuIMConfig) *MaximClient {
 	}
 }
 
+// WithClient 使用给定的HTTP客户端调用IM服务，如记录调用耗时的客户端。
+func (c *MaximClient) WithClient(client *http.Client) *MaximClient {
+	c.client = client
+	return c
+}
+
 // RegisterUser /user/register/v2 注册用户,返回包含用户id
-func (c *MaximClient) RegisterUser(xl *xlog.Logger, username, password string) (*gjson.Result, error) {
+func (c *MaximClient) RegisterUser(ctx context.Context, xl *xlog.Logger, username, password string) (*gjson.Result, error) {
 	if xl == nil {
 		xl = xlog.New("MaximClient")
 	}
@@ -44,7 +51,7 @@ func (c *MaximClient) RegisterUser(xl *xlog.Logger, username, password string) (
 		"password": password,
 	}
 
-	resp, err := c.PostWithJson(url, user)
+	resp, err := c.PostWithJson(ctx, xl, url, user)
 	defer resp.Body.Close()
 
 	if err != nil {
@@ -65,13 +72,13 @@ func (c *MaximClient) RegisterUser(xl *xlog.Logger, username, password string) (
 	return &result, nil
 }
 
-func (c *MaximClient) CreateChatroom(xl *xlog.Logger, name string) (int64, error) {
+func (c *MaximClient) CreateChatroom(ctx context.Context, xl *xlog.Logger, name string) (int64, error) {
 	url := c.apiEndPoint + "/group/create"
 	var req = map[string]interface{}{
 		"name": name,
 		"type": 2,
 	}
-	resp, err := c.PostWithJson(url, req)
+	resp, err := c.PostWithJson(ctx, xl, url, req)
 	defer resp.Body.Close()
 
 	if err != nil {
@@ -97,12 +104,12 @@ func (c *MaximClient) CreateChatroom(xl *xlog.Logger, name string) (int64, error
 	return result.Get("data.group_id").Int(), nil
 }
 
-func (c *MaximClient) DestroyGroupChat(xl *xlog.Logger, groupId int64) error {
+func (c *MaximClient) DestroyGroupChat(ctx context.Context, xl *xlog.Logger, groupId int64) error {
 	query := map[string]interface{}{
 		"group_id": groupId,
 	}
 	url := c.apiEndPoint + "/group/destroy"
-	resp, err := c.PostWithEmptyBody(url, query)
+	resp, err := c.PostWithEmptyBody(ctx, xl, url, query)
 	if resp == nil {
 		return fmt.Errorf("maxin destroy group chat error")
 	}
@@ -136,7 +143,7 @@ func (c *MaximClient) DestroyGroupChat(xl *xlog.Logger, groupId int64) error {
 	}
 }
 
-func (c *MaximClient) PostWithJson(url string, params interface{}) (resp *http.Response, err error) {
+func (c *MaximClient) PostWithJson(ctx context.Context, xl *xlog.Logger, url string, params interface{}) (resp *http.Response, err error) {
 	msg, err := json.Marshal(params)
 	if err != nil {
 		return
@@ -148,10 +155,10 @@ func (c *MaximClient) PostWithJson(url string, params interface{}) (resp *http.R
 	req.Header.Set("access-token", c.accessToken)
 	req.ContentLength = int64(len(msg))
 
-	return c.client.Do(req)
+	return c.client.Do(req.WithContext(ctx))
 }
 
-func (c *MaximClient) Get(url string) (*http.Response, error) {
+func (c *MaximClient) Get(ctx context.Context, xl *xlog.Logger, url string) (*http.Response, error) {
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -160,10 +167,10 @@ func (c *MaximClient) Get(url string) (*http.Response, error) {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("app_id", c.appId)
 	req.Header.Set("access-token", c.accessToken)
-	return c.client.Do(req)
+	return c.client.Do(req.WithContext(ctx))
 }
 
-func (c *MaximClient) PostWithEmptyBody(url string, query map[string]interface{}) (*http.Response, error) {
+func (c *MaximClient) PostWithEmptyBody(ctx context.Context, xl *xlog.Logger, url string, query map[string]interface{}) (*http.Response, error) {
 	stringBuilder := strings.Builder{}
 	stringBuilder.WriteString(url)
 	stringBuilder.WriteString("?")
@@ -182,5 +189,5 @@ func (c *MaximClient) PostWithEmptyBody(url string, query map[string]interface{}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("app_id", c.appId)
 	req.Header.Set("access-token", c.accessToken)
-	return c.client.Do(req)
+	return c.client.Do(req.WithContext(ctx))
 }
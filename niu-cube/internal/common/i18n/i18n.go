@@ -0,0 +1,99 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+	i18n.go: 错误信息等文案的语言。各包按语言维护自己的文案，这里只负责确定请求使用的语言。
+*/
+
+const (
+	// English 英文，未配置默认语言时使用。
+	English = "en"
+	// Chinese 简体中文。
+	Chinese = "zh-CN"
+)
+
+// Languages 支持的语言。
+var Languages = []string{English, Chinese}
+
+var defaultLanguage = English
+
+// SetDefault 设置请求未指定语言时使用的语言，为空时使用英文，不支持的语言返回错误。
+func SetDefault(lang string) error {
+	if lang == "" {
+		defaultLanguage = English
+		return nil
+	}
+	normalized := Normalize(lang)
+	if normalized == "" {
+		return fmt.Errorf("unsupported language %q, should be one of %v", lang, Languages)
+	}
+	defaultLanguage = normalized
+	return nil
+}
+
+// Default 返回请求未指定语言时使用的语言。
+func Default() string {
+	return defaultLanguage
+}
+
+// Normalize 把en-US、zh、zh_CN等语言标签转换为支持的语言，不支持时返回空字符串。
+// 中文的各地区与繁体标签都使用简体中文。
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	primary := strings.FieldsFunc(tag, func(r rune) bool { return r == '-' || r == '_' })
+	if len(primary) == 0 {
+		return ""
+	}
+	switch primary[0] {
+	case "en":
+		return English
+	case "zh":
+		return Chinese
+	}
+	return ""
+}
+
+// Match 按Accept-Language头部中各语言的权重返回最合适的支持的语言，都不支持时返回空字符串。
+func Match(acceptLanguage string) string {
+	type candidate struct {
+		lang   string
+		weight float64
+	}
+	candidates := make([]candidate, 0)
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(part, ";")
+		lang := Normalize(fields[0])
+		if lang == "" {
+			continue
+		}
+		weight := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+			if err != nil {
+				q = 0
+			}
+			weight = q
+		}
+		if weight > 0 {
+			candidates = append(candidates, candidate{lang: lang, weight: weight})
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	// 权重相同时使用先出现的语言。
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].weight > candidates[j].weight
+	})
+	return candidates[0].lang
+}
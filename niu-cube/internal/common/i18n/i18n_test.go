@@ -0,0 +1,54 @@
+package i18n
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		"en":      English,
+		"en-US":   English,
+		"EN_gb":   English,
+		"zh":      Chinese,
+		"zh-CN":   Chinese,
+		"zh-Hant": Chinese,
+		"ja":      "",
+		"*":       "",
+		"":        "",
+	}
+	for tag, want := range cases {
+		if got := Normalize(tag); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	cases := map[string]string{
+		"":                              "",
+		"fr-FR,ja;q=0.8":                "",
+		"zh-CN,zh;q=0.9,en;q=0.8":       Chinese,
+		"en-US,en;q=0.9,zh-CN;q=0.8":    English,
+		"fr;q=1, zh-TW;q=0.5, en;q=0.7": English,
+		"en;q=0, zh;q=0.1":              Chinese,
+		"zh;q=abc, en;q=0.2":            English,
+		"en, zh":                        English,
+		"*;q=0.5, zh-Hans-CN;q=0.6, ja": Chinese,
+	}
+	for header, want := range cases {
+		if got := Match(header); got != want {
+			t.Errorf("Match(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	defer SetDefault("")
+	if err := SetDefault("zh"); err != nil || Default() != Chinese {
+		t.Errorf("SetDefault(zh) = %v, default %q", err, Default())
+	}
+	if err := SetDefault("ja"); err == nil || Default() != Chinese {
+		t.Errorf("SetDefault(ja) = %v, default %q", err, Default())
+	}
+	if err := SetDefault(""); err != nil || Default() != English {
+		t.Errorf("SetDefault() = %v, default %q", err, Default())
+	}
+}
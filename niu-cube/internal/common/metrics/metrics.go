@@ -0,0 +1,204 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/qiniu/x/xlog"
+	"gopkg.in/mgo.v2"
+
+	"github.com/solutions/niu-cube/internal/common/tracing"
+)
+
+/*
+	metrics.go: Prometheus监控指标，通过/metrics暴露。
+	HTTP请求按路由模板、场景与房间类型区分，定时任务按任务名区分，外部调用按服务名区分。
+*/
+
+const namespace = "niu_cube"
+
+var taskLogger = xlog.New("niu-cube-task")
+
+const (
+	// SceneOther 无法归入具体场景的请求。
+	SceneOther = "other"
+	// RoomTypeOther 不在已知房间类型中的取值，避免客户端传入的任意值产生过多的时间序列。
+	RoomTypeOther = "other"
+)
+
+var (
+	// HTTPRequestDuration HTTP请求耗时。
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status", "scene", "room_type"})
+	// HTTPResponses 按业务错误码统计的HTTP响应数，成功时code为0。
+	HTTPResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "responses_total",
+		Help:      "HTTP responses by business response code.",
+	}, []string{"route", "code", "scene", "room_type"})
+	// TaskDuration 定时任务每次执行的耗时。
+	TaskDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "task",
+		Name:      "duration_seconds",
+		Help:      "Duration of scheduled task runs.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"task"})
+	// TaskFailures 定时任务执行失败（返回错误或panic）的次数。
+	TaskFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "task",
+		Name:      "failures_total",
+		Help:      "Scheduled task runs that returned an error or panicked.",
+	}, []string{"task"})
+	// OutboundDuration 调用RTC、IM、短信等外部服务的耗时。operation为SDK方法名或HTTP方法，
+	// result为HTTP状态码、ok或error。
+	OutboundDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "outbound",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of outbound HTTP calls by service.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "operation", "result"})
+	// MongoOperations 按集合、命令与结果统计的数据库操作次数。operation为find、update等MongoDB命令名，
+	// result为ok、not_found、timeout、canceled或error。
+	MongoOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "mongo",
+		Name:      "collection_operations_total",
+		Help:      "MongoDB operations by collection, command and result.",
+	}, []string{"collection", "operation", "result"})
+	// AuditLogRecords 审计日志记录数，result为written、dropped（缓冲区已满）或failed（写入数据库失败）。
+	AuditLogRecords = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "audit_log",
+		Name:      "records_total",
+		Help:      "Audit log records by write result.",
+	}, []string{"result"})
+)
+
+// knownRoomTypes 作为标签值的房间类型，与model中的BaseType常量一致。
+var knownRoomTypes = map[string]bool{
+	"ktv":           true,
+	"classroom":     true,
+	"show":          true,
+	"movie":         true,
+	"onlineExam":    true,
+	"voiceChatRoom": true,
+}
+
+// knownScenes 路由第一段路径对应的场景。
+var knownScenes = map[string]bool{
+	"ktv":    true,
+	"movie":  true,
+	"exam":   true,
+	"base":   true,
+	"repair": true,
+	"ie":     true,
+	"board":  true,
+	"admin":  true,
+}
+
+func init() {
+	mgo.SetStats(true)
+	prometheus.MustRegister(HTTPRequestDuration, HTTPResponses, TaskDuration, TaskFailures, OutboundDuration, MongoOperations, AuditLogRecords, mongoCollector{})
+}
+
+// Handler 返回暴露所有指标的HTTP处理器。
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RoomTypeLabel 返回房间类型的标签值，未知类型归为other。
+func RoomTypeLabel(roomType string) string {
+	if roomType == "" || knownRoomTypes[roomType] {
+		return roomType
+	}
+	return RoomTypeOther
+}
+
+// SceneOfRoute 根据路由模板返回场景，如/v1/ktv/operateSong为ktv，面试相关的路由为interview。
+func SceneOfRoute(route string) string {
+	parts := strings.SplitN(strings.TrimPrefix(route, "/"), "/", 3)
+	if len(parts) < 2 {
+		return SceneOther
+	}
+	if knownScenes[parts[1]] {
+		return parts[1]
+	}
+	if strings.Contains(strings.ToLower(parts[1]), "interview") {
+		return "interview"
+	}
+	return SceneOther
+}
+
+// Task 包装定时任务，记录每次执行的耗时与失败次数。panic会被恢复并记为失败，避免中断调度。
+func Task(name string, task func() error) func() {
+	return func() {
+		start := time.Now()
+		failed := true
+		defer func() {
+			TaskDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+			if failed {
+				TaskFailures.WithLabelValues(name).Inc()
+			}
+			if p := recover(); p != nil {
+				taskLogger.Errorf("task %s panic: %v", name, p)
+			}
+		}()
+		if err := task(); err != nil {
+			taskLogger.Errorf("task %s failed, error %v", name, err)
+			return
+		}
+		failed = false
+	}
+}
+
+// ObserveCall 记录一次通过SDK调用外部服务的耗时，用于无法替换http.Client的SDK。
+func ObserveCall(service, operation string, start time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	OutboundDuration.WithLabelValues(service, operation, result).Observe(time.Since(start).Seconds())
+}
+
+// Transport 记录外部调用耗时的http.RoundTripper。
+type Transport struct {
+	Service string
+	Base    http.RoundTripper
+}
+
+// NewTransport 返回记录service调用耗时的RoundTripper，base为空时使用http.DefaultTransport。
+func NewTransport(service string, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Service: service, Base: base}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.Base.RoundTrip(req)
+	result := "error"
+	if err == nil {
+		result = strconv.Itoa(resp.StatusCode)
+	}
+	OutboundDuration.WithLabelValues(t.Service, req.Method, result).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// NewHTTPClient 返回记录service调用耗时与链路追踪的http.Client，timeout为0时不设超时。
+func NewHTTPClient(service string, timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: NewTransport(service, tracing.NewTransport(service, nil))}
+}
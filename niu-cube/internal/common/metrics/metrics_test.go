@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSceneOfRoute(t *testing.T) {
+	cases := map[string]string{
+		"/v1/ktv/operateSong":            "ktv",
+		"/v1/exam/eventLog":              "exam",
+		"/v1/base/heartBeat":             "base",
+		"/v1/joinInterview/:interviewId": "interview",
+		"/v1/heartBeat/:interviewId":     SceneOther,
+		"/v2/solution":                   SceneOther,
+		"/metrics":                       SceneOther,
+		"":                               SceneOther,
+	}
+	for route, want := range cases {
+		if got := SceneOfRoute(route); got != want {
+			t.Errorf("SceneOfRoute(%q) = %q, want %q", route, got, want)
+		}
+	}
+}
+
+func TestRoomTypeLabel(t *testing.T) {
+	if got := RoomTypeLabel("ktv"); got != "ktv" {
+		t.Fatalf("RoomTypeLabel(ktv) = %q", got)
+	}
+	if got := RoomTypeLabel(""); got != "" {
+		t.Fatalf("RoomTypeLabel(empty) = %q", got)
+	}
+	if got := RoomTypeLabel("x' OR 1=1"); got != RoomTypeOther {
+		t.Fatalf("RoomTypeLabel(unknown) = %q", got)
+	}
+}
+
+func TestTask(t *testing.T) {
+	Task("test_ok", func() error { return nil })()
+	Task("test_error", func() error { return errors.New("list failed") })()
+	Task("test_panic", func() error { panic("boom") })()
+	if got := testutil.ToFloat64(TaskFailures.WithLabelValues("test_ok")); got != 0 {
+		t.Fatalf("failures of test_ok = %v", got)
+	}
+	if got := testutil.ToFloat64(TaskFailures.WithLabelValues("test_error")); got != 1 {
+		t.Fatalf("failures of test_error = %v", got)
+	}
+	if got := testutil.ToFloat64(TaskFailures.WithLabelValues("test_panic")); got != 1 {
+		t.Fatalf("failures of test_panic = %v", got)
+	}
+	if got := testutil.CollectAndCount(TaskDuration, "niu_cube_task_duration_seconds"); got < 3 {
+		t.Fatalf("duration series = %d", got)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+	client := NewHTTPClient("test_service", 0)
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	resp.Body.Close()
+	if got := testutil.CollectAndCount(OutboundDuration.MustCurryWith(map[string]string{"service": "test_service", "result": "502"})); got != 1 {
+		t.Fatalf("outbound series = %d", got)
+	}
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/mgo.v2"
+)
+
+var (
+	mongoOpsDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "mongo", "operations_total"),
+		"Operations sent to or replies received from MongoDB by all DAOs.", []string{"direction"}, nil)
+	mongoDocsDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "mongo", "received_documents_total"),
+		"Documents received from MongoDB by all DAOs.", nil, nil)
+	mongoSocketsDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "mongo", "sockets"),
+		"MongoDB sockets alive or in use.", []string{"state"}, nil)
+	mongoClustersDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "mongo", "clusters"),
+		"MongoDB clusters (one per dialed session pool).", nil, nil)
+)
+
+// mongoCollector 导出mgo驱动的全局统计，覆盖使用mgo的DAO的数据库操作，按集合的统计见MongoOperations。
+type mongoCollector struct{}
+
+func (mongoCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- mongoOpsDesc
+	ch <- mongoDocsDesc
+	ch <- mongoSocketsDesc
+	ch <- mongoClustersDesc
+}
+
+func (mongoCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := mgo.GetStats()
+	ch <- prometheus.MustNewConstMetric(mongoOpsDesc, prometheus.CounterValue, float64(stats.SentOps), "sent")
+	ch <- prometheus.MustNewConstMetric(mongoOpsDesc, prometheus.CounterValue, float64(stats.ReceivedOps), "received")
+	ch <- prometheus.MustNewConstMetric(mongoDocsDesc, prometheus.CounterValue, float64(stats.ReceivedDocs))
+	ch <- prometheus.MustNewConstMetric(mongoSocketsDesc, prometheus.GaugeValue, float64(stats.SocketsAlive), "alive")
+	ch <- prometheus.MustNewConstMetric(mongoSocketsDesc, prometheus.GaugeValue, float64(stats.SocketsInUse), "in_use")
+	ch <- prometheus.MustNewConstMetric(mongoClustersDesc, prometheus.GaugeValue, float64(stats.Clusters))
+}
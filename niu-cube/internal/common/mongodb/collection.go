@@ -0,0 +1,264 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/solutions/niu-cube/internal/common/metrics"
+)
+
+// Collection mgo集合，方法与mgo.Collection相同，每次操作从连接池复制会话，操作结束后关闭，可以在多个goroutine中共享。
+type Collection struct {
+	m    *Manager
+	name string
+	// ctx 通过WithContext绑定，为空时只受Manager的超时时间限制。
+	ctx context.Context
+}
+
+// WithContext 返回绑定ctx的集合，之后的操作在ctx结束后不再执行，执行时间不超过ctx的截止时间。
+// mgo无法中断已发出的请求，ctx取消时正在执行的操作仍会等到截止时间或OperationTimeout。
+func (c *Collection) WithContext(ctx context.Context) *Collection {
+	return &Collection{m: c.m, name: c.name, ctx: ctx}
+}
+
+// Name 集合名。
+func (c *Collection) Name() string {
+	return c.name
+}
+
+// with 在复制的会话中执行fn，会话的读写超时不超过ctx的截止时间。op为操作对应的MongoDB命令名，用于按集合统计操作次数。
+func (c *Collection) with(op string, fn func(coll *mgo.Collection) error) (err error) {
+	defer func() {
+		metrics.MongoOperations.WithLabelValues(c.name, op, operationResult(err)).Inc()
+	}()
+	if c.ctx != nil {
+		if err := c.ctx.Err(); err != nil {
+			return contextError(err)
+		}
+	}
+	session := c.m.copySession()
+	defer session.Close()
+	if timeout, ok := c.deadline(); ok && timeout < c.m.socketTimeout {
+		session.SetSocketTimeout(timeout)
+	}
+	err = fn(session.DB(c.m.database).C(c.name))
+	if err != nil && c.ctx != nil && c.ctx.Err() != nil {
+		return contextError(c.ctx.Err())
+	}
+	return c.m.checkError(err)
+}
+
+// deadline 返回距ctx截止时间的剩余时间，没有截止时间时返回false。
+func (c *Collection) deadline() (time.Duration, bool) {
+	if c.ctx == nil {
+		return 0, false
+	}
+	return contextTimeout(c.ctx)
+}
+
+// contextTimeout 返回距ctx截止时间的剩余时间，至少为1毫秒，没有截止时间时返回false。
+func contextTimeout(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	if timeout := time.Until(deadline); timeout > time.Millisecond {
+		return timeout, true
+	}
+	return time.Millisecond, true
+}
+
+// maxTime 查询在服务端的最长执行时间，取OperationTimeout与ctx剩余时间中较小的一个。
+func (c *Collection) maxTime() time.Duration {
+	if timeout, ok := c.deadline(); ok && timeout < c.m.operationTimeout {
+		return timeout
+	}
+	return c.m.operationTimeout
+}
+
+func (c *Collection) Find(query interface{}) *Query {
+	return &Query{coll: c, query: query}
+}
+
+func (c *Collection) FindId(id interface{}) *Query {
+	return &Query{coll: c, query: bson.M{"_id": id}}
+}
+
+func (c *Collection) Pipe(pipeline interface{}) *Pipe {
+	return &Pipe{coll: c, pipeline: pipeline}
+}
+
+func (c *Collection) Count() (n int, err error) {
+	err = c.with("count", func(coll *mgo.Collection) error {
+		n, err = coll.Count()
+		return err
+	})
+	return n, err
+}
+
+func (c *Collection) Insert(docs ...interface{}) error {
+	return c.with("insert", func(coll *mgo.Collection) error {
+		return coll.Insert(docs...)
+	})
+}
+
+func (c *Collection) Update(selector interface{}, update interface{}) error {
+	return c.with("update", func(coll *mgo.Collection) error {
+		return coll.Update(selector, update)
+	})
+}
+
+func (c *Collection) UpdateId(id interface{}, update interface{}) error {
+	return c.with("update", func(coll *mgo.Collection) error {
+		return coll.UpdateId(id, update)
+	})
+}
+
+func (c *Collection) UpdateAll(selector interface{}, update interface{}) (info *mgo.ChangeInfo, err error) {
+	err = c.with("update", func(coll *mgo.Collection) error {
+		info, err = coll.UpdateAll(selector, update)
+		return err
+	})
+	return info, err
+}
+
+func (c *Collection) Upsert(selector interface{}, update interface{}) (info *mgo.ChangeInfo, err error) {
+	err = c.with("update", func(coll *mgo.Collection) error {
+		info, err = coll.Upsert(selector, update)
+		return err
+	})
+	return info, err
+}
+
+func (c *Collection) UpsertId(id interface{}, update interface{}) (info *mgo.ChangeInfo, err error) {
+	err = c.with("update", func(coll *mgo.Collection) error {
+		info, err = coll.UpsertId(id, update)
+		return err
+	})
+	return info, err
+}
+
+func (c *Collection) Remove(selector interface{}) error {
+	return c.with("delete", func(coll *mgo.Collection) error {
+		return coll.Remove(selector)
+	})
+}
+
+func (c *Collection) RemoveId(id interface{}) error {
+	return c.with("delete", func(coll *mgo.Collection) error {
+		return coll.RemoveId(id)
+	})
+}
+
+func (c *Collection) RemoveAll(selector interface{}) (info *mgo.ChangeInfo, err error) {
+	err = c.with("delete", func(coll *mgo.Collection) error {
+		info, err = coll.RemoveAll(selector)
+		return err
+	})
+	return info, err
+}
+
+func (c *Collection) EnsureIndex(index mgo.Index) error {
+	return c.with("createIndexes", func(coll *mgo.Collection) error {
+		return coll.EnsureIndex(index)
+	})
+}
+
+// Query 查询条件，在All、One、Count、Apply时才复制会话执行，执行时间不超过Manager的OperationTimeout与ctx的截止时间。
+type Query struct {
+	coll     *Collection
+	query    interface{}
+	sort     []string
+	skip     int
+	limit    int
+	selector interface{}
+}
+
+func (q *Query) Sort(fields ...string) *Query {
+	q.sort = fields
+	return q
+}
+
+func (q *Query) Skip(n int) *Query {
+	q.skip = n
+	return q
+}
+
+func (q *Query) Limit(n int) *Query {
+	q.limit = n
+	return q
+}
+
+func (q *Query) Select(selector interface{}) *Query {
+	q.selector = selector
+	return q
+}
+
+// with 在复制的会话中构造mgo查询并执行fn。
+func (q *Query) with(op string, fn func(query *mgo.Query) error) error {
+	return q.coll.with(op, func(coll *mgo.Collection) error {
+		query := coll.Find(q.query).SetMaxTime(q.coll.maxTime())
+		if len(q.sort) > 0 {
+			query = query.Sort(q.sort...)
+		}
+		if q.skip > 0 {
+			query = query.Skip(q.skip)
+		}
+		if q.limit > 0 {
+			query = query.Limit(q.limit)
+		}
+		if q.selector != nil {
+			query = query.Select(q.selector)
+		}
+		return fn(query)
+	})
+}
+
+func (q *Query) All(result interface{}) error {
+	return q.with("find", func(query *mgo.Query) error {
+		return query.All(result)
+	})
+}
+
+func (q *Query) One(result interface{}) error {
+	return q.with("find", func(query *mgo.Query) error {
+		return query.One(result)
+	})
+}
+
+func (q *Query) Count() (n int, err error) {
+	err = q.with("count", func(query *mgo.Query) error {
+		n, err = query.Count()
+		return err
+	})
+	return n, err
+}
+
+func (q *Query) Apply(change mgo.Change, result interface{}) (info *mgo.ChangeInfo, err error) {
+	err = q.with("findAndModify", func(query *mgo.Query) error {
+		info, err = query.Apply(change, result)
+		return err
+	})
+	return info, err
+}
+
+// Pipe 聚合查询，在All、One时才复制会话执行。
+type Pipe struct {
+	coll     *Collection
+	pipeline interface{}
+}
+
+func (p *Pipe) All(result interface{}) error {
+	return p.coll.with("aggregate", func(coll *mgo.Collection) error {
+		return coll.Pipe(p.pipeline).All(result)
+	})
+}
+
+func (p *Pipe) One(result interface{}) error {
+	return p.coll.with("aggregate", func(coll *mgo.Collection) error {
+		return coll.Pipe(p.pipeline).One(result)
+	})
+}
@@ -0,0 +1,243 @@
+// Package mongodb 管理进程内共享的MongoDB连接，各DAO通过Get得到的Manager获取集合，不再各自建立连接。
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2"
+)
+
+const (
+	DefaultPoolLimit        = 100
+	DefaultConnectTimeout   = 10 * time.Second
+	DefaultSocketTimeout    = 60 * time.Second
+	DefaultOperationTimeout = 10 * time.Second
+
+	// errCodeMaxTimeMSExpired 查询超过maxTimeMS时服务端返回的错误码。
+	errCodeMaxTimeMSExpired = 50
+)
+
+// ErrTimeout 操作超过ctx的截止时间或Manager的超时时间。
+var ErrTimeout = fmt.Errorf("mongo operation timed out: %w", context.DeadlineExceeded)
+
+var (
+	managersMu sync.Mutex
+	// managers 按URI与库名共享的Manager。
+	managers = map[string]*Manager{}
+)
+
+// Manager 一个MongoDB库的连接。mgo的集合通过C获取，每次操作从连接池复制会话，操作结束后关闭；
+// 考试等使用官方驱动的DAO通过Collection获取集合，共享同一个连接池。
+type Manager struct {
+	// key 在managers中的键，关闭时从中删除。
+	key              string
+	database         string
+	operationTimeout time.Duration
+	socketTimeout    time.Duration
+	// session 只用于复制会话，本身不执行操作，不持有连接，也不需要Refresh。
+	session *mgo.Session
+	client  *mongo.Client
+	xl      *xlog.Logger
+}
+
+// Get 返回conf对应的Manager，URI与库名相同的配置共享同一个Manager，第一次调用时建立连接。
+func Get(conf *utils.MongoConfig) (*Manager, error) {
+	key := conf.URI + "/" + conf.Database
+	managersMu.Lock()
+	defer managersMu.Unlock()
+	if m, ok := managers[key]; ok {
+		return m, nil
+	}
+	m, err := newManager(conf)
+	if err != nil {
+		return nil, err
+	}
+	m.key = key
+	managers[key] = m
+	return m, nil
+}
+
+func newManager(conf *utils.MongoConfig) (*Manager, error) {
+	xl := xlog.New("niu-cube-mongo")
+	poolLimit := conf.PoolLimit
+	if poolLimit <= 0 {
+		poolLimit = DefaultPoolLimit
+	}
+	connectTimeout := secondsOr(conf.ConnectTimeoutSecond, DefaultConnectTimeout)
+	socketTimeout := secondsOr(conf.SocketTimeoutSecond, DefaultSocketTimeout)
+	info, err := mgo.ParseURL(conf.URI)
+	if err != nil {
+		xl.Errorf("invalid mongo uri, error: %v", err)
+		return nil, err
+	}
+	if info.Database == "" {
+		info.Database = conf.Database
+	}
+	info.Timeout = connectTimeout
+	info.PoolLimit = poolLimit
+	session, err := mgo.DialWithInfo(info)
+	if err != nil {
+		xl.Errorf("failed to create mongo client, error: %v", err)
+		return nil, err
+	}
+	session.SetSocketTimeout(socketTimeout)
+	session.SetSyncTimeout(connectTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(conf.URI).
+		SetMaxPoolSize(uint64(poolLimit)).
+		SetConnectTimeout(connectTimeout).
+		SetServerSelectionTimeout(connectTimeout).
+		SetSocketTimeout(socketTimeout).
+		SetMonitor(newCommandMonitor()))
+	if err != nil {
+		session.Close()
+		xl.Errorf("failed to create mongo client, error: %v", err)
+		return nil, err
+	}
+	return &Manager{
+		database:         conf.Database,
+		operationTimeout: secondsOr(conf.OperationTimeoutSecond, DefaultOperationTimeout),
+		socketTimeout:    socketTimeout,
+		session:          session,
+		client:           client,
+		xl:               xl,
+	}, nil
+}
+
+func secondsOr(seconds int, def time.Duration) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return def
+}
+
+// C 返回mgo的集合。
+func (m *Manager) C(name string) *Collection {
+	return &Collection{m: m, name: name}
+}
+
+// Collection 返回官方驱动的集合，操作次数通过客户端的命令监听按集合统计。
+func (m *Manager) Collection(name string) *mongo.Collection {
+	return m.client.Database(m.database).Collection(name)
+}
+
+// OperationTimeout 单次操作的超时时间，使用官方驱动时作为context的超时时间。
+func (m *Manager) OperationTimeout() time.Duration {
+	return m.operationTimeout
+}
+
+// Ping 复制会话后ping，检查数据库是否可用。复制的会话会重新获取连接，能发现已断开的连接；
+// 连接与读写的等待时间不超过ctx的截止时间。
+func (m *Manager) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+	session := m.copySession()
+	defer session.Close()
+	if timeout, ok := contextTimeout(ctx); ok {
+		session.SetSyncTimeout(timeout)
+		session.SetSocketTimeout(timeout)
+	}
+	err := session.Ping()
+	if err != nil && ctx.Err() != nil {
+		return contextError(ctx.Err())
+	}
+	return m.checkError(err)
+}
+
+// copySession 从连接池复制一个会话，调用方用完后关闭。
+func (m *Manager) copySession() *mgo.Session {
+	return m.session.Copy()
+}
+
+// checkError 超时的错误返回ErrTimeout，其他错误返回err本身。
+// 连接断开时mgo会关闭出错的连接并从连接池中移除，之后复制的会话重新获取连接，这里只记录日志。
+func (m *Manager) checkError(err error) error {
+	if isConnectionError(err) {
+		m.xl.Warnf("mongo connection error: %v", err)
+	}
+	if IsTimeout(err) {
+		return ErrTimeout
+	}
+	return err
+}
+
+// IsTimeout 判断err是否为超时错误，包括ctx超过截止时间、读写超时与服务端查询超过maxTimeMS。
+func IsTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var queryErr *mgo.QueryError
+	if errors.As(err, &queryErr) && queryErr.Code == errCodeMaxTimeMSExpired {
+		return true
+	}
+	var lastErr *mgo.LastError
+	return errors.As(err, &lastErr) && lastErr.Code == errCodeMaxTimeMSExpired
+}
+
+// contextError ctx结束时返回的错误，超过截止时间为ErrTimeout，取消时为context.Canceled。
+func contextError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	return err
+}
+
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	if errors.Is(err, io.EOF) || errors.As(err, &netErr) {
+		return true
+	}
+	message := err.Error()
+	return strings.Contains(message, "no reachable servers") || strings.Contains(message, "Closed explicitly")
+}
+
+// Close 关闭连接，之后对同一配置调用Get会重新建立连接。
+func (m *Manager) Close(ctx context.Context) {
+	managersMu.Lock()
+	defer managersMu.Unlock()
+	if managers[m.key] == m {
+		delete(managers, m.key)
+	}
+	m.close(ctx)
+}
+
+func (m *Manager) close(ctx context.Context) {
+	m.session.Close()
+	if err := m.client.Disconnect(ctx); err != nil {
+		m.xl.Errorf("failed to disconnect mongo client, error: %v", err)
+	}
+}
+
+// CloseAll 停止服务时关闭所有连接。
+func CloseAll(ctx context.Context) {
+	managersMu.Lock()
+	defer managersMu.Unlock()
+	for key, m := range managers {
+		m.close(ctx)
+		delete(managers, key)
+	}
+}
@@ -0,0 +1,129 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"gopkg.in/mgo.v2"
+
+	"github.com/solutions/niu-cube/internal/common/metrics"
+)
+
+func TestIsConnectionError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{err: nil, want: false},
+		{err: mgo.ErrNotFound, want: false},
+		{err: errors.New("E11000 duplicate key error"), want: false},
+		{err: io.EOF, want: true},
+		{err: fmt.Errorf("read: %w", io.EOF), want: true},
+		{err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, want: true},
+		{err: errors.New("no reachable servers"), want: true},
+		{err: errors.New("Closed explicitly"), want: true},
+	}
+	for _, c := range cases {
+		if got := isConnectionError(c.err); got != c.want {
+			t.Errorf("isConnectionError(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestSecondsOr(t *testing.T) {
+	if got := secondsOr(0, DefaultSocketTimeout); got != DefaultSocketTimeout {
+		t.Errorf("secondsOr(0) = %v, want %v", got, DefaultSocketTimeout)
+	}
+	if got := secondsOr(3, DefaultSocketTimeout); got != 3*time.Second {
+		t.Errorf("secondsOr(3) = %v, want 3s", got)
+	}
+}
+
+func TestIsTimeout(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{err: nil, want: false},
+		{err: mgo.ErrNotFound, want: false},
+		{err: io.EOF, want: false},
+		{err: ErrTimeout, want: true},
+		{err: context.DeadlineExceeded, want: true},
+		{err: &mgo.QueryError{Code: errCodeMaxTimeMSExpired, Message: "operation exceeded time limit"}, want: true},
+		{err: &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}, want: true},
+	}
+	for _, c := range cases {
+		if got := IsTimeout(c.err); got != c.want {
+			t.Errorf("IsTimeout(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestCollectionWithDoneContext(t *testing.T) {
+	coll := &Collection{name: "test"}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := coll.WithContext(canceled).Insert(struct{}{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Insert() with canceled context = %v, want %v", err, context.Canceled)
+	}
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if err := coll.WithContext(expired).Find(nil).All(&[]struct{}{}); err != ErrTimeout {
+		t.Errorf("All() with expired context = %v, want %v", err, ErrTimeout)
+	}
+	if got := testutil.ToFloat64(metrics.MongoOperations.WithLabelValues("test", "insert", "canceled")); got != 1 {
+		t.Errorf("canceled inserts = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.MongoOperations.WithLabelValues("test", "find", "timeout")); got != 1 {
+		t.Errorf("timed out finds = %v, want 1", got)
+	}
+}
+
+func TestCommandMonitor(t *testing.T) {
+	monitor := newCommandMonitor()
+	run := func(requestID int64, command bson.D, failure string) {
+		raw, err := bson.Marshal(command)
+		if err != nil {
+			t.Fatalf("marshal command: %v", err)
+		}
+		name := command[0].Key
+		monitor.Started(context.Background(), &event.CommandStartedEvent{Command: raw, CommandName: name, RequestID: requestID})
+		finished := event.CommandFinishedEvent{CommandName: name, RequestID: requestID}
+		if failure == "" {
+			monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{CommandFinishedEvent: finished})
+		} else {
+			monitor.Failed(context.Background(), &event.CommandFailedEvent{CommandFinishedEvent: finished, Failure: failure})
+		}
+	}
+	run(1, bson.D{{Key: "find", Value: "monitor_test"}}, "")
+	run(2, bson.D{{Key: "getMore", Value: int64(42)}, {Key: "collection", Value: "monitor_test"}}, "")
+	run(3, bson.D{{Key: "insert", Value: "monitor_test"}}, "context deadline exceeded")
+	run(4, bson.D{{Key: "ping", Value: 1}}, "")
+	cases := []struct {
+		operation, result string
+		want              float64
+	}{
+		{operation: "find", result: "ok", want: 1},
+		{operation: "getMore", result: "ok", want: 1},
+		{operation: "insert", result: "timeout", want: 1},
+	}
+	for _, c := range cases {
+		if got := testutil.ToFloat64(metrics.MongoOperations.WithLabelValues("monitor_test", c.operation, c.result)); got != c.want {
+			t.Errorf("%s %s = %v, want %v", c.operation, c.result, got, c.want)
+		}
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,81 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+	"gopkg.in/mgo.v2"
+
+	"github.com/solutions/niu-cube/internal/common/metrics"
+)
+
+// operationResult 返回mgo操作结果的标签值。
+func operationResult(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case err == mgo.ErrNotFound:
+		return "not_found"
+	case IsTimeout(err):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "error"
+	}
+}
+
+// newCommandMonitor 返回统计官方驱动命令的监听器。命令开始时按请求ID记录集合名，结束时按集合、命令与结果计数，
+// ping等不针对集合的命令不计数。
+func newCommandMonitor() *event.CommandMonitor {
+	var collections sync.Map
+	finished := func(e event.CommandFinishedEvent, result string) {
+		name, ok := collections.LoadAndDelete(e.RequestID)
+		if !ok {
+			return
+		}
+		metrics.MongoOperations.WithLabelValues(name.(string), e.CommandName, result).Inc()
+	}
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			if name := commandCollection(e); name != "" {
+				collections.Store(e.RequestID, name)
+			}
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			finished(e.CommandFinishedEvent, "ok")
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			finished(e.CommandFinishedEvent, failureResult(e.Failure))
+		},
+	}
+}
+
+// commandCollection 返回命令操作的集合名。命令的第一个字段为命令名，值为集合名；getMore的集合名在collection字段中。
+func commandCollection(e *event.CommandStartedEvent) string {
+	key := e.CommandName
+	if key == "getMore" {
+		key = "collection"
+	}
+	value, err := e.Command.LookupErr(key)
+	if err != nil {
+		return ""
+	}
+	name, _ := value.StringValueOK()
+	return name
+}
+
+// failureResult 根据官方驱动的失败信息返回结果的标签值，事件中只有错误信息，没有原始错误。
+func failureResult(failure string) string {
+	switch {
+	case strings.Contains(failure, "context canceled"):
+		return "canceled"
+	case strings.Contains(failure, "deadline exceeded") || strings.Contains(failure, "timeout") || strings.Contains(failure, "MaxTimeMSExpired"):
+		return "timeout"
+	default:
+		return "error"
+	}
+}
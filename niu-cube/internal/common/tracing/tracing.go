@@ -0,0 +1,153 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/qiniu/x/reqid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/solutions/niu-cube/internal/common/utils"
+)
+
+/*
+	tracing.go: OpenTelemetry链路追踪。每个HTTP请求与定时任务的每次执行对应一个span，
+	DAO与外部服务调用为其子span。span上下文与请求ID通过context.Context传递，请求中为c.Request.Context()，
+	定时任务中为调度器传入的ctx。
+*/
+
+const (
+	// DefaultServiceName 未配置时上报的服务名。
+	DefaultServiceName = "niu-cube"
+
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterOTLP   = "otlp"
+
+	instrumentationName = "github.com/solutions/niu-cube"
+)
+
+func init() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Init 按配置创建导出器并设置全局的TracerProvider，返回停止服务时调用的shutdown函数。
+// conf为空或未配置导出方式时不导出span，但仍会转发请求ID与上游的追踪上下文。
+func Init(conf *utils.TracingConfig) (func(ctx context.Context) error, error) {
+	if conf == nil || conf.Exporter == "" {
+		return func(ctx context.Context) error { return nil }, nil
+	}
+	var exporter sdktrace.SpanExporter
+	var closer io.Closer
+	var err error
+	switch conf.Exporter {
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterFile:
+		if conf.File == "" {
+			return nil, fmt.Errorf("tracing file is required by exporter %s", conf.Exporter)
+		}
+		var file *os.File
+		file, err = os.OpenFile(conf.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		closer = file
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	case ExporterOTLP:
+		options := []otlptracehttp.Option{}
+		if conf.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(conf.Endpoint))
+		}
+		if conf.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), options...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", conf.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	serviceName := conf.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	ratio := conf.SampleRatio
+	if ratio <= 0 {
+		ratio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			_ = closer.Close()
+		}
+		return err
+	}, nil
+}
+
+// Tracer 返回本服务使用的Tracer。
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// ContextWithRequestID 返回带有请求ID的ctx，经Transport发送的请求会转发该请求ID。
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return reqid.NewContext(ctx, requestID)
+}
+
+// Detach 返回带有ctx中span与请求ID、但不随ctx取消的context，用于请求或任务返回后仍在后台执行的操作。
+func Detach(ctx context.Context) context.Context {
+	detached := trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx))
+	if id, ok := reqid.FromContext(ctx); ok {
+		detached = reqid.NewContext(detached, id)
+	}
+	return detached
+}
+
+// Start 以ctx中的span为父span创建新的span，ctx中没有span时创建新的trace。
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) trace.Span {
+	_, span := Tracer().Start(ctx, name, opts...)
+	return span
+}
+
+// StartDAO 为一次数据库操作创建ctx中span的子span，name为DAO的类型与方法名，如BaseRoomDaoService.Insert。
+func StartDAO(ctx context.Context, name string) trace.Span {
+	return Start(ctx, "dao "+name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(semconv.DBSystemMongoDB))
+}
+
+// StartCall 为一次通过SDK调用外部服务创建span，用于无法替换http.Client的SDK。
+func StartCall(ctx context.Context, service, operation string) trace.Span {
+	return Start(ctx, service+" "+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(semconv.PeerServiceKey.String(service)))
+}
+
+// End 结束span，err不为空时将span标记为失败。
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// RequestIDAttribute 记录请求ID的span属性。
+func RequestIDAttribute(requestID string) attribute.KeyValue {
+	return attribute.String("request.id", requestID)
+}
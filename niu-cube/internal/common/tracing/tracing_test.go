@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/qiniu/x/reqid"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStartDAOParent(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	// 两个并发请求各自的DAO span只以自己的ctx为父span。
+	ctx1, request1 := Tracer().Start(context.Background(), "request 1")
+	ctx2, request2 := Tracer().Start(context.Background(), "request 2")
+	StartDAO(ctx2, "BaseRoomDaoService.Select").End()
+	StartDAO(ctx1, "BaseRoomDaoService.Update").End()
+	request1.End()
+	request2.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("got %d spans, want 4", len(spans))
+	}
+	if spans[0].Parent().SpanID() != request2.SpanContext().SpanID() {
+		t.Errorf("parent of %s = %v, want request 2", spans[0].Name(), spans[0].Parent().SpanID())
+	}
+	if spans[1].Parent().SpanID() != request1.SpanContext().SpanID() {
+		t.Errorf("parent of %s = %v, want request 1", spans[1].Name(), spans[1].Parent().SpanID())
+	}
+}
+
+func TestDetach(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	ctx, cancel := context.WithCancel(ContextWithRequestID(context.Background(), "req-detach"))
+	ctx, span := Tracer().Start(ctx, "request")
+	detached := Detach(ctx)
+	cancel()
+	span.End()
+
+	if detached.Err() != nil {
+		t.Errorf("detached context canceled with the parent: %v", detached.Err())
+	}
+	if trace.SpanContextFromContext(detached).SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("detached context lost the span")
+	}
+	if id, ok := reqid.FromContext(detached); !ok || id != "req-detach" {
+		t.Errorf("request id = %q, want req-detach", id)
+	}
+}
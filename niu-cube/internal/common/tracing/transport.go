@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/qiniu/x/reqid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader 转发请求ID使用的头部，与客户端请求使用的头部一致。
+const RequestIDHeader = "X-Reqid"
+
+// Transport 为外部调用创建span，并在请求头中注入追踪上下文与请求ID的http.RoundTripper。
+type Transport struct {
+	Service string
+	Base    http.RoundTripper
+}
+
+// NewTransport 返回追踪service调用的RoundTripper，base为空时使用http.DefaultTransport。
+func NewTransport(service string, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Service: service, Base: base}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := Tracer().Start(req.Context(), t.Service+" "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.PeerServiceKey.String(t.Service),
+			semconv.HTTPMethodKey.String(req.Method),
+			semconv.HTTPURLKey.String(req.URL.Scheme+"://"+req.URL.Host+req.URL.Path),
+		))
+	defer span.End()
+	// RoundTripper不能修改调用方的请求，注入头部前复制一份。
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	if id, ok := reqid.FromContext(ctx); ok && req.Header.Get(RequestIDHeader) == "" {
+		req.Header.Set(RequestIDHeader, id)
+		span.SetAttributes(RequestIDAttribute(id))
+	}
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, strconv.Itoa(resp.StatusCode))
+	}
+	return resp, nil
+}
@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTransport(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	ctx, span := Tracer().Start(ContextWithRequestID(context.Background(), "req-transport"), "request")
+
+	client := &http.Client{Transport: NewTransport("weixin", nil)}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/sns/oauth2/access_token?secret=s", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() = %v", err)
+	}
+	resp.Body.Close()
+	span.End()
+
+	if header.Get(RequestIDHeader) != "req-transport" {
+		t.Errorf("request id header = %q", header.Get(RequestIDHeader))
+	}
+	if req.Header.Get("traceparent") != "" {
+		t.Errorf("transport modified the caller's request")
+	}
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	client0 := spans[0]
+	if client0.Name() != "weixin GET" || client0.Parent().SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("client span = %s, parent %v", client0.Name(), client0.Parent().SpanID())
+	}
+	if want := "00-" + client0.SpanContext().TraceID().String() + "-" + client0.SpanContext().SpanID().String() + "-01"; header.Get("traceparent") != want {
+		t.Errorf("traceparent = %q, want %q", header.Get("traceparent"), want)
+	}
+	if client0.Status().Code.String() != "Error" {
+		t.Errorf("status = %v, want Error for 502", client0.Status())
+	}
+	for _, attr := range client0.Attributes() {
+		if attr.Key == "http.url" && attr.Value.AsString() != server.URL+"/sns/oauth2/access_token" {
+			t.Errorf("http.url = %q, query should be omitted", attr.Value.AsString())
+		}
+	}
+}
@@ -17,19 +17,20 @@ package utils
 import (
 	"log"
 	"os"
-
-	qconfig "github.com/qiniu/x/config"
 )
 
 var (
 	DefaultConf Config
 )
 
+// InitConf 读取并校验配置文件，环境变量覆盖文件中的配置项，配置有误时退出。
 func InitConf(configFilePath string) {
-	err := qconfig.LoadFile(&DefaultConf, configFilePath)
+	conf, err := LoadConfig(configFilePath)
 	if err != nil {
 		log.Fatalf("failed to load config file, error %v", err)
 	}
+	DefaultConf = *conf
+	reloadable.Store(newReloadableConfig(conf))
 }
 
 // SignalingConfig 控制信令相关的配置。
@@ -46,6 +47,14 @@ type SignalingConfig struct {
 type MongoConfig struct {
 	URI      string `json:"uri"`
 	Database string `json:"database"`
+	// PoolLimit 每个服务器的最大连接数，默认100。
+	PoolLimit int `json:"pool_limit"`
+	// ConnectTimeoutSecond 建立连接的超时时间，默认10秒。
+	ConnectTimeoutSecond int `json:"connect_timeout_s"`
+	// SocketTimeoutSecond 等待数据库响应的超时时间，超时后关闭连接，默认60秒。
+	SocketTimeoutSecond int `json:"socket_timeout_s"`
+	// OperationTimeoutSecond 单次查询在数据库中执行的最长时间，默认10秒。
+	OperationTimeoutSecond int `json:"operation_timeout_s"`
 }
 
 // QiniuKeyPair 七牛APIaccess key/secret key配置。
@@ -71,14 +80,82 @@ type MailConfig struct {
 	To                  []string `json:"to"`
 	RetryTimes          int      `json:"retry_times"`
 	RetryIntervalSecond int      `json:"retry_interval_s"`
+	// QueueSize 待发送邮件队列的长度，邮件在后台发送，不阻塞请求。
+	QueueSize int `json:"queue_size"`
+}
+
+// PasswordConfig 邮箱+密码登录配置。
+type PasswordConfig struct {
+	Enabled bool `json:"enabled"`
+	// MinLength 密码的最小长度。
+	MinLength int `json:"min_length"`
+	// BcryptCost 密码哈希的bcrypt cost，为0时使用bcrypt默认值。
+	BcryptCost int `json:"bcrypt_cost"`
+	// RequireVerifiedEmail 为true时邮箱验证通过后才能使用密码登录。
+	RequireVerifiedEmail bool `json:"require_verified_email"`
+	// VerifyEmailExpireSecond 邮箱验证链接的有效时间。
+	VerifyEmailExpireSecond int `json:"verify_email_expire_s"`
+	// ResetPasswordExpireSecond 重置密码链接的有效时间。
+	ResetPasswordExpireSecond int `json:"reset_password_expire_s"`
+	// MaxLoginFailures 密码连续输错该次数后锁定邮箱。
+	MaxLoginFailures int `json:"max_login_failures"`
+	// LoginLockoutSecond 密码输错过多后邮箱锁定的时长。
+	LoginLockoutSecond int `json:"login_lockout_s"`
+	// MailPerEmailDaily 每个邮箱每天最多发送的验证、重置密码邮件数量。
+	MailPerEmailDaily int `json:"mail_per_email_daily"`
+	// MailPerIPDaily 每个IP每天最多请求发送的验证、重置密码邮件数量。
+	MailPerIPDaily int `json:"mail_per_ip_daily"`
+}
+
+// HTTPSMSConfig 通用HTTP短信服务配置，按模板拼装请求调用第三方短信接口。
+// BodyTemplate 为text/template模板，可使用 {{.Phone}} 与 {{.Code}}。
+type HTTPSMSConfig struct {
+	URL           string            `json:"url"`
+	Method        string            `json:"method"`
+	Headers       map[string]string `json:"headers"`
+	BodyTemplate  string            `json:"body_template"`
+	TimeoutSecond int               `json:"timeout_s"`
+}
+
+// OutboxSMSConfig 本地短信发件箱配置，短信以JSON行写入Path文件，供开发与测试使用。
+type OutboxSMSConfig struct {
+	Path string `json:"path"`
+}
+
+// SMSLimitConfig 短信验证码防刷配置。各项为0时使用默认值，为负数时不限制。
+type SMSLimitConfig struct {
+	// PerIPDaily 每个IP每天最多发送的验证码数量。
+	PerIPDaily int `json:"per_ip_daily"`
+	// PerPhoneDaily 每个手机号每天最多发送的验证码数量。
+	PerPhoneDaily int `json:"per_phone_daily"`
+	// GlobalDaily 全部手机号每天最多发送的验证码数量。
+	GlobalDaily int `json:"global_daily"`
+	// MaxValidateFailures 验证码连续输错该次数后锁定手机号。
+	MaxValidateFailures int `json:"max_validate_failures"`
+	// LockoutSecond 手机号锁定的时长。
+	LockoutSecond int `json:"lockout_s"`
+}
+
+// CaptchaConfig 发送短信验证码前的人机验证配置，Provider为空时不需要人机验证。
+type CaptchaConfig struct {
+	Provider     string `json:"provider"`
+	ExpireSecond int    `json:"expire_s"`
 }
 
 // SMSConfig 短信服务配置。
 type SMSConfig struct {
 	Provider string `json:"provider"`
+	// FallbackProvider 主短信服务发送失败时使用的备用短信服务，为空则不切换。
+	FallbackProvider string `json:"fallback_provider,omitempty"`
 	// FixedCodes 固定的手机号->验证码组合，供测试用。
 	FixedCodes map[string]string `json:"fixed_codes,omitempty"`
 	QiniuSMS   *QiniuSMSConfig   `json:"qiniu_sms"`
+	HTTPSMS    *HTTPSMSConfig    `json:"http_sms,omitempty"`
+	Outbox     *OutboxSMSConfig  `json:"outbox,omitempty"`
+	// DefaultCountryCode 手机号默认的国家码，为空时为86。
+	DefaultCountryCode string          `json:"default_country_code,omitempty"`
+	Limit              *SMSLimitConfig `json:"limit,omitempty"`
+	Captcha            *CaptchaConfig  `json:"captcha,omitempty"`
 }
 
 // QiniuRTCConfig 七牛RTC服务配置。
@@ -144,11 +221,101 @@ type Solution struct {
 // Weixin Bucket 保存小程序分享码的七牛存储空间名
 // QRFilePattern 保存小程序码的文件名模式 默认 interview-qrcode/%s-%s
 // Link cdn host
+// APIHost 微信开放接口地址，默认 https://api.weixin.qq.com ，测试时可指向本地的模拟服务
+// LoginEnabled 是否开启小程序登录（signInWithWeixin）
 type Weixin struct {
-	AppID     string `json:"app_id"`
-	AppSecret string `json:"app_secret"`
-	Bucket    string `json:"bucket"`
-	Link      string `json:"link"`
+	AppID        string `json:"app_id"`
+	AppSecret    string `json:"app_secret"`
+	Bucket       string `json:"bucket"`
+	Link         string `json:"link"`
+	APIHost      string `json:"api_host"`
+	LoginEnabled bool   `json:"login_enabled"`
+}
+
+// TokenConfig 登录token配置。
+type TokenConfig struct {
+	// AccessTokenExpireSecond 访问token的有效时间，默认2小时。
+	AccessTokenExpireSecond int `json:"access_token_expire_s"`
+	// RefreshTokenExpireSecond 刷新token的有效时间，默认30天。
+	RefreshTokenExpireSecond int `json:"refresh_token_expire_s"`
+	// RevocationSyncSecond 从数据库同步已吊销token的间隔，默认10秒。
+	RevocationSyncSecond int `json:"revocation_sync_s"`
+	// MaxSessionsPerAccount 每个账号同时在线的设备数上限，超出时踢掉最早活跃的会话，默认5。
+	MaxSessionsPerAccount int `json:"max_sessions_per_account"`
+	// InterviewTokenGraceSecond 面试邀请token在面试结束时间之后仍然有效的时长，默认24小时。
+	InterviewTokenGraceSecond int `json:"interview_token_grace_s"`
+}
+
+// RateLimitRule 一组路由的令牌桶限流规则，RatePerSecond不大于0时不限流。
+type RateLimitRule struct {
+	// RatePerSecond 每秒补充的令牌数。
+	RatePerSecond float64 `json:"rate_per_s"`
+	// Burst 令牌桶容量，即允许的瞬时请求数，默认为RatePerSecond向上取整。
+	Burst int `json:"burst"`
+	// Key 限流的维度：user 按用户ID或API key，未登录时按IP；ip 按客户端IP。默认user。
+	Key string `json:"key"`
+}
+
+// RateLimitConfig 接口限流配置，Groups以路由组名为key，未配置的组使用默认规则。
+type RateLimitConfig struct {
+	Disabled bool                      `json:"disabled"`
+	Groups   map[string]*RateLimitRule `json:"groups"`
+}
+
+// CORSPolicy 跨域访问策略。
+type CORSPolicy struct {
+	// AllowOrigins 允许的来源，如https://console.example.com；可以使用通配符，如https://*.example.com，"*"表示允许所有来源。
+	AllowOrigins []string `json:"allow_origins"`
+	AllowHeaders []string `json:"allow_headers"`
+	AllowMethods []string `json:"allow_methods"`
+	// ExposeHeaders 允许浏览器读取的响应头部。
+	ExposeHeaders []string `json:"expose_headers"`
+	// MaxAgeSecond 预检请求结果的缓存时间。
+	MaxAgeSecond int `json:"max_age_s"`
+	// AllowCredentials 是否允许携带cookie等凭证，不能与"*"来源同时使用。
+	AllowCredentials bool `json:"allow_credentials"`
+}
+
+// CORSConfig 跨域访问配置。Groups以路径前缀为key，如/v1/admin，请求使用前缀最长的策略，
+// 策略中未配置的来源、头部、方法与缓存时间使用默认策略的值。
+type CORSConfig struct {
+	CORSPolicy
+	Groups map[string]*CORSPolicy `json:"groups"`
+}
+
+// ShutdownConfig 停止服务的配置。
+type ShutdownConfig struct {
+	// TimeoutSecond 收到停止信号后等待进行中的请求与定时任务完成的最长时间，默认30秒。
+	TimeoutSecond int `json:"timeout_s"`
+	// DrainDelaySecond 收到停止信号后/readyz先返回503，等待该时长再停止接收请求，便于负载均衡摘除实例，默认0。
+	DrainDelaySecond int `json:"drain_delay_s"`
+}
+
+// AuditLogConfig 审计日志配置，记录异步批量写入数据库，超过保留时间后由数据库自动删除。
+type AuditLogConfig struct {
+	Disabled bool `json:"disabled"`
+	// RetentionDays 审计日志的保留天数，默认90天。
+	RetentionDays int `json:"retention_days"`
+	// BufferSize 等待写入的记录数上限，写入跟不上时丢弃新的记录，默认10000。
+	BufferSize int `json:"buffer_size"`
+	// BatchSize 每次批量写入的记录数，默认100。
+	BatchSize int `json:"batch_size"`
+	// FlushIntervalSecond 不足一批时写入的间隔，默认1秒。
+	FlushIntervalSecond int `json:"flush_interval_s"`
+}
+
+// TracingConfig 链路追踪配置。
+type TracingConfig struct {
+	// Exporter span的导出方式：stdout 输出到标准输出，file 追加写入File，otlp 通过OTLP/HTTP发送到Endpoint；为空时不导出。
+	Exporter string `json:"exporter"`
+	File     string `json:"file"`
+	// Endpoint OTLP/HTTP接收端的host:port，为空时使用默认的localhost:4318。
+	Endpoint string `json:"endpoint"`
+	// Insecure 为true时使用HTTP而非HTTPS发送。
+	Insecure    bool   `json:"insecure"`
+	ServiceName string `json:"service_name"`
+	// SampleRatio 没有上游追踪上下文的请求的采样比例，默认1。
+	SampleRatio float64 `json:"sample_ratio"`
 }
 
 type PandoraConfig struct {
@@ -187,6 +354,25 @@ type Config struct {
 	Solutions4Android    []Solution      `json:"solutions_android"`
 	Weixin               Weixin          `json:"weixin"`
 	JwtKey               string          `json:"jwt_key"`
+	Token                *TokenConfig    `json:"token"`
+	// AdminPhones 始终视为admin角色的手机号，用于初始化第一个管理员。
+	AdminPhones []string        `json:"admin_phones"`
+	Mail        *MailConfig     `json:"mail"`
+	Password    *PasswordConfig `json:"password"`
+	// TrustedProxies 可信反向代理的IP或CIDR，只有来自这些地址的请求才采用X-Forwarded-For中的客户端IP，为空时只信任本机。
+	TrustedProxies []string `json:"trusted_proxies"`
+	// RateLimit 心跳、事件上报、点歌、短信验证码等高频接口的限流配置，为空时使用默认规则。
+	RateLimit *RateLimitConfig `json:"rate_limit"`
+	// Shutdown 停止服务时的等待时间配置，为空时使用默认值。
+	Shutdown *ShutdownConfig `json:"shutdown"`
+	// AuditLog 审计日志配置，为空时使用默认值。
+	AuditLog *AuditLogConfig `json:"audit_log"`
+	// CORS 跨域访问配置，为空时允许所有来源且不允许携带凭证。
+	CORS *CORSConfig `json:"cors"`
+	// Tracing 链路追踪配置，为空时不导出span。
+	Tracing *TracingConfig `json:"tracing"`
+	// DefaultLanguage 请求未指定语言时错误信息使用的语言，en或zh-CN，为空时使用en。
+	DefaultLanguage string `json:"default_language"`
 }
 
 // NewSample 返回样例配置。
@@ -0,0 +1,276 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	qconfig "github.com/qiniu/x/config"
+
+	"github.com/solutions/niu-cube/internal/common/i18n"
+)
+
+// EnvPrefix 覆盖配置项的环境变量前缀。环境变量名为前缀加上配置项的JSON路径，转为大写并以下划线连接，
+// 如NIU_CUBE_MONGO_URI覆盖mongo.uri，NIU_CUBE_IM_QINIU_APP_TOKEN覆盖im.qiniu.app_token。
+// 列表可以用逗号分隔或使用JSON，对象与对象列表使用JSON。
+const EnvPrefix = "NIU_CUBE"
+
+// placeholderPattern 配置样例中未替换的占位值，如"Must，<你的AppId>"、"<Nullable，朵拉AI的AK>"。
+var placeholderPattern = regexp.MustCompile(`(^|<)(Must|Nullable)，`)
+
+// ConfigErrors 配置校验发现的全部问题。
+type ConfigErrors []string
+
+func (e ConfigErrors) Error() string {
+	return "invalid config:\n  " + strings.Join(e, "\n  ")
+}
+
+// LoadConfig 读取配置文件，使用环境变量覆盖后校验，返回的错误为ConfigErrors时包含全部校验问题。
+func LoadConfig(configFilePath string) (*Config, error) {
+	conf := &Config{}
+	if err := qconfig.LoadFile(conf, configFilePath); err != nil {
+		return nil, err
+	}
+	if err := ApplyEnv(conf, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// ApplyEnv 使用lookup返回的环境变量覆盖conf中的配置项，值无法解析时返回错误。
+func ApplyEnv(conf *Config, lookup func(key string) (string, bool)) error {
+	_, err := applyEnv(reflect.ValueOf(conf).Elem(), EnvPrefix, lookup)
+	return err
+}
+
+// applyEnv 覆盖结构体v的字段，返回是否有字段被覆盖。
+func applyEnv(v reflect.Value, prefix string, lookup func(key string) (string, bool)) (bool, error) {
+	changed := false
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		fv := v.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			ok, err := applyEnv(fv, prefix, lookup)
+			if err != nil {
+				return false, err
+			}
+			changed = changed || ok
+			continue
+		}
+		name := jsonName(field)
+		if name == "" {
+			continue
+		}
+		key := prefix + "_" + strings.ToUpper(name)
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			ok, err := applyEnv(fv, key, lookup)
+			if err != nil {
+				return false, err
+			}
+			changed = changed || ok
+			continue
+		case field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct:
+			// 配置文件中没有的对象在有对应的环境变量时创建。
+			target := reflect.New(field.Type.Elem())
+			if !fv.IsNil() {
+				target.Elem().Set(fv.Elem())
+			}
+			ok, err := applyEnv(target.Elem(), key, lookup)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				fv.Set(target)
+				changed = true
+			}
+			continue
+		}
+		value, ok := lookup(key)
+		if !ok {
+			continue
+		}
+		if err := setEnvValue(fv, value); err != nil {
+			return false, fmt.Errorf("invalid environment variable %s: %v", key, err)
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+func setEnvValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(value), "[") {
+			items := make([]string, 0)
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			v.Set(reflect.ValueOf(items))
+			return nil
+		}
+		return json.Unmarshal([]byte(value), v.Addr().Interface())
+	default:
+		return json.Unmarshal([]byte(value), v.Addr().Interface())
+	}
+	return nil
+}
+
+func jsonName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		name = field.Name
+	}
+	return name
+}
+
+// Validate 校验配置：必填项、validate:"nonzero"标记的字段，以及未替换的样例占位值。
+func (c *Config) Validate() error {
+	errs := ConfigErrors{}
+	if c.ListenPort <= 0 || c.ListenPort > 65535 {
+		errs = append(errs, "listen_port must be between 1 and 65535")
+	}
+	if c.Mongo == nil || c.Mongo.URI == "" || c.Mongo.Database == "" {
+		errs = append(errs, "mongo.uri and mongo.database are required")
+	} else if c.Mongo.PoolLimit < 0 || c.Mongo.ConnectTimeoutSecond < 0 || c.Mongo.SocketTimeoutSecond < 0 || c.Mongo.OperationTimeoutSecond < 0 {
+		errs = append(errs, "mongo.pool_limit and mongo timeouts must not be negative")
+	}
+	if c.JwtKey == "" {
+		errs = append(errs, "jwt_key is required")
+	}
+	if c.DefaultLanguage != "" && i18n.Normalize(c.DefaultLanguage) == "" {
+		errs = append(errs, fmt.Sprintf("default_language should be one of %v", i18n.Languages))
+	}
+	checkConfigValue(reflect.ValueOf(c).Elem(), "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkConfigValue 检查v中validate:"nonzero"标记的字段与占位值，path为v的JSON路径。
+func checkConfigValue(v reflect.Value, path string, errs *ConfigErrors) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			checkConfigValue(v.Elem(), path, errs)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			fieldPath := path
+			if !field.Anonymous {
+				fieldPath = joinConfigPath(path, jsonName(field))
+			}
+			if field.Tag.Get("validate") == "nonzero" && v.Field(i).IsZero() {
+				*errs = append(*errs, fieldPath+" is required")
+				continue
+			}
+			checkConfigValue(v.Field(i), fieldPath, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			checkConfigValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			checkConfigValue(iter.Value(), joinConfigPath(path, fmt.Sprint(iter.Key().Interface())), errs)
+		}
+	case reflect.String:
+		if placeholderPattern.MatchString(v.String()) {
+			*errs = append(*errs, fmt.Sprintf("%s still holds the sample placeholder %q", path, v.String()))
+		}
+	}
+}
+
+func joinConfigPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// ReloadableConfig 收到SIGHUP时可以重新加载的配置项，其他配置项修改后需要重启服务。
+type ReloadableConfig struct {
+	DefaultAvatars    []string
+	WelcomeImage      string
+	WelcomeURL        string
+	Solutions         []Solution
+	Solutions4Apple   []Solution
+	Solutions4Android []Solution
+}
+
+// reloadable 当前生效的ReloadableConfig。
+var reloadable atomic.Value
+
+func newReloadableConfig(conf *Config) *ReloadableConfig {
+	return &ReloadableConfig{
+		DefaultAvatars:    conf.DefaultAvatars,
+		WelcomeImage:      conf.WelcomeImage,
+		WelcomeURL:        conf.WelcomeURL,
+		Solutions:         conf.Solutions,
+		Solutions4Apple:   conf.Solutions4Apple,
+		Solutions4Android: conf.Solutions4Android,
+	}
+}
+
+// Reloadable 返回当前生效的可重新加载的配置项，未调用InitConf时取自DefaultConf。
+func Reloadable() *ReloadableConfig {
+	if conf, ok := reloadable.Load().(*ReloadableConfig); ok {
+		return conf
+	}
+	return newReloadableConfig(&DefaultConf)
+}
+
+// ReloadConf 重新读取配置文件，校验通过后更新可重新加载的配置项，校验失败时保留当前配置。
+func ReloadConf(configFilePath string) error {
+	conf, err := LoadConfig(configFilePath)
+	if err != nil {
+		return err
+	}
+	reloadable.Store(newReloadableConfig(conf))
+	return nil
+}
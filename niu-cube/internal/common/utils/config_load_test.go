@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "niu-cube.conf")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+const validConfig = `{
+  "listen_port": 5080,
+  "jwt_key": "key",
+  "mongo": {"uri": "mongodb://localhost:27017", "database": "niu_cube"},
+  "welcome_image": "https://example.com/welcome.png",
+  "default_avatars": ["https://example.com/0.png"]
+}`
+
+func TestApplyEnv(t *testing.T) {
+	conf := &Config{Mongo: &MongoConfig{URI: "mongodb://file", Database: "file"}}
+	env := map[string]string{
+		"NIU_CUBE_LISTEN_PORT":                 "6080",
+		"NIU_CUBE_MONGO_URI":                   "mongodb://env",
+		"NIU_CUBE_IM_QINIU_APP_TOKEN":          "token",
+		"NIU_CUBE_WEIXIN_LOGIN_ENABLED":        "true",
+		"NIU_CUBE_TRACING_SAMPLE_RATIO":        "0.5",
+		"NIU_CUBE_DEFAULT_AVATARS":             "a.png, b.png",
+		"NIU_CUBE_CORS_ALLOW_ORIGINS":          `["https://console.example.com"]`,
+		"NIU_CUBE_SOLUTIONS":                   `[{"id": "ktv", "title": "KTV"}]`,
+		"NIU_CUBE_RATE_LIMIT_GROUPS":           `{"sms": {"rate_per_s": 1}}`,
+		"NIU_CUBE_PANDORA_CONFIG_PANDORA_HOST": "https://pandora",
+	}
+	err := ApplyEnv(conf, func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	})
+	if err != nil {
+		t.Fatalf("ApplyEnv() = %v", err)
+	}
+	if conf.ListenPort != 6080 || conf.Mongo.URI != "mongodb://env" || conf.Mongo.Database != "file" {
+		t.Errorf("listen port %d, mongo %+v", conf.ListenPort, conf.Mongo)
+	}
+	// 配置文件中没有的对象由环境变量创建。
+	if conf.IM == nil || conf.IM.Qiniu == nil || conf.IM.Qiniu.AppToken != "token" || conf.Tracing == nil || conf.Tracing.SampleRatio != 0.5 {
+		t.Errorf("im %+v, tracing %+v", conf.IM, conf.Tracing)
+	}
+	if !conf.Weixin.LoginEnabled || conf.PandoraConfig.PandoraHost != "https://pandora" {
+		t.Errorf("weixin %+v, pandora %+v", conf.Weixin, conf.PandoraConfig)
+	}
+	if !reflect.DeepEqual(conf.DefaultAvatars, []string{"a.png", "b.png"}) {
+		t.Errorf("default avatars = %v", conf.DefaultAvatars)
+	}
+	// 内嵌的CORSPolicy与外层使用同一前缀。
+	if conf.CORS == nil || !reflect.DeepEqual(conf.CORS.AllowOrigins, []string{"https://console.example.com"}) {
+		t.Errorf("cors = %+v", conf.CORS)
+	}
+	if len(conf.Solutions) != 1 || conf.Solutions[0].Title != "KTV" || conf.RateLimit.Groups["sms"].RatePerSecond != 1 {
+		t.Errorf("solutions %+v, rate limit %+v", conf.Solutions, conf.RateLimit)
+	}
+	// 未设置环境变量的对象保持为空。
+	if conf.SMS != nil || conf.AuditLog != nil {
+		t.Errorf("sms %+v, audit log %+v", conf.SMS, conf.AuditLog)
+	}
+
+	err = ApplyEnv(conf, func(key string) (string, bool) {
+		return "abc", key == "NIU_CUBE_LISTEN_PORT"
+	})
+	if err == nil || !strings.Contains(err.Error(), "NIU_CUBE_LISTEN_PORT") {
+		t.Errorf("ApplyEnv(invalid) = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	conf := &Config{
+		ListenPort: 5080,
+		JwtKey:     "key",
+		Mongo:      &MongoConfig{URI: "mongodb://localhost", Database: "niu_cube"},
+		IM: &IMConfig{Qiniu: &QiniuIMConfig{
+			AppId:       "Must，<你的AppId>",
+			AppEndpoint: "https://im.example.com",
+		}},
+		DoraAiAk:       "<Nullable，朵拉AI的AK>",
+		DefaultAvatars: []string{"https://example.com/0.png"},
+	}
+	err := conf.Validate()
+	errs, ok := err.(ConfigErrors)
+	if !ok {
+		t.Fatalf("Validate() = %v, want ConfigErrors", err)
+	}
+	want := map[string]bool{
+		"im.qiniu.app_id":    false,
+		"im.qiniu.app_token": false,
+		"dora_ai_ak":         false,
+	}
+	for _, e := range errs {
+		for field := range want {
+			if strings.HasPrefix(e, field+" ") {
+				want[field] = true
+			}
+		}
+	}
+	for field, found := range want {
+		if !found {
+			t.Errorf("no error for %s in %v", field, errs)
+		}
+	}
+	if len(errs) != len(want) {
+		t.Errorf("errors = %v", errs)
+	}
+
+	if err := (&Config{}).Validate(); err == nil || !strings.Contains(err.Error(), "jwt_key") || !strings.Contains(err.Error(), "mongo.uri") {
+		t.Errorf("Validate(empty) = %v", err)
+	}
+	if err := (&Config{DefaultLanguage: "fr"}).Validate(); err == nil || !strings.Contains(err.Error(), "default_language") {
+		t.Errorf("Validate(default_language) = %v", err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfigFile(t, validConfig)
+	os.Setenv("NIU_CUBE_JWT_KEY", "env-key")
+	defer os.Unsetenv("NIU_CUBE_JWT_KEY")
+	conf, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() = %v", err)
+	}
+	if conf.JwtKey != "env-key" {
+		t.Errorf("jwt key = %q", conf.JwtKey)
+	}
+
+	if _, err := LoadConfig(writeConfigFile(t, `{"listen_port": 5080, "jwt_key": "Must，<密钥>"}`)); err == nil {
+		t.Errorf("LoadConfig(placeholder) succeeded")
+	}
+}
+
+func TestReloadConf(t *testing.T) {
+	old := DefaultConf
+	defer func() {
+		DefaultConf = old
+		reloadable.Store(newReloadableConfig(&DefaultConf))
+	}()
+	path := writeConfigFile(t, validConfig)
+	InitConf(path)
+	if Reloadable().WelcomeImage != "https://example.com/welcome.png" {
+		t.Fatalf("welcome image = %q", Reloadable().WelcomeImage)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(strings.Replace(validConfig, "welcome.png", "welcome2.png", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReloadConf(path); err != nil {
+		t.Fatalf("ReloadConf() = %v", err)
+	}
+	if Reloadable().WelcomeImage != "https://example.com/welcome2.png" {
+		t.Errorf("welcome image after reload = %q", Reloadable().WelcomeImage)
+	}
+
+	// 配置有误时保留当前配置。
+	if err := ioutil.WriteFile(path, []byte(`{"listen_port": 5080}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReloadConf(path); err == nil {
+		t.Errorf("ReloadConf(invalid) succeeded")
+	}
+	if Reloadable().WelcomeImage != "https://example.com/welcome2.png" || len(Reloadable().DefaultAvatars) != 1 {
+		t.Errorf("config changed by invalid reload: %+v", Reloadable())
+	}
+}
@@ -1,7 +1,10 @@
 package utils
 
 import (
+	crand "crypto/rand"
+	"encoding/hex"
 	"math/rand"
+	"regexp"
 	"strings"
 	"time"
 )
@@ -26,6 +29,54 @@ func GenerateID() string {
 	return stringBuilder.String()
 }
 
+// GenerateSecureToken 使用加密安全的随机数生成长度为2*n的十六进制字符串，用于token等不可猜测的标识。
+func GenerateSecureToken(n int) string {
+	buf := make([]byte, n)
+	if _, err := crand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// DefaultPhoneCountryCode 未配置时默认的手机号国家码。
+const DefaultPhoneCountryCode = "86"
+
+var (
+	chinaMobileRegExp     = regexp.MustCompile(`^1[3-9][0-9]{9}$`)
+	nationalPhoneRegExp   = regexp.MustCompile(`^[0-9]{6,14}$`)
+	e164PhoneDigitRegExp  = regexp.MustCompile(`^[1-9][0-9]{7,14}$`)
+	phoneSeparatorRemover = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "")
+)
+
+// NormalizePhone 校验并规范化手机号。
+// 去掉空格、横线等分隔符；国家码为defaultCountryCode的号码去掉国家码，只保留国内号码；其他国家码的号码统一为 +<国家码><号码>。
+// 返回的bool表示手机号是否合法。
+func NormalizePhone(phone string, defaultCountryCode string) (string, bool) {
+	if defaultCountryCode == "" {
+		defaultCountryCode = DefaultPhoneCountryCode
+	}
+	phone = phoneSeparatorRemover.Replace(strings.TrimSpace(phone))
+	if strings.HasPrefix(phone, "00") {
+		phone = "+" + phone[2:]
+	}
+	if strings.HasPrefix(phone, "+") {
+		digits := phone[1:]
+		if !e164PhoneDigitRegExp.MatchString(digits) {
+			return "", false
+		}
+		if !strings.HasPrefix(digits, defaultCountryCode) {
+			return "+" + digits, true
+		}
+		phone = digits[len(defaultCountryCode):]
+	} else if defaultCountryCode == DefaultPhoneCountryCode && len(phone) == 13 && strings.HasPrefix(phone, DefaultPhoneCountryCode) {
+		phone = phone[len(DefaultPhoneCountryCode):]
+	}
+	if defaultCountryCode == DefaultPhoneCountryCode {
+		return phone, chinaMobileRegExp.MatchString(phone)
+	}
+	return phone, nationalPhoneRegExp.MatchString(phone)
+}
+
 func TimedTask(t time.Time, task func()) {
 	if t.Before(time.Now()) {
 		go task()
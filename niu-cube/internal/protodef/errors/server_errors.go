@@ -42,6 +42,20 @@ const (
 	ServerErrorUserWatching         = 10010
 	ServerErrorSMSSendTooFrequent   = 10011
 	ServerErrorUserJoined           = 10012
+	ServerErrorTokenInvalid         = 10013
+	ServerErrorTokenExpired         = 10014
+	ServerErrorSMSQuotaExceeded     = 10015
+	ServerErrorSMSValidateLocked    = 10016
+	ServerErrorCaptchaInvalid       = 10017
+	ServerErrorPhoneInvalid         = 10018
+	ServerErrorEmailUsed            = 10019
+	ServerErrorWrongPassword        = 10020
+	ServerErrorEmailNotVerified     = 10021
+	ServerErrorPasswordTooWeak      = 10022
+	ServerErrorPhoneUsed            = 10023
+	ServerErrorWeixinCodeInvalid    = 10024
+	ServerErrorLoginLocked          = 10025
+	ServerErrorMailQuotaExceeded    = 10026
 	ServerErrorMongoOpFail          = 11000
 	// 2开头表示外部服务错误。
 	ServerErrorSMSSendFail = 20001
@@ -0,0 +1,305 @@
+package form
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+)
+
+const (
+	ErrRequiredMsg      = "不能为空"
+	ErrStringValueMsg   = "必须为字符串"
+	ErrEntryKeyMsg      = "key长度应为1到64个字符"
+	ErrRoomTitleMsg     = "房间标题长度不应超过64个字符"
+	ErrRoomDescMsg      = "房间描述长度不应超过512个字符"
+	ErrRoomJoinMsg      = "roomId与params中的invitationCode至少需要一个"
+	ErrUserNameMsg      = "名称长度不应超过64个字符"
+	ErrUserProfileMsg   = "个人简介长度不应超过512个字符"
+	ErrURLLengthMsg     = "地址长度不应超过1024个字符"
+	ErrRoomTypeMsg      = "房间类型长度应为1到32个字符"
+	maxEntryKeyLength   = 64
+	maxRoomTypeLength   = 32
+	maxURLLength        = 1024
+	maxNameLength       = 64
+	maxLongStringLength = 512
+)
+
+// BaseEntryForm 通用房间、麦位、用户的属性或参数项，value可以是任意JSON值。
+type BaseEntryForm struct {
+	Key   string      `json:"key" form:"key"`
+	Value interface{} `json:"value" form:"value"`
+}
+
+func (e BaseEntryForm) Validate() error {
+	return validation.ValidateStruct(&e,
+		validation.Field(&e.Key, validation.Required.Error(ErrRequiredMsg), validation.RuneLength(1, maxEntryKeyLength).Error(ErrEntryKeyMsg)),
+	)
+}
+
+// BaseEntries 转换为持久化的属性项，所有项使用相同的状态。
+func BaseEntries(entries []BaseEntryForm, status int) []model.BaseEntryDo {
+	res := make([]model.BaseEntryDo, 0, len(entries))
+	for _, entry := range entries {
+		res = append(res, model.BaseEntryDo{
+			Key:    entry.Key,
+			Value:  entry.Value,
+			Status: status,
+		})
+	}
+	return res
+}
+
+// StringEntry 查找key对应的字符串值，key不存在时返回false。值的类型已在Validate中校验。
+func StringEntry(entries []BaseEntryForm, key string) (string, bool) {
+	for _, entry := range entries {
+		if entry.Key == key {
+			val, ok := entry.Value.(string)
+			return val, ok
+		}
+	}
+	return "", false
+}
+
+// stringEntries 校验每个属性项，并要求给定key的值为字符串。
+// 自定义规则出错时ozzo不再校验元素本身，这里一并校验以返回所有字段的错误。
+func stringEntries(keys ...string) validation.Rule {
+	return validation.By(func(value interface{}) error {
+		entries, _ := value.([]BaseEntryForm)
+		errs := validation.Errors{}
+		for i, entry := range entries {
+			entryErrs := validation.Errors{}
+			if err := entry.Validate(); err != nil {
+				if fieldErrs, ok := err.(validation.Errors); ok {
+					entryErrs = fieldErrs
+				}
+			}
+			for _, key := range keys {
+				if _, ok := entry.Value.(string); entry.Key == key && !ok {
+					entryErrs["value"] = errors.New(ErrStringValueMsg)
+				}
+			}
+			if len(entryErrs) > 0 {
+				errs[fmt.Sprintf("%d", i)] = entryErrs
+			}
+		}
+		if len(errs) == 0 {
+			return nil
+		}
+		return errs
+	})
+}
+
+func roomTypeRules() []validation.Rule {
+	return []validation.Rule{
+		validation.Required.Error(ErrRequiredMsg),
+		validation.RuneLength(1, maxRoomTypeLength).Error(ErrRoomTypeMsg),
+	}
+}
+
+// BaseRoomCreateForm 创建通用房间。
+type BaseRoomCreateForm struct {
+	Title  string          `json:"title" form:"title"`
+	Desc   string          `json:"desc" form:"desc"`
+	Image  string          `json:"image" form:"image"`
+	Type   string          `json:"type" form:"type"`
+	Attrs  []BaseEntryForm `json:"attrs" form:"attrs"`
+	Params []BaseEntryForm `json:"params" form:"params"`
+}
+
+func (f *BaseRoomCreateForm) Validate() error {
+	return validation.ValidateStruct(f,
+		validation.Field(&f.Title, validation.Required.Error(ErrRequiredMsg), validation.RuneLength(0, maxNameLength).Error(ErrRoomTitleMsg)),
+		validation.Field(&f.Desc, validation.RuneLength(0, maxLongStringLength).Error(ErrRoomDescMsg)),
+		validation.Field(&f.Image, validation.RuneLength(0, maxURLLength).Error(ErrURLLengthMsg)),
+		validation.Field(&f.Type, roomTypeRules()...),
+		validation.Field(&f.Attrs),
+		validation.Field(&f.Params),
+	)
+}
+
+// BaseRoomJoinForm 加入通用房间，roomId为空时使用params中的invitationCode查找房间。
+type BaseRoomJoinForm struct {
+	RoomId string          `json:"roomId" form:"roomId"`
+	Type   string          `json:"type" form:"type"`
+	Params []BaseEntryForm `json:"params" form:"params"`
+}
+
+func (f *BaseRoomJoinForm) Validate() error {
+	err := validation.ValidateStruct(f,
+		validation.Field(&f.Type, roomTypeRules()...),
+		validation.Field(&f.Params, stringEntries("role", "invitationCode")),
+	)
+	if err != nil {
+		return err
+	}
+	if code, _ := StringEntry(f.Params, "invitationCode"); f.RoomId == "" && code == "" {
+		return validation.Errors{"roomId": errors.New(ErrRoomJoinMsg)}
+	}
+	return nil
+}
+
+// BaseRoomLeaveForm 离开通用房间。
+type BaseRoomLeaveForm struct {
+	RoomId string `json:"roomId" form:"roomId"`
+	Type   string `json:"type" form:"type"`
+}
+
+func (f *BaseRoomLeaveForm) Validate() error {
+	return validation.ValidateStruct(f,
+		validation.Field(&f.RoomId, validation.Required.Error(ErrRequiredMsg)),
+		validation.Field(&f.Type, roomTypeRules()...),
+	)
+}
+
+// BaseRoomUpdateForm 更新通用房间的属性。
+type BaseRoomUpdateForm struct {
+	RoomId string          `json:"roomId" form:"roomId"`
+	Type   string          `json:"type" form:"type"`
+	Attrs  []BaseEntryForm `json:"attrs" form:"attrs"`
+}
+
+func (f *BaseRoomUpdateForm) Validate() error {
+	return validation.ValidateStruct(f,
+		validation.Field(&f.RoomId, validation.Required.Error(ErrRequiredMsg)),
+		validation.Field(&f.Type, roomTypeRules()...),
+		validation.Field(&f.Attrs),
+	)
+}
+
+// BaseMicUpForm 上麦。
+type BaseMicUpForm struct {
+	RoomId        string          `json:"roomId" form:"roomId"`
+	Type          string          `json:"type" form:"type"`
+	UserExtension string          `json:"userExtension" form:"userExtension"`
+	Attrs         []BaseEntryForm `json:"attrs" form:"attrs"`
+	Params        []BaseEntryForm `json:"params" form:"params"`
+}
+
+func (f *BaseMicUpForm) Validate() error {
+	return validation.ValidateStruct(f,
+		validation.Field(&f.RoomId, validation.Required.Error(ErrRequiredMsg)),
+		validation.Field(&f.Type, roomTypeRules()...),
+		validation.Field(&f.Attrs),
+		validation.Field(&f.Params),
+	)
+}
+
+// BaseMicDownForm 下麦，uid为下麦的用户。
+type BaseMicDownForm struct {
+	RoomId string `json:"roomId" form:"roomId"`
+	Uid    string `json:"uid" form:"uid"`
+	Type   string `json:"type" form:"type"`
+}
+
+func (f *BaseMicDownForm) Validate() error {
+	return validation.ValidateStruct(f,
+		validation.Field(&f.RoomId, validation.Required.Error(ErrRequiredMsg)),
+		validation.Field(&f.Uid, validation.Required.Error(ErrRequiredMsg)),
+		validation.Field(&f.Type, roomTypeRules()...),
+	)
+}
+
+// BaseMicUpdateForm 更新用户所在麦位的属性。
+type BaseMicUpdateForm struct {
+	RoomId string          `json:"roomId" form:"roomId"`
+	Uid    string          `json:"uid" form:"uid"`
+	Type   string          `json:"type" form:"type"`
+	Attrs  []BaseEntryForm `json:"attrs" form:"attrs"`
+}
+
+func (f *BaseMicUpdateForm) Validate() error {
+	return validation.ValidateStruct(f,
+		validation.Field(&f.RoomId, validation.Required.Error(ErrRequiredMsg)),
+		validation.Field(&f.Uid, validation.Required.Error(ErrRequiredMsg)),
+		validation.Field(&f.Type, roomTypeRules()...),
+		validation.Field(&f.Attrs),
+	)
+}
+
+// RoomTypeForm 带有房间类型的表单，处理函数据此在请求context中记录房间类型。
+type RoomTypeForm interface {
+	RoomType() string
+}
+
+func (f *BaseRoomCreateForm) RoomType() string { return f.Type }
+func (f *BaseRoomJoinForm) RoomType() string   { return f.Type }
+func (f *BaseRoomLeaveForm) RoomType() string  { return f.Type }
+func (f *BaseRoomUpdateForm) RoomType() string { return f.Type }
+func (f *BaseMicUpForm) RoomType() string      { return f.Type }
+func (f *BaseMicDownForm) RoomType() string    { return f.Type }
+func (f *BaseMicUpdateForm) RoomType() string  { return f.Type }
+
+// RoomIDForm 指定了房间ID的表单，处理函数据此在审计日志中记录操作的房间。
+type RoomIDForm interface {
+	RoomID() string
+}
+
+func (f *BaseRoomJoinForm) RoomID() string   { return f.RoomId }
+func (f *BaseRoomLeaveForm) RoomID() string  { return f.RoomId }
+func (f *BaseRoomUpdateForm) RoomID() string { return f.RoomId }
+func (f *BaseMicUpForm) RoomID() string      { return f.RoomId }
+func (f *BaseMicDownForm) RoomID() string    { return f.RoomId }
+func (f *BaseMicUpdateForm) RoomID() string  { return f.RoomId }
+
+// BaseUserUpdateForm 更新通用用户信息，未提供的字段保持不变。
+type BaseUserUpdateForm struct {
+	Name     *string `json:"name" form:"name"`
+	Nickname *string `json:"nickname" form:"nickname"`
+	Avatar   *string `json:"avatar" form:"avatar"`
+	Profile  *string `json:"profile" form:"profile"`
+}
+
+func (f *BaseUserUpdateForm) Validate() error {
+	return validation.ValidateStruct(f,
+		validation.Field(&f.Name, validation.RuneLength(0, maxNameLength).Error(ErrUserNameMsg)),
+		validation.Field(&f.Nickname, validation.RuneLength(0, maxNameLength).Error(ErrUserNameMsg)),
+		validation.Field(&f.Avatar, validation.RuneLength(0, maxURLLength).Error(ErrURLLengthMsg)),
+		validation.Field(&f.Profile, validation.RuneLength(0, maxLongStringLength).Error(ErrUserProfileMsg)),
+	)
+}
+
+// FieldErrors 把表单解析与校验的错误展开为逐个字段的错误，嵌套字段使用点号连接，如attrs.0.key，
+// 错误原因翻译为给定语言。无法定位到字段的错误返回nil。
+func FieldErrors(err error, lang string) []model.FieldError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+		reason := Localize(newMessageError(errTypeMsg, typeErr.Type.String()), lang)
+		return []model.FieldError{{Field: field, Reason: reason.Error()}}
+	}
+	var errs validation.Errors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	res := make([]model.FieldError, 0, len(errs))
+	flattenErrors("", Localize(errs, lang).(validation.Errors), &res)
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Field < res[j].Field
+	})
+	return res
+}
+
+func flattenErrors(prefix string, errs validation.Errors, res *[]model.FieldError) {
+	for field, err := range errs {
+		if err == nil {
+			continue
+		}
+		path := field
+		if prefix != "" {
+			path = prefix + "." + field
+		}
+		if nested, ok := err.(validation.Errors); ok {
+			flattenErrors(path, nested, res)
+			continue
+		}
+		*res = append(*res, model.FieldError{Field: path, Reason: strings.TrimSpace(err.Error())})
+	}
+}
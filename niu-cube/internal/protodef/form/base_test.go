@@ -0,0 +1,249 @@
+package form
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/solutions/niu-cube/internal/common/i18n"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+)
+
+// validateJSON 按处理函数的方式解析并校验请求体，返回逐个字段的错误。
+func validateJSON(t *testing.T, body string, f interface{ Validate() error }) []model.FieldError {
+	t.Helper()
+	if err := json.Unmarshal([]byte(body), f); err != nil {
+		fieldErrs := FieldErrors(err, i18n.Chinese)
+		if len(fieldErrs) == 0 {
+			t.Fatalf("decode error without field: %v", err)
+		}
+		return fieldErrs
+	}
+	err := f.Validate()
+	if err == nil {
+		return nil
+	}
+	fieldErrs := FieldErrors(err, i18n.Chinese)
+	if len(fieldErrs) == 0 {
+		t.Fatalf("validation error without field: %v", err)
+	}
+	return fieldErrs
+}
+
+func fieldNames(errs []model.FieldError) []string {
+	names := make([]string, 0, len(errs))
+	for _, e := range errs {
+		names = append(names, e.Field)
+	}
+	return names
+}
+
+func TestBaseRoomCreateForm(t *testing.T) {
+	cases := []struct {
+		name       string
+		body       string
+		wantFields []string
+	}{
+		{
+			name: "valid with non-string attr values",
+			body: `{"title":"t","type":"voiceChat","attrs":[{"key":"a","value":1},{"key":"b","value":{"x":[1,2]}}],"params":[{"key":"c","value":null}]}`,
+		},
+		{
+			name:       "missing fields",
+			body:       `{}`,
+			wantFields: []string{"title", "type"},
+		},
+		{
+			name:       "entries without key",
+			body:       `{"title":"t","type":"voiceChat","attrs":[{"value":"v"},{"key":"ok"}],"params":[{"key":""}]}`,
+			wantFields: []string{"attrs.0.key", "params.0.key"},
+		},
+		{
+			name:       "too long key",
+			body:       `{"title":"t","type":"voiceChat","attrs":[{"key":"` + strings.Repeat("k", maxEntryKeyLength+1) + `"}]}`,
+			wantFields: []string{"attrs.0.key"},
+		},
+		{
+			name:       "attrs not an array",
+			body:       `{"title":"t","type":"voiceChat","attrs":"a=b"}`,
+			wantFields: []string{"attrs"},
+		},
+		{
+			name:       "attr not an object",
+			body:       `{"title":"t","type":"voiceChat","attrs":[1]}`,
+			wantFields: []string{"attrs.0"},
+		},
+		{
+			name:       "key not a string",
+			body:       `{"title":"t","type":"voiceChat","params":[{"key":1,"value":"v"}]}`,
+			wantFields: []string{"params.0.key"},
+		},
+		{
+			name:       "title not a string",
+			body:       `{"title":1,"type":"voiceChat"}`,
+			wantFields: []string{"title"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := fieldNames(validateJSON(t, tc.body, &BaseRoomCreateForm{}))
+			if len(got) == 0 && len(tc.wantFields) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tc.wantFields) {
+				t.Fatalf("fields = %v, want %v", got, tc.wantFields)
+			}
+		})
+	}
+}
+
+func TestBaseRoomJoinForm(t *testing.T) {
+	cases := []struct {
+		name       string
+		body       string
+		wantFields []string
+	}{
+		{
+			name: "room id",
+			body: `{"roomId":"r1","type":"voiceChat","params":[{"key":"role","value":"roomAudience"}]}`,
+		},
+		{
+			name: "invitation code",
+			body: `{"type":"voiceChat","params":[{"key":"invitationCode","value":"123456"}]}`,
+		},
+		{
+			name:       "neither room id nor invitation code",
+			body:       `{"type":"voiceChat","params":[{"key":"role","value":"roomAudience"}]}`,
+			wantFields: []string{"roomId"},
+		},
+		{
+			name:       "non-string role and invitation code",
+			body:       `{"roomId":"r1","type":"voiceChat","params":[{"key":"role","value":1},{"key":"invitationCode","value":["x"]}]}`,
+			wantFields: []string{"params.0.value", "params.1.value"},
+		},
+		{
+			name:       "non-string value reported with missing key",
+			body:       `{"type":"voiceChat","params":[{"key":"","value":1},{"key":"invitationCode","value":false}]}`,
+			wantFields: []string{"params.0.key", "params.1.value"},
+		},
+		{
+			name: "other params may be any value",
+			body: `{"roomId":"r1","type":"voiceChat","params":[{"key":"extra","value":{"a":1}}]}`,
+		},
+		{
+			name:       "missing type",
+			body:       `{"roomId":"r1"}`,
+			wantFields: []string{"type"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := fieldNames(validateJSON(t, tc.body, &BaseRoomJoinForm{}))
+			if len(got) == 0 && len(tc.wantFields) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tc.wantFields) {
+				t.Fatalf("fields = %v, want %v", got, tc.wantFields)
+			}
+		})
+	}
+}
+
+func TestBaseMicForms(t *testing.T) {
+	got := fieldNames(validateJSON(t, `{"attrs":[{"value":1}],"params":[{"key":"k","value":[1]}]}`, &BaseMicUpForm{}))
+	want := []string{"attrs.0.key", "roomId", "type"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("mic up fields = %v, want %v", got, want)
+	}
+	got = fieldNames(validateJSON(t, `{"roomId":"r1","type":"voiceChat"}`, &BaseMicDownForm{}))
+	if want := []string{"uid"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("mic down fields = %v, want %v", got, want)
+	}
+	got = fieldNames(validateJSON(t, `{"roomId":"r1","uid":"u1","type":"voiceChat","attrs":{"key":"k"}}`, &BaseMicUpdateForm{}))
+	if want := []string{"attrs"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("mic update fields = %v, want %v", got, want)
+	}
+}
+
+func TestBaseUserUpdateForm(t *testing.T) {
+	got := fieldNames(validateJSON(t, `{"name":"`+strings.Repeat("名", maxNameLength+1)+`","profile":"`+strings.Repeat("a", maxLongStringLength+1)+`"}`, &BaseUserUpdateForm{}))
+	if want := []string{"name", "profile"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("fields = %v, want %v", got, want)
+	}
+	got = fieldNames(validateJSON(t, `{"nickname":1}`, &BaseUserUpdateForm{}))
+	if want := []string{"nickname"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("fields = %v, want %v", got, want)
+	}
+	if got := validateJSON(t, `{"avatar":"http://example.com/a.png"}`, &BaseUserUpdateForm{}); len(got) != 0 {
+		t.Fatalf("unexpected errors %v", got)
+	}
+}
+
+func TestStringEntry(t *testing.T) {
+	entries := []BaseEntryForm{{Key: "a", Value: "x"}, {Key: "b", Value: 1}}
+	if val, ok := StringEntry(entries, "a"); !ok || val != "x" {
+		t.Fatalf("StringEntry(a) = %q, %v", val, ok)
+	}
+	if _, ok := StringEntry(entries, "b"); ok {
+		t.Fatalf("StringEntry(b) accepted non-string value")
+	}
+	if _, ok := StringEntry(entries, "c"); ok {
+		t.Fatalf("StringEntry(c) found missing key")
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	if got := FieldErrors(nil, i18n.Chinese); got != nil {
+		t.Fatalf("FieldErrors(nil) = %v", got)
+	}
+	var syntaxErr error = json.Unmarshal([]byte(`{`), &BaseRoomCreateForm{})
+	if got := FieldErrors(syntaxErr, i18n.Chinese); got != nil {
+		t.Fatalf("FieldErrors(syntax error) = %v", got)
+	}
+	errs := validateJSON(t, `{"attrs":[{"key":""}]}`, &BaseRoomUpdateForm{})
+	for _, e := range errs {
+		if e.Reason == "" {
+			t.Fatalf("empty reason for %s", e.Field)
+		}
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	err := (&BaseRoomCreateForm{Type: "voiceChat", Attrs: []BaseEntryForm{{Key: ""}}}).Validate()
+	reasons := map[string]string{}
+	for _, e := range FieldErrors(err, i18n.English) {
+		reasons[e.Field] = e.Reason
+	}
+	if reasons["title"] != "cannot be blank" || reasons["attrs.0.key"] != "cannot be blank" {
+		t.Errorf("english reasons = %v", reasons)
+	}
+	if msg := Localize(err, i18n.English).Error(); msg != "attrs: (0: (key: cannot be blank.).); title: cannot be blank." {
+		t.Errorf("english message = %q", msg)
+	}
+	if msg := Localize(err, i18n.Chinese).Error(); msg != err.Error() {
+		t.Errorf("chinese message = %q, want %q", msg, err.Error())
+	}
+
+	// ozzo内置规则的默认信息翻译为中文，代码中给出的信息保留。
+	err = (&IECreateForm{Title: strings.Repeat("t", 21)}).Validate()
+	reasons = map[string]string{}
+	for _, e := range FieldErrors(err, i18n.Chinese) {
+		reasons[e.Field] = e.Reason
+	}
+	if reasons["notice"] != "不能为空" || reasons["title"] != ErrTitleLengthMsg {
+		t.Errorf("chinese reasons = %v", reasons)
+	}
+
+	if err := Localize(ErrPhoneCollision, i18n.English); err.Error() != "interviewer and candidate can not use the same phone number" {
+		t.Errorf("Localize(ErrPhoneCollision) = %v", err)
+	}
+	typeErr := json.Unmarshal([]byte(`{"title":1}`), &BaseRoomCreateForm{})
+	if errs := FieldErrors(typeErr, i18n.English); len(errs) != 1 || errs[0].Reason != "should be of type string" {
+		t.Errorf("FieldErrors(type error) = %v", errs)
+	}
+	versionErr := (&VersionCreateForm{Platform: "web"}).Validate()
+	if err := Localize(versionErr, i18n.English); !strings.HasSuffix(err.Error(), "must be one of [android ios]") {
+		t.Errorf("Localize(version error) = %v", err)
+	}
+}
@@ -61,10 +61,9 @@ func (i *InterviewCreateForm) Validate() error {
 }
 
 func (i *InterviewCreateForm) FillDefault(c *gin.Context) {
-	val, ok := c.Get(model.UserContextKey)
-	user := val.(model.AccountDo)
-	if !ok {
-		defaultLogger.Infof("error get user from context")
+	user, ok, err := model.ContextAccount(c)
+	if !ok || err != nil {
+		defaultLogger.Infof("error get user from context, error %v", err)
 		return
 	}
 	if i.InterviewerName == "" {
@@ -0,0 +1,104 @@
+package form
+
+import (
+	"errors"
+	"fmt"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+
+	"github.com/solutions/niu-cube/internal/common/i18n"
+)
+
+const (
+	errTypeMsg    = "应为%s类型"
+	errValueInMsg = "%v 必须在 %v中"
+)
+
+// messages 校验错误信息的翻译，key为代码中的中文信息或格式，以及ozzo内置规则的错误码。
+var messages = map[string]map[string]string{
+	i18n.English: {
+		ErrRequiredMsg:               "cannot be blank",
+		ErrStringValueMsg:            "must be a string",
+		ErrEntryKeyMsg:               "key must be 1 to 64 characters",
+		ErrRoomTitleMsg:              "room title must be no more than 64 characters",
+		ErrRoomDescMsg:               "room description must be no more than 512 characters",
+		ErrRoomJoinMsg:               "either roomId or invitationCode in params is required",
+		ErrUserNameMsg:               "name must be no more than 64 characters",
+		ErrUserProfileMsg:            "profile must be no more than 512 characters",
+		ErrURLLengthMsg:              "url must be no more than 1024 characters",
+		ErrRoomTypeMsg:               "room type must be 1 to 32 characters",
+		ErrTitleLengthMsg:            "room title must be no more than 20 characters",
+		ErrNoticeLengthMsg:           "room notice must be no more than 100 characters",
+		ErrPhoneMsg:                  "invalid phone number",
+		ErrTimeMsg:                   "time must be later than now",
+		ErrTitleMsg:                  "title is too long",
+		ErrRoomIdMsg:                 "invalid room id",
+		ErrVersionMsg:                "version must be in the format of V1.1.1",
+		ErrPhoneCollision.Error():    "interviewer and candidate can not use the same phone number",
+		ErrRoleMsg.Error():           "invalid role",
+		ErrInterviewIdNeeded.Error(): "interviewId is required",
+		ErrBoardLocked.Error():       "the board is locked",
+		ErrVersionIdNeeded.Error():   "versionId is required",
+		errTypeMsg:                   "should be of type %s",
+		errValueInMsg:                "%v must be one of %v",
+	},
+	i18n.Chinese: {
+		"validation_required":      "不能为空",
+		"validation_match_invalid": "格式不正确",
+	},
+}
+
+// translate 返回message在给定语言下的翻译，没有翻译时返回false。
+func translate(lang, message string) (string, bool) {
+	translated, ok := messages[lang][message]
+	return translated, ok
+}
+
+// messageError 带参数的校验错误，Localize按格式查找翻译。
+type messageError struct {
+	format string
+	args   []interface{}
+}
+
+func newMessageError(format string, args ...interface{}) error {
+	return &messageError{format: format, args: args}
+}
+
+func (e *messageError) Error() string {
+	return fmt.Sprintf(e.format, e.args...)
+}
+
+// Localize 返回把校验错误翻译为给定语言后的错误，validation.Errors中的各字段逐个翻译，没有翻译的错误原样保留。
+func Localize(err error, lang string) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case validation.Errors:
+		res := validation.Errors{}
+		for field, fieldErr := range e {
+			res[field] = Localize(fieldErr, lang)
+		}
+		return res
+	case validation.Error:
+		if message, ok := translate(lang, e.Message()); ok {
+			return e.SetMessage(message)
+		}
+		// 代码中给出的信息不在目标语言的翻译中时保留原信息，只翻译ozzo内置规则的默认信息。
+		if _, custom := messages[i18n.English][e.Message()]; custom {
+			return e
+		}
+		if message, ok := translate(lang, e.Code()); ok {
+			return e.SetMessage(message)
+		}
+		return e
+	case *messageError:
+		if format, ok := translate(lang, e.format); ok {
+			return newMessageError(format, e.args...)
+		}
+		return e
+	}
+	if message, ok := translate(lang, err.Error()); ok {
+		return errors.New(message)
+	}
+	return err
+}
@@ -28,7 +28,7 @@ type RepairJoinForm struct {
 func (i *RepairCreateForm) Validate() error {
 	err := validation.ValidateStruct(i,
 		validation.Field(&i.Title, validation.Required, validation.Length(0, 100).Error(ErrTitleMsg)),
-		validation.Field(&i.Role, validation.Required.Error("必填")),
+		validation.Field(&i.Role, validation.Required.Error(ErrRequiredMsg)),
 	)
 	if err == nil {
 		role := i.Role
@@ -43,7 +43,7 @@ func (i *RepairCreateForm) Validate() error {
 func (i *RepairJoinForm) Validate() error {
 	err := validation.ValidateStruct(i,
 		validation.Field(&i.RoomId, validation.Required, validation.Length(0, 100).Error(ErrRoomIdMsg)),
-		validation.Field(&i.Role, validation.Required.Error("必填")),
+		validation.Field(&i.Role, validation.Required.Error(ErrRequiredMsg)),
 	)
 	if err == nil {
 		role := i.Role
@@ -53,7 +53,7 @@ func shallowIn(tag string, target interface{}, values ...interface{}) error {
 		//fmt.Printf("%t %t\n",value,item)
 	}
 	if !ok {
-		return fmt.Errorf("%v 必须在 %v中", tag, values)
+		return newMessageError(errValueInMsg, tag, values)
 	}
 	return nil
 }
@@ -69,9 +69,9 @@ func (v *VersionCreateForm) Validate() error {
 		return err
 	}
 	err = validation.ValidateStruct(v,
-		validation.Field(&v.AppName, validation.Required.Error("必填")),
-		validation.Field(&v.Platform, validation.Required.Error("必填")),
-		validation.Field(&v.Version, validation.Required.Error("必填"), validation.Match(versionReg).Error(ErrVersionMsg)),
+		validation.Field(&v.AppName, validation.Required.Error(ErrRequiredMsg)),
+		validation.Field(&v.Platform, validation.Required.Error(ErrRequiredMsg)),
+		validation.Field(&v.Version, validation.Required.Error(ErrRequiredMsg), validation.Match(versionReg).Error(ErrVersionMsg)),
 	)
 	val, ok := err.(validation.InternalError)
 	if ok {
@@ -0,0 +1,70 @@
+package model
+
+import "time"
+
+/*
+	api_key.go: 服务端之间调用使用的API key。API key不属于任何账号，只具备创建时授予的权限。
+*/
+
+// ApiKeyAccountPrefix 使用API key访问时，记录调用方（如数据的创建者）所用ID的前缀，用于与真实账号区分。
+const ApiKeyAccountPrefix = "apikey-"
+
+// ApiKeyDo API key的持久化对象。key的原文为 <ID>.<secret>，只保存secret的哈希。
+type ApiKeyDo struct {
+	ID         string `json:"id" bson:"_id"`
+	Name       string `json:"name" bson:"name"`
+	SecretHash string `json:"-" bson:"secretHash"`
+	// Permissions API key具备的权限。
+	Permissions []Permission `json:"permissions" bson:"permissions"`
+	// AllowedIPs 允许使用该key的IP或CIDR，为空时不限制。
+	AllowedIPs []string `json:"allowedIps,omitempty" bson:"allowedIps,omitempty"`
+	// Creator 创建该key的管理员账号ID。
+	Creator    string    `json:"creator" bson:"creator"`
+	CreateTime time.Time `json:"createTime" bson:"createTime"`
+	// RotateTime 最近一次轮换secret的时间。
+	RotateTime time.Time `json:"rotateTime,omitempty" bson:"rotateTime,omitempty"`
+	// ExpireTime 过期时间，为零值时不过期。
+	ExpireTime   time.Time `json:"expireTime,omitempty" bson:"expireTime,omitempty"`
+	RevokeTime   time.Time `json:"revokeTime,omitempty" bson:"revokeTime,omitempty"`
+	LastUsedTime time.Time `json:"lastUsedTime,omitempty" bson:"lastUsedTime,omitempty"`
+	LastUsedIP   string    `json:"lastUsedIp,omitempty" bson:"lastUsedIp,omitempty"`
+}
+
+// HasPermission 判断API key是否被授予某项权限。
+func (k *ApiKeyDo) HasPermission(permission Permission) bool {
+	for _, p := range k.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Revoked 判断API key是否已吊销。
+func (k *ApiKeyDo) Revoked() bool {
+	return !k.RevokeTime.IsZero()
+}
+
+// Expired 判断API key是否已过期。
+func (k *ApiKeyDo) Expired(now time.Time) bool {
+	return !k.ExpireTime.IsZero() && k.ExpireTime.Before(now)
+}
+
+// CreateApiKeyArgs 创建API key的参数。
+type CreateApiKeyArgs struct {
+	Name         string       `json:"name" form:"name"`
+	Permissions  []Permission `json:"permissions" form:"permissions"`
+	AllowedIPs   []string     `json:"allowedIps" form:"allowedIps"`
+	ExpireSecond int64        `json:"expireSecond" form:"expireSecond"`
+}
+
+// ApiKeyResponse API key信息，Key 为key原文，只在创建与轮换时返回。
+type ApiKeyResponse struct {
+	ApiKeyDo
+	Key string `json:"key,omitempty"`
+}
+
+// ApiKeyListResponse API key列表。
+type ApiKeyListResponse struct {
+	List []ApiKeyDo `json:"list"`
+}
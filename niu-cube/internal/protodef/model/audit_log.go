@@ -0,0 +1,59 @@
+package model
+
+import "time"
+
+/*
+	audit_log.go: 审计日志，每个API请求记录一条，由后台批量写入数据库。
+*/
+
+// AuditResource 请求操作的资源，如房间、面试、考试。
+type AuditResource struct {
+	// Type 资源类型，取自路由参数或请求参数的名称，如roomId、interviewId。
+	Type string `json:"type" bson:"type"`
+	ID   string `json:"id" bson:"id"`
+}
+
+// AuditLogDo 审计日志记录。
+type AuditLogDo struct {
+	ID        string    `json:"id" bson:"_id"`
+	Time      time.Time `json:"time" bson:"time"`
+	RequestID string    `json:"requestId" bson:"requestId"`
+	// UserID 登录用户的ID，使用API key访问时为空。
+	UserID string `json:"userId,omitempty" bson:"userId,omitempty"`
+	// UserPhone 登录用户的手机号，注销账号时据此删除记录。
+	UserPhone string `json:"userPhone,omitempty" bson:"userphone,omitempty"`
+	ApiKeyID  string `json:"apiKeyId,omitempty" bson:"apiKeyId,omitempty"`
+	Method    string `json:"method" bson:"method"`
+	// Route 路由模板，如/v1/ktv/operateSong，未匹配路由时为请求路径。
+	Route     string          `json:"route" bson:"route"`
+	Status    int             `json:"status" bson:"status"`
+	LatencyMs int64           `json:"latencyMs" bson:"latencyMs"`
+	ClientIP  string          `json:"clientIp" bson:"clientIp"`
+	Resources []AuditResource `json:"resources,omitempty" bson:"resources,omitempty"`
+	// Msg 操作描述，如"user 138xxxx 登入"。
+	Msg      string    `json:"msg" bson:"msg"`
+	ExpireAt time.Time `json:"-" bson:"expireAt"`
+}
+
+// AuditLogFilter 查询审计日志的条件，为零值的条件不生效。
+type AuditLogFilter struct {
+	UserID       string    `json:"userId" form:"userId"`
+	Route        string    `json:"route" form:"route"`
+	Method       string    `json:"method" form:"method"`
+	Status       int       `json:"status" form:"status"`
+	ResourceType string    `json:"resourceType" form:"resourceType"`
+	ResourceID   string    `json:"resourceId" form:"resourceId"`
+	StartTime    time.Time `json:"startTime" form:"startTime" time_format:"2006-01-02T15:04:05Z07:00"`
+	EndTime      time.Time `json:"endTime" form:"endTime" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// ListAuditLogsResponse 分页查询审计日志的结果，按时间倒序。
+type ListAuditLogsResponse struct {
+	List           []AuditLogDo `json:"list"`
+	Total          int          `json:"total"`
+	Cnt            int          `json:"cnt"`
+	CurrentPageNum int          `json:"currentPageNum"`
+	NextPageNum    int          `json:"nextPageNum"`
+	PageSize       int          `json:"pageSize"`
+	EndPage        bool         `json:"endPage"`
+}
@@ -14,6 +14,7 @@ type ListRooms struct {
 	NextPageNum    int               `json:"nextPageNum"`
 	PageSize       int               `json:"pageSize"`
 	EndPage        bool              `json:"endPage"`
+	NextCursor     string            `json:"nextCursor"`
 }
 
 type MicInfo struct {
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -8,9 +9,11 @@ import (
 	"fmt"
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
+	"sync"
 	"time"
 )
 
@@ -49,10 +52,14 @@ func (a *IMUserDo) GetPassword() string {
 type AccountDo struct {
 	// 用户ID，作为数据库唯一标识。
 	ID string `json:"id" bson:"_id"`
-	// 手机号，目前要求全局唯一。
+	// 手机号，目前要求全局唯一。使用邮箱注册的账号为空。
 	Phone string `json:"phone" bson:"phone"`
-	// TODO：支持账号密码登录。
-	Password string `json:"password" bson:"password"`
+	// Email 邮箱，设置后要求全局唯一。
+	Email string `json:"email,omitempty" bson:"email,omitempty"`
+	// EmailVerified 邮箱是否已通过验证。
+	EmailVerified bool `json:"emailVerified" bson:"emailVerified"`
+	// Password 密码的bcrypt哈希，未设置密码时为空。
+	Password string `json:"-" bson:"password"`
 	// 用户昵称
 	Nickname string `json:"nickname" bson:"nickname"`
 	// Avatar 头像URL地址
@@ -63,6 +70,27 @@ type AccountDo struct {
 	RegisterTime time.Time `json:"registerTime" bson:"registerTime"`
 	// LastLoginTime 上次登录时间。
 	LastLoginTime time.Time `json:"lastLoginTime" bson:"lastLoginTime"`
+	// Roles 账号被授予的角色，所有账号默认具备user角色。
+	Roles []Role `json:"roles,omitempty" bson:"roles,omitempty"`
+	// WeixinOpenID 关联的小程序用户openid，设置后要求全局唯一。
+	WeixinOpenID string `json:"-" bson:"weixinOpenId,omitempty"`
+	// WeixinUnionID 关联的微信开放平台unionid，小程序未绑定开放平台时为空。
+	WeixinUnionID string `json:"-" bson:"weixinUnionId,omitempty"`
+	// Locale 用户设置的语言，错误信息等优先使用该语言，为空时按请求的Accept-Language。
+	Locale string `json:"locale,omitempty" bson:"locale,omitempty"`
+}
+
+// HasPermission 判断账号的角色是否拥有某项权限。
+func (a AccountDo) HasPermission(permission Permission) bool {
+	if RoleHasPermission(RoleUser, permission) {
+		return true
+	}
+	for _, role := range a.Roles {
+		if RoleHasPermission(role, permission) {
+			return true
+		}
+	}
+	return false
 }
 
 func (a AccountDo) Map() FlattenMap {
@@ -72,24 +100,137 @@ func (a AccountDo) Map() FlattenMap {
 	return res
 }
 
-// AccountTokenDo 已登录用户的信息。
+// DeviceInfo 登录设备信息。
+type DeviceInfo struct {
+	// DeviceID 客户端通过请求头上报的设备ID，同一设备重复登录时复用会话。
+	DeviceID string `json:"deviceId" bson:"deviceId"`
+	// DeviceType 根据User-Agent识别的设备类型，取值同UAValue。
+	DeviceType string `json:"deviceType" bson:"deviceType"`
+	UserAgent  string `json:"userAgent" bson:"userAgent"`
+	IP         string `json:"ip" bson:"ip"`
+}
+
+// AccountTokenDo 已登录用户的会话信息，每个登录设备对应一条记录。
 type AccountTokenDo struct {
-	ID        string `json:"id" bson:"_id"`
-	AccountId string `json:"accountId" bson:"accountId"`
-	// Token 本次登录使用的token。
-	Token          string    `json:"token" bson:"token"`
-	LastModifyTime time.Time `json:"lastModifyTime"`
+	// ID 会话ID。
+	ID         string `json:"id" bson:"_id"`
+	AccountId  string `json:"accountId" bson:"accountId"`
+	DeviceInfo `bson:",inline"`
+	// CreateTime 会话创建时间，即该设备首次登录的时间。
+	CreateTime time.Time `json:"createTime" bson:"createTime"`
+	// Token 本次登录使用的访问token。
+	Token string `json:"token" bson:"token"`
+	// TokenID 访问token的唯一标识，即token中的jti。
+	TokenID string `json:"tokenId" bson:"tokenId"`
+	// ExpireAt 访问token的过期时间。
+	ExpireAt time.Time `json:"expireAt" bson:"expireAt"`
+	// RefreshToken 刷新token原文，只在签发时返回给客户端，不落库。
+	RefreshToken string `json:"refreshToken" bson:"-"`
+	// RefreshTokenHash 刷新token的sha256摘要。
+	RefreshTokenHash string `json:"-" bson:"refreshTokenHash"`
+	// RefreshExpireAt 刷新token的过期时间。
+	RefreshExpireAt time.Time `json:"refreshExpireAt" bson:"refreshExpireAt"`
+	LastModifyTime  time.Time `json:"lastModifyTime" bson:"lastModifyTime"`
+}
+
+const (
+	// DeletedAccountID 账号注销后，其他用户仍需保留的记录（面试、考试、房间等）中替代原账号ID的占位ID。
+	DeletedAccountID = "deleted-account"
+	// DeletedAccountName 账号注销后替代原账号昵称的占位名称。
+	DeletedAccountName = "已注销用户"
+
+	// TaskSubjectAccount 与 TaskActionAccountDelete 为注销账号任务在任务记录中的subject与action。
+	TaskSubjectAccount      = "account"
+	TaskActionAccountDelete = "delete"
+)
+
+// AccountDeletionReport 注销账号任务的结果，记录各集合删除与匿名化的记录数。
+type AccountDeletionReport struct {
+	AccountID  string         `json:"accountId"`
+	Removed    map[string]int `json:"removed"`
+	Anonymized map[string]int `json:"anonymized"`
+	FinishTime time.Time      `json:"finishTime"`
+}
+
+// AccountDataExport 导出的个人数据，Collections 为各集合中与账号相关的记录。
+type AccountDataExport struct {
+	ExportTime  time.Time           `json:"exportTime"`
+	Account     AccountDo           `json:"account"`
+	Collections map[string][]bson.M `json:"collections"`
 }
 
+// AccountEmailTokenPurpose 邮件token的用途。
+type AccountEmailTokenPurpose string
+
+const (
+	AccountEmailTokenVerifyEmail   AccountEmailTokenPurpose = "verify_email"
+	AccountEmailTokenResetPassword AccountEmailTokenPurpose = "reset_password"
+)
+
+// AccountEmailTokenDo 邮箱验证、重置密码邮件中的一次性token，ID为token的哈希，不保存token原文。
+type AccountEmailTokenDo struct {
+	ID         string                   `json:"id" bson:"_id"`
+	AccountID  string                   `json:"accountId" bson:"accountId"`
+	Email      string                   `json:"email" bson:"email"`
+	Purpose    AccountEmailTokenPurpose `json:"purpose" bson:"purpose"`
+	CreateTime time.Time                `json:"createTime" bson:"createTime"`
+	ExpireAt   time.Time                `json:"-" bson:"expireAt"`
+}
+
+// RevokedTokenDo 已吊销的访问token，保留到token自然过期为止。
+type RevokedTokenDo struct {
+	// ID 被吊销token的jti。
+	ID        string    `json:"id" bson:"_id"`
+	AccountId string    `json:"accountId" bson:"accountId"`
+	ExpireAt  time.Time `json:"expireAt" bson:"expireAt"`
+}
+
+// MigrationDo 已执行的数据迁移，ID为迁移的版本号。
+type MigrationDo struct {
+	Version   int       `json:"version" bson:"_id"`
+	Name      string    `json:"name" bson:"name"`
+	AppliedAt time.Time `json:"appliedAt" bson:"appliedAt"`
+}
+
+// SMSDeliveryStatus 短信验证码的发送状态。
+type SMSDeliveryStatus string
+
+const (
+	SMSDeliveryStatusPending SMSDeliveryStatus = "pending"
+	SMSDeliveryStatusSent    SMSDeliveryStatus = "sent"
+	SMSDeliveryStatusFailed  SMSDeliveryStatus = "failed"
+)
+
 // SMSCodeDo 已发送的验证码记录。
+// Provider 为最终发送（或最后一次尝试发送）该短信的短信服务，FailedProviders 为发送失败的短信服务。
 type SMSCodeDo struct {
+	ID              string            `json:"id" bson:"_id"`
+	Phone           string            `json:"phone" bson:"phone"`
+	SMSCode         string            `json:"smsCode" bson:"smsCode"`
+	SendTime        time.Time         `json:"sendTime" bson:"sendTime"`
+	ExpireAt        time.Time         `json:"-" bson:"expireAt"`
+	Status          SMSDeliveryStatus `json:"status" bson:"status"`
+	Provider        string            `json:"provider" bson:"provider"`
+	FailedProviders []string          `json:"failedProviders,omitempty" bson:"failedProviders,omitempty"`
+	LastError       string            `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	DeliverTime     time.Time         `json:"deliverTime,omitempty" bson:"deliverTime,omitempty"`
+}
+
+// SMSQuotaDo 短信验证码、邮件等每天的发送计数，ID为 <类型>:<IP、手机号或邮箱>:<日期>。
+type SMSQuotaDo struct {
 	ID       string    `json:"id" bson:"_id"`
-	Phone    string    `json:"phone" bson:"phone"`
-	SMSCode  string    `json:"smsCode" bson:"smsCode"`
-	SendTime time.Time `json:"sendTime" bson:"sendTime"`
+	Count    int       `json:"count" bson:"count"`
 	ExpireAt time.Time `json:"-" bson:"expireAt"`
 }
 
+// SMSValidateFailureDo 连续输错短信验证码或密码的记录，ID为手机号或 password:<邮箱>。
+type SMSValidateFailureDo struct {
+	ID          string    `json:"id" bson:"_id"`
+	Failures    int       `json:"failures" bson:"failures"`
+	LockedUntil time.Time `json:"lockedUntil" bson:"lockedUntil"`
+	ExpireAt    time.Time `json:"-" bson:"expireAt"`
+}
+
 type SolutionDo struct {
 	ID      string `json:"id" bson:"_id"`
 	Title   string `json:"title" bson:"title"`
@@ -173,6 +314,8 @@ type InterviewDo struct {
 	CandidateName   string    `json:"candidateName" bson:"candidateName"`
 	AppletQrcode    string    `json:"applet_qrcode" bson:"applet_qrcode"`
 	QiniuIMGroupId  int64     `json:"qiniuIMGroupId" bson:"qiniuIMGroupId"`
+	// TokenVersion 面试邀请token的版本，面试取消或修改时递增，使已签发的邀请token失效。
+	TokenVersion int `json:"-" bson:"tokenVersion"`
 }
 
 type InterviewUserDo struct {
@@ -303,7 +446,7 @@ const (
 )
 
 type Task interface {
-	Start(c *mgo.Collection, xl *xlog.Logger)
+	Start(c *mongodb.Collection, xl *xlog.Logger)
 	Handle(handle func() (result string, err error)) Task
 }
 
@@ -313,8 +456,80 @@ const (
 	TaskStatusRunning = TaskStatus("running")
 	TaskStatusSuccess = TaskStatus("success")
 	TaskStatusFailed  = TaskStatus("failed")
+	// TaskStatusInterrupted 停止服务时仍未执行完的任务，再次执行时不计入重试次数。
+	TaskStatusInterrupted = TaskStatus("interrupted")
 )
 
+// runningTasks 记录通过Start启动、尚未执行完的任务，停止服务时等待其结束。
+var runningTasks = struct {
+	sync.Mutex
+	wg       sync.WaitGroup
+	stopping bool
+	tasks    map[*TaskResultDo]*runningTask
+}{tasks: make(map[*TaskResultDo]*runningTask)}
+
+type runningTask struct {
+	coll *mongodb.Collection
+	// id 任务记录写入数据库后的ID，为空时任务尚未开始执行。
+	id string
+}
+
+// acquireTask 登记即将执行的任务，停止服务后返回false，不再启动新的任务。
+func acquireTask(m *TaskResultDo, c *mongodb.Collection) bool {
+	runningTasks.Lock()
+	defer runningTasks.Unlock()
+	if runningTasks.stopping {
+		return false
+	}
+	runningTasks.wg.Add(1)
+	runningTasks.tasks[m] = &runningTask{coll: c}
+	return true
+}
+
+// runTask 记录任务开始执行，停止服务超时时据此将任务标记为interrupted。
+func runTask(m *TaskResultDo, id string) {
+	runningTasks.Lock()
+	runningTasks.tasks[m].id = id
+	runningTasks.Unlock()
+}
+
+func releaseTask(m *TaskResultDo) {
+	runningTasks.Lock()
+	delete(runningTasks.tasks, m)
+	runningTasks.Unlock()
+	runningTasks.wg.Done()
+}
+
+// StopTasks 不再启动新的任务，并等待执行中的任务结束。
+// ctx结束时仍未执行完的任务标记为interrupted，服务重启后由定时任务重新执行，返回ctx的错误。
+func StopTasks(ctx context.Context, xl *xlog.Logger) error {
+	runningTasks.Lock()
+	runningTasks.stopping = true
+	runningTasks.Unlock()
+	done := make(chan struct{})
+	go func() {
+		runningTasks.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+	runningTasks.Lock()
+	defer runningTasks.Unlock()
+	for _, task := range runningTasks.tasks {
+		if task.id == "" {
+			continue
+		}
+		err := task.coll.UpdateId(task.id, bson.M{"$set": bson.M{"status": TaskStatusInterrupted, "update_at": time.Now()}})
+		if err != nil && xl != nil {
+			xl.Errorf("error mark task %v interrupted err:%v", task.id, err)
+		}
+	}
+	return ctx.Err()
+}
+
 // NewTask
 func NewTask(subjectId, subject, action string) *TaskResultDo {
 	task := &TaskResultDo{
@@ -325,7 +540,7 @@ func NewTask(subjectId, subject, action string) *TaskResultDo {
 	return task
 }
 
-func (m *TaskResultDo) beforeRun(c *mgo.Collection, xl *xlog.Logger) (err error) {
+func (m *TaskResultDo) beforeRun(c *mongodb.Collection, xl *xlog.Logger) (err error) {
 	var old TaskResultDo
 	condition := bson.M{"subject": m.Subject, "action": m.Action, "subject_id": m.SubjectID}
 	err = c.Find(condition).One(&old)
@@ -342,8 +557,11 @@ func (m *TaskResultDo) beforeRun(c *mgo.Collection, xl *xlog.Logger) (err error)
 		}
 		return nil
 	case nil:
-		// old task
+		// old task，被中断的任务不计入重试次数
 		m.RetryCount = old.RetryCount + 1
+		if old.Status == TaskStatusInterrupted {
+			m.RetryCount = old.RetryCount
+		}
 		if m.RetryCount > DefaultTaskRetryCountMax {
 			return fmt.Errorf("reach max retry count")
 		}
@@ -362,7 +580,7 @@ func (m *TaskResultDo) beforeRun(c *mgo.Collection, xl *xlog.Logger) (err error)
 }
 
 // success invoke when handle func return nil error
-func (m *TaskResultDo) success(c *mgo.Collection, result string, xl *xlog.Logger) {
+func (m *TaskResultDo) success(c *mongodb.Collection, result string, xl *xlog.Logger) {
 	m.Result = result
 	m.Status = TaskStatusSuccess
 	err := c.UpdateId(m.ID, *m)
@@ -372,7 +590,7 @@ func (m *TaskResultDo) success(c *mgo.Collection, result string, xl *xlog.Logger
 }
 
 // failure invoke when handle func return error
-func (m *TaskResultDo) failure(c *mgo.Collection, err error, xl *xlog.Logger) {
+func (m *TaskResultDo) failure(c *mongodb.Collection, err error, xl *xlog.Logger) {
 	m.Result = err.Error()
 	m.Status = TaskStatusFailed
 	err = c.UpdateId(m.ID, *m)
@@ -389,13 +607,19 @@ func (m *TaskResultDo) Handle(handle func() (result string, err error)) (res Tas
 
 // Start spawn a goroutine and start task
 // fail fast if reach DefaultTaskRetryCountMax
-func (m *TaskResultDo) Start(c *mgo.Collection, xl *xlog.Logger) {
+// skip if StopTasks has been called
+func (m *TaskResultDo) Start(c *mongodb.Collection, xl *xlog.Logger) {
+	if !acquireTask(m, c) {
+		return
+	}
 	go func() {
+		defer releaseTask(m)
 		err := m.beforeRun(c, xl)
 		if err != nil {
 			//m.failure(c, err,xl)
 			return
 		}
+		runTask(m, m.ID)
 		result, err := m.HandleFunc()
 		if err != nil {
 			m.failure(c, err, xl)
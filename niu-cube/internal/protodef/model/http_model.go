@@ -27,6 +27,8 @@ import (
 const (
 	// RequestIDHeader 七牛 request ID 头部。
 	RequestIDHeader = "X-Reqid"
+	// DeviceIDHeader 客户端上报设备ID的头部。
+	DeviceIDHeader = "X-Device-Id"
 	// XLogKey gin context中，用于获取记录请求相关日志的 xlog logger的key。
 	XLogKey = "xlog-logger"
 
@@ -35,8 +37,16 @@ const (
 
 	// UserIDContextKey 存放在请求context 中的用户ID。
 	UserIDContextKey = "userID"
-	// UserContextKey 存放用户对象
+	// UserContextKey 存放用户对象，通过登录token访问时首次调用ContextAccount才会加载。
 	UserContextKey = "user"
+	// UserPhoneContextKey 存放在请求context 中的用户手机号，取自登录token，用于记录操作日志。
+	UserPhoneContextKey = "userPhone"
+	// AccountLoaderContextKey 存放加载当前账号的AccountLoader。
+	AccountLoaderContextKey = "accountLoader"
+	// InterviewRoleContextKey 通过面试邀请token访问时，token绑定的面试角色。
+	InterviewRoleContextKey = "interviewRole"
+	// SessionIDContextKey 存放在请求context 中的当前登录会话ID。
+	SessionIDContextKey = "sessionID"
 
 	//ActionLogContentKey 用于存放log
 	ActionLogContentKey = "action-log"
@@ -46,6 +56,17 @@ const (
 	// TOKEN获取来源
 	TokenSourceFromInterviewToken TokenSource = "interviewToken"
 	TokenSourceFromHeader         TokenSource = "header"
+	TokenSourceFromApiKey         TokenSource = "apiKey"
+	// ApiKeyContextKey 通过API key访问时，存放在请求context 中的API key对象。
+	ApiKeyContextKey = "apiKey"
+	// ApiKeyHeader 携带API key的请求头部。
+	ApiKeyHeader = "X-Api-Key"
+	// RoomTypeContextKey 请求所操作房间的类型，用于监控指标按房间类型区分。
+	RoomTypeContextKey = "roomType"
+	// AuditResourcesContextKey 请求操作的资源，记录在审计日志中。
+	AuditResourcesContextKey = "auditResources"
+	// LanguageContextKey 当前请求使用的语言，由ContextLanguage确定。
+	LanguageContextKey = "language"
 
 	UAContextKey            = "UA"
 	UAMobile        UAValue = "mobile"
@@ -56,6 +77,8 @@ const (
 	// UserIDContextKey 存放在请求context 中的用户ID。
 	PageNumContextKey  = "pageNum"
 	PageSizeContextKey = "pageSize"
+	// PageQueryContextKey 存放在请求context 中的分页参数，见ContextPageQuery。
+	PageQueryContextKey = "pageQuery"
 
 	// RequestStartKey 存放在gin context中的请求开始的时间戳，单位为纳秒。
 	RequestStartKey = "request-start-timestamp-nano"
@@ -85,6 +108,36 @@ type TokenSource string
 type UAValue string
 
 // 状态码和状态信息
+// AccountLoader 按需从数据库加载当前请求的账号。
+type AccountLoader func() (*AccountDo, error)
+
+// ContextAccount 返回当前请求的账号。context中没有账号对象时通过AccountLoader加载并缓存，
+// 没有登录信息时返回false。
+func ContextAccount(c *gin.Context) (AccountDo, bool, error) {
+	if val, ok := c.Get(UserContextKey); ok {
+		return val.(AccountDo), true, nil
+	}
+	val, ok := c.Get(AccountLoaderContextKey)
+	if !ok {
+		return AccountDo{}, false, nil
+	}
+	account, err := val.(AccountLoader)()
+	if err != nil {
+		return AccountDo{}, true, err
+	}
+	c.Set(UserContextKey, *account)
+	return *account, true, nil
+}
+
+// ContextCallerID 返回当前调用方的ID，用于记录数据的创建者。登录用户为账号ID，
+// 通过API key访问时为ApiKeyAccountPrefix加key的ID，不对应任何账号。
+func ContextCallerID(c *gin.Context) string {
+	if val, ok := c.Get(ApiKeyContextKey); ok {
+		return ApiKeyAccountPrefix + val.(*ApiKeyDo).ID
+	}
+	return c.GetString(UserIDContextKey)
+}
+
 type ResponseStatusCode int
 type ResponseStatusMessage string
 
@@ -122,6 +175,23 @@ func (r *Response) WithErrorMessage(message string) *Response {
 	return r
 }
 
+// WithData 在失败的返回中附带详细信息，如参数校验失败的字段。
+func (r *Response) WithData(data interface{}) *Response {
+	r.Data = data
+	return r
+}
+
+// FieldError 请求参数中某个字段的校验错误，嵌套字段使用点号连接，如attrs.0.key。
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// FieldErrorsResponse 参数校验失败时返回的各字段错误。
+type FieldErrorsResponse struct {
+	Fields []FieldError `json:"fields"`
+}
+
 func (r *Response) Send(c *gin.Context) {
 	c.JSON(http.StatusOK, r)
 }
@@ -143,6 +213,7 @@ type Pagination struct {
 	NextPageNum    int           `json:"nextPageNum"`
 	PageSize       int           `json:"pageSize"`
 	EndPage        bool          `json:"endPage"`
+	NextCursor     string        `json:"nextCursor"`
 	List           []interface{} `json:"list"`
 }
 
@@ -165,6 +236,7 @@ type UserInfoResponse struct {
 	Avatar   string `json:"avatar"`
 	Phone    string `json:"phone"`
 	Profile  string `json:"profile"`
+	Locale   string `json:"locale,omitempty"`
 }
 
 // RepairRoomInfoResponse 房间信息表
@@ -179,6 +251,75 @@ type RepairRoomInfoResponse struct {
 // GetSmsCodeArgs 通过短信登录的参数
 type GetSmsCodeArgs struct {
 	Phone string `json:"phone" form:"phone"`
+	// CaptchaID 与 CaptchaAnswer 为人机验证的题目ID与答案，开启人机验证时必填。
+	CaptchaID     string `json:"captchaId" form:"captchaId"`
+	CaptchaAnswer string `json:"captchaAnswer" form:"captchaAnswer"`
+}
+
+// CaptchaResponse 人机验证的题目。
+type CaptchaResponse struct {
+	CaptchaID string `json:"captchaId"`
+	Question  string `json:"question"`
+	// ExpireAt 题目的过期时间，unix时间戳，单位为秒。
+	ExpireAt int64 `json:"expireAt"`
+}
+
+// AccountDeletionResponse 注销账号任务的状态
+type AccountDeletionResponse struct {
+	AccountID string                 `json:"accountId"`
+	Status    TaskStatus             `json:"status"`
+	Report    *AccountDeletionReport `json:"report,omitempty"`
+	// Error 任务失败时的错误信息。
+	Error string `json:"error,omitempty"`
+}
+
+// WeixinSignInArgs 小程序登录的参数
+// JsCode 为wx.login得到的code；EncryptedData、IV 为getPhoneNumber返回的加密手机号，可选，提供时绑定该手机号
+type WeixinSignInArgs struct {
+	JsCode        string `json:"jsCode" form:"jsCode"`
+	EncryptedData string `json:"encryptedData" form:"encryptedData"`
+	IV            string `json:"iv" form:"iv"`
+}
+
+// EmailSignUpArgs 通过邮箱与密码注册的参数
+type EmailSignUpArgs struct {
+	Email    string `json:"email" form:"email"`
+	Password string `json:"password" form:"password"`
+	Nickname string `json:"nickname" form:"nickname"`
+}
+
+// PasswordLoginArgs 通过邮箱与密码登录的参数
+type PasswordLoginArgs struct {
+	Email    string `json:"email" form:"email"`
+	Password string `json:"password" form:"password"`
+}
+
+// EmailArgs 重发验证邮件、找回密码的参数
+type EmailArgs struct {
+	Email string `json:"email" form:"email"`
+}
+
+// VerifyEmailArgs 验证邮箱的参数，token来自验证邮件中的链接
+type VerifyEmailArgs struct {
+	Token string `json:"token" form:"token"`
+}
+
+// ResetPasswordArgs 重置密码的参数，token来自重置密码邮件中的链接
+type ResetPasswordArgs struct {
+	Token    string `json:"token" form:"token"`
+	Password string `json:"password" form:"password"`
+}
+
+// UpdatePasswordArgs 修改密码的参数，账号未设置过密码时OldPassword可为空
+type UpdatePasswordArgs struct {
+	OldPassword string `json:"oldPassword" form:"oldPassword"`
+	NewPassword string `json:"newPassword" form:"newPassword"`
+}
+
+// DeleteAccountArgs 注销账号前重新验证身份的参数，已设置密码的账号填写Password，否则填写发送到绑定手机号的SMSCode
+type DeleteAccountArgs struct {
+	Password string `json:"password" form:"password"`
+	SMSCode  string `json:"smsCode" form:"smsCode"`
 }
 
 // SMSLoginArgs 通过短信登录的参数
@@ -198,17 +339,53 @@ type ImConfigResponse struct {
 	IMGroupId  int64  `json:"imGroupId"`
 }
 
+// LoginTokenResponse 登录token及用于续期的刷新token。
+type LoginTokenResponse struct {
+	Token        string `json:"loginToken"`
+	RefreshToken string `json:"refreshToken"`
+	// ExpireAt 登录token的过期时间，unix时间戳，单位为秒。
+	ExpireAt int64 `json:"expireAt"`
+}
+
 // SignUpOrInResponse 登录的返回结果。
 type SignUpOrInResponse struct {
 	UserInfoResponse
-	Token            string `json:"loginToken"`
+	LoginTokenResponse
 	ImConfigResponse `json:"imConfig"`
 }
 
+// SessionResponse 登录会话信息。
+type SessionResponse struct {
+	SessionID  string `json:"sessionId"`
+	DeviceID   string `json:"deviceId"`
+	DeviceType string `json:"deviceType"`
+	UserAgent  string `json:"userAgent"`
+	IP         string `json:"ip"`
+	// CreateTime 登录时间，unix时间戳，单位为秒。
+	CreateTime int64 `json:"createTime"`
+	// LastActiveTime 最近一次登录或刷新token的时间，unix时间戳，单位为秒。
+	LastActiveTime int64 `json:"lastActiveTime"`
+	// Current 是否为发起请求的会话。
+	Current bool `json:"current"`
+}
+
+// SessionListResponse 登录会话列表。
+type SessionListResponse struct {
+	List  []SessionResponse `json:"list"`
+	Total int               `json:"total"`
+}
+
+// RefreshTokenArgs 刷新登录token的参数。
+type RefreshTokenArgs struct {
+	RefreshToken string `json:"refreshToken" form:"refreshToken"`
+}
+
 // UpdateAccountInfoArgs 修改用户信息接口。
 type UpdateAccountInfoArgs struct {
 	Nickname string `json:"nickname" form:"nickname"`
 	Avatar   string `json:"avatar,omitempty" form:"avatar,omitempty"`
+	// Locale 错误信息等使用的语言，如en、zh-CN，为空时不修改。
+	Locale string `json:"locale,omitempty" form:"locale,omitempty"`
 }
 
 // UpdateAccountInfoResponse 修改用户信息的返回结果。
@@ -316,8 +493,16 @@ type InterviewResponse struct {
 	ShareInfo        ShareInfoResponse         `json:"shareInfo"`
 }
 
+// InterviewTokenArgs 面试邀请token携带的内容，只对一场面试中的一个角色有效。
 type InterviewTokenArgs struct {
-	UserID string `json:"userId"`
+	UserID      string `json:"userId"`
+	InterviewID string `json:"interviewId"`
+	// Role 取值为InterviewRoleCode。
+	Role int `json:"role"`
+	// Version 签发时面试的TokenVersion，面试取消或修改后旧token失效。
+	Version int `json:"ver"`
+	// ExpireAt 过期时间，unix时间戳，单位为秒。
+	ExpireAt int64 `json:"exp"`
 }
 
 type JoinInterviewResponse struct {
@@ -0,0 +1,137 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultPageSize 未指定pageSize时每页的条数。
+	DefaultPageSize = 10
+	// MaxPageSize 每页最多返回的条数，超过时按MaxPageSize返回。
+	MaxPageSize = 100
+)
+
+// ErrInvalidPageCursor 客户端传入的cursor无法解析。
+var ErrInvalidPageCursor = errors.New("invalid page cursor")
+
+// PageCursor 游标分页的位置，记录上一页最后一条记录的排序字段值与ID，编码后作为不透明的cursor返回给客户端。
+type PageCursor struct {
+	// Time 时间类型的排序字段值，如created_time、startTime。
+	Time time.Time `json:"t"`
+	// Status 面试列表先按状态排序，其他列表不使用。
+	Status int    `json:"s,omitempty"`
+	ID     string `json:"id"`
+}
+
+// Encode 返回游标的字符串形式。
+func (p *PageCursor) Encode() string {
+	b, _ := json.Marshal(p)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodePageCursor 解析Encode得到的游标。
+func DecodePageCursor(s string) (*PageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidPageCursor
+	}
+	cursor := &PageCursor{}
+	if err := json.Unmarshal(b, cursor); err != nil || cursor.ID == "" {
+		return nil, ErrInvalidPageCursor
+	}
+	return cursor, nil
+}
+
+// PageQuery 列表的分页参数，Cursor不为空时从游标之后读取PageSize条，否则按PageNum偏移分页。
+type PageQuery struct {
+	PageNum  int
+	PageSize int
+	Cursor   *PageCursor
+}
+
+// NewPageQuery 校验分页参数，pageNum、pageSize不合法时使用默认值，pageSize不超过MaxPageSize。
+func NewPageQuery(pageNum, pageSize int, cursor string) (PageQuery, error) {
+	if pageNum < 1 {
+		pageNum = 1
+	}
+	if pageSize < 1 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+	page := PageQuery{PageNum: pageNum, PageSize: pageSize}
+	if cursor != "" {
+		pageCursor, err := DecodePageCursor(cursor)
+		if err != nil {
+			return page, err
+		}
+		page.Cursor = pageCursor
+	}
+	return page, nil
+}
+
+// Skip 偏移分页时跳过的条数，游标分页时为0。
+func (p PageQuery) Skip() int {
+	if p.Cursor != nil {
+		return 0
+	}
+	return (p.PageNum - 1) * p.PageSize
+}
+
+// ContextPageQuery 返回FetchPageInfo解析出的分页参数，未经过FetchPageInfo时返回默认的第一页。
+func ContextPageQuery(c *gin.Context) PageQuery {
+	if page, ok := c.Get(PageQueryContextKey); ok {
+		return page.(PageQuery)
+	}
+	return PageQuery{PageNum: 1, PageSize: DefaultPageSize}
+}
+
+// PageSortField 游标分页的一个排序字段，Value为游标中该字段的值。
+type PageSortField struct {
+	Name  string
+	Desc  bool
+	Value interface{}
+}
+
+// PageCursorFilter 返回按fields依次排序时位于游标之后的记录的查询条件，最后一个字段应为_id以保证顺序唯一。
+func PageCursorFilter(fields ...PageSortField) map[string]interface{} {
+	conditions := make([]interface{}, 0, len(fields))
+	for i, field := range fields {
+		condition := make(map[string]interface{}, i+1)
+		for _, prev := range fields[:i] {
+			condition[prev.Name] = prev.Value
+		}
+		op := "$gt"
+		if field.Desc {
+			op = "$lt"
+		}
+		condition[field.Name] = map[string]interface{}{op: field.Value}
+		conditions = append(conditions, condition)
+	}
+	return map[string]interface{}{"$or": conditions}
+}
+
+// PageResult 分页查询的结果，Total只在偏移分页时统计，NextCursor在没有下一页时为空。
+type PageResult struct {
+	Total      int
+	Count      int
+	EndPage    bool
+	NextCursor string
+}
+
+// NewPageResult 由多查询的一条判断是否还有下一页，last返回第count条记录对应的游标。
+// fetched为查询到的条数（最多PageSize+1条），返回结果中Count不超过PageSize。
+func NewPageResult(page PageQuery, fetched int, last func(i int) *PageCursor) PageResult {
+	res := PageResult{Count: fetched, EndPage: fetched <= page.PageSize}
+	if !res.EndPage {
+		res.Count = page.PageSize
+		res.NextCursor = last(res.Count - 1).Encode()
+	}
+	return res
+}
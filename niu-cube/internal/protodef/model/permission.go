@@ -0,0 +1,112 @@
+package model
+
+/*
+	permission.go: 角色与权限的定义。账号上只持久化角色，角色拥有的权限在此统一声明。
+*/
+
+type Role string
+type Permission string
+
+const (
+	RoleAdmin         Role = "admin"
+	RoleTeacher       Role = "teacher"
+	RoleExaminer      Role = "examiner"
+	RoleContentEditor Role = "content_editor"
+	// RoleUser 所有账号默认具备的角色，无需持久化。
+	RoleUser Role = "user"
+
+	// PermissionRoleManage 授予、撤销账号角色。
+	PermissionRoleManage Permission = "role:manage"
+	// PermissionAccountManage 删除、同步其他账号。
+	PermissionAccountManage Permission = "account:manage"
+	// PermissionSystemMaintain 清理数据等运维操作。
+	PermissionSystemMaintain Permission = "system:maintain"
+	// PermissionVersionManage 发布、删除APP版本。
+	PermissionVersionManage Permission = "version:manage"
+	// PermissionSongManage 维护KTV曲库。
+	PermissionSongManage Permission = "song:manage"
+	// PermissionMovieManage 维护电影片库。
+	PermissionMovieManage Permission = "movie:manage"
+	// PermissionExamManage 创建、修改、删除考试。
+	PermissionExamManage Permission = "exam:manage"
+	// PermissionQuestionManage 维护考试题库。
+	PermissionQuestionManage Permission = "question:manage"
+	// PermissionExamReview 查看考生名单、作弊事件等监考信息。
+	PermissionExamReview Permission = "exam:review"
+	// PermissionApiKeyManage 创建、轮换、吊销API key，不能授予API key。
+	PermissionApiKeyManage Permission = "apikey:manage"
+	// PermissionAuditLogRead 查询审计日志。
+	PermissionAuditLogRead Permission = "auditlog:read"
+)
+
+// AllPermissions 所有已定义的权限。
+var AllPermissions = []Permission{
+	PermissionRoleManage, PermissionAccountManage, PermissionSystemMaintain, PermissionVersionManage,
+	PermissionSongManage, PermissionMovieManage, PermissionExamManage, PermissionQuestionManage,
+	PermissionExamReview, PermissionApiKeyManage, PermissionAuditLogRead,
+}
+
+// AllRoles 所有可授予的角色。
+var AllRoles = []Role{RoleAdmin, RoleTeacher, RoleExaminer, RoleContentEditor, RoleUser}
+
+// RolePermissions 各角色拥有的权限。admin拥有全部权限，不在此列出。
+var RolePermissions = map[Role][]Permission{
+	RoleTeacher:       {PermissionExamManage, PermissionQuestionManage, PermissionExamReview},
+	RoleExaminer:      {PermissionExamReview},
+	RoleContentEditor: {PermissionSongManage, PermissionMovieManage},
+	RoleUser:          {},
+}
+
+// IsValidRole 判断角色是否已定义。
+func IsValidRole(role Role) bool {
+	for _, r := range AllRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidPermission 判断权限是否已定义。
+func IsValidPermission(permission Permission) bool {
+	for _, p := range AllPermissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleHasPermission 判断角色是否拥有某项权限。
+func RoleHasPermission(role Role, permission Permission) bool {
+	if role == RoleAdmin {
+		return true
+	}
+	for _, p := range RolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleListResponse 角色及其权限列表。
+type RoleListResponse struct {
+	List []RoleResponse `json:"list"`
+}
+
+type RoleResponse struct {
+	Role        Role         `json:"role"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// AccountRolesResponse 账号拥有的角色。
+type AccountRolesResponse struct {
+	AccountID string `json:"accountId"`
+	Roles     []Role `json:"roles"`
+}
+
+// GrantRoleArgs 授予角色的参数。
+type GrantRoleArgs struct {
+	Role Role `json:"role" form:"role"`
+}
@@ -1,5 +1,12 @@
 package model
 
+import (
+	"net/http"
+
+	"github.com/solutions/niu-cube/internal/common/i18n"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+)
+
 type ResponseError struct {
 	// 自定义错误码。
 	Code int `json:"code"`
@@ -11,6 +18,11 @@ type ResponseError struct {
 
 const (
 	ResponseErrorBadRequest         = 400000
+	ResponseErrorInvalidPhone       = 400001
+	ResponseErrorInvalidEmail       = 400002
+	ResponseErrorInvalidPhoneData   = 400003
+	ResponseErrorReauthRequired     = 400004
+	ResponseErrorPasswordNotSet     = 400005
 	ResponseErrorNotLoggedIn        = 401001
 	ResponseErrorWrongSMSCode       = 401002
 	ResponseErrorBadToken           = 401003
@@ -20,8 +32,14 @@ const (
 	ResponseErrorNoSuchBoard        = 404003
 	ResponseErrorNoSuchRoom         = 404004 // TODO: add to doc
 	ResponseErrorSMSSendTooFrequent = 429001
+	ResponseErrorSMSQuotaExceeded   = 429002
+	ResponseErrorSMSValidateLocked  = 429003
+	ResponseErrorLoginLocked        = 429004
+	ResponseErrorMailQuotaExceeded  = 429005
+	ResponseErrorRateLimited        = 429006
 	ResponseErrorInternal           = 500000
 	ResponseErrorExternalService    = 502001
+	ResponseErrorTimeout            = 504001
 	ResponseErrorUnauthorized       = 401000
 	ResponseErrorNotFound           = 404000
 	ResponseErrorValidation         = 401005
@@ -32,101 +50,214 @@ const (
 	ResponseErrorTooManyPeople      = 401010
 	ResponseErrorExamTimeNotMatch   = 401011
 	ResponseErrorExamDuplicateEntry = 401012
+	ResponseErrorTokenExpired       = 401013
+	ResponseErrorWrongCaptcha       = 401014
+	ResponseErrorWrongPassword      = 401015
+	ResponseErrorEmailNotVerified   = 401016
+	ResponseErrorWeixinCodeInvalid  = 401017
+	ResponseErrorPermissionDenied   = 403001
+	ResponseErrorEmailUsed          = 409001
+	ResponseErrorPhoneUsed          = 409002
 )
 
-// NewHTTPErrorBadRequest 参数错误。
-func NewResponseErrorBadRequest() *ResponseError {
+// responseHTTPStatus 错误码的前三位与HTTP状态码不一致的错误码对应的HTTP状态码。
+var responseHTTPStatus = map[int]int{
+	ResponseErrorValidation:         http.StatusBadRequest,
+	ResponseErrorJoinRoom:           http.StatusBadRequest,
+	ResponseErrorValidationRoomId:   http.StatusBadRequest,
+	ResponseErrorGetRoomContent:     http.StatusInternalServerError,
+	ResponseErrorOnlyOneStaff:       http.StatusConflict,
+	ResponseErrorTooManyPeople:      http.StatusConflict,
+	ResponseErrorExamTimeNotMatch:   http.StatusConflict,
+	ResponseErrorExamDuplicateEntry: http.StatusConflict,
+	ResponseErrorWrongCaptcha:       http.StatusBadRequest,
+	ResponseErrorEmailNotVerified:   http.StatusForbidden,
+	ResponseErrorWeixinCodeInvalid:  http.StatusBadRequest,
+}
+
+// ResponseHTTPStatus 返回V2接口中错误码对应的HTTP状态码。错误码的前三位一般为HTTP状态码，
+// 成功为200，无法对应的错误码为500。
+func ResponseHTTPStatus(code int) int {
+	if code == int(ResponseStatusCodeSuccess) {
+		return http.StatusOK
+	}
+	if status, ok := responseHTTPStatus[code]; ok {
+		return status
+	}
+	if status := code / 1000; status >= 400 && status < 600 && http.StatusText(status) != "" {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// newResponseError 返回错误码及其在默认语言下的错误信息，响应时由middleware.Localize翻译为请求使用的语言。
+func newResponseError(code int) *ResponseError {
 	return &ResponseError{
-		Code:    ResponseErrorBadRequest,
-		Message: "参数错误",
+		Code:    code,
+		Message: ResponseMessage(i18n.Default(), code),
 	}
 }
 
+// NewHTTPErrorBadRequest 参数错误。
+func NewResponseErrorBadRequest() *ResponseError {
+	return newResponseError(ResponseErrorBadRequest)
+}
+
+// NewResponseErrorInvalidPhone 手机号格式不合法。
+func NewResponseErrorInvalidPhone() *ResponseError {
+	return newResponseError(ResponseErrorInvalidPhone)
+}
+
+// NewResponseErrorInvalidEmail 邮箱格式不合法。
+func NewResponseErrorInvalidEmail() *ResponseError {
+	return newResponseError(ResponseErrorInvalidEmail)
+}
+
+// NewResponseErrorInvalidPhoneData 小程序提交的加密手机号数据无法解密。
+func NewResponseErrorInvalidPhoneData() *ResponseError {
+	return newResponseError(ResponseErrorInvalidPhoneData)
+}
+
+// NewResponseErrorReauthRequired 注销账号等操作需要提供密码或短信验证码重新验证身份。
+func NewResponseErrorReauthRequired() *ResponseError {
+	return newResponseError(ResponseErrorReauthRequired)
+}
+
+// NewResponseErrorPasswordNotSet 账号既未设置密码也未绑定手机号，无法重新验证身份。
+func NewResponseErrorPasswordNotSet() *ResponseError {
+	return newResponseError(ResponseErrorPasswordNotSet)
+}
+
 // NewResponseErrorNotLoggedIn 用户未登录。
 func NewResponseErrorNotLoggedIn() *ResponseError {
-	return &ResponseError{
-		Code:    ResponseErrorNotLoggedIn,
-		Message: "not logged in",
-	}
+	return newResponseError(ResponseErrorNotLoggedIn)
 }
 
 // NewResponseErrorWrongSMSCode 用户短信验证码错误。
 func NewResponseErrorWrongSMSCode() *ResponseError {
-	return &ResponseError{
-		Code:    ResponseErrorWrongSMSCode,
-		Message: "wrong sms code",
-	}
+	return newResponseError(ResponseErrorWrongSMSCode)
 }
 
 // NewResponseErrorBadToken 登录token错误。
 func NewResponseErrorBadToken() *ResponseError {
-	return &ResponseError{
-		Code:    ResponseErrorBadToken,
-		Message: "bad token",
-	}
+	return newResponseError(ResponseErrorBadToken)
+}
+
+// NewResponseErrorTokenExpired 登录token已过期，需使用刷新token换取新token。
+func NewResponseErrorTokenExpired() *ResponseError {
+	return newResponseError(ResponseErrorTokenExpired)
 }
 
 // NewResponseErrorSMSSendTooFrequent 短信验证码已发送，短时间内不能重复发送。
 func NewResponseErrorSMSSendTooFrequent() *ResponseError {
-	return &ResponseError{
-		Code:    ResponseErrorSMSSendTooFrequent,
-		Message: "send sms code request limited",
-	}
+	return newResponseError(ResponseErrorSMSSendTooFrequent)
+}
+
+// NewResponseErrorSMSQuotaExceeded 超出IP、手机号或全局每天的短信验证码发送数量。
+func NewResponseErrorSMSQuotaExceeded() *ResponseError {
+	return newResponseError(ResponseErrorSMSQuotaExceeded)
+}
+
+// NewResponseErrorSMSValidateLocked 短信验证码输错次数过多，手机号暂时锁定。
+func NewResponseErrorSMSValidateLocked() *ResponseError {
+	return newResponseError(ResponseErrorSMSValidateLocked)
+}
+
+// NewResponseErrorLoginLocked 密码输错次数过多，邮箱暂时锁定。
+func NewResponseErrorLoginLocked() *ResponseError {
+	return newResponseError(ResponseErrorLoginLocked)
+}
+
+// NewResponseErrorMailQuotaExceeded 超出邮箱或IP每天的邮件发送数量。
+func NewResponseErrorMailQuotaExceeded() *ResponseError {
+	return newResponseError(ResponseErrorMailQuotaExceeded)
+}
+
+// NewResponseErrorRateLimited 请求过于频繁，需按Retry-After头部等待后重试。
+func NewResponseErrorRateLimited() *ResponseError {
+	return newResponseError(ResponseErrorRateLimited)
+}
+
+// NewResponseErrorWrongCaptcha 人机验证未通过。
+func NewResponseErrorWrongCaptcha() *ResponseError {
+	return newResponseError(ResponseErrorWrongCaptcha)
+}
+
+// NewResponseErrorWrongPassword 邮箱或密码错误。
+func NewResponseErrorWrongPassword() *ResponseError {
+	return newResponseError(ResponseErrorWrongPassword)
+}
+
+// NewResponseErrorEmailNotVerified 邮箱尚未验证，不能使用密码登录。
+func NewResponseErrorEmailNotVerified() *ResponseError {
+	return newResponseError(ResponseErrorEmailNotVerified)
+}
+
+// NewResponseErrorEmailUsed 邮箱已被其他账号使用。
+func NewResponseErrorEmailUsed() *ResponseError {
+	return newResponseError(ResponseErrorEmailUsed)
+}
+
+// NewResponseErrorWeixinCodeInvalid 小程序登录的js_code无效或已被使用。
+func NewResponseErrorWeixinCodeInvalid() *ResponseError {
+	return newResponseError(ResponseErrorWeixinCodeInvalid)
+}
+
+// NewResponseErrorPhoneUsed 手机号已被其他账号使用，或已绑定其他微信用户。
+func NewResponseErrorPhoneUsed() *ResponseError {
+	return newResponseError(ResponseErrorPhoneUsed)
 }
 
 // NewResponseErrorInternal 其他内部服务错误。
 func NewResponseErrorInternal() *ResponseError {
-	return &ResponseError{
-		Code:    ResponseErrorInternal,
-		Message: "internal server error",
-	}
+	return newResponseError(ResponseErrorInternal)
 }
 
 // NewResponseErrorAlreadyLoggedin 用户已经登录，此为重复登录
 func NewResponseErrorAlreadyLoggedin() *ResponseError {
-	return &ResponseError{
-		Code:    ResponseErrorAlreadyLoggedIn,
-		Message: "already logged in",
-	}
+	return newResponseError(ResponseErrorAlreadyLoggedIn)
 }
 
 // NewResponseErrorExternalService 调用外部服务错误。
 func NewResponseErrorExternalService() *ResponseError {
-	return &ResponseError{
-		Code:    ResponseErrorExternalService,
-		Message: "calling external service failed",
+	return newResponseError(ResponseErrorExternalService)
+}
+
+// NewResponseErrorTimeout 数据库操作超过请求的截止时间或单次操作的超时时间。
+func NewResponseErrorTimeout() *ResponseError {
+	return newResponseError(ResponseErrorTimeout)
+}
+
+// NewResponseErrorDatabase 数据库操作失败，超时返回ResponseErrorTimeout，其他错误返回ResponseErrorInternal。
+func NewResponseErrorDatabase(err error) *ResponseError {
+	if mongodb.IsTimeout(err) {
+		return NewResponseErrorTimeout()
 	}
+	return NewResponseErrorInternal()
 }
 
 // NewResponseErrorNoSuchUser 无此用户。
 func NewResponseErrorNoSuchUser() *ResponseError {
-	return &ResponseError{
-		Code:    ResponseErrorNoSuchUser,
-		Message: "no such user",
-	}
+	return newResponseError(ResponseErrorNoSuchUser)
 }
 
 // NewResponseErrorUnauthorized 一般的HTTP Unauthorized 错误。
 func NewResponseErrorUnauthorized() *ResponseError {
-	return &ResponseError{
-		Code:    ResponseErrorUnauthorized,
-		Message: "unauthorized",
-	}
+	return newResponseError(ResponseErrorUnauthorized)
+}
+
+// NewResponseErrorPermissionDenied 已登录，但账号角色不具备所需权限。
+func NewResponseErrorPermissionDenied() *ResponseError {
+	return newResponseError(ResponseErrorPermissionDenied)
 }
 
 func NewResponseErrorNotFound() *ResponseError {
-	return &ResponseError{
-		Code:    ResponseErrorNotFound,
-		Message: "not found",
-	}
+	return newResponseError(ResponseErrorNotFound)
 }
 
 // NewResponseErrorNoSuchInterview 无此房间。
 func NewResponseErrorNoSuchInterview() *ResponseError {
-	return &ResponseError{
-		Code:    ResponseErrorNoSuchInterview,
-		Message: "no such interview",
-	}
+	return newResponseError(ResponseErrorNoSuchInterview)
 }
 
 func NewResponseErrorValidation(err error) *ResponseError {
@@ -137,24 +268,15 @@ func NewResponseErrorValidation(err error) *ResponseError {
 }
 
 func NewResponseErrorNoSuchBoard() *ResponseError {
-	return &ResponseError{
-		Code:    ResponseErrorNoSuchBoard,
-		Message: "no such board",
-	}
+	return newResponseError(ResponseErrorNoSuchBoard)
 }
 
 func NewResponseErrorNoSuchRoom() *ResponseError {
-	return &ResponseError{
-		Code:    ResponseErrorNoSuchRoom,
-		Message: "not such room",
-	}
+	return newResponseError(ResponseErrorNoSuchRoom)
 }
 
 func NewResponseErrorJoinRoom() *ResponseError {
-	return &ResponseError{
-		Code:    ResponseErrorJoinRoom,
-		Message: "join room fail",
-	}
+	return newResponseError(ResponseErrorJoinRoom)
 }
 
 func NewResponseError(code int, message string) *ResponseError {
@@ -0,0 +1,136 @@
+package model
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/solutions/niu-cube/internal/common/i18n"
+)
+
+// responseMessages 按语言与错误码索引的错误信息。
+var responseMessages = map[string]map[int]string{
+	i18n.English: {
+		ResponseErrorBadRequest:         "bad request",
+		ResponseErrorInvalidPhone:       "invalid phone number",
+		ResponseErrorInvalidEmail:       "invalid email",
+		ResponseErrorInvalidPhoneData:   "invalid phone number data",
+		ResponseErrorReauthRequired:     "password or sms code required",
+		ResponseErrorPasswordNotSet:     "please set a password first",
+		ResponseErrorNotLoggedIn:        "not logged in",
+		ResponseErrorWrongSMSCode:       "wrong sms code",
+		ResponseErrorBadToken:           "bad token",
+		ResponseErrorAlreadyLoggedIn:    "already logged in",
+		ResponseErrorNoSuchUser:         "no such user",
+		ResponseErrorNoSuchInterview:    "no such interview",
+		ResponseErrorNoSuchBoard:        "no such board",
+		ResponseErrorNoSuchRoom:         "no such room",
+		ResponseErrorSMSSendTooFrequent: "send sms code request limited",
+		ResponseErrorSMSQuotaExceeded:   "sms quota exceeded",
+		ResponseErrorSMSValidateLocked:  "too many wrong sms codes, try again later",
+		ResponseErrorLoginLocked:        "too many wrong passwords, try again later",
+		ResponseErrorMailQuotaExceeded:  "mail quota exceeded",
+		ResponseErrorRateLimited:        "too many requests, try again later",
+		ResponseErrorInternal:           "internal server error",
+		ResponseErrorExternalService:    "calling external service failed",
+		ResponseErrorTimeout:            "database operation timed out",
+		ResponseErrorUnauthorized:       "unauthorized",
+		ResponseErrorNotFound:           "not found",
+		ResponseErrorValidation:         "invalid arguments",
+		ResponseErrorJoinRoom:           "join room fail",
+		ResponseErrorValidationRoomId:   "invalid room id",
+		ResponseErrorGetRoomContent:     "GetRoomContent fail",
+		ResponseErrorOnlyOneStaff:       "only one staff is allowed in the room",
+		ResponseErrorTooManyPeople:      "too many people.",
+		ResponseErrorExamTimeNotMatch:   "the exam is not in progress",
+		ResponseErrorExamDuplicateEntry: "you have finished the exam and can not join again",
+		ResponseErrorTokenExpired:       "token expired",
+		ResponseErrorWrongCaptcha:       "wrong captcha",
+		ResponseErrorWrongPassword:      "wrong email or password",
+		ResponseErrorEmailNotVerified:   "email not verified",
+		ResponseErrorWeixinCodeInvalid:  "invalid weixin login code",
+		ResponseErrorPermissionDenied:   "permission denied",
+		ResponseErrorEmailUsed:          "email already registered",
+		ResponseErrorPhoneUsed:          "phone already bound to another account",
+	},
+	i18n.Chinese: {
+		ResponseErrorBadRequest:         "参数错误",
+		ResponseErrorInvalidPhone:       "手机号不合法",
+		ResponseErrorInvalidEmail:       "邮箱不合法",
+		ResponseErrorInvalidPhoneData:   "手机号数据无效",
+		ResponseErrorReauthRequired:     "需要密码或短信验证码",
+		ResponseErrorPasswordNotSet:     "请先设置密码",
+		ResponseErrorNotLoggedIn:        "未登录",
+		ResponseErrorWrongSMSCode:       "短信验证码错误",
+		ResponseErrorBadToken:           "登录token无效",
+		ResponseErrorAlreadyLoggedIn:    "已经登录",
+		ResponseErrorNoSuchUser:         "用户不存在",
+		ResponseErrorNoSuchInterview:    "面试不存在",
+		ResponseErrorNoSuchBoard:        "白板不存在",
+		ResponseErrorNoSuchRoom:         "房间不存在",
+		ResponseErrorSMSSendTooFrequent: "短信验证码发送过于频繁",
+		ResponseErrorSMSQuotaExceeded:   "超出短信验证码的发送数量",
+		ResponseErrorSMSValidateLocked:  "短信验证码错误次数过多，请稍后再试",
+		ResponseErrorLoginLocked:        "密码错误次数过多，请稍后再试",
+		ResponseErrorMailQuotaExceeded:  "超出邮件的发送数量",
+		ResponseErrorRateLimited:        "请求过于频繁，请稍后再试",
+		ResponseErrorInternal:           "服务内部错误",
+		ResponseErrorExternalService:    "调用外部服务失败",
+		ResponseErrorTimeout:            "数据库操作超时",
+		ResponseErrorUnauthorized:       "未授权",
+		ResponseErrorNotFound:           "资源不存在",
+		ResponseErrorValidation:         "参数异常",
+		ResponseErrorJoinRoom:           "加入房间失败",
+		ResponseErrorValidationRoomId:   "房间号不正确",
+		ResponseErrorGetRoomContent:     "获取房间信息失败",
+		ResponseErrorOnlyOneStaff:       "房间中只能有一名员工",
+		ResponseErrorTooManyPeople:      "房间人数已满",
+		ResponseErrorExamTimeNotMatch:   "考试时间不匹配",
+		ResponseErrorExamDuplicateEntry: "您已结束考试，无法再次参加",
+		ResponseErrorTokenExpired:       "登录token已过期",
+		ResponseErrorWrongCaptcha:       "人机验证未通过",
+		ResponseErrorWrongPassword:      "邮箱或密码错误",
+		ResponseErrorEmailNotVerified:   "邮箱尚未验证",
+		ResponseErrorWeixinCodeInvalid:  "微信登录code无效",
+		ResponseErrorPermissionDenied:   "权限不足",
+		ResponseErrorEmailUsed:          "邮箱已被注册",
+		ResponseErrorPhoneUsed:          "手机号已绑定其他账号",
+	},
+}
+
+// ResponseMessage 返回错误码在给定语言下的错误信息，没有对应翻译时使用英文。
+func ResponseMessage(lang string, code int) string {
+	if message, ok := responseMessages[lang][code]; ok {
+		return message
+	}
+	return responseMessages[i18n.English][code]
+}
+
+// LocalizeResponseMessage 返回错误信息在给定语言下的翻译。只翻译错误码在某种语言下的标准错误信息，
+// 处理函数给出的具体错误信息，如参数校验的详情，原样返回。
+func LocalizeResponseMessage(lang string, code int, message string) string {
+	for _, messages := range responseMessages {
+		if messages[code] == message && message != "" {
+			return ResponseMessage(lang, code)
+		}
+	}
+	return message
+}
+
+// ContextLanguage 返回当前请求使用的语言：登录账号设置了语言时使用该语言，其次为Accept-Language中支持的语言，
+// 都没有时为i18n.Default()。结果缓存在LanguageContextKey中。
+func ContextLanguage(c *gin.Context) string {
+	if lang := c.GetString(LanguageContextKey); lang != "" {
+		return lang
+	}
+	lang := ""
+	if account, ok, err := ContextAccount(c); ok && err == nil {
+		lang = i18n.Normalize(account.Locale)
+	}
+	if lang == "" {
+		lang = i18n.Match(c.GetHeader("Accept-Language"))
+	}
+	if lang == "" {
+		lang = i18n.Default()
+	}
+	c.Set(LanguageContextKey, lang)
+	return lang
+}
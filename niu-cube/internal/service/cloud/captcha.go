@@ -0,0 +1,108 @@
+package cloud
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/utils"
+	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
+	model "github.com/solutions/niu-cube/internal/protodef/model"
+)
+
+const (
+	// CaptchaProviderLocal 本地算术题人机验证。
+	CaptchaProviderLocal = "local"
+	// CaptchaDefaultExpireTimeout 人机验证题目默认的有效时间。
+	CaptchaDefaultExpireTimeout = 5 * time.Minute
+)
+
+// CaptchaVerifier 发送短信验证码前的人机验证。可替换为第三方验证码服务，只需出题并校验答案。
+type CaptchaVerifier interface {
+	// NewChallenge 生成一道人机验证题目。
+	NewChallenge(xl *xlog.Logger) (*model.CaptchaResponse, error)
+	// Verify 校验题目的答案，每道题目只能校验一次。
+	Verify(xl *xlog.Logger, captchaID string, answer string) error
+}
+
+// NewCaptchaVerifier 根据配置创建人机验证，未配置provider时返回nil，表示不需要人机验证。
+func NewCaptchaVerifier(conf *utils.CaptchaConfig) (CaptchaVerifier, error) {
+	if conf == nil || conf.Provider == "" {
+		return nil, nil
+	}
+	switch conf.Provider {
+	case CaptchaProviderLocal:
+		return NewLocalCaptchaVerifier(conf), nil
+	default:
+		return nil, fmt.Errorf("unsupported captcha provider %s", conf.Provider)
+	}
+}
+
+// LocalCaptchaVerifier 本地的算术题人机验证，题目保存在进程内存中，仅适用于开发、测试与单实例部署。
+type LocalCaptchaVerifier struct {
+	mutex      sync.Mutex
+	challenges map[string]localCaptchaChallenge
+	expire     time.Duration
+	randSource *rand.Rand
+}
+
+type localCaptchaChallenge struct {
+	answer   string
+	expireAt time.Time
+}
+
+// NewLocalCaptchaVerifier 创建本地算术题人机验证。
+func NewLocalCaptchaVerifier(conf *utils.CaptchaConfig) *LocalCaptchaVerifier {
+	expire := CaptchaDefaultExpireTimeout
+	if conf != nil && conf.ExpireSecond > 0 {
+		expire = time.Duration(conf.ExpireSecond) * time.Second
+	}
+	return &LocalCaptchaVerifier{
+		challenges: make(map[string]localCaptchaChallenge),
+		expire:     expire,
+		randSource: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (v *LocalCaptchaVerifier) NewChallenge(xl *xlog.Logger) (*model.CaptchaResponse, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	now := time.Now()
+	// 顺带清理已过期的题目。
+	for id, challenge := range v.challenges {
+		if challenge.expireAt.Before(now) {
+			delete(v.challenges, id)
+		}
+	}
+	a, b := v.randSource.Intn(50)+1, v.randSource.Intn(50)+1
+	captchaID := utils.GenerateSecureToken(16)
+	expireAt := now.Add(v.expire)
+	v.challenges[captchaID] = localCaptchaChallenge{
+		answer:   fmt.Sprintf("%d", a+b),
+		expireAt: expireAt,
+	}
+	return &model.CaptchaResponse{
+		CaptchaID: captchaID,
+		Question:  fmt.Sprintf("%d + %d = ?", a, b),
+		ExpireAt:  expireAt.Unix(),
+	}, nil
+}
+
+func (v *LocalCaptchaVerifier) Verify(xl *xlog.Logger, captchaID string, answer string) error {
+	v.mutex.Lock()
+	challenge, ok := v.challenges[captchaID]
+	delete(v.challenges, captchaID)
+	v.mutex.Unlock()
+	if !ok || challenge.expireAt.Before(time.Now()) {
+		xl.Infof("captcha %s not found or expired", captchaID)
+		return &errors2.ServerError{Code: errors2.ServerErrorCaptchaInvalid, Summary: "captcha not found or expired"}
+	}
+	if strings.TrimSpace(answer) != challenge.answer {
+		xl.Infof("wrong answer for captcha %s", captchaID)
+		return &errors2.ServerError{Code: errors2.ServerErrorCaptchaInvalid, Summary: "wrong captcha answer"}
+	}
+	return nil
+}
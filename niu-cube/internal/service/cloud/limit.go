@@ -0,0 +1,170 @@
+package cloud
+
+import (
+	"time"
+
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
+	model "github.com/solutions/niu-cube/internal/protodef/model"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Limiter 按天累计的额度与连续失败后的锁定，短信验证码、密码登录与邮件发送共用。
+type Limiter struct {
+	quotaColl      *mongodb.Collection
+	failureColl    *mongodb.Collection
+	maxFailures    int
+	lockoutTimeout time.Duration
+	// quotaErrCode 超出额度时返回的错误码。
+	quotaErrCode int
+	// lockedErrCode 锁定期间返回的错误码。
+	lockedErrCode int
+}
+
+// Quota 一项按天累计的额度，Kind区分计数的类型，Limit小于等于0表示不限制。
+type Quota struct {
+	Kind  string
+	Key   string
+	Limit int
+}
+
+// id 计数记录的ID，按类型、键与日期区分。
+func (q Quota) id(now time.Time) string {
+	return q.Kind + ":" + q.Key + ":" + now.Format("20060102")
+}
+
+// NewLimiter 创建使用quotaColl计数、failureColl记录失败的限制器，过期记录由dao.Indexes中声明的TTL索引清理。
+// maxFailures小于等于0时不锁定。
+func NewLimiter(quotaColl *mongodb.Collection, failureColl *mongodb.Collection, maxFailures int, lockoutTimeout time.Duration, quotaErrCode int, lockedErrCode int) (*Limiter, error) {
+	return &Limiter{
+		quotaColl:      quotaColl,
+		failureColl:    failureColl,
+		maxFailures:    maxFailures,
+		lockoutTimeout: lockoutTimeout,
+		quotaErrCode:   quotaErrCode,
+		lockedErrCode:  lockedErrCode,
+	}, nil
+}
+
+// Reserve 依次累加各项当天的计数。任一超出限制时回退已累加的计数并返回错误，
+// 否则返回回退本次计数的函数，供后续操作失败时调用，失败的操作不占用额度。
+func (l *Limiter) Reserve(xl *xlog.Logger, quotas ...Quota) (func(), error) {
+	now := time.Now()
+	reserved := make([]Quota, 0, len(quotas))
+	release := func() {
+		for _, quota := range reserved {
+			l.decr(xl, quota, now)
+		}
+	}
+	for _, quota := range quotas {
+		if quota.Limit <= 0 {
+			continue
+		}
+		count, err := l.incr(quota, now)
+		if err != nil {
+			xl.Errorf("failed to count %s quota, error %v", quota.Kind, err)
+			release()
+			return nil, err
+		}
+		reserved = append(reserved, quota)
+		if count > quota.Limit {
+			xl.Infof("%s quota of %s exceeded, count %d, limit %d", quota.Kind, quota.Key, count, quota.Limit)
+			release()
+			return nil, &errors2.ServerError{Code: l.quotaErrCode, Summary: quota.Kind + " daily quota exceeded"}
+		}
+	}
+	return release, nil
+}
+
+// incr 累加当天的计数并返回累加后的值，计数在次日过期。
+func (l *Limiter) incr(quota Quota, now time.Time) (int, error) {
+	year, month, day := now.Date()
+	tomorrow := time.Date(year, month, day+1, 0, 0, 0, 0, now.Location())
+	record := model.SMSQuotaDo{}
+	_, err := l.quotaColl.FindId(quota.id(now)).Apply(mgo.Change{
+		Update: bson.M{
+			"$inc":         bson.M{"count": 1},
+			"$setOnInsert": bson.M{"expireAt": tomorrow.Add(time.Hour)},
+		},
+		Upsert:    true,
+		ReturnNew: true,
+	}, &record)
+	if err != nil {
+		return 0, err
+	}
+	return record.Count, nil
+}
+
+// decr 回退一次incr的累加。
+func (l *Limiter) decr(xl *xlog.Logger, quota Quota, now time.Time) {
+	err := l.quotaColl.UpdateId(quota.id(now), bson.M{"$inc": bson.M{"count": -1}})
+	if err != nil && err != mgo.ErrNotFound {
+		xl.Errorf("failed to release %s quota of %s, error %v", quota.Kind, quota.Key, err)
+	}
+}
+
+// CheckLockout key因失败次数过多而锁定时返回错误。
+func (l *Limiter) CheckLockout(xl *xlog.Logger, key string) error {
+	if l.maxFailures <= 0 {
+		return nil
+	}
+	record := model.SMSValidateFailureDo{}
+	err := l.failureColl.FindId(key).One(&record)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil
+		}
+		xl.Errorf("failed to find failure record of %s, error %v", key, err)
+		return err
+	}
+	if record.LockedUntil.After(time.Now()) {
+		xl.Infof("%s is locked until %v for too many failures", key, record.LockedUntil)
+		return &errors2.ServerError{Code: l.lockedErrCode, Summary: "too many failures"}
+	}
+	return nil
+}
+
+// RecordFailure 记录一次失败，连续失败达到上限时锁定key。
+func (l *Limiter) RecordFailure(xl *xlog.Logger, key string) {
+	if l.maxFailures <= 0 {
+		return
+	}
+	now := time.Now()
+	record := model.SMSValidateFailureDo{}
+	_, err := l.failureColl.FindId(key).Apply(mgo.Change{
+		Update: bson.M{
+			"$inc": bson.M{"failures": 1},
+			"$set": bson.M{"expireAt": now.Add(l.lockoutTimeout)},
+		},
+		Upsert:    true,
+		ReturnNew: true,
+	}, &record)
+	if err != nil {
+		xl.Errorf("failed to record failure of %s, error %v", key, err)
+		return
+	}
+	if record.Failures < l.maxFailures {
+		return
+	}
+	lockedUntil := now.Add(l.lockoutTimeout)
+	err = l.failureColl.UpdateId(key, bson.M{"$set": bson.M{
+		"failures":    0,
+		"lockedUntil": lockedUntil,
+		"expireAt":    lockedUntil,
+	}})
+	if err != nil {
+		xl.Errorf("failed to lock %s, error %v", key, err)
+		return
+	}
+	xl.Infof("%s locked until %v after %d failures", key, lockedUntil, record.Failures)
+}
+
+// ResetFailures 成功后清除失败记录。
+func (l *Limiter) ResetFailures(xl *xlog.Logger, key string) {
+	err := l.failureColl.RemoveId(key)
+	if err != nil && err != mgo.ErrNotFound {
+		xl.Errorf("failed to reset failures of %s, error %v", key, err)
+	}
+}
@@ -0,0 +1,154 @@
+package cloud
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/utils"
+)
+
+const (
+	// MailDefaultRetryInterval 发送邮件失败后默认的重试间隔。
+	MailDefaultRetryInterval = time.Second
+	// MailDefaultQueueSize 待发送邮件队列的默认长度。
+	MailDefaultQueueSize = 100
+)
+
+// MailSender 发送邮件。
+type MailSender interface {
+	SendMail(xl *xlog.Logger, to []string, subject string, body string) error
+}
+
+// NewMailSender 根据配置创建邮件发送器，未开启邮件时返回只记录日志的发送器，供开发与测试使用。
+func NewMailSender(conf *utils.MailConfig) MailSender {
+	if conf == nil || !conf.Enabled {
+		return &mockMailSender{}
+	}
+	return NewAsyncMailSender(NewSMTPMailSender(conf), conf.QueueSize)
+}
+
+type mockMailSender struct {
+}
+
+func (m *mockMailSender) SendMail(xl *xlog.Logger, to []string, subject string, body string) error {
+	xl.Debugf("mock: send mail %q to %v: %s", subject, to, body)
+	return nil
+}
+
+// ErrMailQueueFull 待发送邮件队列已满。
+var ErrMailQueueFull = errors.New("mail queue is full")
+
+type mailTask struct {
+	xl      *xlog.Logger
+	to      []string
+	subject string
+	body    string
+}
+
+// AsyncMailSender 把邮件放入队列后立即返回，由后台goroutine调用sender发送，
+// 避免SMTP连接与失败重试阻塞请求。发送结果只记录日志。
+type AsyncMailSender struct {
+	sender MailSender
+	queue  chan mailTask
+	done   chan struct{}
+}
+
+// NewAsyncMailSender 创建异步邮件发送器并启动后台发送，queueSize小于等于0时使用默认长度。
+func NewAsyncMailSender(sender MailSender, queueSize int) *AsyncMailSender {
+	if queueSize <= 0 {
+		queueSize = MailDefaultQueueSize
+	}
+	s := &AsyncMailSender{
+		sender: sender,
+		queue:  make(chan mailTask, queueSize),
+		done:   make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+// SendMail 把邮件加入待发送队列，队列已满时返回ErrMailQueueFull。
+func (s *AsyncMailSender) SendMail(xl *xlog.Logger, to []string, subject string, body string) error {
+	select {
+	case s.queue <- mailTask{xl: xl, to: to, subject: subject, body: body}:
+		return nil
+	default:
+		xl.Errorf("mail queue is full, drop mail %q to %v", subject, to)
+		return ErrMailQueueFull
+	}
+}
+
+// Close 停止接收新邮件，并等待队列中的邮件发送完。
+func (s *AsyncMailSender) Close() {
+	close(s.queue)
+	<-s.done
+}
+
+func (s *AsyncMailSender) loop() {
+	defer close(s.done)
+	for task := range s.queue {
+		err := s.sender.SendMail(task.xl, task.to, task.subject, task.body)
+		if err != nil {
+			task.xl.Errorf("failed to send mail %q to %v, error %v", task.subject, task.to, err)
+		}
+	}
+}
+
+// SMTPMailSender 通过SMTP服务发送邮件，失败时按配置重试。
+type SMTPMailSender struct {
+	conf          *utils.MailConfig
+	retryInterval time.Duration
+}
+
+// NewSMTPMailSender 创建SMTP邮件发送器。
+func NewSMTPMailSender(conf *utils.MailConfig) *SMTPMailSender {
+	retryInterval := MailDefaultRetryInterval
+	if conf.RetryIntervalSecond > 0 {
+		retryInterval = time.Duration(conf.RetryIntervalSecond) * time.Second
+	}
+	return &SMTPMailSender{
+		conf:          conf,
+		retryInterval: retryInterval,
+	}
+}
+
+// SendMail 发送纯文本邮件。
+func (s *SMTPMailSender) SendMail(xl *xlog.Logger, to []string, subject string, body string) error {
+	addr := fmt.Sprintf("%s:%d", s.conf.SMTPHost, s.conf.SMTPPort)
+	var auth smtp.Auth
+	if s.conf.Username != "" {
+		auth = smtp.PlainAuth("", s.conf.Username, s.conf.Password, s.conf.SMTPHost)
+	}
+	msg := s.buildMessage(to, subject, body)
+	var err error
+	for i := 0; i <= s.conf.RetryTimes; i++ {
+		if i > 0 {
+			time.Sleep(s.retryInterval)
+		}
+		err = smtp.SendMail(addr, auth, s.conf.From, to, msg)
+		if err == nil {
+			return nil
+		}
+		xl.Errorf("failed to send mail %q to %v (attempt %d), error %v", subject, to, i+1, err)
+	}
+	return err
+}
+
+func (s *SMTPMailSender) buildMessage(to []string, subject string, body string) []byte {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "From: %s\r\n", s.conf.From)
+	fmt.Fprintf(buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return buf.Bytes()
+}
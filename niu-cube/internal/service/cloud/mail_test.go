@@ -0,0 +1,57 @@
+package cloud
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qiniu/x/xlog"
+)
+
+// blockingMailSender 在release关闭前阻塞发送，记录收到的邮件标题。
+type blockingMailSender struct {
+	release chan struct{}
+	mu      sync.Mutex
+	sent    []string
+}
+
+func (s *blockingMailSender) SendMail(xl *xlog.Logger, to []string, subject string, body string) error {
+	<-s.release
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, subject)
+	return nil
+}
+
+func TestAsyncMailSender(t *testing.T) {
+	sender := &blockingMailSender{release: make(chan struct{})}
+	async := NewAsyncMailSender(sender, 2)
+	xl := xlog.New("test-mail")
+
+	// 第一封被后台取出后阻塞，队列还能容纳两封。
+	results := make([]error, 0, 4)
+	start := time.Now()
+	for _, subject := range []string{"a", "b", "c", "d"} {
+		results = append(results, async.SendMail(xl, []string{"user@example.com"}, subject, "body"))
+		if subject == "a" {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("SendMail should not wait for delivery, took %v", elapsed)
+	}
+	for i, err := range results[:3] {
+		if err != nil {
+			t.Fatalf("mail %d rejected: %v", i, err)
+		}
+	}
+	if results[3] != ErrMailQueueFull {
+		t.Fatalf("mail beyond queue size error = %v, want ErrMailQueueFull", results[3])
+	}
+
+	close(sender.release)
+	async.Close()
+	if len(sender.sent) != 3 || sender.sent[0] != "a" || sender.sent[2] != "c" {
+		t.Fatalf("sent = %v, want [a b c]", sender.sent)
+	}
+}
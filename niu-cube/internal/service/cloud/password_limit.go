@@ -0,0 +1,99 @@
+package cloud
+
+import (
+	"time"
+
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/utils"
+	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
+	dao "github.com/solutions/niu-cube/internal/service/db/dao"
+)
+
+var (
+	// PasswordDefaultMaxLoginFailures 默认连续输错密码该次数后锁定邮箱。
+	PasswordDefaultMaxLoginFailures = 5
+	// PasswordDefaultLoginLockoutTimeout 邮箱默认的锁定时长。
+	PasswordDefaultLoginLockoutTimeout = 15 * time.Minute
+	// MailDefaultPerEmailDaily 每个邮箱每天默认最多发送的验证、重置密码邮件数量。
+	MailDefaultPerEmailDaily = 5
+	// MailDefaultPerIPDaily 每个IP每天默认最多请求发送的验证、重置密码邮件数量。
+	MailDefaultPerIPDaily = 20
+)
+
+const (
+	mailQuotaKindEmail = "mail_email"
+	mailQuotaKindIP    = "mail_ip"
+	// loginFailureKeyPrefix 密码输错记录的ID前缀，与短信验证码的输错记录共用集合。
+	loginFailureKeyPrefix = "password:"
+)
+
+// PasswordLimiter 邮箱密码登录的输错锁定，以及验证、重置密码邮件的发送额度，与短信验证码共用计数与锁定记录。
+type PasswordLimiter struct {
+	limiter       *Limiter
+	perEmailDaily int
+	perIPDaily    int
+}
+
+// NewPasswordLimiter 创建邮箱密码登录的限制器。
+func NewPasswordLimiter(mongoConf *utils.MongoConfig, config *utils.Config, xl *xlog.Logger) (*PasswordLimiter, error) {
+	if xl == nil {
+		xl = xlog.New("niu-cube-password-limiter")
+	}
+	mongoClient, err := mongodb.Get(mongoConf)
+	if err != nil {
+		xl.Errorf("failed to create mongo client, error %v", err)
+		return nil, err
+	}
+	l := &PasswordLimiter{
+		perEmailDaily: MailDefaultPerEmailDaily,
+		perIPDaily:    MailDefaultPerIPDaily,
+	}
+	maxFailures := PasswordDefaultMaxLoginFailures
+	lockoutTimeout := PasswordDefaultLoginLockoutTimeout
+	if conf := config.Password; conf != nil {
+		if conf.MaxLoginFailures != 0 {
+			maxFailures = conf.MaxLoginFailures
+		}
+		if conf.LoginLockoutSecond > 0 {
+			lockoutTimeout = time.Duration(conf.LoginLockoutSecond) * time.Second
+		}
+		if conf.MailPerEmailDaily != 0 {
+			l.perEmailDaily = conf.MailPerEmailDaily
+		}
+		if conf.MailPerIPDaily != 0 {
+			l.perIPDaily = conf.MailPerIPDaily
+		}
+	}
+	l.limiter, err = NewLimiter(mongoClient.C(dao.CollectionSMSQuota), mongoClient.C(dao.CollectionSMSValidateFailure),
+		maxFailures, lockoutTimeout, errors2.ServerErrorMailQuotaExceeded, errors2.ServerErrorLoginLocked)
+	if err != nil {
+		xl.Errorf("failed to create password limiter, error %v", err)
+		return nil, err
+	}
+	return l, nil
+}
+
+// CheckLogin 邮箱因密码输错次数过多而锁定时返回错误。
+func (l *PasswordLimiter) CheckLogin(xl *xlog.Logger, email string) error {
+	return l.limiter.CheckLockout(xl, loginFailureKeyPrefix+email)
+}
+
+// RecordLoginFailure 记录一次密码输错，连续输错达到上限时锁定邮箱。
+func (l *PasswordLimiter) RecordLoginFailure(xl *xlog.Logger, email string) {
+	l.limiter.RecordFailure(xl, loginFailureKeyPrefix+email)
+}
+
+// ResetLoginFailures 登录成功后清除输错记录。
+func (l *PasswordLimiter) ResetLoginFailures(xl *xlog.Logger, email string) {
+	l.limiter.ResetFailures(xl, loginFailureKeyPrefix+email)
+}
+
+// ReserveMail 累加邮箱与IP当天的邮件计数，超出限制时返回错误。邮箱未注册时同样计数，避免泄露邮箱是否注册。
+func (l *PasswordLimiter) ReserveMail(xl *xlog.Logger, email string, ip string) (func(), error) {
+	quotas := []Quota{{Kind: mailQuotaKindEmail, Key: email, Limit: l.perEmailDaily}}
+	if ip != "" {
+		quotas = append(quotas, Quota{Kind: mailQuotaKindIP, Key: ip, Limit: l.perIPDaily})
+	}
+	return l.limiter.Reserve(xl, quotas...)
+}
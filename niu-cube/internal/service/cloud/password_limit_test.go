@@ -0,0 +1,56 @@
+package cloud
+
+import (
+	"testing"
+	"time"
+
+	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
+	"github.com/solutions/niu-cube/internal/service/db/dao"
+	"github.com/solutions/niu-cube/internal/service/db/dao/daotest"
+)
+
+func TestPasswordLimiter(t *testing.T) {
+	db, _ := daotest.NewDatabase(t)
+	limiter, err := NewLimiter(db.C(dao.CollectionSMSQuota), db.C(dao.CollectionSMSValidateFailure),
+		2, time.Minute, errors2.ServerErrorMailQuotaExceeded, errors2.ServerErrorLoginLocked)
+	if err != nil {
+		t.Fatalf("NewLimiter: %v", err)
+	}
+	l := &PasswordLimiter{limiter: limiter, perEmailDaily: 2, perIPDaily: 3}
+	email := "user@example.com"
+
+	// 连续输错两次后锁定，登录成功会清除之前的输错次数。
+	l.RecordLoginFailure(nil, email)
+	l.ResetLoginFailures(nil, email)
+	l.RecordLoginFailure(nil, email)
+	if err := l.CheckLogin(nil, email); err != nil {
+		t.Fatalf("one failure should not lock, error %v", err)
+	}
+	l.RecordLoginFailure(nil, email)
+	if err := l.CheckLogin(nil, email); serverErrorCode(err) != errors2.ServerErrorLoginLocked {
+		t.Fatalf("CheckLogin error = %v, want login locked", err)
+	}
+	// 密码输错记录与短信验证码输错记录互不影响。
+	if err := limiter.CheckLockout(nil, email); err != nil {
+		t.Fatalf("plain key should not be locked, error %v", err)
+	}
+
+	steps := []struct {
+		email    string
+		ip       string
+		wantCode int
+	}{
+		{email: "a@example.com", ip: "1.1.1.1"},
+		{email: "a@example.com", ip: "1.1.1.1"},
+		{email: "a@example.com", ip: "1.1.1.1", wantCode: errors2.ServerErrorMailQuotaExceeded},
+		{email: "b@example.com", ip: "1.1.1.1"},
+		{email: "c@example.com", ip: "1.1.1.1", wantCode: errors2.ServerErrorMailQuotaExceeded},
+		{email: "c@example.com", ip: "2.2.2.2"},
+	}
+	for i, step := range steps {
+		_, err := l.ReserveMail(nil, step.email, step.ip)
+		if serverErrorCode(err) != step.wantCode {
+			t.Fatalf("step %d: ReserveMail error = %v, want code %d", i, err, step.wantCode)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package cloud
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -14,6 +15,8 @@ import (
 	qiniurtc "github.com/qiniu/go-sdk/v7/rtc"
 	"github.com/qiniu/x/xlog"
 
+	"github.com/solutions/niu-cube/internal/common/metrics"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
 )
 
@@ -21,6 +24,8 @@ type RTCService struct {
 	*qiniurtc.Manager
 	conf   utils.QiniuRTCConfig
 	signer *qiniuauth.Credentials
+	// client 直接调用RTC与直播接口的HTTP客户端，SDK的调用通过metrics.ObserveCall与tracing.StartCall记录。
+	client *http.Client
 	xl     *xlog.Logger
 }
 
@@ -42,11 +47,19 @@ func NewRtcService(conf utils.Config) *RTCService {
 	}
 	client := qiniurtc.NewManager(r.signer)
 	r.Manager = client
+	r.client = metrics.NewHTTPClient("rtc", 0)
 	return r
 }
 
-func (r *RTCService) ListUser(roomId string) (res []string, err error) {
+func (r *RTCService) ListUser(ctx context.Context, xl *xlog.Logger, roomId string) (res []string, err error) {
+	if xl == nil {
+		xl = r.xl
+	}
+	start := time.Now()
+	span := tracing.StartCall(ctx, "rtc", "ListUser")
 	users, err := r.Manager.ListUser(r.conf.AppID, roomId)
+	tracing.End(span, err)
+	metrics.ObserveCall("rtc", "ListUser", start, err)
 	color.Blue(fmt.Sprintf("%d", len(users)))
 	if err != nil {
 		return nil, err
@@ -59,14 +72,25 @@ func (r *RTCService) ListUser(roomId string) (res []string, err error) {
 	}
 }
 
-func (r *RTCService) KickUser(roomId, userId string) error {
-	return r.Manager.KickUser(r.conf.AppID, roomId, userId)
+func (r *RTCService) KickUser(ctx context.Context, xl *xlog.Logger, roomId, userId string) error {
+	if xl == nil {
+		xl = r.xl
+	}
+	start := time.Now()
+	span := tracing.StartCall(ctx, "rtc", "KickUser")
+	err := r.Manager.KickUser(r.conf.AppID, roomId, userId)
+	tracing.End(span, err)
+	metrics.ObserveCall("rtc", "KickUser", start, err)
+	return err
 }
 
-func (r *RTCService) Online(roomId, userId string) bool {
+func (r *RTCService) Online(ctx context.Context, xl *xlog.Logger, roomId, userId string) bool {
+	if xl == nil {
+		xl = r.xl
+	}
 	result := make(chan bool)
 	go func() {
-		users, err := r.ListUser(roomId)
+		users, err := r.ListUser(ctx, xl, roomId)
 		if err != nil {
 			result <- false
 		}
@@ -81,7 +105,7 @@ func (r *RTCService) Online(roomId, userId string) bool {
 	case res := <-result:
 		return res
 	case <-time.After(SDKInvokeTimeout):
-		r.xl.Infof("rtc db list users timeout")
+		xl.Infof("rtc db list users timeout")
 		return false
 	}
 }
@@ -102,7 +126,10 @@ func (r *RTCService) GenerateRTCRoomToken(roomId, userId, permission string) str
 	return token
 }
 
-func (r *RTCService) RecordPlayBackM3u8(streamName string, from, to int64, callback func(filename map[string]string, ok bool) error) error {
+func (r *RTCService) RecordPlayBackM3u8(ctx context.Context, xl *xlog.Logger, streamName string, from, to int64, callback func(filename map[string]string, ok bool) error) error {
+	if xl == nil {
+		xl = r.xl
+	}
 	encodedStreamName := base64.StdEncoding.EncodeToString([]byte(streamName))
 	params := map[string]interface{}{
 		"fname":  streamName,
@@ -114,24 +141,24 @@ func (r *RTCService) RecordPlayBackM3u8(streamName string, from, to int64, callb
 	url := fmt.Sprintf("https://pili.qiniuapi.com/v2/hubs/%s/streams/%s/saveas", r.conf.Hub, encodedStreamName)
 	req, err := http.NewRequest("POST", url, bytes.NewReader(val))
 	if err != nil {
-		r.xl.Errorf("error making req err:%v", err)
+		xl.Errorf("error making req err:%v", err)
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	sign, err := r.signer.SignRequestV2(req)
 	if err != nil {
-		r.xl.Errorf("error signing req err:%v", err)
+		xl.Errorf("error signing req err:%v", err)
 		return err
 	}
 	req.Header.Set("Authorization", "Qiniu "+sign)
-	res, err := http.DefaultClient.Do(req)
+	res, err := r.client.Do(req.WithContext(ctx))
 	if err != nil {
-		r.xl.Errorf("error invoke api err:%v", err)
+		xl.Errorf("error invoke api err:%v", err)
 		return err
 	}
 	data, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		r.xl.Errorf("error read body err:%v", err)
+		xl.Errorf("error read body err:%v", err)
 		return err
 	}
 	resp := make(map[string]string, 0)
@@ -144,7 +171,10 @@ func (r *RTCService) RecordPlayBackM3u8(streamName string, from, to int64, callb
 	return nil
 }
 
-func (r *RTCService) CreateMerge(interviewId string, interviewerId string, otherIds ...string) error {
+func (r *RTCService) CreateMerge(ctx context.Context, xl *xlog.Logger, interviewId string, interviewerId string, otherIds ...string) error {
+	if xl == nil {
+		xl = r.xl
+	}
 	streamName := r.streamName(interviewId)
 	users := []mergeUser{
 		{
@@ -183,19 +213,19 @@ func (r *RTCService) CreateMerge(interviewId string, interviewerId string, other
 	req.Header.Set("Content-Type", "application/json")
 	sign, _ := r.signer.SignRequestV2(req)
 	req.Header.Set("Authorization", "Qiniu "+sign)
-	res, err := http.DefaultClient.Do(req)
+	res, err := r.client.Do(req.WithContext(ctx))
 	if err != nil {
-		r.xl.Errorf("error invoke api %s:%v", url, err)
+		xl.Errorf("error invoke api %s:%v", url, err)
 		return err
 	}
 	resp := make(map[string]string, 0)
 	err = json.NewDecoder(res.Body).Decode(&resp)
 	if err != nil {
-		r.xl.Errorf("error unmarshal err:%v", err)
+		xl.Errorf("error unmarshal err:%v", err)
 		return err
 	}
-	r.xl.Infof("request %v", req)
-	r.xl.Infof("success create merge job %v", resp)
+	xl.Infof("request %v", req)
+	xl.Infof("success create merge job %v", resp)
 	return nil
 }
 
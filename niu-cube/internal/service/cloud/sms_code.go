@@ -1,8 +1,12 @@
 package cloud
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/solutions/niu-cube/internal/common/metrics"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
 	model "github.com/solutions/niu-cube/internal/protodef/model"
@@ -30,32 +34,39 @@ var (
 )
 
 type SmsSender interface {
-	SendSmsCode(xl *xlog.Logger, phone string, code string) error
+	SendSmsCode(ctx context.Context, xl *xlog.Logger, phone string, code string) error
 }
 
 type SmsCodeService struct {
-	mongoClient     *mgo.Session
-	smsCodeColl     *mgo.Collection
+	mongoClient     *mongodb.Manager
+	smsCodeColl     *mongodb.Collection
+	limiter         *Limiter
 	smsSender       SmsSender
+	smsProvider     string
 	resendTimeout   time.Duration
 	validateTimeout time.Duration
 	expireTimeout   time.Duration
 	randSource      rand.Source
+	limits          smsLimits
+	countryCode     string
+	// fallbackSender 主短信服务发送失败时切换的备用短信服务，可为空。
+	fallbackSender   SmsSender
+	fallbackProvider string
 	// fixedCodes 固定的手机号与验证码组合，供测试用。
 	fixedCodes map[string]string
 	xl         *xlog.Logger
 }
 
-func NewSmsCodeService(mongoURI string, database string, config *utils.Config, xl *xlog.Logger) (*SmsCodeService, error) {
+func NewSmsCodeService(mongoConf *utils.MongoConfig, config *utils.Config, xl *xlog.Logger) (*SmsCodeService, error) {
 	if xl == nil {
 		xl = xlog.New("niu-cube-sms-code-controller")
 	}
-	mongoClient, err := mgo.Dial(mongoURI + "/" + mongoURI)
+	mongoClient, err := mongodb.Get(mongoConf)
 	if err != nil {
 		xl.Errorf("failed to create mongo client, error %v", err)
 		return nil, err
 	}
-	smsCodeColl := mongoClient.DB(database).C(dao.CollectionSMSCode)
+	smsCodeColl := mongoClient.C(dao.CollectionSMSCode)
 	c := &SmsCodeService{
 		mongoClient:     mongoClient,
 		smsCodeColl:     smsCodeColl,
@@ -63,20 +74,31 @@ func NewSmsCodeService(mongoURI string, database string, config *utils.Config, x
 		validateTimeout: SMSCodeDefaultValidateTimeout,
 		expireTimeout:   SMSCodeExpireTimeout,
 		randSource:      rand.NewSource(time.Now().UnixNano()),
+		limits:          newSmsLimits(config.SMS.Limit),
+		countryCode:     config.SMS.DefaultCountryCode,
 		fixedCodes:      config.SMS.FixedCodes,
 		xl:              xl,
 	}
+	c.limiter, err = NewLimiter(mongoClient.C(dao.CollectionSMSQuota), mongoClient.C(dao.CollectionSMSValidateFailure),
+		c.limits.maxValidateFailures, c.limits.lockoutTimeout, errors2.ServerErrorSMSQuotaExceeded, errors2.ServerErrorSMSValidateLocked)
+	if err != nil {
+		xl.Errorf("failed to create sms limiter, error %v", err)
+		return nil, err
+	}
 	// 创建短信发送器。
-	switch config.SMS.Provider {
-	// 模拟的短信发送器，仅供测试使用。
-	case "test":
-		c.smsSender = &mockSmsSender{}
-	case "qiniu":
-		sender := NewQiniuSmsSender(config)
-		c.smsSender = sender
-	default:
-		xl.Errorf("unsupported SMS provider %s", config.SMS.Provider)
-		return nil, fmt.Errorf("unsupported SMS provider")
+	c.smsSender, err = NewSmsSender(config.SMS.Provider, config)
+	if err != nil {
+		xl.Errorf("failed to create SMS provider %s, error %v", config.SMS.Provider, err)
+		return nil, err
+	}
+	c.smsProvider = config.SMS.Provider
+	if config.SMS.FallbackProvider != "" && config.SMS.FallbackProvider != config.SMS.Provider {
+		c.fallbackSender, err = NewSmsSender(config.SMS.FallbackProvider, config)
+		if err != nil {
+			xl.Errorf("failed to create fallback SMS provider %s, error %v", config.SMS.FallbackProvider, err)
+			return nil, err
+		}
+		c.fallbackProvider = config.SMS.FallbackProvider
 	}
 	return c, nil
 }
@@ -84,7 +106,7 @@ func NewSmsCodeService(mongoURI string, database string, config *utils.Config, x
 type mockSmsSender struct {
 }
 
-func (m *mockSmsSender) SendSmsCode(xl *xlog.Logger, phone string, code string) error {
+func (m *mockSmsSender) SendSmsCode(ctx context.Context, xl *xlog.Logger, phone string, code string) error {
 	xl.Debugf("mock: send code %s to %s", code, phone)
 	return nil
 }
@@ -108,13 +130,17 @@ func NewQiniuSmsSender(conf *utils.Config) *QiniuSmsSender {
 }
 
 // SendMessage 发送验证码为code的短信。
-func (s *QiniuSmsSender) SendSmsCode(xl *xlog.Logger, phone string, code string) error {
+func (s *QiniuSmsSender) SendSmsCode(ctx context.Context, xl *xlog.Logger, phone string, code string) error {
+	start := time.Now()
+	span := tracing.StartCall(ctx, "sms", "SendMessage")
 	_, err := s.manager.SendMessage(qiniusms.MessagesRequest{
 		SignatureID: s.conf.SMS.QiniuSMS.SignatureID,
 		TemplateID:  s.conf.SMS.QiniuSMS.TemplateID,
 		Mobiles:     []string{phone},
 		Parameters:  map[string]interface{}{SMSCodeParamKey: code},
 	})
+	tracing.End(span, err)
+	metrics.ObserveCall("sms", "SendMessage", start, err)
 	if err != nil {
 		xl.Errorf("failed to send message, error %v", err)
 		return err
@@ -122,12 +148,16 @@ func (s *QiniuSmsSender) SendSmsCode(xl *xlog.Logger, phone string, code string)
 	return nil
 }
 
-// Send 对给定手机号发送验证码。
-func (c *SmsCodeService) Send(xl *xlog.Logger, phone string) error {
+// Send 对给定手机号发送验证码，ip为请求方IP，用于按IP限制发送数量。
+func (c *SmsCodeService) Send(ctx context.Context, xl *xlog.Logger, phone string, ip string) error {
 	if xl == nil {
 		xl = c.xl
 	}
-	// TODO: 校验手机号码。
+	phone, ok := utils.NormalizePhone(phone, c.countryCode)
+	if !ok {
+		xl.Infof("invalid phone number %s", phone)
+		return &errors2.ServerError{Code: errors2.ServerErrorPhoneInvalid, Summary: "invalid phone number"}
+	}
 	// 首先查找是否有1分钟内发送给该手机号的记录。
 	now := time.Now()
 	filter := map[string]interface{}{
@@ -135,6 +165,10 @@ func (c *SmsCodeService) Send(xl *xlog.Logger, phone string) error {
 		"sendTime": map[string]interface{}{
 			"$gt": now.Add(-c.resendTimeout),
 		},
+		// 发送失败的记录不限制重发。
+		"status": map[string]interface{}{
+			"$ne": model.SMSDeliveryStatusFailed,
+		},
 	}
 	sendCount, err := c.smsCodeColl.Find(filter).Count()
 	if err != nil && err != mgo.ErrNotFound {
@@ -145,6 +179,10 @@ func (c *SmsCodeService) Send(xl *xlog.Logger, phone string) error {
 		xl.Infof("phone number %s has already been sent to in 1 minute", phone)
 		return &errors2.ServerError{Code: errors2.ServerErrorSMSSendTooFrequent, Summary: ""}
 	}
+	releaseQuotas, err := c.reserveQuotas(xl, phone, ip)
+	if err != nil {
+		return err
+	}
 
 	code := fmt.Sprintf("%06d", c.randSource.Int63()%1000000)
 
@@ -156,28 +194,56 @@ func (c *SmsCodeService) Send(xl *xlog.Logger, phone string) error {
 		SMSCode:  code,
 		SendTime: time.Now(),
 		ExpireAt: time.Now().Add(c.expireTimeout),
+		Status:   model.SMSDeliveryStatusPending,
+		Provider: c.smsProvider,
 	}
 	err = c.smsCodeColl.Insert(smsCodeRecord)
 	if err != nil {
 		xl.Errorf("failed to insert SMS code record, error %v", err)
+		releaseQuotas()
 		return err
 	}
-	err = c.smsSender.SendSmsCode(xl, phone, code)
+	err = c.deliver(ctx, xl, smsCodeRecord)
+	updateErr := c.smsCodeColl.UpdateId(smsCodeID, bson.M{"$set": bson.M{
+		"status":          smsCodeRecord.Status,
+		"provider":        smsCodeRecord.Provider,
+		"failedProviders": smsCodeRecord.FailedProviders,
+		"lastError":       smsCodeRecord.LastError,
+		"deliverTime":     smsCodeRecord.DeliverTime,
+	}})
+	if updateErr != nil {
+		xl.Errorf("failed to update delivery status of sms code record %s, error %v", smsCodeID, updateErr)
+	}
 	if err != nil {
 		xl.Errorf("failed to send SMS code, error %v", err)
-		// 删除已插入的发送记录。
-		xl.Errorf("===========> %s", smsCodeID)
-		deleteErr := c.smsCodeColl.Remove(map[string]interface{}{"_id": smsCodeID})
-		if deleteErr != nil {
-			xl.Errorf("failed to delete sms code record in mongo, error %v", deleteErr)
-		}
+		releaseQuotas()
+		return err
+	}
+	xl.Debugf("sent code %s to phone number %s by %s", code, phone, smsCodeRecord.Provider)
+	return nil
+}
+
+// deliver 通过主短信服务发送验证码，失败时切换到备用短信服务，并把发送结果记录到record上。
+func (c *SmsCodeService) deliver(ctx context.Context, xl *xlog.Logger, record *model.SMSCodeDo) error {
+	err := c.smsSender.SendSmsCode(ctx, xl, record.Phone, record.SMSCode)
+	if err != nil && c.fallbackSender != nil {
+		xl.Infof("SMS provider %s failed, error %v, fail over to %s", c.smsProvider, err, c.fallbackProvider)
+		record.FailedProviders = append(record.FailedProviders, c.smsProvider)
+		record.Provider = c.fallbackProvider
+		err = c.fallbackSender.SendSmsCode(ctx, xl, record.Phone, record.SMSCode)
+	}
+	if err != nil {
+		record.FailedProviders = append(record.FailedProviders, record.Provider)
+		record.Status = model.SMSDeliveryStatusFailed
+		record.LastError = err.Error()
 		return err
 	}
-	xl.Debugf("sent code %s to phone number %s", code, phone)
+	record.Status = model.SMSDeliveryStatusSent
+	record.DeliverTime = time.Now()
 	return nil
 }
 
-// Validate 检验手机号与验证码是否符合。
+// Validate 检验手机号与验证码是否符合。连续输错验证码次数过多时，手机号在一段时间内无法通过校验。
 func (c *SmsCodeService) Validate(xl *xlog.Logger, phone string, code string) error {
 	if xl == nil {
 		xl = c.xl
@@ -190,6 +256,10 @@ func (c *SmsCodeService) Validate(xl *xlog.Logger, phone string, code string) er
 			return nil
 		}
 	}
+	err := c.limiter.CheckLockout(xl, phone)
+	if err != nil {
+		return err
+	}
 	now := time.Now()
 	filter := map[string]interface{}{
 		"phone":   phone,
@@ -197,6 +267,9 @@ func (c *SmsCodeService) Validate(xl *xlog.Logger, phone string, code string) er
 		"sendTime": map[string]interface{}{
 			"$gt": now.Add(-c.validateTimeout),
 		},
+		"status": map[string]interface{}{
+			"$ne": model.SMSDeliveryStatusFailed,
+		},
 	}
 	smsCodeRecord := model.SMSCodeDo{}
 	b, jsonerr := json.Marshal(filter)
@@ -205,14 +278,16 @@ func (c *SmsCodeService) Validate(xl *xlog.Logger, phone string, code string) er
 	}
 	xl.Infof("filter > %s", string(b))
 
-	err := c.smsCodeColl.Find(filter).One(&smsCodeRecord)
+	err = c.smsCodeColl.Find(filter).One(&smsCodeRecord)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Infof("sms code is not found or expired")
+			c.limiter.RecordFailure(xl, phone)
 		} else {
 			xl.Errorf("failed to find sms code record, error %v", err)
 		}
 		return err
 	}
+	c.limiter.ResetFailures(xl, phone)
 	return nil
 }
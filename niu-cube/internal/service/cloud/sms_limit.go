@@ -0,0 +1,79 @@
+package cloud
+
+import (
+	"time"
+
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/utils"
+)
+
+var (
+	// SMSDefaultPerIPDaily 每个IP每天默认最多发送的验证码数量。
+	SMSDefaultPerIPDaily = 20
+	// SMSDefaultPerPhoneDaily 每个手机号每天默认最多发送的验证码数量。
+	SMSDefaultPerPhoneDaily = 10
+	// SMSDefaultGlobalDaily 每天默认最多发送的验证码总数。
+	SMSDefaultGlobalDaily = 10000
+	// SMSDefaultMaxValidateFailures 默认连续输错验证码该次数后锁定手机号。
+	SMSDefaultMaxValidateFailures = 5
+	// SMSDefaultLockoutTimeout 手机号默认的锁定时长。
+	SMSDefaultLockoutTimeout = 15 * time.Minute
+)
+
+const (
+	smsQuotaKindIP     = "ip"
+	smsQuotaKindPhone  = "phone"
+	smsQuotaKindGlobal = "global"
+)
+
+// smsLimits 短信验证码的防刷限制，数量小于等于0表示不限制。
+type smsLimits struct {
+	perIPDaily          int
+	perPhoneDaily       int
+	globalDaily         int
+	maxValidateFailures int
+	lockoutTimeout      time.Duration
+}
+
+func newSmsLimits(conf *utils.SMSLimitConfig) smsLimits {
+	limits := smsLimits{
+		perIPDaily:          SMSDefaultPerIPDaily,
+		perPhoneDaily:       SMSDefaultPerPhoneDaily,
+		globalDaily:         SMSDefaultGlobalDaily,
+		maxValidateFailures: SMSDefaultMaxValidateFailures,
+		lockoutTimeout:      SMSDefaultLockoutTimeout,
+	}
+	if conf == nil {
+		return limits
+	}
+	if conf.PerIPDaily != 0 {
+		limits.perIPDaily = conf.PerIPDaily
+	}
+	if conf.PerPhoneDaily != 0 {
+		limits.perPhoneDaily = conf.PerPhoneDaily
+	}
+	if conf.GlobalDaily != 0 {
+		limits.globalDaily = conf.GlobalDaily
+	}
+	if conf.MaxValidateFailures != 0 {
+		limits.maxValidateFailures = conf.MaxValidateFailures
+	}
+	if conf.LockoutSecond > 0 {
+		limits.lockoutTimeout = time.Duration(conf.LockoutSecond) * time.Second
+	}
+	return limits
+}
+
+// reserveQuotas 累加IP、手机号与全局当天的发送计数，任一超出限制时返回错误，
+// 否则返回发送失败时回退计数的函数。
+func (c *SmsCodeService) reserveQuotas(xl *xlog.Logger, phone string, ip string) (func(), error) {
+	quotas := make([]Quota, 0, 3)
+	if ip != "" {
+		quotas = append(quotas, Quota{Kind: smsQuotaKindIP, Key: ip, Limit: c.limits.perIPDaily})
+	}
+	quotas = append(quotas,
+		Quota{Kind: smsQuotaKindPhone, Key: phone, Limit: c.limits.perPhoneDaily},
+		Quota{Kind: smsQuotaKindGlobal, Key: "", Limit: c.limits.globalDaily},
+	)
+	return c.limiter.Reserve(xl, quotas...)
+}
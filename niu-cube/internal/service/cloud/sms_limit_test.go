@@ -0,0 +1,167 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/utils"
+	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+	"github.com/solutions/niu-cube/internal/service/db/dao"
+	"github.com/solutions/niu-cube/internal/service/db/dao/daotest"
+)
+
+func TestNewSmsLimits(t *testing.T) {
+	defaults := smsLimits{
+		perIPDaily:          SMSDefaultPerIPDaily,
+		perPhoneDaily:       SMSDefaultPerPhoneDaily,
+		globalDaily:         SMSDefaultGlobalDaily,
+		maxValidateFailures: SMSDefaultMaxValidateFailures,
+		lockoutTimeout:      SMSDefaultLockoutTimeout,
+	}
+	custom := defaults
+	custom.perPhoneDaily = 3
+	custom.globalDaily = -1
+	custom.lockoutTimeout = time.Minute
+	cases := []struct {
+		name string
+		conf *utils.SMSLimitConfig
+		want smsLimits
+	}{
+		{name: "nil", want: defaults},
+		{name: "zero values", conf: &utils.SMSLimitConfig{}, want: defaults},
+		{name: "override", conf: &utils.SMSLimitConfig{PerPhoneDaily: 3, GlobalDaily: -1, LockoutSecond: 60}, want: custom},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := newSmsLimits(tc.conf); got != tc.want {
+				t.Fatalf("newSmsLimits = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+// newTestSmsCodeService 创建使用测试数据库的验证码服务。
+func newTestSmsCodeService(t *testing.T, sender SmsSender, limits smsLimits) *SmsCodeService {
+	t.Helper()
+	db, _ := daotest.NewDatabase(t)
+	limiter, err := NewLimiter(db.C(dao.CollectionSMSQuota), db.C(dao.CollectionSMSValidateFailure),
+		limits.maxValidateFailures, limits.lockoutTimeout, errors2.ServerErrorSMSQuotaExceeded, errors2.ServerErrorSMSValidateLocked)
+	if err != nil {
+		t.Fatalf("NewLimiter: %v", err)
+	}
+	return &SmsCodeService{
+		smsCodeColl:     db.C(dao.CollectionSMSCode),
+		limiter:         limiter,
+		smsSender:       sender,
+		smsProvider:     "test",
+		validateTimeout: SMSCodeDefaultValidateTimeout,
+		expireTimeout:   SMSCodeExpireTimeout,
+		randSource:      rand.NewSource(time.Now().UnixNano()),
+		limits:          limits,
+		xl:              xlog.New("test-sms"),
+	}
+}
+
+func serverErrorCode(err error) int {
+	serverErr := &errors2.ServerError{}
+	if errors.As(err, &serverErr) {
+		return serverErr.Code
+	}
+	return 0
+}
+
+func TestSmsQuota(t *testing.T) {
+	sender := &switchableSmsSender{}
+	c := newTestSmsCodeService(t, sender, smsLimits{perIPDaily: 3, perPhoneDaily: 2})
+	steps := []struct {
+		name     string
+		phone    string
+		ip       string
+		fail     bool
+		wantCode int
+		wantErr  bool
+	}{
+		{name: "first", phone: "13800000001", ip: "1.1.1.1"},
+		{name: "second", phone: "13800000001", ip: "1.1.1.1"},
+		{name: "phone quota", phone: "13800000001", ip: "1.1.1.1", wantCode: errors2.ServerErrorSMSQuotaExceeded},
+		// 被手机号额度拒绝的请求不占用IP额度。
+		{name: "ip quota not consumed by rejected request", phone: "13800000002", ip: "1.1.1.1"},
+		{name: "ip quota", phone: "13800000003", ip: "1.1.1.1", wantCode: errors2.ServerErrorSMSQuotaExceeded},
+		{name: "delivery failure", phone: "13800000004", ip: "2.2.2.2", fail: true, wantErr: true},
+		{name: "failed delivery releases quota", phone: "13800000004", ip: "2.2.2.2"},
+		{name: "after release", phone: "13800000004", ip: "2.2.2.2"},
+		{name: "phone quota after release", phone: "13800000004", ip: "2.2.2.2", wantCode: errors2.ServerErrorSMSQuotaExceeded},
+	}
+	for _, step := range steps {
+		sender.fail = step.fail
+		err := c.Send(context.Background(), nil, step.phone, step.ip)
+		if step.wantCode != 0 || step.wantErr {
+			if err == nil || (step.wantCode != 0 && serverErrorCode(err) != step.wantCode) {
+				t.Fatalf("%s: Send error = %v, want code %d", step.name, err, step.wantCode)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: Send error = %v", step.name, err)
+		}
+	}
+}
+
+func TestSmsLockout(t *testing.T) {
+	sender := &switchableSmsSender{}
+	c := newTestSmsCodeService(t, sender, smsLimits{maxValidateFailures: 2, lockoutTimeout: time.Minute})
+	phone := "13800000005"
+	if err := c.Send(context.Background(), nil, phone, ""); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	steps := []struct {
+		name     string
+		code     string
+		wantCode int
+		wantErr  bool
+	}{
+		{name: "wrong code", code: "wrong", wantErr: true},
+		{name: "right code resets failures", code: sender.lastCode},
+		{name: "wrong code again", code: "wrong", wantErr: true},
+		{name: "second wrong code locks", code: "wrong", wantErr: true},
+		{name: "locked", code: sender.lastCode, wantCode: errors2.ServerErrorSMSValidateLocked},
+	}
+	for _, step := range steps {
+		err := c.Validate(nil, phone, step.code)
+		if step.wantCode != 0 || step.wantErr {
+			if err == nil || (step.wantCode != 0 && serverErrorCode(err) != step.wantCode) {
+				t.Fatalf("%s: Validate error = %v, want code %d", step.name, err, step.wantCode)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: Validate error = %v", step.name, err)
+		}
+	}
+	record := model.SMSValidateFailureDo{}
+	if err := c.limiter.failureColl.FindId(phone).One(&record); err != nil {
+		t.Fatalf("find failure record: %v", err)
+	}
+	if record.LockedUntil.Before(time.Now().Add(50 * time.Second)) {
+		t.Fatalf("locked until %v, want about one minute later", record.LockedUntil)
+	}
+}
+
+// switchableSmsSender 可切换成功与失败的短信发送器，记录最后发送的验证码。
+type switchableSmsSender struct {
+	fail     bool
+	lastCode string
+}
+
+func (s *switchableSmsSender) SendSmsCode(ctx context.Context, xl *xlog.Logger, phone string, code string) error {
+	if s.fail {
+		return errors.New("provider unavailable")
+	}
+	s.lastCode = code
+	return nil
+}
@@ -0,0 +1,190 @@
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/metrics"
+	"github.com/solutions/niu-cube/internal/common/utils"
+)
+
+const (
+	SmsProviderTest   = "test"
+	SmsProviderQiniu  = "qiniu"
+	SmsProviderHTTP   = "http"
+	SmsProviderOutbox = "outbox"
+
+	// HTTPSmsDefaultTimeout 通用HTTP短信服务默认的请求超时时间。
+	HTTPSmsDefaultTimeout = 5 * time.Second
+	// OutboxSmsDefaultPath 本地短信发件箱默认的文件路径。
+	OutboxSmsDefaultPath = "sms_outbox.jsonl"
+)
+
+// SmsSenderFactory 根据配置创建短信发送器。
+type SmsSenderFactory func(config *utils.Config) (SmsSender, error)
+
+var (
+	smsSenderFactories      = map[string]SmsSenderFactory{}
+	smsSenderFactoriesMutex sync.RWMutex
+)
+
+func init() {
+	RegisterSmsSender(SmsProviderTest, func(config *utils.Config) (SmsSender, error) {
+		// 模拟的短信发送器，仅供测试使用。
+		return &mockSmsSender{}, nil
+	})
+	RegisterSmsSender(SmsProviderQiniu, func(config *utils.Config) (SmsSender, error) {
+		if config.SMS.QiniuSMS == nil {
+			return nil, fmt.Errorf("qiniu_sms is not configured")
+		}
+		return NewQiniuSmsSender(config), nil
+	})
+	RegisterSmsSender(SmsProviderHTTP, func(config *utils.Config) (SmsSender, error) {
+		return NewHTTPSmsSender(config.SMS.HTTPSMS)
+	})
+	RegisterSmsSender(SmsProviderOutbox, func(config *utils.Config) (SmsSender, error) {
+		return NewOutboxSmsSender(config.SMS.Outbox), nil
+	})
+}
+
+// RegisterSmsSender 注册名为name的短信服务，重复注册时覆盖之前的注册。
+func RegisterSmsSender(name string, factory SmsSenderFactory) {
+	smsSenderFactoriesMutex.Lock()
+	defer smsSenderFactoriesMutex.Unlock()
+	smsSenderFactories[name] = factory
+}
+
+// NewSmsSender 创建名为name的短信服务的发送器。
+func NewSmsSender(name string, config *utils.Config) (SmsSender, error) {
+	smsSenderFactoriesMutex.RLock()
+	factory, ok := smsSenderFactories[name]
+	smsSenderFactoriesMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported SMS provider %s", name)
+	}
+	return factory(config)
+}
+
+// HTTPSmsSender 通用HTTP短信发送器，按配置的地址、请求头与请求体模板调用第三方短信接口，返回2xx视为发送成功。
+type HTTPSmsSender struct {
+	url          string
+	method       string
+	headers      map[string]string
+	bodyTemplate *template.Template
+	client       *http.Client
+}
+
+// NewHTTPSmsSender 创建通用HTTP短信发送器。
+func NewHTTPSmsSender(conf *utils.HTTPSMSConfig) (*HTTPSmsSender, error) {
+	if conf == nil || conf.URL == "" {
+		return nil, fmt.Errorf("http_sms url is not configured")
+	}
+	bodyTemplate, err := template.New("http_sms").Parse(conf.BodyTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid http_sms body_template: %v", err)
+	}
+	method := strings.ToUpper(conf.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+	timeout := HTTPSmsDefaultTimeout
+	if conf.TimeoutSecond > 0 {
+		timeout = time.Duration(conf.TimeoutSecond) * time.Second
+	}
+	return &HTTPSmsSender{
+		url:          conf.URL,
+		method:       method,
+		headers:      conf.Headers,
+		bodyTemplate: bodyTemplate,
+		client:       metrics.NewHTTPClient("sms", timeout),
+	}, nil
+}
+
+type smsTemplateParams struct {
+	Phone string
+	Code  string
+}
+
+func (s *HTTPSmsSender) SendSmsCode(ctx context.Context, xl *xlog.Logger, phone string, code string) error {
+	body := &bytes.Buffer{}
+	err := s.bodyTemplate.Execute(body, smsTemplateParams{Phone: phone, Code: code})
+	if err != nil {
+		xl.Errorf("failed to render http sms body, error %v", err)
+		return err
+	}
+	req, err := http.NewRequest(s.method, s.url, body)
+	if err != nil {
+		xl.Errorf("failed to create http sms request, error %v", err)
+		return err
+	}
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		xl.Errorf("failed to send http sms request, error %v", err)
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		xl.Errorf("http sms provider returned status %d, body %s", resp.StatusCode, string(respBody))
+		return fmt.Errorf("http sms provider returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// OutboxSmsSender 本地短信发件箱，把短信以JSON行追加到本地文件而不真正发送，供开发与测试使用。
+type OutboxSmsSender struct {
+	path  string
+	mutex sync.Mutex
+}
+
+// OutboxSmsMessage 写入发件箱的一条短信。
+type OutboxSmsMessage struct {
+	Phone    string    `json:"phone"`
+	Code     string    `json:"code"`
+	SendTime time.Time `json:"sendTime"`
+}
+
+// NewOutboxSmsSender 创建本地短信发件箱。
+func NewOutboxSmsSender(conf *utils.OutboxSMSConfig) *OutboxSmsSender {
+	path := OutboxSmsDefaultPath
+	if conf != nil && conf.Path != "" {
+		path = conf.Path
+	}
+	return &OutboxSmsSender{path: path}
+}
+
+func (s *OutboxSmsSender) SendSmsCode(ctx context.Context, xl *xlog.Logger, phone string, code string) error {
+	line, err := json.Marshal(OutboxSmsMessage{Phone: phone, Code: code, SendTime: time.Now()})
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		xl.Errorf("failed to open sms outbox %s, error %v", s.path, err)
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	if err != nil {
+		xl.Errorf("failed to write sms outbox %s, error %v", s.path, err)
+		return err
+	}
+	xl.Debugf("outbox: wrote code %s for %s to %s", code, phone, s.path)
+	return nil
+}
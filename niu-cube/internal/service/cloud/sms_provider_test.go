@@ -0,0 +1,137 @@
+package cloud
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/utils"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+)
+
+type failingSmsSender struct {
+	calls int
+}
+
+func (s *failingSmsSender) SendSmsCode(ctx context.Context, xl *xlog.Logger, phone string, code string) error {
+	s.calls++
+	return errors.New("provider unavailable")
+}
+
+// readOutbox 读取发件箱中的所有短信。
+func readOutbox(t *testing.T, path string) []OutboxSmsMessage {
+	t.Helper()
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("open outbox: %v", err)
+	}
+	defer f.Close()
+	messages := make([]OutboxSmsMessage, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		message := OutboxSmsMessage{}
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			t.Fatalf("invalid outbox line %q: %v", scanner.Text(), err)
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+func TestNewSmsSender(t *testing.T) {
+	outboxPath := filepath.Join(t.TempDir(), "outbox.jsonl")
+	RegisterSmsSender("test-custom", func(config *utils.Config) (SmsSender, error) {
+		return &failingSmsSender{}, nil
+	})
+	config := &utils.Config{SMS: &utils.SMSConfig{Outbox: &utils.OutboxSMSConfig{Path: outboxPath}}}
+	cases := []struct {
+		provider string
+		wantErr  bool
+		check    func(t *testing.T, sender SmsSender)
+	}{
+		{provider: SmsProviderTest},
+		{provider: SmsProviderOutbox, check: func(t *testing.T, sender SmsSender) {
+			if sender.(*OutboxSmsSender).path != outboxPath {
+				t.Fatalf("outbox path = %s, want %s", sender.(*OutboxSmsSender).path, outboxPath)
+			}
+		}},
+		{provider: "test-custom", check: func(t *testing.T, sender SmsSender) {
+			if _, ok := sender.(*failingSmsSender); !ok {
+				t.Fatalf("registered factory not used, got %T", sender)
+			}
+		}},
+		// 未配置对应服务时创建失败。
+		{provider: SmsProviderQiniu, wantErr: true},
+		{provider: SmsProviderHTTP, wantErr: true},
+		{provider: "unknown", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.provider, func(t *testing.T) {
+			sender, err := NewSmsSender(tc.provider, config)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("NewSmsSender error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err == nil && tc.check != nil {
+				tc.check(t, sender)
+			}
+		})
+	}
+}
+
+func TestSmsDeliverFallback(t *testing.T) {
+	cases := []struct {
+		name            string
+		primaryFails    bool
+		withFallback    bool
+		wantErr         bool
+		wantStatus      model.SMSDeliveryStatus
+		wantProvider    string
+		wantFailed      []string
+		wantOutboxCount int
+	}{
+		{name: "primary ok", withFallback: true, wantStatus: model.SMSDeliveryStatusSent, wantProvider: SmsProviderOutbox, wantOutboxCount: 1},
+		{name: "fail over", primaryFails: true, withFallback: true, wantStatus: model.SMSDeliveryStatusSent, wantProvider: SmsProviderOutbox, wantFailed: []string{"primary"}, wantOutboxCount: 1},
+		{name: "no fallback", primaryFails: true, wantErr: true, wantStatus: model.SMSDeliveryStatusFailed, wantProvider: "primary", wantFailed: []string{"primary"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			outboxPath := filepath.Join(t.TempDir(), "outbox.jsonl")
+			outbox := NewOutboxSmsSender(&utils.OutboxSMSConfig{Path: outboxPath})
+			c := &SmsCodeService{smsSender: outbox, smsProvider: SmsProviderOutbox}
+			if tc.primaryFails {
+				c.smsSender = &failingSmsSender{}
+				c.smsProvider = "primary"
+			}
+			if tc.withFallback {
+				c.fallbackSender = outbox
+				c.fallbackProvider = SmsProviderOutbox
+			}
+			record := &model.SMSCodeDo{Phone: "+8613800000000", SMSCode: "123456", Provider: c.smsProvider}
+			err := c.deliver(context.Background(), xlog.New("test-sms"), record)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("deliver error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if record.Status != tc.wantStatus || record.Provider != tc.wantProvider {
+				t.Fatalf("record status %s provider %s, want %s %s", record.Status, record.Provider, tc.wantStatus, tc.wantProvider)
+			}
+			if len(record.FailedProviders) != len(tc.wantFailed) || (len(tc.wantFailed) > 0 && record.FailedProviders[0] != tc.wantFailed[0]) {
+				t.Fatalf("failed providers = %v, want %v", record.FailedProviders, tc.wantFailed)
+			}
+			messages := readOutbox(t, outboxPath)
+			if len(messages) != tc.wantOutboxCount {
+				t.Fatalf("outbox has %d messages, want %d", len(messages), tc.wantOutboxCount)
+			}
+			if len(messages) > 0 && (messages[0].Phone != record.Phone || messages[0].Code != record.SMSCode) {
+				t.Fatalf("unexpected outbox message %+v", messages[0])
+			}
+		})
+	}
+}
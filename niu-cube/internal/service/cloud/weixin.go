@@ -8,6 +8,8 @@ import (
 	"github.com/qiniu/go-sdk/v7/auth/qbox"
 	"github.com/qiniu/go-sdk/v7/storage"
 	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/metrics"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"io"
 	"io/ioutil"
@@ -38,6 +40,7 @@ const (
 type WeixinService struct {
 	token   string
 	baseURL string
+	client  *http.Client
 	xl      *xlog.Logger
 	conf    utils.Config
 	locker  *sync.RWMutex
@@ -48,6 +51,7 @@ func NewWeixinService(conf utils.Config) *WeixinService {
 	w.locker = &sync.RWMutex{}
 	w.baseURL = "https://api.weixin.qq.com/cgi-bin"
 	w.xl = xlog.New("weixin service")
+	w.client = metrics.NewHTTPClient("weixin", 0)
 	w.conf = conf
 	w.setToken()
 	go func() {
@@ -67,10 +71,10 @@ func (w *WeixinService) setToken() {
 	values.Add("grant_type", "client_credential")
 	values.Add("appid", w.conf.Weixin.AppID)
 	values.Add("secret", w.conf.Weixin.AppSecret)
-	res, err := http.Get(w.baseURL + "/token?" + values.Encode())
+	res, err := w.client.Get(w.baseURL + "/token?" + values.Encode())
 	cnt := 0
 	for err != nil && cnt != 5 {
-		res, err = http.Get(w.baseURL + "/token?" + values.Encode())
+		res, err = w.client.Get(w.baseURL + "/token?" + values.Encode())
 		cnt++
 	}
 	w.xl.Infof("token target url %v", w.baseURL+"/token?"+values.Encode())
@@ -105,7 +109,7 @@ func (w *WeixinService) getToken() (string, error) {
 	return w.token, nil
 }
 
-func (w *WeixinService) getQRCode(path string) ([]byte, error) {
+func (w *WeixinService) getQRCode(ctx context.Context, xl *xlog.Logger, path string) ([]byte, error) {
 	payload := map[string]interface{}{
 		"path":  path,
 		"width": 250,
@@ -116,40 +120,48 @@ func (w *WeixinService) getQRCode(path string) ([]byte, error) {
 		return nil, err
 	}
 	target := w.baseURL + "/wxaapp/createwxaqrcode" + "?access_token=" + token
-	w.xl.Infof("get qrcode,url:%s", target)
-	res, err := http.Post(target, "application/json", bytes.NewReader(body))
+	xl.Infof("get qrcode,url:%s", target)
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
 	if err != nil {
-		w.xl.Errorf("fetch qrcode failed err:%v", err)
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := w.client.Do(req.WithContext(ctx))
+	if err != nil {
+		xl.Errorf("fetch qrcode failed err:%v", err)
 		return nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
-			w.xl.Errorf("close qrcode resp body failed err:%v", err)
+			xl.Errorf("close qrcode resp body failed err:%v", err)
 		}
 	}(res.Body)
 	data, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		w.xl.Errorf("read qrcode resp body failed err:%v", err)
+		xl.Errorf("read qrcode resp body failed err:%v", err)
 		return nil, err
 	}
 	match, err := regexp.Match(ErrQrCodeMsg, data)
 	if err != nil || match {
-		w.xl.Errorf("match err reg,getting image failed")
+		xl.Errorf("match err reg,getting image failed")
 		return nil, err
 	}
 	return data, err
 }
 
-func (w *WeixinService) GetAndUploadQRCode(interviewId string, interviewToken string) (string, error) {
+func (w *WeixinService) GetAndUploadQRCode(ctx context.Context, xl *xlog.Logger, interviewId string, interviewToken string) (string, error) {
+	if xl == nil {
+		xl = w.xl
+	}
 	filekey := fmt.Sprintf(QRCodeImageInterviewPattern, interviewId)
 	appletURI := QRCodeImageInterviewSchema + fmt.Sprintf("?interviewId=%s&interviewToken=%s", interviewId, interviewToken)
-	image, err := w.getQRCode(appletURI)
+	image, err := w.getQRCode(ctx, xl, appletURI)
 	if err != nil {
 		return "", err
 	}
-	w.xl.Infof("fetch qrcode of room %v successfully", interviewId)
-	err = upload(w.conf.Weixin.Bucket, w.conf.QiniuKeyPair, image, filekey, w.xl)
+	xl.Infof("fetch qrcode of room %v successfully", interviewId)
+	err = upload(ctx, w.conf.Weixin.Bucket, w.conf.QiniuKeyPair, image, filekey, xl)
 	if err != nil {
 		return "", err
 	}
@@ -157,7 +169,7 @@ func (w *WeixinService) GetAndUploadQRCode(interviewId string, interviewToken st
 	return imageURL, err
 }
 
-func (w *WeixinService) UploadFile(file *multipart.FileHeader) (string, error) {
+func (w *WeixinService) UploadFile(ctx context.Context, file *multipart.FileHeader) (string, error) {
 
 	fileContent, err := file.Open()
 	if err != nil {
@@ -170,7 +182,7 @@ func (w *WeixinService) UploadFile(file *multipart.FileHeader) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	err = upload(w.conf.Weixin.Bucket, w.conf.QiniuKeyPair, byteContainer, fileName, w.xl)
+	err = upload(ctx, w.conf.Weixin.Bucket, w.conf.QiniuKeyPair, byteContainer, fileName, w.xl)
 	if err != nil {
 		return "", err
 	}
@@ -179,7 +191,7 @@ func (w *WeixinService) UploadFile(file *multipart.FileHeader) (string, error) {
 }
 
 // fileKey 上传文件的访问名
-func upload(bucketName string, conf utils.QiniuKeyPair, data []byte, fileKey string, xl *xlog.Logger) error {
+func upload(ctx context.Context, bucketName string, conf utils.QiniuKeyPair, data []byte, fileKey string, xl *xlog.Logger) error {
 	if xl == nil {
 		xl = defaultLogger
 	}
@@ -198,7 +210,9 @@ func upload(bucketName string, conf utils.QiniuKeyPair, data []byte, fileKey str
 	formUploader := storage.NewFormUploader(&cfg)
 	ret := storage.PutRet{}
 	dataLen := int64(len(data))
-	err := formUploader.Put(context.Background(), &ret, upToken, fileKey, bytes.NewReader(data), dataLen, nil)
+	span := tracing.StartCall(ctx, "kodo", "Put")
+	err := formUploader.Put(ctx, &ret, upToken, fileKey, bytes.NewReader(data), dataLen, nil)
+	tracing.End(span, err)
 	if err != nil {
 		xl.Errorf("file uploading failed err:%v", err)
 		return err
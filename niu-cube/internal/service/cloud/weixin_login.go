@@ -0,0 +1,161 @@
+package cloud
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/metrics"
+	"github.com/solutions/niu-cube/internal/common/utils"
+	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
+)
+
+const (
+	// WeixinDefaultAPIHost 微信开放接口的默认地址。
+	WeixinDefaultAPIHost = "https://api.weixin.qq.com"
+	// weixinErrCodeInvalidCode js_code不合法。
+	weixinErrCodeInvalidCode = 40029
+	// weixinErrCodeCodeUsed js_code已被使用。
+	weixinErrCodeCodeUsed = 40163
+)
+
+// WeixinSession 小程序登录凭证校验（code2session）的结果。
+type WeixinSession struct {
+	OpenID     string `json:"openid"`
+	UnionID    string `json:"unionid"`
+	SessionKey string `json:"session_key"`
+}
+
+// WeixinAuthClient 小程序登录用到的微信接口，测试时可替换为本地实现。
+type WeixinAuthClient interface {
+	// Code2Session 使用小程序wx.login得到的js_code换取openid、unionid与session_key。
+	Code2Session(ctx context.Context, xl *xlog.Logger, jsCode string) (*WeixinSession, error)
+}
+
+// HTTPWeixinAuthClient 通过HTTP调用微信开放接口。
+type HTTPWeixinAuthClient struct {
+	appID     string
+	appSecret string
+	apiHost   string
+	client    *http.Client
+}
+
+// NewWeixinAuthClient 创建小程序登录接口的客户端，未配置api_host时使用微信官方地址。
+func NewWeixinAuthClient(conf utils.Weixin) *HTTPWeixinAuthClient {
+	apiHost := conf.APIHost
+	if apiHost == "" {
+		apiHost = WeixinDefaultAPIHost
+	}
+	return &HTTPWeixinAuthClient{
+		appID:     conf.AppID,
+		appSecret: conf.AppSecret,
+		apiHost:   apiHost,
+		client:    metrics.NewHTTPClient("weixin", 5*time.Second),
+	}
+}
+
+type code2SessionResponse struct {
+	WeixinSession
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (w *HTTPWeixinAuthClient) Code2Session(ctx context.Context, xl *xlog.Logger, jsCode string) (*WeixinSession, error) {
+	values := url.Values{}
+	values.Add("appid", w.appID)
+	values.Add("secret", w.appSecret)
+	values.Add("js_code", jsCode)
+	values.Add("grant_type", "authorization_code")
+	req, err := http.NewRequest(http.MethodGet, w.apiHost+"/sns/jscode2session?"+values.Encode(), nil)
+	if err != nil {
+		xl.Errorf("failed to create weixin code2session request, error %v", err)
+		return nil, err
+	}
+	res, err := w.client.Do(req.WithContext(ctx))
+	if err != nil {
+		xl.Errorf("failed to call weixin code2session, error %v", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		xl.Errorf("weixin code2session returned status %d", res.StatusCode)
+		return nil, fmt.Errorf("weixin code2session returned status %d", res.StatusCode)
+	}
+	resp := code2SessionResponse{}
+	err = json.NewDecoder(res.Body).Decode(&resp)
+	if err != nil {
+		xl.Errorf("failed to decode weixin code2session response, error %v", err)
+		return nil, err
+	}
+	switch resp.ErrCode {
+	case 0:
+	case weixinErrCodeInvalidCode, weixinErrCodeCodeUsed:
+		xl.Infof("weixin code2session rejected js_code, errcode %d, errmsg %s", resp.ErrCode, resp.ErrMsg)
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorWeixinCodeInvalid, Summary: resp.ErrMsg}
+	default:
+		xl.Errorf("weixin code2session failed, errcode %d, errmsg %s", resp.ErrCode, resp.ErrMsg)
+		return nil, fmt.Errorf("weixin code2session failed, errcode %d, errmsg %s", resp.ErrCode, resp.ErrMsg)
+	}
+	if resp.OpenID == "" || resp.SessionKey == "" {
+		return nil, fmt.Errorf("weixin code2session returned empty openid or session_key")
+	}
+	return &resp.WeixinSession, nil
+}
+
+// WeixinPhoneNumber 小程序getPhoneNumber返回的加密数据解密后的内容。
+type WeixinPhoneNumber struct {
+	PhoneNumber     string `json:"phoneNumber"`
+	PurePhoneNumber string `json:"purePhoneNumber"`
+	CountryCode     string `json:"countryCode"`
+	Watermark       struct {
+		AppID     string `json:"appid"`
+		Timestamp int64  `json:"timestamp"`
+	} `json:"watermark"`
+}
+
+// DecryptWeixinPhoneNumber 使用session_key解密getPhoneNumber返回的encryptedData（AES-128-CBC），并校验数据属于本小程序。
+func DecryptWeixinPhoneNumber(appID string, sessionKey string, encryptedData string, iv string) (*WeixinPhoneNumber, error) {
+	key, err := base64.StdEncoding.DecodeString(sessionKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session key: %v", err)
+	}
+	ivBytes, err := base64.StdEncoding.DecodeString(iv)
+	if err != nil {
+		return nil, fmt.Errorf("invalid iv: %v", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(encryptedData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted data: %v", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ivBytes) != block.BlockSize() || len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("invalid encrypted data or iv length")
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, ivBytes).CryptBlocks(plain, data)
+	// 去掉PKCS#7填充。
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > block.BlockSize() {
+		return nil, fmt.Errorf("invalid padding in decrypted data")
+	}
+	plain = plain[:len(plain)-padding]
+	phone := &WeixinPhoneNumber{}
+	err = json.Unmarshal(plain, phone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid decrypted data: %v", err)
+	}
+	if phone.Watermark.AppID != appID {
+		return nil, fmt.Errorf("watermark appid %s mismatch", phone.Watermark.AppID)
+	}
+	return phone, nil
+}
@@ -3,6 +3,7 @@ package dao
 import (
 	"context"
 	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/db/dao"
@@ -13,33 +14,34 @@ import (
 )
 
 type AppVersionDao interface {
-	GetNewestAppVersion(arch string) (*model.AppVersion, error)
+	GetNewestAppVersion(ctx context.Context, arch string) (*model.AppVersion, error)
 
-	InsertAppVersion(version *model.AppVersion) error
+	InsertAppVersion(ctx context.Context, version *model.AppVersion) error
 }
 
 type AppVersionDaoService struct {
 	collection *mongo.Collection
-	logger     *xlog.Logger
+	// timeout 单次操作的超时时间。
+	timeout time.Duration
+	logger  *xlog.Logger
 }
 
 func NewAppVersionDaoService(config *utils.MongoConfig) *AppVersionDaoService {
 	logger := xlog.New("app_version dao service")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(config.URI))
+	client, err := mongodb.Get(config)
 	if err != nil {
 		panic(err)
 	}
-	collection := client.Database(config.Database).Collection(dao.CollectionAppVersion)
+	collection := client.Collection(dao.CollectionAppVersion)
 	return &AppVersionDaoService{
 		collection,
+		client.OperationTimeout(),
 		logger,
 	}
 }
 
-func (a *AppVersionDaoService) GetNewestAppVersion(arch string) (*model.AppVersion, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+func (a *AppVersionDaoService) GetNewestAppVersion(ctx context.Context, arch string) (*model.AppVersion, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
 	defer cancel()
 	var result model.AppVersion
 	err := a.collection.FindOne(ctx, primitive.M{"arch": arch}, &options.FindOneOptions{
@@ -52,10 +54,10 @@ func (a *AppVersionDaoService) GetNewestAppVersion(arch string) (*model.AppVersi
 	return &result, nil
 }
 
-func (a *AppVersionDaoService) InsertAppVersion(version *model.AppVersion) error {
+func (a *AppVersionDaoService) InsertAppVersion(ctx context.Context, version *model.AppVersion) error {
 	version.Id = primitive.NewObjectID().Hex()
 	version.CreatedTime = time.Now()
-	timeout, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	timeout, cancel := context.WithTimeout(ctx, a.timeout)
 	defer cancel()
 	_, err := a.collection.InsertOne(timeout, version)
 	if err != nil {
@@ -1,12 +1,15 @@
 package dao
 
 import (
+	"context"
 	"time"
 
 	"github.com/qiniu/x/xlog"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/db/dao"
@@ -14,19 +17,19 @@ import (
 
 // BaseMicDaoInterface 麦位相关数据库方法
 type BaseMicDaoInterface interface {
-	InsertBaseMic(xl *xlog.Logger, baseMic *model.BaseMicDo) (*model.BaseMicDo, error)
+	InsertBaseMic(ctx context.Context, xl *xlog.Logger, baseMic *model.BaseMicDo) (*model.BaseMicDo, error)
 
-	Delete(xl *xlog.Logger, micId string) error
+	Delete(ctx context.Context, xl *xlog.Logger, micId string) error
 
-	Update(xl *xlog.Logger, baseMic *model.BaseMicDo) error
+	Update(ctx context.Context, xl *xlog.Logger, baseMic *model.BaseMicDo) error
 
-	Select(xl *xlog.Logger, micId string) (*model.BaseMicDo, error)
+	Select(ctx context.Context, xl *xlog.Logger, micId string) (*model.BaseMicDo, error)
 }
 
 // BaseMicDaoService 键在这里生成，不需要传参制定
 type BaseMicDaoService struct {
-	client      *mgo.Session
-	baseMicColl *mgo.Collection
+	client      *mongodb.Manager
+	baseMicColl *mongodb.Collection
 	xl          *xlog.Logger
 }
 
@@ -34,12 +37,12 @@ func NewBaseMicDaoService(xl *xlog.Logger, config *utils.MongoConfig) (*BaseMicD
 	if xl == nil {
 		xl = xlog.New("niu-cube-base-mic")
 	}
-	client, err := mgo.Dial(config.URI)
+	client, err := mongodb.Get(config)
 	if err != nil {
 		xl.Errorf("failed to create mongo client, error: %v", err)
 		return nil, err
 	}
-	baseMicColl := client.DB(config.Database).C(dao.CollectionBaseMic)
+	baseMicColl := client.C(dao.CollectionBaseMic)
 	return &BaseMicDaoService{
 		client,
 		baseMicColl,
@@ -47,14 +50,15 @@ func NewBaseMicDaoService(xl *xlog.Logger, config *utils.MongoConfig) (*BaseMicD
 	}, nil
 }
 
-func (b *BaseMicDaoService) InsertBaseMic(xl *xlog.Logger, baseMic *model.BaseMicDo) (*model.BaseMicDo, error) {
+func (b *BaseMicDaoService) InsertBaseMic(ctx context.Context, xl *xlog.Logger, baseMic *model.BaseMicDo) (*model.BaseMicDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseMicDaoService.InsertBaseMic").End()
 	baseMic.CreatedTime = time.Now()
 	baseMic.UpdatedTime = time.Now()
 	baseMic.Id = bson.NewObjectId().Hex()
-	err := b.baseMicColl.Insert(baseMic)
+	err := b.baseMicColl.WithContext(ctx).Insert(baseMic)
 	if err != nil {
 		xl.Error("insert into base_mic failed.")
 		return nil, err
@@ -62,11 +66,12 @@ func (b *BaseMicDaoService) InsertBaseMic(xl *xlog.Logger, baseMic *model.BaseMi
 	return baseMic, nil
 }
 
-func (b *BaseMicDaoService) Delete(xl *xlog.Logger, micId string) error {
+func (b *BaseMicDaoService) Delete(ctx context.Context, xl *xlog.Logger, micId string) error {
 	if xl == nil {
 		xl = b.xl
 	}
-	err := b.baseMicColl.RemoveId(micId)
+	defer tracing.StartDAO(ctx, "BaseMicDaoService.Delete").End()
+	err := b.baseMicColl.WithContext(ctx).RemoveId(micId)
 	if err != nil {
 		xl.Error("delete from base_mic failed.")
 		return err
@@ -74,12 +79,13 @@ func (b *BaseMicDaoService) Delete(xl *xlog.Logger, micId string) error {
 	return nil
 }
 
-func (b *BaseMicDaoService) Update(xl *xlog.Logger, baseMic *model.BaseMicDo) error {
+func (b *BaseMicDaoService) Update(ctx context.Context, xl *xlog.Logger, baseMic *model.BaseMicDo) error {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseMicDaoService.Update").End()
 	baseMic.UpdatedTime = time.Now()
-	err := b.baseMicColl.UpdateId(baseMic.Id, baseMic)
+	err := b.baseMicColl.WithContext(ctx).UpdateId(baseMic.Id, baseMic)
 	if err != nil {
 		xl.Error("update base_mic failed.")
 		return err
@@ -87,12 +93,13 @@ func (b *BaseMicDaoService) Update(xl *xlog.Logger, baseMic *model.BaseMicDo) er
 	return nil
 }
 
-func (b *BaseMicDaoService) Select(xl *xlog.Logger, micId string) (*model.BaseMicDo, error) {
+func (b *BaseMicDaoService) Select(ctx context.Context, xl *xlog.Logger, micId string) (*model.BaseMicDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseMicDaoService.Select").End()
 	var mic model.BaseMicDo
-	err := b.baseMicColl.FindId(micId).One(&mic)
+	err := b.baseMicColl.WithContext(ctx).FindId(micId).One(&mic)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Infof("can't find this records:[%s] from base_mic.", micId)
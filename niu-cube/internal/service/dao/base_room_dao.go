@@ -1,12 +1,15 @@
 package dao
 
 import (
+	"context"
 	"time"
 
 	"github.com/qiniu/x/xlog"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/db/dao"
@@ -14,28 +17,28 @@ import (
 
 // BaseRoomDaoInterface 通用房间数据库相关操作
 type BaseRoomDaoInterface interface {
-	Insert(xl *xlog.Logger, baseRoomDo *model.BaseRoomDo) (*model.BaseRoomDo, error)
+	Insert(ctx context.Context, xl *xlog.Logger, baseRoomDo *model.BaseRoomDo) (*model.BaseRoomDo, error)
 
-	Delete(xl *xlog.Logger, roomId string) error
+	Delete(ctx context.Context, xl *xlog.Logger, roomId string) error
 
-	Update(xl *xlog.Logger, baseRoomDo *model.BaseRoomDo) error
+	Update(ctx context.Context, xl *xlog.Logger, baseRoomDo *model.BaseRoomDo) error
 
-	Select(xl *xlog.Logger, roomId string) (*model.BaseRoomDo, error)
+	Select(ctx context.Context, xl *xlog.Logger, roomId string) (*model.BaseRoomDo, error)
 
-	SelectByInvitationCode(xl *xlog.Logger, invitationCode string) (*model.BaseRoomDo, error)
+	SelectByInvitationCode(ctx context.Context, xl *xlog.Logger, invitationCode string) (*model.BaseRoomDo, error)
 
-	ListByRoomType(xl *xlog.Logger, roomType string, pageNum, pageSize int) ([]model.BaseRoomDo, int, int, error)
+	ListByRoomType(ctx context.Context, xl *xlog.Logger, roomType string, page model.PageQuery) ([]model.BaseRoomDo, model.PageResult, error)
 
-	ListByTimeout(xl *xlog.Logger, threshold time.Time) ([]model.BaseRoomDo, error)
+	ListByTimeout(ctx context.Context, xl *xlog.Logger, threshold time.Time) ([]model.BaseRoomDo, error)
 
 	// ListAllForce 测试用
-	ListAllForce(xl *xlog.Logger) ([]model.BaseRoomDo, error)
+	ListAllForce(ctx context.Context, xl *xlog.Logger) ([]model.BaseRoomDo, error)
 }
 
 // BaseRoomDaoService 键在这里生成，不需要传参指定
 type BaseRoomDaoService struct {
-	client       *mgo.Session
-	baseRoomColl *mgo.Collection
+	client       *mongodb.Manager
+	baseRoomColl *mongodb.Collection
 	xl           *xlog.Logger
 }
 
@@ -43,12 +46,12 @@ func NewBaseRoomDaoService(xl *xlog.Logger, conf *utils.MongoConfig) (*BaseRoomD
 	if xl == nil {
 		xl = xlog.New("niu-cube-base-room")
 	}
-	client, err := mgo.Dial(conf.URI)
+	client, err := mongodb.Get(conf)
 	if err != nil {
 		xl.Errorf("failed to create mongo client, error: %v", err)
 		return nil, err
 	}
-	baseRoomColl := client.DB(conf.Database).C(dao.CollectionBaseRoom)
+	baseRoomColl := client.C(dao.CollectionBaseRoom)
 	return &BaseRoomDaoService{
 		client,
 		baseRoomColl,
@@ -56,14 +59,15 @@ func NewBaseRoomDaoService(xl *xlog.Logger, conf *utils.MongoConfig) (*BaseRoomD
 	}, nil
 }
 
-func (b *BaseRoomDaoService) Insert(xl *xlog.Logger, baseRoomDo *model.BaseRoomDo) (*model.BaseRoomDo, error) {
+func (b *BaseRoomDaoService) Insert(ctx context.Context, xl *xlog.Logger, baseRoomDo *model.BaseRoomDo) (*model.BaseRoomDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseRoomDaoService.Insert").End()
 	baseRoomDo.Id = bson.NewObjectId().Hex()
 	baseRoomDo.CreatedTime = time.Now()
 	baseRoomDo.UpdatedTime = time.Now()
-	err := b.baseRoomColl.Insert(baseRoomDo)
+	err := b.baseRoomColl.WithContext(ctx).Insert(baseRoomDo)
 	if err != nil {
 		xl.Error("insert base_room failed.")
 		return nil, err
@@ -71,11 +75,12 @@ func (b *BaseRoomDaoService) Insert(xl *xlog.Logger, baseRoomDo *model.BaseRoomD
 	return baseRoomDo, nil
 }
 
-func (b *BaseRoomDaoService) Delete(xl *xlog.Logger, roomId string) error {
+func (b *BaseRoomDaoService) Delete(ctx context.Context, xl *xlog.Logger, roomId string) error {
 	if xl == nil {
 		xl = b.xl
 	}
-	err := b.baseRoomColl.RemoveId(roomId)
+	defer tracing.StartDAO(ctx, "BaseRoomDaoService.Delete").End()
+	err := b.baseRoomColl.WithContext(ctx).RemoveId(roomId)
 	if err != nil {
 		xl.Error("delete base_room failed.")
 		return err
@@ -83,12 +88,13 @@ func (b *BaseRoomDaoService) Delete(xl *xlog.Logger, roomId string) error {
 	return nil
 }
 
-func (b *BaseRoomDaoService) Update(xl *xlog.Logger, baseRoomDo *model.BaseRoomDo) error {
+func (b *BaseRoomDaoService) Update(ctx context.Context, xl *xlog.Logger, baseRoomDo *model.BaseRoomDo) error {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseRoomDaoService.Update").End()
 	baseRoomDo.UpdatedTime = time.Now()
-	err := b.baseRoomColl.Update(bson.M{"_id": baseRoomDo.Id}, baseRoomDo)
+	err := b.baseRoomColl.WithContext(ctx).Update(bson.M{"_id": baseRoomDo.Id}, baseRoomDo)
 	if err != nil {
 		xl.Error("update base_room failed.")
 		return err
@@ -96,12 +102,13 @@ func (b *BaseRoomDaoService) Update(xl *xlog.Logger, baseRoomDo *model.BaseRoomD
 	return nil
 }
 
-func (b *BaseRoomDaoService) Select(xl *xlog.Logger, roomId string) (*model.BaseRoomDo, error) {
+func (b *BaseRoomDaoService) Select(ctx context.Context, xl *xlog.Logger, roomId string) (*model.BaseRoomDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseRoomDaoService.Select").End()
 	var room model.BaseRoomDo
-	err := b.baseRoomColl.Find(bson.M{"_id": roomId, "status": model.BaseRoomCreated}).One(&room)
+	err := b.baseRoomColl.WithContext(ctx).Find(bson.M{"_id": roomId, "status": model.BaseRoomCreated}).One(&room)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Infof("can't find this record:[%s] from base_room.", roomId)
@@ -113,12 +120,13 @@ func (b *BaseRoomDaoService) Select(xl *xlog.Logger, roomId string) (*model.Base
 	return &room, nil
 }
 
-func (b *BaseRoomDaoService) SelectByInvitationCode(xl *xlog.Logger, invitationCode string) (*model.BaseRoomDo, error) {
+func (b *BaseRoomDaoService) SelectByInvitationCode(ctx context.Context, xl *xlog.Logger, invitationCode string) (*model.BaseRoomDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseRoomDaoService.SelectByInvitationCode").End()
 	result := model.BaseRoomDo{}
-	err := b.baseRoomColl.Find(bson.M{"status": model.BaseRoomCreated, "invitation_code": invitationCode}).One((&result))
+	err := b.baseRoomColl.WithContext(ctx).Find(bson.M{"status": model.BaseRoomCreated, "invitation_code": invitationCode}).One((&result))
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Infof("can't find this record:[%s] from base_room.", invitationCode)
@@ -130,32 +138,46 @@ func (b *BaseRoomDaoService) SelectByInvitationCode(xl *xlog.Logger, invitationC
 	return &result, nil
 }
 
-func (b *BaseRoomDaoService) ListByRoomType(xl *xlog.Logger, roomType string, pageNum, pageSize int) ([]model.BaseRoomDo, int, int, error) {
+// ListByRoomType 按创建时间倒序分页列出房间，page.Cursor不为空时从游标之后读取，不统计总数。
+func (b *BaseRoomDaoService) ListByRoomType(ctx context.Context, xl *xlog.Logger, roomType string, page model.PageQuery) ([]model.BaseRoomDo, model.PageResult, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseRoomDaoService.ListByRoomType").End()
 	var baseRoomDos []model.BaseRoomDo
-	skip := (pageNum - 1) * pageSize
-	limit := pageSize
-	err := b.baseRoomColl.Find(bson.M{"status": model.BaseRoomCreated, "type": roomType}).Sort("-created_time").Skip(skip).Limit(limit).All(&baseRoomDos)
+	filter := bson.M{"status": model.BaseRoomCreated, "type": roomType}
+	query := filter
+	if page.Cursor != nil {
+		query = bson.M{"$and": []interface{}{filter, model.PageCursorFilter(
+			model.PageSortField{Name: "created_time", Desc: true, Value: page.Cursor.Time},
+			model.PageSortField{Name: "_id", Desc: true, Value: page.Cursor.ID},
+		)}}
+	}
+	err := b.baseRoomColl.WithContext(ctx).Find(query).Sort("-created_time", "-_id").Skip(page.Skip()).Limit(page.PageSize + 1).All(&baseRoomDos)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Infof("can't list those records:[%s] from base_room.", roomType)
 		} else {
 			xl.Errorf("list by %s from base_room failed.", roomType)
 		}
-		return nil, 0, 0, err
+		return nil, model.PageResult{}, err
 	}
-	total, _ := b.baseRoomColl.Find(bson.M{"status": model.BaseRoomCreated, "type": roomType}).Count()
-	return baseRoomDos, total, len(baseRoomDos), nil
+	res := model.NewPageResult(page, len(baseRoomDos), func(i int) *model.PageCursor {
+		return &model.PageCursor{Time: baseRoomDos[i].CreatedTime, ID: baseRoomDos[i].Id}
+	})
+	if page.Cursor == nil {
+		res.Total, _ = b.baseRoomColl.WithContext(ctx).Find(filter).Count()
+	}
+	return baseRoomDos[:res.Count], res, nil
 }
 
-func (b *BaseRoomDaoService) ListByTimeout(xl *xlog.Logger, threshold time.Time) ([]model.BaseRoomDo, error) {
+func (b *BaseRoomDaoService) ListByTimeout(ctx context.Context, xl *xlog.Logger, threshold time.Time) ([]model.BaseRoomDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseRoomDaoService.ListByTimeout").End()
 	var rooms []model.BaseRoomDo
-	err := b.baseRoomColl.Find(bson.M{"status": model.BaseRoomCreated, "updated_time": bson.M{"$lt": threshold}}).All(&rooms)
+	err := b.baseRoomColl.WithContext(ctx).Find(bson.M{"status": model.BaseRoomCreated, "updated_time": bson.M{"$lt": threshold}}).All(&rooms)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Infof("can't list those records:[%s] from base_room", threshold)
@@ -167,11 +189,12 @@ func (b *BaseRoomDaoService) ListByTimeout(xl *xlog.Logger, threshold time.Time)
 	return rooms, nil
 }
 
-func (b *BaseRoomDaoService) ListAllForce(xl *xlog.Logger) ([]model.BaseRoomDo, error) {
+func (b *BaseRoomDaoService) ListAllForce(ctx context.Context, xl *xlog.Logger) ([]model.BaseRoomDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseRoomDaoService.ListAllForce").End()
 	result := make([]model.BaseRoomDo, 0, 1)
-	_ = b.baseRoomColl.Find(nil).All(&result)
+	_ = b.baseRoomColl.WithContext(ctx).Find(nil).All(&result)
 	return result, nil
 }
@@ -1,35 +1,38 @@
 package dao
 
 import (
+	"context"
 	"time"
 
 	"github.com/qiniu/x/xlog"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/db/dao"
 )
 
 type BaseRoomMicDaoInterface interface {
-	Insert(xl *xlog.Logger, baseRoomMicDo *model.BaseRoomMicDo) (*model.BaseRoomMicDo, error)
+	Insert(ctx context.Context, xl *xlog.Logger, baseRoomMicDo *model.BaseRoomMicDo) (*model.BaseRoomMicDo, error)
 
 	// DeleteByRoomIdMicId 做假删除，尽量不要调用这个方法
-	DeleteByRoomIdMicId(xl *xlog.Logger, roomId, micId string) error
+	DeleteByRoomIdMicId(ctx context.Context, xl *xlog.Logger, roomId, micId string) error
 
 	// Update 通过更新数据表来实现删除
-	Update(xl *xlog.Logger, baseRoomMic *model.BaseRoomMicDo) error
+	Update(ctx context.Context, xl *xlog.Logger, baseRoomMic *model.BaseRoomMicDo) error
 
 	// Select 返回还在麦位的用户
-	Select(xl *xlog.Logger, roomId, micId string) (*model.BaseRoomMicDo, error)
+	Select(ctx context.Context, xl *xlog.Logger, roomId, micId string) (*model.BaseRoomMicDo, error)
 
-	ListByRoomId(xl *xlog.Logger, roomId string) ([]model.BaseRoomMicDo, error)
+	ListByRoomId(ctx context.Context, xl *xlog.Logger, roomId string) ([]model.BaseRoomMicDo, error)
 }
 
 type BaseRoomMicDaoService struct {
-	client          *mgo.Session
-	baseRoomMicColl *mgo.Collection
+	client          *mongodb.Manager
+	baseRoomMicColl *mongodb.Collection
 	xl              *xlog.Logger
 }
 
@@ -37,12 +40,12 @@ func NewBaseRoomMicDaoService(xl *xlog.Logger, config *utils.MongoConfig) (*Base
 	if xl == nil {
 		xl = xlog.New("niu-cube-base-room-mic")
 	}
-	client, err := mgo.Dial(config.URI)
+	client, err := mongodb.Get(config)
 	if err != nil {
 		xl.Errorf("failed to create mongo client, error: %v", err)
 		return nil, err
 	}
-	baseRoomMicColl := client.DB(config.Database).C(dao.CollectionBaseRoomMic)
+	baseRoomMicColl := client.C(dao.CollectionBaseRoomMic)
 	return &BaseRoomMicDaoService{
 		client,
 		baseRoomMicColl,
@@ -50,14 +53,15 @@ func NewBaseRoomMicDaoService(xl *xlog.Logger, config *utils.MongoConfig) (*Base
 	}, nil
 }
 
-func (b *BaseRoomMicDaoService) Insert(xl *xlog.Logger, baseRoomMicDo *model.BaseRoomMicDo) (*model.BaseRoomMicDo, error) {
+func (b *BaseRoomMicDaoService) Insert(ctx context.Context, xl *xlog.Logger, baseRoomMicDo *model.BaseRoomMicDo) (*model.BaseRoomMicDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseRoomMicDaoService.Insert").End()
 	baseRoomMicDo.Id = bson.NewObjectId().Hex()
 	baseRoomMicDo.CreatedTime = time.Now()
 	baseRoomMicDo.UpdatedTime = time.Now()
-	err := b.baseRoomMicColl.Insert(baseRoomMicDo)
+	err := b.baseRoomMicColl.WithContext(ctx).Insert(baseRoomMicDo)
 	if err != nil {
 		xl.Error("insert into base_room_mic failed.")
 		return nil, err
@@ -65,11 +69,12 @@ func (b *BaseRoomMicDaoService) Insert(xl *xlog.Logger, baseRoomMicDo *model.Bas
 	return baseRoomMicDo, nil
 }
 
-func (b *BaseRoomMicDaoService) DeleteByRoomIdMicId(xl *xlog.Logger, roomId, micId string) error {
+func (b *BaseRoomMicDaoService) DeleteByRoomIdMicId(ctx context.Context, xl *xlog.Logger, roomId, micId string) error {
 	if xl == nil {
 		xl = b.xl
 	}
-	err := b.baseRoomMicColl.Remove(bson.M{"room_id": roomId, "mic_id": micId})
+	defer tracing.StartDAO(ctx, "BaseRoomMicDaoService.DeleteByRoomIdMicId").End()
+	err := b.baseRoomMicColl.WithContext(ctx).Remove(bson.M{"room_id": roomId, "mic_id": micId})
 	if err != nil {
 		xl.Error("delete from base_room_mic by roomId:[%s] micId:[%s] failed.", roomId, micId)
 		return err
@@ -77,12 +82,13 @@ func (b *BaseRoomMicDaoService) DeleteByRoomIdMicId(xl *xlog.Logger, roomId, mic
 	return nil
 }
 
-func (b *BaseRoomMicDaoService) Update(xl *xlog.Logger, baseRoomMic *model.BaseRoomMicDo) error {
+func (b *BaseRoomMicDaoService) Update(ctx context.Context, xl *xlog.Logger, baseRoomMic *model.BaseRoomMicDo) error {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseRoomMicDaoService.Update").End()
 	baseRoomMic.UpdatedTime = time.Now()
-	err := b.baseRoomMicColl.UpdateId(baseRoomMic.Id, baseRoomMic)
+	err := b.baseRoomMicColl.WithContext(ctx).UpdateId(baseRoomMic.Id, baseRoomMic)
 	if err != nil {
 		xl.Error("update base_room_mic failed.")
 		return err
@@ -90,12 +96,13 @@ func (b *BaseRoomMicDaoService) Update(xl *xlog.Logger, baseRoomMic *model.BaseR
 	return nil
 }
 
-func (b *BaseRoomMicDaoService) Select(xl *xlog.Logger, roomId, micId string) (*model.BaseRoomMicDo, error) {
+func (b *BaseRoomMicDaoService) Select(ctx context.Context, xl *xlog.Logger, roomId, micId string) (*model.BaseRoomMicDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseRoomMicDaoService.Select").End()
 	var roomMic model.BaseRoomMicDo
-	err := b.baseRoomMicColl.Find(bson.M{"room_id": roomId, "mic_id": micId, "status": model.BaseRoomMicUsed}).One(&roomMic)
+	err := b.baseRoomMicColl.WithContext(ctx).Find(bson.M{"room_id": roomId, "mic_id": micId, "status": model.BaseRoomMicUsed}).One(&roomMic)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Infof("can't find this record from base_room.")
@@ -107,12 +114,13 @@ func (b *BaseRoomMicDaoService) Select(xl *xlog.Logger, roomId, micId string) (*
 	return &roomMic, nil
 }
 
-func (b *BaseRoomMicDaoService) ListByRoomId(xl *xlog.Logger, roomId string) ([]model.BaseRoomMicDo, error) {
+func (b *BaseRoomMicDaoService) ListByRoomId(ctx context.Context, xl *xlog.Logger, roomId string) ([]model.BaseRoomMicDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseRoomMicDaoService.ListByRoomId").End()
 	var roomMics []model.BaseRoomMicDo
-	err := b.baseRoomMicColl.Find(bson.M{"room_id": roomId}).All(&roomMics)
+	err := b.baseRoomMicColl.WithContext(ctx).Find(bson.M{"room_id": roomId}).All(&roomMics)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't list those records from base_room_mic.")
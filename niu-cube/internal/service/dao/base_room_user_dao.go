@@ -1,37 +1,40 @@
 package dao
 
 import (
+	"context"
 	"time"
 
 	"github.com/qiniu/x/xlog"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/db/dao"
 )
 
 type BaseRoomUserDaoInterface interface {
-	Insert(xl *xlog.Logger, baseRoomUserDo *model.BaseRoomUserDo) (*model.BaseRoomUserDo, error)
+	Insert(ctx context.Context, xl *xlog.Logger, baseRoomUserDo *model.BaseRoomUserDo) (*model.BaseRoomUserDo, error)
 
 	// SelectByRoomIdUserId 只会返回尚且在房间的用户，已经离线的不算
-	SelectByRoomIdUserId(xl *xlog.Logger, roomId, userId string) (*model.BaseRoomUserDo, error)
+	SelectByRoomIdUserId(ctx context.Context, xl *xlog.Logger, roomId, userId string) (*model.BaseRoomUserDo, error)
 
-	Update(xl *xlog.Logger, baseRoomUserDo *model.BaseRoomUserDo) error
+	Update(ctx context.Context, xl *xlog.Logger, baseRoomUserDo *model.BaseRoomUserDo) error
 
 	// ListByRoomId 依旧只会返回还在房间的用户
-	ListByRoomId(xl *xlog.Logger, roomId string) ([]model.BaseRoomUserDo, error)
+	ListByRoomId(ctx context.Context, xl *xlog.Logger, roomId string) ([]model.BaseRoomUserDo, error)
 
-	ListByHeartbeatTimeout(xl *xlog.Logger, thresholdTimeout time.Time) ([]model.BaseRoomUserDo, error)
+	ListByHeartbeatTimeout(ctx context.Context, xl *xlog.Logger, thresholdTimeout time.Time) ([]model.BaseRoomUserDo, error)
 
 	// DeleteByRoomIdUserId 最好不要调用
-	DeleteByRoomIdUserId(xl *xlog.Logger, roomId, userId string) error
+	DeleteByRoomIdUserId(ctx context.Context, xl *xlog.Logger, roomId, userId string) error
 }
 
 type BaseRoomUserDaoService struct {
-	client           *mgo.Session
-	baseRoomUserColl *mgo.Collection
+	client           *mongodb.Manager
+	baseRoomUserColl *mongodb.Collection
 	xl               *xlog.Logger
 }
 
@@ -39,12 +42,12 @@ func NewBaseRoomUserDaoService(xl *xlog.Logger, config *utils.MongoConfig) (*Bas
 	if xl == nil {
 		xl = xlog.New("niu-cube-base-room-user")
 	}
-	client, err := mgo.Dial(config.URI)
+	client, err := mongodb.Get(config)
 	if err != nil {
 		xl.Errorf("failed to create mongo client, error: %v", err)
 		return nil, err
 	}
-	baseRoomUserColl := client.DB(config.Database).C(dao.CollectionBaseRoomUser)
+	baseRoomUserColl := client.C(dao.CollectionBaseRoomUser)
 	return &BaseRoomUserDaoService{
 		client,
 		baseRoomUserColl,
@@ -52,7 +55,7 @@ func NewBaseRoomUserDaoService(xl *xlog.Logger, config *utils.MongoConfig) (*Bas
 	}, nil
 }
 
-func (b *BaseRoomUserDaoService) Insert(xl *xlog.Logger,
+func (b *BaseRoomUserDaoService) Insert(ctx context.Context, xl *xlog.Logger,
 	baseRoomUserDo *model.BaseRoomUserDo) (*model.BaseRoomUserDo, error) {
 	if xl == nil {
 		xl = b.xl
@@ -60,7 +63,7 @@ func (b *BaseRoomUserDaoService) Insert(xl *xlog.Logger,
 	baseRoomUserDo.Id = bson.NewObjectId().Hex()
 	baseRoomUserDo.CreatedTime = time.Now()
 	baseRoomUserDo.UpdatedTime = time.Now()
-	err := b.baseRoomUserColl.Insert(baseRoomUserDo)
+	err := b.baseRoomUserColl.WithContext(ctx).Insert(baseRoomUserDo)
 	if err != nil {
 		xl.Error("insert into base_room_user failed.")
 		return nil, err
@@ -68,12 +71,13 @@ func (b *BaseRoomUserDaoService) Insert(xl *xlog.Logger,
 	return baseRoomUserDo, nil
 }
 
-func (b *BaseRoomUserDaoService) SelectByRoomIdUserId(xl *xlog.Logger, roomId, userId string) (*model.BaseRoomUserDo, error) {
+func (b *BaseRoomUserDaoService) SelectByRoomIdUserId(ctx context.Context, xl *xlog.Logger, roomId, userId string) (*model.BaseRoomUserDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseRoomUserDaoService.SelectByRoomIdUserId").End()
 	var roomUser model.BaseRoomUserDo
-	err := b.baseRoomUserColl.Find(bson.M{"room_id": roomId, "user_id": userId, "status": model.BaseRoomUserJoin}).One(&roomUser)
+	err := b.baseRoomUserColl.WithContext(ctx).Find(bson.M{"room_id": roomId, "user_id": userId, "status": model.BaseRoomUserJoin}).One(&roomUser)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Infof("can't find this record from base_room_user by roomId:[%s] userId:[%s].", roomId, userId)
@@ -85,12 +89,13 @@ func (b *BaseRoomUserDaoService) SelectByRoomIdUserId(xl *xlog.Logger, roomId, u
 	return &roomUser, nil
 }
 
-func (b *BaseRoomUserDaoService) Update(xl *xlog.Logger, baseRoomUserDo *model.BaseRoomUserDo) error {
+func (b *BaseRoomUserDaoService) Update(ctx context.Context, xl *xlog.Logger, baseRoomUserDo *model.BaseRoomUserDo) error {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseRoomUserDaoService.Update").End()
 	baseRoomUserDo.UpdatedTime = time.Now()
-	err := b.baseRoomUserColl.UpdateId(baseRoomUserDo.Id, baseRoomUserDo)
+	err := b.baseRoomUserColl.WithContext(ctx).UpdateId(baseRoomUserDo.Id, baseRoomUserDo)
 	if err != nil {
 		xl.Error("update base_room_user failed.")
 		return err
@@ -98,12 +103,13 @@ func (b *BaseRoomUserDaoService) Update(xl *xlog.Logger, baseRoomUserDo *model.B
 	return nil
 }
 
-func (b *BaseRoomUserDaoService) ListByRoomId(xl *xlog.Logger, roomId string) ([]model.BaseRoomUserDo, error) {
+func (b *BaseRoomUserDaoService) ListByRoomId(ctx context.Context, xl *xlog.Logger, roomId string) ([]model.BaseRoomUserDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseRoomUserDaoService.ListByRoomId").End()
 	roomUserDos := make([]model.BaseRoomUserDo, 0, 1)
-	err := b.baseRoomUserColl.Find(bson.M{"room_id": roomId, "status": model.BaseRoomUserJoin}).Sort("-updated_time").All(&roomUserDos)
+	err := b.baseRoomUserColl.WithContext(ctx).Find(bson.M{"room_id": roomId, "status": model.BaseRoomUserJoin}).Sort("-updated_time").All(&roomUserDos)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Infof("can't list those records:[%s] base_room_user.", roomId)
@@ -115,12 +121,13 @@ func (b *BaseRoomUserDaoService) ListByRoomId(xl *xlog.Logger, roomId string) ([
 	return roomUserDos, nil
 }
 
-func (b *BaseRoomUserDaoService) ListByHeartbeatTimeout(xl *xlog.Logger, thresholdTimeout time.Time) ([]model.BaseRoomUserDo, error) {
+func (b *BaseRoomUserDaoService) ListByHeartbeatTimeout(ctx context.Context, xl *xlog.Logger, thresholdTimeout time.Time) ([]model.BaseRoomUserDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseRoomUserDaoService.ListByHeartbeatTimeout").End()
 	roomUserDos := make([]model.BaseRoomUserDo, 0, 1)
-	err := b.baseRoomUserColl.Find(bson.M{"last_heartbeat_time": bson.M{"$lt": thresholdTimeout}, "status": model.BaseRoomUserJoin}).All(&roomUserDos)
+	err := b.baseRoomUserColl.WithContext(ctx).Find(bson.M{"last_heartbeat_time": bson.M{"$lt": thresholdTimeout}, "status": model.BaseRoomUserJoin}).All(&roomUserDos)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't list those records:[%s] base_room_user.", thresholdTimeout)
@@ -132,11 +139,12 @@ func (b *BaseRoomUserDaoService) ListByHeartbeatTimeout(xl *xlog.Logger, thresho
 	return roomUserDos, nil
 }
 
-func (b *BaseRoomUserDaoService) DeleteByRoomIdUserId(xl *xlog.Logger, roomId, userId string) error {
+func (b *BaseRoomUserDaoService) DeleteByRoomIdUserId(ctx context.Context, xl *xlog.Logger, roomId, userId string) error {
 	if xl == nil {
 		xl = b.xl
 	}
-	err := b.baseRoomUserColl.Remove(bson.M{"room_id": roomId, "user_id": userId})
+	defer tracing.StartDAO(ctx, "BaseRoomUserDaoService.DeleteByRoomIdUserId").End()
+	err := b.baseRoomUserColl.WithContext(ctx).Remove(bson.M{"room_id": roomId, "user_id": userId})
 	if err != nil {
 		xl.Error("delete from base_room_user failed.")
 		return err
@@ -1,12 +1,15 @@
 package dao
 
 import (
+	"context"
 	"time"
 
 	"github.com/qiniu/x/xlog"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/db"
@@ -15,21 +18,21 @@ import (
 
 // BaseUserDaoInterface 通用用户相关数据库操作
 type BaseUserDaoInterface interface {
-	Insert(xl *xlog.Logger, baseUserDo *model.BaseUserDo) (*model.BaseUserDo, error)
+	Insert(ctx context.Context, xl *xlog.Logger, baseUserDo *model.BaseUserDo) (*model.BaseUserDo, error)
 
-	Delete(xl *xlog.Logger, userId string) error
+	Delete(ctx context.Context, xl *xlog.Logger, userId string) error
 
-	Update(xl *xlog.Logger, baseUserDo *model.BaseUserDo) error
+	Update(ctx context.Context, xl *xlog.Logger, baseUserDo *model.BaseUserDo) error
 
-	Select(xl *xlog.Logger, userId string) (*model.BaseUserDo, error)
+	Select(ctx context.Context, xl *xlog.Logger, userId string) (*model.BaseUserDo, error)
 
-	ListAll() ([]model.BaseUserDo, error)
+	ListAll(ctx context.Context) ([]model.BaseUserDo, error)
 }
 
 // BaseUserDaoService 主键在这里生成，不需要传参制定
 type BaseUserDaoService struct {
-	client         *mgo.Session
-	baseUserColl   *mgo.Collection
+	client         *mongodb.Manager
+	baseUserColl   *mongodb.Collection
 	accountService *db.AccountService
 	xl             *xlog.Logger
 }
@@ -38,12 +41,12 @@ func NewBaseUserDaoService(xl *xlog.Logger, config *utils.MongoConfig) (*BaseUse
 	if xl == nil {
 		xl = xlog.New("niu-cube-base-user")
 	}
-	client, err := mgo.Dial(config.URI)
+	client, err := mongodb.Get(config)
 	if err != nil {
 		xl.Errorf("failed to create mongo client, error: %v", err)
 		return nil, err
 	}
-	baseUserColl := client.DB(config.Database).C(dao.CollectionBaseUser)
+	baseUserColl := client.C(dao.CollectionBaseUser)
 	accountService, err := db.NewAccountService(*config, xl)
 	return &BaseUserDaoService{
 		client,
@@ -53,16 +56,17 @@ func NewBaseUserDaoService(xl *xlog.Logger, config *utils.MongoConfig) (*BaseUse
 	}, nil
 }
 
-func (b *BaseUserDaoService) Insert(xl *xlog.Logger, baseUserDo *model.BaseUserDo) (*model.BaseUserDo, error) {
+func (b *BaseUserDaoService) Insert(ctx context.Context, xl *xlog.Logger, baseUserDo *model.BaseUserDo) (*model.BaseUserDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseUserDaoService.Insert").End()
 	if baseUserDo.Id == "" {
 		baseUserDo.Id = bson.NewObjectId().Hex()
 	}
 	baseUserDo.CreatedTime = time.Now()
 	baseUserDo.UpdatedTime = time.Now()
-	err := b.baseUserColl.Insert(baseUserDo)
+	err := b.baseUserColl.WithContext(ctx).Insert(baseUserDo)
 	if err != nil {
 		xl.Error("insert into base_user failed.")
 		return nil, err
@@ -70,11 +74,12 @@ func (b *BaseUserDaoService) Insert(xl *xlog.Logger, baseUserDo *model.BaseUserD
 	return baseUserDo, nil
 }
 
-func (b *BaseUserDaoService) Delete(xl *xlog.Logger, userId string) error {
+func (b *BaseUserDaoService) Delete(ctx context.Context, xl *xlog.Logger, userId string) error {
 	if xl == nil {
 		xl = b.xl
 	}
-	err := b.baseUserColl.RemoveId(userId)
+	defer tracing.StartDAO(ctx, "BaseUserDaoService.Delete").End()
+	err := b.baseUserColl.WithContext(ctx).RemoveId(userId)
 	if err != nil {
 		xl.Error("delete from base_user failed.")
 		return err
@@ -82,12 +87,13 @@ func (b *BaseUserDaoService) Delete(xl *xlog.Logger, userId string) error {
 	return nil
 }
 
-func (b *BaseUserDaoService) Update(xl *xlog.Logger, baseUserDo *model.BaseUserDo) error {
+func (b *BaseUserDaoService) Update(ctx context.Context, xl *xlog.Logger, baseUserDo *model.BaseUserDo) error {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseUserDaoService.Update").End()
 	baseUserDo.UpdatedTime = time.Now()
-	err := b.baseUserColl.UpdateId(baseUserDo.Id, baseUserDo)
+	err := b.baseUserColl.WithContext(ctx).UpdateId(baseUserDo.Id, baseUserDo)
 	if err != nil {
 		xl.Error("update base_user failed.")
 		return err
@@ -95,16 +101,17 @@ func (b *BaseUserDaoService) Update(xl *xlog.Logger, baseUserDo *model.BaseUserD
 	return nil
 }
 
-func (b *BaseUserDaoService) Select(xl *xlog.Logger, userId string) (*model.BaseUserDo, error) {
+func (b *BaseUserDaoService) Select(ctx context.Context, xl *xlog.Logger, userId string) (*model.BaseUserDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseUserDaoService.Select").End()
 	var baseUserDo model.BaseUserDo
-	err := b.baseUserColl.FindId(userId).One(&baseUserDo)
+	err := b.baseUserColl.WithContext(ctx).FindId(userId).One(&baseUserDo)
 	if err != nil {
 		// 查询旧表
 		if err == mgo.ErrNotFound {
-			val, err := b.accountService.GetAccountByID(xl, userId)
+			val, err := b.accountService.GetAccountByID(ctx, xl, userId)
 			if err != nil {
 				xl.Error("select from old user collection failed.")
 				return nil, err
@@ -120,7 +127,7 @@ func (b *BaseUserDaoService) Select(xl *xlog.Logger, userId string) (*model.Base
 				UpdatedTime:   time.Now(),
 				BaseUserAttrs: nil,
 			}
-			err = b.baseUserColl.Insert(&baseUserDo)
+			err = b.baseUserColl.WithContext(ctx).Insert(&baseUserDo)
 			if err != nil {
 				xl.Error("insert into base_user failed.")
 				return nil, err
@@ -134,9 +141,9 @@ func (b *BaseUserDaoService) Select(xl *xlog.Logger, userId string) (*model.Base
 	return &baseUserDo, nil
 }
 
-func (b *BaseUserDaoService) ListAll() ([]model.BaseUserDo, error) {
+func (b *BaseUserDaoService) ListAll(ctx context.Context) ([]model.BaseUserDo, error) {
 	results := make([]model.BaseUserDo, 0)
-	err := b.baseUserColl.Find(nil).All(&results)
+	err := b.baseUserColl.WithContext(ctx).Find(nil).All(&results)
 	if err != nil {
 		return nil, err
 	}
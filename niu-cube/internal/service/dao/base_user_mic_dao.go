@@ -1,42 +1,45 @@
 package dao
 
 import (
+	"context"
 	"time"
 
 	"github.com/qiniu/x/xlog"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/db/dao"
 )
 
 type BaseUserMicDaoInterface interface {
-	Insert(xl *xlog.Logger, baseUserMic *model.BaseUserMicDo) (*model.BaseUserMicDo, error)
+	Insert(ctx context.Context, xl *xlog.Logger, baseUserMic *model.BaseUserMicDo) (*model.BaseUserMicDo, error)
 
 	// Update 用于麦位数量固定的场景，更新麦位占有属性
-	Update(xl *xlog.Logger, baseUserMic *model.BaseUserMicDo) error
+	Update(ctx context.Context, xl *xlog.Logger, baseUserMic *model.BaseUserMicDo) error
 
-	DeleteByUserIdMicId(xl *xlog.Logger, userId, micId string) error
+	DeleteByUserIdMicId(ctx context.Context, xl *xlog.Logger, userId, micId string) error
 
-	Delete(xl *xlog.Logger, id string) error
+	Delete(ctx context.Context, xl *xlog.Logger, id string) error
 
-	SelectByRoomIdMicId(xl *xlog.Logger, roomId, micId string) (*model.BaseUserMicDo, error)
+	SelectByRoomIdMicId(ctx context.Context, xl *xlog.Logger, roomId, micId string) (*model.BaseUserMicDo, error)
 
 	// SelectByRoomIdUserId 这里有潜在的问题，就是如果这样写就无法保证同一个用户存在多个场景
-	SelectByRoomIdUserId(xl *xlog.Logger, roomId, userId string) (*model.BaseUserMicDo, error)
+	SelectByRoomIdUserId(ctx context.Context, xl *xlog.Logger, roomId, userId string) (*model.BaseUserMicDo, error)
 
 	// ListByRoomId 只会列出有人使用的麦
-	ListByRoomId(xl *xlog.Logger, roomId string) ([]model.BaseUserMicDo, error)
+	ListByRoomId(ctx context.Context, xl *xlog.Logger, roomId string) ([]model.BaseUserMicDo, error)
 
 	// ListByUserId 这个用于允许整个系统存在同一用户且多场景的情形
-	ListByUserId(xl *xlog.Logger, userId string) ([]model.BaseUserMicDo, error)
+	ListByUserId(ctx context.Context, xl *xlog.Logger, userId string) ([]model.BaseUserMicDo, error)
 }
 
 type BaseUserMicDaoService struct {
-	client          *mgo.Session
-	baseUserMicColl *mgo.Collection
+	client          *mongodb.Manager
+	baseUserMicColl *mongodb.Collection
 	xl              *xlog.Logger
 }
 
@@ -44,12 +47,12 @@ func NewBaseUserMicDaoService(xl *xlog.Logger, config *utils.MongoConfig) (*Base
 	if xl == nil {
 		xl = xlog.New("niu-cube-base-user-mic")
 	}
-	client, err := mgo.Dial(config.URI)
+	client, err := mongodb.Get(config)
 	if err != nil {
 		xl.Errorf("failed to create mongo client, error: %v", err)
 		return nil, err
 	}
-	baseUserMicColl := client.DB(config.Database).C(dao.CollectionBaseUserMic)
+	baseUserMicColl := client.C(dao.CollectionBaseUserMic)
 	return &BaseUserMicDaoService{
 		client,
 		baseUserMicColl,
@@ -57,14 +60,15 @@ func NewBaseUserMicDaoService(xl *xlog.Logger, config *utils.MongoConfig) (*Base
 	}, nil
 }
 
-func (b *BaseUserMicDaoService) Insert(xl *xlog.Logger, baseUserMic *model.BaseUserMicDo) (*model.BaseUserMicDo, error) {
+func (b *BaseUserMicDaoService) Insert(ctx context.Context, xl *xlog.Logger, baseUserMic *model.BaseUserMicDo) (*model.BaseUserMicDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseUserMicDaoService.Insert").End()
 	baseUserMic.Id = bson.NewObjectId().Hex()
 	baseUserMic.CreatedTime = time.Now()
 	baseUserMic.UpdatedTime = time.Now()
-	err := b.baseUserMicColl.Insert(baseUserMic)
+	err := b.baseUserMicColl.WithContext(ctx).Insert(baseUserMic)
 	if err != nil {
 		xl.Error("insert into base_user_mic failed.")
 		return nil, err
@@ -72,12 +76,13 @@ func (b *BaseUserMicDaoService) Insert(xl *xlog.Logger, baseUserMic *model.BaseU
 	return baseUserMic, nil
 }
 
-func (b *BaseUserMicDaoService) Update(xl *xlog.Logger, baseUserMic *model.BaseUserMicDo) error {
+func (b *BaseUserMicDaoService) Update(ctx context.Context, xl *xlog.Logger, baseUserMic *model.BaseUserMicDo) error {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseUserMicDaoService.Update").End()
 	baseUserMic.UpdatedTime = time.Now()
-	err := b.baseUserMicColl.UpdateId(baseUserMic.Id, baseUserMic)
+	err := b.baseUserMicColl.WithContext(ctx).UpdateId(baseUserMic.Id, baseUserMic)
 	if err != nil {
 		xl.Error("update base_user_mic failed.")
 		return err
@@ -85,11 +90,12 @@ func (b *BaseUserMicDaoService) Update(xl *xlog.Logger, baseUserMic *model.BaseU
 	return nil
 }
 
-func (b *BaseUserMicDaoService) DeleteByUserIdMicId(xl *xlog.Logger, userId, micId string) error {
+func (b *BaseUserMicDaoService) DeleteByUserIdMicId(ctx context.Context, xl *xlog.Logger, userId, micId string) error {
 	if xl == nil {
 		xl = b.xl
 	}
-	err := b.baseUserMicColl.Remove(bson.M{"user_id": userId, "mic_id": micId})
+	defer tracing.StartDAO(ctx, "BaseUserMicDaoService.DeleteByUserIdMicId").End()
+	err := b.baseUserMicColl.WithContext(ctx).Remove(bson.M{"user_id": userId, "mic_id": micId})
 	if err != nil {
 		xl.Error("delete from base_user_mic failed.")
 		return err
@@ -97,11 +103,12 @@ func (b *BaseUserMicDaoService) DeleteByUserIdMicId(xl *xlog.Logger, userId, mic
 	return nil
 }
 
-func (b *BaseUserMicDaoService) Delete(xl *xlog.Logger, id string) error {
+func (b *BaseUserMicDaoService) Delete(ctx context.Context, xl *xlog.Logger, id string) error {
 	if xl == nil {
 		xl = b.xl
 	}
-	err := b.baseUserMicColl.RemoveId(id)
+	defer tracing.StartDAO(ctx, "BaseUserMicDaoService.Delete").End()
+	err := b.baseUserMicColl.WithContext(ctx).RemoveId(id)
 	if err != nil {
 		xl.Error("delete from base_user_mic failed.")
 		return err
@@ -109,12 +116,13 @@ func (b *BaseUserMicDaoService) Delete(xl *xlog.Logger, id string) error {
 	return nil
 }
 
-func (b *BaseUserMicDaoService) SelectByRoomIdMicId(xl *xlog.Logger, roomId, micId string) (*model.BaseUserMicDo, error) {
+func (b *BaseUserMicDaoService) SelectByRoomIdMicId(ctx context.Context, xl *xlog.Logger, roomId, micId string) (*model.BaseUserMicDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseUserMicDaoService.SelectByRoomIdMicId").End()
 	var userMic model.BaseUserMicDo
-	err := b.baseUserMicColl.Find(bson.M{"room_id": roomId, "mic_id": micId, "status": model.BaseUserMicHold}).One(&userMic)
+	err := b.baseUserMicColl.WithContext(ctx).Find(bson.M{"room_id": roomId, "mic_id": micId, "status": model.BaseUserMicHold}).One(&userMic)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't find this record from base_user_mic.")
@@ -126,12 +134,13 @@ func (b *BaseUserMicDaoService) SelectByRoomIdMicId(xl *xlog.Logger, roomId, mic
 	return &userMic, nil
 }
 
-func (b *BaseUserMicDaoService) SelectByRoomIdUserId(xl *xlog.Logger, roomId, userId string) (*model.BaseUserMicDo, error) {
+func (b *BaseUserMicDaoService) SelectByRoomIdUserId(ctx context.Context, xl *xlog.Logger, roomId, userId string) (*model.BaseUserMicDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseUserMicDaoService.SelectByRoomIdUserId").End()
 	var userMic model.BaseUserMicDo
-	err := b.baseUserMicColl.Find(bson.M{"room_id": roomId, "user_id": userId, "status": model.BaseUserMicHold}).One(&userMic)
+	err := b.baseUserMicColl.WithContext(ctx).Find(bson.M{"room_id": roomId, "user_id": userId, "status": model.BaseUserMicHold}).One(&userMic)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't find this record from base_user_mic.")
@@ -143,12 +152,13 @@ func (b *BaseUserMicDaoService) SelectByRoomIdUserId(xl *xlog.Logger, roomId, us
 	return &userMic, nil
 }
 
-func (b *BaseUserMicDaoService) ListByRoomId(xl *xlog.Logger, roomId string) ([]model.BaseUserMicDo, error) {
+func (b *BaseUserMicDaoService) ListByRoomId(ctx context.Context, xl *xlog.Logger, roomId string) ([]model.BaseUserMicDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseUserMicDaoService.ListByRoomId").End()
 	var userMicDos []model.BaseUserMicDo
-	err := b.baseUserMicColl.Find(bson.M{"room_id": roomId, "status": model.BaseUserMicHold}).All(&userMicDos)
+	err := b.baseUserMicColl.WithContext(ctx).Find(bson.M{"room_id": roomId, "status": model.BaseUserMicHold}).All(&userMicDos)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't list those records from base_user_mic.")
@@ -160,12 +170,13 @@ func (b *BaseUserMicDaoService) ListByRoomId(xl *xlog.Logger, roomId string) ([]
 	return userMicDos, nil
 }
 
-func (b *BaseUserMicDaoService) ListByUserId(xl *xlog.Logger, userId string) ([]model.BaseUserMicDo, error) {
+func (b *BaseUserMicDaoService) ListByUserId(ctx context.Context, xl *xlog.Logger, userId string) ([]model.BaseUserMicDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "BaseUserMicDaoService.ListByUserId").End()
 	var userMicDos []model.BaseUserMicDo
-	err := b.baseUserMicColl.Find(bson.M{"user_id": userId, "status": model.BaseUserMicHold}).All(&userMicDos)
+	err := b.baseUserMicColl.WithContext(ctx).Find(bson.M{"user_id": userId, "status": model.BaseUserMicHold}).All(&userMicDos)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't list those records from base_user_mic.")
@@ -3,6 +3,7 @@ package dao
 import (
 	"context"
 	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/db/dao"
@@ -13,139 +14,140 @@ import (
 )
 
 type ExamDao interface {
-	Insert(exam *model.ExamDo) error
+	Insert(ctx context.Context, exam *model.ExamDo) error
 
-	Select(id string) (*model.ExamDo, error)
+	Select(ctx context.Context, id string) (*model.ExamDo, error)
 
-	ListByCreator0(creator string) ([]model.ExamDo, error)
+	ListByCreator0(ctx context.Context, creator string) ([]model.ExamDo, error)
 
-	ListAll0() ([]model.ExamDo, error)
+	ListAll0(ctx context.Context) ([]model.ExamDo, error)
 
-	ListAll(pgNum, pgSize int64) ([]model.ExamDo, int64, error)
+	ListAll(ctx context.Context, page model.PageQuery) ([]model.ExamDo, model.PageResult, error)
 
-	Update(exam *model.ExamDo) error
+	Update(ctx context.Context, exam *model.ExamDo) error
 
-	Delete0(id string) error
+	Delete0(ctx context.Context, id string) error
 
-	Delete(id string) error
+	Delete(ctx context.Context, id string) error
 
-	DeleteAll0() error
+	DeleteAll0(ctx context.Context) error
 
-	DeleteAll() error
+	DeleteAll(ctx context.Context) error
 }
 
 type QuestionDao interface {
-	Insert(question *model.QuestionDo) error
+	Insert(ctx context.Context, question *model.QuestionDo) error
 
-	Select(id string) (*model.QuestionDo, error)
+	Select(ctx context.Context, id string) (*model.QuestionDo, error)
 
-	Update(question *model.QuestionDo) error
+	Update(ctx context.Context, question *model.QuestionDo) error
 
-	ListByType0(t string) ([]model.QuestionDo, error)
+	ListByType0(ctx context.Context, t string) ([]model.QuestionDo, error)
 
-	ListAll0() ([]model.QuestionDo, int64, error)
+	ListAll0(ctx context.Context) ([]model.QuestionDo, int64, error)
 
-	ListAll(pgNum, pgSize int64) ([]model.QuestionDo, int64, error)
+	ListAll(ctx context.Context, page model.PageQuery) ([]model.QuestionDo, model.PageResult, error)
 
-	Delete0(id string) error
+	Delete0(ctx context.Context, id string) error
 
-	Delete(id string) error
+	Delete(ctx context.Context, id string) error
 }
 
 type ExamPaperDao interface {
-	Insert(examPaper *model.ExamPaperDo) error
+	Insert(ctx context.Context, examPaper *model.ExamPaperDo) error
 
-	Select(id string) (*model.ExamPaperDo, error)
+	Select(ctx context.Context, id string) (*model.ExamPaperDo, error)
 
-	ListByExamId(examId string) ([]model.ExamPaperDo, error)
+	ListByExamId(ctx context.Context, examId string) ([]model.ExamPaperDo, error)
 
-	Update(examPaper *model.ExamPaperDo) error
+	Update(ctx context.Context, examPaper *model.ExamPaperDo) error
 
-	Delete0(id string) error
+	Delete0(ctx context.Context, id string) error
 
-	Delete(id string) error
+	Delete(ctx context.Context, id string) error
 
-	DeleteAll() error
+	DeleteAll(ctx context.Context) error
 }
 
 type UserExamDao interface {
-	Insert(userExam *model.UserExamDo) error
+	Insert(ctx context.Context, userExam *model.UserExamDo) error
 
-	Select(id string) (*model.UserExamDo, error)
+	Select(ctx context.Context, id string) (*model.UserExamDo, error)
 
-	SelectByExamIdUserId(examId, userId string) (*model.UserExamDo, error)
+	SelectByExamIdUserId(ctx context.Context, examId, userId string) (*model.UserExamDo, error)
 
-	ListByExamId0(examId string) ([]model.UserExamDo, error)
+	ListByExamId0(ctx context.Context, examId string) ([]model.UserExamDo, error)
 
-	ListByExamId(examId string, pgNum, pgSize int64) ([]model.UserExamDo, int64, error)
+	ListByExamId(ctx context.Context, examId string, page model.PageQuery) ([]model.UserExamDo, model.PageResult, error)
 
-	ListByUserId0(userId string) ([]model.UserExamDo, error)
+	ListByUserId0(ctx context.Context, userId string) ([]model.UserExamDo, error)
 
-	ListByUserId(userId string, pgNum, pgSize int64) ([]model.UserExamDo, int64, error)
+	ListByUserId(ctx context.Context, userId string, page model.PageQuery) ([]model.UserExamDo, model.PageResult, error)
 
-	ListAll0() ([]model.UserExamDo, error)
+	ListAll0(ctx context.Context) ([]model.UserExamDo, error)
 
-	ListAll(pgNum, pgSize int64) ([]model.UserExamDo, int64, error)
+	ListAll(ctx context.Context, pgNum, pgSize int64) ([]model.UserExamDo, int64, error)
 
-	Update(userExam *model.UserExamDo) error
+	Update(ctx context.Context, userExam *model.UserExamDo) error
 
-	Delete0(id string) error
+	Delete0(ctx context.Context, id string) error
 
-	Delete(id string) error
+	Delete(ctx context.Context, id string) error
 
-	DeleteAll() error
+	DeleteAll(ctx context.Context) error
 }
 
 type AnswerPaperDao interface {
-	Insert(answerPaper *model.AnswerPaperDo) error
+	Insert(ctx context.Context, answerPaper *model.AnswerPaperDo) error
 
-	Select(id string) (*model.AnswerPaperDo, error)
+	Select(ctx context.Context, id string) (*model.AnswerPaperDo, error)
 
-	SelectByExamIdUserId(examId, userId string) (*model.AnswerPaperDo, error)
+	SelectByExamIdUserId(ctx context.Context, examId, userId string) (*model.AnswerPaperDo, error)
 
-	ListByExamId0(examId string) ([]model.AnswerPaperDo, error)
+	ListByExamId0(ctx context.Context, examId string) ([]model.AnswerPaperDo, error)
 
-	Update(answerPaper *model.AnswerPaperDo) error
+	Update(ctx context.Context, answerPaper *model.AnswerPaperDo) error
 
-	Delete0(id string) error
+	Delete0(ctx context.Context, id string) error
 
-	Delete(id string) error
+	Delete(ctx context.Context, id string) error
 
-	DeleteAll() error
+	DeleteAll(ctx context.Context) error
 }
 
 type CheatingEventDao interface {
-	Insert(cheatingEvent *model.CheatingEvent) error
+	Insert(ctx context.Context, cheatingEvent *model.CheatingEvent) error
 
-	ListByExamIdUserId(examId, userId string, afterTimestamp, beforeTimestamp int64) ([]model.CheatingEvent, error)
+	ListByExamIdUserId(ctx context.Context, examId, userId string, afterTimestamp, beforeTimestamp int64) ([]model.CheatingEvent, error)
 }
 
 type ExamDaoService struct {
 	collection *mongo.Collection
-	logger     *xlog.Logger
+	// timeout 单次操作的超时时间。
+	timeout time.Duration
+	logger  *xlog.Logger
 }
 
 func NewExamDaoService(config *utils.MongoConfig) *ExamDaoService {
 	logger := xlog.New("exam dao service")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(config.URI))
+	client, err := mongodb.Get(config)
 	if err != nil {
 		panic(err)
 	}
-	collection := client.Database(config.Database).Collection(dao.CollectionExam)
+	collection := client.Collection(dao.CollectionExam)
 	return &ExamDaoService{
 		collection,
+		client.OperationTimeout(),
 		logger,
 	}
 }
 
-func (e *ExamDaoService) Insert(exam *model.ExamDo) error {
+func (e *ExamDaoService) Insert(ctx context.Context, exam *model.ExamDo) error {
 	exam.Id = primitive.NewObjectID().Hex()
 	exam.Status = model.ExamCreated
 	exam.CreatedTime = time.Now()
 	exam.UpdatedTime = time.Now()
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+	timeout, cancelFunc := context.WithTimeout(ctx, e.timeout)
 	defer cancelFunc()
 	_, err := e.collection.InsertOne(timeout, exam)
 	if err != nil {
@@ -155,8 +157,8 @@ func (e *ExamDaoService) Insert(exam *model.ExamDo) error {
 	return nil
 }
 
-func (e *ExamDaoService) Select(id string) (*model.ExamDo, error) {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (e *ExamDaoService) Select(ctx context.Context, id string) (*model.ExamDo, error) {
+	timeout, cancelFunc := context.WithTimeout(ctx, e.timeout)
 	defer cancelFunc()
 	one := e.collection.FindOne(timeout, primitive.M{"_id": id, "status": primitive.M{"$ne": model.ExamDestroyed}})
 	if err := one.Err(); err != nil {
@@ -175,8 +177,8 @@ func (e *ExamDaoService) Select(id string) (*model.ExamDo, error) {
 	return &result, nil
 }
 
-func (e *ExamDaoService) ListByCreator0(creator string) ([]model.ExamDo, error) {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (e *ExamDaoService) ListByCreator0(ctx context.Context, creator string) ([]model.ExamDo, error) {
+	timeout, cancelFunc := context.WithTimeout(ctx, e.timeout)
 	defer cancelFunc()
 	cursor, err := e.collection.Find(timeout, primitive.M{"creator": creator}, &options.FindOptions{
 		Sort: primitive.M{"created_time": -1},
@@ -211,8 +213,8 @@ func (e *ExamDaoService) ListByCreator0(creator string) ([]model.ExamDo, error)
 	return results, nil
 }
 
-func (e *ExamDaoService) ListAll0() ([]model.ExamDo, error) {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (e *ExamDaoService) ListAll0(ctx context.Context) ([]model.ExamDo, error) {
+	timeout, cancelFunc := context.WithTimeout(ctx, e.timeout)
 	defer cancelFunc()
 	cursor, err := e.collection.Find(timeout, primitive.M{"status": primitive.M{"$ne": model.ExamDestroyed}}, &options.FindOptions{
 		Sort: primitive.M{"created_time": -1},
@@ -247,18 +249,16 @@ func (e *ExamDaoService) ListAll0() ([]model.ExamDo, error) {
 	return results, nil
 }
 
-func (e *ExamDaoService) ListAll(pgNum, pgSize int64) ([]model.ExamDo, int64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// ListAll 按创建时间倒序分页列出未销毁的考试，page.Cursor不为空时从游标之后读取，不统计总数。
+func (e *ExamDaoService) ListAll(ctx context.Context, page model.PageQuery) ([]model.ExamDo, model.PageResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
 	defer cancel()
-	skip := (pgNum - 1) * pgSize
-	cursor, err := e.collection.Find(ctx, primitive.M{"status": primitive.M{"$ne": model.ExamDestroyed}}, &options.FindOptions{
-		Limit: &pgSize,
-		Skip:  &skip,
-		Sort:  primitive.M{"created_time": -1},
-	})
+	filter := primitive.M{"status": primitive.M{"$ne": model.ExamDestroyed}}
+	query, opts := pageFindOptions(filter, page, "created_time", true)
+	cursor, err := e.collection.Find(ctx, query, opts)
 	if err != nil {
 		e.logger.Error(err)
-		return nil, 0, err
+		return nil, model.PageResult{}, err
 	}
 	defer func(cursor *mongo.Cursor, ctx context.Context) {
 		err := cursor.Close(ctx)
@@ -266,63 +266,66 @@ func (e *ExamDaoService) ListAll(pgNum, pgSize int64) ([]model.ExamDo, int64, er
 			e.logger.Error(err)
 		}
 	}(cursor, ctx)
-	if err != nil {
-		return nil, 0, err
-	}
-	results := make([]model.ExamDo, 0, pgSize)
+	results := make([]model.ExamDo, 0, page.PageSize+1)
 	for cursor.Next(ctx) {
 		tmp := model.ExamDo{}
 		err := cursor.Decode(&tmp)
 		if err != nil {
 			e.logger.Error(err)
-			return nil, 0, err
+			return nil, model.PageResult{}, err
 		}
 		results = append(results, tmp)
 	}
 	if err := cursor.Err(); err != nil {
 		e.logger.Error(err)
-		return nil, 0, err
+		return nil, model.PageResult{}, err
 	}
-	total, err := e.collection.CountDocuments(ctx, primitive.M{"status": primitive.M{"$ne": model.ExamDestroyed}})
-	if err != nil {
-		return nil, 0, err
+	res := model.NewPageResult(page, len(results), func(i int) *model.PageCursor {
+		return &model.PageCursor{Time: results[i].CreatedTime, ID: results[i].Id}
+	})
+	if page.Cursor == nil {
+		total, err := e.collection.CountDocuments(ctx, filter)
+		if err != nil {
+			return nil, model.PageResult{}, err
+		}
+		res.Total = int(total)
 	}
-	return results, total, nil
+	return results[:res.Count], res, nil
 }
 
-func (e *ExamDaoService) Update(exam *model.ExamDo) error {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (e *ExamDaoService) Update(ctx context.Context, exam *model.ExamDo) error {
+	timeout, cancelFunc := context.WithTimeout(ctx, e.timeout)
 	defer cancelFunc()
 	_, err := e.collection.UpdateByID(timeout, exam.Id, primitive.M{"$set": exam})
 	return err
 }
 
-func (e *ExamDaoService) Delete0(id string) error {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (e *ExamDaoService) Delete0(ctx context.Context, id string) error {
+	timeout, cancelFunc := context.WithTimeout(ctx, e.timeout)
 	defer cancelFunc()
 	_, err := e.collection.DeleteOne(timeout, primitive.M{"_id": id})
 	return err
 }
 
-func (e *ExamDaoService) Delete(id string) error {
-	exam, err := e.Select(id)
+func (e *ExamDaoService) Delete(ctx context.Context, id string) error {
+	exam, err := e.Select(ctx, id)
 	if err != nil {
 		return err
 	}
 	exam.UpdatedTime = time.Now()
 	exam.Status = model.ExamDestroyed
-	return e.Update(exam)
+	return e.Update(ctx, exam)
 }
 
-func (e *ExamDaoService) DeleteAll0() error {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (e *ExamDaoService) DeleteAll0(ctx context.Context) error {
+	timeout, cancelFunc := context.WithTimeout(ctx, e.timeout)
 	defer cancelFunc()
 	_, err := e.collection.DeleteMany(timeout, primitive.M{})
 	return err
 }
 
-func (e *ExamDaoService) DeleteAll() error {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 15*time.Second)
+func (e *ExamDaoService) DeleteAll(ctx context.Context) error {
+	timeout, cancelFunc := context.WithTimeout(ctx, e.timeout)
 	defer cancelFunc()
 	_, err := e.collection.UpdateMany(timeout, primitive.M{}, primitive.M{"$set": primitive.M{"status": model.ExamDestroyed}})
 	return err
@@ -330,25 +333,26 @@ func (e *ExamDaoService) DeleteAll() error {
 
 type QuestionDaoService struct {
 	collection *mongo.Collection
-	logger     *xlog.Logger
+	// timeout 单次操作的超时时间。
+	timeout time.Duration
+	logger  *xlog.Logger
 }
 
 func NewQuestionDaoService(config *utils.MongoConfig) *QuestionDaoService {
 	logger := xlog.New("question dao service")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(config.URI))
+	client, err := mongodb.Get(config)
 	if err != nil {
 		panic(err)
 	}
-	collection := client.Database(config.Database).Collection(dao.CollectionQuestion)
+	collection := client.Collection(dao.CollectionQuestion)
 	return &QuestionDaoService{
 		collection,
+		client.OperationTimeout(),
 		logger,
 	}
 }
 
-func (q *QuestionDaoService) Insert(question *model.QuestionDo) error {
+func (q *QuestionDaoService) Insert(ctx context.Context, question *model.QuestionDo) error {
 	question.Id = primitive.NewObjectID().Hex()
 	question.Status = model.QuestionAvailable
 	question.CreatedTime = time.Now()
@@ -357,7 +361,7 @@ func (q *QuestionDaoService) Insert(question *model.QuestionDo) error {
 	question.Answer.Status = model.AnswerAvailable
 	question.Answer.CreatedTime = time.Now()
 	question.Answer.UpdatedTime = time.Now()
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+	timeout, cancelFunc := context.WithTimeout(ctx, q.timeout)
 	defer cancelFunc()
 	_, err := q.collection.InsertOne(timeout, question)
 	if err != nil {
@@ -367,8 +371,8 @@ func (q *QuestionDaoService) Insert(question *model.QuestionDo) error {
 	return nil
 }
 
-func (q *QuestionDaoService) Select(id string) (*model.QuestionDo, error) {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (q *QuestionDaoService) Select(ctx context.Context, id string) (*model.QuestionDo, error) {
+	timeout, cancelFunc := context.WithTimeout(ctx, q.timeout)
 	defer cancelFunc()
 	one := q.collection.FindOne(timeout, primitive.M{"_id": id})
 	if err := one.Err(); err != nil {
@@ -387,15 +391,15 @@ func (q *QuestionDaoService) Select(id string) (*model.QuestionDo, error) {
 	return &result, nil
 }
 
-func (q *QuestionDaoService) Update(question *model.QuestionDo) error {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (q *QuestionDaoService) Update(ctx context.Context, question *model.QuestionDo) error {
+	timeout, cancelFunc := context.WithTimeout(ctx, q.timeout)
 	defer cancelFunc()
 	_, err := q.collection.UpdateByID(timeout, question.Id, primitive.M{"$set": question})
 	return err
 }
 
-func (q *QuestionDaoService) ListByType0(t string) ([]model.QuestionDo, error) {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (q *QuestionDaoService) ListByType0(ctx context.Context, t string) ([]model.QuestionDo, error) {
+	timeout, cancelFunc := context.WithTimeout(ctx, q.timeout)
 	defer cancelFunc()
 	cursor, err := q.collection.Find(timeout, primitive.M{"type": t}, &options.FindOptions{
 		Sort: primitive.M{"created_time": -1},
@@ -430,8 +434,8 @@ func (q *QuestionDaoService) ListByType0(t string) ([]model.QuestionDo, error) {
 	return results, nil
 }
 
-func (q *QuestionDaoService) ListAll0() ([]model.QuestionDo, int64, error) {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (q *QuestionDaoService) ListAll0(ctx context.Context) ([]model.QuestionDo, int64, error) {
+	timeout, cancelFunc := context.WithTimeout(ctx, q.timeout)
 	defer cancelFunc()
 	cursor, err := q.collection.Find(timeout, primitive.M{"status": model.QuestionAvailable}, &options.FindOptions{
 		Sort: primitive.M{"created_time": -1},
@@ -471,18 +475,16 @@ func (q *QuestionDaoService) ListAll0() ([]model.QuestionDo, int64, error) {
 	return results, total, nil
 }
 
-func (q *QuestionDaoService) ListAll(pgNum, pgSize int64) ([]model.QuestionDo, int64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// ListAll 按创建时间倒序分页列出可用的题目，page.Cursor不为空时从游标之后读取，不统计总数。
+func (q *QuestionDaoService) ListAll(ctx context.Context, page model.PageQuery) ([]model.QuestionDo, model.PageResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, q.timeout)
 	defer cancel()
-	skip := (pgNum - 1) * pgSize
-	cursor, err := q.collection.Find(ctx, primitive.M{"status": model.QuestionAvailable}, &options.FindOptions{
-		Limit: &pgSize,
-		Skip:  &skip,
-		Sort:  primitive.M{"created_time": -1},
-	})
+	filter := primitive.M{"status": model.QuestionAvailable}
+	query, opts := pageFindOptions(filter, page, "created_time", true)
+	cursor, err := q.collection.Find(ctx, query, opts)
 	if err != nil {
 		q.logger.Error(err)
-		return nil, 0, err
+		return nil, model.PageResult{}, err
 	}
 	defer func(cursor *mongo.Cursor, ctx context.Context) {
 		err := cursor.Close(ctx)
@@ -490,73 +492,77 @@ func (q *QuestionDaoService) ListAll(pgNum, pgSize int64) ([]model.QuestionDo, i
 			q.logger.Error(err)
 		}
 	}(cursor, ctx)
-	if err != nil {
-		return nil, 0, err
-	}
-	results := make([]model.QuestionDo, 0, pgSize)
+	results := make([]model.QuestionDo, 0, page.PageSize+1)
 	for cursor.Next(ctx) {
 		tmp := model.QuestionDo{}
 		err := cursor.Decode(&tmp)
 		if err != nil {
 			q.logger.Error(err)
-			return nil, 0, err
+			return nil, model.PageResult{}, err
 		}
 		results = append(results, tmp)
 	}
 	if err := cursor.Err(); err != nil {
 		q.logger.Error(err)
-		return nil, 0, err
+		return nil, model.PageResult{}, err
 	}
-	total, err := q.collection.CountDocuments(ctx, primitive.M{})
-	if err != nil {
-		return nil, 0, err
+	res := model.NewPageResult(page, len(results), func(i int) *model.PageCursor {
+		return &model.PageCursor{Time: results[i].CreatedTime, ID: results[i].Id}
+	})
+	if page.Cursor == nil {
+		total, err := q.collection.CountDocuments(ctx, primitive.M{})
+		if err != nil {
+			return nil, model.PageResult{}, err
+		}
+		res.Total = int(total)
 	}
-	return results, total, nil
+	return results[:res.Count], res, nil
 }
 
-func (q *QuestionDaoService) Delete0(id string) error {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (q *QuestionDaoService) Delete0(ctx context.Context, id string) error {
+	timeout, cancelFunc := context.WithTimeout(ctx, q.timeout)
 	defer cancelFunc()
 	_, err := q.collection.DeleteOne(timeout, primitive.M{"_id": id})
 	return err
 }
 
-func (q *QuestionDaoService) Delete(id string) error {
-	question, err := q.Select(id)
+func (q *QuestionDaoService) Delete(ctx context.Context, id string) error {
+	question, err := q.Select(ctx, id)
 	if err != nil {
 		return err
 	}
 	question.UpdatedTime = time.Now()
 	question.Status = model.QuestionUnavailable
-	return q.Update(question)
+	return q.Update(ctx, question)
 }
 
 type ExamPaperDaoService struct {
 	collection *mongo.Collection
-	logger     *xlog.Logger
+	// timeout 单次操作的超时时间。
+	timeout time.Duration
+	logger  *xlog.Logger
 }
 
 func NewExamPaperDaoService(config *utils.MongoConfig) *ExamPaperDaoService {
 	logger := xlog.New("exam dao service")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(config.URI))
+	client, err := mongodb.Get(config)
 	if err != nil {
 		panic(err)
 	}
-	collection := client.Database(config.Database).Collection(dao.CollectionExamPaper)
+	collection := client.Collection(dao.CollectionExamPaper)
 	return &ExamPaperDaoService{
 		collection,
+		client.OperationTimeout(),
 		logger,
 	}
 }
 
-func (e *ExamPaperDaoService) Insert(examPaper *model.ExamPaperDo) error {
+func (e *ExamPaperDaoService) Insert(ctx context.Context, examPaper *model.ExamPaperDo) error {
 	examPaper.Id = primitive.NewObjectID().Hex()
 	examPaper.Status = model.ExamPaperAvailable
 	examPaper.CreatedTime = time.Now()
 	examPaper.UpdatedTime = time.Now()
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+	timeout, cancelFunc := context.WithTimeout(ctx, e.timeout)
 	defer cancelFunc()
 	_, err := e.collection.InsertOne(timeout, examPaper)
 	if err != nil {
@@ -566,8 +572,8 @@ func (e *ExamPaperDaoService) Insert(examPaper *model.ExamPaperDo) error {
 	return nil
 }
 
-func (e *ExamPaperDaoService) Select(id string) (*model.ExamPaperDo, error) {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (e *ExamPaperDaoService) Select(ctx context.Context, id string) (*model.ExamPaperDo, error) {
+	timeout, cancelFunc := context.WithTimeout(ctx, e.timeout)
 	defer cancelFunc()
 	one := e.collection.FindOne(timeout, primitive.M{"_id": id})
 	if err := one.Err(); err != nil {
@@ -586,8 +592,8 @@ func (e *ExamPaperDaoService) Select(id string) (*model.ExamPaperDo, error) {
 	return &result, nil
 }
 
-func (e *ExamPaperDaoService) ListByExamId(examId string) ([]model.ExamPaperDo, error) {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (e *ExamPaperDaoService) ListByExamId(ctx context.Context, examId string) ([]model.ExamPaperDo, error) {
+	timeout, cancelFunc := context.WithTimeout(ctx, e.timeout)
 	defer cancelFunc()
 	cursor, err := e.collection.Find(timeout, primitive.M{"exam_id": examId, "status": model.ExamPaperAvailable}, &options.FindOptions{
 		Sort: primitive.M{"created_time": -1},
@@ -622,32 +628,32 @@ func (e *ExamPaperDaoService) ListByExamId(examId string) ([]model.ExamPaperDo,
 	return results, nil
 }
 
-func (e *ExamPaperDaoService) Update(examPaper *model.ExamPaperDo) error {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (e *ExamPaperDaoService) Update(ctx context.Context, examPaper *model.ExamPaperDo) error {
+	timeout, cancelFunc := context.WithTimeout(ctx, e.timeout)
 	defer cancelFunc()
 	_, err := e.collection.UpdateByID(timeout, examPaper.Id, primitive.M{"$set": examPaper})
 	return err
 }
 
-func (e *ExamPaperDaoService) Delete0(id string) error {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (e *ExamPaperDaoService) Delete0(ctx context.Context, id string) error {
+	timeout, cancelFunc := context.WithTimeout(ctx, e.timeout)
 	defer cancelFunc()
 	_, err := e.collection.DeleteOne(timeout, primitive.M{"_id": id})
 	return err
 }
 
-func (e *ExamPaperDaoService) Delete(id string) error {
-	examPaper, err := e.Select(id)
+func (e *ExamPaperDaoService) Delete(ctx context.Context, id string) error {
+	examPaper, err := e.Select(ctx, id)
 	if err != nil {
 		return err
 	}
 	examPaper.UpdatedTime = time.Now()
 	examPaper.Status = model.QuestionUnavailable
-	return e.Update(examPaper)
+	return e.Update(ctx, examPaper)
 }
 
-func (e *ExamPaperDaoService) DeleteAll() error {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 15*time.Second)
+func (e *ExamPaperDaoService) DeleteAll(ctx context.Context) error {
+	timeout, cancelFunc := context.WithTimeout(ctx, e.timeout)
 	defer cancelFunc()
 	_, err := e.collection.UpdateMany(timeout, primitive.M{}, primitive.M{"$set": primitive.M{"status": model.ExamPaperUnAvailable}})
 	return err
@@ -655,30 +661,31 @@ func (e *ExamPaperDaoService) DeleteAll() error {
 
 type UserExamDaoService struct {
 	collection *mongo.Collection
-	logger     *xlog.Logger
+	// timeout 单次操作的超时时间。
+	timeout time.Duration
+	logger  *xlog.Logger
 }
 
 func NewUserExamDaoService(config *utils.MongoConfig) *UserExamDaoService {
 	logger := xlog.New("exam dao service")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(config.URI))
+	client, err := mongodb.Get(config)
 	if err != nil {
 		panic(err)
 	}
-	collection := client.Database(config.Database).Collection(dao.CollectionUserExam)
+	collection := client.Collection(dao.CollectionUserExam)
 	return &UserExamDaoService{
 		collection,
+		client.OperationTimeout(),
 		logger,
 	}
 }
 
-func (u *UserExamDaoService) Insert(userExam *model.UserExamDo) error {
+func (u *UserExamDaoService) Insert(ctx context.Context, userExam *model.UserExamDo) error {
 	userExam.Id = primitive.NewObjectID().Hex()
 	userExam.Status = model.UserExamToBeInvolved
 	userExam.CreatedTime = time.Now()
 	userExam.UpdatedTime = time.Now()
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+	timeout, cancelFunc := context.WithTimeout(ctx, u.timeout)
 	defer cancelFunc()
 	_, err := u.collection.InsertOne(timeout, userExam)
 	if err != nil {
@@ -688,8 +695,8 @@ func (u *UserExamDaoService) Insert(userExam *model.UserExamDo) error {
 	return nil
 }
 
-func (u *UserExamDaoService) Select(id string) (*model.UserExamDo, error) {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (u *UserExamDaoService) Select(ctx context.Context, id string) (*model.UserExamDo, error) {
+	timeout, cancelFunc := context.WithTimeout(ctx, u.timeout)
 	defer cancelFunc()
 	one := u.collection.FindOne(timeout, primitive.M{"_id": id})
 	if err := one.Err(); err != nil {
@@ -708,8 +715,8 @@ func (u *UserExamDaoService) Select(id string) (*model.UserExamDo, error) {
 	return &result, nil
 }
 
-func (u *UserExamDaoService) SelectByExamIdUserId(examId, userId string) (*model.UserExamDo, error) {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (u *UserExamDaoService) SelectByExamIdUserId(ctx context.Context, examId, userId string) (*model.UserExamDo, error) {
+	timeout, cancelFunc := context.WithTimeout(ctx, u.timeout)
 	defer cancelFunc()
 	one := u.collection.FindOne(timeout, primitive.M{"exam_id": examId, "user_id": userId, "status": primitive.M{"$ne": model.ExamDestroyed}})
 	if err := one.Err(); err != nil {
@@ -728,8 +735,8 @@ func (u *UserExamDaoService) SelectByExamIdUserId(examId, userId string) (*model
 	return &result, nil
 }
 
-func (u *UserExamDaoService) ListByExamId0(examId string) ([]model.UserExamDo, error) {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (u *UserExamDaoService) ListByExamId0(ctx context.Context, examId string) ([]model.UserExamDo, error) {
+	timeout, cancelFunc := context.WithTimeout(ctx, u.timeout)
 	defer cancelFunc()
 	cursor, err := u.collection.Find(timeout, primitive.M{"exam_id": examId}, &options.FindOptions{
 		Sort: primitive.M{"created_time": -1},
@@ -764,18 +771,16 @@ func (u *UserExamDaoService) ListByExamId0(examId string) ([]model.UserExamDo, e
 	return results, nil
 }
 
-func (u *UserExamDaoService) ListByExamId(examId string, pgNum, pgSize int64) ([]model.UserExamDo, int64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// ListByExamId 按创建时间倒序分页列出考试中的考生，page.Cursor不为空时从游标之后读取，不统计总数。
+func (u *UserExamDaoService) ListByExamId(ctx context.Context, examId string, page model.PageQuery) ([]model.UserExamDo, model.PageResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
 	defer cancel()
-	skip := (pgNum - 1) * pgSize
-	cursor, err := u.collection.Find(ctx, primitive.M{"exam_id": examId, "status": model.UserExamInProgress}, &options.FindOptions{
-		Limit: &pgSize,
-		Skip:  &skip,
-		Sort:  primitive.M{"created_time": -1},
-	})
+	filter := primitive.M{"exam_id": examId, "status": model.UserExamInProgress}
+	query, opts := pageFindOptions(filter, page, "created_time", true)
+	cursor, err := u.collection.Find(ctx, query, opts)
 	if err != nil {
 		u.logger.Error(err)
-		return nil, 0, err
+		return nil, model.PageResult{}, err
 	}
 	defer func(cursor *mongo.Cursor, ctx context.Context) {
 		err := cursor.Close(ctx)
@@ -783,32 +788,35 @@ func (u *UserExamDaoService) ListByExamId(examId string, pgNum, pgSize int64) ([
 			u.logger.Error(err)
 		}
 	}(cursor, ctx)
-	if err != nil {
-		return nil, 0, err
-	}
-	results := make([]model.UserExamDo, 0, pgSize)
+	results := make([]model.UserExamDo, 0, page.PageSize+1)
 	for cursor.Next(ctx) {
 		tmp := model.UserExamDo{}
 		err := cursor.Decode(&tmp)
 		if err != nil {
 			u.logger.Error(err)
-			return nil, 0, err
+			return nil, model.PageResult{}, err
 		}
 		results = append(results, tmp)
 	}
 	if err := cursor.Err(); err != nil {
 		u.logger.Error(err)
-		return nil, 0, err
+		return nil, model.PageResult{}, err
 	}
-	total, err := u.collection.CountDocuments(ctx, primitive.M{"exam_id": examId, "status": model.UserExamInProgress})
-	if err != nil {
-		return nil, 0, err
+	res := model.NewPageResult(page, len(results), func(i int) *model.PageCursor {
+		return &model.PageCursor{Time: results[i].CreatedTime, ID: results[i].Id}
+	})
+	if page.Cursor == nil {
+		total, err := u.collection.CountDocuments(ctx, filter)
+		if err != nil {
+			return nil, model.PageResult{}, err
+		}
+		res.Total = int(total)
 	}
-	return results, total, nil
+	return results[:res.Count], res, nil
 }
 
-func (u *UserExamDaoService) ListByUserId0(userId string) ([]model.UserExamDo, error) {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (u *UserExamDaoService) ListByUserId0(ctx context.Context, userId string) ([]model.UserExamDo, error) {
+	timeout, cancelFunc := context.WithTimeout(ctx, u.timeout)
 	defer cancelFunc()
 	cursor, err := u.collection.Find(timeout, primitive.M{"user_id": userId}, &options.FindOptions{
 		Sort: primitive.M{"created_time": -1},
@@ -843,18 +851,16 @@ func (u *UserExamDaoService) ListByUserId0(userId string) ([]model.UserExamDo, e
 	return results, nil
 }
 
-func (u *UserExamDaoService) ListByUserId(userId string, pgNum, pgSize int64) ([]model.UserExamDo, int64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// ListByUserId 按创建时间倒序分页列出用户参加的考试，page.Cursor不为空时从游标之后读取，不统计总数。
+func (u *UserExamDaoService) ListByUserId(ctx context.Context, userId string, page model.PageQuery) ([]model.UserExamDo, model.PageResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
 	defer cancel()
-	skip := (pgNum - 1) * pgSize
-	cursor, err := u.collection.Find(ctx, primitive.M{"user_id": userId, "status": primitive.M{"$ne": model.ExamDestroyed}}, &options.FindOptions{
-		Limit: &pgSize,
-		Skip:  &skip,
-		Sort:  primitive.M{"created_time": -1},
-	})
+	filter := primitive.M{"user_id": userId, "status": primitive.M{"$ne": model.ExamDestroyed}}
+	query, opts := pageFindOptions(filter, page, "created_time", true)
+	cursor, err := u.collection.Find(ctx, query, opts)
 	if err != nil {
 		u.logger.Error(err)
-		return nil, 0, err
+		return nil, model.PageResult{}, err
 	}
 	defer func(cursor *mongo.Cursor, ctx context.Context) {
 		err := cursor.Close(ctx)
@@ -862,32 +868,35 @@ func (u *UserExamDaoService) ListByUserId(userId string, pgNum, pgSize int64) ([
 			u.logger.Error(err)
 		}
 	}(cursor, ctx)
-	if err != nil {
-		return nil, 0, err
-	}
-	results := make([]model.UserExamDo, 0, pgSize)
+	results := make([]model.UserExamDo, 0, page.PageSize+1)
 	for cursor.Next(ctx) {
 		tmp := model.UserExamDo{}
 		err := cursor.Decode(&tmp)
 		if err != nil {
 			u.logger.Error(err)
-			return nil, 0, err
+			return nil, model.PageResult{}, err
 		}
 		results = append(results, tmp)
 	}
 	if err := cursor.Err(); err != nil {
 		u.logger.Error(err)
-		return nil, 0, err
+		return nil, model.PageResult{}, err
 	}
-	total, err := u.collection.CountDocuments(ctx, primitive.M{"user_id": userId, "status": primitive.M{"$ne": model.ExamDestroyed}})
-	if err != nil {
-		return nil, 0, err
+	res := model.NewPageResult(page, len(results), func(i int) *model.PageCursor {
+		return &model.PageCursor{Time: results[i].CreatedTime, ID: results[i].Id}
+	})
+	if page.Cursor == nil {
+		total, err := u.collection.CountDocuments(ctx, filter)
+		if err != nil {
+			return nil, model.PageResult{}, err
+		}
+		res.Total = int(total)
 	}
-	return results, total, nil
+	return results[:res.Count], res, nil
 }
 
-func (u *UserExamDaoService) ListAll0() ([]model.UserExamDo, error) {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (u *UserExamDaoService) ListAll0(ctx context.Context) ([]model.UserExamDo, error) {
+	timeout, cancelFunc := context.WithTimeout(ctx, u.timeout)
 	defer cancelFunc()
 	cursor, err := u.collection.Find(timeout, primitive.M{}, &options.FindOptions{
 		Sort: primitive.M{"created_time": -1},
@@ -922,8 +931,8 @@ func (u *UserExamDaoService) ListAll0() ([]model.UserExamDo, error) {
 	return results, nil
 }
 
-func (u *UserExamDaoService) ListAll(pgNum, pgSize int64) ([]model.UserExamDo, int64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (u *UserExamDaoService) ListAll(ctx context.Context, pgNum, pgSize int64) ([]model.UserExamDo, int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
 	defer cancel()
 	skip := (pgNum - 1) * pgSize
 	cursor, err := u.collection.Find(ctx, primitive.M{"status": primitive.M{"$ne": model.ExamDestroyed}}, &options.FindOptions{
@@ -965,33 +974,33 @@ func (u *UserExamDaoService) ListAll(pgNum, pgSize int64) ([]model.UserExamDo, i
 	return results, total, nil
 }
 
-func (u *UserExamDaoService) Update(userExam *model.UserExamDo) error {
+func (u *UserExamDaoService) Update(ctx context.Context, userExam *model.UserExamDo) error {
 	userExam.UpdatedTime = time.Now()
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+	timeout, cancelFunc := context.WithTimeout(ctx, u.timeout)
 	defer cancelFunc()
 	_, err := u.collection.UpdateByID(timeout, userExam.Id, primitive.M{"$set": userExam})
 	return err
 }
 
-func (u *UserExamDaoService) Delete0(id string) error {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (u *UserExamDaoService) Delete0(ctx context.Context, id string) error {
+	timeout, cancelFunc := context.WithTimeout(ctx, u.timeout)
 	defer cancelFunc()
 	_, err := u.collection.DeleteOne(timeout, primitive.M{"_id": id})
 	return err
 }
 
-func (u *UserExamDaoService) Delete(id string) error {
-	userExam, err := u.Select(id)
+func (u *UserExamDaoService) Delete(ctx context.Context, id string) error {
+	userExam, err := u.Select(ctx, id)
 	if err != nil {
 		return err
 	}
 	userExam.UpdatedTime = time.Now()
 	userExam.Status = model.QuestionUnavailable
-	return u.Update(userExam)
+	return u.Update(ctx, userExam)
 }
 
-func (u *UserExamDaoService) DeleteAll() error {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 15*time.Second)
+func (u *UserExamDaoService) DeleteAll(ctx context.Context) error {
+	timeout, cancelFunc := context.WithTimeout(ctx, u.timeout)
 	defer cancelFunc()
 	_, err := u.collection.UpdateMany(timeout, primitive.M{}, primitive.M{"$set": primitive.M{"status": model.UserExamDestroyed}})
 	return err
@@ -999,25 +1008,26 @@ func (u *UserExamDaoService) DeleteAll() error {
 
 type AnswerPaperDaoService struct {
 	collection *mongo.Collection
-	logger     *xlog.Logger
+	// timeout 单次操作的超时时间。
+	timeout time.Duration
+	logger  *xlog.Logger
 }
 
 func NewAnswerPaperDaoService(config *utils.MongoConfig) *AnswerPaperDaoService {
 	logger := xlog.New("exam dao service")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(config.URI))
+	client, err := mongodb.Get(config)
 	if err != nil {
 		panic(err)
 	}
-	collection := client.Database(config.Database).Collection(dao.CollectionAnswerPaper)
+	collection := client.Collection(dao.CollectionAnswerPaper)
 	return &AnswerPaperDaoService{
 		collection,
+		client.OperationTimeout(),
 		logger,
 	}
 }
 
-func (a *AnswerPaperDaoService) Insert(answerPaper *model.AnswerPaperDo) error {
+func (a *AnswerPaperDaoService) Insert(ctx context.Context, answerPaper *model.AnswerPaperDo) error {
 	answerPaper.Id = primitive.NewObjectID().Hex()
 	answerPaper.Status = model.AnswerPaperAvailable
 	answerPaper.CreatedTime = time.Now()
@@ -1027,7 +1037,7 @@ func (a *AnswerPaperDaoService) Insert(answerPaper *model.AnswerPaperDo) error {
 		answerPaper.AnswerList[idx].CreatedTime = time.Now()
 		answerPaper.AnswerList[idx].UpdatedTime = time.Now()
 	}
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+	timeout, cancelFunc := context.WithTimeout(ctx, a.timeout)
 	defer cancelFunc()
 	_, err := a.collection.InsertOne(timeout, answerPaper)
 	if err != nil {
@@ -1037,8 +1047,8 @@ func (a *AnswerPaperDaoService) Insert(answerPaper *model.AnswerPaperDo) error {
 	return nil
 }
 
-func (a *AnswerPaperDaoService) Select(id string) (*model.AnswerPaperDo, error) {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (a *AnswerPaperDaoService) Select(ctx context.Context, id string) (*model.AnswerPaperDo, error) {
+	timeout, cancelFunc := context.WithTimeout(ctx, a.timeout)
 	defer cancelFunc()
 	one := a.collection.FindOne(timeout, primitive.M{"_id": id})
 	if err := one.Err(); err != nil {
@@ -1057,8 +1067,8 @@ func (a *AnswerPaperDaoService) Select(id string) (*model.AnswerPaperDo, error)
 	return &result, nil
 }
 
-func (a *AnswerPaperDaoService) SelectByExamIdUserId(examId, userId string) (*model.AnswerPaperDo, error) {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (a *AnswerPaperDaoService) SelectByExamIdUserId(ctx context.Context, examId, userId string) (*model.AnswerPaperDo, error) {
+	timeout, cancelFunc := context.WithTimeout(ctx, a.timeout)
 	defer cancelFunc()
 	one := a.collection.FindOne(timeout, primitive.M{"exam_id": examId, "user_id": userId})
 	if err := one.Err(); err != nil {
@@ -1077,8 +1087,8 @@ func (a *AnswerPaperDaoService) SelectByExamIdUserId(examId, userId string) (*mo
 	return &result, nil
 }
 
-func (a *AnswerPaperDaoService) ListByExamId0(examId string) ([]model.AnswerPaperDo, error) {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (a *AnswerPaperDaoService) ListByExamId0(ctx context.Context, examId string) ([]model.AnswerPaperDo, error) {
+	timeout, cancelFunc := context.WithTimeout(ctx, a.timeout)
 	defer cancelFunc()
 	cursor, err := a.collection.Find(timeout, primitive.M{"exam_id": examId}, &options.FindOptions{
 		Sort: primitive.M{"created_time": -1},
@@ -1113,32 +1123,32 @@ func (a *AnswerPaperDaoService) ListByExamId0(examId string) ([]model.AnswerPape
 	return results, nil
 }
 
-func (a *AnswerPaperDaoService) Update(answerPaper *model.AnswerPaperDo) error {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (a *AnswerPaperDaoService) Update(ctx context.Context, answerPaper *model.AnswerPaperDo) error {
+	timeout, cancelFunc := context.WithTimeout(ctx, a.timeout)
 	defer cancelFunc()
 	_, err := a.collection.UpdateByID(timeout, answerPaper.Id, primitive.M{"$set": answerPaper})
 	return err
 }
 
-func (a *AnswerPaperDaoService) Delete0(id string) error {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+func (a *AnswerPaperDaoService) Delete0(ctx context.Context, id string) error {
+	timeout, cancelFunc := context.WithTimeout(ctx, a.timeout)
 	defer cancelFunc()
 	_, err := a.collection.DeleteOne(timeout, primitive.M{"_id": id})
 	return err
 }
 
-func (a *AnswerPaperDaoService) Delete(id string) error {
-	answerPaper, err := a.Select(id)
+func (a *AnswerPaperDaoService) Delete(ctx context.Context, id string) error {
+	answerPaper, err := a.Select(ctx, id)
 	if err != nil {
 		return err
 	}
 	answerPaper.UpdatedTime = time.Now()
 	answerPaper.Status = model.QuestionUnavailable
-	return a.Update(answerPaper)
+	return a.Update(ctx, answerPaper)
 }
 
-func (a *AnswerPaperDaoService) DeleteAll() error {
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 15*time.Second)
+func (a *AnswerPaperDaoService) DeleteAll(ctx context.Context) error {
+	timeout, cancelFunc := context.WithTimeout(ctx, a.timeout)
 	defer cancelFunc()
 	_, err := a.collection.UpdateMany(timeout, primitive.M{}, primitive.M{"$set": primitive.M{"status": model.AnswerPaperUnavailable}})
 	return err
@@ -1146,28 +1156,29 @@ func (a *AnswerPaperDaoService) DeleteAll() error {
 
 type CheatingEventDaoService struct {
 	collection *mongo.Collection
-	logger     *xlog.Logger
+	// timeout 单次操作的超时时间。
+	timeout time.Duration
+	logger  *xlog.Logger
 }
 
 func NewCheatingEventDaoService(config *utils.MongoConfig) *CheatingEventDaoService {
 	logger := xlog.New("cheating event dao service")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(config.URI))
+	client, err := mongodb.Get(config)
 	if err != nil {
 		panic(err)
 	}
-	collection := client.Database(config.Database).Collection(dao.CollectionCheatingExam)
+	collection := client.Collection(dao.CollectionCheatingExam)
 	return &CheatingEventDaoService{
 		collection,
+		client.OperationTimeout(),
 		logger,
 	}
 }
 
-func (c *CheatingEventDaoService) Insert(cheatingEvent *model.CheatingEvent) error {
+func (c *CheatingEventDaoService) Insert(ctx context.Context, cheatingEvent *model.CheatingEvent) error {
 	cheatingEvent.Id = primitive.NewObjectID().Hex()
 	cheatingEvent.Timestamp = time.Now().UnixMilli()
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+	timeout, cancelFunc := context.WithTimeout(ctx, c.timeout)
 	defer cancelFunc()
 	_, err := c.collection.InsertOne(timeout, cheatingEvent)
 	if err != nil {
@@ -1177,8 +1188,8 @@ func (c *CheatingEventDaoService) Insert(cheatingEvent *model.CheatingEvent) err
 	return nil
 }
 
-func (c *CheatingEventDaoService) ListByExamIdUserId(examId, userId string, afterTimestamp, beforeTimestamp int64) ([]model.CheatingEvent, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (c *CheatingEventDaoService) ListByExamIdUserId(ctx context.Context, examId, userId string, afterTimestamp, beforeTimestamp int64) ([]model.CheatingEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 	match := primitive.M{"$match": primitive.D{
 		primitive.E{Key: "exam_id", Value: examId},
@@ -1224,3 +1235,25 @@ func (c *CheatingEventDaoService) ListByExamIdUserId(examId, userId string, afte
 	}
 	return results, nil
 }
+
+// pageFindOptions 返回按sortKey及_id排序分页查询的条件与选项，多查询一条用于判断是否还有下一页。
+func pageFindOptions(filter primitive.M, page model.PageQuery, sortKey string, desc bool) (primitive.M, *options.FindOptions) {
+	order := 1
+	if desc {
+		order = -1
+	}
+	query := filter
+	if page.Cursor != nil {
+		query = primitive.M{"$and": primitive.A{filter, model.PageCursorFilter(
+			model.PageSortField{Name: sortKey, Desc: desc, Value: page.Cursor.Time},
+			model.PageSortField{Name: "_id", Desc: desc, Value: page.Cursor.ID},
+		)}}
+	}
+	skip := int64(page.Skip())
+	limit := int64(page.PageSize + 1)
+	return query, &options.FindOptions{
+		Limit: &limit,
+		Skip:  &skip,
+		Sort:  primitive.D{{Key: sortKey, Value: order}, {Key: "_id", Value: order}},
+	}
+}
@@ -1,12 +1,15 @@
 package dao
 
 import (
+	"context"
 	"time"
 
 	"github.com/qiniu/x/xlog"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/db/dao"
@@ -14,15 +17,15 @@ import (
 
 // ImageFileDaoInterface
 type ImageFileDaoInterface interface {
-	InsertImageFile(xl *xlog.Logger, imageFile *model.ImageFileDo) (*model.ImageFileDo, error)
+	InsertImageFile(ctx context.Context, xl *xlog.Logger, imageFile *model.ImageFileDo) (*model.ImageFileDo, error)
 
-	SelectRecentImage(xl *xlog.Logger) (*model.ImageFileDo, error)
+	SelectRecentImage(ctx context.Context, xl *xlog.Logger) (*model.ImageFileDo, error)
 }
 
 // ImageFileDaoService
 type ImageFileDao struct {
-	client        *mgo.Session
-	imageFileColl *mgo.Collection
+	client        *mongodb.Manager
+	imageFileColl *mongodb.Collection
 	xl            *xlog.Logger
 }
 
@@ -30,12 +33,12 @@ func NewImageFileDao(xl *xlog.Logger, config *utils.MongoConfig) (*ImageFileDao,
 	if xl == nil {
 		xl = xlog.New("niu-cube-image-file")
 	}
-	client, err := mgo.Dial(config.URI)
+	client, err := mongodb.Get(config)
 	if err != nil {
 		xl.Errorf("failed to create mongo client, error: %v", err)
 		return nil, err
 	}
-	imageFileColl := client.DB(config.Database).C(dao.CollectionQiniuImageFile)
+	imageFileColl := client.C(dao.CollectionQiniuImageFile)
 	return &ImageFileDao{
 		client,
 		imageFileColl,
@@ -43,14 +46,15 @@ func NewImageFileDao(xl *xlog.Logger, config *utils.MongoConfig) (*ImageFileDao,
 	}, nil
 }
 
-func (b *ImageFileDao) InsertImageFile(xl *xlog.Logger, imageFile *model.ImageFileDo) (*model.ImageFileDo, error) {
+func (b *ImageFileDao) InsertImageFile(ctx context.Context, xl *xlog.Logger, imageFile *model.ImageFileDo) (*model.ImageFileDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "ImageFileDao.InsertImageFile").End()
 	imageFile.CreateTime = time.Now()
 	imageFile.UpdateTime = time.Now()
 	imageFile.ID = bson.NewObjectId().Hex()
-	err := b.imageFileColl.Insert(imageFile)
+	err := b.imageFileColl.WithContext(ctx).Insert(imageFile)
 	if err != nil {
 		xl.Error("insert into image_file failed.")
 		return nil, err
@@ -58,12 +62,13 @@ func (b *ImageFileDao) InsertImageFile(xl *xlog.Logger, imageFile *model.ImageFi
 	return imageFile, nil
 }
 
-func (b *ImageFileDao) SelectRecentImage(xl *xlog.Logger) (*model.ImageFileDo, error) {
+func (b *ImageFileDao) SelectRecentImage(ctx context.Context, xl *xlog.Logger) (*model.ImageFileDo, error) {
 	if xl == nil {
 		xl = b.xl
 	}
+	defer tracing.StartDAO(ctx, "ImageFileDao.SelectRecentImage").End()
 	var imageFile model.ImageFileDo
-	err := b.imageFileColl.Find(nil).Sort("-createTime").One(&imageFile)
+	err := b.imageFileColl.WithContext(ctx).Find(nil).Sort("-createTime").One(&imageFile)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Infof("can't find this records:[%s] from image_file.")
@@ -1,34 +1,37 @@
 package dao
 
 import (
+	"context"
 	"time"
 
 	"github.com/qiniu/x/xlog"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/db/dao"
 )
 
 type MovieDaoInterface interface {
-	Insert(xl *xlog.Logger, movieDo *model.MovieDo) error
+	Insert(ctx context.Context, xl *xlog.Logger, movieDo *model.MovieDo) error
 
-	Select(xl *xlog.Logger, movieId string) (*model.MovieDo, error)
+	Select(ctx context.Context, xl *xlog.Logger, movieId string) (*model.MovieDo, error)
 
-	SelectByNameDirector(xl *xlog.Logger, name, director string) (*model.MovieDo, error)
+	SelectByNameDirector(ctx context.Context, xl *xlog.Logger, name, director string) (*model.MovieDo, error)
 
-	ListAll(xl *xlog.Logger, pageNum, pageSize int) ([]model.MovieDo, int, error)
+	ListAll(ctx context.Context, xl *xlog.Logger, page model.PageQuery) ([]model.MovieDo, model.PageResult, error)
 
-	Update(xl *xlog.Logger, movieDo *model.MovieDo) error
+	Update(ctx context.Context, xl *xlog.Logger, movieDo *model.MovieDo) error
 
-	Delete(xl *xlog.Logger, movieId string) error
+	Delete(ctx context.Context, xl *xlog.Logger, movieId string) error
 }
 
 type MovieDaoService struct {
-	client    *mgo.Session
-	movieColl *mgo.Collection
+	client    *mongodb.Manager
+	movieColl *mongodb.Collection
 	xl        *xlog.Logger
 }
 
@@ -36,12 +39,12 @@ func NewMovieDaoService(xl *xlog.Logger, config *utils.MongoConfig) (*MovieDaoSe
 	if xl == nil {
 		xl = xlog.New("niu-cube-movie")
 	}
-	client, err := mgo.Dial(config.URI)
+	client, err := mongodb.Get(config)
 	if err != nil {
 		xl.Error("failed to create mongo client, error: %v", err)
 		return nil, err
 	}
-	movieColl := client.DB(config.Database).C(dao.CollectionMovie)
+	movieColl := client.C(dao.CollectionMovie)
 	return &MovieDaoService{
 		client,
 		movieColl,
@@ -49,14 +52,15 @@ func NewMovieDaoService(xl *xlog.Logger, config *utils.MongoConfig) (*MovieDaoSe
 	}, nil
 }
 
-func (m *MovieDaoService) Insert(xl *xlog.Logger, movieDo *model.MovieDo) error {
+func (m *MovieDaoService) Insert(ctx context.Context, xl *xlog.Logger, movieDo *model.MovieDo) error {
 	if xl == nil {
 		xl = m.xl
 	}
+	defer tracing.StartDAO(ctx, "MovieDaoService.Insert").End()
 	movieDo.Id = bson.NewObjectId().Hex()
 	movieDo.CreatedTime = time.Now()
 	movieDo.UpdatedTime = time.Now()
-	err := m.movieColl.Insert(movieDo)
+	err := m.movieColl.WithContext(ctx).Insert(movieDo)
 	if err != nil {
 		xl.Error("insert into movie failed.")
 		return err
@@ -64,12 +68,13 @@ func (m *MovieDaoService) Insert(xl *xlog.Logger, movieDo *model.MovieDo) error
 	return nil
 }
 
-func (m *MovieDaoService) Select(xl *xlog.Logger, movieId string) (*model.MovieDo, error) {
+func (m *MovieDaoService) Select(ctx context.Context, xl *xlog.Logger, movieId string) (*model.MovieDo, error) {
 	if xl == nil {
 		xl = m.xl
 	}
+	defer tracing.StartDAO(ctx, "MovieDaoService.Select").End()
 	result := model.MovieDo{}
-	err := m.movieColl.FindId(movieId).One(&result)
+	err := m.movieColl.WithContext(ctx).FindId(movieId).One(&result)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't find this record from movie")
@@ -81,12 +86,13 @@ func (m *MovieDaoService) Select(xl *xlog.Logger, movieId string) (*model.MovieD
 	return &result, nil
 }
 
-func (m *MovieDaoService) SelectByNameDirector(xl *xlog.Logger, name, director string) (*model.MovieDo, error) {
+func (m *MovieDaoService) SelectByNameDirector(ctx context.Context, xl *xlog.Logger, name, director string) (*model.MovieDo, error) {
 	if xl == nil {
 		xl = m.xl
 	}
+	defer tracing.StartDAO(ctx, "MovieDaoService.SelectByNameDirector").End()
 	result := model.MovieDo{}
-	err := m.movieColl.Find(bson.M{"status": model.MovieAvailable, "name": name, "director": director}).One(&result)
+	err := m.movieColl.WithContext(ctx).Find(bson.M{"status": model.MovieAvailable, "name": name, "director": director}).One(&result)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't find this record from movie")
@@ -98,32 +104,46 @@ func (m *MovieDaoService) SelectByNameDirector(xl *xlog.Logger, name, director s
 	return &result, nil
 }
 
-func (m *MovieDaoService) ListAll(xl *xlog.Logger, pageNum, pageSize int) ([]model.MovieDo, int, error) {
+// ListAll 按创建时间正序分页列出电影，page.Cursor不为空时从游标之后读取，不统计总数。
+func (m *MovieDaoService) ListAll(ctx context.Context, xl *xlog.Logger, page model.PageQuery) ([]model.MovieDo, model.PageResult, error) {
 	if xl == nil {
 		xl = m.xl
 	}
-	movieDos := make([]model.MovieDo, 0, pageSize)
-	skip := (pageNum - 1) * pageSize
-	limit := pageSize
-	err := m.movieColl.Find(bson.M{"status": model.MovieAvailable}).Sort("created_time").Skip(skip).Limit(limit).All(&movieDos)
+	defer tracing.StartDAO(ctx, "MovieDaoService.ListAll").End()
+	movieDos := make([]model.MovieDo, 0, page.PageSize+1)
+	filter := bson.M{"status": model.MovieAvailable}
+	query := filter
+	if page.Cursor != nil {
+		query = bson.M{"$and": []interface{}{filter, model.PageCursorFilter(
+			model.PageSortField{Name: "created_time", Value: page.Cursor.Time},
+			model.PageSortField{Name: "_id", Value: page.Cursor.ID},
+		)}}
+	}
+	err := m.movieColl.WithContext(ctx).Find(query).Sort("created_time", "_id").Skip(page.Skip()).Limit(page.PageSize + 1).All(&movieDos)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't find those records from song.")
 		} else {
 			xl.Error("list song failed.")
 		}
-		return nil, 0, err
+		return nil, model.PageResult{}, err
+	}
+	res := model.NewPageResult(page, len(movieDos), func(i int) *model.PageCursor {
+		return &model.PageCursor{Time: movieDos[i].CreatedTime, ID: movieDos[i].Id}
+	})
+	if page.Cursor == nil {
+		res.Total, _ = m.movieColl.WithContext(ctx).Find(filter).Count()
 	}
-	total, _ := m.movieColl.Find(bson.M{"status": model.MovieAvailable}).Count()
-	return movieDos, total, nil
+	return movieDos[:res.Count], res, nil
 }
 
-func (m *MovieDaoService) Update(xl *xlog.Logger, movieDo *model.MovieDo) error {
+func (m *MovieDaoService) Update(ctx context.Context, xl *xlog.Logger, movieDo *model.MovieDo) error {
 	if xl == nil {
 		xl = m.xl
 	}
+	defer tracing.StartDAO(ctx, "MovieDaoService.Update").End()
 	movieDo.UpdatedTime = time.Now()
-	err := m.movieColl.UpdateId(movieDo.Id, movieDo)
+	err := m.movieColl.WithContext(ctx).UpdateId(movieDo.Id, movieDo)
 	if err != nil {
 		xl.Error("update movie failed.")
 		return err
@@ -131,11 +151,12 @@ func (m *MovieDaoService) Update(xl *xlog.Logger, movieDo *model.MovieDo) error
 	return nil
 }
 
-func (m *MovieDaoService) Delete(xl *xlog.Logger, movieId string) error {
+func (m *MovieDaoService) Delete(ctx context.Context, xl *xlog.Logger, movieId string) error {
 	if xl == nil {
 		xl = m.xl
 	}
-	err := m.movieColl.RemoveId(movieId)
+	defer tracing.StartDAO(ctx, "MovieDaoService.Delete").End()
+	err := m.movieColl.WithContext(ctx).RemoveId(movieId)
 	if err != nil {
 		xl.Error("delete from movie failed.")
 		return err
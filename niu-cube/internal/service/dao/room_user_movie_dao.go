@@ -1,38 +1,41 @@
 package dao
 
 import (
+	"context"
 	"time"
 
 	"github.com/qiniu/x/xlog"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/db/dao"
 )
 
 type RoomUserMovieInterface interface {
-	Insert(xl *xlog.Logger, roomUserMovieDo *model.RoomUserMovieDo) error
+	Insert(ctx context.Context, xl *xlog.Logger, roomUserMovieDo *model.RoomUserMovieDo) error
 
-	Delete(xl *xlog.Logger, roomUserMovieId string) error
+	Delete(ctx context.Context, xl *xlog.Logger, roomUserMovieId string) error
 
-	Update(xl *xlog.Logger, roomUserMovieDo *model.RoomUserMovieDo) error
+	Update(ctx context.Context, xl *xlog.Logger, roomUserMovieDo *model.RoomUserMovieDo) error
 
-	Select(xl *xlog.Logger, roomUserMovieId string) (*model.RoomUserMovieDo, error)
+	Select(ctx context.Context, xl *xlog.Logger, roomUserMovieId string) (*model.RoomUserMovieDo, error)
 
-	SelectByRoomIdMovieId(xl *xlog.Logger, roomId, movieId string) (*model.RoomUserMovieDo, error)
+	SelectByRoomIdMovieId(ctx context.Context, xl *xlog.Logger, roomId, movieId string) (*model.RoomUserMovieDo, error)
 
-	SelectByRoomIdUserId(xl *xlog.Logger, roomId, userId string) (*model.RoomUserMovieDo, error)
+	SelectByRoomIdUserId(ctx context.Context, xl *xlog.Logger, roomId, userId string) (*model.RoomUserMovieDo, error)
 
-	SelectByRoomIdPlaying(xl *xlog.Logger, roomId string) (*model.RoomUserMovieDo, error)
+	SelectByRoomIdPlaying(ctx context.Context, xl *xlog.Logger, roomId string) (*model.RoomUserMovieDo, error)
 
-	ListByRoomId(xl *xlog.Logger, roomId string, pageNum, pageSize int) ([]model.RoomUserMovieDo, int, error)
+	ListByRoomId(ctx context.Context, xl *xlog.Logger, roomId string, pageNum, pageSize int) ([]model.RoomUserMovieDo, int, error)
 }
 
 type RoomUserMovieDaoService struct {
-	client            *mgo.Session
-	roomUserMovieColl *mgo.Collection
+	client            *mongodb.Manager
+	roomUserMovieColl *mongodb.Collection
 	xl                *xlog.Logger
 }
 
@@ -40,12 +43,12 @@ func NewRoomUserMovieService(xl *xlog.Logger, config *utils.MongoConfig) (*RoomU
 	if xl == nil {
 		xl = xlog.New("niu-cube-room-user-movie")
 	}
-	client, err := mgo.Dial(config.URI)
+	client, err := mongodb.Get(config)
 	if err != nil {
 		xl.Error("failed to create mongo client, error: %v", err)
 		return nil, err
 	}
-	roomUserMovieColl := client.DB(config.Database).C(dao.CollectionRoomUserMovie)
+	roomUserMovieColl := client.C(dao.CollectionRoomUserMovie)
 	return &RoomUserMovieDaoService{
 		client,
 		roomUserMovieColl,
@@ -53,14 +56,15 @@ func NewRoomUserMovieService(xl *xlog.Logger, config *utils.MongoConfig) (*RoomU
 	}, nil
 }
 
-func (r *RoomUserMovieDaoService) Insert(xl *xlog.Logger, roomUserMovieDo *model.RoomUserMovieDo) error {
+func (r *RoomUserMovieDaoService) Insert(ctx context.Context, xl *xlog.Logger, roomUserMovieDo *model.RoomUserMovieDo) error {
 	if xl == nil {
 		xl = r.xl
 	}
+	defer tracing.StartDAO(ctx, "RoomUserMovieDaoService.Insert").End()
 	roomUserMovieDo.Id = bson.NewObjectId().Hex()
 	roomUserMovieDo.CreatedTime = time.Now()
 	roomUserMovieDo.UpdatedTime = time.Now()
-	err := r.roomUserMovieColl.Insert(roomUserMovieDo)
+	err := r.roomUserMovieColl.WithContext(ctx).Insert(roomUserMovieDo)
 	if err != nil {
 		xl.Error("insert into room_user_movie failed.")
 		return err
@@ -68,11 +72,12 @@ func (r *RoomUserMovieDaoService) Insert(xl *xlog.Logger, roomUserMovieDo *model
 	return nil
 }
 
-func (r *RoomUserMovieDaoService) Delete(xl *xlog.Logger, roomUserMovieId string) error {
+func (r *RoomUserMovieDaoService) Delete(ctx context.Context, xl *xlog.Logger, roomUserMovieId string) error {
 	if xl == nil {
 		xl = r.xl
 	}
-	err := r.roomUserMovieColl.RemoveId(roomUserMovieId)
+	defer tracing.StartDAO(ctx, "RoomUserMovieDaoService.Delete").End()
+	err := r.roomUserMovieColl.WithContext(ctx).RemoveId(roomUserMovieId)
 	if err != nil {
 		xl.Error("delete from room_user_movie failed.")
 		return err
@@ -80,12 +85,13 @@ func (r *RoomUserMovieDaoService) Delete(xl *xlog.Logger, roomUserMovieId string
 	return nil
 }
 
-func (r *RoomUserMovieDaoService) Update(xl *xlog.Logger, roomUserMovieDo *model.RoomUserMovieDo) error {
+func (r *RoomUserMovieDaoService) Update(ctx context.Context, xl *xlog.Logger, roomUserMovieDo *model.RoomUserMovieDo) error {
 	if xl == nil {
 		xl = r.xl
 	}
+	defer tracing.StartDAO(ctx, "RoomUserMovieDaoService.Update").End()
 	roomUserMovieDo.UpdatedTime = time.Now()
-	err := r.roomUserMovieColl.UpdateId(roomUserMovieDo.Id, roomUserMovieDo)
+	err := r.roomUserMovieColl.WithContext(ctx).UpdateId(roomUserMovieDo.Id, roomUserMovieDo)
 	if err != nil {
 		xl.Error("update room_user_movie failed.")
 		return err
@@ -93,12 +99,13 @@ func (r *RoomUserMovieDaoService) Update(xl *xlog.Logger, roomUserMovieDo *model
 	return nil
 }
 
-func (r *RoomUserMovieDaoService) Select(xl *xlog.Logger, roomUserMovieId string) (*model.RoomUserMovieDo, error) {
+func (r *RoomUserMovieDaoService) Select(ctx context.Context, xl *xlog.Logger, roomUserMovieId string) (*model.RoomUserMovieDo, error) {
 	if xl == nil {
 		xl = r.xl
 	}
+	defer tracing.StartDAO(ctx, "RoomUserMovieDaoService.Select").End()
 	roomUserMovieDo := model.RoomUserMovieDo{}
-	err := r.roomUserMovieColl.FindId(roomUserMovieId).One(&roomUserMovieDo)
+	err := r.roomUserMovieColl.WithContext(ctx).FindId(roomUserMovieId).One(&roomUserMovieDo)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't find this record from room_user_movie")
@@ -110,12 +117,13 @@ func (r *RoomUserMovieDaoService) Select(xl *xlog.Logger, roomUserMovieId string
 	return &roomUserMovieDo, nil
 }
 
-func (r *RoomUserMovieDaoService) SelectByRoomIdMovieId(xl *xlog.Logger, roomId, movieId string) (*model.RoomUserMovieDo, error) {
+func (r *RoomUserMovieDaoService) SelectByRoomIdMovieId(ctx context.Context, xl *xlog.Logger, roomId, movieId string) (*model.RoomUserMovieDo, error) {
 	if xl == nil {
 		xl = r.xl
 	}
+	defer tracing.StartDAO(ctx, "RoomUserMovieDaoService.SelectByRoomIdMovieId").End()
 	roomUserMovieDo := model.RoomUserMovieDo{}
-	err := r.roomUserMovieColl.Find(bson.M{"room_id": roomId, "movie_id": movieId, "status": model.RoomUserMovieAvailable}).One(&roomUserMovieDo)
+	err := r.roomUserMovieColl.WithContext(ctx).Find(bson.M{"room_id": roomId, "movie_id": movieId, "status": model.RoomUserMovieAvailable}).One(&roomUserMovieDo)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't find this record from room_user_movie")
@@ -127,12 +135,13 @@ func (r *RoomUserMovieDaoService) SelectByRoomIdMovieId(xl *xlog.Logger, roomId,
 	return &roomUserMovieDo, nil
 }
 
-func (r *RoomUserMovieDaoService) SelectByRoomIdUserId(xl *xlog.Logger, roomId, userId string) (*model.RoomUserMovieDo, error) {
+func (r *RoomUserMovieDaoService) SelectByRoomIdUserId(ctx context.Context, xl *xlog.Logger, roomId, userId string) (*model.RoomUserMovieDo, error) {
 	if xl == nil {
 		xl = r.xl
 	}
+	defer tracing.StartDAO(ctx, "RoomUserMovieDaoService.SelectByRoomIdUserId").End()
 	roomUserMovieDo := model.RoomUserMovieDo{}
-	err := r.roomUserMovieColl.Find(bson.M{"room_id": roomId, "user_id": userId, "status": model.RoomUserMovieAvailable}).One(&roomUserMovieDo)
+	err := r.roomUserMovieColl.WithContext(ctx).Find(bson.M{"room_id": roomId, "user_id": userId, "status": model.RoomUserMovieAvailable}).One(&roomUserMovieDo)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't find this record from room_user_movie")
@@ -144,12 +153,13 @@ func (r *RoomUserMovieDaoService) SelectByRoomIdUserId(xl *xlog.Logger, roomId,
 	return &roomUserMovieDo, nil
 }
 
-func (r *RoomUserMovieDaoService) SelectByRoomIdPlaying(xl *xlog.Logger, roomId string) (*model.RoomUserMovieDo, error) {
+func (r *RoomUserMovieDaoService) SelectByRoomIdPlaying(ctx context.Context, xl *xlog.Logger, roomId string) (*model.RoomUserMovieDo, error) {
 	if xl == nil {
 		xl = r.xl
 	}
+	defer tracing.StartDAO(ctx, "RoomUserMovieDaoService.SelectByRoomIdPlaying").End()
 	result := model.RoomUserMovieDo{}
-	err := r.roomUserMovieColl.Find(bson.M{"status": model.RoomUserMovieAvailable, "is_playing": true, "room_id": roomId}).One(&result)
+	err := r.roomUserMovieColl.WithContext(ctx).Find(bson.M{"status": model.RoomUserMovieAvailable, "is_playing": true, "room_id": roomId}).One(&result)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't find this record from room_user_movie")
@@ -161,14 +171,15 @@ func (r *RoomUserMovieDaoService) SelectByRoomIdPlaying(xl *xlog.Logger, roomId
 	return &result, nil
 }
 
-func (r *RoomUserMovieDaoService) ListByRoomId(xl *xlog.Logger, roomId string, pageNum, pageSize int) ([]model.RoomUserMovieDo, int, error) {
+func (r *RoomUserMovieDaoService) ListByRoomId(ctx context.Context, xl *xlog.Logger, roomId string, pageNum, pageSize int) ([]model.RoomUserMovieDo, int, error) {
 	if xl == nil {
 		xl = r.xl
 	}
+	defer tracing.StartDAO(ctx, "RoomUserMovieDaoService.ListByRoomId").End()
 	roomUserMovieDos := make([]model.RoomUserMovieDo, 0, pageSize)
 	skip := (pageNum - 1) * pageSize
 	limit := pageSize
-	err := r.roomUserMovieColl.Find(bson.M{"room_id": roomId, "status": model.RoomUserMovieAvailable}).Sort("created_time").Skip(skip).Limit(limit).All(&roomUserMovieDos)
+	err := r.roomUserMovieColl.WithContext(ctx).Find(bson.M{"room_id": roomId, "status": model.RoomUserMovieAvailable}).Sort("created_time").Skip(skip).Limit(limit).All(&roomUserMovieDos)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't find those records from song.")
@@ -177,6 +188,6 @@ func (r *RoomUserMovieDaoService) ListByRoomId(xl *xlog.Logger, roomId string, p
 		}
 		return nil, 0, err
 	}
-	total, _ := r.roomUserMovieColl.Find(bson.M{"room_id": roomId, "status": model.RoomUserMovieAvailable}).Count()
+	total, _ := r.roomUserMovieColl.WithContext(ctx).Find(bson.M{"room_id": roomId, "status": model.RoomUserMovieAvailable}).Count()
 	return roomUserMovieDos, total, nil
 }
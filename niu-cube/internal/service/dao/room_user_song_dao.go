@@ -1,34 +1,37 @@
 package dao
 
 import (
+	"context"
 	"time"
 
 	"github.com/qiniu/x/xlog"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/db/dao"
 )
 
 type RoomUserSongDaoInterface interface {
-	Insert(xl *xlog.Logger, roomUserSongDo *model.RoomUserSongDo) (*model.RoomUserSongDo, error)
+	Insert(ctx context.Context, xl *xlog.Logger, roomUserSongDo *model.RoomUserSongDo) (*model.RoomUserSongDo, error)
 
-	Select(xl *xlog.Logger, id string) (*model.RoomUserSongDo, error)
+	Select(ctx context.Context, xl *xlog.Logger, id string) (*model.RoomUserSongDo, error)
 
-	SelectByRoomIdSongId(xl *xlog.Logger, roomId, songId string) (*model.RoomUserSongDo, error)
+	SelectByRoomIdSongId(ctx context.Context, xl *xlog.Logger, roomId, songId string) (*model.RoomUserSongDo, error)
 
-	SelectByRoomIdUserId(xl *xlog.Logger, roomId, userId string) (*model.RoomUserSongDo, error)
+	SelectByRoomIdUserId(ctx context.Context, xl *xlog.Logger, roomId, userId string) (*model.RoomUserSongDo, error)
 
-	Update(xl *xlog.Logger, roomUserSongDo *model.RoomUserSongDo) error
+	Update(ctx context.Context, xl *xlog.Logger, roomUserSongDo *model.RoomUserSongDo) error
 
-	ListByRoomId(xl *xlog.Logger, roomId string, pageNum, pageSize int) ([]model.RoomUserSongDo, int, int, error)
+	ListByRoomId(ctx context.Context, xl *xlog.Logger, roomId string, pageNum, pageSize int) ([]model.RoomUserSongDo, int, int, error)
 }
 
 type RoomUserSongDaoService struct {
-	client           *mgo.Session
-	roomUserSongColl *mgo.Collection
+	client           *mongodb.Manager
+	roomUserSongColl *mongodb.Collection
 	xl               *xlog.Logger
 }
 
@@ -36,12 +39,12 @@ func NewRoomUserSongDaoService(xl *xlog.Logger, config *utils.MongoConfig) (*Roo
 	if xl == nil {
 		xl = xlog.New("niu-cube-room-user-song")
 	}
-	client, err := mgo.Dial(config.URI)
+	client, err := mongodb.Get(config)
 	if err != nil {
 		xl.Errorf("failed to create mongo client, error: %v", err)
 		return nil, err
 	}
-	roomUserSongColl := client.DB(config.Database).C(dao.CollectionRoomUserSong)
+	roomUserSongColl := client.C(dao.CollectionRoomUserSong)
 	return &RoomUserSongDaoService{
 		client,
 		roomUserSongColl,
@@ -49,14 +52,15 @@ func NewRoomUserSongDaoService(xl *xlog.Logger, config *utils.MongoConfig) (*Roo
 	}, nil
 }
 
-func (r *RoomUserSongDaoService) Insert(xl *xlog.Logger, roomUserSongDo *model.RoomUserSongDo) (*model.RoomUserSongDo, error) {
+func (r *RoomUserSongDaoService) Insert(ctx context.Context, xl *xlog.Logger, roomUserSongDo *model.RoomUserSongDo) (*model.RoomUserSongDo, error) {
 	if xl == nil {
 		xl = r.xl
 	}
+	defer tracing.StartDAO(ctx, "RoomUserSongDaoService.Insert").End()
 	roomUserSongDo.Id = bson.NewObjectId().Hex()
 	roomUserSongDo.CreatedTime = time.Now()
 	roomUserSongDo.UpdatedTime = time.Now()
-	err := r.roomUserSongColl.Insert(roomUserSongDo)
+	err := r.roomUserSongColl.WithContext(ctx).Insert(roomUserSongDo)
 	if err != nil {
 		xl.Error("insert into room_user_song failed.")
 		return nil, err
@@ -64,12 +68,13 @@ func (r *RoomUserSongDaoService) Insert(xl *xlog.Logger, roomUserSongDo *model.R
 	return roomUserSongDo, nil
 }
 
-func (r *RoomUserSongDaoService) Select(xl *xlog.Logger, id string) (*model.RoomUserSongDo, error) {
+func (r *RoomUserSongDaoService) Select(ctx context.Context, xl *xlog.Logger, id string) (*model.RoomUserSongDo, error) {
 	if xl == nil {
 		xl = r.xl
 	}
+	defer tracing.StartDAO(ctx, "RoomUserSongDaoService.Select").End()
 	var roomUserSongDo model.RoomUserSongDo
-	err := r.roomUserSongColl.FindId(id).One(&roomUserSongDo)
+	err := r.roomUserSongColl.WithContext(ctx).FindId(id).One(&roomUserSongDo)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't find this record from room_user_song.")
@@ -81,12 +86,13 @@ func (r *RoomUserSongDaoService) Select(xl *xlog.Logger, id string) (*model.Room
 	return &roomUserSongDo, nil
 }
 
-func (r *RoomUserSongDaoService) SelectByRoomIdSongId(xl *xlog.Logger, roomId, songId string) (*model.RoomUserSongDo, error) {
+func (r *RoomUserSongDaoService) SelectByRoomIdSongId(ctx context.Context, xl *xlog.Logger, roomId, songId string) (*model.RoomUserSongDo, error) {
 	if xl == nil {
 		xl = r.xl
 	}
+	defer tracing.StartDAO(ctx, "RoomUserSongDaoService.SelectByRoomIdSongId").End()
 	var roomUserSongDo model.RoomUserSongDo
-	err := r.roomUserSongColl.Find(bson.M{"room_id": roomId, "song_id": songId}).One(&roomUserSongDo)
+	err := r.roomUserSongColl.WithContext(ctx).Find(bson.M{"room_id": roomId, "song_id": songId}).One(&roomUserSongDo)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't find this record from room_user_song.")
@@ -98,12 +104,13 @@ func (r *RoomUserSongDaoService) SelectByRoomIdSongId(xl *xlog.Logger, roomId, s
 	return &roomUserSongDo, nil
 }
 
-func (r *RoomUserSongDaoService) SelectByRoomIdUserId(xl *xlog.Logger, roomId, userId string) (*model.RoomUserSongDo, error) {
+func (r *RoomUserSongDaoService) SelectByRoomIdUserId(ctx context.Context, xl *xlog.Logger, roomId, userId string) (*model.RoomUserSongDo, error) {
 	if xl == nil {
 		xl = r.xl
 	}
+	defer tracing.StartDAO(ctx, "RoomUserSongDaoService.SelectByRoomIdUserId").End()
 	var roomUserSongDo model.RoomUserSongDo
-	err := r.roomUserSongColl.Find(bson.M{"room_id": roomId, "user_id": userId}).One(&roomUserSongDo)
+	err := r.roomUserSongColl.WithContext(ctx).Find(bson.M{"room_id": roomId, "user_id": userId}).One(&roomUserSongDo)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't find this record from room_user_song.")
@@ -115,12 +122,13 @@ func (r *RoomUserSongDaoService) SelectByRoomIdUserId(xl *xlog.Logger, roomId, u
 	return &roomUserSongDo, nil
 }
 
-func (r *RoomUserSongDaoService) Update(xl *xlog.Logger, roomUserSongDo *model.RoomUserSongDo) error {
+func (r *RoomUserSongDaoService) Update(ctx context.Context, xl *xlog.Logger, roomUserSongDo *model.RoomUserSongDo) error {
 	if xl == nil {
 		xl = r.xl
 	}
+	defer tracing.StartDAO(ctx, "RoomUserSongDaoService.Update").End()
 	roomUserSongDo.UpdatedTime = time.Now()
-	err := r.roomUserSongColl.UpdateId(roomUserSongDo.Id, roomUserSongDo)
+	err := r.roomUserSongColl.WithContext(ctx).UpdateId(roomUserSongDo.Id, roomUserSongDo)
 	if err != nil {
 		xl.Error("update room_user_song failed.")
 		return err
@@ -128,14 +136,15 @@ func (r *RoomUserSongDaoService) Update(xl *xlog.Logger, roomUserSongDo *model.R
 	return nil
 }
 
-func (r *RoomUserSongDaoService) ListByRoomId(xl *xlog.Logger, roomId string, pageNum, pageSize int) ([]model.RoomUserSongDo, int, int, error) {
+func (r *RoomUserSongDaoService) ListByRoomId(ctx context.Context, xl *xlog.Logger, roomId string, pageNum, pageSize int) ([]model.RoomUserSongDo, int, int, error) {
 	if xl == nil {
 		xl = r.xl
 	}
+	defer tracing.StartDAO(ctx, "RoomUserSongDaoService.ListByRoomId").End()
 	var roomUserSongDos []model.RoomUserSongDo
 	skip := (pageNum - 1) * pageSize
 	limit := pageSize
-	err := r.roomUserSongColl.Find(bson.M{"room_id": roomId, "status": model.RoomUserSongAvailable}).Sort("-created_time").Skip(skip).Limit(limit).All(&roomUserSongDos)
+	err := r.roomUserSongColl.WithContext(ctx).Find(bson.M{"room_id": roomId, "status": model.RoomUserSongAvailable}).Sort("-created_time").Skip(skip).Limit(limit).All(&roomUserSongDos)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't find those records from room_user_song.")
@@ -144,6 +153,6 @@ func (r *RoomUserSongDaoService) ListByRoomId(xl *xlog.Logger, roomId string, pa
 		}
 		return nil, 0, 0, err
 	}
-	total, _ := r.roomUserSongColl.Find(bson.M{"room_id": roomId, "status": model.RoomUserSongAvailable}).Count()
+	total, _ := r.roomUserSongColl.WithContext(ctx).Find(bson.M{"room_id": roomId, "status": model.RoomUserSongAvailable}).Count()
 	return roomUserSongDos, total, len(roomUserSongDos), nil
 }
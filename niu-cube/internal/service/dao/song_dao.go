@@ -1,39 +1,42 @@
 package dao
 
 import (
+	"context"
 	"time"
 
 	"github.com/qiniu/x/xlog"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/db/dao"
 )
 
 type SongDaoInterface interface {
-	Insert(xl *xlog.Logger, songDo *model.SongDo) (*model.SongDo, error)
+	Insert(ctx context.Context, xl *xlog.Logger, songDo *model.SongDo) (*model.SongDo, error)
 
-	Update(xl *xlog.Logger, songDo *model.SongDo) error
+	Update(ctx context.Context, xl *xlog.Logger, songDo *model.SongDo) error
 
-	Select(xl *xlog.Logger, songId string) (*model.SongDo, error)
+	Select(ctx context.Context, xl *xlog.Logger, songId string) (*model.SongDo, error)
 
-	SelectByNameAndAuthor(xl *xlog.Logger, songName, author string) (*model.SongDo, error)
+	SelectByNameAndAuthor(ctx context.Context, xl *xlog.Logger, songName, author string) (*model.SongDo, error)
 
-	Delete(xl *xlog.Logger, songId string) error
+	Delete(ctx context.Context, xl *xlog.Logger, songId string) error
 
-	ListByNameFuzzy(xl *xlog.Logger, songName string) ([]model.SongDo, error)
+	ListByNameFuzzy(ctx context.Context, xl *xlog.Logger, songName string) ([]model.SongDo, error)
 
-	ListByAuthorFuzzy(xl *xlog.Logger, authorName string) ([]model.SongDo, error)
+	ListByAuthorFuzzy(ctx context.Context, xl *xlog.Logger, authorName string) ([]model.SongDo, error)
 
-	ListAll(xl *xlog.Logger, pageNum, pageSize int) ([]model.SongDo, int, int, error)
+	ListAll(ctx context.Context, xl *xlog.Logger, page model.PageQuery) ([]model.SongDo, model.PageResult, error)
 }
 
 type SongDaoService struct {
-	client           *mgo.Session
-	songColl         *mgo.Collection
-	roomUserSongColl *mgo.Collection
+	client           *mongodb.Manager
+	songColl         *mongodb.Collection
+	roomUserSongColl *mongodb.Collection
 	xl               *xlog.Logger
 }
 
@@ -41,13 +44,13 @@ func NewSongDaoService(xl *xlog.Logger, config *utils.MongoConfig) (*SongDaoServ
 	if xl == nil {
 		xl = xlog.New("niu-cube-song")
 	}
-	client, err := mgo.Dial(config.URI)
+	client, err := mongodb.Get(config)
 	if err != nil {
 		xl.Error("failed to create mongo client, error: %v", err)
 		return nil, err
 	}
-	songColl := client.DB(config.Database).C(dao.CollectionSong)
-	roomUserSongColl := client.DB(config.Database).C(dao.CollectionRoomUserSong)
+	songColl := client.C(dao.CollectionSong)
+	roomUserSongColl := client.C(dao.CollectionRoomUserSong)
 	return &SongDaoService{
 		client,
 		songColl,
@@ -56,14 +59,15 @@ func NewSongDaoService(xl *xlog.Logger, config *utils.MongoConfig) (*SongDaoServ
 	}, nil
 }
 
-func (s *SongDaoService) Insert(xl *xlog.Logger, songDo *model.SongDo) (*model.SongDo, error) {
+func (s *SongDaoService) Insert(ctx context.Context, xl *xlog.Logger, songDo *model.SongDo) (*model.SongDo, error) {
 	if xl == nil {
 		xl = s.xl
 	}
+	defer tracing.StartDAO(ctx, "SongDaoService.Insert").End()
 	songDo.Id = bson.NewObjectId().Hex()
 	songDo.CreatedTime = time.Now()
 	songDo.UpdatedTime = time.Now()
-	err := s.songColl.Insert(songDo)
+	err := s.songColl.WithContext(ctx).Insert(songDo)
 	if err != nil {
 		xl.Error("insert into song failed.")
 		return nil, err
@@ -71,12 +75,13 @@ func (s *SongDaoService) Insert(xl *xlog.Logger, songDo *model.SongDo) (*model.S
 	return songDo, nil
 }
 
-func (s *SongDaoService) Update(xl *xlog.Logger, songDo *model.SongDo) error {
+func (s *SongDaoService) Update(ctx context.Context, xl *xlog.Logger, songDo *model.SongDo) error {
 	if xl == nil {
 		xl = s.xl
 	}
+	defer tracing.StartDAO(ctx, "SongDaoService.Update").End()
 	songDo.UpdatedTime = time.Now()
-	err := s.songColl.UpdateId(songDo.Id, songDo)
+	err := s.songColl.WithContext(ctx).UpdateId(songDo.Id, songDo)
 	if err != nil {
 		xl.Error("update song failed.")
 		return err
@@ -84,12 +89,13 @@ func (s *SongDaoService) Update(xl *xlog.Logger, songDo *model.SongDo) error {
 	return nil
 }
 
-func (s *SongDaoService) Select(xl *xlog.Logger, songId string) (*model.SongDo, error) {
+func (s *SongDaoService) Select(ctx context.Context, xl *xlog.Logger, songId string) (*model.SongDo, error) {
 	if xl == nil {
 		xl = s.xl
 	}
+	defer tracing.StartDAO(ctx, "SongDaoService.Select").End()
 	var songDo model.SongDo
-	err := s.songColl.FindId(songId).One(&songDo)
+	err := s.songColl.WithContext(ctx).FindId(songId).One(&songDo)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't find this record from song")
@@ -101,12 +107,13 @@ func (s *SongDaoService) Select(xl *xlog.Logger, songId string) (*model.SongDo,
 	return &songDo, nil
 }
 
-func (s *SongDaoService) SelectByNameAndAuthor(xl *xlog.Logger, songName, author string) (*model.SongDo, error) {
+func (s *SongDaoService) SelectByNameAndAuthor(ctx context.Context, xl *xlog.Logger, songName, author string) (*model.SongDo, error) {
 	if xl == nil {
 		xl = s.xl
 	}
+	defer tracing.StartDAO(ctx, "SongDaoService.SelectByNameAndAuthor").End()
 	var songDo model.SongDo
-	err := s.songColl.Find(bson.M{"name": songName, "author": author, "status": model.SongAvailable}).One(&songDo)
+	err := s.songColl.WithContext(ctx).Find(bson.M{"name": songName, "author": author, "status": model.SongAvailable}).One(&songDo)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't find this record.")
@@ -118,11 +125,12 @@ func (s *SongDaoService) SelectByNameAndAuthor(xl *xlog.Logger, songName, author
 	return &songDo, nil
 }
 
-func (s *SongDaoService) Delete(xl *xlog.Logger, songId string) error {
+func (s *SongDaoService) Delete(ctx context.Context, xl *xlog.Logger, songId string) error {
 	if xl == nil {
 		xl = s.xl
 	}
-	err := s.songColl.RemoveId(songId)
+	defer tracing.StartDAO(ctx, "SongDaoService.Delete").End()
+	err := s.songColl.WithContext(ctx).RemoveId(songId)
 	if err != nil {
 		xl.Error("delete from song failed.")
 		return err
@@ -131,31 +139,44 @@ func (s *SongDaoService) Delete(xl *xlog.Logger, songId string) error {
 }
 
 // ListByNameFuzzy 这个方法暂时没有需求，做保留
-func (s *SongDaoService) ListByNameFuzzy(xl *xlog.Logger, songName string) ([]model.SongDo, error) {
+func (s *SongDaoService) ListByNameFuzzy(ctx context.Context, xl *xlog.Logger, songName string) ([]model.SongDo, error) {
 	panic("implement me")
 }
 
 // ListByAuthorFuzzy 同上原因
-func (s *SongDaoService) ListByAuthorFuzzy(xl *xlog.Logger, authorName string) ([]model.SongDo, error) {
+func (s *SongDaoService) ListByAuthorFuzzy(ctx context.Context, xl *xlog.Logger, authorName string) ([]model.SongDo, error) {
 	panic("implement me")
 }
 
-func (s *SongDaoService) ListAll(xl *xlog.Logger, pageNum, pageSize int) ([]model.SongDo, int, int, error) {
+// ListAll 按创建时间正序分页列出歌曲，page.Cursor不为空时从游标之后读取，不统计总数。
+func (s *SongDaoService) ListAll(ctx context.Context, xl *xlog.Logger, page model.PageQuery) ([]model.SongDo, model.PageResult, error) {
 	if xl == nil {
 		xl = s.xl
 	}
-	songDos := make([]model.SongDo, 0, pageSize)
-	skip := (pageNum - 1) * pageSize
-	limit := pageSize
-	err := s.songColl.Find(bson.M{"status": model.SongAvailable}).Sort("created_time").Skip(skip).Limit(limit).All(&songDos)
+	defer tracing.StartDAO(ctx, "SongDaoService.ListAll").End()
+	songDos := make([]model.SongDo, 0, page.PageSize+1)
+	filter := bson.M{"status": model.SongAvailable}
+	query := filter
+	if page.Cursor != nil {
+		query = bson.M{"$and": []interface{}{filter, model.PageCursorFilter(
+			model.PageSortField{Name: "created_time", Value: page.Cursor.Time},
+			model.PageSortField{Name: "_id", Value: page.Cursor.ID},
+		)}}
+	}
+	err := s.songColl.WithContext(ctx).Find(query).Sort("created_time", "_id").Skip(page.Skip()).Limit(page.PageSize + 1).All(&songDos)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Info("can't find those records from song.")
 		} else {
 			xl.Error("list song failed.")
 		}
-		return nil, 0, 0, err
+		return nil, model.PageResult{}, err
+	}
+	res := model.NewPageResult(page, len(songDos), func(i int) *model.PageCursor {
+		return &model.PageCursor{Time: songDos[i].CreatedTime, ID: songDos[i].Id}
+	})
+	if page.Cursor == nil {
+		res.Total, _ = s.songColl.WithContext(ctx).Find(filter).Count()
 	}
-	total, _ := s.songColl.Find(bson.M{"status": model.SongAvailable}).Count()
-	return songDos, total, len(songDos), nil
+	return songDos[:res.Count], res, nil
 }
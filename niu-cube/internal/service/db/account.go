@@ -1,7 +1,14 @@
 package db
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
+	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
 	model "github.com/solutions/niu-cube/internal/protodef/model"
 	dao "github.com/solutions/niu-cube/internal/service/db/dao"
 	"time"
@@ -12,40 +19,99 @@ import (
 	"gopkg.in/mgo.v2/bson"
 )
 
+var (
+	// AccessTokenDefaultExpire 访问token的默认有效时间。
+	AccessTokenDefaultExpire = 2 * time.Hour
+	// RefreshTokenDefaultExpire 刷新token的默认有效时间。
+	RefreshTokenDefaultExpire = 30 * 24 * time.Hour
+	// MaxSessionsDefault 每个账号默认允许同时在线的设备数。
+	MaxSessionsDefault = 5
+)
+
+// LoginClaims 登录token中携带的声明。
+type LoginClaims struct {
+	UserID string `json:"userID"`
+	// SessionID 签发该token的登录会话。
+	SessionID string `json:"sid"`
+	// Phone 签发时账号的手机号，只用于记录日志，不作为身份依据。
+	Phone string `json:"phone,omitempty"`
+	jwt.StandardClaims
+}
+
 // AccountController 用户注册、更新信息、登录、退出登录等操作。
 type AccountService struct {
-	mongoClient      *mgo.Session
-	accountColl      *mgo.Collection
-	accountTokenColl *mgo.Collection
-	xl               *xlog.Logger
+	mongoClient        *mongodb.Manager
+	accountColl        *mongodb.Collection
+	accountTokenColl   *mongodb.Collection
+	emailTokenColl     *mongodb.Collection
+	jwtKey             []byte
+	accessTokenExpire  time.Duration
+	refreshTokenExpire time.Duration
+	maxSessions        int
+	revocation         *TokenRevocationList
+	xl                 *xlog.Logger
 }
 
 func NewAccountService(conf utils.MongoConfig, xl *xlog.Logger) (*AccountService, error) {
 	if xl == nil {
 		xl = xlog.New("niu-cube-account-db")
 	}
-	mongoClient, err := mgo.Dial(conf.URI + "/" + conf.Database)
+	if utils.DefaultConf.JwtKey == "" {
+		xl.Errorf("jwt_key is not configured")
+		return nil, fmt.Errorf("jwt_key is not configured")
+	}
+	accessTokenExpire := AccessTokenDefaultExpire
+	refreshTokenExpire := RefreshTokenDefaultExpire
+	syncInterval := TokenRevocationDefaultSyncInterval
+	maxSessions := MaxSessionsDefault
+	if tokenConf := utils.DefaultConf.Token; tokenConf != nil {
+		if tokenConf.AccessTokenExpireSecond > 0 {
+			accessTokenExpire = time.Duration(tokenConf.AccessTokenExpireSecond) * time.Second
+		}
+		if tokenConf.RefreshTokenExpireSecond > 0 {
+			refreshTokenExpire = time.Duration(tokenConf.RefreshTokenExpireSecond) * time.Second
+		}
+		if tokenConf.RevocationSyncSecond > 0 {
+			syncInterval = time.Duration(tokenConf.RevocationSyncSecond) * time.Second
+		}
+		if tokenConf.MaxSessionsPerAccount > 0 {
+			maxSessions = tokenConf.MaxSessionsPerAccount
+		}
+	}
+	mongoClient, err := mongodb.Get(&conf)
 	if err != nil {
 		xl.Errorf("failed to create mongo client, error %v", err)
 		return nil, err
 	}
-	accountColl := mongoClient.DB(conf.Database).C(dao.CollectionAccount)
-	accountTokenColl := mongoClient.DB(conf.Database).C(dao.CollectionAccountToken)
+	revocation, err := getRevocationList(mongoClient, syncInterval)
+	if err != nil {
+		xl.Errorf("failed to create token revocation list, error %v", err)
+		return nil, err
+	}
+	accountColl := mongoClient.C(dao.CollectionAccount)
+	accountTokenColl := mongoClient.C(dao.CollectionAccountToken)
 	return &AccountService{
-		mongoClient:      mongoClient,
-		accountColl:      accountColl,
-		accountTokenColl: accountTokenColl,
-		xl:               xl,
+		mongoClient:        mongoClient,
+		accountColl:        accountColl,
+		accountTokenColl:   accountTokenColl,
+		emailTokenColl:     mongoClient.C(dao.CollectionAccountEmailToken),
+		jwtKey:             []byte(utils.DefaultConf.JwtKey),
+		accessTokenExpire:  accessTokenExpire,
+		refreshTokenExpire: refreshTokenExpire,
+		maxSessions:        maxSessions,
+		revocation:         revocation,
+		xl:                 xl,
 	}, nil
 }
 
 // CreateAccount 创建用户账号。
-func (c *AccountService) CreateAccount(xl *xlog.Logger, account *model.AccountDo) error {
+func (c *AccountService) CreateAccount(ctx context.Context, xl *xlog.Logger, account *model.AccountDo) error {
 	if xl == nil {
 		xl = c.xl
 	}
+	defer tracing.StartDAO(ctx, "AccountService.CreateAccount").End()
 	account.RegisterTime = time.Now()
-	err := c.accountColl.Insert(account)
+	err := c.accountColl.WithContext(ctx).Insert(account)
 	if err != nil {
 		xl.Errorf("failed to insert user, error %v", err)
 		return err
@@ -54,27 +120,28 @@ func (c *AccountService) CreateAccount(xl *xlog.Logger, account *model.AccountDo
 }
 
 // GetAccountByPhone 使用电话号码查找账号。
-func (c *AccountService) GetAccountByPhone(xl *xlog.Logger, phone string) (*model.AccountDo, error) {
-	return c.GetAccountByFields(xl, map[string]interface{}{"phone": phone})
+func (c *AccountService) GetAccountByPhone(ctx context.Context, xl *xlog.Logger, phone string) (*model.AccountDo, error) {
+	return c.GetAccountByFields(ctx, xl, map[string]interface{}{"phone": phone})
 }
 
 // GetOrSaveAccountByPhone 使用电话号码查找账号。
-func (c *AccountService) GetOrSaveAccountByPhone(xl *xlog.Logger, phone string) (*model.AccountDo, error) {
-	return c.GetAccountByFields(xl, map[string]interface{}{"phone": phone})
+func (c *AccountService) GetOrSaveAccountByPhone(ctx context.Context, xl *xlog.Logger, phone string) (*model.AccountDo, error) {
+	return c.GetAccountByFields(ctx, xl, map[string]interface{}{"phone": phone})
 }
 
 // GetAccountByID 使用ID查找账号。
-func (c *AccountService) GetAccountByID(xl *xlog.Logger, id string) (*model.AccountDo, error) {
-	return c.GetAccountByFields(xl, map[string]interface{}{"_id": id})
+func (c *AccountService) GetAccountByID(ctx context.Context, xl *xlog.Logger, id string) (*model.AccountDo, error) {
+	return c.GetAccountByFields(ctx, xl, map[string]interface{}{"_id": id})
 }
 
 // GetAccountByFields 根据一组key/value关系查找用户账号。
-func (c *AccountService) GetAccountByFields(xl *xlog.Logger, fields map[string]interface{}) (*model.AccountDo, error) {
+func (c *AccountService) GetAccountByFields(ctx context.Context, xl *xlog.Logger, fields map[string]interface{}) (*model.AccountDo, error) {
 	if xl == nil {
 		xl = c.xl
 	}
+	defer tracing.StartDAO(ctx, "AccountService.GetAccountByFields").End()
 	account := model.AccountDo{}
-	err := c.accountColl.Find(fields).One(&account)
+	err := c.accountColl.WithContext(ctx).Find(fields).One(&account)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Infof("no such user for fields %v", fields)
@@ -87,11 +154,12 @@ func (c *AccountService) GetAccountByFields(xl *xlog.Logger, fields map[string]i
 }
 
 // UpdateAccount 更新用户信息。
-func (c *AccountService) UpdateAccount(xl *xlog.Logger, id string, newAccount *model.AccountDo) (*model.AccountDo, error) {
+func (c *AccountService) UpdateAccount(ctx context.Context, xl *xlog.Logger, id string, newAccount *model.AccountDo) (*model.AccountDo, error) {
 	if xl == nil {
 		xl = c.xl
 	}
-	account, err := c.GetAccountByID(xl, id)
+	defer tracing.StartDAO(ctx, "AccountService.UpdateAccount").End()
+	account, err := c.GetAccountByID(ctx, xl, id)
 	if err != nil {
 		return nil, err
 	}
@@ -101,7 +169,10 @@ func (c *AccountService) UpdateAccount(xl *xlog.Logger, id string, newAccount *m
 	if newAccount.Avatar != "" {
 		account.Avatar = newAccount.Avatar
 	}
-	err = c.accountColl.Update(bson.M{"_id": id}, bson.M{"$set": account})
+	if newAccount.Locale != "" {
+		account.Locale = newAccount.Locale
+	}
+	err = c.accountColl.WithContext(ctx).Update(bson.M{"_id": id}, bson.M{"$set": account})
 	if err != nil {
 		xl.Errorf("failed to update account %s,error %v", id, err)
 		return nil, err
@@ -109,42 +180,93 @@ func (c *AccountService) UpdateAccount(xl *xlog.Logger, id string, newAccount *m
 	return account, nil
 }
 
-// AccountLogin 设置某个账号为已登录状态。
-func (c *AccountService) AccountLogin(xl *xlog.Logger, userID string) (user *model.AccountTokenDo, err error) {
+// GrantRole 授予账号角色，重复授予不报错。
+func (c *AccountService) GrantRole(ctx context.Context, xl *xlog.Logger, id string, role model.Role) error {
 	if xl == nil {
 		xl = c.xl
 	}
-	account, err := c.GetAccountByID(xl, userID)
+	defer tracing.StartDAO(ctx, "AccountService.GrantRole").End()
+	err := c.accountColl.WithContext(ctx).UpdateId(id, bson.M{"$addToSet": bson.M{"roles": role}})
+	if err != nil {
+		xl.Errorf("failed to grant role %s to account %s, error %v", role, id, err)
+		return err
+	}
+	return nil
+}
+
+// RevokeRole 撤销账号的角色。
+func (c *AccountService) RevokeRole(ctx context.Context, xl *xlog.Logger, id string, role model.Role) error {
+	if xl == nil {
+		xl = c.xl
+	}
+	defer tracing.StartDAO(ctx, "AccountService.RevokeRole").End()
+	err := c.accountColl.WithContext(ctx).UpdateId(id, bson.M{"$pull": bson.M{"roles": role}})
+	if err != nil {
+		xl.Errorf("failed to revoke role %s from account %s, error %v", role, id, err)
+		return err
+	}
+	return nil
+}
+
+// AccountLogin 在指定设备上登录账号，签发新的访问token与刷新token。
+// sessionID不为空时续用该会话；否则同一设备ID已有会话时复用该会话，没有则新建会话。
+// 其他设备上的会话不受影响，但在线设备数超过上限时会踢掉最早活跃的会话。
+func (c *AccountService) AccountLogin(ctx context.Context, xl *xlog.Logger, userID string, sessionID string, device model.DeviceInfo) (user *model.AccountTokenDo, err error) {
+	if xl == nil {
+		xl = c.xl
+	}
+	defer tracing.StartDAO(ctx, "AccountService.AccountLogin").End()
+	account, err := c.GetAccountByID(ctx, xl, userID)
 	if err != nil {
 		xl.Errorf("AccountLogin: failed to find account %s", userID)
 		return nil, err
 	}
-	// 查看是否已经登录。
-	activeUser := &model.AccountTokenDo{
-		ID:        userID,
-		AccountId: userID,
+	now := time.Now()
+	// 查看该设备是否已经登录。
+	activeUser := &model.AccountTokenDo{}
+	var filter bson.M
+	switch {
+	case sessionID != "":
+		filter = bson.M{"_id": sessionID, "accountId": userID}
+	case device.DeviceID != "":
+		filter = bson.M{"accountId": userID, "deviceId": device.DeviceID}
 	}
-	err = c.accountTokenColl.Find(map[string]interface{}{"_id": userID}).
-		One(activeUser)
-	if err != nil {
-		if err != mgo.ErrNotFound {
-			xl.Errorf("failed to check logged in users in mongo,error %v", err)
+	if filter != nil {
+		err = c.accountTokenColl.WithContext(ctx).Find(filter).One(activeUser)
+		if err != nil && err != mgo.ErrNotFound {
+			xl.Errorf("failed to check logged in sessions in mongo,error %v", err)
 			return nil, err
 		}
+	}
+	if filter != nil && err == nil {
+		xl.Infof("user %s has been already logged in on session %s, the old token will be invalid", userID, activeUser.ID)
+		c.revokeAccessToken(ctx, xl, activeUser)
+		if device.DeviceID == "" {
+			device.DeviceID = activeUser.DeviceID
+		}
 	} else {
-		xl.Infof("user %s has been already logged in, the old session will be invalid", userID)
+		activeUser = &model.AccountTokenDo{
+			ID:         utils.GenerateID(),
+			AccountId:  userID,
+			CreateTime: now,
+		}
 	}
+	activeUser.DeviceInfo = device
 	// generate token.
-	activeUser.Token = c.makeLoginToken(xl, account)
+	err = c.issueTokens(xl, account, activeUser)
+	if err != nil {
+		return nil, err
+	}
 	// update or insert login record.
-	_, err = c.accountTokenColl.Upsert(bson.M{"_id": userID}, activeUser)
+	_, err = c.accountTokenColl.WithContext(ctx).UpsertId(activeUser.ID, activeUser)
 	if err != nil {
 		xl.Errorf("failed to update or insert user login record, error %v", err)
 		return nil, err
 	}
+	c.evictSessions(ctx, xl, userID)
 	// 更新最后登录时间。
-	account.LastLoginTime = time.Now()
-	err = c.accountColl.Update(bson.M{"_id": userID}, bson.M{"$set": bson.M{"lastLoginTime": time.Now()}})
+	account.LastLoginTime = now
+	err = c.accountColl.WithContext(ctx).Update(bson.M{"_id": userID}, bson.M{"$set": bson.M{"lastLoginTime": now}})
 	if err != nil {
 		// 更新登录时间失败不影响正常返回。
 		xl.Errorf("failed to update user %s login time, error %v", userID, err)
@@ -152,65 +274,259 @@ func (c *AccountService) AccountLogin(xl *xlog.Logger, userID string) (user *mod
 	return activeUser, nil
 }
 
-func (c *AccountService) makeLoginToken(xl *xlog.Logger, account *model.AccountDo) string {
+// evictSessions 在线会话数超过上限时，按最近活跃时间踢掉最早的会话。
+func (c *AccountService) evictSessions(ctx context.Context, xl *xlog.Logger, userID string) {
+	sessions, err := c.ListSessions(ctx, xl, userID)
+	if err != nil {
+		xl.Errorf("failed to list sessions of user %s to evict, error %v", userID, err)
+		return
+	}
+	if len(sessions) <= c.maxSessions {
+		return
+	}
+	for i := range sessions[c.maxSessions:] {
+		session := &sessions[c.maxSessions+i]
+		xl.Infof("user %s exceeds %d sessions, evict session %s", userID, c.maxSessions, session.ID)
+		err = c.removeSession(ctx, xl, session)
+		if err != nil {
+			// 踢掉会话失败不影响本次登录，下次登录时会再次尝试。
+			xl.Errorf("failed to evict session %s of user %s, error %v", session.ID, userID, err)
+		}
+	}
+}
+
+// ListSessions 列出账号所有的登录会话，最近活跃的在前。
+func (c *AccountService) ListSessions(ctx context.Context, xl *xlog.Logger, userID string) ([]model.AccountTokenDo, error) {
+	if xl == nil {
+		xl = c.xl
+	}
+	defer tracing.StartDAO(ctx, "AccountService.ListSessions").End()
+	sessions := make([]model.AccountTokenDo, 0)
+	err := c.accountTokenColl.WithContext(ctx).Find(bson.M{"accountId": userID}).Sort("-lastModifyTime").All(&sessions)
+	if err != nil {
+		xl.Errorf("failed to list sessions of user %s, error %v", userID, err)
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// RevokeSession 注销账号的某个登录会话。
+func (c *AccountService) RevokeSession(ctx context.Context, xl *xlog.Logger, userID string, sessionID string) error {
 	if xl == nil {
 		xl = c.xl
 	}
-	timestamp := time.Now().UnixNano()
-	// TODO: add more secret things for token?
-	claims := jwt.MapClaims{
-		"userID":    account.ID,
-		"timestamp": timestamp,
+	defer tracing.StartDAO(ctx, "AccountService.RevokeSession").End()
+	session := &model.AccountTokenDo{}
+	err := c.accountTokenColl.WithContext(ctx).Find(bson.M{"_id": sessionID, "accountId": userID}).One(session)
+	if err != nil {
+		if err != mgo.ErrNotFound {
+			xl.Errorf("failed to find session %s of user %s, error %v", sessionID, userID, err)
+		}
+		return err
 	}
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	token, _ := t.SignedString([]byte(""))
-	return token
+	return c.removeSession(ctx, xl, session)
 }
 
-// AccountLogout 用户退出登录。
-func (c *AccountService) AccountLogout(xl *xlog.Logger, userID string) error {
+// RevokeAllSessions 注销账号的所有登录会话，exceptSessionID不为空时保留该会话。
+func (c *AccountService) RevokeAllSessions(ctx context.Context, xl *xlog.Logger, userID string, exceptSessionID string) error {
 	if xl == nil {
 		xl = c.xl
 	}
-	// 删除用户登录记录。
-	err := c.accountTokenColl.RemoveId(userID)
+	defer tracing.StartDAO(ctx, "AccountService.RevokeAllSessions").End()
+	sessions, err := c.ListSessions(ctx, xl, userID)
 	if err != nil {
-		xl.Errorf("failed to remove user ID %s in logged in users, error %v", userID, err)
 		return err
 	}
+	for i := range sessions {
+		if sessions[i].ID == exceptSessionID {
+			continue
+		}
+		err = c.removeSession(ctx, xl, &sessions[i])
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// GetIDByToken 根据token获取账号ID。如果未在已登录用户表查找到这个token，说明该token不合法。
-func (c *AccountService) GetIDByToken(xl *xlog.Logger, token string) (id string, err error) {
+// removeSession 吊销会话当前的访问token并删除会话，使其刷新token失效。
+func (c *AccountService) removeSession(ctx context.Context, xl *xlog.Logger, session *model.AccountTokenDo) error {
+	c.revokeAccessToken(ctx, xl, session)
+	err := c.accountTokenColl.WithContext(ctx).RemoveId(session.ID)
+	if err != nil && err != mgo.ErrNotFound {
+		xl.Errorf("failed to remove session %s of user %s, error %v", session.ID, session.AccountId, err)
+		return err
+	}
+	return nil
+}
+
+// RefreshLogin 使用刷新token换取新的访问token。刷新token只能使用一次，每次刷新都会签发新的刷新token。
+func (c *AccountService) RefreshLogin(ctx context.Context, xl *xlog.Logger, refreshToken string) (user *model.AccountTokenDo, err error) {
 	if xl == nil {
 		xl = c.xl
 	}
-	accountTokenRecord := &model.AccountTokenDo{}
-	err = c.accountTokenColl.Find(map[string]interface{}{"token": token}).One(accountTokenRecord)
+	defer tracing.StartDAO(ctx, "AccountService.RefreshLogin").End()
+	if refreshToken == "" {
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenInvalid, Summary: "empty refresh token"}
+	}
+	oldHash := hashToken(refreshToken)
+	activeUser := &model.AccountTokenDo{}
+	err = c.accountTokenColl.WithContext(ctx).Find(bson.M{"refreshTokenHash": oldHash}).One(activeUser)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			xl.Infof("refresh token not found in active users")
+			return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenInvalid, Summary: "unknown refresh token"}
+		}
+		xl.Errorf("failed to find refresh token in active users, error %v", err)
+		return nil, err
+	}
+	if activeUser.RefreshExpireAt.Before(time.Now()) {
+		xl.Infof("refresh token of user %s expired at %v", activeUser.AccountId, activeUser.RefreshExpireAt)
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenExpired, Summary: "refresh token expired"}
+	}
+	account, err := c.GetAccountByID(ctx, xl, activeUser.AccountId)
+	if err != nil {
+		xl.Errorf("RefreshLogin: failed to find account %s", activeUser.AccountId)
+		return nil, err
+	}
+	oldUser := *activeUser
+	err = c.issueTokens(xl, account, activeUser)
+	if err != nil {
+		return nil, err
+	}
+	// 以旧的刷新token摘要为条件更新，避免同一个刷新token被并发使用两次。
+	err = c.accountTokenColl.WithContext(ctx).Update(bson.M{"_id": activeUser.ID, "refreshTokenHash": oldHash}, activeUser)
 	if err != nil {
 		if err == mgo.ErrNotFound {
-			xl.Infof("token %s not found in active users", token)
-			return "", err
+			xl.Infof("refresh token of user %s has been used concurrently", activeUser.AccountId)
+			return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenInvalid, Summary: "refresh token already used"}
 		}
-		xl.Errorf("failed to find token in active users, error %v", err)
+		xl.Errorf("failed to update user login record, error %v", err)
+		return nil, err
+	}
+	c.revokeAccessToken(ctx, xl, &oldUser)
+	return activeUser, nil
+}
+
+// issueTokens 为账号签发访问token与刷新token，并写入登录记录。
+func (c *AccountService) issueTokens(xl *xlog.Logger, account *model.AccountDo, activeUser *model.AccountTokenDo) error {
+	now := time.Now()
+	tokenID := utils.GenerateSecureToken(16)
+	expireAt := now.Add(c.accessTokenExpire)
+	token, err := c.makeLoginToken(xl, account, activeUser.ID, tokenID, now, expireAt)
+	if err != nil {
+		return err
+	}
+	refreshToken := utils.GenerateSecureToken(32)
+	activeUser.Token = token
+	activeUser.TokenID = tokenID
+	activeUser.ExpireAt = expireAt
+	activeUser.RefreshToken = refreshToken
+	activeUser.RefreshTokenHash = hashToken(refreshToken)
+	activeUser.RefreshExpireAt = now.Add(c.refreshTokenExpire)
+	activeUser.LastModifyTime = now
+	return nil
+}
+
+func (c *AccountService) makeLoginToken(xl *xlog.Logger, account *model.AccountDo, sessionID string, tokenID string, issuedAt time.Time, expireAt time.Time) (string, error) {
+	if xl == nil {
+		xl = c.xl
+	}
+	claims := LoginClaims{
+		UserID:    account.ID,
+		SessionID: sessionID,
+		Phone:     account.Phone,
+		StandardClaims: jwt.StandardClaims{
+			Id:        tokenID,
+			Subject:   account.ID,
+			IssuedAt:  issuedAt.Unix(),
+			ExpiresAt: expireAt.Unix(),
+		},
+	}
+	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token, err := t.SignedString(c.jwtKey)
+	if err != nil {
+		xl.Errorf("failed to sign login token for user %s, error %v", account.ID, err)
 		return "", err
 	}
-	return accountTokenRecord.ID, nil
+	return token, nil
+}
+
+func (c *AccountService) revokeAccessToken(ctx context.Context, xl *xlog.Logger, activeUser *model.AccountTokenDo) {
+	if activeUser.TokenID == "" {
+		return
+	}
+	err := c.revocation.Revoke(ctx, xl, activeUser.AccountId, activeUser.TokenID, activeUser.ExpireAt)
+	if err != nil {
+		// 内存中已吊销，写库失败只影响其他实例，不影响正常返回。
+		xl.Errorf("failed to persist revocation of token %s, error %v", activeUser.TokenID, err)
+	}
+}
+
+// hashToken 计算刷新token等一次性token的哈希，数据库中只保存哈希。
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// AccountLogout 用户在当前会话退出登录，吊销当前的访问token并使刷新token失效，不影响其他设备。
+func (c *AccountService) AccountLogout(ctx context.Context, xl *xlog.Logger, userID string, sessionID string) error {
+	if xl == nil {
+		xl = c.xl
+	}
+	defer tracing.StartDAO(ctx, "AccountService.AccountLogout").End()
+	err := c.RevokeSession(ctx, xl, userID, sessionID)
+	if err != nil {
+		xl.Errorf("failed to remove session %s of user %s in logged in users, error %v", sessionID, userID, err)
+		return err
+	}
+	return nil
 }
 
-func (c *AccountService) DeleteAccount(xl *xlog.Logger, id string) error {
+// ParseLoginToken 校验登录token的签名、有效期以及是否已被吊销，不访问数据库。
+func (c *AccountService) ParseLoginToken(xl *xlog.Logger, token string) (*LoginClaims, error) {
 	if xl == nil {
 		xl = c.xl
 	}
-	return c.accountColl.RemoveId(id)
+	claims := &LoginClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		return c.jwtKey, nil
+	})
+	if err != nil {
+		if validationErr, ok := err.(*jwt.ValidationError); ok && validationErr.Errors&jwt.ValidationErrorExpired != 0 {
+			xl.Infof("login token of user %s expired", claims.UserID)
+			return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenExpired, Summary: "token expired"}
+		}
+		xl.Infof("invalid login token, error %v", err)
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenInvalid, Summary: "invalid token"}
+	}
+	if claims.UserID == "" || claims.SessionID == "" || claims.Id == "" || claims.ExpiresAt == 0 {
+		xl.Infof("login token lacks required claims")
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenInvalid, Summary: "invalid token"}
+	}
+	if c.revocation.IsRevoked(claims.Id) {
+		xl.Infof("login token %s of user %s has been revoked", claims.Id, claims.UserID)
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenInvalid, Summary: "token revoked"}
+	}
+	return claims, nil
 }
 
-func (c *AccountService) ListAll0() ([]model.AccountDo, error) {
-	results := make([]model.AccountDo, 0)
-	err := c.accountColl.Find(nil).All(&results)
+// GetIDByToken 根据token获取账号ID。token签名错误、已过期或已被吊销时返回错误。
+func (c *AccountService) GetIDByToken(xl *xlog.Logger, token string) (id string, err error) {
+	claims, err := c.ParseLoginToken(xl, token)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	return claims.UserID, nil
+}
+
+func (c *AccountService) DeleteAccount(ctx context.Context, xl *xlog.Logger, id string) error {
+	if xl == nil {
+		xl = c.xl
 	}
-	return results, nil
+	defer tracing.StartDAO(ctx, "AccountService.DeleteAccount").End()
+	return c.accountColl.WithContext(ctx).RemoveId(id)
 }
@@ -0,0 +1,284 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
+	"github.com/solutions/niu-cube/internal/common/utils"
+	model "github.com/solutions/niu-cube/internal/protodef/model"
+	dao "github.com/solutions/niu-cube/internal/service/db/dao"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// accountDataKey 集合字段引用账号的方式。
+type accountDataKey int
+
+const (
+	accountDataKeyID accountDataKey = iota
+	accountDataKeyPhone
+)
+
+// accountDataRef 某个集合中引用账号的字段。
+// anonymize为nil时注销账号会删除匹配的记录；否则记录仍被其他用户使用（如面试、考试），只用anonymize覆盖账号相关字段。
+type accountDataRef struct {
+	collection string
+	field      string
+	key        accountDataKey
+	anonymize  bson.M
+	// exportOmit 导出个人数据时去掉的字段，如token、密码哈希等凭据。
+	exportOmit []string
+	// imDB 为true时记录保存在IM服务单独的数据库中。
+	imDB bool
+}
+
+// accountDataRefs 所有引用账号的集合。新增引用账号ID或手机号的集合时需要在此登记，注销与导出才能覆盖到。
+// 账号本身最后删除，保证任务失败重试时仍能找到账号。已吊销token列表只含token ID，随token过期自然清理，不在此列出。
+var accountDataRefs = []accountDataRef{
+	{collection: dao.CollectionAccountToken, field: "accountId", key: accountDataKeyID, exportOmit: []string{"token", "tokenId", "refreshTokenHash"}},
+	{collection: dao.CollectionAccountEmailToken, field: "accountId", key: accountDataKeyID, exportOmit: []string{"_id"}},
+	{collection: dao.CollectionSMSCode, field: "phone", key: accountDataKeyPhone, exportOmit: []string{"smsCode"}},
+	{collection: dao.CollectionSMSValidateFailure, field: "_id", key: accountDataKeyPhone},
+	{collection: dao.CollectionQiniuIMUser, field: "username", key: accountDataKeyID, exportOmit: []string{"token", "password", "salt"}, imDB: true},
+	{collection: dao.CollectionBaseUser, field: "_id", key: accountDataKeyID},
+	{collection: dao.CollectionBaseRoomUser, field: "user_id", key: accountDataKeyID},
+	{collection: dao.CollectionBaseUserMic, field: "user_id", key: accountDataKeyID},
+	{collection: dao.CollectionBaseRoom, field: "creator", key: accountDataKeyID, anonymize: bson.M{"creator": model.DeletedAccountID}},
+	{collection: dao.CollectionRoomAccount, field: "accountId", key: accountDataKeyID},
+	{collection: dao.CollectionRoom, field: "creator_id", key: accountDataKeyID, anonymize: bson.M{"creator_id": model.DeletedAccountID}},
+	{collection: dao.CollectionBizExtra, field: "creator", key: accountDataKeyID, anonymize: bson.M{"creator": model.DeletedAccountID}},
+	{collection: dao.CollectionBizExtra, field: "interviewer", key: accountDataKeyID, anonymize: bson.M{"interviewer": model.DeletedAccountID, "interviewerName": model.DeletedAccountName}},
+	{collection: dao.CollectionBizExtra, field: "candidate", key: accountDataKeyID, anonymize: bson.M{"candidate": model.DeletedAccountID, "candidateName": model.DeletedAccountName}},
+	{collection: dao.InterviewCollection, field: "creator", key: accountDataKeyID, anonymize: bson.M{"creator": model.DeletedAccountID}},
+	{collection: dao.InterviewCollection, field: "interviewer", key: accountDataKeyID, anonymize: bson.M{"interviewer": model.DeletedAccountID, "interviewerName": model.DeletedAccountName}},
+	{collection: dao.InterviewCollection, field: "candidate", key: accountDataKeyID, anonymize: bson.M{"candidate": model.DeletedAccountID, "candidateName": model.DeletedAccountName}},
+	{collection: dao.InterviewUserCollection, field: "userId", key: accountDataKeyID},
+	{collection: dao.CollectionBoard, field: "current_user_id", key: accountDataKeyID, anonymize: bson.M{"current_user_id": ""}},
+	{collection: dao.CollectionRepairRoom, field: "creator", key: accountDataKeyID, anonymize: bson.M{"creator": model.DeletedAccountID}},
+	{collection: dao.CollectionRepairRoom, field: "updator", key: accountDataKeyID, anonymize: bson.M{"updator": model.DeletedAccountID}},
+	{collection: dao.CollectionRepairRoomUser, field: "userId", key: accountDataKeyID},
+	{collection: dao.CollectionRoomUserSong, field: "user_id", key: accountDataKeyID},
+	{collection: dao.CollectionRoomUserMovie, field: "user_id", key: accountDataKeyID},
+	{collection: dao.CollectionExam, field: "creator", key: accountDataKeyID, anonymize: bson.M{"creator": model.DeletedAccountID}},
+	{collection: dao.CollectionUserExam, field: "user_id", key: accountDataKeyID},
+	{collection: dao.CollectionAnswerPaper, field: "user_id", key: accountDataKeyID},
+	{collection: dao.CollectionCheatingExam, field: "user_id", key: accountDataKeyID},
+	{collection: dao.CollectionApiKey, field: "creator", key: accountDataKeyID, exportOmit: []string{"secretHash"}, anonymize: bson.M{"creator": model.DeletedAccountID}},
+	{collection: dao.CollectionAuditLog, field: "userId", key: accountDataKeyID},
+	{collection: dao.CollectionAuditLog, field: "userphone", key: accountDataKeyPhone},
+	{collection: dao.ActionCollection, field: "userphone", key: accountDataKeyPhone},
+}
+
+// AccountDataService 注销账号时级联删除或匿名化所有引用该账号的数据，以及导出账号的个人数据。
+type AccountDataService struct {
+	mongoClient *mongodb.Manager
+	db          *mongodb.Manager
+	// imDB 七牛IM用户所在的数据库，未使用七牛IM时为nil。
+	imDB     *mongodb.Manager
+	taskColl *mongodb.Collection
+	account  *AccountService
+	xl       *xlog.Logger
+}
+
+// NewAccountDataService imConf为七牛IM用户所在数据库的配置，未使用七牛IM时为nil。
+func NewAccountDataService(conf utils.MongoConfig, imConf *utils.MongoConfig, account *AccountService, xl *xlog.Logger) (*AccountDataService, error) {
+	if xl == nil {
+		xl = xlog.New("niu-cube-account-data")
+	}
+	mongoClient, err := mongodb.Get(&conf)
+	if err != nil {
+		xl.Errorf("failed to create mongo client, error %v", err)
+		return nil, err
+	}
+	db := mongoClient
+	s := &AccountDataService{
+		mongoClient: mongoClient,
+		db:          db,
+		taskColl:    db.C(dao.TaskCollection),
+		account:     account,
+		xl:          xl,
+	}
+	if imConf != nil {
+		imClient, err := mongodb.Get(imConf)
+		if err != nil {
+			xl.Errorf("failed to create mongo client of IM, error %v", err)
+			return nil, err
+		}
+		s.imDB = imClient
+	}
+	return s, nil
+}
+
+// collection 返回ref所在的集合，记录在IM数据库而未使用七牛IM时返回false。
+func (s *AccountDataService) collection(ctx context.Context, ref accountDataRef) (*mongodb.Collection, bool) {
+	if !ref.imDB {
+		return s.db.C(ref.collection).WithContext(ctx), true
+	}
+	if s.imDB == nil {
+		return nil, false
+	}
+	return s.imDB.C(ref.collection).WithContext(ctx), true
+}
+
+// StartDeletion 以后台任务的方式注销账号，任务结果可通过GetDeletion查询。
+// 同一账号的任务失败后再次调用会重试，删除操作可重复执行。任务在请求返回后继续执行，不随ctx取消，只沿用ctx中的span。
+func (s *AccountDataService) StartDeletion(ctx context.Context, xl *xlog.Logger, account *model.AccountDo) {
+	if xl == nil {
+		xl = s.xl
+	}
+	defer tracing.StartDAO(ctx, "AccountDataService.StartDeletion").End()
+	taskCtx := tracing.Detach(ctx)
+	model.NewTask(account.ID, model.TaskSubjectAccount, model.TaskActionAccountDelete).Handle(func() (string, error) {
+		report, err := s.DeleteAccountData(taskCtx, xl, account)
+		if err != nil {
+			return "", err
+		}
+		result, err := json.Marshal(report)
+		if err != nil {
+			return "", err
+		}
+		return string(result), nil
+	}).Start(s.taskColl, xl)
+	xl.Infof("account deletion task started for account %s", account.ID)
+}
+
+// GetDeletion 查询账号最近一次注销任务的状态与结果。
+func (s *AccountDataService) GetDeletion(ctx context.Context, xl *xlog.Logger, accountID string) (*model.AccountDeletionResponse, error) {
+	if xl == nil {
+		xl = s.xl
+	}
+	defer tracing.StartDAO(ctx, "AccountDataService.GetDeletion").End()
+	task := model.TaskResultDo{}
+	err := s.taskColl.WithContext(ctx).Find(bson.M{
+		"subject":    model.TaskSubjectAccount,
+		"action":     model.TaskActionAccountDelete,
+		"subject_id": accountID,
+	}).Sort("-create_at").One(&task)
+	if err != nil {
+		if err != mgo.ErrNotFound {
+			xl.Errorf("failed to get deletion task of account %s, error %v", accountID, err)
+		}
+		return nil, err
+	}
+	resp := &model.AccountDeletionResponse{
+		AccountID: accountID,
+		Status:    task.Status,
+	}
+	switch task.Status {
+	case model.TaskStatusSuccess:
+		report := &model.AccountDeletionReport{}
+		if err := json.Unmarshal([]byte(task.Result), report); err == nil {
+			resp.Report = report
+		}
+	case model.TaskStatusFailed:
+		resp.Error = task.Result
+	}
+	return resp, nil
+}
+
+// DeleteAccountData 注销账号：注销所有登录会话，删除或匿名化引用账号的记录，最后删除账号本身。
+func (s *AccountDataService) DeleteAccountData(ctx context.Context, xl *xlog.Logger, account *model.AccountDo) (*model.AccountDeletionReport, error) {
+	if xl == nil {
+		xl = s.xl
+	}
+	defer tracing.StartDAO(ctx, "AccountDataService.DeleteAccountData").End()
+	report := &model.AccountDeletionReport{
+		AccountID:  account.ID,
+		Removed:    make(map[string]int),
+		Anonymized: make(map[string]int),
+	}
+	err := s.account.RevokeAllSessions(ctx, xl, account.ID, "")
+	if err != nil {
+		xl.Errorf("failed to revoke sessions of account %s, error %v", account.ID, err)
+		return nil, err
+	}
+	for _, ref := range accountDataRefs {
+		selector, ok := ref.selector(account)
+		if !ok {
+			continue
+		}
+		coll, ok := s.collection(ctx, ref)
+		if !ok {
+			continue
+		}
+		if ref.anonymize == nil {
+			info, err := coll.RemoveAll(selector)
+			if err != nil {
+				xl.Errorf("failed to remove %s records of account %s, error %v", ref.collection, account.ID, err)
+				return nil, err
+			}
+			report.Removed[ref.collection] += info.Removed
+			continue
+		}
+		info, err := coll.UpdateAll(selector, bson.M{"$set": ref.anonymize})
+		if err != nil {
+			xl.Errorf("failed to anonymize %s records of account %s, error %v", ref.collection, account.ID, err)
+			return nil, err
+		}
+		report.Anonymized[ref.collection] += info.Updated
+	}
+	err = s.db.C(dao.CollectionAccount).WithContext(ctx).RemoveId(account.ID)
+	if err != nil && err != mgo.ErrNotFound {
+		xl.Errorf("failed to remove account %s, error %v", account.ID, err)
+		return nil, err
+	}
+	if err == nil {
+		report.Removed[dao.CollectionAccount] = 1
+	}
+	report.FinishTime = time.Now()
+	xl.Infof("account %s deleted, removed %v, anonymized %v", account.ID, report.Removed, report.Anonymized)
+	return report, nil
+}
+
+// ExportAccountData 导出与账号相关的所有记录，凭据类字段不导出。
+func (s *AccountDataService) ExportAccountData(ctx context.Context, xl *xlog.Logger, account *model.AccountDo) (*model.AccountDataExport, error) {
+	if xl == nil {
+		xl = s.xl
+	}
+	defer tracing.StartDAO(ctx, "AccountDataService.ExportAccountData").End()
+	export := &model.AccountDataExport{
+		ExportTime:  time.Now(),
+		Account:     *account,
+		Collections: make(map[string][]bson.M),
+	}
+	for _, ref := range accountDataRefs {
+		selector, ok := ref.selector(account)
+		if !ok {
+			continue
+		}
+		coll, ok := s.collection(ctx, ref)
+		if !ok {
+			continue
+		}
+		records := make([]bson.M, 0)
+		err := coll.Find(selector).All(&records)
+		if err != nil {
+			xl.Errorf("failed to export %s records of account %s, error %v", ref.collection, account.ID, err)
+			return nil, err
+		}
+		for _, record := range records {
+			for _, field := range ref.exportOmit {
+				delete(record, field)
+			}
+		}
+		export.Collections[ref.collection] = append(export.Collections[ref.collection], records...)
+	}
+	return export, nil
+}
+
+// selector 返回匹配账号记录的查询条件，账号没有对应的手机号时返回false。
+func (r accountDataRef) selector(account *model.AccountDo) (bson.M, bool) {
+	switch r.key {
+	case accountDataKeyPhone:
+		if account.Phone == "" {
+			return nil, false
+		}
+		return bson.M{r.field: account.Phone}, true
+	default:
+		return bson.M{r.field: account.ID}, true
+	}
+}
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/solutions/niu-cube/internal/common/utils"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+	"github.com/solutions/niu-cube/internal/service/db/dao"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestAccountDataRefs(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, ref := range accountDataRefs {
+		id := ref.collection + "." + ref.field
+		if ref.collection == "" || ref.field == "" || seen[id] {
+			t.Fatalf("invalid or duplicated ref %+v", ref)
+		}
+		seen[id] = true
+		// 匿名化必须覆盖匹配字段本身，否则重试注销时会重复匹配，导出时仍会包含其他用户的记录。
+		if ref.anonymize != nil {
+			if _, ok := ref.anonymize[ref.field]; !ok {
+				t.Fatalf("ref %s anonymizes without overwriting %s", ref.collection, ref.field)
+			}
+		}
+		if ref.imDB != (ref.collection == dao.CollectionQiniuIMUser) {
+			t.Fatalf("ref %s imDB = %v", ref.collection, ref.imDB)
+		}
+	}
+	if seen[dao.CollectionAccount+"._id"] {
+		t.Fatalf("account itself should be removed after all refs")
+	}
+}
+
+func TestAccountDataRefSelector(t *testing.T) {
+	cases := []struct {
+		name    string
+		ref     accountDataRef
+		account model.AccountDo
+		want    bson.M
+		wantOK  bool
+	}{
+		{name: "by id", ref: accountDataRef{field: "user_id"}, account: model.AccountDo{ID: "u1", Phone: "13800000000"}, want: bson.M{"user_id": "u1"}, wantOK: true},
+		{name: "by phone", ref: accountDataRef{field: "phone", key: accountDataKeyPhone}, account: model.AccountDo{ID: "u1", Phone: "13800000000"}, want: bson.M{"phone": "13800000000"}, wantOK: true},
+		// 没有手机号的账号（如邮箱注册）跳过按手机号引用的集合，避免匹配到所有手机号为空的记录。
+		{name: "no phone", ref: accountDataRef{field: "phone", key: accountDataKeyPhone}, account: model.AccountDo{ID: "u1"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := tc.ref.selector(&tc.account)
+			if ok != tc.wantOK || (ok && got[tc.ref.field] != tc.want[tc.ref.field]) {
+				t.Fatalf("selector = (%v, %v), want (%v, %v)", got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestAccountDataServiceCollection(t *testing.T) {
+	s := &AccountDataService{}
+	if _, ok := s.collection(context.Background(), accountDataRef{collection: dao.CollectionQiniuIMUser, imDB: true}); ok {
+		t.Fatalf("IM refs should be skipped without IM database")
+	}
+}
+
+func TestDeleteAndExportAccountData(t *testing.T) {
+	conf := testMongoConfig(t)
+	imConf := testMongoConfig(t)
+	accounts, err := NewAccountService(conf, nil)
+	if err != nil {
+		t.Fatalf("NewAccountService: %v", err)
+	}
+	s, err := NewAccountDataService(conf, &imConf, accounts, nil)
+	if err != nil {
+		t.Fatalf("NewAccountDataService: %v", err)
+	}
+	account := &model.AccountDo{ID: utils.GenerateID(), Phone: "13800000001"}
+	if err := accounts.CreateAccount(context.Background(), nil, account); err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+	other := utils.GenerateID()
+	inserts := []struct {
+		imDB       bool
+		collection string
+		doc        bson.M
+	}{
+		{collection: dao.CollectionBaseUser, doc: bson.M{"_id": account.ID, "name": "me"}},
+		{collection: dao.CollectionBaseUser, doc: bson.M{"_id": other, "name": "other"}},
+		{collection: dao.CollectionSMSCode, doc: bson.M{"_id": "sms-1", "phone": account.Phone, "smsCode": "123456"}},
+		{collection: dao.CollectionBaseRoom, doc: bson.M{"_id": "room-1", "creator": account.ID}},
+		{collection: dao.CollectionApiKey, doc: bson.M{"_id": "key-1", "creator": account.ID, "secretHash": "hash"}},
+		{imDB: true, collection: dao.CollectionQiniuIMUser, doc: bson.M{"_id": "im-1", "username": account.ID, "password": "secret"}},
+		// 主库中同名集合的记录不应被当作IM用户处理。
+		{collection: dao.CollectionQiniuIMUser, doc: bson.M{"_id": "im-main", "username": account.ID}},
+	}
+	for _, insert := range inserts {
+		db := s.db
+		if insert.imDB {
+			db = s.imDB
+		}
+		if err := db.C(insert.collection).Insert(insert.doc); err != nil {
+			t.Fatalf("insert %s: %v", insert.collection, err)
+		}
+	}
+
+	export, err := s.ExportAccountData(context.Background(), nil, account)
+	if err != nil {
+		t.Fatalf("ExportAccountData: %v", err)
+	}
+	wantExported := map[string]int{
+		dao.CollectionBaseUser:    1,
+		dao.CollectionSMSCode:     1,
+		dao.CollectionBaseRoom:    1,
+		dao.CollectionApiKey:      1,
+		dao.CollectionQiniuIMUser: 1,
+	}
+	for collection, want := range wantExported {
+		if got := len(export.Collections[collection]); got != want {
+			t.Fatalf("exported %d %s records, want %d", got, collection, want)
+		}
+	}
+	if _, ok := export.Collections[dao.CollectionSMSCode][0]["smsCode"]; ok {
+		t.Fatalf("sms code should be omitted from export")
+	}
+	if _, ok := export.Collections[dao.CollectionApiKey][0]["secretHash"]; ok {
+		t.Fatalf("api key secret hash should be omitted from export")
+	}
+	if imUser := export.Collections[dao.CollectionQiniuIMUser][0]; imUser["_id"] != "im-1" || imUser["password"] != nil {
+		t.Fatalf("unexpected exported IM user %v", imUser)
+	}
+
+	report, err := s.DeleteAccountData(context.Background(), nil, account)
+	if err != nil {
+		t.Fatalf("DeleteAccountData: %v", err)
+	}
+	if report.Removed[dao.CollectionBaseUser] != 1 || report.Removed[dao.CollectionQiniuIMUser] != 1 ||
+		report.Anonymized[dao.CollectionBaseRoom] != 1 || report.Anonymized[dao.CollectionApiKey] != 1 || report.Removed[dao.CollectionAccount] != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if n, _ := s.db.C(dao.CollectionBaseUser).FindId(other).Count(); n != 1 {
+		t.Fatalf("other user's record should be kept")
+	}
+	if n, _ := s.db.C(dao.CollectionQiniuIMUser).FindId("im-main").Count(); n != 1 {
+		t.Fatalf("main database should not be touched for IM users")
+	}
+	room := bson.M{}
+	if err := s.db.C(dao.CollectionBaseRoom).FindId("room-1").One(&room); err != nil || room["creator"] != model.DeletedAccountID {
+		t.Fatalf("room should be anonymized, got %v, error %v", room, err)
+	}
+	// 重试注销可重复执行。
+	if _, err := s.DeleteAccountData(context.Background(), nil, account); err != nil {
+		t.Fatalf("DeleteAccountData retry: %v", err)
+	}
+}
+
+func TestGetDeletionLatest(t *testing.T) {
+	conf := testMongoConfig(t)
+	s, err := NewAccountDataService(conf, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewAccountDataService: %v", err)
+	}
+	now := time.Now()
+	for i, status := range []model.TaskStatus{model.TaskStatusFailed, model.TaskStatusSuccess, model.TaskStatusRunning} {
+		// 按插入顺序之外的创建时间排列，最新的任务为success。
+		createAt := now.Add(time.Duration(i) * time.Minute)
+		if status == model.TaskStatusRunning {
+			createAt = now.Add(-time.Hour)
+		}
+		err := s.taskColl.Insert(model.TaskResultDo{
+			ID:        utils.GenerateID(),
+			CreateAt:  createAt,
+			Subject:   model.TaskSubjectAccount,
+			Action:    model.TaskActionAccountDelete,
+			SubjectID: "u1",
+			Status:    status,
+			Result:    `{"accountId":"u1"}`,
+		})
+		if err != nil {
+			t.Fatalf("insert task: %v", err)
+		}
+	}
+	deletion, err := s.GetDeletion(context.Background(), nil, "u1")
+	if err != nil {
+		t.Fatalf("GetDeletion: %v", err)
+	}
+	if deletion.Status != model.TaskStatusSuccess || deletion.Report == nil {
+		t.Fatalf("GetDeletion = %+v, want latest success task", deletion)
+	}
+}
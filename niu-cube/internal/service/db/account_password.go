@@ -0,0 +1,298 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/tracing"
+	"github.com/solutions/niu-cube/internal/common/utils"
+	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
+	model "github.com/solutions/niu-cube/internal/protodef/model"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	// PasswordDefaultMinLength 密码默认的最小长度。
+	PasswordDefaultMinLength = 8
+	// VerifyEmailDefaultExpire 邮箱验证链接默认的有效时间。
+	VerifyEmailDefaultExpire = 24 * time.Hour
+	// ResetPasswordDefaultExpire 重置密码链接默认的有效时间。
+	ResetPasswordDefaultExpire = 30 * time.Minute
+)
+
+// passwordMaxLength bcrypt只使用密码的前72个字节，超出部分拒绝而不是静默截断。
+const passwordMaxLength = 72
+
+// NormalizeEmail 校验并规范化邮箱地址，统一为小写。
+func NormalizeEmail(email string) (string, bool) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return "", false
+	}
+	return email, true
+}
+
+func passwordMinLength() int {
+	if conf := utils.DefaultConf.Password; conf != nil && conf.MinLength > 0 {
+		return conf.MinLength
+	}
+	return PasswordDefaultMinLength
+}
+
+func bcryptCost() int {
+	if conf := utils.DefaultConf.Password; conf != nil && conf.BcryptCost > 0 {
+		return conf.BcryptCost
+	}
+	return bcrypt.DefaultCost
+}
+
+// HashPassword 校验密码强度，并返回密码的bcrypt哈希。
+func HashPassword(password string) (string, error) {
+	if len(password) < passwordMinLength() || len(password) > passwordMaxLength {
+		return "", &errors2.ServerError{Code: errors2.ServerErrorPasswordTooWeak, Summary: "password length out of range"}
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost())
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+var (
+	dummyPasswordHash     []byte
+	dummyPasswordHashOnce sync.Once
+)
+
+// compareDummyPassword 与一个随机密码的哈希比较，使邮箱不存在或账号未设置密码时的耗时与密码错误时一致。
+func compareDummyPassword(password string) {
+	dummyPasswordHashOnce.Do(func() {
+		dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte(utils.GenerateSecureToken(16)), bcryptCost())
+	})
+	_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+}
+
+// CheckPassword 判断密码与账号的密码哈希是否一致，未设置密码的账号总是不一致。
+func CheckPassword(account *model.AccountDo, password string) bool {
+	if account == nil || account.Password == "" {
+		compareDummyPassword(password)
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(password)) == nil
+}
+
+// GetAccountByEmail 使用邮箱查找账号。
+func (c *AccountService) GetAccountByEmail(ctx context.Context, xl *xlog.Logger, email string) (*model.AccountDo, error) {
+	email, ok := NormalizeEmail(email)
+	if !ok {
+		return nil, mgo.ErrNotFound
+	}
+	return c.GetAccountByFields(ctx, xl, map[string]interface{}{"email": email})
+}
+
+// CreateAccountWithPassword 使用邮箱与密码创建账号，邮箱需通过验证邮件确认。
+func (c *AccountService) CreateAccountWithPassword(ctx context.Context, xl *xlog.Logger, account *model.AccountDo, password string) error {
+	if xl == nil {
+		xl = c.xl
+	}
+	defer tracing.StartDAO(ctx, "AccountService.CreateAccountWithPassword").End()
+	email, ok := NormalizeEmail(account.Email)
+	if !ok {
+		return fmt.Errorf("invalid email %q", account.Email)
+	}
+	_, err := c.GetAccountByEmail(ctx, xl, email)
+	if err == nil {
+		xl.Infof("email %s already registered", email)
+		return &errors2.ServerError{Code: errors2.ServerErrorEmailUsed, Summary: "email already registered"}
+	}
+	if err != mgo.ErrNotFound {
+		return err
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	account.Email = email
+	account.EmailVerified = false
+	account.Password = hash
+	err = c.CreateAccount(ctx, xl, account)
+	if mgo.IsDup(err) {
+		xl.Infof("email %s registered concurrently", email)
+		return &errors2.ServerError{Code: errors2.ServerErrorEmailUsed, Summary: "email already registered"}
+	}
+	return err
+}
+
+// LoginByPassword 校验邮箱与密码，返回对应账号。邮箱不存在与密码错误返回相同的错误。
+func (c *AccountService) LoginByPassword(ctx context.Context, xl *xlog.Logger, email string, password string) (*model.AccountDo, error) {
+	if xl == nil {
+		xl = c.xl
+	}
+	defer tracing.StartDAO(ctx, "AccountService.LoginByPassword").End()
+	account, err := c.GetAccountByEmail(ctx, xl, email)
+	if err != nil && err != mgo.ErrNotFound {
+		return nil, err
+	}
+	if err == mgo.ErrNotFound {
+		account = nil
+	}
+	if !CheckPassword(account, password) {
+		xl.Infof("wrong email or password for %s", email)
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorWrongPassword, Summary: "wrong email or password"}
+	}
+	if conf := utils.DefaultConf.Password; conf != nil && conf.RequireVerifiedEmail && !account.EmailVerified {
+		xl.Infof("email %s of account %s not verified", account.Email, account.ID)
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorEmailNotVerified, Summary: "email not verified"}
+	}
+	return account, nil
+}
+
+// UpdatePassword 修改账号密码，账号未设置过密码时不校验旧密码。修改后注销其他设备上的会话。
+func (c *AccountService) UpdatePassword(ctx context.Context, xl *xlog.Logger, id string, sessionID string, oldPassword string, newPassword string) error {
+	if xl == nil {
+		xl = c.xl
+	}
+	defer tracing.StartDAO(ctx, "AccountService.UpdatePassword").End()
+	account, err := c.GetAccountByID(ctx, xl, id)
+	if err != nil {
+		return err
+	}
+	if account.Password != "" && !CheckPassword(account, oldPassword) {
+		xl.Infof("wrong old password for account %s", id)
+		return &errors2.ServerError{Code: errors2.ServerErrorWrongPassword, Summary: "wrong old password"}
+	}
+	err = c.setPassword(ctx, xl, id, newPassword)
+	if err != nil {
+		return err
+	}
+	return c.RevokeAllSessions(ctx, xl, id, sessionID)
+}
+
+// CreateEmailToken 为账号签发用于邮箱验证或重置密码的一次性token，返回token原文。
+func (c *AccountService) CreateEmailToken(ctx context.Context, xl *xlog.Logger, account *model.AccountDo, purpose model.AccountEmailTokenPurpose) (string, error) {
+	if xl == nil {
+		xl = c.xl
+	}
+	defer tracing.StartDAO(ctx, "AccountService.CreateEmailToken").End()
+	expire := VerifyEmailDefaultExpire
+	if purpose == model.AccountEmailTokenResetPassword {
+		expire = ResetPasswordDefaultExpire
+	}
+	if conf := utils.DefaultConf.Password; conf != nil {
+		if purpose == model.AccountEmailTokenVerifyEmail && conf.VerifyEmailExpireSecond > 0 {
+			expire = time.Duration(conf.VerifyEmailExpireSecond) * time.Second
+		}
+		if purpose == model.AccountEmailTokenResetPassword && conf.ResetPasswordExpireSecond > 0 {
+			expire = time.Duration(conf.ResetPasswordExpireSecond) * time.Second
+		}
+	}
+	// 同一用途只保留最新的token。
+	_, err := c.emailTokenColl.WithContext(ctx).RemoveAll(bson.M{"accountId": account.ID, "purpose": purpose})
+	if err != nil {
+		xl.Errorf("failed to remove old %s tokens of account %s, error %v", purpose, account.ID, err)
+		return "", err
+	}
+	token := utils.GenerateSecureToken(32)
+	now := time.Now()
+	record := &model.AccountEmailTokenDo{
+		ID:         hashToken(token),
+		AccountID:  account.ID,
+		Email:      account.Email,
+		Purpose:    purpose,
+		CreateTime: now,
+		ExpireAt:   now.Add(expire),
+	}
+	err = c.emailTokenColl.WithContext(ctx).Insert(record)
+	if err != nil {
+		xl.Errorf("failed to save %s token of account %s, error %v", purpose, account.ID, err)
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeEmailToken 校验并删除一次性token，token不存在或用途不符时返回ServerErrorTokenInvalid，已过期时返回ServerErrorTokenExpired。
+func (c *AccountService) consumeEmailToken(ctx context.Context, xl *xlog.Logger, token string, purpose model.AccountEmailTokenPurpose) (*model.AccountEmailTokenDo, error) {
+	record := model.AccountEmailTokenDo{}
+	_, err := c.emailTokenColl.WithContext(ctx).Find(bson.M{"_id": hashToken(token), "purpose": purpose}).Apply(mgo.Change{Remove: true}, &record)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			xl.Infof("%s token not found", purpose)
+			return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenInvalid, Summary: "invalid token"}
+		}
+		xl.Errorf("failed to consume %s token, error %v", purpose, err)
+		return nil, err
+	}
+	if record.ExpireAt.Before(time.Now()) {
+		xl.Infof("%s token of account %s expired", purpose, record.AccountID)
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenExpired, Summary: "token expired"}
+	}
+	return &record, nil
+}
+
+// VerifyEmail 使用验证邮件中的token确认邮箱。
+func (c *AccountService) VerifyEmail(ctx context.Context, xl *xlog.Logger, token string) (*model.AccountDo, error) {
+	if xl == nil {
+		xl = c.xl
+	}
+	defer tracing.StartDAO(ctx, "AccountService.VerifyEmail").End()
+	record, err := c.consumeEmailToken(ctx, xl, token, model.AccountEmailTokenVerifyEmail)
+	if err != nil {
+		return nil, err
+	}
+	// 邮箱在发出验证邮件后被修改时，旧邮箱的验证链接失效。
+	err = c.accountColl.WithContext(ctx).Update(bson.M{"_id": record.AccountID, "email": record.Email}, bson.M{"$set": bson.M{"emailVerified": true}})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenInvalid, Summary: "email changed"}
+		}
+		xl.Errorf("failed to verify email of account %s, error %v", record.AccountID, err)
+		return nil, err
+	}
+	return c.GetAccountByID(ctx, xl, record.AccountID)
+}
+
+// ResetPassword 使用重置密码邮件中的token设置新密码，并注销账号所有会话。
+// 能收到重置邮件即证明拥有该邮箱，同时将邮箱标记为已验证。
+func (c *AccountService) ResetPassword(ctx context.Context, xl *xlog.Logger, token string, newPassword string) error {
+	if xl == nil {
+		xl = c.xl
+	}
+	defer tracing.StartDAO(ctx, "AccountService.ResetPassword").End()
+	// 先校验密码强度，避免token被消耗后才发现密码不合法。
+	if _, err := HashPassword(newPassword); err != nil {
+		return err
+	}
+	record, err := c.consumeEmailToken(ctx, xl, token, model.AccountEmailTokenResetPassword)
+	if err != nil {
+		return err
+	}
+	err = c.setPassword(ctx, xl, record.AccountID, newPassword)
+	if err != nil {
+		return err
+	}
+	err = c.accountColl.WithContext(ctx).Update(bson.M{"_id": record.AccountID, "email": record.Email}, bson.M{"$set": bson.M{"emailVerified": true}})
+	if err != nil && err != mgo.ErrNotFound {
+		xl.Errorf("failed to verify email of account %s, error %v", record.AccountID, err)
+	}
+	return c.RevokeAllSessions(ctx, xl, record.AccountID, "")
+}
+
+func (c *AccountService) setPassword(ctx context.Context, xl *xlog.Logger, id string, password string) error {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	err = c.accountColl.WithContext(ctx).UpdateId(id, bson.M{"$set": bson.M{"password": hash}})
+	if err != nil {
+		xl.Errorf("failed to update password of account %s, error %v", id, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/solutions/niu-cube/internal/common/utils"
+	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// serverErrorCode 返回ServerError的错误码，其他错误返回0。
+func serverErrorCode(err error) int {
+	if serverErr, ok := err.(*errors2.ServerError); ok {
+		return serverErr.Code
+	}
+	return 0
+}
+
+// withPasswordConfig 在测试期间使用给定的密码配置，并使用最低的bcrypt cost加快测试。
+func withPasswordConfig(t *testing.T, conf utils.PasswordConfig) {
+	t.Helper()
+	old := utils.DefaultConf.Password
+	if conf.BcryptCost == 0 {
+		conf.BcryptCost = 4
+	}
+	utils.DefaultConf.Password = &conf
+	t.Cleanup(func() { utils.DefaultConf.Password = old })
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	cases := []struct {
+		email  string
+		want   string
+		wantOK bool
+	}{
+		{email: "user@example.com", want: "user@example.com", wantOK: true},
+		{email: "  User@Example.COM ", want: "user@example.com", wantOK: true},
+		{email: "user"},
+		{email: "User <user@example.com>"},
+		{email: ""},
+	}
+	for _, tc := range cases {
+		got, ok := NormalizeEmail(tc.email)
+		if got != tc.want || ok != tc.wantOK {
+			t.Fatalf("NormalizeEmail(%q) = (%q, %v), want (%q, %v)", tc.email, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestHashAndCheckPassword(t *testing.T) {
+	withPasswordConfig(t, utils.PasswordConfig{MinLength: 8})
+	cases := []struct {
+		name     string
+		password string
+		wantErr  bool
+	}{
+		{name: "ok", password: "password1"},
+		{name: "too short", password: "short", wantErr: true},
+		{name: "max length", password: strings.Repeat("a", passwordMaxLength)},
+		{name: "too long", password: strings.Repeat("a", passwordMaxLength+1), wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			hash, err := HashPassword(tc.password)
+			if tc.wantErr {
+				if serverErrorCode(err) != errors2.ServerErrorPasswordTooWeak {
+					t.Fatalf("HashPassword error = %v, want password too weak", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("HashPassword: %v", err)
+			}
+			account := &model.AccountDo{Password: hash}
+			if !CheckPassword(account, tc.password) || CheckPassword(account, "x"+tc.password[1:]) {
+				t.Fatalf("CheckPassword mismatch for %q", tc.password)
+			}
+		})
+	}
+	if CheckPassword(&model.AccountDo{}, "password1") || CheckPassword(nil, "password1") {
+		t.Fatalf("accounts without password should never match")
+	}
+}
+
+func TestLoginByPassword(t *testing.T) {
+	withPasswordConfig(t, utils.PasswordConfig{RequireVerifiedEmail: true})
+	s, err := NewAccountService(testMongoConfig(t), nil)
+	if err != nil {
+		t.Fatalf("NewAccountService: %v", err)
+	}
+	verified := &model.AccountDo{ID: utils.GenerateID(), Email: "Verified@Example.com"}
+	if err := s.CreateAccountWithPassword(context.Background(), nil, verified, "password1"); err != nil {
+		t.Fatalf("CreateAccountWithPassword: %v", err)
+	}
+	if err := s.accountColl.UpdateId(verified.ID, bson.M{"$set": bson.M{"emailVerified": true}}); err != nil {
+		t.Fatalf("verify email: %v", err)
+	}
+	unverified := &model.AccountDo{ID: utils.GenerateID(), Email: "unverified@example.com"}
+	if err := s.CreateAccountWithPassword(context.Background(), nil, unverified, "password1"); err != nil {
+		t.Fatalf("CreateAccountWithPassword: %v", err)
+	}
+	duplicated := &model.AccountDo{ID: utils.GenerateID(), Email: "verified@example.com"}
+	if err := s.CreateAccountWithPassword(context.Background(), nil, duplicated, "password1"); serverErrorCode(err) != errors2.ServerErrorEmailUsed {
+		t.Fatalf("duplicated email error = %v", err)
+	}
+	// 绕过检查直接插入时由唯一索引拒绝。
+	if err := s.CreateAccount(context.Background(), nil, &model.AccountDo{ID: utils.GenerateID(), Email: "verified@example.com"}); err == nil {
+		t.Fatalf("unique email index should reject duplicated email")
+	}
+	// 未设置邮箱的账号不受唯一索引影响。
+	for i := 0; i < 2; i++ {
+		if err := s.CreateAccount(context.Background(), nil, &model.AccountDo{ID: utils.GenerateID()}); err != nil {
+			t.Fatalf("CreateAccount without email: %v", err)
+		}
+	}
+
+	cases := []struct {
+		name     string
+		email    string
+		password string
+		wantID   string
+		wantCode int
+	}{
+		{name: "ok", email: " VERIFIED@example.com", password: "password1", wantID: verified.ID},
+		{name: "wrong password", email: "verified@example.com", password: "password2", wantCode: errors2.ServerErrorWrongPassword},
+		{name: "unknown email", email: "nobody@example.com", password: "password1", wantCode: errors2.ServerErrorWrongPassword},
+		{name: "invalid email", email: "nobody", password: "password1", wantCode: errors2.ServerErrorWrongPassword},
+		{name: "unverified email", email: "unverified@example.com", password: "password1", wantCode: errors2.ServerErrorEmailNotVerified},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			account, err := s.LoginByPassword(context.Background(), nil, tc.email, tc.password)
+			if tc.wantCode != 0 {
+				if serverErrorCode(err) != tc.wantCode {
+					t.Fatalf("LoginByPassword error = %v, want code %d", err, tc.wantCode)
+				}
+				return
+			}
+			if err != nil || account.ID != tc.wantID {
+				t.Fatalf("LoginByPassword = (%v, %v), want account %s", account, err, tc.wantID)
+			}
+		})
+	}
+}
+
+func TestEmailTokenFlow(t *testing.T) {
+	withPasswordConfig(t, utils.PasswordConfig{})
+	s, err := NewAccountService(testMongoConfig(t), nil)
+	if err != nil {
+		t.Fatalf("NewAccountService: %v", err)
+	}
+	account := &model.AccountDo{ID: utils.GenerateID(), Email: "user@example.com"}
+	if err := s.CreateAccountWithPassword(context.Background(), nil, account, "password1"); err != nil {
+		t.Fatalf("CreateAccountWithPassword: %v", err)
+	}
+	login, err := s.AccountLogin(context.Background(), nil, account.ID, "", model.DeviceInfo{DeviceID: "device-1"})
+	if err != nil {
+		t.Fatalf("AccountLogin: %v", err)
+	}
+
+	superseded, _ := s.CreateEmailToken(context.Background(), nil, account, model.AccountEmailTokenVerifyEmail)
+	verifyToken, _ := s.CreateEmailToken(context.Background(), nil, account, model.AccountEmailTokenVerifyEmail)
+	resetToken, _ := s.CreateEmailToken(context.Background(), nil, account, model.AccountEmailTokenResetPassword)
+	// 手动插入一条已过期的记录，再次签发会删除同一用途的旧token，所以在签发之后插入。
+	expiredToken := utils.GenerateSecureToken(32)
+	if err := s.emailTokenColl.Insert(&model.AccountEmailTokenDo{
+		ID:        hashToken(expiredToken),
+		AccountID: account.ID,
+		Email:     account.Email,
+		Purpose:   model.AccountEmailTokenResetPassword,
+		ExpireAt:  time.Now().Add(-time.Minute),
+	}); err != nil {
+		t.Fatalf("insert expired token: %v", err)
+	}
+
+	steps := []struct {
+		name     string
+		run      func() error
+		wantCode int
+	}{
+		{name: "superseded token", run: func() error { _, err := s.VerifyEmail(context.Background(), nil, superseded); return err }, wantCode: errors2.ServerErrorTokenInvalid},
+		{name: "wrong purpose", run: func() error { _, err := s.VerifyEmail(context.Background(), nil, resetToken); return err }, wantCode: errors2.ServerErrorTokenInvalid},
+		{name: "verify email", run: func() error { _, err := s.VerifyEmail(context.Background(), nil, verifyToken); return err }},
+		{name: "verify token used twice", run: func() error { _, err := s.VerifyEmail(context.Background(), nil, verifyToken); return err }, wantCode: errors2.ServerErrorTokenInvalid},
+		{name: "expired reset token", run: func() error { return s.ResetPassword(context.Background(), nil, expiredToken, "password2") }, wantCode: errors2.ServerErrorTokenExpired},
+		{name: "weak new password keeps token", run: func() error { return s.ResetPassword(context.Background(), nil, resetToken, "short") }, wantCode: errors2.ServerErrorPasswordTooWeak},
+		{name: "reset password", run: func() error { return s.ResetPassword(context.Background(), nil, resetToken, "password2") }},
+		{name: "reset token used twice", run: func() error { return s.ResetPassword(context.Background(), nil, resetToken, "password3") }, wantCode: errors2.ServerErrorTokenInvalid},
+	}
+	for _, step := range steps {
+		err := step.run()
+		if step.wantCode != 0 {
+			if serverErrorCode(err) != step.wantCode {
+				t.Fatalf("%s: error = %v, want code %d", step.name, err, step.wantCode)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+	}
+
+	got, err := s.GetAccountByID(context.Background(), nil, account.ID)
+	if err != nil {
+		t.Fatalf("GetAccountByID: %v", err)
+	}
+	if !got.EmailVerified || !CheckPassword(got, "password2") {
+		t.Fatalf("email should be verified and password reset, got %+v", got)
+	}
+	if _, err := s.ParseLoginToken(nil, login.Token); err == nil {
+		t.Fatalf("sessions should be revoked after password reset")
+	}
+}
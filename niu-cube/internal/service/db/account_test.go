@@ -0,0 +1,198 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/utils"
+	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+)
+
+// newTestTokenService 返回只用于签发与校验登录token的AccountService，不连接数据库。
+func newTestTokenService(key string) *AccountService {
+	return &AccountService{
+		jwtKey:     []byte(key),
+		revocation: &TokenRevocationList{revoked: make(map[string]time.Time)},
+		xl:         xlog.New("test-account"),
+	}
+}
+
+func TestParseLoginToken(t *testing.T) {
+	s := newTestTokenService("test-key")
+	account := &model.AccountDo{ID: "user-1", Phone: "13800000000"}
+	now := time.Now()
+	sign := func(s *AccountService, sessionID string, tokenID string, expireAt time.Time) string {
+		token, err := s.makeLoginToken(nil, account, sessionID, tokenID, now, expireAt)
+		if err != nil {
+			t.Fatalf("makeLoginToken: %v", err)
+		}
+		return token
+	}
+	s.revocation.revoked["revoked-jti"] = now.Add(time.Hour)
+	noneToken, err := jwt.NewWithClaims(jwt.SigningMethodNone, LoginClaims{
+		UserID:         account.ID,
+		SessionID:      "session-1",
+		StandardClaims: jwt.StandardClaims{Id: "none-jti", ExpiresAt: now.Add(time.Hour).Unix()},
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("sign none token: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		token    string
+		wantCode int
+	}{
+		{name: "valid", token: sign(s, "session-1", "jti-1", now.Add(time.Hour))},
+		{name: "expired", token: sign(s, "session-1", "jti-2", now.Add(-time.Minute)), wantCode: errors2.ServerErrorTokenExpired},
+		{name: "revoked", token: sign(s, "session-1", "revoked-jti", now.Add(time.Hour)), wantCode: errors2.ServerErrorTokenInvalid},
+		{name: "other key", token: sign(newTestTokenService("other-key"), "session-1", "jti-3", now.Add(time.Hour)), wantCode: errors2.ServerErrorTokenInvalid},
+		{name: "no session", token: sign(s, "", "jti-4", now.Add(time.Hour)), wantCode: errors2.ServerErrorTokenInvalid},
+		{name: "no jti", token: sign(s, "session-1", "", now.Add(time.Hour)), wantCode: errors2.ServerErrorTokenInvalid},
+		{name: "alg none", token: noneToken, wantCode: errors2.ServerErrorTokenInvalid},
+		{name: "tampered", token: sign(s, "session-1", "jti-5", now.Add(time.Hour)) + "x", wantCode: errors2.ServerErrorTokenInvalid},
+		{name: "garbage", token: "not-a-token", wantCode: errors2.ServerErrorTokenInvalid},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims, err := s.ParseLoginToken(nil, tc.token)
+			if tc.wantCode == 0 {
+				if err != nil {
+					t.Fatalf("ParseLoginToken: %v", err)
+				}
+				if claims.UserID != account.ID || claims.SessionID != "session-1" || claims.Phone != account.Phone {
+					t.Fatalf("unexpected claims %+v", claims)
+				}
+				return
+			}
+			serverErr, ok := err.(*errors2.ServerError)
+			if !ok || serverErr.Code != tc.wantCode {
+				t.Fatalf("ParseLoginToken error = %v, want code %d", err, tc.wantCode)
+			}
+		})
+	}
+}
+
+func TestTokenRevocationListMerge(t *testing.T) {
+	now := time.Now()
+	l := &TokenRevocationList{
+		revoked: map[string]time.Time{
+			"active":  now.Add(time.Hour),
+			"expired": now.Add(-time.Hour),
+		},
+		xl: xlog.New("test-revocation"),
+	}
+	l.merge([]model.RevokedTokenDo{
+		{ID: "synced", ExpireAt: now.Add(time.Hour)},
+		{ID: "synced-expired", ExpireAt: now.Add(-time.Second)},
+	}, now)
+	cases := map[string]bool{
+		"active":         true,
+		"synced":         true,
+		"expired":        false,
+		"synced-expired": false,
+		"unknown":        false,
+	}
+	for tokenID, want := range cases {
+		if got := l.IsRevoked(tokenID); got != want {
+			t.Errorf("IsRevoked(%q) = %v, want %v", tokenID, got, want)
+		}
+	}
+}
+
+func TestRefreshLoginRotation(t *testing.T) {
+	conf := testMongoConfig(t)
+	s, err := NewAccountService(conf, nil)
+	if err != nil {
+		t.Fatalf("NewAccountService: %v", err)
+	}
+	account := &model.AccountDo{ID: utils.GenerateID(), Phone: "13800000001"}
+	if err := s.CreateAccount(context.Background(), nil, account); err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+	login, err := s.AccountLogin(context.Background(), nil, account.ID, "", model.DeviceInfo{DeviceID: "device-1"})
+	if err != nil {
+		t.Fatalf("AccountLogin: %v", err)
+	}
+	refreshed, err := s.RefreshLogin(context.Background(), nil, login.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshLogin: %v", err)
+	}
+	if refreshed.ID != login.ID || refreshed.RefreshToken == login.RefreshToken || refreshed.Token == login.Token {
+		t.Fatalf("refresh should keep the session and rotate both tokens")
+	}
+	if _, err := s.ParseLoginToken(nil, login.Token); err == nil {
+		t.Fatalf("old access token should be revoked after refresh")
+	}
+	if _, err := s.ParseLoginToken(nil, refreshed.Token); err != nil {
+		t.Fatalf("new access token should be valid, error %v", err)
+	}
+	if _, err := s.RefreshLogin(context.Background(), nil, login.RefreshToken); err == nil {
+		t.Fatalf("used refresh token should be rejected")
+	}
+	if err := s.AccountLogout(context.Background(), nil, account.ID, refreshed.ID); err != nil {
+		t.Fatalf("AccountLogout: %v", err)
+	}
+	if _, err := s.RefreshLogin(context.Background(), nil, refreshed.RefreshToken); err == nil {
+		t.Fatalf("refresh token should be invalid after logout")
+	}
+	if _, err := s.ParseLoginToken(nil, refreshed.Token); err == nil {
+		t.Fatalf("access token should be revoked after logout")
+	}
+}
+
+func TestSessionCapAndEviction(t *testing.T) {
+	conf := testMongoConfig(t)
+	s, err := NewAccountService(conf, nil)
+	if err != nil {
+		t.Fatalf("NewAccountService: %v", err)
+	}
+	s.maxSessions = 2
+	account := &model.AccountDo{ID: utils.GenerateID(), Phone: "13800000002"}
+	if err := s.CreateAccount(context.Background(), nil, account); err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+	logins := make(map[string]*model.AccountTokenDo)
+	for _, device := range []string{"device-1", "device-2", "device-3"} {
+		login, err := s.AccountLogin(context.Background(), nil, account.ID, "", model.DeviceInfo{DeviceID: device})
+		if err != nil {
+			t.Fatalf("AccountLogin on %s: %v", device, err)
+		}
+		logins[device] = login
+		// 保证各会话的活跃时间不同。
+		time.Sleep(10 * time.Millisecond)
+	}
+	sessions, err := s.ListSessions(context.Background(), nil, account.ID)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].DeviceID != "device-3" || sessions[1].DeviceID != "device-2" {
+		t.Fatalf("sessions = %+v, want device-3 and device-2", sessions)
+	}
+	if _, err := s.ParseLoginToken(nil, logins["device-1"].Token); err == nil {
+		t.Fatalf("access token of evicted session should be revoked")
+	}
+	if _, err := s.RefreshLogin(context.Background(), nil, logins["device-1"].RefreshToken); err == nil {
+		t.Fatalf("refresh token of evicted session should be invalid")
+	}
+
+	// 同一设备重复登录复用会话，不占用新的名额。
+	again, err := s.AccountLogin(context.Background(), nil, account.ID, "", model.DeviceInfo{DeviceID: "device-2"})
+	if err != nil {
+		t.Fatalf("AccountLogin again: %v", err)
+	}
+	if again.ID != logins["device-2"].ID {
+		t.Fatalf("login on the same device should reuse session %s, got %s", logins["device-2"].ID, again.ID)
+	}
+	if _, err := s.ParseLoginToken(nil, logins["device-2"].Token); err == nil {
+		t.Fatalf("previous access token of the reused session should be revoked")
+	}
+	sessions, err = s.ListSessions(context.Background(), nil, account.ID)
+	if err != nil || len(sessions) != 2 || sessions[0].ID != again.ID {
+		t.Fatalf("sessions after re-login = %+v, error %v", sessions, err)
+	}
+}
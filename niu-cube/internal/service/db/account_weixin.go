@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/tracing"
+	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
+	model "github.com/solutions/niu-cube/internal/protodef/model"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// GetAccountByWeixin 查找与微信用户关联的账号，openid找不到时再按unionid查找。
+func (c *AccountService) GetAccountByWeixin(ctx context.Context, xl *xlog.Logger, openID string, unionID string) (*model.AccountDo, error) {
+	account, err := c.GetAccountByFields(ctx, xl, map[string]interface{}{"weixinOpenId": openID})
+	if err != mgo.ErrNotFound || unionID == "" {
+		return account, err
+	}
+	return c.GetAccountByFields(ctx, xl, map[string]interface{}{"weixinUnionId": unionID})
+}
+
+// GetOrCreateAccountByWeixin 查找或创建与微信用户关联的账号，返回账号以及是否为新建账号。
+// 微信用户未关联账号时，若提供了手机号则关联到该手机号已注册的账号；手机号未注册或未提供时使用newAccount创建账号。
+// 已关联的账号尚未设置手机号时，绑定提供的手机号。
+func (c *AccountService) GetOrCreateAccountByWeixin(ctx context.Context, xl *xlog.Logger, openID string, unionID string, phone string, newAccount *model.AccountDo) (*model.AccountDo, bool, error) {
+	if xl == nil {
+		xl = c.xl
+	}
+	defer tracing.StartDAO(ctx, "AccountService.GetOrCreateAccountByWeixin").End()
+	account, err := c.GetAccountByWeixin(ctx, xl, openID, unionID)
+	if err != nil && err != mgo.ErrNotFound {
+		return nil, false, err
+	}
+	if err == nil {
+		update := bson.M{}
+		if account.WeixinOpenID != openID {
+			account.WeixinOpenID = openID
+			update["weixinOpenId"] = openID
+		}
+		if unionID != "" && account.WeixinUnionID != unionID {
+			account.WeixinUnionID = unionID
+			update["weixinUnionId"] = unionID
+		}
+		if phone != "" && account.Phone == "" {
+			err = c.checkPhoneUnused(ctx, xl, phone)
+			if err != nil {
+				return nil, false, err
+			}
+			account.Phone = phone
+			update["phone"] = phone
+		}
+		if len(update) > 0 {
+			err = c.accountColl.WithContext(ctx).UpdateId(account.ID, bson.M{"$set": update})
+			if err != nil {
+				xl.Errorf("failed to link weixin user %s to account %s, error %v", openID, account.ID, err)
+				return nil, false, err
+			}
+		}
+		return account, false, nil
+	}
+
+	if phone != "" {
+		account, err = c.GetAccountByPhone(ctx, xl, phone)
+		if err != nil && err != mgo.ErrNotFound {
+			return nil, false, err
+		}
+		if err == nil {
+			if account.WeixinOpenID != "" {
+				xl.Infof("phone %s already bound to weixin user %s", phone, account.WeixinOpenID)
+				return nil, false, &errors2.ServerError{Code: errors2.ServerErrorPhoneUsed, Summary: "phone bound to another weixin user"}
+			}
+			account.WeixinOpenID = openID
+			account.WeixinUnionID = unionID
+			err = c.accountColl.WithContext(ctx).UpdateId(account.ID, bson.M{"$set": bson.M{"weixinOpenId": openID, "weixinUnionId": unionID}})
+			if err != nil {
+				xl.Errorf("failed to link weixin user %s to account %s, error %v", openID, account.ID, err)
+				return nil, false, err
+			}
+			return account, false, nil
+		}
+	}
+
+	newAccount.Phone = phone
+	newAccount.WeixinOpenID = openID
+	newAccount.WeixinUnionID = unionID
+	err = c.CreateAccount(ctx, xl, newAccount)
+	if err != nil {
+		return nil, false, err
+	}
+	return newAccount, true, nil
+}
+
+func (c *AccountService) checkPhoneUnused(ctx context.Context, xl *xlog.Logger, phone string) error {
+	_, err := c.GetAccountByPhone(ctx, xl, phone)
+	if err == nil {
+		xl.Infof("phone %s already registered", phone)
+		return &errors2.ServerError{Code: errors2.ServerErrorPhoneUsed, Summary: "phone already registered"}
+	}
+	if err != mgo.ErrNotFound {
+		return err
+	}
+	return nil
+}
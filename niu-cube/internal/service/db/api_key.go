@@ -0,0 +1,184 @@
+package db
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
+	"github.com/solutions/niu-cube/internal/common/utils"
+	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
+	model "github.com/solutions/niu-cube/internal/protodef/model"
+	dao "github.com/solutions/niu-cube/internal/service/db/dao"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ApiKeyService 创建、轮换、吊销与校验API key。
+type ApiKeyService struct {
+	mongoClient *mongodb.Manager
+	apiKeyColl  *mongodb.Collection
+	xl          *xlog.Logger
+}
+
+func NewApiKeyService(conf utils.MongoConfig, xl *xlog.Logger) (*ApiKeyService, error) {
+	if xl == nil {
+		xl = xlog.New("niu-cube-api-key-db")
+	}
+	mongoClient, err := mongodb.Get(&conf)
+	if err != nil {
+		xl.Errorf("failed to create mongo client, error %v", err)
+		return nil, err
+	}
+	return &ApiKeyService{
+		mongoClient: mongoClient,
+		apiKeyColl:  mongoClient.C(dao.CollectionApiKey),
+		xl:          xl,
+	}, nil
+}
+
+// CreateApiKey 保存新的API key，返回key原文。key原文只在创建与轮换时返回，之后无法再次获取。
+func (s *ApiKeyService) CreateApiKey(ctx context.Context, xl *xlog.Logger, apiKey *model.ApiKeyDo) (string, error) {
+	if xl == nil {
+		xl = s.xl
+	}
+	defer tracing.StartDAO(ctx, "ApiKeyService.CreateApiKey").End()
+	secret := utils.GenerateSecureToken(24)
+	apiKey.ID = utils.GenerateID()
+	apiKey.SecretHash = hashToken(secret)
+	apiKey.CreateTime = time.Now()
+	err := s.apiKeyColl.WithContext(ctx).Insert(apiKey)
+	if err != nil {
+		xl.Errorf("failed to insert api key %s, error %v", apiKey.Name, err)
+		return "", err
+	}
+	return apiKey.ID + "." + secret, nil
+}
+
+// ListApiKeys 列出所有API key，包括已吊销的。
+func (s *ApiKeyService) ListApiKeys(ctx context.Context, xl *xlog.Logger) ([]model.ApiKeyDo, error) {
+	if xl == nil {
+		xl = s.xl
+	}
+	defer tracing.StartDAO(ctx, "ApiKeyService.ListApiKeys").End()
+	apiKeys := make([]model.ApiKeyDo, 0)
+	err := s.apiKeyColl.WithContext(ctx).Find(nil).Sort("-createTime").All(&apiKeys)
+	if err != nil {
+		xl.Errorf("failed to list api keys, error %v", err)
+		return nil, err
+	}
+	return apiKeys, nil
+}
+
+// RotateApiKey 为API key生成新的secret，旧的key原文立即失效，返回新的key原文。
+func (s *ApiKeyService) RotateApiKey(ctx context.Context, xl *xlog.Logger, id string) (*model.ApiKeyDo, string, error) {
+	if xl == nil {
+		xl = s.xl
+	}
+	defer tracing.StartDAO(ctx, "ApiKeyService.RotateApiKey").End()
+	secret := utils.GenerateSecureToken(24)
+	apiKey := model.ApiKeyDo{}
+	_, err := s.apiKeyColl.WithContext(ctx).Find(bson.M{"_id": id, "revokeTime": bson.M{"$exists": false}}).Apply(mgo.Change{
+		Update:    bson.M{"$set": bson.M{"secretHash": hashToken(secret), "rotateTime": time.Now()}},
+		ReturnNew: true,
+	}, &apiKey)
+	if err != nil {
+		if err != mgo.ErrNotFound {
+			xl.Errorf("failed to rotate api key %s, error %v", id, err)
+		}
+		return nil, "", err
+	}
+	return &apiKey, apiKey.ID + "." + secret, nil
+}
+
+// RevokeApiKey 吊销API key，吊销后不能再使用或轮换。
+func (s *ApiKeyService) RevokeApiKey(ctx context.Context, xl *xlog.Logger, id string) error {
+	if xl == nil {
+		xl = s.xl
+	}
+	defer tracing.StartDAO(ctx, "ApiKeyService.RevokeApiKey").End()
+	err := s.apiKeyColl.WithContext(ctx).Update(bson.M{"_id": id, "revokeTime": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"revokeTime": time.Now()}})
+	if err != nil && err != mgo.ErrNotFound {
+		xl.Errorf("failed to revoke api key %s, error %v", id, err)
+	}
+	return err
+}
+
+// Authenticate 校验API key原文与调用方IP，成功时记录最近使用时间与IP。
+func (s *ApiKeyService) Authenticate(ctx context.Context, xl *xlog.Logger, key string, ip string) (*model.ApiKeyDo, error) {
+	if xl == nil {
+		xl = s.xl
+	}
+	defer tracing.StartDAO(ctx, "ApiKeyService.Authenticate").End()
+	parts := strings.SplitN(key, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenInvalid, Summary: "malformed api key"}
+	}
+	apiKey := model.ApiKeyDo{}
+	err := s.apiKeyColl.WithContext(ctx).FindId(parts[0]).One(&apiKey)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenInvalid, Summary: "api key not found"}
+		}
+		xl.Errorf("failed to get api key %s, error %v", parts[0], err)
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(parts[1])), []byte(apiKey.SecretHash)) != 1 || apiKey.Revoked() {
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenInvalid, Summary: "api key invalid or revoked"}
+	}
+	now := time.Now()
+	if apiKey.Expired(now) {
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenExpired, Summary: "api key expired"}
+	}
+	if !IPAllowed(apiKey.AllowedIPs, ip) {
+		xl.Infof("api key %s used from %s, not in allowlist %v", apiKey.ID, ip, apiKey.AllowedIPs)
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorUserNoPermission, Summary: "ip not allowed"}
+	}
+	err = s.apiKeyColl.WithContext(ctx).UpdateId(apiKey.ID, bson.M{"$set": bson.M{"lastUsedTime": now, "lastUsedIp": ip}})
+	if err != nil {
+		xl.Warnf("failed to update last used time of api key %s, error %v", apiKey.ID, err)
+	}
+	return &apiKey, nil
+}
+
+// IPAllowed 判断ip是否在IP或CIDR列表中，列表为空时不限制。
+func IPAllowed(allowlist []string, ip string) bool {
+	if len(allowlist) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, allowed := range allowlist {
+		if strings.Contains(allowed, "/") {
+			_, network, err := net.ParseCIDR(allowed)
+			if err == nil && network.Contains(parsed) {
+				return true
+			}
+			continue
+		}
+		if allowedIP := net.ParseIP(allowed); allowedIP != nil && allowedIP.Equal(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidIPAllowlist 判断列表中每一项都是合法的IP或CIDR。
+func ValidIPAllowlist(allowlist []string) bool {
+	for _, allowed := range allowlist {
+		if strings.Contains(allowed, "/") {
+			if _, _, err := net.ParseCIDR(allowed); err != nil {
+				return false
+			}
+		} else if net.ParseIP(allowed) == nil {
+			return false
+		}
+	}
+	return true
+}
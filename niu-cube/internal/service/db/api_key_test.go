@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+)
+
+func TestIPAllowed(t *testing.T) {
+	cases := []struct {
+		allowlist []string
+		ip        string
+		want      bool
+	}{
+		{nil, "192.0.2.1", true},
+		{[]string{"192.0.2.1"}, "192.0.2.1", true},
+		{[]string{"192.0.2.1"}, "192.0.2.2", false},
+		{[]string{"10.0.0.0/8"}, "10.1.2.3", true},
+		{[]string{"10.0.0.0/8"}, "11.0.0.1", false},
+		{[]string{"2001:db8::/32"}, "2001:db8::1", true},
+		{[]string{"192.0.2.1"}, "not-an-ip", false},
+		{[]string{"192.0.2.1"}, "", false},
+		{[]string{"bad-entry", "192.0.2.1"}, "192.0.2.1", true},
+	}
+	for _, tc := range cases {
+		if got := IPAllowed(tc.allowlist, tc.ip); got != tc.want {
+			t.Errorf("IPAllowed(%v, %q) = %v, want %v", tc.allowlist, tc.ip, got, tc.want)
+		}
+	}
+}
+
+func TestApiKeyAuthenticate(t *testing.T) {
+	conf := testMongoConfig(t)
+	s, err := NewApiKeyService(conf, nil)
+	if err != nil {
+		t.Fatalf("NewApiKeyService: %v", err)
+	}
+
+	apiKey := &model.ApiKeyDo{Name: "importer", Permissions: []model.Permission{model.PermissionSongManage}, AllowedIPs: []string{"10.0.0.0/8"}}
+	key, err := s.CreateApiKey(context.Background(), nil, apiKey)
+	if err != nil {
+		t.Fatalf("CreateApiKey: %v", err)
+	}
+
+	got, err := s.Authenticate(context.Background(), nil, key, "10.1.2.3")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if !got.HasPermission(model.PermissionSongManage) || got.HasPermission(model.PermissionExamManage) {
+		t.Fatalf("unexpected permissions %v", got.Permissions)
+	}
+	if _, err := s.Authenticate(context.Background(), nil, key, "192.0.2.1"); serverErrorCode(err) != errors2.ServerErrorUserNoPermission {
+		t.Fatalf("ip outside allowlist: err = %v", err)
+	}
+	if _, err := s.Authenticate(context.Background(), nil, key+"x", "10.1.2.3"); serverErrorCode(err) != errors2.ServerErrorTokenInvalid {
+		t.Fatalf("wrong secret: err = %v", err)
+	}
+	if _, err := s.Authenticate(context.Background(), nil, "malformed", "10.1.2.3"); serverErrorCode(err) != errors2.ServerErrorTokenInvalid {
+		t.Fatalf("malformed key: err = %v", err)
+	}
+
+	_, rotated, err := s.RotateApiKey(context.Background(), nil, apiKey.ID)
+	if err != nil {
+		t.Fatalf("RotateApiKey: %v", err)
+	}
+	if _, err := s.Authenticate(context.Background(), nil, key, "10.1.2.3"); serverErrorCode(err) != errors2.ServerErrorTokenInvalid {
+		t.Fatalf("old key after rotation: err = %v", err)
+	}
+	if _, err := s.Authenticate(context.Background(), nil, rotated, "10.1.2.3"); err != nil {
+		t.Fatalf("rotated key: %v", err)
+	}
+	if err := s.RevokeApiKey(context.Background(), nil, apiKey.ID); err != nil {
+		t.Fatalf("RevokeApiKey: %v", err)
+	}
+	if _, err := s.Authenticate(context.Background(), nil, rotated, "10.1.2.3"); serverErrorCode(err) != errors2.ServerErrorTokenInvalid {
+		t.Fatalf("revoked key: err = %v", err)
+	}
+
+	expired := &model.ApiKeyDo{Name: "expired", ExpireTime: time.Now().Add(-time.Minute)}
+	expiredKey, err := s.CreateApiKey(context.Background(), nil, expired)
+	if err != nil {
+		t.Fatalf("CreateApiKey: %v", err)
+	}
+	if _, err := s.Authenticate(context.Background(), nil, expiredKey, "10.1.2.3"); serverErrorCode(err) != errors2.ServerErrorTokenExpired {
+		t.Fatalf("expired key: err = %v", err)
+	}
+}
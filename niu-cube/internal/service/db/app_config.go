@@ -1,8 +1,10 @@
 package db
 
 import (
+	"context"
 	"fmt"
 	"github.com/solutions/niu-cube/cmd/niu-cube-interview/service/cloud/maxim"
+	"github.com/solutions/niu-cube/internal/common/metrics"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"gopkg.in/mgo.v2"
@@ -38,9 +40,9 @@ type QiniuIMService struct {
 }
 
 type AppConfigInterface interface {
-	GetUserToken(xl *xlog.Logger, userID string) (*model.IMUserDo, error)
-	GetGroupId(xl *xlog.Logger, groupId string) (int64, error)
-	DestroyGroupChat(xl *xlog.Logger, groupId int64) error
+	GetUserToken(ctx context.Context, xl *xlog.Logger, userID string) (*model.IMUserDo, error)
+	GetGroupId(ctx context.Context, xl *xlog.Logger, groupId string) (int64, error)
+	DestroyGroupChat(ctx context.Context, xl *xlog.Logger, groupId int64) error
 }
 
 func NewAppConfigService(conf *utils.IMConfig, xl *xlog.Logger) (AppConfigInterface, error) {
@@ -81,7 +83,7 @@ func NewQiniuIMService(conf *utils.IMConfig, xl *xlog.Logger) (*QiniuIMService,
 		appKey:             appKey,
 		appSecret:          appSecret,
 		userMap:            map[string]*model.IMUserDo{},
-		qiniuIMClient:      maxim.NewMaximClient(conf.Qiniu),
+		qiniuIMClient:      maxim.NewMaximClient(conf.Qiniu).WithClient(metrics.NewHTTPClient("im", 0)),
 		xl:                 xl,
 		stopCh:             make(chan struct{}),
 		qiniuIMUserService: qiniuIMUserService,
@@ -103,13 +105,13 @@ func NewQiniuIMService(conf *utils.IMConfig, xl *xlog.Logger) (*QiniuIMService,
 }
 
 // GetUserToken 用户注册，生成User token
-func (c *QiniuIMService) GetUserToken(xl *xlog.Logger, userID string) (*model.IMUserDo, error) {
+func (c *QiniuIMService) GetUserToken(ctx context.Context, xl *xlog.Logger, userID string) (*model.IMUserDo, error) {
 	if xl == nil {
 		xl = c.xl
 	}
 	qiniuUserId := c.appEnvPrefix + userID
 
-	qiniuIMuser, err := c.qiniuIMUserService.GetAccountByID(xl, qiniuUserId)
+	qiniuIMuser, err := c.qiniuIMUserService.GetAccountByID(ctx, xl, qiniuUserId)
 	if err != nil && err != mgo.ErrNotFound {
 		xl.Errorf("GetUserToken.GetAccountByID error %+v", err)
 		return nil, err
@@ -120,23 +122,23 @@ func (c *QiniuIMService) GetUserToken(xl *xlog.Logger, userID string) (*model.IM
 	}
 
 	qiniuIMuser = NewQiniuIMUser(qiniuUserId)
-	result, err := c.qiniuIMClient.RegisterUser(xl, qiniuIMuser.Username, qiniuIMuser.GetPassword())
+	result, err := c.qiniuIMClient.RegisterUser(ctx, xl, qiniuIMuser.Username, qiniuIMuser.GetPassword())
 	if err != nil {
 		xl.Errorf("maximClient.RegisterUser %s err:%v result:%v", qiniuIMuser.Username, err, result)
 		return nil, err
 	}
 	qiniuIMuser.UserID = result.Get("data.user_id").String()
 
-	if err = c.qiniuIMUserService.CreateAccount(xl, qiniuIMuser); err != nil {
+	if err = c.qiniuIMUserService.CreateAccount(ctx, xl, qiniuIMuser); err != nil {
 		return nil, err
 	}
 
 	return qiniuIMuser, nil
 }
 
-func (c *QiniuIMService) GetGroupId(xl *xlog.Logger, groupId string) (int64, error) {
+func (c *QiniuIMService) GetGroupId(ctx context.Context, xl *xlog.Logger, groupId string) (int64, error) {
 	currentGroupId := c.appEnvPrefix + groupId
-	imGroupId, err := c.qiniuIMClient.CreateChatroom(xl, currentGroupId)
+	imGroupId, err := c.qiniuIMClient.CreateChatroom(ctx, xl, currentGroupId)
 	if err != nil {
 		xl.Errorf("CreateChatroom %s, error %+v", groupId, err)
 		return 0, err
@@ -144,8 +146,8 @@ func (c *QiniuIMService) GetGroupId(xl *xlog.Logger, groupId string) (int64, err
 	return imGroupId, nil
 }
 
-func (c *QiniuIMService) DestroyGroupChat(xl *xlog.Logger, groupId int64) error {
-	return c.qiniuIMClient.DestroyGroupChat(xl, groupId)
+func (c *QiniuIMService) DestroyGroupChat(ctx context.Context, xl *xlog.Logger, groupId int64) error {
+	return c.qiniuIMClient.DestroyGroupChat(ctx, xl, groupId)
 }
 
 // RandomSixDigitId generate 6 digit letters
@@ -169,11 +171,11 @@ func NewQiniuIMUser(userId string) *model.IMUserDo {
 
 type mockIMService struct{}
 
-func (m *mockIMService) DestroyGroupChat(xl *xlog.Logger, groupId int64) error {
+func (m *mockIMService) DestroyGroupChat(ctx context.Context, xl *xlog.Logger, groupId int64) error {
 	return nil
 }
 
-func (m *mockIMService) GetUserToken(xl *xlog.Logger, userID string) (*model.IMUserDo, error) {
+func (m *mockIMService) GetUserToken(ctx context.Context, xl *xlog.Logger, userID string) (*model.IMUserDo, error) {
 	return &model.IMUserDo{
 		UserID:   userID,
 		Username: userID,
@@ -181,6 +183,6 @@ func (m *mockIMService) GetUserToken(xl *xlog.Logger, userID string) (*model.IMU
 	}, nil
 }
 
-func (m *mockIMService) GetGroupId(xl *xlog.Logger, groupId string) (int64, error) {
+func (m *mockIMService) GetGroupId(ctx context.Context, xl *xlog.Logger, groupId string) (int64, error) {
 	return 0, nil
 }
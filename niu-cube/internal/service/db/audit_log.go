@@ -0,0 +1,239 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/metrics"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/utils"
+	model "github.com/solutions/niu-cube/internal/protodef/model"
+	dao "github.com/solutions/niu-cube/internal/service/db/dao"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// AuditLogDefaultRetention 审计日志默认保留时间。
+	AuditLogDefaultRetention = 90 * 24 * time.Hour
+	// AuditLogDefaultBufferSize 默认等待写入的记录数上限。
+	AuditLogDefaultBufferSize = 10000
+	// AuditLogDefaultBatchSize 默认每次批量写入的记录数。
+	AuditLogDefaultBatchSize = 100
+	// AuditLogDefaultFlushInterval 默认不足一批时写入的间隔。
+	AuditLogDefaultFlushInterval = time.Second
+	// AuditLogMaxPageSize 查询审计日志每页的最大记录数。
+	AuditLogMaxPageSize = 100
+)
+
+// AuditLogService 异步批量写入与查询审计日志。
+// Write只把记录放入缓冲区，由后台协程批量写入数据库；缓冲区已满或数据库不可用时丢弃记录，不影响请求。
+type AuditLogService struct {
+	mongoClient   *mongodb.Manager
+	auditColl     *mongodb.Collection
+	retention     time.Duration
+	batchSize     int
+	flushInterval time.Duration
+	// insert 批量写入记录，默认写入auditColl。
+	insert func(docs ...interface{}) error
+
+	records   chan *model.AuditLogDo
+	closing   chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	xl        *xlog.Logger
+}
+
+// NewAuditLogService 创建审计日志服务并启动后台写入，过期记录由dao.Indexes中声明的TTL索引清理。
+func NewAuditLogService(conf utils.MongoConfig, auditConf *utils.AuditLogConfig, xl *xlog.Logger) (*AuditLogService, error) {
+	if xl == nil {
+		xl = xlog.New("niu-cube-audit-log")
+	}
+	mongoClient, err := mongodb.Get(&conf)
+	if err != nil {
+		xl.Errorf("failed to create mongo client, error %v", err)
+		return nil, err
+	}
+	coll := mongoClient.C(dao.CollectionAuditLog)
+	s := newAuditLogService(auditConf, coll.Insert, xl)
+	s.mongoClient = mongoClient
+	s.auditColl = coll
+	go s.loop()
+	return s, nil
+}
+
+func newAuditLogService(conf *utils.AuditLogConfig, insert func(docs ...interface{}) error, xl *xlog.Logger) *AuditLogService {
+	s := &AuditLogService{
+		retention:     AuditLogDefaultRetention,
+		batchSize:     AuditLogDefaultBatchSize,
+		flushInterval: AuditLogDefaultFlushInterval,
+		insert:        insert,
+		closing:       make(chan struct{}),
+		done:          make(chan struct{}),
+		xl:            xl,
+	}
+	bufferSize := AuditLogDefaultBufferSize
+	if conf != nil {
+		if conf.RetentionDays > 0 {
+			s.retention = time.Duration(conf.RetentionDays) * 24 * time.Hour
+		}
+		if conf.BufferSize > 0 {
+			bufferSize = conf.BufferSize
+		}
+		if conf.BatchSize > 0 {
+			s.batchSize = conf.BatchSize
+		}
+		if conf.FlushIntervalSecond > 0 {
+			s.flushInterval = time.Duration(conf.FlushIntervalSecond) * time.Second
+		}
+	}
+	s.records = make(chan *model.AuditLogDo, bufferSize)
+	return s
+}
+
+// Write 将记录放入缓冲区，不等待写入数据库。缓冲区已满或服务已关闭时丢弃记录。
+func (s *AuditLogService) Write(record *model.AuditLogDo) {
+	if record.ID == "" {
+		record.ID = utils.GenerateID()
+	}
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	record.ExpireAt = record.Time.Add(s.retention)
+	select {
+	case <-s.closing:
+		metrics.AuditLogRecords.WithLabelValues("dropped").Inc()
+		return
+	default:
+	}
+	select {
+	case s.records <- record:
+	default:
+		metrics.AuditLogRecords.WithLabelValues("dropped").Inc()
+	}
+}
+
+func (s *AuditLogService) loop() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+	batch := make([]interface{}, 0, s.batchSize)
+	for {
+		select {
+		case record := <-s.records:
+			batch = append(batch, record)
+			if len(batch) >= s.batchSize {
+				batch = s.flush(batch)
+			}
+		case <-ticker.C:
+			batch = s.flush(batch)
+		case <-s.closing:
+			for {
+				select {
+				case record := <-s.records:
+					batch = append(batch, record)
+					if len(batch) >= s.batchSize {
+						batch = s.flush(batch)
+					}
+				default:
+					s.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush 写入一批记录，失败时记录日志后丢弃，返回清空后的batch。
+func (s *AuditLogService) flush(batch []interface{}) []interface{} {
+	if len(batch) == 0 {
+		return batch
+	}
+	err := s.insert(batch...)
+	if err != nil {
+		s.xl.Errorf("failed to write %d audit log records, error %v", len(batch), err)
+		metrics.AuditLogRecords.WithLabelValues("failed").Add(float64(len(batch)))
+	} else {
+		metrics.AuditLogRecords.WithLabelValues("written").Add(float64(len(batch)))
+	}
+	for i := range batch {
+		batch[i] = nil
+	}
+	return batch[:0]
+}
+
+// Close 停止接收新的记录，并等待缓冲区中的记录写入完成，ctx结束时不再等待并返回ctx的错误。
+func (s *AuditLogService) Close(ctx context.Context) error {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ListAuditLogs 按条件分页查询审计日志，按时间倒序，返回记录与总数。
+func (s *AuditLogService) ListAuditLogs(xl *xlog.Logger, filter model.AuditLogFilter, pageNum, pageSize int) ([]model.AuditLogDo, int, error) {
+	if xl == nil {
+		xl = s.xl
+	}
+	if pageNum < 1 {
+		pageNum = 1
+	}
+	if pageSize < 1 || pageSize > AuditLogMaxPageSize {
+		pageSize = AuditLogMaxPageSize
+	}
+	query := auditLogQuery(filter)
+	total, err := s.auditColl.Find(query).Count()
+	if err != nil {
+		xl.Errorf("failed to count audit logs, error %v", err)
+		return nil, 0, err
+	}
+	records := make([]model.AuditLogDo, 0)
+	err = s.auditColl.Find(query).Sort("-time").Skip((pageNum - 1) * pageSize).Limit(pageSize).All(&records)
+	if err != nil {
+		xl.Errorf("failed to list audit logs, error %v", err)
+		return nil, 0, err
+	}
+	return records, total, nil
+}
+
+// auditLogQuery 将查询条件转换为数据库查询。
+func auditLogQuery(filter model.AuditLogFilter) bson.M {
+	query := bson.M{}
+	if filter.UserID != "" {
+		query["userId"] = filter.UserID
+	}
+	if filter.Route != "" {
+		query["route"] = filter.Route
+	}
+	if filter.Method != "" {
+		query["method"] = filter.Method
+	}
+	if filter.Status != 0 {
+		query["status"] = filter.Status
+	}
+	switch {
+	case filter.ResourceID != "" && filter.ResourceType != "":
+		query["resources"] = bson.M{"$elemMatch": bson.M{"type": filter.ResourceType, "id": filter.ResourceID}}
+	case filter.ResourceID != "":
+		query["resources.id"] = filter.ResourceID
+	case filter.ResourceType != "":
+		query["resources.type"] = filter.ResourceType
+	}
+	timeRange := bson.M{}
+	if !filter.StartTime.IsZero() {
+		timeRange["$gte"] = filter.StartTime
+	}
+	if !filter.EndTime.IsZero() {
+		timeRange["$lt"] = filter.EndTime
+	}
+	if len(timeRange) > 0 {
+		query["time"] = timeRange
+	}
+	return query
+}
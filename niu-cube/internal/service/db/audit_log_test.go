@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/utils"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// fakeAuditLogStore 记录每次批量写入的记录数。
+type fakeAuditLogStore struct {
+	lock    sync.Mutex
+	batches []int
+	block   chan struct{}
+	err     error
+}
+
+func (f *fakeAuditLogStore) insert(docs ...interface{}) error {
+	if f.block != nil {
+		<-f.block
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.batches = append(f.batches, len(docs))
+	return f.err
+}
+
+func (f *fakeAuditLogStore) total() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	n := 0
+	for _, size := range f.batches {
+		n += size
+	}
+	return n
+}
+
+func TestAuditLogServiceBatch(t *testing.T) {
+	store := &fakeAuditLogStore{}
+	s := newAuditLogService(&utils.AuditLogConfig{BatchSize: 3, FlushIntervalSecond: 3600, RetentionDays: 7}, store.insert, xlog.New("test"))
+	go s.loop()
+	records := make([]*model.AuditLogDo, 0)
+	for i := 0; i < 7; i++ {
+		record := &model.AuditLogDo{Route: "/v1/test"}
+		records = append(records, record)
+		s.Write(record)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Close(ctx); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if store.total() != 7 {
+		t.Fatalf("wrote %d records (batches %v), want 7", store.total(), store.batches)
+	}
+	for _, size := range store.batches {
+		if size > 3 {
+			t.Errorf("batch of %d records exceeds batch size", size)
+		}
+	}
+	record := records[0]
+	if record.ID == "" || record.Time.IsZero() || record.ExpireAt.Sub(record.Time) != 7*24*time.Hour {
+		t.Errorf("record = %+v", record)
+	}
+
+	// 关闭后写入的记录被丢弃。
+	s.Write(&model.AuditLogDo{})
+	if store.total() != 7 {
+		t.Errorf("record written after close")
+	}
+}
+
+func TestAuditLogServiceDropWhenFull(t *testing.T) {
+	store := &fakeAuditLogStore{block: make(chan struct{}), err: errors.New("mongo down")}
+	s := newAuditLogService(&utils.AuditLogConfig{BufferSize: 2, BatchSize: 1, FlushIntervalSecond: 3600}, store.insert, xlog.New("test"))
+	go s.loop()
+	done := make(chan struct{})
+	go func() {
+		// 写入阻塞时Write仍立即返回，缓冲区满后丢弃记录。
+		for i := 0; i < 100; i++ {
+			s.Write(&model.AuditLogDo{})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Write blocked while the store is blocked")
+	}
+	close(store.block)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Close(ctx); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if n := store.total(); n == 0 || n > 3 {
+		t.Errorf("attempted to write %d records, want between 1 and 3", n)
+	}
+}
+
+func TestAuditLogQuery(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	query := auditLogQuery(model.AuditLogFilter{
+		UserID:       "user-1",
+		ResourceType: "roomId",
+		ResourceID:   "room-1",
+		StartTime:    start,
+	})
+	want := bson.M{
+		"userId":    "user-1",
+		"resources": bson.M{"$elemMatch": bson.M{"type": "roomId", "id": "room-1"}},
+		"time":      bson.M{"$gte": start},
+	}
+	if !reflect.DeepEqual(query, want) {
+		t.Errorf("query = %v, want %v", query, want)
+	}
+	if query := auditLogQuery(model.AuditLogFilter{ResourceID: "room-1"}); query["resources.id"] != "room-1" {
+		t.Errorf("query = %v", query)
+	}
+}
+
+func TestListAuditLogs(t *testing.T) {
+	conf := testMongoConfig(t)
+	s, err := NewAuditLogService(conf, &utils.AuditLogConfig{FlushIntervalSecond: 1}, nil)
+	if err != nil {
+		t.Fatalf("NewAuditLogService: %v", err)
+	}
+	now := time.Now()
+	s.Write(&model.AuditLogDo{Time: now.Add(-time.Hour), UserID: "user-1", Route: "/v1/a", Resources: []model.AuditResource{{Type: "roomId", ID: "room-1"}}})
+	s.Write(&model.AuditLogDo{Time: now, UserID: "user-1", Route: "/v1/b"})
+	s.Write(&model.AuditLogDo{Time: now, UserID: "user-2", Route: "/v1/a"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Close(ctx); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	records, total, err := s.ListAuditLogs(nil, model.AuditLogFilter{UserID: "user-1"}, 1, 10)
+	if err != nil || total != 2 || len(records) != 2 || records[0].Route != "/v1/b" {
+		t.Fatalf("ListAuditLogs(user-1) = %v, %d, %v", records, total, err)
+	}
+	_, total, err = s.ListAuditLogs(nil, model.AuditLogFilter{ResourceID: "room-1"}, 1, 10)
+	if err != nil || total != 1 {
+		t.Fatalf("ListAuditLogs(room-1) total %d, error %v", total, err)
+	}
+	_, total, err = s.ListAuditLogs(nil, model.AuditLogFilter{Route: "/v1/a", StartTime: now.Add(-time.Minute)}, 1, 10)
+	if err != nil || total != 1 {
+		t.Fatalf("ListAuditLogs(route, time) total %d, error %v", total, err)
+	}
+}
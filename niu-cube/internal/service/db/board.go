@@ -1,11 +1,13 @@
 package db
 
 import (
+	"context"
 	"fmt"
 	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	model "github.com/solutions/niu-cube/internal/protodef/model"
-	"gopkg.in/mgo.v2"
+	dao "github.com/solutions/niu-cube/internal/service/db/dao"
 	"math/rand"
 )
 
@@ -14,28 +16,28 @@ var (
 )
 
 type BoardService struct {
-	boardCollection *mgo.Collection
+	boardCollection *mongodb.Collection
 	xl              *xlog.Logger
 }
 
 func NewBoardService(xl *xlog.Logger, config utils.MongoConfig) *BoardService {
 	v := new(BoardService)
 	v.xl = xlog.New("board service")
-	db, err := mgo.Dial(config.URI)
+	db, err := mongodb.Get(&config)
 	if err != nil {
 		v.xl.Fatalf("error dialing service error:%v", err)
 	}
-	err = db.Ping()
+	err = db.Ping(context.Background())
 	if err != nil {
 		v.xl.Fatalf("err ping db error:%v", err)
 	}
-	v.boardCollection = db.DB(config.Database).C("boards")
+	v.boardCollection = db.C(dao.CollectionBoard)
 	return v
 }
 
 // CRUD
 
-func (v *BoardService) Create(xl *xlog.Logger, board model.BoardDo) error {
+func (v *BoardService) Create(ctx context.Context, xl *xlog.Logger, board model.BoardDo) error {
 	var logger *xlog.Logger
 	if xl != nil {
 		logger = xl
@@ -48,14 +50,14 @@ func (v *BoardService) Create(xl *xlog.Logger, board model.BoardDo) error {
 			board.ID = board.InterviewID
 		}
 	}
-	err := v.boardCollection.Insert(board)
+	err := v.boardCollection.WithContext(ctx).Insert(board)
 	if err != nil {
 		logger.Errorf("error create boardDo %v err:%v", board, err)
 	}
 	return err
 }
 
-func (v *BoardService) Upsert(xl *xlog.Logger, board model.BoardDo) error {
+func (v *BoardService) Upsert(ctx context.Context, xl *xlog.Logger, board model.BoardDo) error {
 	var logger *xlog.Logger
 	if xl != nil {
 		logger = xl
@@ -68,7 +70,7 @@ func (v *BoardService) Upsert(xl *xlog.Logger, board model.BoardDo) error {
 			board.ID = board.InterviewID
 		}
 	}
-	info, err := v.boardCollection.UpsertId(board.ID, board)
+	info, err := v.boardCollection.WithContext(ctx).UpsertId(board.ID, board)
 	if err != nil {
 		logger.Errorf("error upsert boardDo %v err:%v", board, err)
 	}
@@ -80,41 +82,41 @@ func (v *BoardService) Upsert(xl *xlog.Logger, board model.BoardDo) error {
 	return err
 }
 
-func (v *BoardService) Update(xl *xlog.Logger, board model.BoardDo) error {
+func (v *BoardService) Update(ctx context.Context, xl *xlog.Logger, board model.BoardDo) error {
 	var logger *xlog.Logger
 	if xl != nil {
 		logger = xl
 	}
-	err := v.boardCollection.UpdateId(board.ID, board)
+	err := v.boardCollection.WithContext(ctx).UpdateId(board.ID, board)
 	if err != nil {
 		logger.Errorf("error update boardDo %v err:%v", board, err)
 	}
 	return err
 }
 
-func (v *BoardService) Delete(xl *xlog.Logger, id string) error {
+func (v *BoardService) Delete(ctx context.Context, xl *xlog.Logger, id string) error {
 	var logger *xlog.Logger
 	if xl != nil {
 		logger = xl
 	}
-	err := v.boardCollection.RemoveId(id)
+	err := v.boardCollection.WithContext(ctx).RemoveId(id)
 	if err != nil {
 		logger.Errorf("error update boardId %v err:%v", id, err)
 	}
 	return err
 }
 
-func (c *BoardService) GetOneByID(xl *xlog.Logger, id string) (model.BoardDo, error) {
-	return c.GetOneByMap(xl, map[string]interface{}{"_id": id})
+func (c *BoardService) GetOneByID(ctx context.Context, xl *xlog.Logger, id string) (model.BoardDo, error) {
+	return c.GetOneByMap(ctx, xl, map[string]interface{}{"_id": id})
 }
 
-func (v *BoardService) GetOneByMap(xl *xlog.Logger, filter interface{}) (model.BoardDo, error) {
+func (v *BoardService) GetOneByMap(ctx context.Context, xl *xlog.Logger, filter interface{}) (model.BoardDo, error) {
 	var logger *xlog.Logger
 	if xl != nil {
 		logger = xl
 	}
 	var board model.BoardDo
-	err := v.boardCollection.Find(filter).One(&board)
+	err := v.boardCollection.WithContext(ctx).Find(filter).One(&board)
 	if err != nil {
 		logger.Debugf("error get by filter %v err:%v", filter, err)
 		return board, err
@@ -123,14 +125,14 @@ func (v *BoardService) GetOneByMap(xl *xlog.Logger, filter interface{}) (model.B
 }
 
 // GetByMap
-func (v *BoardService) GetByMap(xl *xlog.Logger, filter interface{}) ([]model.BoardDo, error) {
+func (v *BoardService) GetByMap(ctx context.Context, xl *xlog.Logger, filter interface{}) ([]model.BoardDo, error) {
 	var logger *xlog.Logger
 	if xl != nil {
 		logger = xl
 	}
 	boards := make([]model.BoardDo, 0)
 	var err error
-	err = v.boardCollection.Find(filter).All(&boards)
+	err = v.boardCollection.WithContext(ctx).Find(filter).All(&boards)
 	if err != nil {
 		logger.Debugf("error get by filter %v err:%v", filter, err)
 		return boards, err
@@ -139,19 +141,19 @@ func (v *BoardService) GetByMap(xl *xlog.Logger, filter interface{}) ([]model.Bo
 }
 
 // GetPageByMap
-func (v *BoardService) GetPageByMap(xl *xlog.Logger, filter interface{}, pageNum, pageSize int) ([]model.BoardDo, int, error) {
+func (v *BoardService) GetPageByMap(ctx context.Context, xl *xlog.Logger, filter interface{}, pageNum, pageSize int) ([]model.BoardDo, int, error) {
 	var logger *xlog.Logger
 	if xl != nil {
 		logger = xl
 	}
 	boards := make([]model.BoardDo, 0)
 	var err error
-	err = v.boardCollection.Find(filter).Skip((pageNum - 1) * pageSize).Limit(pageSize).All(&boards)
+	err = v.boardCollection.WithContext(ctx).Find(filter).Skip((pageNum - 1) * pageSize).Limit(pageSize).All(&boards)
 	if err != nil {
 		logger.Debugf("error get by filter %v err:%v", filter, err)
 		return boards, 0, err
 	}
-	cnt, err := v.boardCollection.Find(filter).Count()
+	cnt, err := v.boardCollection.WithContext(ctx).Find(filter).Count()
 	if err != nil {
 		logger.Debugf("error get by filter %v err:%v", filter, err)
 		return boards, 0, err
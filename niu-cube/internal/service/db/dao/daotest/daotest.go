@@ -0,0 +1,52 @@
+// Package daotest 提供需要MongoDB的集成测试使用的测试数据库。
+package daotest
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiniu/x/xlog"
+
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/utils"
+	"github.com/solutions/niu-cube/internal/service/db/dao"
+)
+
+// MongoURIEnv 集成测试使用的MongoDB地址，未设置时跳过需要数据库的测试。
+const MongoURIEnv = "NIU_CUBE_TEST_MONGO_URI"
+
+// cleanupTimeout 测试结束后删除数据库与关闭连接的超时时间。
+const cleanupTimeout = 10 * time.Second
+
+// NewDatabase 创建一个独立的测试数据库并创建声明的索引，返回其连接与配置。使用该配置调用mongodb.Get得到同一个Manager，
+// 测试结束后删除该数据库并关闭连接。
+func NewDatabase(t *testing.T) (*mongodb.Manager, utils.MongoConfig) {
+	t.Helper()
+	uri := os.Getenv(MongoURIEnv)
+	if uri == "" {
+		t.Skipf("%s is not set", MongoURIEnv)
+	}
+	conf := utils.MongoConfig{
+		URI:      strings.TrimSuffix(uri, "/"),
+		Database: "niu_cube_test_" + strings.ToLower(utils.GenerateID()),
+	}
+	db, err := mongodb.Get(&conf)
+	if err != nil {
+		t.Fatalf("dial mongo: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+		if err := db.Collection(dao.CollectionAccount).Database().Drop(ctx); err != nil {
+			t.Logf("drop test database %s: %v", conf.Database, err)
+		}
+		db.Close(ctx)
+	})
+	if err := dao.EnsureIndexes(db, xlog.New("test")); err != nil {
+		t.Fatalf("EnsureIndexes: %v", err)
+	}
+	return db, conf
+}
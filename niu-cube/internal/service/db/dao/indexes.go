@@ -0,0 +1,114 @@
+package dao
+
+import (
+	"time"
+
+	"github.com/qiniu/x/xlog"
+	"gopkg.in/mgo.v2"
+
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+)
+
+// ttlIndex 按expireAt字段过期的TTL索引。mgo不支持expireAfterSeconds为0，记录在expireAt之后1秒由数据库自动清理。
+func ttlIndex(key string) mgo.Index {
+	return mgo.Index{Key: []string{key}, ExpireAfter: time.Second}
+}
+
+// Indexes 各集合需要的索引，按集合名索引。执行迁移时创建，已存在的索引不会重复创建，修改已有索引的选项需要先手动删除。
+var Indexes = map[string][]mgo.Index{
+	// 邮箱设置后全局唯一，未设置邮箱的账号不写入该字段。
+	CollectionAccount: {
+		{Key: []string{"email"}, Unique: true, Sparse: true},
+		{Key: []string{"phone"}},
+		{Key: []string{"weixinOpenId"}, Sparse: true},
+		{Key: []string{"weixinUnionId"}, Sparse: true},
+	},
+	CollectionAccountToken: {
+		{Key: []string{"accountId", "-lastModifyTime"}},
+		{Key: []string{"refreshTokenHash"}},
+	},
+	CollectionRevokedToken: {
+		ttlIndex("expireAt"),
+	},
+	CollectionSMSCode: {
+		{Key: []string{"phone", "sendTime"}},
+		ttlIndex("expireAt"),
+	},
+	CollectionSMSQuota: {
+		ttlIndex("expireAt"),
+	},
+	CollectionSMSValidateFailure: {
+		ttlIndex("expireAt"),
+	},
+	CollectionAuditLog: {
+		ttlIndex("expireAt"),
+		{Key: []string{"-time"}},
+		{Key: []string{"userId", "-time"}},
+		{Key: []string{"route", "-time"}},
+		{Key: []string{"resources.id", "-time"}},
+	},
+	InterviewUserCollection: {
+		{Key: []string{"interviewId", "userId"}},
+		{Key: []string{"lastHeartBeatTime", "status"}},
+	},
+	CollectionRepairRoomUser: {
+		{Key: []string{"lastHeartBeatTime", "status"}},
+	},
+	CollectionSong: {
+		{Key: []string{"status", "created_time", "_id"}},
+	},
+	CollectionMovie: {
+		{Key: []string{"status", "created_time", "_id"}},
+	},
+	CollectionBaseRoom: {
+		{Key: []string{"invitation_code", "status"}},
+		{Key: []string{"type", "status", "-created_time"}},
+		{Key: []string{"status", "updated_time"}},
+	},
+	CollectionBaseRoomUser: {
+		{Key: []string{"room_id", "user_id", "status"}},
+		{Key: []string{"room_id", "status", "-updated_time"}},
+		{Key: []string{"last_heartbeat_time", "status"}},
+	},
+	CollectionBaseRoomMic: {
+		{Key: []string{"room_id", "mic_id"}},
+	},
+	CollectionBaseUserMic: {
+		{Key: []string{"room_id", "mic_id", "status"}},
+		{Key: []string{"room_id", "user_id", "status"}},
+		{Key: []string{"user_id", "status"}},
+	},
+	CollectionRoomUserSong: {
+		{Key: []string{"room_id", "song_id"}},
+		{Key: []string{"room_id", "status", "-created_time"}},
+	},
+	CollectionRoomUserMovie: {
+		{Key: []string{"room_id", "movie_id", "status"}},
+		{Key: []string{"room_id", "status", "created_time"}},
+	},
+	CollectionExamPaper: {
+		{Key: []string{"exam_id", "status"}},
+	},
+	CollectionUserExam: {
+		{Key: []string{"exam_id", "user_id"}},
+		{Key: []string{"user_id", "-created_time"}},
+	},
+	CollectionAnswerPaper: {
+		{Key: []string{"exam_id", "user_id"}},
+	},
+}
+
+// EnsureIndexes 创建Indexes中声明的全部索引，返回第一个失败的错误。
+func EnsureIndexes(client *mongodb.Manager, xl *xlog.Logger) error {
+	for name, indexes := range Indexes {
+		coll := client.C(name)
+		for _, index := range indexes {
+			err := coll.EnsureIndex(index)
+			if err != nil {
+				xl.Errorf("failed to create index %v of %s, error %v", index.Key, name, err)
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -5,9 +5,21 @@ const (
 	CollectionAccount = "accounts"
 	// CollectionAccountToken 存储已登录用户的表。
 	CollectionAccountToken = "account_token"
+	// CollectionRevokedToken 存储已吊销的登录token的表。
+	CollectionRevokedToken = "account_token_revoked"
+
+	// CollectionApiKey 存储服务端调用使用的API key的表。
+	CollectionApiKey = "api_key"
+
+	// CollectionAccountEmailToken 存储邮箱验证、重置密码token的表。
+	CollectionAccountEmailToken = "account_email_token"
 
 	// CollectionSMSCode 存储已发送的短信验证码的表。
 	CollectionSMSCode = "sms_code"
+	// CollectionSMSQuota 存储短信验证码每天发送计数的表。
+	CollectionSMSQuota = "sms_quota"
+	// CollectionSMSValidateFailure 存储手机号输错验证码次数与锁定状态的表。
+	CollectionSMSValidateFailure = "sms_validate_failure"
 
 	// CollectionRoom 存储直播房间信息的表。
 	CollectionRoom        = "rooms"
@@ -16,6 +28,9 @@ const (
 	// CollectionBizExtra 每个room关联的业务部分
 	CollectionBizExtra = "biz_extras"
 
+	// CollectionBoard 面试白板状态的表。
+	CollectionBoard = "boards"
+
 	InterviewCollection     = "interviews"
 	InterviewUserCollection = "interview_users"
 
@@ -23,8 +38,13 @@ const (
 	CounterCollection = "_counter"
 	TaskCollection    = "task_results"
 
-	// ActionCollection 全局日志流水
+	// CollectionMigration 存储已执行的数据迁移的表。
+	CollectionMigration = "_migrations"
+
+	// ActionCollection 全局日志流水，已由CollectionAuditLog代替，只保留历史记录。
 	ActionCollection = "actions"
+	// CollectionAuditLog 审计日志。
+	CollectionAuditLog = "audit_log"
 
 	// CollectionRepairRoom 检修相关业务
 	CollectionRepairRoom     = "repair_room"
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/utils"
+)
+
+// MongoHealthCheckTimeout 就绪检查ping MongoDB的超时时间。
+const MongoHealthCheckTimeout = 2 * time.Second
+
+// MongoHealthChecker 通过ping检查MongoDB是否可用，使用与DAO共享的连接池，检查结果反映业务实际使用的连接。
+type MongoHealthChecker struct {
+	name   string
+	client *mongodb.Manager
+}
+
+func NewMongoHealthChecker(name string, conf utils.MongoConfig, xl *xlog.Logger) (*MongoHealthChecker, error) {
+	if xl == nil {
+		xl = xlog.New("niu-cube-health")
+	}
+	client, err := mongodb.Get(&conf)
+	if err != nil {
+		xl.Errorf("failed to create mongo client for %s health check, error %v", name, err)
+		return nil, err
+	}
+	return &MongoHealthChecker{name: name, client: client}, nil
+}
+
+func (m *MongoHealthChecker) Name() string {
+	return m.name
+}
+
+// Check ping数据库，超过MongoHealthCheckTimeout时返回超时错误。
+func (m *MongoHealthChecker) Check() error {
+	ctx, cancel := context.WithTimeout(context.Background(), MongoHealthCheckTimeout)
+	defer cancel()
+	return m.client.Ping(ctx)
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"fmt"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
@@ -163,7 +164,7 @@ func (i *IEServiceImpl) GetCreator(roomId string) (user model.AccountDo, err err
 	if err != nil {
 		return user, err
 	}
-	u, err := i.accountService.GetAccountByID(nil, id)
+	u, err := i.accountService.GetAccountByID(context.Background(), nil, id)
 	if err != nil {
 		return user, err
 	}
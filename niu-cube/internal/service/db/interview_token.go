@@ -0,0 +1,122 @@
+package db
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/solutions/niu-cube/internal/common/utils"
+	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
+	model "github.com/solutions/niu-cube/internal/protodef/model"
+)
+
+const (
+	// InterviewTokenDefaultGrace 面试邀请token在面试结束后默认的有效时长。
+	InterviewTokenDefaultGrace = 24 * time.Hour
+	// interviewTokenSignPrefix 签名时附加的前缀，避免与其他用途的签名混用。
+	interviewTokenSignPrefix = "interview-token."
+)
+
+// InterviewTokenService 签发与校验面试邀请token。
+// token格式为 base64url(内容JSON).base64url(HMAC-SHA256签名)，绑定一场面试、一个角色和过期时间。
+type InterviewTokenService struct {
+	key   []byte
+	grace time.Duration
+}
+
+func NewInterviewTokenService(conf utils.Config) *InterviewTokenService {
+	grace := InterviewTokenDefaultGrace
+	if conf.Token != nil && conf.Token.InterviewTokenGraceSecond > 0 {
+		grace = time.Duration(conf.Token.InterviewTokenGraceSecond) * time.Second
+	}
+	return &InterviewTokenService{
+		key:   []byte(conf.JwtKey),
+		grace: grace,
+	}
+}
+
+// Sign 为面试中的用户签发邀请token，用户须为该面试的面试官或应聘者。
+func (s *InterviewTokenService) Sign(interview *model.InterviewDo, userID string) (string, error) {
+	role, ok := interviewRoleOf(interview, userID)
+	if !ok {
+		return "", &errors2.ServerError{Code: errors2.ServerErrorUserNoPermission, Summary: "user is not a member of the interview"}
+	}
+	expireAt := interview.EndTime
+	if expireAt.Before(time.Now()) {
+		expireAt = time.Now()
+	}
+	args := model.InterviewTokenArgs{
+		UserID:      userID,
+		InterviewID: interview.ID,
+		Role:        int(role),
+		Version:     interview.TokenVersion,
+		ExpireAt:    expireAt.Add(s.grace).Unix(),
+	}
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+	return encodedPayload + "." + base64.RawURLEncoding.EncodeToString(s.sign(encodedPayload)), nil
+}
+
+// Parse 校验token的签名与有效期，返回token内容。是否属于某场面试需再调用Check。
+func (s *InterviewTokenService) Parse(token string) (*model.InterviewTokenArgs, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenInvalid, Summary: "malformed interview token"}
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, s.sign(parts[0])) {
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenInvalid, Summary: "bad interview token signature"}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenInvalid, Summary: "malformed interview token"}
+	}
+	args := &model.InterviewTokenArgs{}
+	err = json.Unmarshal(payload, args)
+	if err != nil || args.UserID == "" || args.InterviewID == "" {
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenInvalid, Summary: "malformed interview token"}
+	}
+	if time.Now().Unix() > args.ExpireAt {
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenExpired, Summary: "interview token expired"}
+	}
+	return args, nil
+}
+
+// Check 校验token是否仍对该面试有效：面试ID一致、未因取消或修改而吊销，且角色与面试成员一致。
+func (s *InterviewTokenService) Check(args *model.InterviewTokenArgs, interview *model.InterviewDo) error {
+	if args.InterviewID != interview.ID {
+		return &errors2.ServerError{Code: errors2.ServerErrorTokenInvalid, Summary: "interview token issued for another interview"}
+	}
+	if args.Version != interview.TokenVersion {
+		return &errors2.ServerError{Code: errors2.ServerErrorTokenInvalid, Summary: "interview token revoked"}
+	}
+	role, ok := interviewRoleOf(interview, args.UserID)
+	if !ok || int(role) != args.Role {
+		return &errors2.ServerError{Code: errors2.ServerErrorTokenInvalid, Summary: "interview token role mismatch"}
+	}
+	return nil
+}
+
+func (s *InterviewTokenService) sign(encodedPayload string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(interviewTokenSignPrefix + encodedPayload))
+	return mac.Sum(nil)
+}
+
+// interviewRoleOf 返回用户在面试中的角色。
+func interviewRoleOf(interview *model.InterviewDo, userID string) (model.InterviewRoleCode, bool) {
+	switch userID {
+	case interview.Interviewer:
+		return model.InterviewRoleCodeInterviewer, true
+	case interview.Candidate:
+		return model.InterviewRoleCodeCandidate, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/solutions/niu-cube/internal/common/utils"
+	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+)
+
+func TestInterviewToken(t *testing.T) {
+	s := NewInterviewTokenService(utils.Config{JwtKey: "test-key"})
+	interview := &model.InterviewDo{
+		ID:          "interview-1",
+		Interviewer: "interviewer",
+		Candidate:   "candidate",
+		EndTime:     time.Now().Add(time.Hour),
+	}
+	candidateToken, err := s.Sign(interview, "candidate")
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	interviewerToken, err := s.Sign(interview, "interviewer")
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if _, err := s.Sign(interview, "stranger"); err == nil {
+		t.Fatalf("Sign should reject users outside the interview")
+	}
+
+	// 篡改内容但保留原签名。
+	parts := strings.Split(candidateToken, ".")
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	args := model.InterviewTokenArgs{}
+	_ = json.Unmarshal(payload, &args)
+	args.Role = int(model.InterviewRoleCodeInterviewer)
+	forged, _ := json.Marshal(args)
+	tampered := base64.RawURLEncoding.EncodeToString(forged) + "." + parts[1]
+
+	// 已过期但签名正确的token。
+	args.Role = int(model.InterviewRoleCodeCandidate)
+	args.ExpireAt = time.Now().Add(-time.Minute).Unix()
+	expiredPayload, _ := json.Marshal(args)
+	encodedExpired := base64.RawURLEncoding.EncodeToString(expiredPayload)
+	expiredToken := encodedExpired + "." + base64.RawURLEncoding.EncodeToString(s.sign(encodedExpired))
+	otherKeyToken, err := NewInterviewTokenService(utils.Config{JwtKey: "other-key"}).Sign(interview, "candidate")
+	if err != nil {
+		t.Fatalf("Sign with other key: %v", err)
+	}
+
+	other := *interview
+	other.ID = "interview-2"
+	bumped := *interview
+	bumped.TokenVersion++
+	swapped := *interview
+	swapped.Candidate = "someone-else"
+
+	cases := []struct {
+		name      string
+		token     string
+		interview *model.InterviewDo
+		wantRole  model.InterviewRoleCode
+		wantCode  int
+	}{
+		{name: "candidate", token: candidateToken, interview: interview, wantRole: model.InterviewRoleCodeCandidate},
+		{name: "interviewer", token: interviewerToken, interview: interview, wantRole: model.InterviewRoleCodeInterviewer},
+		{name: "tampered payload", token: tampered, interview: interview, wantCode: errors2.ServerErrorTokenInvalid},
+		{name: "tampered signature", token: parts[0] + ".AAAA", interview: interview, wantCode: errors2.ServerErrorTokenInvalid},
+		{name: "malformed", token: "abc", interview: interview, wantCode: errors2.ServerErrorTokenInvalid},
+		{name: "other key", token: otherKeyToken, interview: interview, wantCode: errors2.ServerErrorTokenInvalid},
+		{name: "expired", token: expiredToken, interview: interview, wantCode: errors2.ServerErrorTokenExpired},
+		{name: "wrong interview", token: candidateToken, interview: &other, wantCode: errors2.ServerErrorTokenInvalid},
+		{name: "version bumped", token: candidateToken, interview: &bumped, wantCode: errors2.ServerErrorTokenInvalid},
+		{name: "candidate replaced", token: candidateToken, interview: &swapped, wantCode: errors2.ServerErrorTokenInvalid},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			args, err := s.Parse(tc.token)
+			if err == nil {
+				err = s.Check(args, tc.interview)
+			}
+			if tc.wantCode == 0 {
+				if err != nil {
+					t.Fatalf("token rejected: %v", err)
+				}
+				if model.InterviewRoleCode(args.Role) != tc.wantRole {
+					t.Fatalf("role = %d, want %d", args.Role, tc.wantRole)
+				}
+				return
+			}
+			serverErr, ok := err.(*errors2.ServerError)
+			if !ok || serverErr.Code != tc.wantCode {
+				t.Fatalf("error = %v, want code %d", err, tc.wantCode)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package db
 
 import (
+	"context"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
 	model "github.com/solutions/niu-cube/internal/protodef/model"
@@ -13,11 +16,11 @@ import (
 )
 
 type InterviewService struct {
-	mongoClient       *mgo.Session
-	interviewColl     *mgo.Collection
-	interviewUserColl *mgo.Collection
-	accountTokenColl  *mgo.Collection
-	taskColl          *mgo.Collection
+	mongoClient       *mongodb.Manager
+	interviewColl     *mongodb.Collection
+	interviewUserColl *mongodb.Collection
+	accountTokenColl  *mongodb.Collection
+	taskColl          *mongodb.Collection
 	xl                *xlog.Logger
 }
 
@@ -29,15 +32,15 @@ func NewInterviewService(conf utils.MongoConfig, xl *xlog.Logger) (*InterviewSer
 	if xl == nil {
 		xl = xlog.New("niu-cube-room-controller")
 	}
-	mongoClient, err := mgo.Dial(conf.URI)
+	mongoClient, err := mongodb.Get(&conf)
 	if err != nil {
 		xl.Errorf("failed to create mongo client, error %v", err)
 		return nil, err
 	}
-	interviewColl := mongoClient.DB(conf.Database).C(dao.InterviewCollection)
-	accountTokenColl := mongoClient.DB(conf.Database).C(dao.CollectionAccountToken)
-	interviewerUserColl := mongoClient.DB(conf.Database).C(dao.InterviewUserCollection)
-	taskColl := mongoClient.DB(conf.Database).C(dao.TaskCollection)
+	interviewColl := mongoClient.C(dao.InterviewCollection)
+	accountTokenColl := mongoClient.C(dao.CollectionAccountToken)
+	interviewerUserColl := mongoClient.C(dao.InterviewUserCollection)
+	taskColl := mongoClient.C(dao.TaskCollection)
 	return &InterviewService{
 		mongoClient:       mongoClient,
 		interviewColl:     interviewColl,
@@ -48,11 +51,12 @@ func NewInterviewService(conf utils.MongoConfig, xl *xlog.Logger) (*InterviewSer
 	}, nil
 }
 
-func (c *InterviewService) CreateInterview(xl *xlog.Logger, interview *model.InterviewDo) (*model.InterviewDo, error) {
+func (c *InterviewService) CreateInterview(ctx context.Context, xl *xlog.Logger, interview *model.InterviewDo) (*model.InterviewDo, error) {
 	if xl == nil {
 		xl = c.xl
 	}
-	err := c.interviewColl.Insert(interview)
+	defer tracing.StartDAO(ctx, "InterviewService.CreateInterview").End()
+	err := c.interviewColl.WithContext(ctx).Insert(interview)
 	if err != nil {
 		xl.Errorf("failed to update user status of user %s, error %v", interview.Creator, err)
 		return nil, err
@@ -73,12 +77,12 @@ func (c *InterviewService) CreateInterview(xl *xlog.Logger, interview *model.Int
 		Status:         1,
 		LastModifyTime: createTime,
 	}
-	err = c.interviewUserColl.Insert(interviewer)
+	err = c.interviewUserColl.WithContext(ctx).Insert(interviewer)
 	if err != nil {
 		xl.Errorf("failed to update user status of user %s, error %v", interview.Creator, err)
 		return nil, err
 	}
-	err = c.interviewUserColl.Insert(candidate)
+	err = c.interviewUserColl.WithContext(ctx).Insert(candidate)
 	if err != nil {
 		xl.Errorf("failed to update user status of user %s, error %v", interview.Creator, err)
 		return nil, err
@@ -86,33 +90,48 @@ func (c *InterviewService) CreateInterview(xl *xlog.Logger, interview *model.Int
 	return interview, nil
 }
 
-func (c *InterviewService) ListInterviewsByPage(xl *xlog.Logger, userID string, pageNum int, pageSize int) ([]model.InterviewDo, int, error) {
+// ListInterviewsByPage 分页列出用户参与的面试，按状态倒序、开始时间正序排列，page.Cursor不为空时从游标之后读取，不统计总数。
+func (c *InterviewService) ListInterviewsByPage(ctx context.Context, xl *xlog.Logger, userID string, page model.PageQuery) ([]model.InterviewDo, model.PageResult, error) {
 	if xl == nil {
 		xl = c.xl
 	}
-	skip := (pageNum - 1) * pageSize
-	limit := pageSize
+	defer tracing.StartDAO(ctx, "InterviewService.ListInterviewsByPage").End()
 	interviews := []model.InterviewDo{}
-	err := c.interviewColl.Find(bson.M{"$or": []bson.M{bson.M{"candidate": userID}, bson.M{"interviewer": userID}, bson.M{"creator": userID}}}).Sort("-status", "startTime").Skip(skip).Limit(limit).All(&interviews)
+	filter := bson.M{"$or": []bson.M{bson.M{"candidate": userID}, bson.M{"interviewer": userID}, bson.M{"creator": userID}}}
+	query := filter
+	if page.Cursor != nil {
+		query = bson.M{"$and": []interface{}{filter, model.PageCursorFilter(
+			model.PageSortField{Name: "status", Desc: true, Value: page.Cursor.Status},
+			model.PageSortField{Name: "startTime", Value: page.Cursor.Time},
+			model.PageSortField{Name: "_id", Value: page.Cursor.ID},
+		)}}
+	}
+	err := c.interviewColl.WithContext(ctx).Find(query).Sort("-status", "startTime", "_id").Skip(page.Skip()).Limit(page.PageSize + 1).All(&interviews)
 	if err != nil {
 		xl.Errorf("failed to ListInterviews of userId %s, error %v", userID, err)
-		return nil, 0, err
+		return nil, model.PageResult{}, err
 	}
-	total, err := c.interviewColl.Find(bson.M{"$or": []bson.M{bson.M{"candidate": userID}, bson.M{"interviewer": userID}, bson.M{"creator": userID}}}).Count()
-	if err != nil {
-		xl.Errorf("failed to ListInterviews of userId %s, error %v", userID, err)
-		return nil, 0, err
+	res := model.NewPageResult(page, len(interviews), func(i int) *model.PageCursor {
+		return &model.PageCursor{Time: interviews[i].StartTime, Status: interviews[i].Status, ID: interviews[i].ID}
+	})
+	if page.Cursor == nil {
+		res.Total, err = c.interviewColl.WithContext(ctx).Find(filter).Count()
+		if err != nil {
+			xl.Errorf("failed to ListInterviews of userId %s, error %v", userID, err)
+			return nil, model.PageResult{}, err
+		}
 	}
-	return interviews, total, err
+	return interviews[:res.Count], res, nil
 }
 
 // GetRoomByFields 根据一组 key/value 关系查找直播房间。
-func (c *InterviewService) GetInterviewByFields(xl *xlog.Logger, fields map[string]interface{}) (*model.InterviewDo, error) {
+func (c *InterviewService) GetInterviewByFields(ctx context.Context, xl *xlog.Logger, fields map[string]interface{}) (*model.InterviewDo, error) {
 	if xl == nil {
 		xl = c.xl
 	}
+	defer tracing.StartDAO(ctx, "InterviewService.GetInterviewByFields").End()
 	interview := model.InterviewDo{}
-	err := c.interviewColl.Find(fields).One(&interview)
+	err := c.interviewColl.WithContext(ctx).Find(fields).One(&interview)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Infof("no such room for fields %v", fields)
@@ -124,15 +143,16 @@ func (c *InterviewService) GetInterviewByFields(xl *xlog.Logger, fields map[stri
 	return &interview, nil
 }
 
-func (c *InterviewService) GetInterviewByID(xl *xlog.Logger, interviewID string) (*model.InterviewDo, error) {
-	return c.GetInterviewByFields(xl, map[string]interface{}{"_id": interviewID})
+func (c *InterviewService) GetInterviewByID(ctx context.Context, xl *xlog.Logger, interviewID string) (*model.InterviewDo, error) {
+	return c.GetInterviewByFields(ctx, xl, map[string]interface{}{"_id": interviewID})
 }
 
-func (c *InterviewService) UpdateInterview(xl *xlog.Logger, id string, interview *model.InterviewDo) (*model.InterviewDo, error) {
+func (c *InterviewService) UpdateInterview(ctx context.Context, xl *xlog.Logger, id string, interview *model.InterviewDo) (*model.InterviewDo, error) {
 	if xl == nil {
 		xl = c.xl
 	}
-	err := c.interviewColl.Update(bson.M{"_id": id}, bson.M{"$set": interview})
+	defer tracing.StartDAO(ctx, "InterviewService.UpdateInterview").End()
+	err := c.interviewColl.WithContext(ctx).Update(bson.M{"_id": id}, bson.M{"$set": interview})
 	if err != nil {
 		xl.Errorf("failed to update interview %s,error %v", id, err)
 		return nil, err
@@ -140,10 +160,11 @@ func (c *InterviewService) UpdateInterview(xl *xlog.Logger, id string, interview
 	return interview, nil
 }
 
-func (c *InterviewService) JoinInterview(xl *xlog.Logger, userID string, interviewID string) ([]model.InterviewUserDo, []model.InterviewUserDo, error) {
+func (c *InterviewService) JoinInterview(ctx context.Context, xl *xlog.Logger, userID string, interviewID string) ([]model.InterviewUserDo, []model.InterviewUserDo, error) {
 	if xl == nil {
 		xl = c.xl
 	}
+	defer tracing.StartDAO(ctx, "InterviewService.JoinInterview").End()
 	interviewUserDo := &model.InterviewUserDo{
 		ID:             interviewID + "_" + userID,
 		InterviewID:    interviewID,
@@ -151,24 +172,24 @@ func (c *InterviewService) JoinInterview(xl *xlog.Logger, userID string, intervi
 		Status:         2,
 		LastModifyTime: time.Now(),
 	}
-	err := c.interviewUserColl.Update(bson.M{"userId": userID, "interviewId": interviewID}, bson.M{"$set": interviewUserDo})
+	err := c.interviewUserColl.WithContext(ctx).Update(bson.M{"userId": userID, "interviewId": interviewID}, bson.M{"$set": interviewUserDo})
 	if err != nil {
 		xl.Errorf("failed to update user status of user %s, error %v", userID, err)
 		return nil, nil, err
 	}
 	xl.Infof("user %s JoinInterview %s", userID, interviewID)
-	onlineInterviewUserDos, onlineUsersQueryErr := c.OnlineInterviewUsers(xl, userID, interviewID)
+	onlineInterviewUserDos, onlineUsersQueryErr := c.OnlineInterviewUsers(ctx, xl, userID, interviewID)
 	if onlineUsersQueryErr != nil {
 		xl.Errorf("failed to update user status of user %s, error %v", userID, err)
 		return nil, nil, onlineUsersQueryErr
 	}
-	allInterviewUserDos, allUsersQueryErr := c.AllInterviewUsers(xl, userID, interviewID)
+	allInterviewUserDos, allUsersQueryErr := c.AllInterviewUsers(ctx, xl, userID, interviewID)
 	if allUsersQueryErr != nil {
 		xl.Errorf("failed to update user status of user %s, error %v", userID, err)
 		return nil, nil, allUsersQueryErr
 	}
 	if len(onlineInterviewUserDos) > 1 {
-		interview, err := c.GetInterviewByID(xl, interviewID)
+		interview, err := c.GetInterviewByID(ctx, xl, interviewID)
 		if err != nil {
 			// TODO: 这里直接返回错误？
 			if err == mgo.ErrNotFound {
@@ -177,7 +198,7 @@ func (c *InterviewService) JoinInterview(xl *xlog.Logger, userID string, intervi
 			xl.Errorf("failed to get room %s, error %v", interviewID, err)
 		}
 		interview.Status = int(model.InterviewStatusCodeStart)
-		updateErr := c.interviewColl.Update(bson.M{"_id": interviewID}, bson.M{"$set": interview})
+		updateErr := c.interviewColl.WithContext(ctx).Update(bson.M{"_id": interviewID}, bson.M{"$set": interview})
 		if updateErr != nil {
 			xl.Errorf("failed to update interview %s,error %v", interviewID, updateErr)
 			return nil, nil, err
@@ -186,11 +207,12 @@ func (c *InterviewService) JoinInterview(xl *xlog.Logger, userID string, intervi
 	return onlineInterviewUserDos, allInterviewUserDos, nil
 }
 
-func (c *InterviewService) LeaveInterview(xl *xlog.Logger, userID string, interviewID string) error {
+func (c *InterviewService) LeaveInterview(ctx context.Context, xl *xlog.Logger, userID string, interviewID string) error {
 	if xl == nil {
 		xl = c.xl
 	}
-	_, err := c.GetInterviewByID(xl, interviewID)
+	defer tracing.StartDAO(ctx, "InterviewService.LeaveInterview").End()
+	_, err := c.GetInterviewByID(ctx, xl, interviewID)
 	if err != nil {
 		// TODO: 这里直接返回错误？
 		if err == mgo.ErrNotFound {
@@ -207,7 +229,7 @@ func (c *InterviewService) LeaveInterview(xl *xlog.Logger, userID string, interv
 		Status:         3,
 		LastModifyTime: time.Now(),
 	}
-	interviewUserUpdateErr := c.interviewUserColl.Update(bson.M{"userId": userID, "interviewId": interviewID}, bson.M{"$set": interviewUserDo})
+	interviewUserUpdateErr := c.interviewUserColl.WithContext(ctx).Update(bson.M{"userId": userID, "interviewId": interviewID}, bson.M{"$set": interviewUserDo})
 	if interviewUserUpdateErr != nil {
 		xl.Errorf("failed to update user status of user %s, error %v", userID, interviewUserUpdateErr)
 	}
@@ -215,41 +237,44 @@ func (c *InterviewService) LeaveInterview(xl *xlog.Logger, userID string, interv
 	return nil
 }
 
-func (c *InterviewService) OnlineInterviewUsers(xl *xlog.Logger, userID string, interviewID string) ([]model.InterviewUserDo, error) {
+func (c *InterviewService) OnlineInterviewUsers(ctx context.Context, xl *xlog.Logger, userID string, interviewID string) ([]model.InterviewUserDo, error) {
 	if xl == nil {
 		xl = c.xl
 	}
+	defer tracing.StartDAO(ctx, "InterviewService.OnlineInterviewUsers").End()
 	interviewUserDos := []model.InterviewUserDo{}
-	err := c.interviewUserColl.Find(bson.M{"interviewId": interviewID, "$or": []bson.M{bson.M{"status": 2}}}).All(&interviewUserDos)
+	err := c.interviewUserColl.WithContext(ctx).Find(bson.M{"interviewId": interviewID, "$or": []bson.M{bson.M{"status": 2}}}).All(&interviewUserDos)
 	return interviewUserDos, err
 }
 
-func (c *InterviewService) AllInterviewUsers(xl *xlog.Logger, userID string, interviewID string) ([]model.InterviewUserDo, error) {
+func (c *InterviewService) AllInterviewUsers(ctx context.Context, xl *xlog.Logger, userID string, interviewID string) ([]model.InterviewUserDo, error) {
 	if xl == nil {
 		xl = c.xl
 	}
+	defer tracing.StartDAO(ctx, "InterviewService.AllInterviewUsers").End()
 	interviewUserDos := []model.InterviewUserDo{}
-	err := c.interviewUserColl.Find(bson.M{"interviewId": interviewID}).All(&interviewUserDos)
+	err := c.interviewUserColl.WithContext(ctx).Find(bson.M{"interviewId": interviewID}).All(&interviewUserDos)
 	return interviewUserDos, err
 }
 
 // HeartBeat mark user LastHeartBeat moment
-func (c *InterviewService) HeartBeat(xl *xlog.Logger, userId, interviewID string) {
+func (c *InterviewService) HeartBeat(ctx context.Context, xl *xlog.Logger, userId, interviewID string) {
 	if xl == nil {
 		xl = c.xl
 	}
-	interview, err := c.GetInterviewByID(xl, interviewID)
+	defer tracing.StartDAO(ctx, "InterviewService.HeartBeat").End()
+	interview, err := c.GetInterviewByID(ctx, xl, interviewID)
 	if err != nil || interview.Status == int(model.InterviewStatusCodeEnd) {
 		return
 	}
 	var record model.InterviewUserDo
 	condition := bson.M{"_id": interviewID + "_" + userId}
-	err = c.interviewUserColl.Find(condition).One(&record)
+	err = c.interviewUserColl.WithContext(ctx).Find(condition).One(&record)
 	if err != nil {
 		return
 	}
 	record.LastHeartBeatTime = time.Now()
-	err = c.interviewUserColl.UpdateId(record.ID, record)
+	err = c.interviewUserColl.WithContext(ctx).UpdateId(record.ID, record)
 	if err != nil {
 		xl.Errorf("update interview user do err:%v", err)
 	}
@@ -257,10 +282,11 @@ func (c *InterviewService) HeartBeat(xl *xlog.Logger, userId, interviewID string
 }
 
 // ListHeartBeatTimeOutUser
-func (c *InterviewService) ListHeartBeatTimeOutUser(xl *xlog.Logger) ([]model.InterviewUserDo, error) {
+func (c *InterviewService) ListHeartBeatTimeOutUser(ctx context.Context, xl *xlog.Logger) ([]model.InterviewUserDo, error) {
 	if xl == nil {
 		xl = c.xl
 	}
+	defer tracing.StartDAO(ctx, "InterviewService.ListHeartBeatTimeOutUser").End()
 	ddl := time.Now().Add((time.Duration(model.HeartBeatInterval) + 5) * time.Second * (-1))
 	condition := bson.M{
 		"lastHeartBeatTime": bson.M{
@@ -269,19 +295,20 @@ func (c *InterviewService) ListHeartBeatTimeOutUser(xl *xlog.Logger) ([]model.In
 		"status": 2,
 	}
 	records := make([]model.InterviewUserDo, 0)
-	err := c.interviewUserColl.Find(condition).All(&records)
+	err := c.interviewUserColl.WithContext(ctx).Find(condition).All(&records)
 	return records, err
 }
 
-func (c *InterviewService) Online(xl *xlog.Logger, interviewId, userId string) bool {
+func (c *InterviewService) Online(ctx context.Context, xl *xlog.Logger, interviewId, userId string) bool {
 	if xl == nil {
 		xl = c.xl
 	}
-	_, err := c.GetInterviewByID(xl, interviewId)
+	defer tracing.StartDAO(ctx, "InterviewService.Online").End()
+	_, err := c.GetInterviewByID(ctx, xl, interviewId)
 	if err != nil {
 		return false
 	}
-	users, err := c.OnlineInterviewUsers(xl, userId, interviewId)
+	users, err := c.OnlineInterviewUsers(ctx, xl, userId, interviewId)
 	if err != nil {
 		return false
 	}
@@ -293,17 +320,18 @@ func (c *InterviewService) Online(xl *xlog.Logger, interviewId, userId string) b
 	return false
 }
 
-func (c *InterviewService) GetRecordURL(xl *xlog.Logger, interviewId string) string {
+func (c *InterviewService) GetRecordURL(ctx context.Context, xl *xlog.Logger, interviewId string) string {
 	if xl == nil {
 		xl = c.xl
 	}
+	defer tracing.StartDAO(ctx, "InterviewService.GetRecordURL").End()
 	condition := bson.M{
 		"subject":    "interview",
 		"action":     "record",
 		"subject_id": interviewId,
 	}
 	var record model.TaskResultDo
-	err := c.taskColl.Find(condition).One(&record)
+	err := c.taskColl.WithContext(ctx).Find(condition).One(&record)
 	if err != nil {
 		xl.Debugf("get interview record task err:%v", err)
 		return ""
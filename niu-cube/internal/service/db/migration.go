@@ -0,0 +1,183 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/qiniu/x/xlog"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/utils"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+	"github.com/solutions/niu-cube/internal/service/db/dao"
+)
+
+// Migration 一次数据迁移。迁移按Version递增的顺序执行，执行成功后记录在CollectionMigration中，不再重复执行。
+// 多处同时执行migrate up时同一迁移可能执行多次，Up需要可以重复执行。
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(xl *xlog.Logger, client *mongodb.Manager) error
+}
+
+// migrations 全部数据迁移，新的迁移追加在末尾，已发布的迁移不能修改版本号。
+var migrations = []Migration{
+	{Version: 1, Name: "remove legacy sessions", Up: removeLegacySessions},
+	{Version: 2, Name: "create base users for accounts", Up: createBaseUsers},
+}
+
+// MigrationStatus 迁移的执行状态，未执行时AppliedAt为零值。
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// MigrationRunner 创建dao.Indexes中声明的索引，并依次执行未执行过的数据迁移。
+type MigrationRunner struct {
+	client     *mongodb.Manager
+	coll       *mongodb.Collection
+	migrations []Migration
+	xl         *xlog.Logger
+}
+
+func NewMigrationRunner(conf utils.MongoConfig, xl *xlog.Logger) (*MigrationRunner, error) {
+	if xl == nil {
+		xl = xlog.New("niu-cube-migration")
+	}
+	client, err := mongodb.Get(&conf)
+	if err != nil {
+		xl.Errorf("failed to create mongo client, error %v", err)
+		return nil, err
+	}
+	return &MigrationRunner{
+		client:     client,
+		coll:       client.C(dao.CollectionMigration),
+		migrations: migrations,
+		xl:         xl,
+	}, nil
+}
+
+// Status 返回全部迁移的执行状态，按版本排列。
+func (r *MigrationRunner) Status(xl *xlog.Logger) ([]MigrationStatus, error) {
+	if xl == nil {
+		xl = r.xl
+	}
+	applied, err := r.applied(xl)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, 0, len(r.migrations))
+	for _, migration := range r.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = record.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Up 创建声明的索引，并按版本依次执行未执行过的迁移，返回本次执行的迁移。某个迁移失败时停止，之后的迁移不再执行。
+func (r *MigrationRunner) Up(xl *xlog.Logger) ([]Migration, error) {
+	if xl == nil {
+		xl = r.xl
+	}
+	err := dao.EnsureIndexes(r.client, xl)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := r.applied(xl)
+	if err != nil {
+		return nil, err
+	}
+	done := make([]Migration, 0)
+	for _, migration := range r.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		xl.Infof("applying migration %d %s", migration.Version, migration.Name)
+		err = migration.Up(xl, r.client)
+		if err != nil {
+			xl.Errorf("failed to apply migration %d %s, error %v", migration.Version, migration.Name, err)
+			return done, fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+		record := &model.MigrationDo{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}
+		err = r.coll.Insert(record)
+		// 其他实例已执行并记录了该迁移。
+		if err != nil && !mgo.IsDup(err) {
+			xl.Errorf("failed to record migration %d %s, error %v", migration.Version, migration.Name, err)
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// applied 返回已执行的迁移，按版本索引。
+func (r *MigrationRunner) applied(xl *xlog.Logger) (map[int]model.MigrationDo, error) {
+	records := make([]model.MigrationDo, 0)
+	err := r.coll.Find(nil).All(&records)
+	if err != nil {
+		xl.Errorf("failed to list applied migrations, error %v", err)
+		return nil, err
+	}
+	applied := make(map[int]model.MigrationDo, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// removeLegacySessions 清理旧版本按账号ID保存的登录记录。旧记录中的token不是JWT，也没有刷新token与活跃时间，
+// 升级后既不能访问也不能刷新，保留只会占用在线设备数并在会话列表中显示为从未活跃。
+func removeLegacySessions(xl *xlog.Logger, client *mongodb.Manager) error {
+	info, err := client.C(dao.CollectionAccountToken).RemoveAll(bson.M{"tokenId": bson.M{"$exists": false}})
+	if err != nil {
+		return err
+	}
+	if info.Removed > 0 {
+		xl.Infof("removed %d legacy sessions", info.Removed)
+	}
+	return nil
+}
+
+// createBaseUsers 为通用用户信息出现之前注册的账号创建通用用户信息，代替原来的account/sync接口。
+func createBaseUsers(xl *xlog.Logger, client *mongodb.Manager) error {
+	accounts := make([]model.AccountDo, 0)
+	err := client.C(dao.CollectionAccount).Find(nil).Select(bson.M{"nickname": 1, "avatar": 1}).All(&accounts)
+	if err != nil {
+		return err
+	}
+	baseUserColl := client.C(dao.CollectionBaseUser)
+	created := 0
+	for _, account := range accounts {
+		now := time.Now()
+		// 字段与model.BaseUserDo一致，已有通用用户信息的账号不修改。
+		baseUser := bson.M{
+			"name":            account.Nickname,
+			"nickname":        account.Nickname,
+			"avatar":          account.Avatar,
+			"status":          model.BaseUserLogin,
+			"profile":         "",
+			"created_time":    now,
+			"updated_time":    now,
+			"base_user_attrs": []model.BaseEntryDo{},
+		}
+		info, err := baseUserColl.Upsert(bson.M{"_id": account.ID}, bson.M{"$setOnInsert": baseUser})
+		if err != nil {
+			return err
+		}
+		if info.UpsertedId != nil {
+			created++
+		}
+	}
+	if created > 0 {
+		xl.Infof("created %d base users", created)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/solutions/niu-cube/internal/common/utils"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+	"github.com/solutions/niu-cube/internal/service/db/dao"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestMigrationRunner(t *testing.T) {
+	conf := testMongoConfig(t)
+	s, err := NewAccountService(conf, nil)
+	if err != nil {
+		t.Fatalf("NewAccountService: %v", err)
+	}
+	account := &model.AccountDo{ID: utils.GenerateID(), Phone: "13800000003", Nickname: "user-3"}
+	if err := s.CreateAccount(context.Background(), nil, account); err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+	// 旧版本以账号ID作为登录记录ID，token为随机字符串。
+	err = s.accountTokenColl.Insert(map[string]interface{}{"_id": account.ID, "accountId": account.ID, "token": "legacy", "lastmodifytime": time.Time{}})
+	if err != nil {
+		t.Fatalf("insert legacy session: %v", err)
+	}
+	login, err := s.AccountLogin(context.Background(), nil, account.ID, "", model.DeviceInfo{DeviceID: "device-1"})
+	if err != nil {
+		t.Fatalf("AccountLogin: %v", err)
+	}
+
+	runner, err := NewMigrationRunner(conf, nil)
+	if err != nil {
+		t.Fatalf("NewMigrationRunner: %v", err)
+	}
+	statuses, err := runner.Status(nil)
+	if err != nil || len(statuses) != len(migrations) || statuses[0].Applied {
+		t.Fatalf("status before migration = %+v, error %v", statuses, err)
+	}
+	done, err := runner.Up(nil)
+	if err != nil || len(done) != len(migrations) {
+		t.Fatalf("Up = %+v, error %v", done, err)
+	}
+
+	sessions, err := s.ListSessions(context.Background(), nil, account.ID)
+	if err != nil || len(sessions) != 1 || sessions[0].ID != login.ID {
+		t.Fatalf("sessions after migration = %+v, error %v", sessions, err)
+	}
+	baseUser := model.BaseUserDo{}
+	err = runner.client.C(dao.CollectionBaseUser).Find(bson.M{"_id": account.ID}).One(&baseUser)
+	if err != nil || baseUser.Nickname != account.Nickname || baseUser.Status != model.BaseUserLogin {
+		t.Fatalf("base user after migration = %+v, error %v", baseUser, err)
+	}
+
+	statuses, err = runner.Status(nil)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for _, status := range statuses {
+		if !status.Applied || status.AppliedAt.IsZero() {
+			t.Fatalf("migration %d not recorded: %+v", status.Version, status)
+		}
+	}
+	done, err = runner.Up(nil)
+	if err != nil || len(done) != 0 {
+		t.Fatalf("second Up = %+v, error %v", done, err)
+	}
+}
@@ -0,0 +1,18 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/solutions/niu-cube/internal/common/utils"
+	"github.com/solutions/niu-cube/internal/service/db/dao/daotest"
+)
+
+// testMongoConfig 返回一个独立的测试数据库配置，并设置签发登录token需要的JwtKey。
+func testMongoConfig(t *testing.T) utils.MongoConfig {
+	t.Helper()
+	if utils.DefaultConf.JwtKey == "" {
+		utils.DefaultConf.JwtKey = "niu-cube-test-jwt-key"
+	}
+	_, conf := daotest.NewDatabase(t)
+	return conf
+}
@@ -1,7 +1,10 @@
 package db
 
 import (
+	"context"
 	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/db/dao"
@@ -9,14 +12,14 @@ import (
 )
 
 type QiniuIMUserInterface interface {
-	CreateAccount(xl *xlog.Logger, imUserDo *model.IMUserDo) error
-	GetAccountByID(xl *xlog.Logger, id string) (*model.IMUserDo, error)
+	CreateAccount(ctx context.Context, xl *xlog.Logger, imUserDo *model.IMUserDo) error
+	GetAccountByID(ctx context.Context, xl *xlog.Logger, id string) (*model.IMUserDo, error)
 }
 
 // QiniuIMUserService 七牛IM用户
 type QiniuIMUserService struct {
-	mongoClient     *mgo.Session
-	qiniuIMUserColl *mgo.Collection
+	mongoClient     *mongodb.Manager
+	qiniuIMUserColl *mongodb.Collection
 	xl              *xlog.Logger
 }
 
@@ -24,12 +27,12 @@ func NewQiniuIMUserService(conf *utils.MongoConfig, xl *xlog.Logger) (*QiniuIMUs
 	if xl == nil {
 		xl = xlog.New("niu-cube-qiniu-im-user-db")
 	}
-	mongoClient, err := mgo.Dial(conf.URI + "/" + conf.Database)
+	mongoClient, err := mongodb.Get(conf)
 	if err != nil {
 		xl.Errorf("failed to create mongo client, error %v", err)
 		return nil, err
 	}
-	qiniuIMUserColl := mongoClient.DB(conf.Database).C(dao.CollectionQiniuIMUser)
+	qiniuIMUserColl := mongoClient.C(dao.CollectionQiniuIMUser)
 	return &QiniuIMUserService{
 		mongoClient:     mongoClient,
 		qiniuIMUserColl: qiniuIMUserColl,
@@ -38,11 +41,12 @@ func NewQiniuIMUserService(conf *utils.MongoConfig, xl *xlog.Logger) (*QiniuIMUs
 }
 
 // CreateAccount 创建用户账号。
-func (c *QiniuIMUserService) CreateAccount(xl *xlog.Logger, imUserDo *model.IMUserDo) error {
+func (c *QiniuIMUserService) CreateAccount(ctx context.Context, xl *xlog.Logger, imUserDo *model.IMUserDo) error {
 	if xl == nil {
 		xl = c.xl
 	}
-	err := c.qiniuIMUserColl.Insert(imUserDo)
+	defer tracing.StartDAO(ctx, "QiniuIMUserService.CreateAccount").End()
+	err := c.qiniuIMUserColl.WithContext(ctx).Insert(imUserDo)
 	if err != nil {
 		xl.Errorf("failed to insert qiniu im user, error %v", err)
 		return err
@@ -51,17 +55,18 @@ func (c *QiniuIMUserService) CreateAccount(xl *xlog.Logger, imUserDo *model.IMUs
 }
 
 // GetAccountByID 使用ID查找账号。
-func (c *QiniuIMUserService) GetAccountByID(xl *xlog.Logger, id string) (*model.IMUserDo, error) {
-	return c.GetAccountByFields(xl, map[string]interface{}{"username": id})
+func (c *QiniuIMUserService) GetAccountByID(ctx context.Context, xl *xlog.Logger, id string) (*model.IMUserDo, error) {
+	return c.GetAccountByFields(ctx, xl, map[string]interface{}{"username": id})
 }
 
 // GetAccountByFields 根据一组key/value关系查找用户账号。
-func (c *QiniuIMUserService) GetAccountByFields(xl *xlog.Logger, fields map[string]interface{}) (*model.IMUserDo, error) {
+func (c *QiniuIMUserService) GetAccountByFields(ctx context.Context, xl *xlog.Logger, fields map[string]interface{}) (*model.IMUserDo, error) {
 	if xl == nil {
 		xl = c.xl
 	}
+	defer tracing.StartDAO(ctx, "QiniuIMUserService.GetAccountByFields").End()
 	imUser := model.IMUserDo{}
-	err := c.qiniuIMUserColl.Find(fields).One(&imUser)
+	err := c.qiniuIMUserColl.WithContext(ctx).Find(fields).One(&imUser)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Infof("no such qiniu im user for fields %v", fields)
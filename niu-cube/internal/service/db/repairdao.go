@@ -1,8 +1,11 @@
 package db
 
 import (
+	"context"
 	"fmt"
 	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
 	"github.com/solutions/niu-cube/internal/protodef/model"
@@ -14,41 +17,41 @@ import (
 
 type RepairInterface interface {
 	// 创建修理房间
-	CreateRoom(xl *xlog.Logger, repairRoom *model.RepairRoomDo) (*model.RepairRoomDo, error)
+	CreateRoom(ctx context.Context, xl *xlog.Logger, repairRoom *model.RepairRoomDo) (*model.RepairRoomDo, error)
 	// 创建房间和用户的关系
-	CreateRoomUser(xl *xlog.Logger, repairRoomUser *model.RepairRoomUserDo) (*model.RepairRoomUserDo, error)
+	CreateRoomUser(ctx context.Context, xl *xlog.Logger, repairRoomUser *model.RepairRoomUserDo) (*model.RepairRoomUserDo, error)
 
 	// 加入房间
-	JoinRoom(xl *xlog.Logger, userID string, roomID string, role string) (*model.RepairRoomDo, []model.RepairRoomUserDo, error)
+	JoinRoom(ctx context.Context, xl *xlog.Logger, userID string, roomID string, role string) (*model.RepairRoomDo, []model.RepairRoomUserDo, error)
 
 	// LimitStaff 限制检修员数量
-	LimitStaff(role, roomId string) (bool, error)
+	LimitStaff(ctx context.Context, role, roomId string) (bool, error)
 
 	// 按房间号查询房间
-	GetRoomByID(xl *xlog.Logger, roomID string) (*model.RepairRoomDo, error)
+	GetRoomByID(ctx context.Context, xl *xlog.Logger, roomID string) (*model.RepairRoomDo, error)
 
-	LeaveRoom(xl *xlog.Logger, userID string, roomID string) error
+	LeaveRoom(ctx context.Context, xl *xlog.Logger, userID string, roomID string) error
 
 	// 房间列表查询
-	ListRoomsByPage(xl *xlog.Logger, userID string, pageNum int, pageSize int) ([]model.RepairRoomDo, int, error)
+	ListRoomsByPage(ctx context.Context, xl *xlog.Logger, userID string, pageNum int, pageSize int) ([]model.RepairRoomDo, int, error)
 
 	// 心跳
-	HeartBeat(xl *xlog.Logger, userID string, roomID string) error
+	HeartBeat(ctx context.Context, xl *xlog.Logger, userID string, roomID string) error
 
 	// 获取房间信息
-	GetRoomContent(xl *xlog.Logger, userID string, roomID string) (*model.RepairRoomDo, []model.RepairRoomUserDo, error)
+	GetRoomContent(ctx context.Context, xl *xlog.Logger, userID string, roomID string) (*model.RepairRoomDo, []model.RepairRoomUserDo, error)
 
 	// 获取超时用户
-	ListHeartBeatTimeOutUser(xl *xlog.Logger) ([]model.RepairRoomUserDo, error)
+	ListHeartBeatTimeOutUser(ctx context.Context, xl *xlog.Logger) ([]model.RepairRoomUserDo, error)
 
 	// 房间里是的包含有效的检修员
-	ContainStaff(roomID string) (bool, error)
+	ContainStaff(ctx context.Context, roomID string) (bool, error)
 }
 
 type RepairService struct {
-	mongoClient        *mgo.Session
-	repairRoomColl     *mgo.Collection
-	repairRoomUserColl *mgo.Collection
+	mongoClient        *mongodb.Manager
+	repairRoomColl     *mongodb.Collection
+	repairRoomUserColl *mongodb.Collection
 	xl                 *xlog.Logger
 }
 
@@ -56,13 +59,13 @@ func NewRepairService(conf utils.MongoConfig, xl *xlog.Logger) (*RepairService,
 	if xl == nil {
 		xl = xlog.New("niu-cube-repair")
 	}
-	mongoClient, err := mgo.Dial(conf.URI)
+	mongoClient, err := mongodb.Get(&conf)
 	if err != nil {
 		xl.Errorf("failed to create mongo client, error %v", err)
 		return nil, err
 	}
-	repairRoomColl := mongoClient.DB(conf.Database).C(dao.CollectionRepairRoom)
-	repairRoomUserColl := mongoClient.DB(conf.Database).C(dao.CollectionRepairRoomUser)
+	repairRoomColl := mongoClient.C(dao.CollectionRepairRoom)
+	repairRoomUserColl := mongoClient.C(dao.CollectionRepairRoomUser)
 	return &RepairService{
 		mongoClient:        mongoClient,
 		repairRoomColl:     repairRoomColl,
@@ -71,11 +74,12 @@ func NewRepairService(conf utils.MongoConfig, xl *xlog.Logger) (*RepairService,
 	}, nil
 }
 
-func (c *RepairService) CreateRoom(xl *xlog.Logger, repairRoom *model.RepairRoomDo) (*model.RepairRoomDo, error) {
+func (c *RepairService) CreateRoom(ctx context.Context, xl *xlog.Logger, repairRoom *model.RepairRoomDo) (*model.RepairRoomDo, error) {
 	if xl == nil {
 		xl = c.xl
 	}
-	err := c.repairRoomColl.Insert(repairRoom)
+	defer tracing.StartDAO(ctx, "RepairService.CreateRoom").End()
+	err := c.repairRoomColl.WithContext(ctx).Insert(repairRoom)
 	if err != nil {
 		xl.Errorf("failed to Insert repairRoom  repairRoom: %v, error %v", repairRoom, err)
 		return nil, err
@@ -84,11 +88,12 @@ func (c *RepairService) CreateRoom(xl *xlog.Logger, repairRoom *model.RepairRoom
 	return repairRoom, nil
 }
 
-func (c *RepairService) CreateRoomUser(xl *xlog.Logger, repairRoomUser *model.RepairRoomUserDo) (*model.RepairRoomUserDo, error) {
+func (c *RepairService) CreateRoomUser(ctx context.Context, xl *xlog.Logger, repairRoomUser *model.RepairRoomUserDo) (*model.RepairRoomUserDo, error) {
 	if xl == nil {
 		xl = c.xl
 	}
-	err := c.repairRoomUserColl.Insert(repairRoomUser)
+	defer tracing.StartDAO(ctx, "RepairService.CreateRoomUser").End()
+	err := c.repairRoomUserColl.WithContext(ctx).Insert(repairRoomUser)
 	if err != nil {
 		xl.Errorf("failed to Insert CreateRoomUser  repairRoomUser: %v, error %v", repairRoomUser, err)
 		return nil, err
@@ -97,13 +102,14 @@ func (c *RepairService) CreateRoomUser(xl *xlog.Logger, repairRoomUser *model.Re
 	return repairRoomUser, nil
 }
 
-func (c *RepairService) JoinRoom(xl *xlog.Logger, userID string, roomId string, role string) (*model.RepairRoomDo, []model.RepairRoomUserDo, error) {
+func (c *RepairService) JoinRoom(ctx context.Context, xl *xlog.Logger, userID string, roomId string, role string) (*model.RepairRoomDo, []model.RepairRoomUserDo, error) {
 	if xl == nil {
 		xl = c.xl
 	}
+	defer tracing.StartDAO(ctx, "RepairService.JoinRoom").End()
 
 	// 查看是否存在房间
-	room, err := c.GetRoomByID(xl, roomId)
+	room, err := c.GetRoomByID(ctx, xl, roomId)
 	if err != nil || room.Status == int(model.RepairRoomStatusCodeClose) {
 		xl.Infof("room is not exit or close ,roomId: %s", roomId)
 		return nil, nil, fmt.Errorf("房间异常")
@@ -111,7 +117,7 @@ func (c *RepairService) JoinRoom(xl *xlog.Logger, userID string, roomId string,
 
 	// 限制检修员数量
 	if role == string(model.RepairRoomRoleStaff) {
-		if ok, _ := c.LimitStaff(userID, roomId); !ok {
+		if ok, _ := c.LimitStaff(ctx, userID, roomId); !ok {
 			xl.Infof("there is already a staff in the room[%s]", roomId)
 			return nil, nil, fmt.Errorf("仅限一名检修员")
 		}
@@ -121,7 +127,7 @@ func (c *RepairService) JoinRoom(xl *xlog.Logger, userID string, roomId string,
 	var repairRoomUser model.RepairRoomUserDo
 	condition := bson.M{"_id": roomId + "_" + userID}
 
-	err = c.repairRoomUserColl.Find(condition).One(&repairRoomUser)
+	err = c.repairRoomUserColl.WithContext(ctx).Find(condition).One(&repairRoomUser)
 	if err != nil {
 		// add
 		repairRoomUser := &model.RepairRoomUserDo{
@@ -134,7 +140,7 @@ func (c *RepairService) JoinRoom(xl *xlog.Logger, userID string, roomId string,
 			UpdateTime:        time.Now(),
 			LastHeartBeatTime: time.Now(),
 		}
-		_, err = c.CreateRoomUser(xl, repairRoomUser)
+		_, err = c.CreateRoomUser(ctx, xl, repairRoomUser)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -144,14 +150,14 @@ func (c *RepairService) JoinRoom(xl *xlog.Logger, userID string, roomId string,
 		repairRoomUser.Role = role
 		repairRoomUser.UpdateTime = time.Now()
 		repairRoomUser.LastHeartBeatTime = time.Now()
-		err = c.repairRoomUserColl.UpdateId(repairRoomUser.ID, repairRoomUser)
+		err = c.repairRoomUserColl.WithContext(ctx).UpdateId(repairRoomUser.ID, repairRoomUser)
 		if err != nil {
 			xl.Errorf("repairRoomUserColl.UpdateId err:%v", err)
 			return nil, nil, err
 		}
 	}
 	// 加入房间
-	allRoomUserDos, allUsersQueryErr := c.AllRoomUsers(xl, roomId)
+	allRoomUserDos, allUsersQueryErr := c.AllRoomUsers(ctx, xl, roomId)
 	if allUsersQueryErr != nil {
 		xl.Errorf("allRoomUsers failed  roomId %s, error %v", roomId, err)
 		return nil, nil, allUsersQueryErr
@@ -159,9 +165,9 @@ func (c *RepairService) JoinRoom(xl *xlog.Logger, userID string, roomId string,
 	return room, allRoomUserDos, nil
 }
 
-func (c *RepairService) LimitStaff(userId, roomId string) (bool, error) {
+func (c *RepairService) LimitStaff(ctx context.Context, userId, roomId string) (bool, error) {
 	var repairRoomUserDos []model.RepairRoomUserDo
-	err := c.repairRoomUserColl.Find(bson.M{"roomId": roomId, "role": model.RepairRoomRoleStaff, "status": model.RepairRoomUserStatusCodeNormal}).All(&repairRoomUserDos)
+	err := c.repairRoomUserColl.WithContext(ctx).Find(bson.M{"roomId": roomId, "role": model.RepairRoomRoleStaff, "status": model.RepairRoomUserStatusCodeNormal}).All(&repairRoomUserDos)
 	if err != nil {
 		return false, err
 	}
@@ -173,13 +179,14 @@ func (c *RepairService) LimitStaff(userId, roomId string) (bool, error) {
 	return true, nil
 }
 
-func (c *RepairService) LeaveRoom(xl *xlog.Logger, userID string, roomID string) error {
+func (c *RepairService) LeaveRoom(ctx context.Context, xl *xlog.Logger, userID string, roomID string) error {
 	if xl == nil {
 		xl = c.xl
 	}
+	defer tracing.StartDAO(ctx, "RepairService.LeaveRoom").End()
 
 	// 查看是否存在房间
-	room, err := c.GetRoomByID(xl, roomID)
+	room, err := c.GetRoomByID(ctx, xl, roomID)
 	if err != nil {
 		xl.Infof("room is not exit ,roomId: %s", roomID)
 		return err
@@ -188,7 +195,7 @@ func (c *RepairService) LeaveRoom(xl *xlog.Logger, userID string, roomID string)
 	// 查看room_user
 	var repairRoomUser model.RepairRoomUserDo
 	condition := bson.M{"_id": roomID + "_" + userID}
-	err = c.repairRoomUserColl.Find(condition).One(&repairRoomUser)
+	err = c.repairRoomUserColl.WithContext(ctx).Find(condition).One(&repairRoomUser)
 
 	if err != nil {
 		// 不存在，直接当正常结束
@@ -199,14 +206,14 @@ func (c *RepairService) LeaveRoom(xl *xlog.Logger, userID string, roomID string)
 	repairRoomUser.Status = int(model.RepairRoomUserStatusCodeDelete)
 	repairRoomUser.UpdateTime = time.Now()
 	repairRoomUser.LastHeartBeatTime = time.Now()
-	err = c.repairRoomUserColl.UpdateId(repairRoomUser.ID, repairRoomUser)
+	err = c.repairRoomUserColl.WithContext(ctx).UpdateId(repairRoomUser.ID, repairRoomUser)
 	if err != nil {
 		xl.Errorf("repairRoomUserColl.UpdateId err:%v", err)
 		return err
 	}
 
 	// 查看房间里还有多少人，没有人的话房间关闭
-	allRoomUserDos, allUsersQueryErr := c.AllRoomUsers(xl, roomID)
+	allRoomUserDos, allUsersQueryErr := c.AllRoomUsers(ctx, xl, roomID)
 	if allUsersQueryErr != nil {
 		xl.Errorf("allRoomUsers failed  roomId %s, error %v", roomID, err)
 		return allUsersQueryErr
@@ -214,14 +221,14 @@ func (c *RepairService) LeaveRoom(xl *xlog.Logger, userID string, roomID string)
 	if len(allRoomUserDos) < 1 {
 		room.UpdateTime = time.Now()
 		room.Status = int(model.RepairRoomStatusCodeClose)
-		c.repairRoomColl.UpdateId(roomID, room)
+		c.repairRoomColl.WithContext(ctx).UpdateId(roomID, room)
 	}
 
 	return nil
 
 }
 
-func (c *RepairService) ListRoomsByPage(xl *xlog.Logger, userID string, pageNum int, pageSize int) ([]model.RepairRoomDo, int, error) {
+func (c *RepairService) ListRoomsByPage(ctx context.Context, xl *xlog.Logger, userID string, pageNum int, pageSize int) ([]model.RepairRoomDo, int, error) {
 
 	if xl == nil {
 		xl = c.xl
@@ -229,12 +236,12 @@ func (c *RepairService) ListRoomsByPage(xl *xlog.Logger, userID string, pageNum
 	skip := (pageNum - 1) * pageSize
 	limit := pageSize
 	repairRooms := []model.RepairRoomDo{}
-	err := c.repairRoomColl.Find(bson.M{"status": model.RepairRoomUserStatusCodeNormal}).Sort("-createTime").Skip(skip).Limit(limit).All(&repairRooms)
+	err := c.repairRoomColl.WithContext(ctx).Find(bson.M{"status": model.RepairRoomUserStatusCodeNormal}).Sort("-createTime").Skip(skip).Limit(limit).All(&repairRooms)
 	if err != nil {
 		xl.Errorf("failed to ListRoomsByPage of userId %s, error %v", userID, err)
 		return nil, 0, err
 	}
-	total, err := c.repairRoomColl.Find(bson.M{"status": model.RepairRoomUserStatusCodeNormal}).Count()
+	total, err := c.repairRoomColl.WithContext(ctx).Find(bson.M{"status": model.RepairRoomUserStatusCodeNormal}).Count()
 	if err != nil {
 		xl.Errorf("failed to ListRoomsByPage count of userId %s, error %v", userID, err)
 		return nil, 0, err
@@ -243,14 +250,15 @@ func (c *RepairService) ListRoomsByPage(xl *xlog.Logger, userID string, pageNum
 
 }
 
-func (c *RepairService) GetRoomByID(xl *xlog.Logger, roomID string) (*model.RepairRoomDo, error) {
+func (c *RepairService) GetRoomByID(ctx context.Context, xl *xlog.Logger, roomID string) (*model.RepairRoomDo, error) {
 	if xl == nil {
 		xl = c.xl
 	}
+	defer tracing.StartDAO(ctx, "RepairService.GetRoomByID").End()
 	fields := map[string]interface{}{"_id": roomID}
 
 	repairRoom := model.RepairRoomDo{}
-	err := c.repairRoomColl.Find(fields).One(&repairRoom)
+	err := c.repairRoomColl.WithContext(ctx).Find(fields).One(&repairRoom)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			xl.Infof("no such room for fields %v", fields)
@@ -262,13 +270,14 @@ func (c *RepairService) GetRoomByID(xl *xlog.Logger, roomID string) (*model.Repa
 	return &repairRoom, nil
 }
 
-func (c *RepairService) HeartBeat(xl *xlog.Logger, userID string, roomID string) error {
+func (c *RepairService) HeartBeat(ctx context.Context, xl *xlog.Logger, userID string, roomID string) error {
 	if xl == nil {
 		xl = c.xl
 	}
+	defer tracing.StartDAO(ctx, "RepairService.HeartBeat").End()
 
 	// 查看是否存在房间
-	room, err := c.GetRoomByID(xl, roomID)
+	room, err := c.GetRoomByID(ctx, xl, roomID)
 	if err != nil || room.Status == int(model.RepairRoomStatusCodeClose) {
 		xl.Infof("room is not exit or close ,roomId: %s", roomID)
 		return nil
@@ -277,7 +286,7 @@ func (c *RepairService) HeartBeat(xl *xlog.Logger, userID string, roomID string)
 	// 查看room_user
 	var repairRoomUser model.RepairRoomUserDo
 	condition := bson.M{"_id": roomID + "_" + userID}
-	err = c.repairRoomUserColl.Find(condition).One(&repairRoomUser)
+	err = c.repairRoomUserColl.WithContext(ctx).Find(condition).One(&repairRoomUser)
 
 	if err != nil {
 		// 不存在，直接当正常结束
@@ -287,7 +296,7 @@ func (c *RepairService) HeartBeat(xl *xlog.Logger, userID string, roomID string)
 
 	repairRoomUser.UpdateTime = time.Now()
 	repairRoomUser.LastHeartBeatTime = time.Now()
-	err = c.repairRoomUserColl.UpdateId(repairRoomUser.ID, repairRoomUser)
+	err = c.repairRoomUserColl.WithContext(ctx).UpdateId(repairRoomUser.ID, repairRoomUser)
 	if err != nil {
 		xl.Errorf("repairRoomUserColl.UpdateId err:%v", err)
 		return err
@@ -295,20 +304,20 @@ func (c *RepairService) HeartBeat(xl *xlog.Logger, userID string, roomID string)
 	return nil
 }
 
-func (c *RepairService) GetRoomContent(xl *xlog.Logger, userID string, roomID string) (*model.RepairRoomDo, []model.RepairRoomUserDo, error) {
+func (c *RepairService) GetRoomContent(ctx context.Context, xl *xlog.Logger, userID string, roomID string) (*model.RepairRoomDo, []model.RepairRoomUserDo, error) {
 
 	if xl == nil {
 		xl = c.xl
 	}
 
 	// 查看是否存在房间
-	room, err := c.GetRoomByID(xl, roomID)
+	room, err := c.GetRoomByID(ctx, xl, roomID)
 	if err != nil {
 		xl.Infof("room is not exit ,roomId: %s", roomID)
 		return nil, nil, err
 	}
 	// 加入房间
-	allRoomUserDos, allUsersQueryErr := c.AllRoomUsers(xl, roomID)
+	allRoomUserDos, allUsersQueryErr := c.AllRoomUsers(ctx, xl, roomID)
 	if allUsersQueryErr != nil {
 		xl.Errorf("allRoomUsers failed  roomId %s, error %v", roomID, err)
 		return nil, nil, allUsersQueryErr
@@ -316,7 +325,7 @@ func (c *RepairService) GetRoomContent(xl *xlog.Logger, userID string, roomID st
 	return room, allRoomUserDos, nil
 }
 
-func (c *RepairService) ListHeartBeatTimeOutUser(xl *xlog.Logger) ([]model.RepairRoomUserDo, error) {
+func (c *RepairService) ListHeartBeatTimeOutUser(ctx context.Context, xl *xlog.Logger) ([]model.RepairRoomUserDo, error) {
 
 	if xl == nil {
 		xl = c.xl
@@ -329,15 +338,15 @@ func (c *RepairService) ListHeartBeatTimeOutUser(xl *xlog.Logger) ([]model.Repai
 		"status": int(model.RepairRoomUserStatusCodeNormal),
 	}
 	roomUsers := make([]model.RepairRoomUserDo, 0)
-	err := c.repairRoomUserColl.Find(condition).Sort("-createTime").Limit(10).All(&roomUsers)
+	err := c.repairRoomUserColl.WithContext(ctx).Find(condition).Sort("-createTime").Limit(10).All(&roomUsers)
 	return roomUsers, err
 
 }
 
-func (c *RepairService) ContainStaff(roomID string) (bool, error) {
+func (c *RepairService) ContainStaff(ctx context.Context, roomID string) (bool, error) {
 
 	repairRoomUserDos := []model.RepairRoomUserDo{}
-	c.repairRoomUserColl.Find(bson.M{"roomId": roomID, "role": model.RepairRoomRoleStaff, "status": model.RepairRoomUserStatusCodeNormal}).All(&repairRoomUserDos)
+	c.repairRoomUserColl.WithContext(ctx).Find(bson.M{"roomId": roomID, "role": model.RepairRoomRoleStaff, "status": model.RepairRoomUserStatusCodeNormal}).All(&repairRoomUserDos)
 	if len(repairRoomUserDos) > 0 {
 		return true, nil
 	} else {
@@ -345,11 +354,12 @@ func (c *RepairService) ContainStaff(roomID string) (bool, error) {
 	}
 }
 
-func (c *RepairService) AllRoomUsers(xl *xlog.Logger, roomID string) ([]model.RepairRoomUserDo, error) {
+func (c *RepairService) AllRoomUsers(ctx context.Context, xl *xlog.Logger, roomID string) ([]model.RepairRoomUserDo, error) {
 	if xl == nil {
 		xl = c.xl
 	}
+	defer tracing.StartDAO(ctx, "RepairService.AllRoomUsers").End()
 	repairRoomUserDos := []model.RepairRoomUserDo{}
-	err := c.repairRoomUserColl.Find(bson.M{"roomId": roomID, "status": model.RepairRoomUserStatusCodeNormal}).All(&repairRoomUserDos)
+	err := c.repairRoomUserColl.WithContext(ctx).Find(bson.M{"roomId": roomID, "status": model.RepairRoomUserStatusCodeNormal}).All(&repairRoomUserDos)
 	return repairRoomUserDos, err
 }
@@ -3,6 +3,7 @@ package db
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/db/dao"
@@ -34,9 +35,9 @@ type RoomService interface {
 }
 
 type RoomServiceImpl struct {
-	roomColl        *mgo.Collection
-	roomAccountColl *mgo.Collection
-	bizExtraColl    *mgo.Collection
+	roomColl        *mongodb.Collection
+	roomAccountColl *mongodb.Collection
+	bizExtraColl    *mongodb.Collection
 }
 
 func (r RoomServiceImpl) Enter(rAccountId string, roomRole string, roomId string) error {
@@ -267,13 +268,13 @@ func (r RoomServiceImpl) KickAllUser(roomName string) error {
 }
 
 func NewRoomService() *RoomServiceImpl {
-	client, err := mgo.Dial(utils.DefaultConf.Mongo.URI)
+	client, err := mongodb.Get(utils.DefaultConf.Mongo)
 	if err != nil {
 		panic(err)
 	}
-	roomColl := client.DB(utils.DefaultConf.Mongo.Database).C(dao.CollectionRoom)
-	roomAccountColl := client.DB(utils.DefaultConf.Mongo.Database).C(dao.CollectionRoomAccount)
-	bizExtraColl := client.DB(utils.DefaultConf.Mongo.Database).C(dao.CollectionBizExtra)
+	roomColl := client.C(dao.CollectionRoom)
+	roomAccountColl := client.C(dao.CollectionRoomAccount)
+	bizExtraColl := client.C(dao.CollectionBizExtra)
 	return &RoomServiceImpl{roomColl: roomColl, roomAccountColl: roomAccountColl, bizExtraColl: bizExtraColl}
 }
 
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 
@@ -8,6 +9,7 @@ import (
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
+	"github.com/solutions/niu-cube/internal/common/mongodb"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/db/dao"
@@ -18,22 +20,22 @@ var (
 )
 
 type TaskService struct {
-	taskCollection *mgo.Collection
+	taskCollection *mongodb.Collection
 	xl             *xlog.Logger
 }
 
 func NewTaskService(xl *xlog.Logger, config utils.MongoConfig) *TaskService {
 	v := new(TaskService)
 	v.xl = xlog.New("task db")
-	db, err := mgo.Dial(config.URI)
+	db, err := mongodb.Get(&config)
 	if err != nil {
 		v.xl.Fatalf("error dialing db error:%v", err)
 	}
-	err = db.Ping()
+	err = db.Ping(context.Background())
 	if err != nil {
 		v.xl.Fatalf("err ping db error:%v", err)
 	}
-	v.taskCollection = db.DB(config.Database).C(dao.TaskCollection)
+	v.taskCollection = db.C(dao.TaskCollection)
 	return v
 }
 
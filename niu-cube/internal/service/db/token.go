@@ -1,32 +1,33 @@
 package db
 
 import (
+	"context"
 	"fmt"
 	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	model "github.com/solutions/niu-cube/internal/protodef/model"
 	dao "github.com/solutions/niu-cube/internal/service/db/dao"
-	"gopkg.in/mgo.v2"
 	"math/rand"
 )
 
 type AccountTokenService struct {
-	accountTokenCollection *mgo.Collection
+	accountTokenCollection *mongodb.Collection
 	xl                     *xlog.Logger
 }
 
 func NewAccountTokenService(xl *xlog.Logger, config utils.MongoConfig) *AccountTokenService {
 	v := new(AccountTokenService)
 	v.xl = xlog.New("accountToken service")
-	db, err := mgo.Dial(config.URI)
+	db, err := mongodb.Get(&config)
 	if err != nil {
 		v.xl.Fatalf("error dialing service error:%v", err)
 	}
-	err = db.Ping()
+	err = db.Ping(context.Background())
 	if err != nil {
 		v.xl.Fatalf("err ping db error:%v", err)
 	}
-	v.accountTokenCollection = db.DB(config.Database).C(dao.CollectionAccountToken)
+	v.accountTokenCollection = db.C(dao.CollectionAccountToken)
 	return v
 }
 
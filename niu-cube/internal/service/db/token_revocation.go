@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
+	model "github.com/solutions/niu-cube/internal/protodef/model"
+	dao "github.com/solutions/niu-cube/internal/service/db/dao"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// TokenRevocationDefaultSyncInterval 默认从数据库同步吊销记录的间隔。
+	TokenRevocationDefaultSyncInterval = 10 * time.Second
+)
+
+var (
+	defaultRevocationList      *TokenRevocationList
+	defaultRevocationListMutex sync.Mutex
+)
+
+// TokenRevocationList 已吊销的登录token列表。
+// 校验token时只查内存，吊销时同时写入数据库，并定期从数据库同步，保证多实例部署时的一致性。
+type TokenRevocationList struct {
+	mutex   sync.RWMutex
+	revoked map[string]time.Time
+	coll    *mongodb.Collection
+	xl      *xlog.Logger
+}
+
+// getRevocationList 返回进程内共享的吊销列表，同一进程内的多个AccountService共用一份，
+// 使用第一个创建它的AccountService的数据库连接。
+func getRevocationList(db *mongodb.Manager, syncInterval time.Duration) (*TokenRevocationList, error) {
+	defaultRevocationListMutex.Lock()
+	defer defaultRevocationListMutex.Unlock()
+	if defaultRevocationList != nil {
+		return defaultRevocationList, nil
+	}
+	list, err := NewTokenRevocationList(db, nil)
+	if err != nil {
+		return nil, err
+	}
+	list.sync()
+	go list.loop(syncInterval)
+	defaultRevocationList = list
+	return list, nil
+}
+
+// NewTokenRevocationList 创建吊销列表，token过期后记录由dao.Indexes中声明的TTL索引清理。
+func NewTokenRevocationList(db *mongodb.Manager, xl *xlog.Logger) (*TokenRevocationList, error) {
+	if xl == nil {
+		xl = xlog.New("niu-cube-token-revocation")
+	}
+	coll := db.C(dao.CollectionRevokedToken)
+	return &TokenRevocationList{
+		revoked: make(map[string]time.Time),
+		coll:    coll,
+		xl:      xl,
+	}, nil
+}
+
+// Revoke 吊销jti为tokenID的token，expireAt之后该记录不再需要保留。
+func (l *TokenRevocationList) Revoke(ctx context.Context, xl *xlog.Logger, accountID string, tokenID string, expireAt time.Time) error {
+	if xl == nil {
+		xl = l.xl
+	}
+	defer tracing.StartDAO(ctx, "TokenRevocationList.Revoke").End()
+	if tokenID == "" {
+		return nil
+	}
+	l.mutex.Lock()
+	l.revoked[tokenID] = expireAt
+	l.mutex.Unlock()
+	record := &model.RevokedTokenDo{
+		ID:        tokenID,
+		AccountId: accountID,
+		ExpireAt:  expireAt,
+	}
+	_, err := l.coll.UpsertId(tokenID, record)
+	if err != nil {
+		xl.Errorf("failed to save revoked token %s, error %v", tokenID, err)
+		return err
+	}
+	return nil
+}
+
+// IsRevoked 判断jti为tokenID的token是否已被吊销。
+func (l *TokenRevocationList) IsRevoked(tokenID string) bool {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	_, ok := l.revoked[tokenID]
+	return ok
+}
+
+func (l *TokenRevocationList) loop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		l.sync()
+	}
+}
+
+// sync 从数据库加载未过期的吊销记录，并清理内存中已过期的记录。
+func (l *TokenRevocationList) sync() {
+	now := time.Now()
+	records := make([]model.RevokedTokenDo, 0)
+	err := l.coll.Find(bson.M{"expireAt": bson.M{"$gt": now}}).All(&records)
+	if err != nil {
+		l.xl.Errorf("failed to load revoked tokens, error %v", err)
+	}
+	l.merge(records, now)
+}
+
+// merge 合并从数据库加载的吊销记录，并清理now之前已过期的记录。
+func (l *TokenRevocationList) merge(records []model.RevokedTokenDo, now time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, record := range records {
+		l.revoked[record.ID] = record.ExpireAt
+	}
+	for tokenID, expireAt := range l.revoked {
+		if expireAt.Before(now) {
+			delete(l.revoked, tokenID)
+		}
+	}
+}
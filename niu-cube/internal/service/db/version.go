@@ -1,30 +1,31 @@
 package db
 
 import (
+	"context"
 	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	model "github.com/solutions/niu-cube/internal/protodef/model"
-	"gopkg.in/mgo.v2"
 	"math/rand"
 )
 
 type VersionService struct {
-	versionCollection *mgo.Collection
+	versionCollection *mongodb.Collection
 	xl                *xlog.Logger
 }
 
 func NewVersionService(xl *xlog.Logger, config utils.MongoConfig) *VersionService {
 	v := new(VersionService)
 	v.xl = xlog.New("version service")
-	db, err := mgo.Dial(config.URI)
+	db, err := mongodb.Get(&config)
 	if err != nil {
 		v.xl.Fatalf("error dialing service error:%v", err)
 	}
-	err = db.Ping()
+	err = db.Ping(context.Background())
 	if err != nil {
 		v.xl.Fatalf("err ping db error:%v", err)
 	}
-	v.versionCollection = db.DB(config.Database).C("versions")
+	v.versionCollection = db.C("versions")
 	return v
 }
 
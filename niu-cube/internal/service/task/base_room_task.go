@@ -1,6 +1,7 @@
 package task
 
 import (
+	"context"
 	"github.com/solutions/niu-cube/internal/service/db"
 	"gopkg.in/mgo.v2"
 	"time"
@@ -53,57 +54,71 @@ func NewBaseRoomTaskService(config utils.Config) (*BaseRoomTask, error) {
 	}, nil
 }
 
-func (t *BaseRoomTask) StartTimeoutUserTask() {
+// StartTimeoutUserTask 将心跳超时的用户下线，列举超时用户失败时返回错误。
+func (t *BaseRoomTask) StartTimeoutUserTask(ctx context.Context, xl *xlog.Logger) error {
+	if xl == nil {
+		xl = t.xl
+	}
+	ctx, cancel := context.WithTimeout(ctx, taskTimeout)
+	defer cancel()
 	oldTime := time.Now().UnixMilli() - 10*time.Minute.Milliseconds()
 	threshold := time.UnixMilli(oldTime)
-	list, err := t.baseRoomUser.ListByHeartbeatTimeout(t.xl, threshold)
+	list, err := t.baseRoomUser.ListByHeartbeatTimeout(ctx, xl, threshold)
 	if err != nil {
-		t.xl.Error("list base_room_user failed!")
-		return
+		xl.Error("list base_room_user failed!")
+		return err
 	}
 	for _, val := range list {
-		t.outline(&val)
+		t.outline(ctx, xl, &val)
 	}
+	return nil
 }
 
-func (t *BaseRoomTask) StartIdleRoomTask() {
+// StartIdleRoomTask 释放长时间无人的房间，列举房间失败时返回错误。
+func (t *BaseRoomTask) StartIdleRoomTask(ctx context.Context, xl *xlog.Logger) error {
+	if xl == nil {
+		xl = t.xl
+	}
+	ctx, cancel := context.WithTimeout(ctx, taskTimeout)
+	defer cancel()
 	oldTime := time.Now().UnixMilli() - 1*time.Hour.Milliseconds()
 	threshold := time.UnixMilli(oldTime)
-	list, err := t.baseRoom.ListByTimeout(t.xl, threshold)
+	list, err := t.baseRoom.ListByTimeout(ctx, xl, threshold)
 	if err != nil && err != mgo.ErrNotFound {
-		t.xl.Error("list base_room for timeout failed!")
-		return
+		xl.Error("list base_room for timeout failed!")
+		return err
 	}
 	for _, val := range list {
-		l, _ := t.baseRoomUser.ListByRoomId(t.xl, val.Id)
+		l, _ := t.baseRoomUser.ListByRoomId(ctx, xl, val.Id)
 		// 如果没人且距离上次修改超过了一个小时，将释放房间
 		if len(l) == 0 {
-			t.xl.Infof("release room: %s", val.Id)
+			xl.Infof("release room: %s", val.Id)
 			val.Status = model.BaseRoomDestroyed
-			_ = t.baseRoom.Update(t.xl, &val)
-			_ = t.appConfig.DestroyGroupChat(t.xl, val.QiniuIMGroupId)
+			_ = t.baseRoom.Update(ctx, xl, &val)
+			_ = t.appConfig.DestroyGroupChat(ctx, xl, val.QiniuIMGroupId)
 		}
 	}
+	return nil
 }
 
-func (t *BaseRoomTask) outline(roomUser *model.BaseRoomUserDo) {
-	room, _ := t.baseRoom.Select(nil, roomUser.RoomId)
+func (t *BaseRoomTask) outline(ctx context.Context, xl *xlog.Logger, roomUser *model.BaseRoomUserDo) {
+	room, _ := t.baseRoom.Select(ctx, xl, roomUser.RoomId)
 	if room != nil && room.Creator == roomUser.UserId {
-		t.xl.Infof("room creator outline, and the room will be destroyed.")
+		xl.Infof("room creator outline, and the room will be destroyed.")
 		room.Status = model.BaseRoomDestroyed
-		_ = t.baseRoom.Update(nil, room)
-		_ = t.appConfig.DestroyGroupChat(t.xl, room.QiniuIMGroupId)
+		_ = t.baseRoom.Update(ctx, xl, room)
+		_ = t.appConfig.DestroyGroupChat(ctx, xl, room.QiniuIMGroupId)
 	}
-	userMic, _ := t.baseUserMic.SelectByRoomIdUserId(nil, roomUser.RoomId, roomUser.UserId)
+	userMic, _ := t.baseUserMic.SelectByRoomIdUserId(ctx, xl, roomUser.RoomId, roomUser.UserId)
 	if userMic != nil {
 		userMic.Status = model.BaseUserMicNonHold
-		_ = t.baseUserMic.Update(nil, userMic)
-		roomMic, _ := t.baseRoomMic.Select(nil, userMic.RoomId, userMic.MicId)
+		_ = t.baseUserMic.Update(ctx, xl, userMic)
+		roomMic, _ := t.baseRoomMic.Select(ctx, xl, userMic.RoomId, userMic.MicId)
 		if roomMic != nil {
 			roomMic.Status = model.BaseRoomMicUnused
-			_ = t.baseRoomMic.Update(nil, roomMic)
+			_ = t.baseRoomMic.Update(ctx, xl, roomMic)
 		}
 	}
 	roomUser.Status = model.BaseRoomUserTimeout
-	_ = t.baseRoomUser.Update(nil, roomUser)
+	_ = t.baseRoomUser.Update(ctx, xl, roomUser)
 }
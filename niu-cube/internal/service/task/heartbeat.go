@@ -1,9 +1,11 @@
 package task
 
 import (
+	"context"
 	"github.com/qiniu/x/xlog"
-	"gopkg.in/mgo.v2"
 
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/cloud"
@@ -14,7 +16,7 @@ import (
 type HeartBeatCheckTask struct {
 	interviewService *db.InterviewService
 	rtc              *cloud.RTCService
-	taskColl         *mgo.Collection
+	taskColl         *mongodb.Collection
 	xl               *xlog.Logger
 }
 
@@ -25,11 +27,11 @@ func NewHeartBeatTask(conf utils.Config) *HeartBeatCheckTask {
 	if err != nil {
 		panic(err)
 	}
-	client, err := mgo.Dial(conf.Mongo.URI)
+	client, err := mongodb.Get(conf.Mongo)
 	if err != nil {
 		panic(err)
 	}
-	taskColl := client.DB(utils.DefaultConf.Mongo.Database).C(dao.TaskCollection)
+	taskColl := client.C(dao.TaskCollection)
 	return &HeartBeatCheckTask{
 		interviewService: interviewService,
 		rtc:              cloud.NewRtcService(utils.DefaultConf),
@@ -38,35 +40,44 @@ func NewHeartBeatTask(conf utils.Config) *HeartBeatCheckTask {
 	}
 }
 
-func (h *HeartBeatCheckTask) Start() {
-	users, err := h.interviewService.ListHeartBeatTimeOutUser(h.xl)
+func (h *HeartBeatCheckTask) Start(ctx context.Context, xl *xlog.Logger) error {
+	if xl == nil {
+		xl = h.xl
+	}
+	ctx, cancel := context.WithTimeout(ctx, taskTimeout)
+	defer cancel()
+	users, err := h.interviewService.ListHeartBeatTimeOutUser(ctx, xl)
 	if err != nil {
-		h.xl.Errorf("error list heartbeat timeout user:%v", err)
-		return
+		xl.Errorf("error list heartbeat timeout user:%v", err)
+		return err
 	}
 	for _, user := range users {
+		// 踢人在后台执行，使用单独的超时时间。
 		var handleFunc = func() (result string, err error) {
-			err = h.kickIfTimeout(user.InterviewID, user.UserID)
+			ctx, cancel := context.WithTimeout(tracing.Detach(ctx), taskTimeout)
+			defer cancel()
+			err = h.kickIfTimeout(ctx, xl, user.InterviewID, user.UserID)
 			if err == nil {
 				result = "success kick timeout user"
-				h.xl.Infof(result)
+				xl.Infof(result)
 			} else {
-				h.xl.Errorf("failed kick user %v err:%v", user.UserID, err)
+				xl.Errorf("failed kick user %v err:%v", user.UserID, err)
 			}
 			return
 		}
-		model.NewTask(user.ID, "user", "kickOut").Handle(handleFunc).Start(h.taskColl, h.xl)
+		model.NewTask(user.ID, "user", "kickOut").Handle(handleFunc).Start(h.taskColl, xl)
 	}
+	return nil
 }
 
 // kickIfTimeout kick and update interview_user table, should be atomic op
-func (h *HeartBeatCheckTask) kickIfTimeout(roomId, userId string) error {
-	err := h.rtc.KickUser(roomId, userId)
+func (h *HeartBeatCheckTask) kickIfTimeout(ctx context.Context, xl *xlog.Logger, roomId, userId string) error {
+	err := h.rtc.KickUser(ctx, xl, roomId, userId)
 	if err != nil {
 		// rtc踢人失败 但是也缺少了心跳 认为离开
-		h.xl.Errorf("err kick rtc user %v err:%v", userId, err)
+		xl.Errorf("err kick rtc user %v err:%v", userId, err)
 	}
-	err = h.interviewService.LeaveInterview(h.xl, userId, roomId)
+	err = h.interviewService.LeaveInterview(ctx, xl, userId, roomId)
 	if err != nil {
 		return err
 	}
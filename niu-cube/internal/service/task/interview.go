@@ -1,39 +1,42 @@
 package task
 
 import (
+	"context"
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/utils"
 	model "github.com/solutions/niu-cube/internal/protodef/model"
 	dao "github.com/solutions/niu-cube/internal/service/db/dao"
 	"time"
 
 	"github.com/qiniu/x/log"
-	"gopkg.in/mgo.v2"
+	"github.com/qiniu/x/xlog"
 	"gopkg.in/mgo.v2/bson"
 )
 
 type InterviewTask struct {
-	mongoClient   *mgo.Session
-	interviewColl *mgo.Collection
+	mongoClient   *mongodb.Manager
+	interviewColl *mongodb.Collection
 }
 
-func NewInterviewTask(mongoURI string, database string) (*InterviewTask, error) {
-	mongoClient, err := mgo.Dial(mongoURI + "/" + database)
+func NewInterviewTask(conf *utils.MongoConfig) (*InterviewTask, error) {
+	mongoClient, err := mongodb.Get(conf)
 	if err != nil {
 		log.Errorf("failed to create mongo client, error %v", err)
 		return nil, err
 	}
-	interviewColl := mongoClient.DB(database).C(dao.InterviewCollection)
+	interviewColl := mongoClient.C(dao.InterviewCollection)
 	return &InterviewTask{
 		mongoClient:   mongoClient,
 		interviewColl: interviewColl,
 	}, nil
 }
 
-func (c *InterviewTask) ListTaskInterviews(dataSize int) ([]model.InterviewDo, error) {
+func (c *InterviewTask) ListTaskInterviews(ctx context.Context, dataSize int) ([]model.InterviewDo, error) {
 	if dataSize <= 0 {
 		dataSize = 10
 	}
 	interviews := []model.InterviewDo{}
-	err := c.interviewColl.Find(bson.M{"$or": []bson.M{bson.M{"status": model.InterviewStatusCodeInit}, bson.M{"status": model.InterviewStatusCodeStart}}}).Sort("startTime").Limit(dataSize).All(&interviews)
+	err := c.interviewColl.WithContext(ctx).Find(bson.M{"$or": []bson.M{bson.M{"status": model.InterviewStatusCodeInit}, bson.M{"status": model.InterviewStatusCodeStart}}}).Sort("startTime").Limit(dataSize).All(&interviews)
 	if err != nil {
 		log.Errorf("failed to ListTaskInterviews , error %v", err)
 		return nil, err
@@ -41,8 +44,8 @@ func (c *InterviewTask) ListTaskInterviews(dataSize int) ([]model.InterviewDo, e
 	return interviews, err
 }
 
-func (c *InterviewTask) UpdateInterview(interview *model.InterviewDo) (*model.InterviewDo, error) {
-	err := c.interviewColl.Update(bson.M{"_id": interview.ID}, bson.M{"$set": interview})
+func (c *InterviewTask) UpdateInterview(ctx context.Context, interview *model.InterviewDo) (*model.InterviewDo, error) {
+	err := c.interviewColl.WithContext(ctx).Update(bson.M{"_id": interview.ID}, bson.M{"$set": interview})
 	if err != nil {
 		log.Errorf("failed to update interview %s,error %v", interview.ID, err)
 		return nil, err
@@ -50,27 +53,32 @@ func (c *InterviewTask) UpdateInterview(interview *model.InterviewDo) (*model.In
 	return interview, nil
 }
 
-func (t *InterviewTask) TaskForModifyInterviewStatus() {
-	log.Infof("taskForModifyInterviewStatus run at %s", time.Now().String())
+func (t *InterviewTask) TaskForModifyInterviewStatus(ctx context.Context, xl *xlog.Logger) error {
+	if xl == nil {
+		xl = xlog.New("interview task")
+	}
+	xl.Infof("taskForModifyInterviewStatus run at %s", time.Now().String())
 
-	interviews, err := t.ListTaskInterviews(10)
+	ctx, cancel := context.WithTimeout(ctx, taskTimeout)
+	defer cancel()
+	interviews, err := t.ListTaskInterviews(ctx, 10)
 	if err != nil {
-		log.Errorf("TaskForModifyInterviewStatus find interviews, error: %v", err)
-		return
+		xl.Errorf("TaskForModifyInterviewStatus find interviews, error: %v", err)
+		return err
 	}
 	if len(interviews) <= 0 {
-		log.Infof("taskForModifyInterviewStatus find no interviews")
+		xl.Infof("taskForModifyInterviewStatus find no interviews")
 	}
 	for _, interview := range interviews {
 		d, _ := time.ParseDuration("-24h")
 		if time.Now().Add(d).After(interview.CreateTime) {
-			log.Infof("TaskForModifyInterviewStatus modify status for interview %s, status: %d, startTime: %s", interview.ID, interview.Status, interview.StartTime)
+			xl.Infof("TaskForModifyInterviewStatus modify status for interview %s, status: %d, startTime: %s", interview.ID, interview.Status, interview.StartTime)
 			interview.Status = int(model.InterviewStatusCodeEnd)
-			_, err := t.UpdateInterview(&interview)
+			_, err := t.UpdateInterview(ctx, &interview)
 			if err != nil {
-				log.Errorf("TaskForModifyInterviewStatus modify err, %v", err)
+				xl.Errorf("TaskForModifyInterviewStatus modify err, %v", err)
 			}
 		}
 	}
-
+	return nil
 }
@@ -1,11 +1,13 @@
 package task
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/qiniu/x/xlog"
-	"gopkg.in/mgo.v2"
 
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/cloud"
@@ -15,9 +17,9 @@ import (
 type RecordTask struct {
 	Rtc           *cloud.RTCService
 	conf          utils.Config
-	interviewColl *mgo.Collection
-	taskColl      *mgo.Collection
-	client        *mgo.Session
+	interviewColl *mongodb.Collection
+	taskColl      *mongodb.Collection
+	client        *mongodb.Manager
 	xl            *xlog.Logger
 }
 
@@ -26,29 +28,38 @@ func NewRecordTask(conf utils.Config) *RecordTask {
 	n.Rtc = cloud.NewRtcService(conf)
 	n.xl = xlog.New("record task manager")
 	var err error
-	n.client, err = mgo.Dial(conf.Mongo.URI)
+	n.client, err = mongodb.Get(conf.Mongo)
 	if err != nil {
 		n.xl.Fatalf("error fetching service client err:%v", err)
 	}
-	n.interviewColl = n.client.DB(conf.Mongo.Database).C(dao.InterviewCollection)
-	n.taskColl = n.client.DB(conf.Mongo.Database).C(dao.TaskCollection)
+	n.interviewColl = n.client.C(dao.InterviewCollection)
+	n.taskColl = n.client.C(dao.TaskCollection)
 	n.conf = conf
 	return n
 }
 
 // Start 同步任务
 // 面试状态已结束 && 开启录制
-func (r RecordTask) Start() {
-	tasks, err := r.listTasks()
+func (r RecordTask) Start(ctx context.Context, xl *xlog.Logger) error {
+	if xl == nil {
+		xl = r.xl
+	}
+	ctx, cancel := context.WithTimeout(ctx, taskTimeout)
+	defer cancel()
+	tasks, err := r.listTasks(ctx, xl)
 	if err != nil {
-		r.xl.Errorf("error fetching task err:%v", err)
-		return
+		xl.Errorf("error fetching task err:%v", err)
+		return err
 	}
 	for _, interview := range tasks {
-		model.NewTask(interview.ID, "interview", "record").Handle(r.genHandleFunc(interview)).Start(r.taskColl, r.xl)
+		model.NewTask(interview.ID, "interview", "record").Handle(r.genHandleFunc(ctx, xl, interview)).Start(r.taskColl, xl)
 	}
+	return nil
 }
-func (r *RecordTask) genHandleFunc(interview model.InterviewDo) func() (string, error) {
+
+// genHandleFunc 返回在后台执行的录制任务，任务不随ctx取消，只沿用ctx中的span。
+func (r *RecordTask) genHandleFunc(ctx context.Context, xl *xlog.Logger, interview model.InterviewDo) func() (string, error) {
+	ctx = tracing.Detach(ctx)
 	return func() (string, error) {
 		var result string
 		var callback = func(resp map[string]string, ok bool) error {
@@ -60,12 +71,15 @@ func (r *RecordTask) genHandleFunc(interview model.InterviewDo) func() (string,
 			}
 			return err
 		}
-		err := r.Rtc.RecordPlayBackM3u8(r.streamName(interview.ID), 0, 0, callback)
+		err := r.Rtc.RecordPlayBackM3u8(ctx, xl, r.streamName(interview.ID), 0, 0, callback)
 		if err == nil {
+			// 录制任务在后台执行，使用单独的超时时间。
+			ctx, cancel := context.WithTimeout(ctx, taskTimeout)
+			defer cancel()
 			var newInterview model.InterviewDo
-			_ = r.interviewColl.FindId(interview.ID).One(&newInterview)
+			_ = r.interviewColl.WithContext(ctx).FindId(interview.ID).One(&newInterview)
 			newInterview.Recorded = true
-			_ = r.interviewColl.UpdateId(interview.ID, newInterview)
+			_ = r.interviewColl.WithContext(ctx).UpdateId(interview.ID, newInterview)
 			return result, err
 		} else {
 			return "", err
@@ -73,16 +87,16 @@ func (r *RecordTask) genHandleFunc(interview model.InterviewDo) func() (string,
 	}
 }
 
-func (r *RecordTask) listTasks() ([]model.InterviewDo, error) {
+func (r *RecordTask) listTasks(ctx context.Context, xl *xlog.Logger) ([]model.InterviewDo, error) {
 	condition := map[string]interface{}{
 		"status":   model.InterviewStatusCodeEnd,
 		"isRecord": true,
 		"recorded": false,
 	}
 	interviews := make([]model.InterviewDo, 0)
-	err := r.interviewColl.Find(condition).Limit(10).All(&interviews)
+	err := r.interviewColl.WithContext(ctx).Find(condition).Limit(10).All(&interviews)
 	if err != nil {
-		r.xl.Errorf("fetch interview list err:%v", err)
+		xl.Errorf("fetch interview list err:%v", err)
 		return interviews, err
 	}
 	return interviews, nil
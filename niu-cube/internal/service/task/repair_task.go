@@ -1,6 +1,7 @@
 package task
 
 import (
+	"context"
 	"github.com/qiniu/x/xlog"
 
 	"github.com/solutions/niu-cube/internal/common/utils"
@@ -25,21 +26,26 @@ func NewRepairTask(conf utils.Config) (*RepairTask, error) {
 	}, nil
 }
 
-func (h *RepairTask) Start() {
-
+func (h *RepairTask) Start(ctx context.Context, xl *xlog.Logger) error {
+	if xl == nil {
+		xl = h.xl
+	}
 	// 查看状态是正常的没有心跳的room_user
-	users, err := h.repair.ListHeartBeatTimeOutUser(h.xl)
+	ctx, cancel := context.WithTimeout(ctx, taskTimeout)
+	defer cancel()
+	users, err := h.repair.ListHeartBeatTimeOutUser(ctx, xl)
 	if err != nil {
-		h.xl.Errorf("error list heartbeat timeout user:%v", err)
-		return
+		xl.Errorf("error list heartbeat timeout user:%v", err)
+		return err
 	}
 	for _, user := range users {
-		leaveRoomErr := h.repair.LeaveRoom(h.xl, user.UserID, user.RoomId)
+		leaveRoomErr := h.repair.LeaveRoom(ctx, xl, user.UserID, user.RoomId)
 		if leaveRoomErr != nil {
-			h.xl.Errorf("failed LeaveRoom  userId:%s,roomId:%s, err:%v", user.UserID, user.RoomId, leaveRoomErr)
+			xl.Errorf("failed LeaveRoom  userId:%s,roomId:%s, err:%v", user.UserID, user.RoomId, leaveRoomErr)
 		} else {
-			h.xl.Infof("RepairTask success LeaveRoom  userId:%v,roomId:%v ", user.UserID, user.RoomId)
+			xl.Infof("RepairTask success LeaveRoom  userId:%v,roomId:%v ", user.UserID, user.RoomId)
 		}
 
 	}
+	return nil
 }
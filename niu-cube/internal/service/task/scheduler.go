@@ -0,0 +1,101 @@
+package task
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jasonlvhit/gocron"
+	"github.com/qiniu/x/xlog"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/solutions/niu-cube/internal/common/metrics"
+	"github.com/solutions/niu-cube/internal/common/tracing"
+	"github.com/solutions/niu-cube/internal/common/utils"
+)
+
+// taskTimeout 任务每次执行中数据库操作的超时时间，数据库无响应时不会一直占用调度器。
+const taskTimeout = 30 * time.Second
+
+// Scheduler 定时任务调度器，停止时不再触发新的执行，并等待执行中的任务结束。
+type Scheduler struct {
+	scheduler *gocron.Scheduler
+	stopped   chan bool
+
+	lock     sync.Mutex
+	wg       sync.WaitGroup
+	stopping bool
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{scheduler: gocron.NewScheduler()}
+}
+
+// Every 添加每隔interval个时间单位执行一次的任务，时间单位与任务通过返回值的Seconds()、Do()等方法设置。
+func (s *Scheduler) Every(interval uint64) *gocron.Job {
+	return s.scheduler.Every(interval)
+}
+
+// Do 为job设置名为name的任务，执行耗时与失败次数计入监控指标。
+// 每次执行使用新的请求ID与logger，并创建名为"task <name>"的span，任务中以传入的ctx进行的调用都是其子span。
+func (s *Scheduler) Do(job *gocron.Job, name string, task func(ctx context.Context, xl *xlog.Logger) error) error {
+	return job.Do(func() {
+		if !s.acquire() {
+			return
+		}
+		defer s.wg.Done()
+		xl := xlog.New(utils.NewReqID())
+		ctx := tracing.ContextWithRequestID(context.Background(), xl.ReqId)
+		ctx, span := tracing.Tracer().Start(ctx, "task "+name, trace.WithAttributes(tracing.RequestIDAttribute(xl.ReqId)))
+		metrics.Task(name, func() (err error) {
+			defer func() { tracing.End(span, err) }()
+			return task(ctx, xl)
+		})()
+	})
+}
+
+func (s *Scheduler) acquire() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.stopping {
+		return false
+	}
+	s.wg.Add(1)
+	return true
+}
+
+// Start 开始调度，Stop之后调用时不再开始调度。
+func (s *Scheduler) Start() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.stopping || s.stopped != nil {
+		return
+	}
+	s.stopped = s.scheduler.Start()
+}
+
+// Stop 停止调度并等待执行中的任务结束，ctx结束时不再等待并返回ctx的错误。
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.lock.Lock()
+	if s.stopping {
+		s.lock.Unlock()
+		return nil
+	}
+	s.stopping = true
+	stopped := s.stopped
+	s.lock.Unlock()
+	if stopped != nil {
+		stopped <- true
+	}
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
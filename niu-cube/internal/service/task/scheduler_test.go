@@ -0,0 +1,143 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qiniu/x/reqid"
+	"github.com/qiniu/x/xlog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSchedulerStop(t *testing.T) {
+	s := NewScheduler()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var finished int32
+	err := s.Do(s.Every(1).Hours(), "test_stop", func(ctx context.Context, xl *xlog.Logger) error {
+		close(started)
+		<-release
+		atomic.StoreInt32(&finished, 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("add task: %v", err)
+	}
+	s.Start()
+	s.scheduler.RunAll()
+	<-started
+
+	// 执行中的任务超过等待时间时，Stop返回ctx的错误。
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Stop(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Stop() = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// 停止后不再执行新的任务。
+	var runs int32
+	err = s.Do(s.Every(1).Hours(), "test_after_stop", func(ctx context.Context, xl *xlog.Logger) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("add task: %v", err)
+	}
+	s.scheduler.RunAll()
+
+	close(release)
+	s.wg.Wait()
+	if atomic.LoadInt32(&finished) != 1 {
+		t.Fatalf("running task not finished")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&runs); n != 0 {
+		t.Fatalf("task run %d times after stop", n)
+	}
+}
+
+func TestSchedulerStopWaitsForTasks(t *testing.T) {
+	s := NewScheduler()
+	started := make(chan struct{})
+	var finished int32
+	err := s.Do(s.Every(1).Hours(), "test_wait", func(ctx context.Context, xl *xlog.Logger) error {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		atomic.StoreInt32(&finished, 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("add task: %v", err)
+	}
+	s.Start()
+	s.scheduler.RunAll()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("Stop() = %v", err)
+	}
+	if atomic.LoadInt32(&finished) != 1 {
+		t.Fatalf("Stop returned before running task finished")
+	}
+}
+
+func TestSchedulerStopBeforeStart(t *testing.T) {
+	s := NewScheduler()
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() = %v", err)
+	}
+	// 先收到停止信号时，之后的Start不再开始调度。
+	s.Start()
+	if s.stopped != nil {
+		t.Fatalf("scheduler started after stop")
+	}
+}
+
+func TestSchedulerTaskLogger(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	s := NewScheduler()
+	loggers := make(chan *xlog.Logger, 2)
+	err := s.Do(s.Every(1).Hours(), "test_logger", func(ctx context.Context, xl *xlog.Logger) error {
+		// 任务的ctx中带有任务的span与请求ID。
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			t.Errorf("task %s ctx has no span", xl.ReqId)
+		}
+		if id, ok := reqid.FromContext(ctx); !ok || id != xl.ReqId {
+			t.Errorf("request id in ctx = %q, want %q", id, xl.ReqId)
+		}
+		loggers <- xl
+		return errors.New("failed")
+	})
+	if err != nil {
+		t.Fatalf("add task: %v", err)
+	}
+	s.scheduler.RunAll()
+	first := <-loggers
+	s.scheduler.RunAll()
+	second := <-loggers
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("Stop() = %v", err)
+	}
+
+	if first.ReqId == "" || first.ReqId == second.ReqId {
+		t.Errorf("request ids = %q, %q, want a new id for each run", first.ReqId, second.ReqId)
+	}
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[0].Name() != "task test_logger" || spans[0].Status().Code != codes.Error {
+		t.Fatalf("spans = %v", spans)
+	}
+}
@@ -15,10 +15,14 @@
 package web
 
 import (
+	"context"
 	"github.com/solutions/niu-cube/internal/service/dao"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/solutions/niu-cube/internal/common/i18n"
+	"github.com/solutions/niu-cube/internal/common/metrics"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/cloud"
@@ -30,6 +34,46 @@ import (
 	"github.com/qiniu/x/xlog"
 )
 
+// healthApiHandler 全局唯一，停止服务时通过Drain使就绪检查失败。
+var healthApiHandler = &handler.HealthApiHandler{}
+
+// Drain 标记服务正在停止，/readyz之后返回503。
+func Drain() {
+	healthApiHandler.Drain()
+}
+
+// auditLogService NewRouter创建的审计日志服务，停止服务时通过Close写入缓冲区中的记录。
+var auditLogService *db.AuditLogService
+
+// Close 在HTTP服务停止后调用，等待缓冲区中的审计日志写入数据库。
+func Close(ctx context.Context) error {
+	if auditLogService == nil {
+		return nil
+	}
+	return auditLogService.Close(ctx)
+}
+
+// newHealthCheckers 返回就绪检查的依赖：主数据库、七牛IM数据库，以及RTC、IM、短信的配置。
+func newHealthCheckers(config *utils.Config) ([]handler.HealthChecker, error) {
+	mongoChecker, err := db.NewMongoHealthChecker("mongo", *config.Mongo, nil)
+	if err != nil {
+		return nil, err
+	}
+	checkers := []handler.HealthChecker{mongoChecker}
+	if config.IM != nil && config.IM.Provider == "qiniu" && config.IM.Qiniu != nil && config.IM.Qiniu.Mongo != nil {
+		imMongoChecker, err := db.NewMongoHealthChecker("im_mongo", *config.IM.Qiniu.Mongo, nil)
+		if err != nil {
+			return nil, err
+		}
+		checkers = append(checkers, imMongoChecker)
+	}
+	return append(checkers,
+		handler.NewHealthCheck("rtc_config", func() error { return handler.CheckRTCConfig(config) }),
+		handler.NewHealthCheck("im_config", func() error { return handler.CheckIMConfig(config) }),
+		handler.NewHealthCheck("sms_config", func() error { return handler.CheckSMSConfig(config) }),
+	), nil
+}
+
 // NewRouter @title 互动直播API
 // @version 0.0.1
 // @description  http apis
@@ -38,9 +82,31 @@ import (
 func NewRouter(config *utils.Config) (*gin.Engine, error) {
 	// 1. 初始化GIN
 	router := gin.New()
-	router.Use(gin.Recovery())
+	err := middleware.SetTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	router.TrustedProxies = config.TrustedProxies
+	if len(router.TrustedProxies) == 0 {
+		router.TrustedProxies = middleware.DefaultTrustedProxies
+	}
+	err = middleware.SetRateLimit(config.RateLimit, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = i18n.SetDefault(config.DefaultLanguage)
+	if err != nil {
+		return nil, err
+	}
+	router.Use(gin.Recovery(), middleware.Metrics)
+	// Prometheus监控指标，服务只监听本机地址，由采集端经反向代理或本机访问。
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
 	// 1.1. 全局CORS配置
-	router.Use(corsMiddleware())
+	corsMiddleware, err := middleware.NewCORS(config.CORS)
+	if err != nil {
+		return nil, err
+	}
+	router.Use(corsMiddleware)
 
 	// 2. 声明Service
 	// 2.1 全局配置Service
@@ -51,7 +117,16 @@ func NewRouter(config *utils.Config) (*gin.Engine, error) {
 	appConfigApiHandler := &handler.AppConfigApiHandler{}
 
 	// 2.2 账号Service
-	smsCodeService, err := cloud.NewSmsCodeService(config.Mongo.URI, config.Mongo.Database, config, nil)
+	smsCodeService, err := cloud.NewSmsCodeService(config.Mongo, config, nil)
+	if err != nil {
+		return nil, err
+	}
+	captchaVerifier, err := cloud.NewCaptchaVerifier(config.SMS.Captcha)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordLimiter, err := cloud.NewPasswordLimiter(config.Mongo, config, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -60,6 +135,14 @@ func NewRouter(config *utils.Config) (*gin.Engine, error) {
 	if err != nil {
 		return nil, err
 	}
+	var imMongo *utils.MongoConfig
+	if config.IM.Provider == "qiniu" && config.IM.Qiniu != nil {
+		imMongo = config.IM.Qiniu.Mongo
+	}
+	accountDataService, err := db.NewAccountDataService(*config.Mongo, imMongo, accountService, nil)
+	if err != nil {
+		return nil, err
+	}
 	baseUserDao, err := dao.NewBaseUserDaoService(nil, config.Mongo)
 	if err != nil {
 		return nil, err
@@ -93,234 +176,328 @@ func NewRouter(config *utils.Config) (*gin.Engine, error) {
 	exam.RunOnstart()
 
 	accountApiHandler := &handler.AccountApiHandler{
-		Account:           accountService,
-		SmsCode:           smsCodeService,
-		AppConfigService:  appConfigService,
-		DefaultAvatarURLs: config.DefaultAvatars,
-		BaseUserDao:       baseUserDao,
-		ExamService:       exam,
+		Account:          accountService,
+		SmsCode:          smsCodeService,
+		AppConfigService: appConfigService,
+		BaseUserDao:      baseUserDao,
+		ExamService:      exam,
+		Mail:             cloud.NewMailSender(config.Mail),
+		FrontendUrlHost:  config.FrontendUrlHost,
+		AccountData:      accountDataService,
+		PasswordLimit:    passwordLimiter,
+	}
+	if config.Weixin.LoginEnabled {
+		accountApiHandler.Weixin = cloud.NewWeixinAuthClient(config.Weixin)
+		accountApiHandler.WeixinAppID = config.Weixin.AppID
+	}
+	// 未开启人机验证时保持接口值为nil。
+	if captchaVerifier != nil {
+		accountApiHandler.Captcha = captchaVerifier
 	}
 
-	middleware.InitMiddleware(*config)
-
-	// 4. 配置V1路径
-	v1 := router.Group("/v1", addApiVersion(model.ApiVersionV1), addRequestID, middleware.FetchPageInfo, middleware.ActionLogMiddleware())
-	{
-		// 3.1 通用|获取APP全局配置
-		v1.GET("appConfig", appConfigApiHandler.GetAppConfig)
-		v1.GET("appConfig/", appConfigApiHandler.GetAppConfig)
-		// TODO： 增加鉴权
-		v1.GET("token/kodo", appConfigApiHandler.KodoToken)
-		v1.GET("token/kodo/", appConfigApiHandler.KodoToken)
-		// 3.2 发送验证码
-		v1.POST("getSmsCode", accountApiHandler.SendSmsCode)
-		v1.POST("getSmsCode/", accountApiHandler.SendSmsCode)
-		// 3.3 登录/注册
-		v1.POST("signUpOrIn", accountApiHandler.SignUpOrIn)
-		v1.POST("signUpOrIn/", accountApiHandler.SignUpOrIn)
-
-		v1.POST("token/getToken", appConfigApiHandler.GetToken)
-
-		v1.GET("test/:interviewId", interviewApiHandler.InterviewUrlFromId)
-
-		// 3.4 文件上传下载相关
-		v1.POST("upload", fileApiHandler.Upload)
-		v1.GET("recentImage", fileApiHandler.RecentImage)
-
-		v1.GET("exam/roomToken", exam.RoomToken)
-
-		v1.GET("exam/aiToken", exam.AiToken)
-
-		v1.GET("/pandora/token", exam.PandoraToken)
-
+	roleApiHandler := handler.NewRoleApiHandler(accountService)
+	apiKeyService, err := db.NewApiKeyService(*config.Mongo, nil)
+	if err != nil {
+		return nil, err
 	}
-	baseAuth := v1.Group("", middleware.Authenticate)
-	{
-		// 3.3 登录/注册
-		baseAuth.POST("signInWithToken", accountApiHandler.SignInWithToken)
-		baseAuth.POST("signInWithToken/", accountApiHandler.SignInWithToken)
-
-		// 3.4 登出
-		baseAuth.POST("signOut", accountApiHandler.SignOut)
-		baseAuth.POST("signOut/", accountApiHandler.SignOut)
-		// 3.5 场景列表
-		baseAuth.GET("solution", appConfigApiHandler.SolutionList)
-		baseAuth.GET("solution/", appConfigApiHandler.SolutionList)
-		// 3.6 用户信息获取
-		baseAuth.GET("accountInfo", accountApiHandler.GetAccountInfo)
-		baseAuth.GET("accountInfo/", accountApiHandler.GetAccountInfo)
-		baseAuth.GET("accountInfo/:accountId", accountApiHandler.GetAccountInfo)
-		// 3.7 用户信息更新
-		baseAuth.POST("accountInfo", accountApiHandler.UpdateAccountInfo)
-		baseAuth.POST("accountInfo/", accountApiHandler.UpdateAccountInfo)
-		baseAuth.POST("accountInfo/:accountId", accountApiHandler.UpdateAccountInfo)
-		baseAuth.DELETE("account/delete/:phone", accountApiHandler.DeleteAccount)
-		baseAuth.GET("account/sync", accountApiHandler.Sync)
-
-		// 3.8 面试场景-面试列表
-		baseAuth.GET("interview", interviewApiHandler.ListAllInterviews)
-		baseAuth.GET("interview/", interviewApiHandler.ListAllInterviews)
-
-		// 3.10 面试场景-取消面试
-		baseAuth.POST("cancelInterview/:interviewId", interviewApiHandler.CancelInterview)
-
-		// 3.14 面试场景-创建面试
-		baseAuth.POST("interview", interviewApiHandler.CreatInterview)
-		baseAuth.POST("interview/", interviewApiHandler.CreatInterview)
-		// 3.15 面试场景-修改面试
-		baseAuth.POST("interview/:interviewId", interviewApiHandler.UpdateInterview)
-
-		// 4.1 检修场景-创建房间
-		baseAuth.POST("repair/createRoom", repairApiHandler.CreateRoom)
-		// 4.2 检修场景-加入房间
-		baseAuth.POST("repair/joinRoom", repairApiHandler.JoinRoom)
-		// 4.3 检修场景-离开房间
-		baseAuth.GET("repair/leaveRoom/:roomId", repairApiHandler.LeaveRoom)
-		// 4.4 检修场景-房间列表
-		baseAuth.GET("repair/listRoom/", repairApiHandler.ListRoom)
-		baseAuth.GET("repair/listRoom", repairApiHandler.ListRoom)
-		baseAuth.POST("repair/listRoom/", repairApiHandler.ListRoom)
-		baseAuth.POST("repair/listRoom", repairApiHandler.ListRoom)
-		// 4.5 检修场景-心跳接口
-		baseAuth.GET("repair/heartBeat/:roomId", repairApiHandler.HeartBeat)
-
-		// 4.6 检修场景-获取房间信息
-		baseAuth.GET("repair/getRoomInfo/:roomId", repairApiHandler.GetRoomInfo)
-
-		// 通用创建房间
-		baseAuth.POST("base/createRoom", baseRoom.CreateRoom)
-		// 通用加入房间
-		baseAuth.POST("base/joinRoom", baseRoom.JoinRoom)
-		// 通用离开房间
-		baseAuth.POST("base/leaveRoom", baseRoom.LeaveRoom)
-		// 通用列举房间
-		baseAuth.GET("base/listRoom", baseRoom.ListRooms)
-		// 通用心跳保活
-		baseAuth.GET("base/heartBeat", baseUser.Heartbeat)
-		// 更新用户信息
-		baseAuth.POST("/base/userInfo", baseUser.UpdateUserInfo)
-		// 通用房间信息
-		baseAuth.GET("base/getRoomInfo", baseRoom.RoomInfo)
-		baseAuth.DELETE("base/room/groupChat/truncate", baseRoom.TruncateGroupChat)
-		// 通用上麦接口
-		baseAuth.POST("base/upMic", baseMic.UpMic)
-		// 通用更新房间
-		baseAuth.POST("base/updateRoomAttr", baseRoom.UpdateRoomInfo)
-		// 通用更新麦位
-		baseAuth.POST("base/updateMicAttr", baseMic.UpdateMicAttrs)
-		// 通用下麦接口
-		baseAuth.POST("base/downMic", baseMic.DownMic)
-		// 通用房间麦位扩展信息
-		baseAuth.GET("base/getRoomMicInfo", baseMic.MicInfo)
-		// 通用房间属性
-		baseAuth.GET("base/getRoomAttr", baseRoom.RoomInfoAttr)
-		// 通用麦位属性
-		baseAuth.GET("base/getMicAttr", baseMic.MicAttrs)
-
-		baseAuth.GET("listUser/:roomId", baseRoom.ListUser)
-
-		// 歌曲列表
-		baseAuth.POST("ktv/songList", ktv.ListSong)
-		// 当前用户已选歌曲
-		baseAuth.POST("ktv/selectedSongList", ktv.SongDemanded)
-		// 点歌/取消点歌
-		baseAuth.POST("ktv/operateSong", ktv.SongOperation)
-		// 歌曲信息
-		baseAuth.POST("ktv/songInfo", ktv.SongInfo)
-		// 添加歌曲
-		baseAuth.POST("ktv/addSongs", ktv.AddSongs)
-		// 更新歌曲
-		baseAuth.POST("ktv/updateSong", ktv.UpdateSong)
-		// 删除歌曲
-		baseAuth.POST("ktv/deleteSong", ktv.DeleteSong)
-		// 列举所有歌曲
-		baseAuth.GET("ktv/listSongs", ktv.ListAllSong)
-
-		// 在线看电影相关
-		baseAuth.GET("watchMoviesTogether/movieList", movie.ListMovie)
-		baseAuth.GET("watchMoviesTogether/selectedMovieList", movie.MovieDemanded)
-		baseAuth.POST("watchMoviesTogether/movieOperation", movie.MovieOperation)
-		baseAuth.GET("watchMoviesTogether/movieInfo", movie.MovieInfo)
-		baseAuth.POST("watchMoviesTogether/switchMovie", movie.MovieSwitch)
-		baseAuth.POST("movie/addMovies", movie.AddMovies)
-		baseAuth.POST("movie/updateMovies", movie.UpdateMovie)
-		baseAuth.POST("movie/deleteMovies", movie.DeleteMovie)
-		baseAuth.GET("movie/listMovies", movie.ListAllMovie)
-
-		// 在线考试相关
-		baseAuth.POST("exam/create", exam.CreateExam)
-		baseAuth.POST("exam/update", exam.UpdateExam)
-		baseAuth.POST("exam/delete", exam.DeleteExam)
-		baseAuth.POST("exam/join", exam.JoinExam)
-		baseAuth.POST("exam/leave", exam.LeaveExam)
-		baseAuth.GET("exam/info/:examId", exam.GetExamInfo)
-		baseAuth.GET("exam/examinees/:examId", exam.GetExamExaminees)
-		baseAuth.GET("exam/paper/:examId", exam.GetExamPaper)
-		baseAuth.POST("exam/answer", exam.CommitExamAnswer)
-		baseAuth.GET("exam/answer/details/:examId/*userId", exam.GetExamAnswerDetails)
-		baseAuth.GET("exam/list/student", exam.ListExamStudent)
-		baseAuth.GET("exam/list/teacher", exam.ListExamTeacher)
-		baseAuth.GET("exam/questionList/*type", exam.QuestionList)
-		baseAuth.POST("exam/questions/add", exam.AddQuestion)
-		baseAuth.POST("exam/questions/update", exam.UpdateQuestion)
-		baseAuth.POST("exam/question/delete", exam.DeleteQuestion)
-		baseAuth.POST("exam/eventLog", exam.UploadCheatingEvent)
-		baseAuth.POST("exam/eventLog/more", exam.MoreCheatingEvent)
-		baseAuth.GET("exam/clear", exam.Clear)
-		baseAuth.GET("exam/sync/:phone")
+	apiKeyApiHandler := handler.NewApiKeyApiHandler(apiKeyService)
+	auditLogService, err = db.NewAuditLogService(*config.Mongo, config.AuditLog, nil)
+	if err != nil {
+		return nil, err
 	}
-	// 无状态登录
-	stateLessAuth := v1.Use(middleware.AfapAuthenticate)
-	{
+	if config.AuditLog == nil || !config.AuditLog.Disabled {
+		middleware.SetAuditLogWriter(auditLogService)
+	}
+	auditLogApiHandler := handler.NewAuditLogApiHandler(auditLogService)
 
-		// 3.9 面试场景-结束面试
-		stateLessAuth.POST("endInterview/:interviewId", interviewApiHandler.EndInterview)
-		// 3.11 面试场景-进入面试
-		stateLessAuth.POST("joinInterview/:interviewId", interviewApiHandler.JoinInterview)
-		// 3.12 面试场景-离开面试
-		stateLessAuth.POST("leaveInterview/:interviewId", interviewApiHandler.LeaveInterview)
-		// 3.13 面试场景-心跳
-		stateLessAuth.GET("heartBeat/:interviewId", interviewApiHandler.HeartBeat)
-		// 3.16 面试场景-面试详情
-		stateLessAuth.GET("interview/:interviewId", interviewApiHandler.GetInterview)
+	middleware.InitMiddleware(*config)
 
-	}
+	// 4. 注册接口，V1与V2使用相同的处理函数，V2的响应由middleware.APIV2转换为真实的HTTP状态码与统一的返回格式
+	registerAPI := func(api *gin.RouterGroup, apiVersion model.ApiVersion) {
+		{
+			// 3.1 通用|获取APP全局配置
+			api.GET("appConfig", appConfigApiHandler.GetAppConfig)
+			api.GET("appConfig/", appConfigApiHandler.GetAppConfig)
+			// TODO： 增加鉴权
+			api.GET("token/kodo", appConfigApiHandler.KodoToken)
+			api.GET("token/kodo/", appConfigApiHandler.KodoToken)
+			// 3.2 发送验证码，开启人机验证时需先获取题目
+			api.GET("captcha", accountApiHandler.GetCaptcha)
+			api.POST("getSmsCode", middleware.RateLimit(middleware.RateLimitGroupSmsCode), accountApiHandler.SendSmsCode)
+			api.POST("getSmsCode/", middleware.RateLimit(middleware.RateLimitGroupSmsCode), accountApiHandler.SendSmsCode)
+			// 3.3 登录/注册
+			api.POST("signUpOrIn", accountApiHandler.SignUpOrIn)
+			api.POST("signUpOrIn/", accountApiHandler.SignUpOrIn)
+
+			// 3.3 邮箱与密码注册/登录，未开启时不注册相关接口
+			if config.Password != nil && config.Password.Enabled {
+				api.POST("signUpByEmail", accountApiHandler.SignUpByEmail)
+				api.POST("signInByPassword", accountApiHandler.SignInByPassword)
+				api.POST("email/verify", accountApiHandler.VerifyEmail)
+				api.POST("email/resendVerify", accountApiHandler.ResendVerifyEmail)
+				api.POST("password/forgot", accountApiHandler.ForgotPassword)
+				api.POST("password/reset", accountApiHandler.ResetPassword)
+			}
+			// 3.3 小程序登录，未开启时不注册相关接口
+			if config.Weixin.LoginEnabled {
+				api.POST("signInWithWeixin", accountApiHandler.SignInWithWeixin)
+			}
+
+			api.POST("token/getToken", appConfigApiHandler.GetToken)
+			// 3.3 刷新登录token
+			api.POST("token/refresh", accountApiHandler.RefreshToken)
+			api.POST("token/refresh/", accountApiHandler.RefreshToken)
+
+			// 3.4 文件上传下载相关
+			api.POST("upload", fileApiHandler.Upload)
+			api.GET("recentImage", fileApiHandler.RecentImage)
+
+			api.GET("exam/roomToken", exam.RoomToken)
+
+			api.GET("exam/aiToken", exam.AiToken)
+
+			api.GET("/pandora/token", exam.PandoraToken)
 
-	version := v1.Group("", middleware.Authenticate, middleware.VersionGate())
-	{
-		version.GET("version", versionApiHandler.GetOrListVersion)
-		version.GET("version/", versionApiHandler.GetOrListVersion)
+		}
+		baseAuth := api.Group("", middleware.Authenticate)
+		{
+			// 3.3 登录/注册
+			baseAuth.POST("signInWithToken", accountApiHandler.SignInWithToken)
+			baseAuth.POST("signInWithToken/", accountApiHandler.SignInWithToken)
+
+			// 3.4 登出
+			baseAuth.POST("signOut", accountApiHandler.SignOut)
+			baseAuth.POST("signOut/", accountApiHandler.SignOut)
+			// 3.4 登录会话（设备）管理
+			baseAuth.GET("sessions", accountApiHandler.ListSessions)
+			baseAuth.DELETE("sessions", accountApiHandler.RevokeAllSessions)
+			baseAuth.DELETE("sessions/:sessionId", accountApiHandler.RevokeSession)
+			if config.Password != nil && config.Password.Enabled {
+				// 3.4 设置/修改密码
+				baseAuth.POST("password", accountApiHandler.UpdatePassword)
+			}
+			// 3.5 场景列表，V2的场景列表不需要登录，在下方单独注册
+			if apiVersion == model.ApiVersionV1 {
+				baseAuth.GET("solution", appConfigApiHandler.SolutionList)
+				baseAuth.GET("solution/", appConfigApiHandler.SolutionList)
+			}
+			// 3.6 用户信息获取
+			baseAuth.GET("accountInfo", accountApiHandler.GetAccountInfo)
+			baseAuth.GET("accountInfo/", accountApiHandler.GetAccountInfo)
+			baseAuth.GET("accountInfo/:accountId", accountApiHandler.GetAccountInfo)
+			// 3.7 用户信息更新
+			baseAuth.POST("accountInfo", accountApiHandler.UpdateAccountInfo)
+			baseAuth.POST("accountInfo/", accountApiHandler.UpdateAccountInfo)
+			baseAuth.POST("accountInfo/:accountId", accountApiHandler.UpdateAccountInfo)
+			// 3.7 注销账号、导出个人数据
+			baseAuth.DELETE("account", accountApiHandler.DeleteMyAccount)
+			baseAuth.GET("account/export", accountApiHandler.ExportAccountData)
+			baseAuth.DELETE("account/delete/:phone", middleware.RequirePermission(model.PermissionAccountManage), accountApiHandler.DeleteAccount)
+			baseAuth.GET("account/deletion/:accountId", middleware.RequirePermission(model.PermissionAccountManage), accountApiHandler.GetAccountDeletion)
+
+			// 3.8 面试场景-面试列表
+			baseAuth.GET("interview", interviewApiHandler.ListAllInterviews)
+			baseAuth.GET("interview/", interviewApiHandler.ListAllInterviews)
+
+			// 3.10 面试场景-取消面试
+			baseAuth.POST("cancelInterview/:interviewId", interviewApiHandler.CancelInterview)
+
+			// 3.14 面试场景-创建面试
+			baseAuth.POST("interview", interviewApiHandler.CreatInterview)
+			baseAuth.POST("interview/", interviewApiHandler.CreatInterview)
+			// 3.15 面试场景-修改面试
+			baseAuth.POST("interview/:interviewId", interviewApiHandler.UpdateInterview)
+			// 3.17 面试场景-获取面试入口链接
+			baseAuth.GET("test/:interviewId", interviewApiHandler.InterviewUrlFromId)
+
+			// 4.1 检修场景-创建房间
+			baseAuth.POST("repair/createRoom", repairApiHandler.CreateRoom)
+			// 4.2 检修场景-加入房间
+			baseAuth.POST("repair/joinRoom", repairApiHandler.JoinRoom)
+			// 4.3 检修场景-离开房间
+			baseAuth.GET("repair/leaveRoom/:roomId", repairApiHandler.LeaveRoom)
+			// 4.4 检修场景-房间列表
+			baseAuth.GET("repair/listRoom/", repairApiHandler.ListRoom)
+			baseAuth.GET("repair/listRoom", repairApiHandler.ListRoom)
+			baseAuth.POST("repair/listRoom/", repairApiHandler.ListRoom)
+			baseAuth.POST("repair/listRoom", repairApiHandler.ListRoom)
+			// 4.5 检修场景-心跳接口
+			baseAuth.GET("repair/heartBeat/:roomId", middleware.RateLimit(middleware.RateLimitGroupHeartbeat), repairApiHandler.HeartBeat)
+
+			// 4.6 检修场景-获取房间信息
+			baseAuth.GET("repair/getRoomInfo/:roomId", repairApiHandler.GetRoomInfo)
+
+			// 通用创建房间
+			baseAuth.POST("base/createRoom", baseRoom.CreateRoom)
+			// 通用加入房间
+			baseAuth.POST("base/joinRoom", baseRoom.JoinRoom)
+			// 通用离开房间
+			baseAuth.POST("base/leaveRoom", baseRoom.LeaveRoom)
+			// 通用列举房间
+			baseAuth.GET("base/listRoom", baseRoom.ListRooms)
+			// 通用心跳保活
+			baseAuth.GET("base/heartBeat", middleware.RateLimit(middleware.RateLimitGroupHeartbeat), baseUser.Heartbeat)
+			// 更新用户信息
+			baseAuth.POST("/base/userInfo", baseUser.UpdateUserInfo)
+			// 通用房间信息
+			baseAuth.GET("base/getRoomInfo", baseRoom.RoomInfo)
+			baseAuth.DELETE("base/room/groupChat/truncate", baseRoom.TruncateGroupChat)
+			// 通用上麦接口
+			baseAuth.POST("base/upMic", baseMic.UpMic)
+			// 通用更新房间
+			baseAuth.POST("base/updateRoomAttr", baseRoom.UpdateRoomInfo)
+			// 通用更新麦位
+			baseAuth.POST("base/updateMicAttr", baseMic.UpdateMicAttrs)
+			// 通用下麦接口
+			baseAuth.POST("base/downMic", baseMic.DownMic)
+			// 通用房间麦位扩展信息
+			baseAuth.GET("base/getRoomMicInfo", baseMic.MicInfo)
+			// 通用房间属性
+			baseAuth.GET("base/getRoomAttr", baseRoom.RoomInfoAttr)
+			// 通用麦位属性
+			baseAuth.GET("base/getMicAttr", baseMic.MicAttrs)
+
+			baseAuth.GET("listUser/:roomId", baseRoom.ListUser)
+
+			// 歌曲列表
+			baseAuth.POST("ktv/songList", ktv.ListSong)
+			// 当前用户已选歌曲
+			baseAuth.POST("ktv/selectedSongList", ktv.SongDemanded)
+			// 点歌/取消点歌
+			baseAuth.POST("ktv/operateSong", middleware.RateLimit(middleware.RateLimitGroupSongOperation), ktv.SongOperation)
+			// 歌曲信息
+			baseAuth.POST("ktv/songInfo", ktv.SongInfo)
+			// 列举所有歌曲
+			baseAuth.GET("ktv/listSongs", ktv.ListAllSong)
+
+			// 在线看电影相关
+			baseAuth.GET("watchMoviesTogether/movieList", movie.ListMovie)
+			baseAuth.GET("watchMoviesTogether/selectedMovieList", movie.MovieDemanded)
+			baseAuth.POST("watchMoviesTogether/movieOperation", movie.MovieOperation)
+			baseAuth.GET("watchMoviesTogether/movieInfo", movie.MovieInfo)
+			baseAuth.POST("watchMoviesTogether/switchMovie", movie.MovieSwitch)
+			baseAuth.GET("movie/listMovies", movie.ListAllMovie)
+
+			// 在线考试相关
+			baseAuth.POST("exam/join", exam.JoinExam)
+			baseAuth.POST("exam/leave", exam.LeaveExam)
+			baseAuth.GET("exam/info/:examId", exam.GetExamInfo)
+			baseAuth.GET("exam/examinees/:examId", middleware.RequirePermission(model.PermissionExamReview), exam.GetExamExaminees)
+			baseAuth.GET("exam/paper/:examId", exam.GetExamPaper)
+			baseAuth.POST("exam/answer", exam.CommitExamAnswer)
+			baseAuth.GET("exam/answer/details/:examId/*userId", exam.GetExamAnswerDetails)
+			baseAuth.GET("exam/list/student", exam.ListExamStudent)
+			baseAuth.GET("exam/list/teacher", middleware.RequirePermission(model.PermissionExamReview), exam.ListExamTeacher)
+			baseAuth.GET("exam/questionList/*type", exam.QuestionList)
+			baseAuth.POST("exam/eventLog", middleware.RateLimit(middleware.RateLimitGroupEventLog), exam.UploadCheatingEvent)
+			baseAuth.POST("exam/eventLog/more", middleware.RequirePermission(model.PermissionExamReview), exam.MoreCheatingEvent)
+			baseAuth.GET("exam/clear", middleware.RequirePermission(model.PermissionSystemMaintain), exam.Clear)
+		}
+		// 内容管理：除登录账号外，也接受授予了对应权限的API key，供服务端脚本导入歌曲、电影与考试题目
+		contentManage := api.Group("")
+		{
+			// 添加歌曲
+			contentManage.POST("ktv/addSongs", middleware.AllowApiKey(model.PermissionSongManage), ktv.AddSongs)
+			// 更新歌曲
+			contentManage.POST("ktv/updateSong", middleware.AllowApiKey(model.PermissionSongManage), ktv.UpdateSong)
+			// 删除歌曲
+			contentManage.POST("ktv/deleteSong", middleware.AllowApiKey(model.PermissionSongManage), ktv.DeleteSong)
+
+			contentManage.POST("movie/addMovies", middleware.AllowApiKey(model.PermissionMovieManage), movie.AddMovies)
+			contentManage.POST("movie/updateMovies", middleware.AllowApiKey(model.PermissionMovieManage), movie.UpdateMovie)
+			contentManage.POST("movie/deleteMovies", middleware.AllowApiKey(model.PermissionMovieManage), movie.DeleteMovie)
+
+			contentManage.POST("exam/create", middleware.AllowApiKey(model.PermissionExamManage), exam.CreateExam)
+			contentManage.POST("exam/update", middleware.AllowApiKey(model.PermissionExamManage), exam.UpdateExam)
+			contentManage.POST("exam/delete", middleware.AllowApiKey(model.PermissionExamManage), exam.DeleteExam)
+			contentManage.POST("exam/questions/add", middleware.AllowApiKey(model.PermissionQuestionManage), exam.AddQuestion)
+			contentManage.POST("exam/questions/update", middleware.AllowApiKey(model.PermissionQuestionManage), exam.UpdateQuestion)
+			contentManage.POST("exam/question/delete", middleware.AllowApiKey(model.PermissionQuestionManage), exam.DeleteQuestion)
+		}
+		// 无状态登录，只作用于本组路由。
+		stateLessAuth := api.Group("", middleware.AfapAuthenticate)
+		{
+
+			// 3.9 面试场景-结束面试
+			stateLessAuth.POST("endInterview/:interviewId", interviewApiHandler.EndInterview)
+			// 3.11 面试场景-进入面试
+			stateLessAuth.POST("joinInterview/:interviewId", interviewApiHandler.JoinInterview)
+			// 3.12 面试场景-离开面试
+			stateLessAuth.POST("leaveInterview/:interviewId", interviewApiHandler.LeaveInterview)
+			// 3.13 面试场景-心跳
+			stateLessAuth.GET("heartBeat/:interviewId", middleware.RateLimit(middleware.RateLimitGroupHeartbeat), interviewApiHandler.HeartBeat)
+			// 3.16 面试场景-面试详情
+			stateLessAuth.GET("interview/:interviewId", interviewApiHandler.GetInterview)
+
+		}
 
-		version.POST("version", versionApiHandler.CreateVersion)
-		version.POST("version/", versionApiHandler.CreateVersion)
+		version := api.Group("", middleware.Authenticate, middleware.RequirePermission(model.PermissionVersionManage))
+		{
+			version.GET("version", versionApiHandler.GetOrListVersion)
+			version.GET("version/", versionApiHandler.GetOrListVersion)
 
-		version.GET("version/:versionId", versionApiHandler.GetOrListVersion)
-		version.DELETE("version/:versionId", versionApiHandler.DeleteVersion)
-	}
+			version.POST("version", versionApiHandler.CreateVersion)
+			version.POST("version/", versionApiHandler.CreateVersion)
 
-	board := v1.Group("", middleware.AfapAuthenticate)
-	{
-		board.GET("board/:interviewId", boardApiHandler.GetBoard)
-		//board.POST("board",boardApiHandler.CreateOrUpdateBoard)
-		board.POST("board/:interviewId", boardApiHandler.CreateOrUpdateBoard)
-		board.PUT("board/:interviewId", boardApiHandler.CreateOrUpdateBoard)
-	}
-	ie := v1.Group("ie", middleware.Authenticate)
-	{
-		ieHandler.RegisterRoute(ie)
+			version.GET("version/:versionId", versionApiHandler.GetOrListVersion)
+			version.DELETE("version/:versionId", versionApiHandler.DeleteVersion)
+		}
+
+		// 管理员维护账号角色
+		admin := api.Group("admin", middleware.Authenticate, middleware.RequirePermission(model.PermissionRoleManage))
+		{
+			admin.GET("roles", roleApiHandler.ListRoles)
+			admin.GET("accounts/:accountId/roles", roleApiHandler.GetAccountRoles)
+			admin.POST("accounts/:accountId/roles", roleApiHandler.GrantRole)
+			admin.DELETE("accounts/:accountId/roles/:role", roleApiHandler.RevokeRole)
+		}
+		// 管理员维护服务端调用使用的API key
+		apiKeys := api.Group("admin/apiKeys", middleware.Authenticate, middleware.RequirePermission(model.PermissionApiKeyManage))
+		{
+			apiKeys.GET("", apiKeyApiHandler.ListApiKeys)
+			apiKeys.POST("", apiKeyApiHandler.CreateApiKey)
+			apiKeys.POST(":keyId/rotate", apiKeyApiHandler.RotateApiKey)
+			apiKeys.DELETE(":keyId", apiKeyApiHandler.RevokeApiKey)
+		}
+		// 管理员查询审计日志
+		api.GET("admin/auditLogs", middleware.Authenticate, middleware.RequirePermission(model.PermissionAuditLogRead), auditLogApiHandler.ListAuditLogs)
+
+		board := api.Group("", middleware.AfapAuthenticate)
+		{
+			board.GET("board/:interviewId", boardApiHandler.GetBoard)
+			//board.POST("board",boardApiHandler.CreateOrUpdateBoard)
+			board.POST("board/:interviewId", boardApiHandler.CreateOrUpdateBoard)
+			board.PUT("board/:interviewId", boardApiHandler.CreateOrUpdateBoard)
+		}
+		ie := api.Group("ie", middleware.Authenticate)
+		{
+			ieHandler.RegisterRoute(ie)
+		}
 	}
+	// 4.1 配置V1路径，保持现有的返回格式不变
+	v1 := router.Group("/v1", addApiVersion(model.ApiVersionV1), addRequestID, middleware.Localize, middleware.Trace, middleware.FetchPageInfo, middleware.AuditLog)
+	registerAPI(v1, model.ApiVersionV1)
 
 	appVersion := handler.NewAppVersionApiHandler(config.Mongo)
 
-	// 5. 配置V1路径
-	v2 := router.Group("/v2", addApiVersion(model.ApiVersionV2), addRequestID, middleware.FetchPageInfo, middleware.ActionLogMiddleware())
+	// 5. 配置V2路径
+	v2 := router.Group("/v2", addApiVersion(model.ApiVersionV2), addRequestID, middleware.Localize, middleware.APIV2, middleware.Trace, middleware.FetchPageInfo, middleware.AuditLog)
+	registerAPI(v2, model.ApiVersionV2)
 	{
 		v2.GET("solution", appConfigApiHandler.SolutionList)
 		v2.GET("solution/", appConfigApiHandler.SolutionList)
-		v2.POST("/app/updates", appVersion.UpdateAppVersion)
+		v2.POST("/app/updates", middleware.Authenticate, middleware.RequirePermission(model.PermissionVersionManage), appVersion.UpdateAppVersion)
 		v2.GET("/app/updates", appVersion.GetNewestAppVersion)
 	}
 
+	// 存活与就绪检查
+	healthApiHandler.Checkers, err = newHealthCheckers(config)
+	if err != nil {
+		return nil, err
+	}
+	router.GET("/healthz", healthApiHandler.Healthz)
+	router.GET("/readyz", healthApiHandler.Readyz)
+
 	router.NoRoute(addRequestID, returnNotFound)
 	router.RedirectTrailingSlash = false
 
@@ -346,24 +523,15 @@ func addRequestID(c *gin.Context) {
 	c.Set(model.RequestStartKey, time.Now())
 }
 
+// returnNotFound 未匹配的路由，V2路径返回404，其他路径保持V1的返回方式。
 func returnNotFound(c *gin.Context) {
 	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
 	xl.Debugf("%s %s: not found", c.Request.Method, c.Request.URL.Path)
 	responseErr := model.NewResponseErrorNotFound()
 	resp := model.NewFailResponse(*responseErr)
-	c.JSON(http.StatusOK, resp)
-}
-
-func corsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, HEAD")
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-		c.Next()
+	if strings.HasPrefix(c.Request.URL.Path, "/v2/") {
+		c.JSON(http.StatusNotFound, resp.WithRequestID(xl.ReqId))
+		return
 	}
+	c.JSON(http.StatusOK, resp)
 }
@@ -1,60 +1,146 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/i18n"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
 	model "github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/dao"
 	"github.com/solutions/niu-cube/internal/service/db"
 	"github.com/solutions/niu-cube/internal/service/web/middleware"
+	"gopkg.in/mgo.v2"
 	"math/rand"
 	"net/http"
-	"regexp"
 )
 
 type SmsCodeInterface interface {
-	Send(xl *xlog.Logger, phone string) (err error)
+	// Send 发送验证码，ip为请求方IP
+	Send(ctx context.Context, xl *xlog.Logger, phone string, ip string) (err error)
 	Validate(xl *xlog.Logger, phone string, smsCode string) (err error)
 }
 
+// CaptchaInterface 发送验证码前的人机验证
+type CaptchaInterface interface {
+	NewChallenge(xl *xlog.Logger) (*model.CaptchaResponse, error)
+	Verify(xl *xlog.Logger, captchaID string, answer string) error
+}
+
 type AccountInterface interface {
 	// GetAccountByPhone 通过手机号查询账号
-	GetAccountByPhone(xl *xlog.Logger, phone string) (*model.AccountDo, error)
+	GetAccountByPhone(ctx context.Context, xl *xlog.Logger, phone string) (*model.AccountDo, error)
 
 	// GetOrSaveAccountByPhone 通过手机号查询账号或创建账号
-	GetOrSaveAccountByPhone(xl *xlog.Logger, phone string) (*model.AccountDo, error)
+	GetOrSaveAccountByPhone(ctx context.Context, xl *xlog.Logger, phone string) (*model.AccountDo, error)
+
+	GetAccountByID(ctx context.Context, xl *xlog.Logger, id string) (*model.AccountDo, error)
+
+	CreateAccount(ctx context.Context, xl *xlog.Logger, account *model.AccountDo) error
+
+	UpdateAccount(ctx context.Context, xl *xlog.Logger, id string, account *model.AccountDo) (*model.AccountDo, error)
+
+	// AccountLogin 在指定设备上登录，sessionID不为空时续用该会话
+	AccountLogin(ctx context.Context, xl *xlog.Logger, id string, sessionID string, device model.DeviceInfo) (user *model.AccountTokenDo, err error)
+
+	// RefreshLogin 使用刷新token换取新的登录token
+	RefreshLogin(ctx context.Context, xl *xlog.Logger, refreshToken string) (user *model.AccountTokenDo, err error)
+
+	AccountLogout(ctx context.Context, xl *xlog.Logger, id string, sessionID string) error
+
+	// ListSessions 列出账号所有的登录会话
+	ListSessions(ctx context.Context, xl *xlog.Logger, id string) ([]model.AccountTokenDo, error)
+
+	// RevokeSession 注销账号的某个登录会话
+	RevokeSession(ctx context.Context, xl *xlog.Logger, id string, sessionID string) error
+
+	// RevokeAllSessions 注销账号的所有登录会话，保留exceptSessionID
+	RevokeAllSessions(ctx context.Context, xl *xlog.Logger, id string, exceptSessionID string) error
+
+	DeleteAccount(ctx context.Context, xl *xlog.Logger, id string) error
 
-	GetAccountByID(xl *xlog.Logger, id string) (*model.AccountDo, error)
+	// GetAccountByEmail 通过邮箱查询账号
+	GetAccountByEmail(ctx context.Context, xl *xlog.Logger, email string) (*model.AccountDo, error)
 
-	CreateAccount(xl *xlog.Logger, account *model.AccountDo) error
+	// CreateAccountWithPassword 使用邮箱与密码创建账号
+	CreateAccountWithPassword(ctx context.Context, xl *xlog.Logger, account *model.AccountDo, password string) error
 
-	UpdateAccount(xl *xlog.Logger, id string, account *model.AccountDo) (*model.AccountDo, error)
+	// LoginByPassword 校验邮箱与密码
+	LoginByPassword(ctx context.Context, xl *xlog.Logger, email string, password string) (*model.AccountDo, error)
 
-	AccountLogin(xl *xlog.Logger, id string) (user *model.AccountTokenDo, err error)
+	// UpdatePassword 修改密码，并注销sessionID以外的会话
+	UpdatePassword(ctx context.Context, xl *xlog.Logger, id string, sessionID string, oldPassword string, newPassword string) error
 
-	AccountLogout(xl *xlog.Logger, id string) error
+	// CreateEmailToken 签发邮箱验证或重置密码的一次性token
+	CreateEmailToken(ctx context.Context, xl *xlog.Logger, account *model.AccountDo, purpose model.AccountEmailTokenPurpose) (string, error)
 
-	DeleteAccount(xl *xlog.Logger, id string) error
+	VerifyEmail(ctx context.Context, xl *xlog.Logger, token string) (*model.AccountDo, error)
 
-	ListAll0() ([]model.AccountDo, error)
+	ResetPassword(ctx context.Context, xl *xlog.Logger, token string, newPassword string) error
+
+	// GetOrCreateAccountByWeixin 查找或创建与微信用户关联的账号，phone不为空时绑定该手机号
+	GetOrCreateAccountByWeixin(ctx context.Context, xl *xlog.Logger, openID string, unionID string, phone string, newAccount *model.AccountDo) (*model.AccountDo, bool, error)
+}
+
+// AccountDataInterface 注销账号时清理账号数据，以及导出账号的个人数据
+type AccountDataInterface interface {
+	// StartDeletion 以后台任务的方式注销账号
+	StartDeletion(ctx context.Context, xl *xlog.Logger, account *model.AccountDo)
+	// GetDeletion 查询账号注销任务的状态
+	GetDeletion(ctx context.Context, xl *xlog.Logger, accountID string) (*model.AccountDeletionResponse, error)
+	ExportAccountData(ctx context.Context, xl *xlog.Logger, account *model.AccountDo) (*model.AccountDataExport, error)
 }
 
 type AccountApiHandler struct {
-	Account           AccountInterface
-	SmsCode           SmsCodeInterface
+	Account AccountInterface
+	SmsCode SmsCodeInterface
+	// Captcha 为nil时发送验证码不需要人机验证。
+	Captcha           CaptchaInterface
 	AppConfigService  db.AppConfigInterface
 	DefaultAvatarURLs []string
 	BaseUserDao       dao.BaseUserDaoInterface
 	ExamService       ExamApi
+	// Mail 发送邮箱验证与重置密码邮件，FrontendUrlHost 为邮件中链接的前端地址。
+	Mail            MailInterface
+	FrontendUrlHost string
+	AccountData     AccountDataInterface
+	// Weixin 小程序登录调用的微信接口，WeixinAppID 用于校验加密手机号数据的来源。
+	Weixin      WeixinAuthInterface
+	WeixinAppID string
+	// PasswordLimit 为nil时密码登录与发送邮件不做限制。
+	PasswordLimit PasswordLimitInterface
+}
+
+// normalizePhone 检查手机号码是否符合规则，并统一为不带默认国家码的格式。
+func normalizePhone(phone string) (string, bool) {
+	countryCode := ""
+	if utils.DefaultConf.SMS != nil {
+		countryCode = utils.DefaultConf.SMS.DefaultCountryCode
+	}
+	return utils.NormalizePhone(phone, countryCode)
 }
 
-// validatePhone 检查手机号码是否符合规则。
-func validatePhone(phone string) bool {
-	phoneRegExp := regexp.MustCompile(`1[3-9][0-9]{9}`)
-	return phoneRegExp.MatchString(phone)
+// GetCaptcha 获取发送验证码前的人机验证题目
+func (h *AccountApiHandler) GetCaptcha(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	if h.Captcha == nil {
+		responseErr := model.NewResponseErrorNotFound()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID).WithErrorMessage("captcha is not enabled")
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	challenge, err := h.Captcha.NewChallenge(xl)
+	if err != nil {
+		xl.Errorf("failed to create captcha challenge, error %v", err)
+		responseErr := model.NewResponseErrorInternal()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	c.JSON(http.StatusOK, model.NewSuccessResponse(challenge).WithRequestID(requestID))
 }
 
 // SendSmsCode 发送验证码短信
@@ -69,13 +155,25 @@ func (h *AccountApiHandler) SendSmsCode(c *gin.Context) {
 		c.JSON(http.StatusOK, resp)
 		return
 	}
-	if !validatePhone(args.Phone) {
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID).WithErrorMessage("手机号不合法")
+	phone, ok := normalizePhone(args.Phone)
+	if !ok {
+		responseErr := model.NewResponseErrorInvalidPhone()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
 		c.JSON(http.StatusOK, resp)
 		return
 	}
-	messageSendErr := h.SmsCode.Send(xl, args.Phone)
+	args.Phone = phone
+	if h.Captcha != nil {
+		err = h.Captcha.Verify(xl, args.CaptchaID, args.CaptchaAnswer)
+		if err != nil {
+			xl.Infof("captcha verification failed for %s, error %v", args.Phone, err)
+			responseErr := model.NewResponseErrorWrongCaptcha()
+			resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+	}
+	messageSendErr := h.SmsCode.Send(c.Request.Context(), xl, args.Phone, middleware.ClientIP(c))
 	if messageSendErr != nil {
 		serverErr, ok := messageSendErr.(*errors2.ServerError)
 		if ok && serverErr.Code == errors2.ServerErrorSMSSendTooFrequent {
@@ -85,6 +183,13 @@ func (h *AccountApiHandler) SendSmsCode(c *gin.Context) {
 			c.JSON(http.StatusOK, resp)
 			return
 		}
+		if ok && serverErr.Code == errors2.ServerErrorSMSQuotaExceeded {
+			xl.Infof("SMS quota exceeded for %s from %s", args.Phone, middleware.ClientIP(c))
+			responseErr := model.NewResponseErrorSMSQuotaExceeded()
+			resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+			c.JSON(http.StatusOK, resp)
+			return
+		}
 		xl.Errorf("failed to send sms code to phone number %s, error %v", args.Phone, messageSendErr)
 		c.JSON(http.StatusInternalServerError, messageSendErr)
 		return
@@ -107,12 +212,16 @@ func (h *AccountApiHandler) generateNicknameByPhone(phone string) string {
 	return namePrefix + phone[len(phone)-4:]
 }
 
+// generateInitialAvatar 从DefaultAvatarURLs中随机选取新用户的头像，DefaultAvatarURLs为空时使用配置中可重新加载的default_avatars。
 func (h *AccountApiHandler) generateInitialAvatar() string {
-	if len(h.DefaultAvatarURLs) == 0 {
+	avatars := h.DefaultAvatarURLs
+	if len(avatars) == 0 {
+		avatars = utils.Reloadable().DefaultAvatars
+	}
+	if len(avatars) == 0 {
 		return ""
 	}
-	index := rand.Intn(len(h.DefaultAvatarURLs))
-	return h.DefaultAvatarURLs[index]
+	return avatars[rand.Intn(len(avatars))]
 }
 
 func (h *AccountApiHandler) SignUpOrIn(c *gin.Context) {
@@ -128,15 +237,25 @@ func (h *AccountApiHandler) SignUpOrIn(c *gin.Context) {
 		return
 	}
 
+	// 合法手机号统一格式，与发送验证码时一致；不合法的号码保持原样，仅可能匹配固定验证码。
+	if phone, ok := normalizePhone(args.Phone); ok {
+		args.Phone = phone
+	}
 	err = h.SmsCode.Validate(xl, args.Phone, args.SMSCode)
 	if err != nil {
 		xl.Infof("SignUpOrIn: validate SMS code failed, error %v", err)
+		if serverErr, ok := err.(*errors2.ServerError); ok && serverErr.Code == errors2.ServerErrorSMSValidateLocked {
+			responseErr := model.NewResponseErrorSMSValidateLocked()
+			resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+			c.JSON(http.StatusOK, resp)
+			return
+		}
 		responseErr := model.NewResponseErrorWrongSMSCode()
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
 		c.JSON(http.StatusOK, resp)
 		return
 	}
-	account, err := h.Account.GetAccountByPhone(xl, args.Phone)
+	account, err := h.Account.GetAccountByPhone(c.Request.Context(), xl, args.Phone)
 	if err != nil {
 		if err.Error() == "not found" {
 			xl.Infof("SignUpOrIn: phone number %s not found, create new account", args.Phone)
@@ -146,7 +265,7 @@ func (h *AccountApiHandler) SignUpOrIn(c *gin.Context) {
 				Phone:    args.Phone,
 				Avatar:   h.generateInitialAvatar(),
 			}
-			createErr := h.Account.CreateAccount(xl, newAccount)
+			createErr := h.Account.CreateAccount(c.Request.Context(), xl, newAccount)
 			if createErr != nil {
 				xl.Errorf("SignUpOrIn: failed to create account, error %v", err)
 				responseErr := model.NewResponseErrorInternal()
@@ -164,8 +283,8 @@ func (h *AccountApiHandler) SignUpOrIn(c *gin.Context) {
 				Profile:       "",
 				BaseUserAttrs: make([]model.BaseEntryDo, 0, 0),
 			}
-			h.BaseUserDao.Insert(nil, &baseUser)
-			h.ExamService.SyncExamList(baseUser.Id)
+			h.BaseUserDao.Insert(c.Request.Context(), nil, &baseUser)
+			h.ExamService.SyncExamList(c.Request.Context(), baseUser.Id)
 		} else {
 			xl.Errorf("SignUpOrIn: get account by phone number failed, error %v", err)
 			responseErr := model.NewResponseErrorInternal()
@@ -177,7 +296,8 @@ func (h *AccountApiHandler) SignUpOrIn(c *gin.Context) {
 	xl.Infof("SignUpOrIn: accountId => %s", account.ID)
 
 	// 更新该账号状态为已登录。
-	user, err := h.Account.AccountLogin(xl, account.ID)
+	device := middleware.FetchDeviceInfo(xl, requestID, c)
+	user, err := h.Account.AccountLogin(c.Request.Context(), xl, account.ID, "", device)
 	if err != nil {
 		serverErr, ok := err.(*errors2.ServerError)
 		if ok && serverErr.Code == errors2.ServerErrorUserLoggedin {
@@ -194,7 +314,7 @@ func (h *AccountApiHandler) SignUpOrIn(c *gin.Context) {
 		return
 	}
 
-	imUser, err := h.AppConfigService.GetUserToken(xl, user.AccountId)
+	imUser, err := h.AppConfigService.GetUserToken(c.Request.Context(), xl, user.AccountId)
 	if err != nil {
 		xl.Errorf("failed to call IM db to get token")
 		responseErr := model.NewResponseErrorExternalService()
@@ -211,7 +331,7 @@ func (h *AccountApiHandler) SignUpOrIn(c *gin.Context) {
 			Avatar:   account.Avatar,
 			Profile:  string(model.DefaultAccountProfile),
 		},
-		Token: user.Token,
+		LoginTokenResponse: newLoginTokenResponse(user),
 		ImConfigResponse: model.ImConfigResponse{
 			IMUsername: imUser.Username,
 			IMPassword: imUser.GetPassword(),
@@ -227,12 +347,14 @@ func (h *AccountApiHandler) SignOut(c *gin.Context) {
 	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
 	requestID := xl.ReqId
 	userID := c.GetString(model.UserIDContextKey)
-	err := h.Account.AccountLogout(xl, userID)
+	sessionID := c.GetString(model.SessionIDContextKey)
+	err := h.Account.AccountLogout(c.Request.Context(), xl, userID, sessionID)
 	if err != nil {
 		xl.Errorf("user %s log out error: %v", userID, err)
 		responseErr := model.NewResponseErrorNotLoggedIn()
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
 		c.JSON(http.StatusOK, resp)
+		return
 	}
 	xl.Infof("user %s logged out", userID)
 	c.SetCookie(model.LoginTokenKey, "", -1, "/", "niucube.qiniu.com", true, false)
@@ -245,7 +367,7 @@ func (h *AccountApiHandler) SignInWithToken(c *gin.Context) {
 	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
 	requestID := xl.ReqId
 	accountId := c.GetString(model.UserIDContextKey)
-	account, err := h.Account.GetAccountByID(xl, accountId)
+	account, err := h.Account.GetAccountByID(c.Request.Context(), xl, accountId)
 	if err != nil {
 		xl.Infof("cannot find account, accountId: %s, error %v", accountId, err)
 		responseErr := model.NewResponseErrorNoSuchUser()
@@ -254,8 +376,9 @@ func (h *AccountApiHandler) SignInWithToken(c *gin.Context) {
 		return
 	}
 
-	// 更新该账号状态为已登录。
-	user, err := h.Account.AccountLogin(xl, account.ID)
+	// 更新该账号状态为已登录，续用当前会话。
+	device := middleware.FetchDeviceInfo(xl, requestID, c)
+	user, err := h.Account.AccountLogin(c.Request.Context(), xl, account.ID, c.GetString(model.SessionIDContextKey), device)
 	if err != nil {
 		serverErr, ok := err.(*errors2.ServerError)
 		if ok && serverErr.Code == errors2.ServerErrorUserLoggedin {
@@ -273,7 +396,7 @@ func (h *AccountApiHandler) SignInWithToken(c *gin.Context) {
 	}
 
 	// TODO 融云获取需要用户ID，不同设备，相同七牛账号如何处理，是否可以用UUID
-	imUser, err := h.AppConfigService.GetUserToken(xl, user.AccountId)
+	imUser, err := h.AppConfigService.GetUserToken(c.Request.Context(), xl, user.AccountId)
 	if err != nil {
 		xl.Errorf("failed to call IM db to get token")
 		responseErr := model.NewResponseErrorExternalService()
@@ -289,7 +412,7 @@ func (h *AccountApiHandler) SignInWithToken(c *gin.Context) {
 			Avatar:   account.Avatar,
 			Profile:  string(model.DefaultAccountProfile),
 		},
-		Token: user.Token,
+		LoginTokenResponse: newLoginTokenResponse(user),
 		ImConfigResponse: model.ImConfigResponse{
 			IMUsername: imUser.Username,
 			IMPassword: imUser.GetPassword(),
@@ -301,6 +424,129 @@ func (h *AccountApiHandler) SignInWithToken(c *gin.Context) {
 	c.JSON(http.StatusOK, res)
 }
 
+// RefreshToken 使用刷新token换取新的登录token，旧的登录token与刷新token同时失效。
+func (h *AccountApiHandler) RefreshToken(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	args := model.RefreshTokenArgs{}
+	err := c.Bind(&args)
+	if err != nil || args.RefreshToken == "" {
+		xl.Infof("RefreshToken: invalid args in body, error %v", err)
+		responseErr := model.NewResponseErrorBadRequest()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	user, err := h.Account.RefreshLogin(c.Request.Context(), xl, args.RefreshToken)
+	if err != nil {
+		serverErr, ok := err.(*errors2.ServerError)
+		if ok && serverErr.Code == errors2.ServerErrorTokenExpired {
+			responseErr := model.NewResponseErrorTokenExpired()
+			resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+		if ok && serverErr.Code == errors2.ServerErrorTokenInvalid {
+			responseErr := model.NewResponseErrorBadToken()
+			resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+		xl.Errorf("failed to refresh login token, error %v", err)
+		responseErr := model.NewResponseErrorInternal()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	h.actionLog(c).UserInfo(fmt.Sprintf("user %s", user.AccountId))
+	c.SetCookie(model.LoginTokenKey, user.Token, 0, "/", "niucube.qiniu.com", true, false)
+	c.JSON(http.StatusOK, model.NewSuccessResponse(newLoginTokenResponse(user)).WithRequestID(requestID))
+}
+
+// ListSessions 列出当前账号在各设备上的登录会话。
+func (h *AccountApiHandler) ListSessions(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	userID := c.GetString(model.UserIDContextKey)
+	currentSessionID := c.GetString(model.SessionIDContextKey)
+	sessions, err := h.Account.ListSessions(c.Request.Context(), xl, userID)
+	if err != nil {
+		responseErr := model.NewResponseErrorInternal()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	list := make([]model.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		list = append(list, model.SessionResponse{
+			SessionID:      session.ID,
+			DeviceID:       session.DeviceID,
+			DeviceType:     session.DeviceType,
+			UserAgent:      session.UserAgent,
+			IP:             session.IP,
+			CreateTime:     session.CreateTime.Unix(),
+			LastActiveTime: session.LastModifyTime.Unix(),
+			Current:        session.ID == currentSessionID,
+		})
+	}
+	res := model.NewSuccessResponse(model.SessionListResponse{
+		List:  list,
+		Total: len(list),
+	}).WithRequestID(requestID)
+	c.JSON(http.StatusOK, res)
+}
+
+// RevokeSession 注销当前账号的某个登录会话，可用于踢掉其他设备。
+func (h *AccountApiHandler) RevokeSession(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	userID := c.GetString(model.UserIDContextKey)
+	sessionID := c.Param("sessionId")
+	err := h.Account.RevokeSession(c.Request.Context(), xl, userID, sessionID)
+	if err != nil {
+		responseErr := model.NewResponseErrorInternal()
+		if err == mgo.ErrNotFound {
+			responseErr = model.NewResponseErrorNotFound()
+		}
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	xl.Infof("user %s revoked session %s", userID, sessionID)
+	c.JSON(http.StatusOK, model.NewSuccessResponse(nil).WithRequestID(requestID))
+}
+
+// RevokeAllSessions 注销当前账号的所有登录会话，keepCurrent=true时保留发起请求的会话。
+func (h *AccountApiHandler) RevokeAllSessions(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	userID := c.GetString(model.UserIDContextKey)
+	exceptSessionID := ""
+	if c.Query("keepCurrent") == "true" {
+		exceptSessionID = c.GetString(model.SessionIDContextKey)
+	}
+	err := h.Account.RevokeAllSessions(c.Request.Context(), xl, userID, exceptSessionID)
+	if err != nil {
+		responseErr := model.NewResponseErrorInternal()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	xl.Infof("user %s revoked all sessions except %q", userID, exceptSessionID)
+	if exceptSessionID == "" {
+		c.SetCookie(model.LoginTokenKey, "", -1, "/", "niucube.qiniu.com", true, false)
+	}
+	c.JSON(http.StatusOK, model.NewSuccessResponse(nil).WithRequestID(requestID))
+}
+
+func newLoginTokenResponse(user *model.AccountTokenDo) model.LoginTokenResponse {
+	return model.LoginTokenResponse{
+		Token:        user.Token,
+		RefreshToken: user.RefreshToken,
+		ExpireAt:     user.ExpireAt.Unix(),
+	}
+}
+
 func (h *AccountApiHandler) UpdateAccountInfo(c *gin.Context) {
 	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
 	requestID := xl.ReqId
@@ -316,7 +562,7 @@ func (h *AccountApiHandler) UpdateAccountInfo(c *gin.Context) {
 		return
 	}
 
-	account, err := h.Account.GetAccountByID(xl, accountId)
+	account, err := h.Account.GetAccountByID(c.Request.Context(), xl, accountId)
 	if err != nil {
 		xl.Infof("cannot find account, accountId: %s, error %v", accountId, err)
 		responseErr := model.NewResponseErrorNoSuchUser()
@@ -338,8 +584,18 @@ func (h *AccountApiHandler) UpdateAccountInfo(c *gin.Context) {
 	if args.Avatar != "" {
 		account.Avatar = args.Avatar
 	}
+	if args.Locale != "" {
+		account.Locale = i18n.Normalize(args.Locale)
+		if account.Locale == "" {
+			xl.Infof("unsupported locale %s", args.Locale)
+			responseErr := model.NewResponseErrorBadRequest()
+			resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+	}
 
-	newAccount, err := h.Account.UpdateAccount(xl, accountId, account)
+	newAccount, err := h.Account.UpdateAccount(c.Request.Context(), xl, accountId, account)
 	if err != nil {
 		responseErr := model.NewResponseErrorInternal()
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
@@ -354,6 +610,7 @@ func (h *AccountApiHandler) UpdateAccountInfo(c *gin.Context) {
 			Avatar:   newAccount.Avatar,
 			Phone:    newAccount.Phone,
 			Profile:  string(model.DefaultAccountProfile),
+			Locale:   newAccount.Locale,
 		},
 	})
 	c.JSON(http.StatusOK, res)
@@ -363,7 +620,7 @@ func (h *AccountApiHandler) GetAccountInfo(c *gin.Context) {
 	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
 	requestID := xl.ReqId
 	accountId := c.GetString(model.UserIDContextKey)
-	account, err := h.Account.GetAccountByID(xl, accountId)
+	account, err := h.Account.GetAccountByID(c.Request.Context(), xl, accountId)
 	if err != nil {
 		xl.Infof("cannot find account, error %v", err)
 		responseErr := model.NewResponseErrorNoSuchUser()
@@ -386,85 +643,161 @@ func (h *AccountApiHandler) GetAccountInfo(c *gin.Context) {
 			Avatar:   account.Avatar,
 			Phone:    account.Phone,
 			Profile:  string(model.DefaultAccountProfile),
+			Locale:   account.Locale,
 		},
 	})
 	c.JSON(http.StatusOK, res)
 }
 
-func (h *AccountApiHandler) Sync(context *gin.Context) {
+// DeleteAccount 管理员注销指定手机号的账号，账号数据在后台任务中清理
+func (h *AccountApiHandler) DeleteAccount(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
-	accountDos, err := h.Account.ListAll0()
+	userId := context.GetString(model.UserIDContextKey)
+	phone := context.Param("phone")
+	xl.Infof("user: %s try to delete %s.", userId, phone)
+	accountDo, err := h.Account.GetAccountByPhone(context.Request.Context(), nil, phone)
 	if err != nil {
-		xl := context.MustGet(model.XLogKey).(*xlog.Logger)
-		xl.Errorf("cannot list all accounts, error %v", err)
-		responseErr := model.NewResponseErrorInternal()
-		resp := model.NewFailResponse(*responseErr)
+		responseErr := model.NewResponseErrorNoSuchUser()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	for i := range accountDos {
-		user, _ := h.BaseUserDao.Select(nil, accountDos[i].ID)
-		if user == nil {
-			baseUser := model.BaseUserDo{
-				Id:            accountDos[i].ID,
-				Name:          accountDos[i].Nickname,
-				Nickname:      accountDos[i].Nickname,
-				Avatar:        accountDos[i].Avatar,
-				Status:        model.BaseUserLogin,
-				Profile:       "",
-				BaseUserAttrs: make([]model.BaseEntryDo, 0, 0),
+	h.startDeletion(context, accountDo)
+}
+
+// DeleteMyAccount 注销当前登录的账号，需要在请求中提供密码或短信验证码重新验证身份
+func (h *AccountApiHandler) DeleteMyAccount(context *gin.Context) {
+	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
+	requestId := xl.ReqId
+	userId := context.GetString(model.UserIDContextKey)
+	args := model.DeleteAccountArgs{}
+	err := context.ShouldBind(&args)
+	if err != nil || (args.Password == "" && args.SMSCode == "") {
+		xl.Infof("DeleteMyAccount: password or sms code required, error %v", err)
+		responseErr := model.NewResponseErrorReauthRequired()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
+		context.JSON(http.StatusOK, resp)
+		return
+	}
+	accountDo, err := h.Account.GetAccountByID(context.Request.Context(), xl, userId)
+	if err != nil {
+		xl.Infof("cannot find account %s, error %v", userId, err)
+		responseErr := model.NewResponseErrorNoSuchUser()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
+		context.JSON(http.StatusOK, resp)
+		return
+	}
+	if !h.reauthenticate(context, xl, accountDo, args) {
+		return
+	}
+	xl.Infof("user: %s try to delete own account.", userId)
+	h.startDeletion(context, accountDo)
+}
+
+// reauthenticate 使用密码或绑定手机号的短信验证码重新验证身份，失败时写入错误返回并返回false。
+// 设置了密码的账号只接受密码，未设置密码的账号使用短信验证码。
+func (h *AccountApiHandler) reauthenticate(context *gin.Context, xl *xlog.Logger, accountDo *model.AccountDo, args model.DeleteAccountArgs) bool {
+	requestId := xl.ReqId
+	if accountDo.Password != "" {
+		limitKey := accountDo.ID
+		if accountDo.Email != "" {
+			limitKey = limitKeyOfEmail(accountDo.Email)
+		}
+		if h.PasswordLimit != nil {
+			if err := h.PasswordLimit.CheckLogin(xl, limitKey); err != nil {
+				h.writePasswordError(context, xl, err)
+				return false
 			}
-			h.BaseUserDao.Insert(nil, &baseUser)
 		}
-		xl.Infof("sync: %s", accountDos[i].Phone)
-		h.ExamService.SyncExamList(accountDos[i].ID)
+		if !db.CheckPassword(accountDo, args.Password) {
+			xl.Infof("wrong password to delete account %s", accountDo.ID)
+			if h.PasswordLimit != nil {
+				h.PasswordLimit.RecordLoginFailure(xl, limitKey)
+			}
+			responseErr := model.NewResponseErrorWrongPassword()
+			resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
+			context.JSON(http.StatusOK, resp)
+			return false
+		}
+		if h.PasswordLimit != nil {
+			h.PasswordLimit.ResetLoginFailures(xl, limitKey)
+		}
+		return true
 	}
-	resp := &model.Response{
-		Code:    int(model.ResponseStatusCodeSuccess),
-		Message: string(model.ResponseStatusMessageSuccess),
-		Data: struct {
-		}{},
-		RequestID: requestId,
+	if accountDo.Phone == "" {
+		xl.Infof("account %s has neither password nor phone to reauthenticate", accountDo.ID)
+		responseErr := model.NewResponseErrorPasswordNotSet()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
+		context.JSON(http.StatusOK, resp)
+		return false
+	}
+	err := h.SmsCode.Validate(xl, accountDo.Phone, args.SMSCode)
+	if err != nil {
+		xl.Infof("wrong sms code to delete account %s, error %v", accountDo.ID, err)
+		responseErr := model.NewResponseErrorWrongSMSCode()
+		if serverErr, ok := err.(*errors2.ServerError); ok && serverErr.Code == errors2.ServerErrorSMSValidateLocked {
+			responseErr = model.NewResponseErrorSMSValidateLocked()
+		}
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
+		context.JSON(http.StatusOK, resp)
+		return false
 	}
-	context.JSON(http.StatusOK, resp)
+	return true
 }
 
-func (h *AccountApiHandler) DeleteAccount(context *gin.Context) {
+func (h *AccountApiHandler) startDeletion(context *gin.Context, accountDo *model.AccountDo) {
+	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
+	h.AccountData.StartDeletion(context.Request.Context(), xl, accountDo)
+	resp := &model.AccountDeletionResponse{
+		AccountID: accountDo.ID,
+		Status:    model.TaskStatusRunning,
+	}
+	context.JSON(http.StatusOK, model.NewSuccessResponse(resp).WithRequestID(xl.ReqId))
+}
+
+// GetAccountDeletion 查询账号注销任务的状态，完成后返回各个表中删除与匿名化的记录数
+func (h *AccountApiHandler) GetAccountDeletion(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
-	userId := context.GetString(model.UserIDContextKey)
-	phone := context.Param("phone")
-	xl.Infof("user: %s try to delete %s.", userId, phone)
-	accountDo, err := h.Account.GetAccountByPhone(nil, phone)
+	accountId := context.Param("accountId")
+	deletion, err := h.AccountData.GetDeletion(context.Request.Context(), xl, accountId)
 	if err != nil {
-		responseErr := model.NewResponseErrorNoSuchUser()
-		resp := model.NewFailResponse(*responseErr)
+		var responseErr *model.ResponseError
+		if err == mgo.ErrNotFound {
+			responseErr = model.NewResponseErrorNotFound()
+		} else {
+			responseErr = model.NewResponseErrorInternal()
+		}
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	err = h.Account.DeleteAccount(nil, accountDo.ID)
+	context.JSON(http.StatusOK, model.NewSuccessResponse(deletion).WithRequestID(requestId))
+}
+
+// ExportAccountData 以JSON文件的形式下载当前账号的所有个人数据
+func (h *AccountApiHandler) ExportAccountData(context *gin.Context) {
+	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
+	requestId := xl.ReqId
+	userId := context.GetString(model.UserIDContextKey)
+	accountDo, err := h.Account.GetAccountByID(context.Request.Context(), xl, userId)
 	if err != nil {
-		responseErr := model.NewResponseErrorInternal()
-		resp := model.NewFailResponse(*responseErr)
+		xl.Infof("cannot find account %s, error %v", userId, err)
+		responseErr := model.NewResponseErrorNoSuchUser()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	err = h.BaseUserDao.Delete(nil, accountDo.ID)
+	export, err := h.AccountData.ExportAccountData(context.Request.Context(), xl, accountDo)
 	if err != nil {
 		responseErr := model.NewResponseErrorInternal()
-		resp := model.NewFailResponse(*responseErr)
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	resp := &model.Response{
-		Code:    int(model.ResponseStatusCodeSuccess),
-		Message: string(model.ResponseStatusMessageSuccess),
-		Data: struct {
-		}{},
-		RequestID: requestId,
-	}
-	context.JSON(http.StatusOK, resp)
+	context.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"account-%s.json\"", accountDo.ID))
+	context.JSON(http.StatusOK, model.NewSuccessResponse(export).WithRequestID(requestId))
 }
 
 func (h *AccountApiHandler) actionLog(c *gin.Context) *middleware.Action {
@@ -0,0 +1,353 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/utils"
+	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
+	model "github.com/solutions/niu-cube/internal/protodef/model"
+	"github.com/solutions/niu-cube/internal/service/db"
+	"github.com/solutions/niu-cube/internal/service/web/middleware"
+	"gopkg.in/mgo.v2"
+)
+
+// MailInterface 发送验证邮箱、重置密码邮件
+type MailInterface interface {
+	SendMail(xl *xlog.Logger, to []string, subject string, body string) error
+}
+
+// PasswordLimitInterface 密码登录的输错锁定与验证、重置密码邮件的发送额度
+type PasswordLimitInterface interface {
+	CheckLogin(xl *xlog.Logger, email string) error
+	RecordLoginFailure(xl *xlog.Logger, email string)
+	ResetLoginFailures(xl *xlog.Logger, email string)
+	// ReserveMail 累加邮箱与IP当天的邮件计数，返回发送失败时回退计数的函数
+	ReserveMail(xl *xlog.Logger, email string, ip string) (func(), error)
+}
+
+// limitKeyOfEmail 返回计数与锁定使用的邮箱，不合法的邮箱同样按小写计数。
+func limitKeyOfEmail(email string) string {
+	if normalized, ok := db.NormalizeEmail(email); ok {
+		return normalized
+	}
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// SignUpByEmail 使用邮箱与密码注册，注册后发送验证邮件。
+func (h *AccountApiHandler) SignUpByEmail(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	args := model.EmailSignUpArgs{}
+	err := c.Bind(&args)
+	if err != nil {
+		xl.Infof("SignUpByEmail: invalid args in body, error %v", err)
+		responseErr := model.NewResponseErrorBadRequest()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	email, ok := db.NormalizeEmail(args.Email)
+	if !ok {
+		responseErr := model.NewResponseErrorInvalidEmail()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	nickname := args.Nickname
+	if nickname == "" {
+		nickname = h.generateNicknameByEmail(email)
+	}
+	account := &model.AccountDo{
+		ID:         utils.GenerateID(),
+		Email:      email,
+		Nickname:   nickname,
+		Avatar:     h.generateInitialAvatar(),
+		RegisterIP: middleware.ClientIP(c),
+	}
+	err = h.Account.CreateAccountWithPassword(c.Request.Context(), xl, account, args.Password)
+	if err != nil {
+		h.writePasswordError(c, xl, err)
+		return
+	}
+	h.initBaseUser(c.Request.Context(), xl, account)
+	h.sendEmailToken(c.Request.Context(), xl, account, model.AccountEmailTokenVerifyEmail)
+
+	xl.Infof("SignUpByEmail: account %s created for %s", account.ID, email)
+	h.actionLog(c).UserInfo(fmt.Sprintf("unauthorized user %s", email))
+	c.JSON(http.StatusOK, model.NewSuccessResponse(model.UserInfoResponse{
+		ID:       account.ID,
+		Nickname: account.Nickname,
+		Avatar:   account.Avatar,
+		Profile:  string(model.DefaultAccountProfile),
+	}).WithRequestID(requestID))
+}
+
+// SignInByPassword 使用邮箱与密码登录，返回结果与短信登录相同。
+func (h *AccountApiHandler) SignInByPassword(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	args := model.PasswordLoginArgs{}
+	err := c.Bind(&args)
+	if err != nil || args.Email == "" || args.Password == "" {
+		xl.Infof("SignInByPassword: invalid args in body, error %v", err)
+		responseErr := model.NewResponseErrorBadRequest()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	email := limitKeyOfEmail(args.Email)
+	if h.PasswordLimit != nil {
+		err = h.PasswordLimit.CheckLogin(xl, email)
+		if err != nil {
+			h.writePasswordError(c, xl, err)
+			return
+		}
+	}
+	account, err := h.Account.LoginByPassword(c.Request.Context(), xl, args.Email, args.Password)
+	if err != nil {
+		if serverErr, ok := err.(*errors2.ServerError); ok && serverErr.Code == errors2.ServerErrorWrongPassword && h.PasswordLimit != nil {
+			h.PasswordLimit.RecordLoginFailure(xl, email)
+		}
+		h.writePasswordError(c, xl, err)
+		return
+	}
+	if h.PasswordLimit != nil {
+		h.PasswordLimit.ResetLoginFailures(xl, email)
+	}
+	h.actionLog(c).UserInfo(fmt.Sprintf("user %s", account.Email))
+	h.completeSignIn(c, xl, account)
+}
+
+// VerifyEmail 使用验证邮件中的token确认邮箱。
+func (h *AccountApiHandler) VerifyEmail(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	args := model.VerifyEmailArgs{}
+	err := c.Bind(&args)
+	if err != nil || args.Token == "" {
+		responseErr := model.NewResponseErrorBadRequest()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	account, err := h.Account.VerifyEmail(c.Request.Context(), xl, args.Token)
+	if err != nil {
+		h.writePasswordError(c, xl, err)
+		return
+	}
+	xl.Infof("email %s of account %s verified", account.Email, account.ID)
+	c.JSON(http.StatusOK, model.NewSuccessResponse(nil).WithRequestID(requestID))
+}
+
+// ResendVerifyEmail 重新发送验证邮件。邮箱未注册或已验证时同样返回成功，避免泄露邮箱是否注册。
+func (h *AccountApiHandler) ResendVerifyEmail(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	args := model.EmailArgs{}
+	err := c.Bind(&args)
+	if err != nil || args.Email == "" {
+		responseErr := model.NewResponseErrorBadRequest()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	if !h.reserveMail(c, xl, args.Email) {
+		return
+	}
+	account, err := h.Account.GetAccountByEmail(c.Request.Context(), xl, args.Email)
+	if err == nil && !account.EmailVerified {
+		h.sendEmailToken(c.Request.Context(), xl, account, model.AccountEmailTokenVerifyEmail)
+	} else if err != nil && err != mgo.ErrNotFound {
+		xl.Errorf("failed to get account by email %s, error %v", args.Email, err)
+	}
+	c.JSON(http.StatusOK, model.NewSuccessResponse(nil).WithRequestID(requestID))
+}
+
+// ForgotPassword 发送重置密码邮件。邮箱未注册时同样返回成功，避免泄露邮箱是否注册。
+func (h *AccountApiHandler) ForgotPassword(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	args := model.EmailArgs{}
+	err := c.Bind(&args)
+	if err != nil || args.Email == "" {
+		responseErr := model.NewResponseErrorBadRequest()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	if !h.reserveMail(c, xl, args.Email) {
+		return
+	}
+	account, err := h.Account.GetAccountByEmail(c.Request.Context(), xl, args.Email)
+	if err == nil {
+		h.sendEmailToken(c.Request.Context(), xl, account, model.AccountEmailTokenResetPassword)
+	} else if err != mgo.ErrNotFound {
+		xl.Errorf("failed to get account by email %s, error %v", args.Email, err)
+	}
+	c.JSON(http.StatusOK, model.NewSuccessResponse(nil).WithRequestID(requestID))
+}
+
+// ResetPassword 使用重置密码邮件中的token设置新密码，账号所有设备需重新登录。
+func (h *AccountApiHandler) ResetPassword(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	args := model.ResetPasswordArgs{}
+	err := c.Bind(&args)
+	if err != nil || args.Token == "" {
+		responseErr := model.NewResponseErrorBadRequest()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	err = h.Account.ResetPassword(c.Request.Context(), xl, args.Token, args.Password)
+	if err != nil {
+		h.writePasswordError(c, xl, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.NewSuccessResponse(nil).WithRequestID(requestID))
+}
+
+// UpdatePassword 已登录用户设置或修改密码，其他设备上的会话需重新登录。
+func (h *AccountApiHandler) UpdatePassword(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	args := model.UpdatePasswordArgs{}
+	err := c.Bind(&args)
+	if err != nil {
+		responseErr := model.NewResponseErrorBadRequest()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	userID := c.GetString(model.UserIDContextKey)
+	err = h.Account.UpdatePassword(c.Request.Context(), xl, userID, c.GetString(model.SessionIDContextKey), args.OldPassword, args.NewPassword)
+	if err != nil {
+		h.writePasswordError(c, xl, err)
+		return
+	}
+	xl.Infof("user %s updated password", userID)
+	c.JSON(http.StatusOK, model.NewSuccessResponse(nil).WithRequestID(requestID))
+}
+
+// reserveMail 累加邮箱与请求方IP当天的邮件计数，超出限制时写入错误返回并返回false。
+func (h *AccountApiHandler) reserveMail(c *gin.Context, xl *xlog.Logger, email string) bool {
+	if h.PasswordLimit == nil {
+		return true
+	}
+	_, err := h.PasswordLimit.ReserveMail(xl, limitKeyOfEmail(email), middleware.ClientIP(c))
+	if err != nil {
+		h.writePasswordError(c, xl, err)
+		return false
+	}
+	return true
+}
+
+// sendEmailToken 签发一次性token并把带链接的邮件加入发送队列，发送失败只记录日志，用户可重新请求。
+func (h *AccountApiHandler) sendEmailToken(ctx context.Context, xl *xlog.Logger, account *model.AccountDo, purpose model.AccountEmailTokenPurpose) {
+	token, err := h.Account.CreateEmailToken(ctx, xl, account, purpose)
+	if err != nil {
+		xl.Errorf("failed to create %s token for account %s, error %v", purpose, account.ID, err)
+		return
+	}
+	var subject, body string
+	switch purpose {
+	case model.AccountEmailTokenVerifyEmail:
+		link := h.FrontendUrlHost + "/verify-email?token=" + url.QueryEscape(token)
+		subject = "牛魔方邮箱验证"
+		body = fmt.Sprintf("%s，您好：\n\n请点击以下链接验证您的邮箱：\n%s\n\n如果这不是您的操作，请忽略本邮件。", account.Nickname, link)
+	case model.AccountEmailTokenResetPassword:
+		link := h.FrontendUrlHost + "/reset-password?token=" + url.QueryEscape(token)
+		subject = "牛魔方重置密码"
+		body = fmt.Sprintf("%s，您好：\n\n请点击以下链接重置您的密码：\n%s\n\n如果这不是您的操作，请忽略本邮件，您的密码不会被修改。", account.Nickname, link)
+	}
+	err = h.Mail.SendMail(xl, []string{account.Email}, subject, body)
+	if err != nil {
+		xl.Errorf("failed to send %s mail to %s, error %v", purpose, account.Email, err)
+	}
+}
+
+// completeSignIn 账号校验通过后登录，返回登录token与IM配置。
+func (h *AccountApiHandler) completeSignIn(c *gin.Context, xl *xlog.Logger, account *model.AccountDo) {
+	requestID := xl.ReqId
+	device := middleware.FetchDeviceInfo(xl, requestID, c)
+	user, err := h.Account.AccountLogin(c.Request.Context(), xl, account.ID, "", device)
+	if err != nil {
+		xl.Errorf("failed to set account %s to status logged in, error %v", account.ID, err)
+		responseErr := model.NewResponseErrorInternal()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	imUser, err := h.AppConfigService.GetUserToken(c.Request.Context(), xl, user.AccountId)
+	if err != nil {
+		xl.Errorf("failed to call IM db to get token")
+		responseErr := model.NewResponseErrorExternalService()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	res := model.NewSuccessResponse(model.SignUpOrInResponse{
+		UserInfoResponse: model.UserInfoResponse{
+			ID:       account.ID,
+			Nickname: account.Nickname,
+			Avatar:   account.Avatar,
+			Profile:  string(model.DefaultAccountProfile),
+		},
+		LoginTokenResponse: newLoginTokenResponse(user),
+		ImConfigResponse: model.ImConfigResponse{
+			IMUsername: imUser.Username,
+			IMPassword: imUser.GetPassword(),
+			IMUid:      imUser.UserID,
+			Type:       int(model.ImTypeQiniu),
+		},
+	})
+	c.SetCookie(model.LoginTokenKey, user.Token, 0, "/", "niucube.qiniu.com", true, false)
+	c.JSON(http.StatusOK, res)
+}
+
+// writePasswordError 把邮箱、密码相关的错误转换为对应的返回码。
+func (h *AccountApiHandler) writePasswordError(c *gin.Context, xl *xlog.Logger, err error) {
+	responseErr := model.NewResponseErrorInternal()
+	if serverErr, ok := err.(*errors2.ServerError); ok {
+		switch serverErr.Code {
+		case errors2.ServerErrorEmailUsed:
+			responseErr = model.NewResponseErrorEmailUsed()
+		case errors2.ServerErrorWrongPassword:
+			responseErr = model.NewResponseErrorWrongPassword()
+		case errors2.ServerErrorEmailNotVerified:
+			responseErr = model.NewResponseErrorEmailNotVerified()
+		case errors2.ServerErrorPasswordTooWeak:
+			responseErr = model.NewResponseErrorBadRequest()
+			responseErr.Message = "password too weak"
+		case errors2.ServerErrorTokenInvalid:
+			responseErr = model.NewResponseErrorBadToken()
+		case errors2.ServerErrorTokenExpired:
+			responseErr = model.NewResponseErrorTokenExpired()
+		case errors2.ServerErrorLoginLocked:
+			responseErr = model.NewResponseErrorLoginLocked()
+		case errors2.ServerErrorMailQuotaExceeded:
+			responseErr = model.NewResponseErrorMailQuotaExceeded()
+		}
+	} else if err == mgo.ErrNotFound {
+		responseErr = model.NewResponseErrorNoSuchUser()
+	} else {
+		xl.Errorf("%s %s failed, error %v", c.Request.Method, c.Request.URL.Path, err)
+	}
+	resp := model.NewFailResponse(*responseErr).WithRequestID(xl.ReqId)
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *AccountApiHandler) generateNicknameByEmail(email string) string {
+	for i, ch := range email {
+		if ch == '@' {
+			return "用户_" + email[:i]
+		}
+	}
+	return "用户_" + email
+}
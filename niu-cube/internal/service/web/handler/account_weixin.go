@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/utils"
+	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
+	model "github.com/solutions/niu-cube/internal/protodef/model"
+	"github.com/solutions/niu-cube/internal/service/cloud"
+	"github.com/solutions/niu-cube/internal/service/web/middleware"
+)
+
+// WeixinAuthInterface 小程序登录用到的微信接口
+type WeixinAuthInterface interface {
+	Code2Session(ctx context.Context, xl *xlog.Logger, jsCode string) (*cloud.WeixinSession, error)
+}
+
+// SignInWithWeixin 小程序登录：使用wx.login得到的code换取openid，登录关联的账号，未关联时创建账号。
+// 同时提供getPhoneNumber返回的加密手机号时，绑定该手机号，手机号已注册时登录该手机号的账号。
+func (h *AccountApiHandler) SignInWithWeixin(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	args := model.WeixinSignInArgs{}
+	err := c.Bind(&args)
+	if err != nil || args.JsCode == "" {
+		xl.Infof("SignInWithWeixin: invalid args in body, error %v", err)
+		responseErr := model.NewResponseErrorBadRequest()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	session, err := h.Weixin.Code2Session(c.Request.Context(), xl, args.JsCode)
+	if err != nil {
+		responseErr := model.NewResponseErrorExternalService()
+		if serverErr, ok := err.(*errors2.ServerError); ok && serverErr.Code == errors2.ServerErrorWeixinCodeInvalid {
+			responseErr = model.NewResponseErrorWeixinCodeInvalid()
+		}
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	phone := ""
+	if args.EncryptedData != "" {
+		phoneNumber, err := cloud.DecryptWeixinPhoneNumber(h.WeixinAppID, session.SessionKey, args.EncryptedData, args.IV)
+		if err != nil {
+			xl.Infof("SignInWithWeixin: failed to decrypt phone number, error %v", err)
+			responseErr := model.NewResponseErrorInvalidPhoneData()
+			resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+		var ok bool
+		phone, ok = normalizePhone("+" + phoneNumber.CountryCode + phoneNumber.PurePhoneNumber)
+		if !ok {
+			xl.Infof("SignInWithWeixin: invalid phone number %s", phoneNumber.PhoneNumber)
+			responseErr := model.NewResponseErrorInvalidPhone()
+			resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+	}
+	newAccount := &model.AccountDo{
+		ID:         utils.GenerateID(),
+		Avatar:     h.generateInitialAvatar(),
+		RegisterIP: middleware.ClientIP(c),
+	}
+	// 未绑定手机号时使用账号ID生成昵称。
+	if phone != "" {
+		newAccount.Nickname = h.generateNicknameByPhone(phone)
+	} else {
+		newAccount.Nickname = h.generateNicknameByPhone(newAccount.ID)
+	}
+	account, created, err := h.Account.GetOrCreateAccountByWeixin(c.Request.Context(), xl, session.OpenID, session.UnionID, phone, newAccount)
+	if err != nil {
+		responseErr := model.NewResponseErrorInternal()
+		if serverErr, ok := err.(*errors2.ServerError); ok && serverErr.Code == errors2.ServerErrorPhoneUsed {
+			responseErr = model.NewResponseErrorPhoneUsed()
+		} else {
+			xl.Errorf("SignInWithWeixin: failed to get or create account for weixin user %s, error %v", session.OpenID, err)
+		}
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	if created {
+		xl.Infof("SignInWithWeixin: account %s created for weixin user %s", account.ID, session.OpenID)
+		h.initBaseUser(c.Request.Context(), xl, account)
+	}
+	h.actionLog(c).UserInfo(fmt.Sprintf("weixin user %s", session.OpenID))
+	h.completeSignIn(c, xl, account)
+}
+
+// initBaseUser 为新创建的账号创建通用用户信息并同步考试列表。账号已创建，失败时只记录日志，
+// 可通过同步接口补齐，不影响本次登录。
+func (h *AccountApiHandler) initBaseUser(ctx context.Context, xl *xlog.Logger, account *model.AccountDo) {
+	baseUser := model.BaseUserDo{
+		Id:            account.ID,
+		Name:          account.Nickname,
+		Nickname:      account.Nickname,
+		Avatar:        account.Avatar,
+		Status:        model.BaseUserLogin,
+		Profile:       "",
+		BaseUserAttrs: make([]model.BaseEntryDo, 0, 0),
+	}
+	_, err := h.BaseUserDao.Insert(ctx, xl, &baseUser)
+	if err != nil {
+		xl.Errorf("failed to create base user for account %s, error %v", account.ID, err)
+	}
+	err = h.ExamService.SyncExamList(ctx, baseUser.Id)
+	if err != nil {
+		xl.Errorf("failed to sync exam list for account %s, error %v", account.ID, err)
+	}
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiniu/x/xlog"
+	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+	"github.com/solutions/niu-cube/internal/service/cloud"
+	"github.com/solutions/niu-cube/internal/service/dao"
+	"github.com/solutions/niu-cube/internal/service/db"
+)
+
+type fakeWeixin struct {
+	session *cloud.WeixinSession
+	err     error
+}
+
+func (f *fakeWeixin) Code2Session(ctx context.Context, xl *xlog.Logger, jsCode string) (*cloud.WeixinSession, error) {
+	return f.session, f.err
+}
+
+// fakeWeixinAccounts 只实现小程序登录用到的账号接口。
+type fakeWeixinAccounts struct {
+	AccountInterface
+	account *model.AccountDo
+	created bool
+	err     error
+	openID  string
+}
+
+func (f *fakeWeixinAccounts) GetOrCreateAccountByWeixin(ctx context.Context, xl *xlog.Logger, openID string, unionID string, phone string, newAccount *model.AccountDo) (*model.AccountDo, bool, error) {
+	f.openID = openID
+	if f.err != nil {
+		return nil, false, f.err
+	}
+	if f.created {
+		return newAccount, true, nil
+	}
+	return f.account, false, nil
+}
+
+func (f *fakeWeixinAccounts) AccountLogin(ctx context.Context, xl *xlog.Logger, id string, sessionID string, device model.DeviceInfo) (*model.AccountTokenDo, error) {
+	return &model.AccountTokenDo{AccountId: id, Token: "token-" + id, RefreshToken: "refresh-" + id, ExpireAt: time.Now().Add(time.Hour)}, nil
+}
+
+type fakeIMConfig struct {
+	db.AppConfigInterface
+}
+
+func (f *fakeIMConfig) GetUserToken(ctx context.Context, xl *xlog.Logger, userID string) (*model.IMUserDo, error) {
+	return &model.IMUserDo{UserID: "im-" + userID, Username: userID, Password: "p", Salt: "s"}, nil
+}
+
+// failingBaseUsers 创建通用用户信息总是失败。
+type failingBaseUsers struct {
+	dao.BaseUserDaoInterface
+	inserts int
+}
+
+func (f *failingBaseUsers) Insert(ctx context.Context, xl *xlog.Logger, baseUserDo *model.BaseUserDo) (*model.BaseUserDo, error) {
+	f.inserts++
+	return nil, errors.New("insert failed")
+}
+
+type failingExamSync struct {
+	ExamApi
+	syncs int
+}
+
+func (f *failingExamSync) SyncExamList(ctx context.Context, userId string) error {
+	f.syncs++
+	return errors.New("sync failed")
+}
+
+func TestSignInWithWeixin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	session := &cloud.WeixinSession{OpenID: "openid-1", SessionKey: "c2Vzc2lvbi1rZXktMTIzNA=="}
+	existing := &model.AccountDo{ID: "existing", Nickname: "old user"}
+	cases := []struct {
+		name        string
+		body        model.WeixinSignInArgs
+		weixin      *fakeWeixin
+		accounts    *fakeWeixinAccounts
+		wantCode    int
+		wantUserID  string
+		wantInserts int
+	}{
+		{name: "missing code", body: model.WeixinSignInArgs{}, weixin: &fakeWeixin{session: session}, accounts: &fakeWeixinAccounts{}, wantCode: model.ResponseErrorBadRequest},
+		{name: "invalid code", body: model.WeixinSignInArgs{JsCode: "bad"}, weixin: &fakeWeixin{err: &errors2.ServerError{Code: errors2.ServerErrorWeixinCodeInvalid}}, accounts: &fakeWeixinAccounts{}, wantCode: model.ResponseErrorWeixinCodeInvalid},
+		{name: "weixin unavailable", body: model.WeixinSignInArgs{JsCode: "code"}, weixin: &fakeWeixin{err: errors.New("timeout")}, accounts: &fakeWeixinAccounts{}, wantCode: model.ResponseErrorExternalService},
+		{name: "invalid phone data", body: model.WeixinSignInArgs{JsCode: "code", EncryptedData: "garbage", IV: "garbage"}, weixin: &fakeWeixin{session: session}, accounts: &fakeWeixinAccounts{}, wantCode: model.ResponseErrorInvalidPhoneData},
+		{name: "phone used", body: model.WeixinSignInArgs{JsCode: "code"}, weixin: &fakeWeixin{session: session}, accounts: &fakeWeixinAccounts{err: &errors2.ServerError{Code: errors2.ServerErrorPhoneUsed}}, wantCode: model.ResponseErrorPhoneUsed},
+		{name: "existing account", body: model.WeixinSignInArgs{JsCode: "code"}, weixin: &fakeWeixin{session: session}, accounts: &fakeWeixinAccounts{account: existing}, wantUserID: existing.ID},
+		// 创建通用用户信息与同步考试失败时只记录日志，仍然登录成功。
+		{name: "new account", body: model.WeixinSignInArgs{JsCode: "code"}, weixin: &fakeWeixin{session: session}, accounts: &fakeWeixinAccounts{created: true}, wantInserts: 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			baseUsers := &failingBaseUsers{}
+			exams := &failingExamSync{}
+			h := &AccountApiHandler{
+				Account:          tc.accounts,
+				AppConfigService: &fakeIMConfig{},
+				BaseUserDao:      baseUsers,
+				ExamService:      exams,
+				Weixin:           tc.weixin,
+				WeixinAppID:      "wx-app",
+			}
+			body, _ := json.Marshal(tc.body)
+			recorder := httptest.NewRecorder()
+			router := gin.New()
+			router.POST("/signin", func(c *gin.Context) {
+				c.Set(model.XLogKey, xlog.New("test-weixin"))
+			}, h.SignInWithWeixin)
+			req := httptest.NewRequest(http.MethodPost, "/signin", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(recorder, req)
+
+			resp := struct {
+				Code int                      `json:"code"`
+				Data model.SignUpOrInResponse `json:"data"`
+			}{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid response %q: %v", recorder.Body.String(), err)
+			}
+			if tc.wantCode != 0 {
+				if resp.Code != tc.wantCode {
+					t.Fatalf("code = %d, want %d", resp.Code, tc.wantCode)
+				}
+				return
+			}
+			if resp.Code != int(model.ResponseStatusCodeSuccess) || resp.Data.Token == "" || tc.accounts.openID != session.OpenID {
+				t.Fatalf("sign in failed, response %s", recorder.Body.String())
+			}
+			if tc.wantUserID != "" && resp.Data.ID != tc.wantUserID {
+				t.Fatalf("signed in as %s, want %s", resp.Data.ID, tc.wantUserID)
+			}
+			if baseUsers.inserts != tc.wantInserts || exams.syncs != tc.wantInserts {
+				t.Fatalf("base user inserts %d, exam syncs %d, want %d", baseUsers.inserts, exams.syncs, tc.wantInserts)
+			}
+		})
+	}
+}
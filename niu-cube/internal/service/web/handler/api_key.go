@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+	"github.com/solutions/niu-cube/internal/service/db"
+	"gopkg.in/mgo.v2"
+)
+
+type ApiKeyInterface interface {
+	// CreateApiKey 保存API key，返回key原文
+	CreateApiKey(ctx context.Context, xl *xlog.Logger, apiKey *model.ApiKeyDo) (string, error)
+
+	ListApiKeys(ctx context.Context, xl *xlog.Logger) ([]model.ApiKeyDo, error)
+
+	// RotateApiKey 生成新的secret，返回新的key原文
+	RotateApiKey(ctx context.Context, xl *xlog.Logger, id string) (*model.ApiKeyDo, string, error)
+
+	RevokeApiKey(ctx context.Context, xl *xlog.Logger, id string) error
+}
+
+// ApiKeyApiHandler 管理员维护服务端调用使用的API key。
+type ApiKeyApiHandler struct {
+	ApiKey ApiKeyInterface
+}
+
+func NewApiKeyApiHandler(apiKey ApiKeyInterface) *ApiKeyApiHandler {
+	return &ApiKeyApiHandler{ApiKey: apiKey}
+}
+
+// CreateApiKey 创建API key，返回的key原文只出现这一次。
+func (h *ApiKeyApiHandler) CreateApiKey(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	if !h.checkNotApiKey(c, xl) {
+		return
+	}
+	args := model.CreateApiKeyArgs{}
+	err := c.Bind(&args)
+	if err != nil || strings.TrimSpace(args.Name) == "" || len(args.Permissions) == 0 || args.ExpireSecond < 0 {
+		xl.Infof("CreateApiKey: invalid args, error %v", err)
+		responseErr := model.NewResponseErrorBadRequest()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	for _, permission := range args.Permissions {
+		// API key不能再创建API key。
+		if !model.IsValidPermission(permission) || permission == model.PermissionApiKeyManage {
+			responseErr := model.NewResponseErrorBadRequest()
+			resp := model.NewFailResponse(*responseErr).WithRequestID(requestID).WithErrorMessage("invalid permission " + string(permission))
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+	}
+	if !db.ValidIPAllowlist(args.AllowedIPs) {
+		responseErr := model.NewResponseErrorBadRequest()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID).WithErrorMessage("invalid ip allowlist")
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	apiKey := &model.ApiKeyDo{
+		Name:        strings.TrimSpace(args.Name),
+		Permissions: args.Permissions,
+		AllowedIPs:  args.AllowedIPs,
+		Creator:     c.GetString(model.UserIDContextKey),
+	}
+	if args.ExpireSecond > 0 {
+		apiKey.ExpireTime = time.Now().Add(time.Duration(args.ExpireSecond) * time.Second)
+	}
+	key, err := h.ApiKey.CreateApiKey(c.Request.Context(), xl, apiKey)
+	if err != nil {
+		responseErr := model.NewResponseErrorInternal()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	xl.Infof("user %s created api key %s with permissions %v", apiKey.Creator, apiKey.ID, apiKey.Permissions)
+	c.JSON(http.StatusOK, model.NewSuccessResponse(model.ApiKeyResponse{ApiKeyDo: *apiKey, Key: key}).WithRequestID(requestID))
+}
+
+// ListApiKeys 列出所有API key，不包含key原文。
+func (h *ApiKeyApiHandler) ListApiKeys(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	apiKeys, err := h.ApiKey.ListApiKeys(c.Request.Context(), xl)
+	if err != nil {
+		responseErr := model.NewResponseErrorInternal()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	c.JSON(http.StatusOK, model.NewSuccessResponse(model.ApiKeyListResponse{List: apiKeys}).WithRequestID(requestID))
+}
+
+// RotateApiKey 轮换API key的secret，旧key立即失效。
+func (h *ApiKeyApiHandler) RotateApiKey(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	if !h.checkNotApiKey(c, xl) {
+		return
+	}
+	apiKey, key, err := h.ApiKey.RotateApiKey(c.Request.Context(), xl, c.Param("keyId"))
+	if err != nil {
+		h.writeApiKeyError(c, xl, err)
+		return
+	}
+	xl.Infof("user %s rotated api key %s", c.GetString(model.UserIDContextKey), apiKey.ID)
+	c.JSON(http.StatusOK, model.NewSuccessResponse(model.ApiKeyResponse{ApiKeyDo: *apiKey, Key: key}).WithRequestID(requestID))
+}
+
+// RevokeApiKey 吊销API key。
+func (h *ApiKeyApiHandler) RevokeApiKey(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	keyID := c.Param("keyId")
+	err := h.ApiKey.RevokeApiKey(c.Request.Context(), xl, keyID)
+	if err != nil {
+		h.writeApiKeyError(c, xl, err)
+		return
+	}
+	xl.Infof("user %s revoked api key %s", c.GetString(model.UserIDContextKey), keyID)
+	c.JSON(http.StatusOK, model.NewSuccessResponse(nil).WithRequestID(requestID))
+}
+
+// checkNotApiKey 创建与轮换API key只能由登录的管理员操作，避免泄露的key自我续期。
+func (h *ApiKeyApiHandler) checkNotApiKey(c *gin.Context, xl *xlog.Logger) bool {
+	if _, ok := c.Get(model.ApiKeyContextKey); ok {
+		responseErr := model.NewResponseErrorPermissionDenied()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(xl.ReqId)
+		c.JSON(http.StatusOK, resp)
+		return false
+	}
+	return true
+}
+
+func (h *ApiKeyApiHandler) writeApiKeyError(c *gin.Context, xl *xlog.Logger, err error) {
+	responseErr := model.NewResponseErrorInternal()
+	if err == mgo.ErrNotFound {
+		responseErr = model.NewResponseErrorNotFound()
+	}
+	resp := model.NewFailResponse(*responseErr).WithRequestID(xl.ReqId)
+	c.JSON(http.StatusOK, resp)
+}
@@ -18,13 +18,14 @@ type AppConfigApiHandler struct {
 
 func (h *AppConfigApiHandler) GetAppConfig(c *gin.Context) {
 
+	conf := utils.Reloadable()
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
 		Message: string(model.ResponseStatusMessageSuccess),
 		Data: model.AppConfigResponse{
 			WelcomeResponse: model.WelcomeResponse{
-				Image: utils.DefaultConf.WelcomeImage,
-				Url:   utils.DefaultConf.WelcomeURL,
+				Image: conf.WelcomeImage,
+				Url:   conf.WelcomeURL,
 			},
 		},
 	}
@@ -35,11 +36,12 @@ func (h *AppConfigApiHandler) GetAppConfig(c *gin.Context) {
 func (h *AppConfigApiHandler) SolutionList(c *gin.Context) {
 	mobileOs, isMobile := c.Get(model.UAContextKey)
 	apiVersion, hasApiVersion := c.Get(model.RequestApiVersion)
-	solutionResponseList := utils.DefaultConf.Solutions
+	conf := utils.Reloadable()
+	solutionResponseList := conf.Solutions
 	if isMobile && mobileOs == model.UAMobileApple && hasApiVersion && apiVersion == model.ApiVersionV1 {
-		solutionResponseList = utils.DefaultConf.Solutions4Apple
+		solutionResponseList = conf.Solutions4Apple
 	} else if isMobile && mobileOs == model.UAMobileAndroid && hasApiVersion && apiVersion == model.ApiVersionV1 {
-		solutionResponseList = utils.DefaultConf.Solutions4Android
+		solutionResponseList = conf.Solutions4Android
 	}
 	var solutionList = make([]interface{}, len(solutionResponseList))
 	for index, solutionObj := range solutionResponseList {
@@ -36,10 +36,10 @@ func (a *AppVersionApiHandler) UpdateAppVersion(context *gin.Context) {
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	err := a.appVersionDao.InsertAppVersion(&req)
+	err := a.appVersionDao.InsertAppVersion(context.Request.Context(), &req)
 	if err != nil {
 		xl.Errorf("insert app version error: %v", err)
-		responseErr := model.NewResponseErrorInternal()
+		responseErr := model.NewResponseErrorDatabase(err)
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 		context.JSON(http.StatusOK, resp)
 		return
@@ -62,10 +62,10 @@ func (a *AppVersionApiHandler) GetNewestAppVersion(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	version := context.Query("version")
 	arch := context.Query("arch")
-	appVersion, err := a.appVersionDao.GetNewestAppVersion(arch)
+	appVersion, err := a.appVersionDao.GetNewestAppVersion(context.Request.Context(), arch)
 	if err != nil {
 		xl.Errorf("get newest app version error: %v", err)
-		responseErr := model.NewResponseErrorInternal()
+		responseErr := model.NewResponseErrorDatabase(err)
 		resp := model.NewFailResponse(*responseErr).WithRequestID(xl.ReqId)
 		context.JSON(http.StatusOK, resp)
 		return
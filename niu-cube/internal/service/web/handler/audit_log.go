@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+	"github.com/solutions/niu-cube/internal/service/db"
+)
+
+type AuditLogInterface interface {
+	// ListAuditLogs 按条件分页查询审计日志，返回记录与总数
+	ListAuditLogs(xl *xlog.Logger, filter model.AuditLogFilter, pageNum, pageSize int) ([]model.AuditLogDo, int, error)
+}
+
+// AuditLogApiHandler 管理员查询审计日志。
+type AuditLogApiHandler struct {
+	AuditLog AuditLogInterface
+}
+
+func NewAuditLogApiHandler(auditLog AuditLogInterface) *AuditLogApiHandler {
+	return &AuditLogApiHandler{AuditLog: auditLog}
+}
+
+// ListAuditLogs 按用户、路由、状态码、资源与时间范围查询审计日志，时间使用RFC3339格式，按时间倒序分页返回。
+func (h *AuditLogApiHandler) ListAuditLogs(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	filter := model.AuditLogFilter{}
+	err := c.ShouldBindQuery(&filter)
+	if err != nil || (!filter.StartTime.IsZero() && !filter.EndTime.IsZero() && !filter.StartTime.Before(filter.EndTime)) {
+		xl.Infof("ListAuditLogs: invalid args, error %v", err)
+		responseErr := model.NewResponseErrorBadRequest()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	pageNum := c.GetInt(model.PageNumContextKey)
+	if pageNum < 1 {
+		pageNum = 1
+	}
+	pageSize := c.GetInt(model.PageSizeContextKey)
+	if pageSize < 1 || pageSize > db.AuditLogMaxPageSize {
+		pageSize = db.AuditLogMaxPageSize
+	}
+	records, total, err := h.AuditLog.ListAuditLogs(xl, filter, pageNum, pageSize)
+	if err != nil {
+		responseErr := model.NewResponseErrorInternal()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	endPage := pageNum*pageSize >= total
+	nextPageNum := pageNum + 1
+	if endPage {
+		nextPageNum = pageNum
+	}
+	c.JSON(http.StatusOK, model.NewSuccessResponse(model.ListAuditLogsResponse{
+		List:           records,
+		Total:          total,
+		Cnt:            len(records),
+		CurrentPageNum: pageNum,
+		NextPageNum:    nextPageNum,
+		PageSize:       pageSize,
+		EndPage:        endPage,
+	}).WithRequestID(requestID))
+}
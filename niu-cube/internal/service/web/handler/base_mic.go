@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 	"github.com/fatih/color"
 	"github.com/solutions/niu-cube/internal/service/cloud"
@@ -9,8 +10,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/qiniu/x/xlog"
+	"gopkg.in/mgo.v2"
 
 	"github.com/solutions/niu-cube/internal/common/utils"
+	"github.com/solutions/niu-cube/internal/protodef/form"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	dao2 "github.com/solutions/niu-cube/internal/service/dao"
 )
@@ -75,71 +78,22 @@ func (b *BaseMicApiHandler) UpMic(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
 	userId := context.GetString(model.UserIDContextKey)
-	var input map[string]interface{}
-	err := context.Bind(&input)
-	if err != nil {
-		xl.Infof("invalid args in body, error: %v", err)
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
-		return
-	}
-	var roomId string
-	var roomType string
-	var userExtension string
-	attrs := make([]model.BaseEntryDo, 0, 1)
-	params := make([]model.BaseEntryDo, 0, 1)
-	if roomId0, ok := input["roomId"].(string); ok {
-		roomId = roomId0
-	} else {
-		xl.Infof("miss roomId in body.")
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
+	args := &form.BaseMicUpForm{}
+	if !bindBaseForm(context, xl, args) {
 		return
 	}
-	if roomType0, ok := input["type"].(string); ok {
-		roomType = roomType0
-	} else {
-		xl.Infof("miss roomType in body.")
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
-		return
-	}
-	if userExtension0, ok := input["userExtension"].(string); ok {
-		userExtension = userExtension0
-	} else {
-	}
-	if attrs0, ok := input["attrs"].([]interface{}); ok {
-		for _, val0 := range attrs0 {
-			val := val0.(map[string]interface{})
-			entry := model.BaseEntryDo{
-				Key:    val["key"].(string),
-				Value:  val["value"],
-				Status: model.BaseEntryAvailable,
-			}
-			attrs = append(attrs, entry)
-		}
-	}
-	if params0, ok := input["params"].([]interface{}); ok {
-		for _, val0 := range params0 {
-			val := val0.(map[string]interface{})
-			entry := model.BaseEntryDo{
-				Key:    val["key"].(string),
-				Value:  val["value"],
-				Status: 0,
-			}
-			params = append(params, entry)
-		}
-	}
+	roomId := args.RoomId
+	roomType := args.Type
+	userExtension := args.UserExtension
+	attrs := form.BaseEntries(args.Attrs, model.BaseEntryAvailable)
+	params := form.BaseEntries(args.Params, 0)
 	color.Blue("用户: %s 上 %s 的麦位", userId, roomId)
 	// 以上都是参数处理
-	b.sync(roomId)
-	userMics, err := b.baseUserMicDao.ListByRoomId(xl, roomId)
+	b.sync(context.Request.Context(), xl, roomId)
+	userMics, err := b.baseUserMicDao.ListByRoomId(context.Request.Context(), xl, roomId)
 	if err != nil {
 		xl.Errorf("select base_user_mic all fail with userId: %s", userId)
-		responseErr := model.NewResponseErrorInternal()
+		responseErr := model.NewResponseErrorDatabase(err)
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 		context.JSON(http.StatusOK, resp)
 		return
@@ -152,10 +106,10 @@ func (b *BaseMicApiHandler) UpMic(context *gin.Context) {
 		}
 	}
 	if !alreadyUpMic {
-		roomTmp, err := b.baseRoomDao.Select(xl, roomId)
+		roomTmp, err := b.baseRoomDao.Select(context.Request.Context(), xl, roomId)
 		if err != nil {
 			xl.Errorf("select base_room fail with roomId: %s", roomId)
-			responseErr := model.NewResponseErrorInternal()
+			responseErr := model.NewResponseErrorDatabase(err)
 			resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 			context.JSON(http.StatusOK, resp)
 			return
@@ -164,10 +118,10 @@ func (b *BaseMicApiHandler) UpMic(context *gin.Context) {
 		case model.BaseTypeKtv, model.BaseTypeMovie:
 			var roomMic model.BaseRoomMicDo
 			flag := false
-			roomMics, err := b.baseRoomMicDao.ListByRoomId(xl, roomId)
+			roomMics, err := b.baseRoomMicDao.ListByRoomId(context.Request.Context(), xl, roomId)
 			if err != nil {
 				xl.Errorf("select base_room_mic all fail with roomId: %s", roomId)
-				responseErr := model.NewResponseErrorInternal()
+				responseErr := model.NewResponseErrorDatabase(err)
 				resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 				context.JSON(http.StatusOK, resp)
 				return
@@ -176,7 +130,11 @@ func (b *BaseMicApiHandler) UpMic(context *gin.Context) {
 			if roomTmp.Creator == userId {
 				for _, val := range roomMics {
 					if val.Status == model.BaseRoomMicUnused {
-						mic, _ := b.baseMicDao.Select(xl, val.MicId)
+						mic, err := b.baseMicDao.Select(context.Request.Context(), xl, val.MicId)
+						if err != nil {
+							writeDatabaseError(context, xl, err)
+							return
+						}
 						if mic.Type == model.BaseMicTypeMain {
 							roomMic = val
 							flag = true
@@ -188,7 +146,11 @@ func (b *BaseMicApiHandler) UpMic(context *gin.Context) {
 			} else {
 				for _, val := range roomMics {
 					if val.Status == model.BaseRoomMicUnused {
-						mic, _ := b.baseMicDao.Select(xl, val.MicId)
+						mic, err := b.baseMicDao.Select(context.Request.Context(), xl, val.MicId)
+						if err != nil {
+							writeDatabaseError(context, xl, err)
+							return
+						}
 						if mic.Type == model.BaseMicTypeSecondary {
 							roomMic = val
 							flag = true
@@ -213,9 +175,9 @@ func (b *BaseMicApiHandler) UpMic(context *gin.Context) {
 				return
 			} else {
 				roomMic.Status = model.BaseRoomMicUsed
-				err = b.baseRoomMicDao.Update(xl, &roomMic)
+				err = b.baseRoomMicDao.Update(context.Request.Context(), xl, &roomMic)
 				if err != nil {
-					// TODO
+					writeDatabaseError(context, xl, err)
 					return
 				}
 				userMic := model.BaseUserMicDo{
@@ -225,24 +187,39 @@ func (b *BaseMicApiHandler) UpMic(context *gin.Context) {
 					Status:        model.BaseUserMicHold,
 					UserExtension: userExtension,
 				}
-				_, err = b.baseUserMicDao.Insert(xl, &userMic)
+				_, err = b.baseUserMicDao.Insert(context.Request.Context(), xl, &userMic)
+				if err != nil {
+					writeDatabaseError(context, xl, err)
+					return
+				}
+				mic, err := b.baseMicDao.Select(context.Request.Context(), xl, roomMic.MicId)
 				if err != nil {
-					// TODO
+					writeDatabaseError(context, xl, err)
 					return
 				}
-				mic, _ := b.baseMicDao.Select(xl, roomMic.MicId)
 				mic.BaseMicAttrs = attrs
 				mic.BaseMicParams = params
-				_ = b.baseMicDao.Update(xl, mic)
+				if err := b.baseMicDao.Update(context.Request.Context(), xl, mic); err != nil {
+					writeDatabaseError(context, xl, err)
+					return
+				}
 			}
 		case model.BaseTypeClassroom, model.BaseTypeShow, model.BaseTypeExam, model.BaseTypeVoiceChat:
 			if roomTmp.Creator == userId {
 				var roomMic model.BaseRoomMicDo
 				flag := false
-				roomMics, _ := b.baseRoomMicDao.ListByRoomId(xl, roomId)
+				roomMics, err := b.baseRoomMicDao.ListByRoomId(context.Request.Context(), xl, roomId)
+				if err != nil {
+					writeDatabaseError(context, xl, err)
+					return
+				}
 				for _, val := range roomMics {
 					if val.Status == model.BaseRoomMicUnused {
-						mic, _ := b.baseMicDao.Select(xl, val.MicId)
+						mic, err := b.baseMicDao.Select(context.Request.Context(), xl, val.MicId)
+						if err != nil {
+							writeDatabaseError(context, xl, err)
+							return
+						}
 						if mic.Type == model.BaseMicTypeMain {
 							roomMic = val
 							flag = true
@@ -266,9 +243,9 @@ func (b *BaseMicApiHandler) UpMic(context *gin.Context) {
 					return
 				} else {
 					roomMic.Status = model.BaseRoomMicUsed
-					err = b.baseRoomMicDao.Update(xl, &roomMic)
+					err = b.baseRoomMicDao.Update(context.Request.Context(), xl, &roomMic)
 					if err != nil {
-						// TODO
+						writeDatabaseError(context, xl, err)
 						return
 					}
 					userMic := model.BaseUserMicDo{
@@ -278,15 +255,22 @@ func (b *BaseMicApiHandler) UpMic(context *gin.Context) {
 						Status:        model.BaseUserMicHold,
 						UserExtension: userExtension,
 					}
-					_, err = b.baseUserMicDao.Insert(xl, &userMic)
+					_, err = b.baseUserMicDao.Insert(context.Request.Context(), xl, &userMic)
+					if err != nil {
+						writeDatabaseError(context, xl, err)
+						return
+					}
+					mic, err := b.baseMicDao.Select(context.Request.Context(), xl, roomMic.MicId)
 					if err != nil {
-						// TODO
+						writeDatabaseError(context, xl, err)
 						return
 					}
-					mic, _ := b.baseMicDao.Select(xl, roomMic.MicId)
 					mic.BaseMicAttrs = attrs
 					mic.BaseMicParams = params
-					_ = b.baseMicDao.Update(xl, mic)
+					if err := b.baseMicDao.Update(context.Request.Context(), xl, mic); err != nil {
+						writeDatabaseError(context, xl, err)
+						return
+					}
 				}
 			} else {
 				mic := model.BaseMicDo{
@@ -296,8 +280,9 @@ func (b *BaseMicApiHandler) UpMic(context *gin.Context) {
 					BaseMicAttrs:  attrs,
 					BaseMicParams: params,
 				}
-				_, err := b.baseMicDao.InsertBaseMic(xl, &mic)
+				_, err := b.baseMicDao.InsertBaseMic(context.Request.Context(), xl, &mic)
 				if err != nil {
+					writeDatabaseError(context, xl, err)
 					return
 				}
 				userMic := model.BaseUserMicDo{
@@ -307,9 +292,9 @@ func (b *BaseMicApiHandler) UpMic(context *gin.Context) {
 					Status:        model.BaseUserMicHold,
 					UserExtension: userExtension,
 				}
-				_, err = b.baseUserMicDao.Insert(xl, &userMic)
+				_, err = b.baseUserMicDao.Insert(context.Request.Context(), xl, &userMic)
 				if err != nil {
-					// TODO
+					writeDatabaseError(context, xl, err)
 					return
 				}
 				roomMic := model.BaseRoomMicDo{
@@ -318,10 +303,10 @@ func (b *BaseMicApiHandler) UpMic(context *gin.Context) {
 					Index:  -1,
 					Status: model.BaseRoomMicUsed,
 				}
-				_, err = b.baseRoomMicDao.Insert(xl, &roomMic)
+				_, err = b.baseRoomMicDao.Insert(context.Request.Context(), xl, &roomMic)
 				if err != nil {
 					xl.Errorf("insert base_room_mic fail with roomId: %s", roomId)
-					responseErr := model.NewResponseErrorInternal()
+					responseErr := model.NewResponseErrorDatabase(err)
 					resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 					context.JSON(http.StatusOK, resp)
 					return
@@ -330,17 +315,21 @@ func (b *BaseMicApiHandler) UpMic(context *gin.Context) {
 		}
 	}
 	// 构建返回值
-	userMics, err = b.baseUserMicDao.ListByRoomId(xl, roomId)
+	userMics, err = b.baseUserMicDao.ListByRoomId(context.Request.Context(), xl, roomId)
 	if err != nil {
 		xl.Errorf("select base_user_mic all fail with userId: %s", userId)
-		responseErr := model.NewResponseErrorInternal()
+		responseErr := model.NewResponseErrorDatabase(err)
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 		context.JSON(http.StatusOK, resp)
 		return
 	}
 	mics := make([]model.MicInfo, 0, len(userMics))
 	for _, val := range userMics {
-		baseMicDo, _ := b.baseMicDao.Select(xl, val.MicId)
+		baseMicDo, err := b.baseMicDao.Select(context.Request.Context(), xl, val.MicId)
+		if err != nil && err != mgo.ErrNotFound {
+			writeDatabaseError(context, xl, err)
+			return
+		}
 		micInfo := model.MicInfo{
 			Uid:           val.UserId,
 			UserExtension: val.UserExtension,
@@ -377,57 +366,25 @@ func (b *BaseMicApiHandler) DownMic(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
 	// userId := context.GetString(model.UserIDContextKey)
-	var input map[string]interface{}
-	err := context.Bind(&input)
-	if err != nil {
-		xl.Infof("invalid args in body, error: %v", err)
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
-		return
-	}
-	var roomId string
-	var userId string
-	var roomType string
-	if roomId0, ok := input["roomId"].(string); ok {
-		roomId = roomId0
-	} else {
-		xl.Info("miss roomId in body.")
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
-		return
-	}
-	if micId0, ok := input["uid"].(string); ok {
-		userId = micId0
-	} else {
-		xl.Info("miss userId in body.")
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
-		return
-	}
-	if roomType0, ok := input["type"].(string); ok {
-		roomType = roomType0
-	} else {
-		xl.Info("miss roomType in body.")
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
+	args := &form.BaseMicDownForm{}
+	if !bindBaseForm(context, xl, args) {
 		return
 	}
+	roomId := args.RoomId
+	userId := args.Uid
+	roomType := args.Type
 	// 以上都是参数处理
-	b.sync(roomId)
+	b.sync(context.Request.Context(), xl, roomId)
 	// 特例化处理
 	if roomType == "" {
 	}
-	userMic, _ := b.baseUserMicDao.SelectByRoomIdUserId(xl, roomId, userId)
+	userMic, _ := b.baseUserMicDao.SelectByRoomIdUserId(context.Request.Context(), xl, roomId, userId)
 	if userMic != nil {
-		roomMic, _ := b.baseRoomMicDao.Select(xl, roomId, userMic.MicId)
+		roomMic, _ := b.baseRoomMicDao.Select(context.Request.Context(), xl, roomId, userMic.MicId)
 		userMic.Status = model.BaseUserMicNonHold
-		_ = b.baseUserMicDao.Update(xl, userMic)
+		_ = b.baseUserMicDao.Update(context.Request.Context(), xl, userMic)
 		roomMic.Status = model.BaseRoomMicUnused
-		_ = b.baseRoomMicDao.Update(xl, roomMic)
+		_ = b.baseRoomMicDao.Update(context.Request.Context(), xl, roomMic)
 	} else {
 		xl.Error("未找到相关user_mic")
 	}
@@ -444,76 +401,40 @@ func (b *BaseMicApiHandler) UpdateMicAttrs(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
 	// userId := context.GetString(model.UserIDContextKey)
-	var input map[string]interface{}
-	err := context.Bind(&input)
-	if err != nil {
-		xl.Infof("invalid args in body, error: %v", err)
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
-		return
-	}
-	var roomId string
-	var userId string
-	var roomType string
-	var entries []model.BaseEntryDo
-	if roomId0, ok := input["roomId"].(string); ok {
-		roomId = roomId0
-	} else {
-		xl.Infof("miss roomId in body.")
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
-		return
-	}
-	if micId0, ok := input["uid"].(string); ok {
-		userId = micId0
-	} else {
-		xl.Infof("miss userId in body.")
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
-		return
-	}
-	if roomType0, ok := input["type"].(string); ok {
-		roomType = roomType0
-	} else {
-		xl.Infof("miss roomType in body.")
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
+	args := &form.BaseMicUpdateForm{}
+	if !bindBaseForm(context, xl, args) {
 		return
 	}
+	roomId := args.RoomId
+	userId := args.Uid
+	roomType := args.Type
 	// 以上都是参数处理
-	b.sync(roomId)
+	b.sync(context.Request.Context(), xl, roomId)
 	// 特例化处理
 	if roomType == "" {
 	}
-	if entries0, ok := input["attrs"].([]interface{}); ok {
-		entries = make([]model.BaseEntryDo, 0, len(entries0))
-		for _, val0 := range entries0 {
-			val := val0.(map[string]interface{})
-			entry := model.BaseEntryDo{
-				Key:    val["key"].(string),
-				Value:  val["value"],
-				Status: model.BaseEntryAvailable,
-			}
-			entries = append(entries, entry)
-		}
+	entries := form.BaseEntries(args.Attrs, model.BaseEntryAvailable)
+	v0, err := b.baseUserMicDao.SelectByRoomIdUserId(context.Request.Context(), xl, roomId, userId)
+	if err != nil {
+		xl.Infof("select base_user_mic fail with roomId: %s and userId: %s, error: %v", roomId, userId, err)
+		responseErr := model.NewResponseErrorNotFound()
+		model.NewFailResponse(*responseErr).WithRequestID(requestId).Send(context)
+		return
+	}
+	baseMicDo, err := b.baseMicDao.Select(context.Request.Context(), xl, v0.MicId)
+	if err != nil {
+		xl.Errorf("select base_mic fail with micId: %s, error: %v", v0.MicId, err)
+		responseErr := model.NewResponseErrorDatabase(err)
+		model.NewFailResponse(*responseErr).WithRequestID(requestId).Send(context)
+		return
 	}
-	v0, err := b.baseUserMicDao.SelectByRoomIdUserId(xl, roomId, userId)
-	baseMicDo, _ := b.baseMicDao.Select(xl, v0.MicId)
 	baseMicDo.BaseMicAttrs = entries
-	err = b.baseMicDao.Update(xl, baseMicDo)
+	err = b.baseMicDao.Update(context.Request.Context(), xl, baseMicDo)
 	if err != nil {
-		resp := &model.Response{
-			Code:    int(model.ResponseStatusCodeSuccess),
-			Message: string(model.ResponseStatusMessageSuccess),
-			Data: struct {
-			}{},
-			RequestID: requestId,
-		}
-		context.JSON(http.StatusOK, resp)
+		xl.Errorf("update base_mic fail with micId: %s, error: %v", v0.MicId, err)
+		responseErr := model.NewResponseErrorDatabase(err)
+		model.NewFailResponse(*responseErr).WithRequestID(requestId).Send(context)
+		return
 	}
 	resp := &model.Response{
 		Code:      int(model.ResponseStatusCodeSuccess),
@@ -549,12 +470,12 @@ func (b *BaseMicApiHandler) MicInfo(context *gin.Context) {
 	}
 	color.Blue("用户 %s 获取 %s 的麦位", userId, roomId)
 	// 以上都是参数处理
-	b.sync(roomId)
-	baseRoomDo, _ := b.baseRoomDao.Select(xl, roomId)
-	userMics, _ := b.baseUserMicDao.ListByRoomId(xl, roomId)
+	b.sync(context.Request.Context(), xl, roomId)
+	baseRoomDo, _ := b.baseRoomDao.Select(context.Request.Context(), xl, roomId)
+	userMics, _ := b.baseUserMicDao.ListByRoomId(context.Request.Context(), xl, roomId)
 	mics := make([]model.MicInfo, 0, len(userMics))
 	for _, userMic := range userMics {
-		mic, _ := b.baseMicDao.Select(xl, userMic.MicId)
+		mic, _ := b.baseMicDao.Select(context.Request.Context(), xl, userMic.MicId)
 		if mic.BaseMicAttrs == nil || len(mic.BaseMicAttrs) == 0 {
 			mic.BaseMicAttrs = make([]model.BaseEntryDo, 0, 1)
 		}
@@ -622,13 +543,13 @@ func (b *BaseMicApiHandler) MicAttrs(context *gin.Context) {
 	}
 	color.Blue("用户 %s 尝试获取 %s 里的麦位", userId, roomId)
 	// 以上都是参数处理
-	b.sync(roomId)
+	b.sync(context.Request.Context(), xl, roomId)
 	attrKey := context.DefaultQuery("attrKey", "")
 	if roomType == "" {
 	}
-	roomMic, _ := b.baseUserMicDao.SelectByRoomIdUserId(xl, roomId, userId)
+	roomMic, _ := b.baseUserMicDao.SelectByRoomIdUserId(context.Request.Context(), xl, roomId, userId)
 	color.Blue("用户 %s 尝试获取 %s 的麦位 %s 的属性", userId, roomId, roomMic.MicId)
-	baseMicDo, _ := b.baseMicDao.Select(xl, roomMic.MicId)
+	baseMicDo, _ := b.baseMicDao.Select(context.Request.Context(), xl, roomMic.MicId)
 	if attrKey != "" {
 		var value interface{}
 		for _, entry := range baseMicDo.BaseMicAttrs {
@@ -670,24 +591,24 @@ func (b *BaseMicApiHandler) MicAttrs(context *gin.Context) {
 	}
 }
 
-func (b *BaseMicApiHandler) sync(roomId string) {
-	list, _ := b.rtcService.ListUser(roomId)
+func (b *BaseMicApiHandler) sync(ctx context.Context, xl *xlog.Logger, roomId string) {
+	list, _ := b.rtcService.ListUser(ctx, xl, roomId)
 	set := make(map[string]struct{})
 	for _, val := range list {
 		set[val] = struct{}{}
 	}
 	color.Yellow("开始同步麦位")
-	userMicDos, _ := b.baseUserMicDao.ListByRoomId(nil, roomId)
+	userMicDos, _ := b.baseUserMicDao.ListByRoomId(ctx, xl, roomId)
 	for _, val := range userMicDos {
 		if _, ok := set[val.UserId]; !ok {
-			roomMicDo, _ := b.baseRoomMicDao.Select(nil, val.RoomId, val.MicId)
+			roomMicDo, _ := b.baseRoomMicDao.Select(ctx, xl, val.RoomId, val.MicId)
 			color.Red("房间 %s, 麦位 %s, 用户 %s 不一致", val.RoomId, val.MicId, val.UserId)
 			if roomMicDo != nil {
 				roomMicDo.Status = model.BaseRoomMicUnused
-				_ = b.baseRoomMicDao.Update(nil, roomMicDo)
+				_ = b.baseRoomMicDao.Update(ctx, xl, roomMicDo)
 			}
 			val.Status = model.BaseUserMicNonHold
-			_ = b.baseUserMicDao.Update(nil, &val)
+			_ = b.baseUserMicDao.Update(ctx, xl, &val)
 		}
 	}
 }
@@ -1,9 +1,9 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/solutions/niu-cube/internal/service/db"
@@ -13,9 +13,11 @@ import (
 	"gopkg.in/mgo.v2"
 
 	"github.com/solutions/niu-cube/internal/common/utils"
+	"github.com/solutions/niu-cube/internal/protodef/form"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/cloud"
 	dao2 "github.com/solutions/niu-cube/internal/service/dao"
+	"github.com/solutions/niu-cube/internal/service/web/middleware"
 )
 
 type BaseRoomApi interface {
@@ -111,51 +113,66 @@ func NewBaseRoomApiHandler(xl *xlog.Logger, config *utils.Config) *BaseRoomApiHa
 	}
 }
 
+// baseForm 通用房间、麦位、用户接口的请求表单。
+type baseForm interface {
+	Validate() error
+}
+
+// writeDatabaseError 记录数据库操作的错误并返回，超时时返回超时错误码。
+func writeDatabaseError(context *gin.Context, xl *xlog.Logger, err error) {
+	xl.Errorf("database operation failed, error %v", err)
+	responseErr := model.NewResponseErrorDatabase(err)
+	resp := model.NewFailResponse(*responseErr).WithRequestID(xl.ReqId)
+	context.JSON(http.StatusOK, resp)
+}
+
+// bindBaseForm 解析并校验请求表单，失败时返回各字段的错误原因。
+func bindBaseForm(context *gin.Context, xl *xlog.Logger, args baseForm) bool {
+	err := context.ShouldBind(args)
+	if err == nil {
+		err = args.Validate()
+	}
+	if typed, ok := args.(form.RoomTypeForm); ok && typed.RoomType() != "" {
+		context.Set(model.RoomTypeContextKey, typed.RoomType())
+	}
+	if typed, ok := args.(form.RoomIDForm); ok && typed.RoomID() != "" {
+		middleware.AuditResource(context, "roomId", typed.RoomID())
+	}
+	if err == nil {
+		return true
+	}
+	xl.Infof("invalid args in body, error: %v", err)
+	lang := model.ContextLanguage(context)
+	fields := form.FieldErrors(err, lang)
+	if fields == nil {
+		responseErr := model.NewResponseErrorBadRequest()
+		model.NewFailResponse(*responseErr).WithRequestID(xl.ReqId).Send(context)
+		return false
+	}
+	responseErr := model.NewResponseErrorValidation(form.Localize(err, lang))
+	model.NewFailResponse(*responseErr).WithRequestID(xl.ReqId).WithData(model.FieldErrorsResponse{Fields: fields}).Send(context)
+	return false
+}
+
 func (b *BaseRoomApiHandler) CreateRoom(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
 	userId := context.GetString(model.UserIDContextKey)
-	var input map[string]interface{}
-	err := context.Bind(&input)
-	if err != nil {
-		xl.Infof("invalid args in body, error: %v", err)
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
+	args := &form.BaseRoomCreateForm{}
+	if !bindBaseForm(context, xl, args) {
 		return
 	}
-	var title string
+	var title = args.Title
 	var desc = "no-desc"
-	var image = ""
-	var roomType string
-	if title0, ok := input["title"].(string); ok {
-		title = title0
-	} else {
-		xl.Infof("miss title in body.")
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
-		return
+	var image = args.Image
+	var roomType = args.Type
+	if args.Desc != "" {
+		desc = args.Desc
 	}
-	if desc0, ok := input["desc"].(string); ok {
-		desc = desc0
-	}
-	if image0, ok := input["image"].(string); ok {
-		image = image0
-	}
-	if roomType0, ok := input["type"].(string); ok {
-		roomType = roomType0
-	} else {
-		xl.Infof("miss roomType in body.")
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
-		return
-	}
-	baseUserDo, err := b.baseUserDao.Select(xl, userId)
+	baseUserDo, err := b.baseUserDao.Select(context.Request.Context(), xl, userId)
 	if err != nil {
 		xl.Errorf("select base_user fail with userId: %s", userId)
-		responseErr := model.NewResponseErrorInternal()
+		responseErr := model.NewResponseErrorDatabase(err)
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 		context.JSON(http.StatusOK, resp)
 		return
@@ -172,35 +189,13 @@ func (b *BaseRoomApiHandler) CreateRoom(context *gin.Context) {
 		Creator: userId,
 		Type:    roomType,
 	}
-	attrs := make([]model.BaseEntryDo, 0, 1)
-	if attrs0, ok := input["attrs"].([]interface{}); ok {
-		for _, val0 := range attrs0 {
-			val := val0.(map[string]interface{})
-			entry := model.BaseEntryDo{
-				Key:    val["key"].(string),
-				Value:  val["value"],
-				Status: model.BaseEntryAvailable,
-			}
-			attrs = append(attrs, entry)
-		}
-	}
-	params := make([]model.BaseEntryDo, 0, 1)
-	if params0, ok := input["params"].([]interface{}); ok {
-		for _, val0 := range params0 {
-			val := val0.(map[string]interface{})
-			entry := model.BaseEntryDo{
-				Key:    val["key"].(string),
-				Value:  val["value"],
-				Status: model.BaseEntryAvailable,
-			}
-			params = append(params, entry)
-		}
-	}
+	attrs := form.BaseEntries(args.Attrs, model.BaseEntryAvailable)
+	params := form.BaseEntries(args.Params, model.BaseEntryAvailable)
 	// 上面一堆都是参数解析
 	var invitationCode string
 	for {
 		invitationCode = utils.GenerateID()[0:6]
-		tmp, _ := b.baseRoomDao.SelectByInvitationCode(xl, invitationCode)
+		tmp, _ := b.baseRoomDao.SelectByInvitationCode(context.Request.Context(), xl, invitationCode)
 		if tmp == nil {
 			break
 		}
@@ -214,7 +209,7 @@ func (b *BaseRoomApiHandler) CreateRoom(context *gin.Context) {
 		Status: model.BaseEntryAvailable,
 	})
 	// 创建七牛IM群ID
-	qiniuImGroupId, err := b.appConfigService.GetGroupId(xl, baseRoomDo.Id)
+	qiniuImGroupId, err := b.appConfigService.GetGroupId(context.Request.Context(), xl, baseRoomDo.Id)
 	if err != nil {
 		xl.Errorf("failed to create qiniu im group, error %v", err)
 		responseErr := model.NewResponseErrorInternal()
@@ -223,10 +218,10 @@ func (b *BaseRoomApiHandler) CreateRoom(context *gin.Context) {
 		return
 	}
 	baseRoomDo.QiniuIMGroupId = qiniuImGroupId
-	_, err = b.baseRoomDao.Insert(xl, baseRoomDo)
+	_, err = b.baseRoomDao.Insert(context.Request.Context(), xl, baseRoomDo)
 	if err != nil {
 		xl.Errorf("insert base_room fail with roomId: %s and userId: %s", baseRoomDo.Id, userId)
-		responseErr := model.NewResponseErrorInternal()
+		responseErr := model.NewResponseErrorDatabase(err)
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 		context.JSON(http.StatusOK, resp)
 		return
@@ -248,14 +243,14 @@ func (b *BaseRoomApiHandler) CreateRoom(context *gin.Context) {
 				BaseMicAttrs:  make([]model.BaseEntryDo, 0, 1),
 				BaseMicParams: make([]model.BaseEntryDo, 0, 1),
 			}
-			_, _ = b.baseMicDao.InsertBaseMic(xl, &mic)
+			_, _ = b.baseMicDao.InsertBaseMic(context.Request.Context(), xl, &mic)
 			roomMic := model.BaseRoomMicDo{
 				RoomId: baseRoomDo.Id,
 				MicId:  mic.Id,
 				Index:  i,
 				Status: model.BaseRoomMicUnused,
 			}
-			_, _ = b.baseRoomMicDao.Insert(xl, &roomMic)
+			_, _ = b.baseRoomMicDao.Insert(context.Request.Context(), xl, &roomMic)
 		}
 		// 指定主麦
 		mic := model.BaseMicDo{
@@ -265,14 +260,14 @@ func (b *BaseRoomApiHandler) CreateRoom(context *gin.Context) {
 			BaseMicAttrs:  make([]model.BaseEntryDo, 0, 1),
 			BaseMicParams: make([]model.BaseEntryDo, 0, 1),
 		}
-		_, _ = b.baseMicDao.InsertBaseMic(xl, &mic)
+		_, _ = b.baseMicDao.InsertBaseMic(context.Request.Context(), xl, &mic)
 		roomMic := model.BaseRoomMicDo{
 			RoomId: baseRoomDo.Id,
 			MicId:  mic.Id,
 			Index:  0,
 			Status: model.BaseRoomMicUnused,
 		}
-		_, _ = b.baseRoomMicDao.Insert(xl, &roomMic)
+		_, _ = b.baseRoomMicDao.Insert(context.Request.Context(), xl, &roomMic)
 	// 麦位数按需增长，但是需要设定一个主麦
 	case model.BaseTypeClassroom, model.BaseTypeShow, model.BaseTypeExam, model.BaseTypeVoiceChat:
 		mic := model.BaseMicDo{
@@ -282,14 +277,14 @@ func (b *BaseRoomApiHandler) CreateRoom(context *gin.Context) {
 			BaseMicAttrs:  make([]model.BaseEntryDo, 0, 1),
 			BaseMicParams: make([]model.BaseEntryDo, 0, 1),
 		}
-		_, _ = b.baseMicDao.InsertBaseMic(xl, &mic)
+		_, _ = b.baseMicDao.InsertBaseMic(context.Request.Context(), xl, &mic)
 		roomMic := model.BaseRoomMicDo{
 			RoomId: baseRoomDo.Id,
 			MicId:  mic.Id,
 			Index:  0,
 			Status: model.BaseRoomMicUnused,
 		}
-		_, _ = b.baseRoomMicDao.Insert(xl, &roomMic)
+		_, _ = b.baseRoomMicDao.Insert(context.Request.Context(), xl, &roomMic)
 	}
 	// 构建返回值
 	resp := &model.Response{
@@ -322,63 +317,23 @@ func (b *BaseRoomApiHandler) JoinRoom(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
 	userId := context.GetString(model.UserIDContextKey)
-	var input map[string]interface{}
-	err := context.Bind(&input)
-	if err != nil {
-		xl.Infof("invalid args in body, error: %v", err)
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
+	args := &form.BaseRoomJoinForm{}
+	if !bindBaseForm(context, xl, args) {
 		return
 	}
-	roomId := ""
-	invitationCode := ""
-	var roomType string
-	if roomId0, ok := input["roomId"].(string); ok {
-		roomId = roomId0
-	}
-	if roomType0, ok := input["type"].(string); ok {
-		roomType = roomType0
-	} else {
-		xl.Infof("miss roomType in body.")
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
-		return
-	}
-	params := make([]model.BaseEntryDo, 0, 1)
-	if params0, ok := input["params"].([]interface{}); ok {
-		for _, val0 := range params0 {
-			val := val0.(map[string]interface{})
-			entry := model.BaseEntryDo{
-				Key:    val["key"].(string),
-				Value:  val["value"],
-				Status: model.BaseEntryAvailable,
-			}
-			params = append(params, entry)
-		}
-	}
-	role := "no-role"
-	for _, entry := range params {
-		if entry.Key == "role" {
-			role = entry.Value.(string)
-		}
-		if entry.Key == "invitationCode" {
-			invitationCode = entry.Value.(string)
-		}
-	}
-	if roomId == "" && invitationCode == "" {
-		xl.Infof("miss roomId/invitationCode in body.")
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
-		return
+	roomId := args.RoomId
+	roomType := args.Type
+	invitationCode, _ := form.StringEntry(args.Params, "invitationCode")
+	role, ok := form.StringEntry(args.Params, "role")
+	if !ok {
+		role = "no-role"
 	}
+	var err error
 	var baseRoomDo *model.BaseRoomDo
 	if roomId != "" {
-		baseRoomDo, err = b.baseRoomDao.Select(xl, roomId)
+		baseRoomDo, err = b.baseRoomDao.Select(context.Request.Context(), xl, roomId)
 	} else {
-		baseRoomDo, err = b.baseRoomDao.SelectByInvitationCode(xl, invitationCode)
+		baseRoomDo, err = b.baseRoomDao.SelectByInvitationCode(context.Request.Context(), xl, invitationCode)
 	}
 	if err != nil {
 		if err == mgo.ErrNotFound {
@@ -391,8 +346,8 @@ func (b *BaseRoomApiHandler) JoinRoom(context *gin.Context) {
 			}
 			context.JSON(http.StatusOK, resp)
 		} else {
-			xl.Errorf("select base_room fail with roomId: %s", baseRoomDo.Id)
-			responseErr := model.NewResponseErrorInternal()
+			xl.Errorf("select base_room fail with roomId: %s, invitationCode: %s, error: %v", roomId, invitationCode, err)
+			responseErr := model.NewResponseErrorDatabase(err)
 			resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 			context.JSON(http.StatusOK, resp)
 		}
@@ -402,7 +357,12 @@ func (b *BaseRoomApiHandler) JoinRoom(context *gin.Context) {
 	if roomType == model.BaseTypeClassroom && len(baseRoomDo.BaseRoomParams) != 0 {
 		for _, val := range baseRoomDo.BaseRoomParams {
 			if val.Key == "classType" {
-				classType = int(val.Value.(float64))
+				switch value := val.Value.(type) {
+				case float64:
+					classType = int(value)
+				case int:
+					classType = value
+				}
 				break
 			}
 		}
@@ -410,14 +370,14 @@ func (b *BaseRoomApiHandler) JoinRoom(context *gin.Context) {
 	canNot := false
 	// 是小班课
 	if classType == 2 {
-		l, _ := b.baseRoomUserDao.ListByRoomId(xl, baseRoomDo.Id)
+		l, _ := b.baseRoomUserDao.ListByRoomId(context.Request.Context(), xl, baseRoomDo.Id)
 		// 且人数已经到了两人
 		if len(l) >= 2 && l[0].UserId != userId && l[1].UserId != userId {
 			canNot = true
 		}
 	}
 	if !canNot {
-		baseRoomUserDo, err := b.baseRoomUserDao.SelectByRoomIdUserId(xl, baseRoomDo.Id, userId)
+		baseRoomUserDo, err := b.baseRoomUserDao.SelectByRoomIdUserId(context.Request.Context(), xl, baseRoomDo.Id, userId)
 		if err == mgo.ErrNotFound {
 			baseRoomUserDo = &model.BaseRoomUserDo{
 				RoomId:            baseRoomDo.Id,
@@ -426,17 +386,17 @@ func (b *BaseRoomApiHandler) JoinRoom(context *gin.Context) {
 				Status:            model.BaseRoomUserJoin,
 				LastHeartbeatTime: time.Now(),
 			}
-			_, err = b.baseRoomUserDao.Insert(xl, baseRoomUserDo)
+			_, err = b.baseRoomUserDao.Insert(context.Request.Context(), xl, baseRoomUserDo)
 			if err != nil {
 				xl.Errorf("insert base_room_user fail with userId: %s and roomId: %s", userId, baseRoomDo.Id)
-				responseErr := model.NewResponseErrorInternal()
+				responseErr := model.NewResponseErrorDatabase(err)
 				resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 				context.JSON(http.StatusOK, resp)
 				return
 			}
 		} else {
 			baseRoomUserDo.LastHeartbeatTime = time.Now()
-			_ = b.baseRoomUserDao.Update(xl, baseRoomUserDo)
+			_ = b.baseRoomUserDao.Update(context.Request.Context(), xl, baseRoomUserDo)
 		}
 	} else {
 		resp := &model.Response{
@@ -449,29 +409,29 @@ func (b *BaseRoomApiHandler) JoinRoom(context *gin.Context) {
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	_ = b.baseRoomDao.Update(xl, baseRoomDo)
-	baseUserDo, err := b.baseUserDao.Select(xl, userId)
+	_ = b.baseRoomDao.Update(context.Request.Context(), xl, baseRoomDo)
+	baseUserDo, err := b.baseUserDao.Select(context.Request.Context(), xl, userId)
 	if err != nil {
 		xl.Errorf("select base_user fail with userId: %s", userId)
-		responseErr := model.NewResponseErrorInternal()
+		responseErr := model.NewResponseErrorDatabase(err)
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	baseRoomUserDos, err := b.baseRoomUserDao.ListByRoomId(xl, baseRoomDo.Id)
+	baseRoomUserDos, err := b.baseRoomUserDao.ListByRoomId(context.Request.Context(), xl, baseRoomDo.Id)
 	if err != nil {
 		xl.Errorf("select base_room_user fail with userId: %s and roomId: %s", userId, baseRoomDo.Id)
-		responseErr := model.NewResponseErrorInternal()
+		responseErr := model.NewResponseErrorDatabase(err)
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 		context.JSON(http.StatusOK, resp)
 		return
 	}
 	baseUserDos := make([]model.BaseUserDo, 0, 1)
 	for _, baseRoomUser := range baseRoomUserDos {
-		tmp, _ := b.baseUserDao.Select(xl, baseRoomUser.UserId)
+		tmp, _ := b.baseUserDao.Select(context.Request.Context(), xl, baseRoomUser.UserId)
 		baseUserDos = append(baseUserDos, *tmp)
 	}
-	list, _ := b.baseRoomUserDao.ListByRoomId(xl, baseRoomDo.Id)
+	list, _ := b.baseRoomUserDao.ListByRoomId(context.Request.Context(), xl, baseRoomDo.Id)
 	// 赋值IM群ID
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
@@ -510,39 +470,16 @@ func (b *BaseRoomApiHandler) LeaveRoom(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
 	userId := context.GetString(model.UserIDContextKey)
-	var input map[string]interface{}
-	err := context.Bind(&input)
-	if err != nil {
-		xl.Infof("invalid args in body, error: %v", err)
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
-		return
-	}
-	var roomId string
-	var roomType string
-	if roomId0, ok := input["roomId"].(string); ok {
-		roomId = roomId0
-	} else {
-		xl.Infof("miss roomId in body.")
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
-		return
-	}
-	if roomType0, ok := input["type"].(string); ok {
-		roomType = roomType0
-	} else {
-		xl.Infof("miss roomType in body.")
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
+	args := &form.BaseRoomLeaveForm{}
+	if !bindBaseForm(context, xl, args) {
 		return
 	}
+	roomId := args.RoomId
+	roomType := args.Type
 	// 根据业务类型特例化
 	if roomType == "" {
 	}
-	room, err := b.baseRoomDao.Select(xl, roomId)
+	room, err := b.baseRoomDao.Select(context.Request.Context(), xl, roomId)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			resp := &model.Response{
@@ -554,8 +491,8 @@ func (b *BaseRoomApiHandler) LeaveRoom(context *gin.Context) {
 			}
 			context.JSON(http.StatusOK, resp)
 		} else {
-			xl.Errorf("select base_room fail with roomId:[%s]", userId, roomId)
-			responseErr := model.NewResponseErrorInternal()
+			xl.Errorf("select base_room fail with roomId: %s, error: %v", roomId, err)
+			responseErr := model.NewResponseErrorDatabase(err)
 			resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 			context.JSON(http.StatusOK, resp)
 		}
@@ -566,28 +503,28 @@ func (b *BaseRoomApiHandler) LeaveRoom(context *gin.Context) {
 		if room.Creator == userId {
 			xl.Infof("room creator leave, and the room will be destroyed.")
 			room.Status = model.BaseRoomDestroyed
-			_ = b.baseRoomDao.Update(xl, room)
-			_ = b.appConfigService.DestroyGroupChat(xl, room.QiniuIMGroupId)
-			roomUsers, _ := b.baseRoomUserDao.ListByRoomId(xl, roomId)
+			_ = b.baseRoomDao.Update(context.Request.Context(), xl, room)
+			_ = b.appConfigService.DestroyGroupChat(context.Request.Context(), xl, room.QiniuIMGroupId)
+			roomUsers, _ := b.baseRoomUserDao.ListByRoomId(context.Request.Context(), xl, roomId)
 			for _, val := range roomUsers {
-				b.leaveRoom(&val)
+				b.leaveRoom(context.Request.Context(), &val)
 			}
 		} else {
-			roomUser, _ := b.baseRoomUserDao.SelectByRoomIdUserId(xl, roomId, userId)
+			roomUser, _ := b.baseRoomUserDao.SelectByRoomIdUserId(context.Request.Context(), xl, roomId, userId)
 			if roomUser != nil {
-				b.leaveRoom(roomUser)
+				b.leaveRoom(context.Request.Context(), roomUser)
 			}
 		}
 		if roomType == model.BaseTypeKtv || roomType == model.BaseTypeMovie {
-			roomUserSong, _ := b.roomUserSongDao.SelectByRoomIdUserId(xl, roomId, userId)
+			roomUserSong, _ := b.roomUserSongDao.SelectByRoomIdUserId(context.Request.Context(), xl, roomId, userId)
 			if roomUserSong != nil {
 				roomUserSong.Status = model.RoomUserSongUnavailable
-				_ = b.roomUserSongDao.Update(xl, roomUserSong)
+				_ = b.roomUserSongDao.Update(context.Request.Context(), xl, roomUserSong)
 			}
-			roomUserMovie, _ := b.roomUserMovieDao.SelectByRoomIdUserId(xl, roomId, userId)
+			roomUserMovie, _ := b.roomUserMovieDao.SelectByRoomIdUserId(context.Request.Context(), xl, roomId, userId)
 			if roomUserMovie != nil {
 				roomUserMovie.Status = model.RoomUserMovieUnavailable
-				_ = b.roomUserMovieDao.Update(xl, roomUserMovie)
+				_ = b.roomUserMovieDao.Update(context.Request.Context(), xl, roomUserMovie)
 			}
 		}
 	}
@@ -600,27 +537,26 @@ func (b *BaseRoomApiHandler) LeaveRoom(context *gin.Context) {
 	context.JSON(http.StatusOK, resp)
 }
 
-func (b *BaseRoomApiHandler) leaveRoom(roomUser *model.BaseRoomUserDo) {
-	userMic, _ := b.baseUserMicDao.SelectByRoomIdUserId(nil, roomUser.RoomId, roomUser.UserId)
+func (b *BaseRoomApiHandler) leaveRoom(ctx context.Context, roomUser *model.BaseRoomUserDo) {
+	userMic, _ := b.baseUserMicDao.SelectByRoomIdUserId(ctx, nil, roomUser.RoomId, roomUser.UserId)
 	if userMic != nil {
 		userMic.Status = model.BaseUserMicNonHold
-		_ = b.baseUserMicDao.Update(nil, userMic)
-		roomMic, _ := b.baseRoomMicDao.Select(nil, userMic.RoomId, userMic.MicId)
+		_ = b.baseUserMicDao.Update(ctx, nil, userMic)
+		roomMic, _ := b.baseRoomMicDao.Select(ctx, nil, userMic.RoomId, userMic.MicId)
 		if roomMic != nil {
 			roomMic.Status = model.BaseRoomMicUnused
-			_ = b.baseRoomMicDao.Update(nil, roomMic)
+			_ = b.baseRoomMicDao.Update(ctx, nil, roomMic)
 		}
 	}
 	roomUser.Status = model.BaseRoomUserTimeout
-	_ = b.baseRoomUserDao.Update(nil, roomUser)
+	_ = b.baseRoomUserDao.Update(ctx, nil, roomUser)
 }
 
 func (b *BaseRoomApiHandler) ListRooms(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
 	userId := context.GetString(model.UserIDContextKey)
-	pageSize, _ := strconv.Atoi(context.DefaultQuery("pageSize", "10"))
-	pageNum, _ := strconv.Atoi(context.DefaultQuery("pageNum", "1"))
+	page := model.ContextPageQuery(context)
 	roomType := context.DefaultQuery("type", "")
 	if roomType == "" {
 		xl.Infof("miss roomType in body.")
@@ -629,21 +565,17 @@ func (b *BaseRoomApiHandler) ListRooms(context *gin.Context) {
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	baseRoomDos, total, count, err := b.baseRoomDao.ListByRoomType(xl, roomType, pageNum, pageSize)
+	baseRoomDos, pageRes, err := b.baseRoomDao.ListByRoomType(context.Request.Context(), xl, roomType, page)
 	if err != nil {
 		xl.Errorf("select base_room all fail with userId: %s", userId)
-		responseErr := model.NewResponseErrorInternal()
+		responseErr := model.NewResponseErrorDatabase(err)
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	flag := false
-	if pageNum*pageSize >= total {
-		flag = true
-	}
 	list := make([]model.RoomInformation, 0, len(baseRoomDos))
 	for _, val := range baseRoomDos {
-		l, _ := b.baseRoomUserDao.ListByRoomId(xl, val.Id)
+		l, _ := b.baseRoomUserDao.ListByRoomId(context.Request.Context(), xl, val.Id)
 		list = append(list, model.RoomInformation{
 			BaseRoomDo: val,
 			TotalUsers: len(l),
@@ -654,13 +586,14 @@ func (b *BaseRoomApiHandler) ListRooms(context *gin.Context) {
 		Message: string(model.ResponseStatusMessageSuccess),
 		Data: model.ListRooms{
 			List:           list,
-			Total:          total,
+			Total:          pageRes.Total,
 			NextId:         "",
-			Cnt:            count,
-			CurrentPageNum: pageNum,
-			NextPageNum:    pageNum + 1,
-			PageSize:       pageSize,
-			EndPage:        flag,
+			Cnt:            pageRes.Count,
+			CurrentPageNum: page.PageNum,
+			NextPageNum:    page.PageNum + 1,
+			PageSize:       page.PageSize,
+			EndPage:        pageRes.EndPage,
+			NextCursor:     pageRes.NextCursor,
 		},
 		RequestID: requestId,
 	}
@@ -688,47 +621,47 @@ func (b *BaseRoomApiHandler) RoomInfo(context *gin.Context) {
 		return
 	}
 	// 以上都是参数处理
-	b.sync(roomId)
-	baseRoomDo, err := b.baseRoomDao.Select(xl, roomId)
+	b.sync(context.Request.Context(), xl, roomId)
+	baseRoomDo, err := b.baseRoomDao.Select(context.Request.Context(), xl, roomId)
 	if err != nil {
 		xl.Errorf("select base_room fail with roomId: %s and userId: %s", roomId, userId)
-		responseErr := model.NewResponseErrorInternal()
+		responseErr := model.NewResponseErrorDatabase(err)
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	baseUser, err := b.baseUserDao.Select(xl, userId)
+	baseUser, err := b.baseUserDao.Select(context.Request.Context(), xl, userId)
 	if err != nil {
 		xl.Errorf("select base_user fail with userId: %s", userId)
-		responseErr := model.NewResponseErrorInternal()
+		responseErr := model.NewResponseErrorDatabase(err)
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	baseRoomUserDos, err := b.baseRoomUserDao.ListByRoomId(xl, roomId)
+	baseRoomUserDos, err := b.baseRoomUserDao.ListByRoomId(context.Request.Context(), xl, roomId)
 	if err != nil {
 		xl.Errorf("select base_room_user all fail with roomId: %s", roomId)
-		responseErr := model.NewResponseErrorInternal()
+		responseErr := model.NewResponseErrorDatabase(err)
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 		context.JSON(http.StatusOK, resp)
 		return
 	}
 	baseUserDos := make([]model.BaseUserDo, 0, len(baseRoomUserDos))
 	for _, val := range baseRoomUserDos {
-		tmp, _ := b.baseUserDao.Select(xl, val.UserId)
+		tmp, _ := b.baseUserDao.Select(context.Request.Context(), xl, val.UserId)
 		baseUserDos = append(baseUserDos, *tmp)
 	}
-	userMics, err := b.baseUserMicDao.ListByRoomId(xl, roomId)
+	userMics, err := b.baseUserMicDao.ListByRoomId(context.Request.Context(), xl, roomId)
 	if err != nil {
 		xl.Errorf("select base_room_mic all fail with roomId: %s", roomId)
-		responseErr := model.NewResponseErrorInternal()
+		responseErr := model.NewResponseErrorDatabase(err)
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 		context.JSON(http.StatusOK, resp)
 		return
 	}
 	mics := make([]model.MicInfo, 0, len(userMics))
 	for _, val := range userMics {
-		baseMicDo, _ := b.baseMicDao.Select(xl, val.MicId)
+		baseMicDo, _ := b.baseMicDao.Select(context.Request.Context(), xl, val.MicId)
 		micInfo := model.MicInfo{
 			Uid:           val.UserId,
 			UserExtension: val.UserExtension,
@@ -742,7 +675,7 @@ func (b *BaseRoomApiHandler) RoomInfo(context *gin.Context) {
 		}
 		mics = append(mics, micInfo)
 	}
-	l, _ := b.baseRoomUserDao.ListByRoomId(xl, baseRoomDo.Id)
+	l, _ := b.baseRoomUserDao.ListByRoomId(context.Request.Context(), xl, baseRoomDo.Id)
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
 		Message: string(model.ResponseStatusMessageSuccess),
@@ -771,58 +704,32 @@ func (b *BaseRoomApiHandler) UpdateRoomInfo(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
 	userId := context.GetString(model.UserIDContextKey)
-	var input map[string]interface{}
-	err := context.Bind(&input)
-	if err != nil {
-		xl.Infof("invalid args in body, error: %v", err)
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
-		return
-	}
-	var roomId string
-	var roomType string
-	var entries []model.BaseEntryDo
-	if roomId0, ok := input["roomId"].(string); ok {
-		roomId = roomId0
-	} else {
-		xl.Infof("miss roomId in body.")
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
-		return
-	}
-	if roomType0, ok := input["type"].(string); ok {
-		roomType = roomType0
-	} else {
-		xl.Infof("miss roomType in body.")
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
+	args := &form.BaseRoomUpdateForm{}
+	if !bindBaseForm(context, xl, args) {
 		return
 	}
-	// 特例化处理
-	if roomType == "" {
-	}
-	if entries0, ok := input["attrs"].([]interface{}); ok {
-		entries = make([]model.BaseEntryDo, 0, len(entries0))
-		for _, val0 := range entries0 {
-			val := val0.(map[string]interface{})
-			entry := model.BaseEntryDo{
-				Key:    val["key"].(string),
-				Value:  val["value"],
-				Status: model.BaseEntryAvailable,
-			}
-			entries = append(entries, entry)
+	roomId := args.RoomId
+	entries := form.BaseEntries(args.Attrs, model.BaseEntryAvailable)
+	baseRoomDo, err := b.baseRoomDao.Select(context.Request.Context(), xl, roomId)
+	if err != nil {
+		responseErr := model.NewResponseErrorDatabase(err)
+		if err == mgo.ErrNotFound {
+			responseErr = model.NewResponseErrorNoSuchRoom()
+		} else {
+			xl.Errorf("select base_room fail with roomId: %s, error: %v", roomId, err)
 		}
+		model.NewFailResponse(*responseErr).WithRequestID(requestId).Send(context)
+		return
 	}
-	baseRoomDo, err := b.baseRoomDao.Select(xl, roomId)
 	baseRoomDo.BaseRoomAttrs = entries
-	err = b.baseRoomDao.Update(xl, baseRoomDo)
+	err = b.baseRoomDao.Update(context.Request.Context(), xl, baseRoomDo)
 	if err != nil {
+		xl.Errorf("update base_room fail with roomId: %s, error: %v", roomId, err)
+		responseErr := model.NewResponseErrorDatabase(err)
+		model.NewFailResponse(*responseErr).WithRequestID(requestId).Send(context)
 		return
 	}
-	baseUserDo, err := b.baseUserDao.Select(xl, userId)
+	baseUserDo, err := b.baseUserDao.Select(context.Request.Context(), xl, userId)
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
 		Message: string(model.ResponseStatusMessageSuccess),
@@ -858,10 +765,10 @@ func (b *BaseRoomApiHandler) RoomInfoAttr(context *gin.Context) {
 		return
 	}
 	attrKey := context.DefaultQuery("attrKey", "")
-	baseRoomDo, err := b.baseRoomDao.Select(xl, roomId)
+	baseRoomDo, err := b.baseRoomDao.Select(context.Request.Context(), xl, roomId)
 	if err != nil {
 		xl.Errorf("select base_room fail with roomId: %s", roomId)
-		responseErr := model.NewResponseErrorInternal()
+		responseErr := model.NewResponseErrorDatabase(err)
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 		context.JSON(http.StatusOK, resp)
 		return
@@ -908,37 +815,38 @@ func (b *BaseRoomApiHandler) RoomInfoAttr(context *gin.Context) {
 }
 
 func (b *BaseRoomApiHandler) TruncateGroupChat(context *gin.Context) {
-	force, _ := b.baseRoomDao.ListAllForce(b.xl)
+	force, _ := b.baseRoomDao.ListAllForce(context.Request.Context(), b.xl)
 	for _, val := range force {
 		if val.QiniuIMGroupId == 0 {
 			continue
 		}
-		_ = b.appConfigService.DestroyGroupChat(b.xl, val.QiniuIMGroupId)
+		_ = b.appConfigService.DestroyGroupChat(context.Request.Context(), b.xl, val.QiniuIMGroupId)
 	}
 }
 
 func (b *BaseRoomApiHandler) ListUser(context *gin.Context) {
+	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	roomId := context.Param("roomId")
-	list, _ := b.rtcService.ListUser(roomId)
+	list, _ := b.rtcService.ListUser(context.Request.Context(), xl, roomId)
 	context.JSON(200, list)
 }
 
-func (b *BaseRoomApiHandler) sync(roomId string) {
-	list, _ := b.rtcService.ListUser(roomId)
+func (b *BaseRoomApiHandler) sync(ctx context.Context, xl *xlog.Logger, roomId string) {
+	list, _ := b.rtcService.ListUser(ctx, xl, roomId)
 	set := make(map[string]struct{})
 	for _, val := range list {
 		set[val] = struct{}{}
 	}
-	userMicDos, _ := b.baseUserMicDao.ListByRoomId(nil, roomId)
+	userMicDos, _ := b.baseUserMicDao.ListByRoomId(ctx, xl, roomId)
 	for _, val := range userMicDos {
 		if _, ok := set[val.UserId]; !ok {
-			roomMicDo, _ := b.baseRoomMicDao.Select(nil, val.RoomId, val.MicId)
+			roomMicDo, _ := b.baseRoomMicDao.Select(ctx, xl, val.RoomId, val.MicId)
 			if roomMicDo != nil {
 				roomMicDo.Status = model.BaseRoomMicUnused
-				_ = b.baseRoomMicDao.Update(nil, roomMicDo)
+				_ = b.baseRoomMicDao.Update(ctx, xl, roomMicDo)
 			}
 			val.Status = model.BaseUserMicNonHold
-			_ = b.baseUserMicDao.Update(nil, &val)
+			_ = b.baseUserMicDao.Update(ctx, xl, &val)
 		}
 	}
 }
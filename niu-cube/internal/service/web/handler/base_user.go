@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/solutions/niu-cube/internal/common/utils"
+	"github.com/solutions/niu-cube/internal/protodef/form"
 	"github.com/solutions/niu-cube/internal/protodef/model"
 	"github.com/solutions/niu-cube/internal/service/dao"
 )
@@ -60,14 +61,14 @@ func (b *BaseUserApiHandler) Heartbeat(context *gin.Context) {
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	roomUser, err := b.baseRoomUserDao.SelectByRoomIdUserId(xl, roomId, userId)
+	roomUser, err := b.baseRoomUserDao.SelectByRoomIdUserId(context.Request.Context(), xl, roomId, userId)
 	if err != nil {
 		// 用户已下线
 		if err == mgo.ErrNotFound {
 			xl.Infof("user:[%s] already logout.", userId)
 		} else {
 			xl.Errorf("select base_room_user all fail with roomId:[%s], userId:[%s]", roomId, userId)
-			responseErr := model.NewResponseErrorInternal()
+			responseErr := model.NewResponseErrorDatabase(err)
 			resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
 			context.JSON(http.StatusOK, resp)
 			return
@@ -75,7 +76,7 @@ func (b *BaseUserApiHandler) Heartbeat(context *gin.Context) {
 	}
 	if roomUser != nil {
 		roomUser.LastHeartbeatTime = time.Now()
-		err = b.baseRoomUserDao.Update(xl, roomUser)
+		err = b.baseRoomUserDao.Update(context.Request.Context(), xl, roomUser)
 	}
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
@@ -94,16 +95,11 @@ func (b *BaseUserApiHandler) UpdateUserInfo(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
 	userId := context.GetString(model.UserIDContextKey)
-	var input map[string]interface{}
-	err := context.Bind(&input)
-	if err != nil {
-		xl.Infof("invalid args in body, error: %v", err)
-		responseErr := model.NewResponseErrorBadRequest()
-		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
-		context.JSON(http.StatusOK, resp)
+	args := &form.BaseUserUpdateForm{}
+	if !bindBaseForm(context, xl, args) {
 		return
 	}
-	baseUser, _ := b.baseUserDao.Select(xl, userId)
+	baseUser, _ := b.baseUserDao.Select(context.Request.Context(), xl, userId)
 	if baseUser == nil {
 		xl.Errorf("用户不存在")
 		responseErr := model.NewResponseErrorBadRequest()
@@ -111,19 +107,19 @@ func (b *BaseUserApiHandler) UpdateUserInfo(context *gin.Context) {
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	if tmp, ok := input["name"].(string); ok {
-		baseUser.Name = tmp
+	if args.Name != nil {
+		baseUser.Name = *args.Name
 	}
-	if tmp, ok := input["nickname"].(string); ok {
-		baseUser.Nickname = tmp
+	if args.Nickname != nil {
+		baseUser.Nickname = *args.Nickname
 	}
-	if tmp, ok := input["avatar"].(string); ok {
-		baseUser.Avatar = tmp
+	if args.Avatar != nil {
+		baseUser.Avatar = *args.Avatar
 	}
-	if tmp, ok := input["profile"].(string); ok {
-		baseUser.Profile = tmp
+	if args.Profile != nil {
+		baseUser.Profile = *args.Profile
 	}
-	_ = b.baseUserDao.Update(xl, baseUser)
+	_ = b.baseUserDao.Update(context.Request.Context(), xl, baseUser)
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
 		Message: string(model.ResponseStatusMessageSuccess),
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
@@ -45,20 +46,20 @@ func (v *BoardHandlerApi) CreateOrUpdateBoard(c *gin.Context) {
 	err := c.Bind(&boardForm)
 	if err != nil {
 		xl.Errorf("form binding error:%v", err)
-		respErr := model.NewResponseErrorValidation(err)
+		respErr := model.NewResponseErrorValidation(form.Localize(err, model.ContextLanguage(c)))
 		model.NewFailResponse(*respErr).WithRequestID(xl.ReqId).Send(c)
 		return
 	}
 	err = boardForm.Validate()
 	if err != nil {
 		xl.Errorf("form valdiation error:%v", err)
-		respErr := model.NewResponseErrorValidation(err)
+		respErr := model.NewResponseErrorValidation(form.Localize(err, model.ContextLanguage(c)))
 		model.NewFailResponse(*respErr).WithRequestID(xl.ReqId).Send(c)
 		return
 	}
 	var board model.BoardDo
-	board, err = v.boardService.GetOneByID(v.xl, interviewId)
-	interview, _ := v.interviewService.GetInterviewByID(v.xl, interviewId)
+	board, err = v.boardService.GetOneByID(c.Request.Context(), v.xl, interviewId)
+	interview, _ := v.interviewService.GetInterviewByID(c.Request.Context(), v.xl, interviewId)
 	switch {
 	case interview == nil:
 		xl.Errorf("no such interview:%v", interviewId)
@@ -67,10 +68,10 @@ func (v *BoardHandlerApi) CreateOrUpdateBoard(c *gin.Context) {
 		return
 	case err == nil:
 		// 已存在board 更新状态
-		err := v.boardStateTransition(&board, boardForm.Cmd, userId, interviewId)
+		err := v.boardStateTransition(c.Request.Context(), xl, &board, boardForm.Cmd, userId, interviewId)
 		if err != nil {
 			xl.Errorf("board transit state error:%v", err)
-			respErr := model.NewResponseErrorValidation(err)
+			respErr := model.NewResponseErrorValidation(form.Localize(err, model.ContextLanguage(c)))
 			model.NewFailResponse(*respErr).WithRequestID(xl.ReqId).Send(c)
 			return
 		}
@@ -85,10 +86,10 @@ func (v *BoardHandlerApi) CreateOrUpdateBoard(c *gin.Context) {
 			UpdatedAt:     time.Now(),
 		}
 	}
-	err = v.boardService.Upsert(xl, board)
+	err = v.boardService.Upsert(c.Request.Context(), xl, board)
 	if err != nil {
 		xl.Errorf("board service upsert error:%v", err)
-		respErr := model.NewResponseErrorValidation(err)
+		respErr := model.NewResponseErrorValidation(form.Localize(err, model.ContextLanguage(c)))
 		model.NewFailResponse(*respErr).WithRequestID(xl.ReqId).Send(c)
 		return
 	}
@@ -102,13 +103,13 @@ func (v *BoardHandlerApi) GetBoard(c *gin.Context) {
 	xl.Debugf("get board")
 	if interviewId == "" {
 		xl.Errorf("interviewId is missing")
-		respErr := model.NewResponseErrorValidation(form.ErrInterviewIdNeeded)
+		respErr := model.NewResponseErrorValidation(form.Localize(form.ErrInterviewIdNeeded, model.ContextLanguage(c)))
 		model.NewFailResponse(*respErr).WithRequestID(xl.ReqId).Send(c)
 		return
 	}
 	var res interface{}
 	var err error
-	res, err = v.boardService.GetOneByID(v.xl, interviewId)
+	res, err = v.boardService.GetOneByID(c.Request.Context(), v.xl, interviewId)
 	if err != nil {
 		var respErr *model.ResponseError
 		xl.Errorf("board service get error:%v", err)
@@ -116,7 +117,7 @@ func (v *BoardHandlerApi) GetBoard(c *gin.Context) {
 		case errors.Is(err, mgo.ErrNotFound):
 			respErr = model.NewResponseErrorNoSuchBoard()
 		default:
-			respErr = model.NewResponseErrorValidation(err)
+			respErr = model.NewResponseErrorValidation(form.Localize(err, model.ContextLanguage(c)))
 		}
 		model.NewFailResponse(*respErr).WithRequestID(xl.ReqId).Send(c)
 		return
@@ -125,7 +126,7 @@ func (v *BoardHandlerApi) GetBoard(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
-func (v *BoardHandlerApi) boardStateTransition(b *model.BoardDo, action model.BoardCmd, userId string, interviewId string) error {
+func (v *BoardHandlerApi) boardStateTransition(ctx context.Context, xl *xlog.Logger, b *model.BoardDo, action model.BoardCmd, userId string, interviewId string) error {
 	switch {
 	case userId == b.CurrentUserID:
 		v.xl.Debugf("permit cmd %v from owner %v", action, userId)
@@ -149,21 +150,21 @@ func (v *BoardHandlerApi) boardStateTransition(b *model.BoardDo, action model.Bo
 		// db中 不在线是可靠的，在线者中可能包含不在线者
 		// rtc中 未知
 		owner := b.CurrentUserID
-		onlineExistence := v.rtcService.Online(interviewId, owner)
-		dbExistence := v.interviewService.Online(v.xl, interviewId, owner)
+		onlineExistence := v.rtcService.Online(ctx, xl, interviewId, owner)
+		dbExistence := v.interviewService.Online(ctx, v.xl, interviewId, owner)
 		v.xl.Debugf("db existence:%v, online existence:%v", dbExistence, onlineExistence)
 		switch {
 		case dbExistence == true && onlineExistence == false:
 			b.CurrentUserID = userId
 			v.xl.Debugf("board %v current user change to %v", b.ID, b.CurrentUserID)
-			return v.boardStateTransition(b, action, userId, interviewId)
+			return v.boardStateTransition(ctx, xl, b, action, userId, interviewId)
 		case dbExistence == true && onlineExistence == true:
 			v.xl.Debugf("borad occupied by user %v", b.CurrentUserID)
 			return form.ErrBoardLocked
 		case dbExistence == false:
 			b.CurrentUserID = userId
 			v.xl.Debugf("board %v current user change to %v", b.ID, b.CurrentUserID)
-			return v.boardStateTransition(b, action, userId, interviewId)
+			return v.boardStateTransition(ctx, xl, b, action, userId, interviewId)
 		}
 		return fmt.Errorf("逻辑错误")
 	}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha1"
 	"encoding/base64"
@@ -14,6 +15,7 @@ import (
 	"github.com/solutions/niu-cube/internal/service/cloud"
 	"github.com/solutions/niu-cube/internal/service/dao"
 	"github.com/solutions/niu-cube/internal/service/db"
+	"github.com/solutions/niu-cube/internal/service/web/middleware"
 	"io"
 	"math"
 	"net/http"
@@ -71,9 +73,8 @@ type ExamApi interface {
 
 	RunOnstart()
 
-	SyncExamList(userId string)
-
-	SyncByPhone(context *gin.Context)
+	// SyncExamList 为用户补齐所有考试的考试记录，返回第一个失败的错误
+	SyncExamList(ctx context.Context, userId string) error
 }
 
 type ExamApiHandler struct {
@@ -138,7 +139,7 @@ type CreateExamInput struct {
 func (e *ExamApiHandler) CreateExam(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
-	userId := context.GetString(model.UserIDContextKey)
+	userId := model.ContextCallerID(context)
 	inputs := CreateExamInput{}
 	inputs.Type = "default"
 	err := context.BindJSON(&inputs)
@@ -158,7 +159,7 @@ func (e *ExamApiHandler) CreateExam(context *gin.Context) {
 	}
 	// 添加所有用户
 	if len(inputs.Examinees) == 0 {
-		allUsers, _ := e.baseUserDao.ListAll()
+		allUsers, _ := e.baseUserDao.ListAll(context.Request.Context())
 		for idx := range allUsers {
 			inputs.Examinees = append(inputs.Examinees, allUsers[idx].Id)
 		}
@@ -175,11 +176,11 @@ func (e *ExamApiHandler) CreateExam(context *gin.Context) {
 		Status: model.ExamCreated,
 	}
 	// TODO 处理err
-	_ = e.examDao.Insert(&exam)
+	_ = e.examDao.Insert(context.Request.Context(), &exam)
 	totalScore := 0.0
 	for idx := range inputs.Paper.QuestionList {
 		questionId := inputs.Paper.QuestionList[idx]
-		question, _ := e.questionDao.Select(questionId)
+		question, _ := e.questionDao.Select(context.Request.Context(), questionId)
 		totalScore += question.Score
 	}
 	paper := model.ExamPaperDo{
@@ -189,7 +190,7 @@ func (e *ExamApiHandler) CreateExam(context *gin.Context) {
 		TotalScore:   int(totalScore),
 	}
 	// TODO 处理err
-	_ = e.examPaperDao.Insert(&paper)
+	_ = e.examPaperDao.Insert(context.Request.Context(), &paper)
 	for idx := range inputs.Examinees {
 		userExam := model.UserExamDo{
 			UserId:      inputs.Examinees[idx],
@@ -197,15 +198,13 @@ func (e *ExamApiHandler) CreateExam(context *gin.Context) {
 			ExamPaperId: paper.Id,
 			RoomId:      "",
 		}
-		_ = e.userExamDao.Insert(&userExam)
+		_ = e.userExamDao.Insert(context.Request.Context(), &userExam)
 	}
 	utils.TimedTask(exam.BgnTime, func() {
-		exam.Status = model.ExamInProgress
-		_ = e.examDao.Update(&exam)
+		e.updateExamStatus(&exam, model.ExamInProgress)
 	})
 	utils.TimedTask(exam.EndTime, func() {
-		exam.Status = model.ExamFinished
-		_ = e.examDao.Update(&exam)
+		e.updateExamStatus(&exam, model.ExamFinished)
 	})
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
@@ -234,21 +233,21 @@ func (e *ExamApiHandler) DeleteExam(context *gin.Context) {
 		return
 	}
 	examId := input["examId"].(string)
-	_ = e.examDao.Delete(examId)
-	userExams, _ := e.userExamDao.ListByExamId0(examId)
+	_ = e.examDao.Delete(context.Request.Context(), examId)
+	userExams, _ := e.userExamDao.ListByExamId0(context.Request.Context(), examId)
 	for idx := range userExams {
 		userExams[idx].Status = model.UserExamDestroyed
-		_ = e.userExamDao.Update(&userExams[idx])
+		_ = e.userExamDao.Update(context.Request.Context(), &userExams[idx])
 	}
-	examPapers, _ := e.examPaperDao.ListByExamId(examId)
+	examPapers, _ := e.examPaperDao.ListByExamId(context.Request.Context(), examId)
 	for idx := range examPapers {
 		examPapers[idx].Status = model.ExamPaperUnAvailable
-		_ = e.examPaperDao.Update(&examPapers[idx])
+		_ = e.examPaperDao.Update(context.Request.Context(), &examPapers[idx])
 	}
-	answerPapers, _ := e.answerPaperDao.ListByExamId0(examId)
+	answerPapers, _ := e.answerPaperDao.ListByExamId0(context.Request.Context(), examId)
 	for idx := range answerPapers {
 		answerPapers[idx].Status = model.AnswerPaperUnavailable
-		_ = e.answerPaperDao.Update(&answerPapers[idx])
+		_ = e.answerPaperDao.Update(context.Request.Context(), &answerPapers[idx])
 	}
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
@@ -276,7 +275,7 @@ func (e *ExamApiHandler) UpdateExam(context *gin.Context) {
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	exam, _ := e.examDao.Select(inputs.ExamId)
+	exam, _ := e.examDao.Select(context.Request.Context(), inputs.ExamId)
 	if exam == nil {
 		resp := &model.Response{
 			Code:    model.ResponseErrorBadRequest,
@@ -307,12 +306,12 @@ func (e *ExamApiHandler) UpdateExam(context *gin.Context) {
 		exam.Desc = inputs.Desc
 	}
 	if len(inputs.Paper.QuestionList) != 0 {
-		examPapers, _ := e.examPaperDao.ListByExamId(exam.Id)
+		examPapers, _ := e.examPaperDao.ListByExamId(context.Request.Context(), exam.Id)
 		examPapers[0].QuestionList = inputs.Paper.QuestionList
 		examPapers[0].Name = inputs.Paper.Name
-		_ = e.examPaperDao.Update(&examPapers[0])
+		_ = e.examPaperDao.Update(context.Request.Context(), &examPapers[0])
 	}
-	_ = e.examDao.Update(exam)
+	_ = e.examDao.Update(context.Request.Context(), exam)
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
 		Message: string(model.ResponseStatusMessageSuccess),
@@ -337,7 +336,7 @@ func (e *ExamApiHandler) GetExamInfo(context *gin.Context) {
 	requestId := xl.ReqId
 	examId := context.Param("examId")
 	// TODO err
-	exam, _ := e.examDao.Select(examId)
+	exam, _ := e.examDao.Select(context.Request.Context(), examId)
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
 		Message: string(model.ResponseStatusMessageSuccess),
@@ -365,12 +364,11 @@ func (e *ExamApiHandler) GetExamExaminees(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
 	examId := context.Param("examId")
-	pageNum, _ := strconv.Atoi(context.DefaultQuery("pageNum", "1"))
-	pageSize, _ := strconv.Atoi(context.DefaultQuery("pageSize", "10"))
-	userExams, total, _ := e.userExamDao.ListByExamId(examId, int64(pageNum), int64(pageSize))
+	page := model.ContextPageQuery(context)
+	userExams, pageRes, _ := e.userExamDao.ListByExamId(context.Request.Context(), examId, page)
 	list := make([]ExamExamineesResult, 0, len(userExams))
 	for idx := range userExams {
-		user, _ := e.baseUserDao.Select(e.logger, userExams[idx].UserId)
+		user, _ := e.baseUserDao.Select(context.Request.Context(), e.logger, userExams[idx].UserId)
 		if userExams[idx].RoomId == "" {
 			list = append(list, ExamExamineesResult{
 				UserId:          user.Id,
@@ -410,10 +408,6 @@ func (e *ExamApiHandler) GetExamExaminees(context *gin.Context) {
 			list = append(list, result)
 		}
 	}
-	isEnd := false
-	if len(userExams) != pageSize {
-		isEnd = true
-	}
 	type TempListResult struct {
 		ListResult
 		Timestamp int64 `json:"timestamp"`
@@ -424,13 +418,14 @@ func (e *ExamApiHandler) GetExamExaminees(context *gin.Context) {
 		Message: string(model.ResponseStatusMessageSuccess),
 		Data: TempListResult{
 			ListResult: ListResult{
-				Total:          total,
+				Total:          int64(pageRes.Total),
 				NextId:         "",
 				Cnt:            int64(len(userExams)),
-				CurrentPageNum: int64(pageNum),
-				NextPageNum:    int64(pageNum + 1),
-				PageSize:       int64(pageSize),
-				EndPage:        isEnd,
+				CurrentPageNum: int64(page.PageNum),
+				NextPageNum:    int64(page.PageNum + 1),
+				PageSize:       int64(page.PageSize),
+				EndPage:        pageRes.EndPage,
+				NextCursor:     pageRes.NextCursor,
 				List:           list,
 			},
 		},
@@ -452,7 +447,7 @@ func (e *ExamApiHandler) JoinExam(context *gin.Context) {
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	exam, _ := e.examDao.Select(inputs.ExamId)
+	exam, _ := e.examDao.Select(context.Request.Context(), inputs.ExamId)
 	if exam == nil {
 		// TODO 处理空
 		return
@@ -473,7 +468,7 @@ func (e *ExamApiHandler) JoinExam(context *gin.Context) {
 		return
 	}
 	// TODO err
-	userExam, _ := e.userExamDao.SelectByExamIdUserId(inputs.ExamId, userId)
+	userExam, _ := e.userExamDao.SelectByExamIdUserId(context.Request.Context(), inputs.ExamId, userId)
 	// 禁止重复参加
 	if userExam.Status == model.UserExamFinished {
 		resp := &model.Response{
@@ -492,7 +487,7 @@ func (e *ExamApiHandler) JoinExam(context *gin.Context) {
 	userExam.RoomId = inputs.RoomId
 	userExam.Status = model.UserExamInProgress
 	// TODO 处理err
-	_ = e.userExamDao.Update(userExam)
+	_ = e.userExamDao.Update(context.Request.Context(), userExam)
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
 		Message: string(model.ResponseStatusMessageSuccess),
@@ -518,11 +513,11 @@ func (e *ExamApiHandler) GetExamPaper(context *gin.Context) {
 	userId := context.GetString(model.UserIDContextKey)
 	examId := context.Param("examId")
 	// TODO err
-	exam, _ := e.examDao.Select(examId)
+	exam, _ := e.examDao.Select(context.Request.Context(), examId)
 	if exam.Status != model.ExamInProgress {
 		resp := &model.Response{
 			Code:    model.ResponseErrorExamTimeNotMatch,
-			Message: "时间不匹配",
+			Message: "考试时间不匹配",
 			Data: struct {
 			}{},
 			RequestID: requestId,
@@ -531,16 +526,16 @@ func (e *ExamApiHandler) GetExamPaper(context *gin.Context) {
 		return
 	}
 	// TODO 处理err
-	userExam, _ := e.userExamDao.SelectByExamIdUserId(examId, userId)
+	userExam, _ := e.userExamDao.SelectByExamIdUserId(context.Request.Context(), examId, userId)
 	// TODO 处理err
-	examPaper, _ := e.examPaperDao.Select(userExam.ExamPaperId)
+	examPaper, _ := e.examPaperDao.Select(context.Request.Context(), userExam.ExamPaperId)
 	result := ExamPaperResult{
 		PaperName:    examPaper.Name,
 		TotalScore:   examPaper.TotalScore,
 		QuestionList: make([]model.QuestionDo, len(examPaper.QuestionList), len(examPaper.QuestionList)),
 	}
 	for idx := range examPaper.QuestionList {
-		tmp, _ := e.questionDao.Select(examPaper.QuestionList[idx])
+		tmp, _ := e.questionDao.Select(context.Request.Context(), examPaper.QuestionList[idx])
 		result.QuestionList[idx] = *tmp
 	}
 	resp := &model.Response{
@@ -565,14 +560,14 @@ func (e *ExamApiHandler) LeaveExam(context *gin.Context) {
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	userExam, _ := e.userExamDao.SelectByExamIdUserId(inputs.ExamId, userId)
+	userExam, _ := e.userExamDao.SelectByExamIdUserId(context.Request.Context(), inputs.ExamId, userId)
 	if userExam == nil {
 		// TODO 处理空
 		return
 	}
 	userExam.Status = model.UserExamFinished
 	// TODO 处理err
-	_ = e.userExamDao.Update(userExam)
+	_ = e.userExamDao.Update(context.Request.Context(), userExam)
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
 		Message: string(model.ResponseStatusMessageSuccess),
@@ -608,7 +603,7 @@ func (e *ExamApiHandler) CommitExamAnswer(context *gin.Context) {
 		return
 	}
 	// TODO err
-	exam, _ := e.examDao.Select(inputs.ExamId)
+	exam, _ := e.examDao.Select(context.Request.Context(), inputs.ExamId)
 	if exam.Status != model.ExamInProgress {
 		resp := &model.Response{
 			Code:    model.ResponseErrorExamTimeNotMatch,
@@ -623,7 +618,7 @@ func (e *ExamApiHandler) CommitExamAnswer(context *gin.Context) {
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	answerPaper, _ := e.answerPaperDao.SelectByExamIdUserId(inputs.ExamId, userId)
+	answerPaper, _ := e.answerPaperDao.SelectByExamIdUserId(context.Request.Context(), inputs.ExamId, userId)
 	if answerPaper == nil {
 		answerPaper = &model.AnswerPaperDo{
 			UserId:     userId,
@@ -631,7 +626,7 @@ func (e *ExamApiHandler) CommitExamAnswer(context *gin.Context) {
 			AnswerList: make([]model.AnswerDo, 0, len(inputs.AnswerList)),
 		}
 		// TODO err
-		_ = e.answerPaperDao.Insert(answerPaper)
+		_ = e.answerPaperDao.Insert(context.Request.Context(), answerPaper)
 	}
 	m := make(map[string]model.AnswerDo)
 	for idx := range answerPaper.AnswerList {
@@ -640,7 +635,7 @@ func (e *ExamApiHandler) CommitExamAnswer(context *gin.Context) {
 	for idx := range inputs.AnswerList {
 		answer := model.AnswerDo{}
 		// TODO err
-		question, _ := e.questionDao.Select(inputs.AnswerList[idx].QuestionId)
+		question, _ := e.questionDao.Select(context.Request.Context(), inputs.AnswerList[idx].QuestionId)
 		// TODO Demo暂时设定为满分
 		answer.Score = question.Score
 		answer.QuestionId = question.Id
@@ -662,7 +657,7 @@ func (e *ExamApiHandler) CommitExamAnswer(context *gin.Context) {
 		answerPaper.AnswerList = append(answerPaper.AnswerList, v)
 	}
 	// TODO err
-	_ = e.answerPaperDao.Update(answerPaper)
+	_ = e.answerPaperDao.Update(context.Request.Context(), answerPaper)
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
 		Message: string(model.ResponseStatusMessageSuccess),
@@ -701,20 +696,29 @@ func (e *ExamApiHandler) GetExamAnswerDetails(context *gin.Context) {
 	requestId := xl.ReqId
 	userId := context.GetString(model.UserIDContextKey)
 	examId := context.Param("examId")
-	userId0 := context.Param("userId")
-	if userId0 != "/" {
+	userId0 := strings.TrimPrefix(context.Param("userId"), "/")
+	if userId0 != "" && userId0 != userId {
+		// 查看他人的答卷需要阅卷权限。
+		account, _, err := model.ContextAccount(context)
+		if err != nil || !middleware.HasPermission(account, model.PermissionExamReview) {
+			xl.Infof("user %s is not allowed to view answers of user %s in exam %s, error %v", userId, userId0, examId, err)
+			responseErr := model.NewResponseErrorPermissionDenied()
+			resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
+			context.JSON(http.StatusOK, resp)
+			return
+		}
 		userId = userId0
 	}
 	// TODO err
-	answerPaper, _ := e.answerPaperDao.SelectByExamIdUserId(examId, userId)
+	answerPaper, _ := e.answerPaperDao.SelectByExamIdUserId(context.Request.Context(), examId, userId)
 	answerPaper.TotalScore = 0
 	for idx := range answerPaper.AnswerList {
 		answerPaper.TotalScore += answerPaper.AnswerList[idx].Score
 	}
 	// TODO err
-	_ = e.answerPaperDao.Update(answerPaper)
-	userExam, _ := e.userExamDao.SelectByExamIdUserId(examId, userId)
-	examPaper, _ := e.examPaperDao.Select(userExam.ExamPaperId)
+	_ = e.answerPaperDao.Update(context.Request.Context(), answerPaper)
+	userExam, _ := e.userExamDao.SelectByExamIdUserId(context.Request.Context(), examId, userId)
+	examPaper, _ := e.examPaperDao.Select(context.Request.Context(), userExam.ExamPaperId)
 	result := ExamAnswerDetailsResult{
 		PaperName:  examPaper.Name,
 		TotalScore: answerPaper.TotalScore,
@@ -723,7 +727,7 @@ func (e *ExamApiHandler) GetExamAnswerDetails(context *gin.Context) {
 	for idx := range answerPaper.AnswerList {
 		result.List[idx].QuestionId = answerPaper.AnswerList[idx].QuestionId
 		questionTmp := &result.List[idx].Question
-		question, _ := e.questionDao.Select(answerPaper.AnswerList[idx].QuestionId)
+		question, _ := e.questionDao.Select(context.Request.Context(), answerPaper.AnswerList[idx].QuestionId)
 		answerTmp := &result.List[idx].Question.Answer
 		answer := &answerPaper.AnswerList[idx]
 		questionTmp.Type = question.Type
@@ -761,6 +765,7 @@ type ListResult struct {
 	NextPageNum    int64       `json:"nextPageNum"`
 	PageSize       int64       `json:"pageSize"`
 	EndPage        bool        `json:"endPage"`
+	NextCursor     string      `json:"nextCursor"`
 	List           interface{} `json:"list"`
 }
 
@@ -768,17 +773,12 @@ func (e *ExamApiHandler) ListExamStudent(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
 	userId := context.GetString(model.UserIDContextKey)
-	pageNum, _ := strconv.Atoi(context.DefaultQuery("pageNum", "1"))
-	pageSize, _ := strconv.Atoi(context.DefaultQuery("pageSize", "10"))
+	page := model.ContextPageQuery(context)
 	// TODO err
-	userExams, total, _ := e.userExamDao.ListByUserId(userId, int64(pageNum), int64(pageSize))
-	isEnd := false
-	if len(userExams) != pageSize {
-		isEnd = true
-	}
+	userExams, pageRes, _ := e.userExamDao.ListByUserId(context.Request.Context(), userId, page)
 	exams := make([]ExamResult, 0, len(userExams))
 	for idx := range userExams {
-		temp, _ := e.examDao.Select(userExams[idx].ExamId)
+		temp, _ := e.examDao.Select(context.Request.Context(), userExams[idx].ExamId)
 		if temp == nil {
 			continue
 		}
@@ -788,13 +788,14 @@ func (e *ExamApiHandler) ListExamStudent(context *gin.Context) {
 		Code:    int(model.ResponseStatusCodeSuccess),
 		Message: string(model.ResponseStatusMessageSuccess),
 		Data: ListResult{
-			Total:          total,
+			Total:          int64(pageRes.Total),
 			NextId:         "",
 			Cnt:            int64(len(exams)),
-			CurrentPageNum: int64(pageNum),
-			NextPageNum:    int64(pageNum + 1),
-			PageSize:       int64(pageSize),
-			EndPage:        isEnd,
+			CurrentPageNum: int64(page.PageNum),
+			NextPageNum:    int64(page.PageNum + 1),
+			PageSize:       int64(page.PageSize),
+			EndPage:        pageRes.EndPage,
+			NextCursor:     pageRes.NextCursor,
 			List:           exams,
 		},
 		RequestID: requestId,
@@ -811,20 +812,15 @@ func (e *ExamApiHandler) ListExamTeacher(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
 	// userId := context.GetString(model.UserIDContextKey)
-	pageNum, _ := strconv.Atoi(context.DefaultQuery("pageNum", "1"))
-	pageSize, _ := strconv.Atoi(context.DefaultQuery("pageSize", "10"))
+	page := model.ContextPageQuery(context)
 	// TODO err
-	examList, total, _ := e.examDao.ListAll(int64(pageNum), int64(pageSize))
-	isEnd := false
-	if len(examList) != pageSize {
-		isEnd = true
-	}
+	examList, pageRes, _ := e.examDao.ListAll(context.Request.Context(), page)
 	exams := make([]ExamResult, 0, len(examList))
 	for idx := range examList {
 		if examList[idx].Status == model.ExamDestroyed {
 			continue
 		}
-		examPapers, _ := e.examPaperDao.ListByExamId(examList[idx].Id)
+		examPapers, _ := e.examPaperDao.ListByExamId(context.Request.Context(), examList[idx].Id)
 		e := *examConverter(&examList[idx])
 		e.Paper = &struct {
 			PaperName    string   `json:"paperName"`
@@ -843,13 +839,14 @@ func (e *ExamApiHandler) ListExamTeacher(context *gin.Context) {
 		Code:    int(model.ResponseStatusCodeSuccess),
 		Message: string(model.ResponseStatusMessageSuccess),
 		Data: ListResult{
-			Total:          total,
+			Total:          int64(pageRes.Total),
 			NextId:         "",
 			Cnt:            int64(len(exams)),
-			CurrentPageNum: int64(pageNum),
-			NextPageNum:    int64(pageNum + 1),
-			PageSize:       int64(pageSize),
-			EndPage:        isEnd,
+			CurrentPageNum: int64(page.PageNum),
+			NextPageNum:    int64(page.PageNum + 1),
+			PageSize:       int64(page.PageSize),
+			EndPage:        pageRes.EndPage,
+			NextCursor:     pageRes.NextCursor,
 			List:           exams,
 		},
 		RequestID: requestId,
@@ -863,28 +860,29 @@ func (e *ExamApiHandler) QuestionList(context *gin.Context) {
 	pageNum, _ := strconv.Atoi(context.DefaultQuery("pageNum", "1"))
 	pageSize, _ := strconv.Atoi(context.DefaultQuery("pageSize", "10"))
 	var questions []model.QuestionDo
-	var total int64
-	isEnd := false
+	var pageRes model.PageResult
 	if pageNum == -1 && pageSize == -1 {
-		questions, total, _ = e.questionDao.ListAll0()
-		isEnd = true
+		// pageNum、pageSize均为-1时返回全部题目。
+		var total int64
+		questions, total, _ = e.questionDao.ListAll0(context.Request.Context())
+		pageRes = model.PageResult{Total: int(total), EndPage: true}
 	} else {
-		questions, total, _ = e.questionDao.ListAll(int64(pageNum), int64(pageSize))
-		if len(questions) != pageSize {
-			isEnd = true
-		}
+		page := model.ContextPageQuery(context)
+		pageNum, pageSize = page.PageNum, page.PageSize
+		questions, pageRes, _ = e.questionDao.ListAll(context.Request.Context(), page)
 	}
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
 		Message: string(model.ResponseStatusMessageSuccess),
 		Data: ListResult{
-			Total:          total,
+			Total:          int64(pageRes.Total),
 			NextId:         "",
 			Cnt:            int64(len(questions)),
 			CurrentPageNum: int64(pageNum),
 			NextPageNum:    int64(pageNum + 1),
 			PageSize:       int64(pageSize),
-			EndPage:        isEnd,
+			EndPage:        pageRes.EndPage,
+			NextCursor:     pageRes.NextCursor,
 			List:           questions,
 		},
 		RequestID: requestId,
@@ -939,7 +937,7 @@ func (e *ExamApiHandler) AddQuestion(context *gin.Context) {
 		} else if question.Type == model.Text {
 			question.Answer.Text = inputs[idx].Question.Answer.TextList[0]
 		}
-		_ = e.questionDao.Insert(&question)
+		_ = e.questionDao.Insert(context.Request.Context(), &question)
 	}
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
@@ -969,7 +967,7 @@ func (e *ExamApiHandler) DeleteQuestion(context *gin.Context) {
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	_ = e.questionDao.Delete(input.QuestionId)
+	_ = e.questionDao.Delete(context.Request.Context(), input.QuestionId)
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
 		Message: string(model.ResponseStatusMessageSuccess),
@@ -1036,7 +1034,7 @@ func (e *ExamApiHandler) UploadCheatingEvent(context *gin.Context) {
 		Action: event.Event.Action,
 		Value:  event.Event.Value,
 	}
-	_ = e.cheatingExamDao.Insert(&cheatingEvent)
+	_ = e.cheatingExamDao.Insert(context.Request.Context(), &cheatingEvent)
 	bytes, _ := json.Marshal(cheatingEvent)
 	bytes = append(bytes, byte('\n'))
 	_ = e.appendToLogFile(bytes)
@@ -1095,7 +1093,7 @@ func (e *ExamApiHandler) MoreCheatingEvent(context *gin.Context) {
 		}, 0, len(moreCheatingEvent.UserList)),
 	}
 	for idx := range moreCheatingEvent.UserList {
-		cheatingEventList, _ := e.cheatingExamDao.ListByExamIdUserId(moreCheatingEvent.ExamId, moreCheatingEvent.UserList[idx], moreCheatingEvent.LastTimestamp, result.Timestamp)
+		cheatingEventList, _ := e.cheatingExamDao.ListByExamIdUserId(context.Request.Context(), moreCheatingEvent.ExamId, moreCheatingEvent.UserList[idx], moreCheatingEvent.LastTimestamp, result.Timestamp)
 		eventList := make([]struct {
 			Action    string `json:"action"`
 			Value     string `json:"value"`
@@ -1205,10 +1203,10 @@ func (e *ExamApiHandler) Clear(context *gin.Context) {
 	requestId := xl.ReqId
 	userId := context.GetString(model.UserIDContextKey)
 	e.logger.Infof("user: %s try to clear db.", userId)
-	e.examDao.DeleteAll()
-	e.userExamDao.DeleteAll()
-	e.examPaperDao.DeleteAll()
-	e.answerPaperDao.DeleteAll()
+	e.examDao.DeleteAll(context.Request.Context())
+	e.userExamDao.DeleteAll(context.Request.Context())
+	e.examPaperDao.DeleteAll(context.Request.Context())
+	e.answerPaperDao.DeleteAll(context.Request.Context())
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
 		Message: string(model.ResponseStatusMessageSuccess),
@@ -1220,7 +1218,8 @@ func (e *ExamApiHandler) Clear(context *gin.Context) {
 }
 
 func (e *ExamApiHandler) RunOnstart() {
-	exams, _ := e.examDao.ListAll0()
+	ctx := context.Background()
+	exams, _ := e.examDao.ListAll0(ctx)
 	for idx := range exams {
 		// 复制
 		exam := exams[idx]
@@ -1231,18 +1230,16 @@ func (e *ExamApiHandler) RunOnstart() {
 			if exam.Status == model.ExamDestroyed {
 				return
 			}
-			exam.Status = model.ExamInProgress
-			_ = e.examDao.Update(&exam)
+			e.updateExamStatus(&exam, model.ExamInProgress)
 		})
 		utils.TimedTask(exam.EndTime, func() {
 			if exam.Status == model.ExamDestroyed {
 				return
 			}
-			exam.Status = model.ExamFinished
-			_ = e.examDao.Update(&exam)
+			e.updateExamStatus(&exam, model.ExamFinished)
 		})
 	}
-	// e.examDao.DeleteAll0()
+	// e.examDao.DeleteAll0(ctx)
 	t := "single_choice"
 	question := model.QuestionDo{
 		Type:  t,
@@ -1262,8 +1259,8 @@ func (e *ExamApiHandler) RunOnstart() {
 			Text:       "",
 		},
 	}
-	e.questionDao.Select(question.Id)
-	// e.questionDao.Insert(&question)
+	e.questionDao.Select(ctx, question.Id)
+	// e.questionDao.Insert(ctx, &question)
 	question = model.QuestionDo{
 		Type:  t,
 		Score: 10,
@@ -1282,7 +1279,7 @@ func (e *ExamApiHandler) RunOnstart() {
 			Text:       "",
 		},
 	}
-	// e.questionDao.Insert(&question)
+	// e.questionDao.Insert(ctx, &question)
 	question = model.QuestionDo{
 		Type:  t,
 		Score: 10,
@@ -1301,7 +1298,7 @@ func (e *ExamApiHandler) RunOnstart() {
 			Text:       "",
 		},
 	}
-	// e.questionDao.Insert(&question)
+	// e.questionDao.Insert(ctx, &question)
 	question = model.QuestionDo{
 		Type:  t,
 		Score: 10,
@@ -1320,7 +1317,7 @@ func (e *ExamApiHandler) RunOnstart() {
 			Text:       "",
 		},
 	}
-	// e.questionDao.Insert(&question)
+	// e.questionDao.Insert(ctx, &question)
 	question = model.QuestionDo{
 		Type:  t,
 		Score: 10,
@@ -1339,7 +1336,13 @@ func (e *ExamApiHandler) RunOnstart() {
 			Text:       "",
 		},
 	}
-	// e.questionDao.Insert(&question)
+	// e.questionDao.Insert(ctx, &question)
+}
+
+// updateExamStatus 在考试开始、结束时由定时任务调用，此时创建考试的请求已经结束，不能使用请求的ctx。
+func (e *ExamApiHandler) updateExamStatus(exam *model.ExamDo, status int) {
+	exam.Status = status
+	_ = e.examDao.Update(context.Background(), exam)
 }
 
 func (e *ExamApiHandler) appendToLogFile(content []byte) error {
@@ -1347,18 +1350,22 @@ func (e *ExamApiHandler) appendToLogFile(content []byte) error {
 	return err
 }
 
-func (e *ExamApiHandler) SyncExamList(userId string) {
-	examDos, err := e.examDao.ListAll0()
+func (e *ExamApiHandler) SyncExamList(ctx context.Context, userId string) error {
+	examDos, err := e.examDao.ListAll0(ctx)
 	if err != nil {
 		e.logger.Error(err)
-		return
+		return err
 	}
+	var syncErr error
 	for i := range examDos {
-		userExamDo, _ := e.userExamDao.SelectByExamIdUserId(examDos[i].Id, userId)
+		userExamDo, _ := e.userExamDao.SelectByExamIdUserId(ctx, examDos[i].Id, userId)
 		if userExamDo == nil {
-			examPaperDos, err := e.examPaperDao.ListByExamId(examDos[i].Id)
+			examPaperDos, err := e.examPaperDao.ListByExamId(ctx, examDos[i].Id)
 			if err != nil {
 				e.logger.Error(err)
+				if syncErr == nil {
+					syncErr = err
+				}
 				continue
 			}
 			if len(examPaperDos) == 0 {
@@ -1371,31 +1378,13 @@ func (e *ExamApiHandler) SyncExamList(userId string) {
 				ExamPaperId: examPaperDos[0].Id,
 				RoomId:      "",
 			}
-			_ = e.userExamDao.Insert(&userExam)
-		}
-	}
-}
-
-func (e *ExamApiHandler) SyncByPhone(context *gin.Context) {
-	phone := context.Param("phone")
-	accountDo, err := e.accountDao.GetAccountByPhone(nil, phone)
-	if err != nil {
-		context.JSON(200, "ok")
-	}
-	userDo, _ := e.baseUserDao.Select(nil, accountDo.ID)
-	if userDo == nil {
-		baseUser := model.BaseUserDo{
-			Id:            accountDo.ID,
-			Name:          accountDo.Nickname,
-			Nickname:      accountDo.Nickname,
-			Avatar:        accountDo.Avatar,
-			Status:        model.BaseUserLogin,
-			Profile:       "",
-			BaseUserAttrs: make([]model.BaseEntryDo, 0, 0),
+			err = e.userExamDao.Insert(ctx, &userExam)
+			if err != nil && syncErr == nil {
+				syncErr = err
+			}
 		}
-		e.baseUserDao.Insert(nil, &baseUser)
 	}
-	e.SyncExamList(userDo.Id)
+	return syncErr
 }
 
 type ExamResult struct {
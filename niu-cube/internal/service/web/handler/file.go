@@ -46,7 +46,7 @@ func (h *FileApiHandler) Upload(c *gin.Context) {
 
 	fileName := file.Filename
 	xl.Info("fileName is:", fileName)
-	url, err := h.weixin.UploadFile(file)
+	url, err := h.weixin.UploadFile(c.Request.Context(), file)
 	if err != nil {
 		xl.Errorf("failed to Upload, error %v", err)
 		responseErr := model.NewResponseErrorInternal()
@@ -60,10 +60,10 @@ func (h *FileApiHandler) Upload(c *gin.Context) {
 		FileUrl:  url,
 		Status:   model.ImageFileStatusNormal,
 	}
-	imageFile, err := h.imageFileDao.InsertImageFile(xl, imageFileDo)
+	imageFile, err := h.imageFileDao.InsertImageFile(c.Request.Context(), xl, imageFileDo)
 	if err != nil {
 		xl.Errorf("InsertImageFile, error %v", err)
-		responseErr := model.NewResponseErrorInternal()
+		responseErr := model.NewResponseErrorDatabase(err)
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
 		c.JSON(http.StatusOK, resp)
 		return
@@ -84,11 +84,11 @@ func (h *FileApiHandler) RecentImage(c *gin.Context) {
 	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
 	requestID := xl.ReqId
 
-	imageFile, err := h.imageFileDao.SelectRecentImage(xl)
+	imageFile, err := h.imageFileDao.SelectRecentImage(c.Request.Context(), xl)
 
 	if err != nil {
 		xl.Errorf("SelectRecentImage, error %v", err)
-		responseErr := model.NewResponseErrorInternal()
+		responseErr := model.NewResponseErrorDatabase(err)
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
 		c.JSON(http.StatusOK, resp)
 		return
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/solutions/niu-cube/internal/common/utils"
+	"github.com/solutions/niu-cube/internal/service/cloud"
+)
+
+const (
+	HealthStatusOK       = "ok"
+	HealthStatusFail     = "fail"
+	HealthStatusDraining = "draining"
+)
+
+// HealthChecker 就绪检查的一项依赖。
+type HealthChecker interface {
+	Name() string
+	Check() error
+}
+
+type healthCheckFunc struct {
+	name  string
+	check func() error
+}
+
+func (f healthCheckFunc) Name() string { return f.name }
+func (f healthCheckFunc) Check() error { return f.check() }
+
+// NewHealthCheck 使用函数创建一项就绪检查。
+func NewHealthCheck(name string, check func() error) HealthChecker {
+	return healthCheckFunc{name: name, check: check}
+}
+
+// ComponentHealth 一项依赖的检查结果。
+type ComponentHealth struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HealthResponse 存活与就绪检查的响应。
+type HealthResponse struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentHealth `json:"components,omitempty"`
+}
+
+// HealthApiHandler 提供/healthz与/readyz，供编排系统探测。
+type HealthApiHandler struct {
+	Checkers []HealthChecker
+	draining int32
+}
+
+// Drain 标记服务正在停止，之后就绪检查始终失败，使负载均衡不再转发新请求。
+func (h *HealthApiHandler) Drain() {
+	atomic.StoreInt32(&h.draining, 1)
+}
+
+// Draining 返回服务是否正在停止。
+func (h *HealthApiHandler) Draining() bool {
+	return atomic.LoadInt32(&h.draining) == 1
+}
+
+// Healthz 存活检查，进程能处理请求即返回成功。
+func (h *HealthApiHandler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, HealthResponse{Status: HealthStatusOK})
+}
+
+// Readyz 就绪检查，并发检查所有依赖，任一依赖失败或服务正在停止时返回503。
+func (h *HealthApiHandler) Readyz(c *gin.Context) {
+	if h.Draining() {
+		c.JSON(http.StatusServiceUnavailable, HealthResponse{Status: HealthStatusDraining})
+		return
+	}
+	results := make([]ComponentHealth, len(h.Checkers))
+	wg := sync.WaitGroup{}
+	for i, checker := range h.Checkers {
+		wg.Add(1)
+		go func(i int, checker HealthChecker) {
+			defer wg.Done()
+			results[i] = ComponentHealth{Status: HealthStatusOK}
+			if err := checker.Check(); err != nil {
+				results[i] = ComponentHealth{Status: HealthStatusFail, Error: err.Error()}
+			}
+		}(i, checker)
+	}
+	wg.Wait()
+	resp := HealthResponse{Status: HealthStatusOK, Components: make(map[string]ComponentHealth, len(results))}
+	for i, checker := range h.Checkers {
+		resp.Components[checker.Name()] = results[i]
+		if results[i].Status != HealthStatusOK {
+			resp.Status = HealthStatusFail
+		}
+	}
+	status := http.StatusOK
+	if resp.Status != HealthStatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, resp)
+}
+
+// CheckRTCConfig 检查RTC服务所需的配置是否完整。
+func CheckRTCConfig(conf *utils.Config) error {
+	if conf.RTC == nil || conf.RTC.AppID == "" {
+		return errors.New("rtc.app_id is not configured")
+	}
+	if conf.QiniuKeyPair.AccessKey == "" || conf.QiniuKeyPair.SecretKey == "" {
+		return errors.New("qiniu_key_pair is not configured")
+	}
+	return nil
+}
+
+// CheckIMConfig 检查IM服务所需的配置是否完整。
+func CheckIMConfig(conf *utils.Config) error {
+	if conf.IM == nil {
+		return errors.New("im is not configured")
+	}
+	switch conf.IM.Provider {
+	case "test":
+		return nil
+	case "qiniu":
+		qiniu := conf.IM.Qiniu
+		if qiniu == nil || qiniu.AppId == "" || qiniu.AppEndpoint == "" || qiniu.AppToken == "" {
+			return errors.New("im.qiniu app_id, app_endpoint and app_token are required")
+		}
+		return nil
+	default:
+		return errors.New("unsupported im provider " + conf.IM.Provider)
+	}
+}
+
+// CheckSMSConfig 检查主短信服务与备用短信服务所需的配置是否完整。
+func CheckSMSConfig(conf *utils.Config) error {
+	if conf.SMS == nil {
+		return errors.New("sms is not configured")
+	}
+	if err := checkSmsProvider(conf, conf.SMS.Provider); err != nil {
+		return err
+	}
+	if conf.SMS.FallbackProvider != "" {
+		return checkSmsProvider(conf, conf.SMS.FallbackProvider)
+	}
+	return nil
+}
+
+func checkSmsProvider(conf *utils.Config, provider string) error {
+	switch provider {
+	case cloud.SmsProviderTest, cloud.SmsProviderOutbox:
+		return nil
+	case cloud.SmsProviderQiniu:
+		qiniu := conf.SMS.QiniuSMS
+		if qiniu == nil || qiniu.SignatureID == "" || qiniu.TemplateID == "" {
+			return errors.New("sms.qiniu_sms signature_id and template_id are required")
+		}
+		if conf.QiniuKeyPair.AccessKey == "" || conf.QiniuKeyPair.SecretKey == "" {
+			return errors.New("qiniu_key_pair is not configured")
+		}
+		return nil
+	case cloud.SmsProviderHTTP:
+		if conf.SMS.HTTPSMS == nil || conf.SMS.HTTPSMS.URL == "" {
+			return errors.New("sms.http_sms.url is required")
+		}
+		return nil
+	default:
+		return errors.New("unsupported sms provider " + provider)
+	}
+}
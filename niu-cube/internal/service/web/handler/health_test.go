@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/solutions/niu-cube/internal/common/utils"
+)
+
+func serveHealth(t *testing.T, h gin.HandlerFunc) (int, HealthResponse) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	h(c)
+	resp := HealthResponse{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response %q, error %v", recorder.Body.String(), err)
+	}
+	return recorder.Code, resp
+}
+
+func TestReadyz(t *testing.T) {
+	mongoErr := errors.New("no reachable servers")
+	h := &HealthApiHandler{Checkers: []HealthChecker{
+		NewHealthCheck("mongo", func() error { return nil }),
+		NewHealthCheck("im_mongo", func() error { return mongoErr }),
+	}}
+
+	code, resp := serveHealth(t, h.Readyz)
+	if code != http.StatusServiceUnavailable || resp.Status != HealthStatusFail {
+		t.Fatalf("readyz with failed component = %d %+v", code, resp)
+	}
+	if resp.Components["mongo"].Status != HealthStatusOK {
+		t.Fatalf("mongo = %+v", resp.Components["mongo"])
+	}
+	if c := resp.Components["im_mongo"]; c.Status != HealthStatusFail || c.Error != mongoErr.Error() {
+		t.Fatalf("im_mongo = %+v", c)
+	}
+
+	mongoErr = nil
+	h.Checkers[1] = NewHealthCheck("im_mongo", func() error { return nil })
+	if code, resp := serveHealth(t, h.Readyz); code != http.StatusOK || resp.Status != HealthStatusOK || len(resp.Components) != 2 {
+		t.Fatalf("readyz = %d %+v", code, resp)
+	}
+
+	h.Drain()
+	if code, resp := serveHealth(t, h.Readyz); code != http.StatusServiceUnavailable || resp.Status != HealthStatusDraining {
+		t.Fatalf("readyz while draining = %d %+v", code, resp)
+	}
+	if code, resp := serveHealth(t, h.Healthz); code != http.StatusOK || resp.Status != HealthStatusOK {
+		t.Fatalf("healthz while draining = %d %+v", code, resp)
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	conf := &utils.Config{
+		QiniuKeyPair: utils.QiniuKeyPair{AccessKey: "ak", SecretKey: "sk"},
+		RTC:          &utils.QiniuRTCConfig{AppID: "app"},
+		IM:           &utils.IMConfig{Provider: "qiniu", Qiniu: &utils.QiniuIMConfig{AppId: "id", AppEndpoint: "https://im", AppToken: "token"}},
+		SMS:          &utils.SMSConfig{Provider: "qiniu", QiniuSMS: &utils.QiniuSMSConfig{SignatureID: "sig", TemplateID: "tpl"}},
+	}
+	for name, check := range map[string]func(*utils.Config) error{"rtc": CheckRTCConfig, "im": CheckIMConfig, "sms": CheckSMSConfig} {
+		if err := check(conf); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	conf.IM.Qiniu.AppToken = ""
+	if err := CheckIMConfig(conf); err == nil {
+		t.Fatalf("im without app_token passed")
+	}
+	conf.SMS.FallbackProvider = "http"
+	if err := CheckSMSConfig(conf); err == nil {
+		t.Fatalf("fallback http sms without url passed")
+	}
+	conf.QiniuKeyPair.SecretKey = ""
+	if err := CheckRTCConfig(conf); err == nil {
+		t.Fatalf("rtc without secret key passed")
+	}
+	if err := CheckSMSConfig(&utils.Config{}); err == nil {
+		t.Fatalf("missing sms passed")
+	}
+}
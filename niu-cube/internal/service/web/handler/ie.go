@@ -43,9 +43,14 @@ func NewIEHandlerImpl(ieService db.IEService) *IEHandlerImpl {
 // Create ie/create
 func (I IEHandlerImpl) Create(c *gin.Context) {
 	//userId:=c.MustGet(model.UserIDContextKey)
-	user := c.MustGet(model.UserContextKey).(model.AccountDo)
+	user, _, err := model.ContextAccount(c)
+	if err != nil {
+		I.logger(c).Errorf("err get account:%v", err)
+		c.JSON(http.StatusOK, model.NewResponseErrorNoSuchUser())
+		return
+	}
 	args := form.IECreateForm{}
-	err := c.Bind(&args)
+	err = c.Bind(&args)
 	if err != nil {
 		I.logger(c).Errorf("err bind form:%v", err)
 		return
@@ -53,7 +58,7 @@ func (I IEHandlerImpl) Create(c *gin.Context) {
 	err = args.Validate()
 	if err != nil {
 		I.logger(c).Errorf("err validate form:%v form:%v", err, args)
-		c.JSON(http.StatusOK, model.NewResponseErrorValidation(err))
+		c.JSON(http.StatusOK, model.NewResponseErrorValidation(form.Localize(err, model.ContextLanguage(c))))
 		return
 	}
 	room_extra := map[string]interface{}{"roomAvatar": user.Avatar}
@@ -70,7 +75,12 @@ func (I IEHandlerImpl) Create(c *gin.Context) {
 // 需返回麦序上的人 + 房间内的其他人 重点麦序
 func (I IEHandlerImpl) Join(c *gin.Context) {
 	roomId := c.Param("roomId")
-	user := c.MustGet(model.UserContextKey).(model.AccountDo)
+	user, _, err := model.ContextAccount(c)
+	if err != nil {
+		I.logger(c).Errorf("err get account:%v", err)
+		c.JSON(http.StatusOK, model.NewResponseErrorNoSuchUser())
+		return
+	}
 	extra, err := I.ieService.EnterRoom(user, roomId)
 	if err != nil {
 		switch {
@@ -124,7 +134,12 @@ func (I IEHandlerImpl) Join(c *gin.Context) {
 // Leave ie/:roomId/leave
 func (I IEHandlerImpl) Leave(c *gin.Context) {
 	roomId := c.Param("roomId") // TODO add to const
-	user := c.MustGet(model.UserContextKey).(model.AccountDo)
+	user, _, err := model.ContextAccount(c)
+	if err != nil {
+		I.logger(c).Errorf("err get account:%v", err)
+		c.JSON(http.StatusOK, model.NewResponseErrorNoSuchUser())
+		return
+	}
 	if err := I.ieService.LeaveRoom(user, roomId); err != nil {
 		I.logger(c).Errorf("error leave room err:%v", err)
 		c.JSON(http.StatusOK, model.NewResponseErrorNoSuchRoom())
@@ -139,16 +154,20 @@ func (I IEHandlerImpl) End(c *gin.Context) {
 
 }
 
-//
 func (I IEHandlerImpl) Share(c *gin.Context) {
 	c.JSON(http.StatusOK, "implement me")
 }
 
 func (I IEHandlerImpl) Update(c *gin.Context) {
-	user := c.MustGet(model.UserContextKey).(model.AccountDo)
+	user, _, err := model.ContextAccount(c)
+	if err != nil {
+		I.logger(c).Errorf("err get account:%v", err)
+		c.JSON(http.StatusOK, model.NewResponseErrorNoSuchUser())
+		return
+	}
 	roomId := c.Param("roomId")
 	args := form.IEUpadteForm{}
-	err := c.Bind(&args)
+	err = c.Bind(&args)
 	if err != nil {
 		I.logger(c).Errorf("err bind form:%v", err)
 		return
@@ -156,7 +175,7 @@ func (I IEHandlerImpl) Update(c *gin.Context) {
 	err = args.Validate()
 	if err != nil {
 		I.logger(c).Errorf("err validate form:%v form:%v", err, args)
-		c.JSON(http.StatusOK, model.NewResponseErrorValidation(err))
+		c.JSON(http.StatusOK, model.NewResponseErrorValidation(form.Localize(err, model.ContextLanguage(c))))
 		return
 	}
 	err = I.ieService.UpdateRoomNoticeAndTitle(user, roomId, args.Notice, args.Title)
@@ -1,8 +1,7 @@
 package handler
 
 import (
-	"encoding/base64"
-	"encoding/json"
+	"context"
 	"fmt"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/errors"
@@ -24,6 +23,7 @@ type InterviewApiHandler struct {
 	Interview         InterviewInterface
 	taskService       *db.TaskService
 	weixin            *cloud.WeixinService
+	interviewToken    *db.InterviewTokenService
 	RTC               *cloud.RTCService
 	DefaultAvatarURLs []string
 	RequestUrlHost    string
@@ -32,16 +32,16 @@ type InterviewApiHandler struct {
 
 type InterviewInterface interface {
 	// 创建面试
-	CreateInterview(xl *xlog.Logger, interview *model.InterviewDo) (*model.InterviewDo, error)
+	CreateInterview(ctx context.Context, xl *xlog.Logger, interview *model.InterviewDo) (*model.InterviewDo, error)
 	//
-	ListInterviewsByPage(xl *xlog.Logger, userID string, pageNum int, pageSize int) ([]model.InterviewDo, int, error)
-	GetInterviewByID(xl *xlog.Logger, interviewID string) (*model.InterviewDo, error)
-	UpdateInterview(xl *xlog.Logger, id string, interview *model.InterviewDo) (*model.InterviewDo, error)
-	JoinInterview(xl *xlog.Logger, userID string, interviewID string) ([]model.InterviewUserDo, []model.InterviewUserDo, error)
-	LeaveInterview(xl *xlog.Logger, userID string, interviewID string) error
-	OnlineInterviewUsers(xl *xlog.Logger, userID string, interviewID string) ([]model.InterviewUserDo, error)
-	HeartBeat(xl *xlog.Logger, userId, interviewID string)
-	GetRecordURL(xl *xlog.Logger, interviewId string) string
+	ListInterviewsByPage(ctx context.Context, xl *xlog.Logger, userID string, page model.PageQuery) ([]model.InterviewDo, model.PageResult, error)
+	GetInterviewByID(ctx context.Context, xl *xlog.Logger, interviewID string) (*model.InterviewDo, error)
+	UpdateInterview(ctx context.Context, xl *xlog.Logger, id string, interview *model.InterviewDo) (*model.InterviewDo, error)
+	JoinInterview(ctx context.Context, xl *xlog.Logger, userID string, interviewID string) ([]model.InterviewUserDo, []model.InterviewUserDo, error)
+	LeaveInterview(ctx context.Context, xl *xlog.Logger, userID string, interviewID string) error
+	OnlineInterviewUsers(ctx context.Context, xl *xlog.Logger, userID string, interviewID string) ([]model.InterviewUserDo, error)
+	HeartBeat(ctx context.Context, xl *xlog.Logger, userId, interviewID string)
+	GetRecordURL(ctx context.Context, xl *xlog.Logger, interviewId string) string
 }
 
 func NewInterviewApiHandler(conf utils.Config) *InterviewApiHandler {
@@ -62,7 +62,7 @@ func NewInterviewApiHandler(conf utils.Config) *InterviewApiHandler {
 		panic(err)
 	}
 	i.taskService = db.NewTaskService(nil, *conf.Mongo)
-	i.DefaultAvatarURLs = conf.DefaultAvatars
+	i.interviewToken = db.NewInterviewTokenService(conf)
 	i.RequestUrlHost = conf.RequestUrlHost
 	i.FrontendUrlHost = conf.FrontendUrlHost
 	return i
@@ -94,10 +94,10 @@ func (h *InterviewApiHandler) generateInterviewID() string {
 	return roomID
 }
 
-func (h *InterviewApiHandler) kickOtherUsers(xl *xlog.Logger, roomID string) {
-	roomUserIds, _ := h.RTC.ListUser(roomID)
+func (h *InterviewApiHandler) kickOtherUsers(ctx context.Context, xl *xlog.Logger, roomID string) {
+	roomUserIds, _ := h.RTC.ListUser(ctx, xl, roomID)
 	for _, user := range roomUserIds {
-		h.RTC.KickUser(roomID, user)
+		h.RTC.KickUser(ctx, xl, roomID, user)
 	}
 }
 
@@ -122,12 +122,12 @@ func (h *InterviewApiHandler) CreatInterview(c *gin.Context) {
 	}
 	if err := args.Validate(); err != nil {
 		xl.Infof("form validation error: %v", err)
-		responseErr := model.NewResponseErrorValidation(err)
+		responseErr := model.NewResponseErrorValidation(form.Localize(err, model.ContextLanguage(c)))
 		model.NewFailResponse(*responseErr).WithRequestID(requestID).Send(c)
 		return
 	}
 
-	candidateByPhone, candidateByPhoneErr := h.Account.GetAccountByPhone(xl, args.CandidatePhone)
+	candidateByPhone, candidateByPhoneErr := h.Account.GetAccountByPhone(c.Request.Context(), xl, args.CandidatePhone)
 	if candidateByPhoneErr != nil {
 		if candidateByPhoneErr.Error() == "not found" {
 			xl.Infof("candidate's phone number %s not found, create new account", args.CandidatePhone)
@@ -137,7 +137,7 @@ func (h *InterviewApiHandler) CreatInterview(c *gin.Context) {
 				Phone:    args.CandidatePhone,
 				Avatar:   h.generateInitialAvatar(),
 			}
-			createErr := h.Account.CreateAccount(xl, newAccount)
+			createErr := h.Account.CreateAccount(c.Request.Context(), xl, newAccount)
 			if createErr != nil {
 				xl.Errorf("failed to craete candidate's account, error %v", err)
 				responseErr := model.NewResponseErrorInternal()
@@ -157,7 +157,7 @@ func (h *InterviewApiHandler) CreatInterview(c *gin.Context) {
 	interviewerByPhone := &model.AccountDo{}
 	interviewerName := ""
 	if args.InterviewerName != "" && args.InterviewerPhone != "" {
-		interviewerByArgPhone, err := h.Account.GetAccountByPhone(xl, args.InterviewerPhone)
+		interviewerByArgPhone, err := h.Account.GetAccountByPhone(c.Request.Context(), xl, args.InterviewerPhone)
 		if err != nil {
 			if err.Error() == "not found" {
 				xl.Infof("interviewer's phone number %s not found, create new account", args.InterviewerPhone)
@@ -167,7 +167,7 @@ func (h *InterviewApiHandler) CreatInterview(c *gin.Context) {
 					Phone:    args.InterviewerPhone,
 					Avatar:   h.generateInitialAvatar(),
 				}
-				createErr := h.Account.CreateAccount(xl, newAccount)
+				createErr := h.Account.CreateAccount(c.Request.Context(), xl, newAccount)
 				if createErr != nil {
 					xl.Errorf("failed to create interviewer's account, error %v", err)
 					responseErr := model.NewResponseErrorInternal()
@@ -187,7 +187,7 @@ func (h *InterviewApiHandler) CreatInterview(c *gin.Context) {
 		interviewerByPhone = interviewerByArgPhone
 		interviewerName = args.InterviewerName
 	} else {
-		userInfo, userInfoErr := h.Account.GetAccountByID(xl, userID)
+		userInfo, userInfoErr := h.Account.GetAccountByID(c.Request.Context(), xl, userID)
 		if userInfoErr != nil {
 			xl.Errorf("get interviewer's account by phone number failed, error %v", err)
 			responseErr := model.NewResponseErrorInternal()
@@ -224,14 +224,14 @@ func (h *InterviewApiHandler) CreatInterview(c *gin.Context) {
 
 	// 若房间之前不存在，返回创建的房间。若房间已存在，返回已经存在的房间。
 	//candidateToken := h.InterviewToken(candidateByPhone.ID)
-	//qrcodeURL, err := h.weixin.GetAndUploadQRCode(interview.ID, candidateToken)
+	//qrcodeURL, err := h.weixin.GetAndUploadQRCode(xl, interview.ID, candidateToken)
 	//if err != nil {
 	//	xl.Errorf("error get qrcode link err:%v", err)
 	//}
 	//interview.AppletQrcode = qrcodeURL
 
 	// 创建七牛IM群ID
-	qiniuImGroupId, err := h.AppConfigService.GetGroupId(xl, interviewID)
+	qiniuImGroupId, err := h.AppConfigService.GetGroupId(c.Request.Context(), xl, interviewID)
 	if err != nil {
 		xl.Errorf("failed to create qiniu's im group, error %v", err)
 		responseErr := model.NewResponseErrorInternal()
@@ -241,7 +241,7 @@ func (h *InterviewApiHandler) CreatInterview(c *gin.Context) {
 	}
 	interview.QiniuIMGroupId = qiniuImGroupId
 
-	interviewRes, err := h.Interview.CreateInterview(xl, interview)
+	interviewRes, err := h.Interview.CreateInterview(c.Request.Context(), xl, interview)
 	if err != nil {
 		responseErr := model.NewResponseErrorInternal()
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
@@ -265,9 +265,8 @@ func (h *InterviewApiHandler) ListAllInterviews(c *gin.Context) {
 	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
 	requestID := xl.ReqId
 	userID := c.GetString(model.UserIDContextKey)
-	pageNum := c.GetInt(model.PageNumContextKey)
-	pageSize := c.GetInt(model.PageSizeContextKey)
-	interviews, total, err := h.Interview.ListInterviewsByPage(xl, userID, pageNum, pageSize)
+	page := model.ContextPageQuery(c)
+	interviews, pageRes, err := h.Interview.ListInterviewsByPage(c.Request.Context(), xl, userID, page)
 	if err != nil {
 		xl.Errorf("failed to list all rooms, error %v", err)
 		responseErr := model.NewResponseErrorInternal()
@@ -277,25 +276,29 @@ func (h *InterviewApiHandler) ListAllInterviews(c *gin.Context) {
 	}
 	interviewListResp := &model.InterviewListResponse{}
 	for _, interview := range interviews {
-		getInterviewResp, err := h.makeGetInterviewResponse(xl, &interview, userID)
+		role, ok := interviewRole(c, &interview, userID)
+		if !ok {
+			continue
+		}
+		getInterviewResp, err := h.makeGetInterviewResponse(c.Request.Context(), xl, &interview, role)
 		if err != nil {
 			xl.Errorf("failed to make get room response for room %s", interview.ID)
 			continue
 		}
 		interviewListResp.List = append(interviewListResp.List, *getInterviewResp)
 	}
-	interviewListResp.Total = total
+	interviewListResp.Total = pageRes.Total
 	interviewListResp.Cnt = len(interviewListResp.List)
-	interviewListResp.PageSize = pageSize
-	interviewListResp.CurrentPageNum = pageNum
-	if len(interviewListResp.List)+(pageNum-1)*pageSize >= int(total) {
-		interviewListResp.EndPage = true
-		interviewListResp.NextPageNum = pageNum
+	interviewListResp.PageSize = page.PageSize
+	interviewListResp.CurrentPageNum = page.PageNum
+	interviewListResp.EndPage = pageRes.EndPage
+	if pageRes.EndPage {
+		interviewListResp.NextPageNum = page.PageNum
 	} else {
-		interviewListResp.EndPage = false
-		interviewListResp.NextPageNum = pageNum + 1
+		interviewListResp.NextPageNum = page.PageNum + 1
 	}
 	interviewListResp.NextId = ""
+	interviewListResp.NextCursor = pageRes.NextCursor
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
 		Message: string(model.ResponseStatusMessageSuccess),
@@ -304,16 +307,17 @@ func (h *InterviewApiHandler) ListAllInterviews(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
-func (h *InterviewApiHandler) makeGetInterviewResponse(xl *xlog.Logger, interview *model.InterviewDo, userID string) (*model.InterviewResponse, error) {
+// makeGetInterviewResponse 按当前用户在面试中的角色生成面试详情，只有面试官（含创建者）能拿到带候选人邀请token的分享信息。
+func (h *InterviewApiHandler) makeGetInterviewResponse(ctx context.Context, xl *xlog.Logger, interview *model.InterviewDo, role model.InterviewRoleCode) (*model.InterviewResponse, error) {
 	if interview == nil {
 		return nil, fmt.Errorf("nil room")
 	}
-	candidateInfo, err := h.Account.GetAccountByID(xl, interview.Candidate)
+	candidateInfo, err := h.Account.GetAccountByID(ctx, xl, interview.Candidate)
 	if err != nil {
 		xl.Errorf("failed to get account info for user %s, Candidate of room %s", interview.Candidate, interview.ID)
 		return nil, fmt.Errorf("nil Candidate")
 	}
-	interviewerInfo, err := h.Account.GetAccountByID(xl, interview.Interviewer)
+	interviewerInfo, err := h.Account.GetAccountByID(ctx, xl, interview.Interviewer)
 	if err != nil {
 		xl.Errorf("failed to get account info for user %s, interviewer of room %s", interview.Interviewer, interview.ID)
 		return nil, fmt.Errorf("nil Interviewer")
@@ -325,7 +329,7 @@ func (h *InterviewApiHandler) makeGetInterviewResponse(xl *xlog.Logger, intervie
 	switch interview.Status {
 	case int(model.InterviewStatusCodeInit):
 		interviewStatusName = string(model.InterviewStatusNameInit)
-		if interview.Candidate == userID {
+		if role == model.InterviewRoleCodeCandidate {
 			roleCode = int(model.InterviewRoleCodeCandidate)
 			roleName = string(model.InterviewRoleNameCandidate)
 			options = append(options, model.InterviewOptionResponse{
@@ -364,7 +368,7 @@ func (h *InterviewApiHandler) makeGetInterviewResponse(xl *xlog.Logger, intervie
 		}
 	case int(model.InterviewStatusCodeStart):
 		interviewStatusName = string(model.InterviewStatusNameStart)
-		if interview.Candidate == userID {
+		if role == model.InterviewRoleCodeCandidate {
 			roleCode = int(model.InterviewRoleCodeCandidate)
 			roleName = string(model.InterviewRoleNameCandidate)
 			options = append(options, model.InterviewOptionResponse{
@@ -397,7 +401,7 @@ func (h *InterviewApiHandler) makeGetInterviewResponse(xl *xlog.Logger, intervie
 		}
 	case int(model.InterviewStatusCodeEnd):
 		interviewStatusName = string(model.InterviewStatusNameEnd)
-		if interview.Candidate == userID {
+		if role == model.InterviewRoleCodeCandidate {
 			roleCode = int(model.InterviewRoleCodeCandidate)
 			roleName = string(model.InterviewRoleNameCandidate)
 		} else {
@@ -418,7 +422,7 @@ func (h *InterviewApiHandler) makeGetInterviewResponse(xl *xlog.Logger, intervie
 		//}
 	default:
 		interviewStatusName = string(model.InterviewStatusNameEnd)
-		if interview.Candidate == userID {
+		if role == model.InterviewRoleCodeCandidate {
 			roleCode = int(model.InterviewRoleCodeCandidate)
 			roleName = string(model.InterviewRoleNameCandidate)
 		} else {
@@ -427,15 +431,24 @@ func (h *InterviewApiHandler) makeGetInterviewResponse(xl *xlog.Logger, intervie
 		}
 	}
 
-	candidateMap, _ := json.Marshal(map[string]string{
-		"userId": interview.Candidate,
-	})
-	candidateUrl := h.FrontendUrlHost + "/meeting-entrance/" + interview.ID + "?interviewToken=" + base64.StdEncoding.EncodeToString([]byte(candidateMap))
-	interviewTime := interview.StartTime.Format("2006-01-02 15:04")
-	candidateContent := fmt.Sprintf("您的面试部门为：%s，职位：%s，时间为：%s。请提前预留时间参加面试，面试链接为：%s（请使用电脑浏览器打开链接并进行）", interview.Goverment, interview.Career, interviewTime, candidateUrl)
+	shareInfo := model.ShareInfoResponse{}
+	if role == model.InterviewRoleCodeInterviewer {
+		candidateToken, err := h.InterviewToken(interview, interview.Candidate)
+		if err != nil {
+			xl.Errorf("failed to sign interview token for candidate of interview %s, error %v", interview.ID, err)
+			return nil, err
+		}
+		candidateUrl := h.interviewEntranceURL(interview.ID, candidateToken)
+		interviewTime := interview.StartTime.Format("2006-01-02 15:04")
+		shareInfo = model.ShareInfoResponse{
+			Url:     candidateUrl,
+			Icon:    "https://demo-qnrtc-files.qnsdk.com/default_icon.png",
+			Content: fmt.Sprintf("您的面试部门为：%s，职位：%s，时间为：%s。请提前预留时间参加面试，面试链接为：%s（请使用电脑浏览器打开链接并进行）", interview.Goverment, interview.Career, interviewTime, candidateUrl),
+		}
+	}
 	var recordURL string
 	if interview.Recorded {
-		recordURL = h.Interview.GetRecordURL(xl, interview.ID)
+		recordURL = h.Interview.GetRecordURL(ctx, xl, interview.ID)
 	}
 	interviewResp := model.InterviewResponse{
 		ID:               interview.ID,
@@ -460,23 +473,34 @@ func (h *InterviewApiHandler) makeGetInterviewResponse(xl *xlog.Logger, intervie
 		Options:          options,
 		RecordURL:        recordURL,
 		AppletQrcode:     interview.AppletQrcode,
-		ShareInfo: model.ShareInfoResponse{
-			Url:     candidateUrl,
-			Icon:    "https://demo-qnrtc-files.qnsdk.com/default_icon.png",
-			Content: candidateContent,
-		},
+		ShareInfo:        shareInfo,
 	}
 
 	return &interviewResp, nil
 }
 
+// interviewRole 返回用户在面试中的角色，不是面试成员时返回false。
+// 通过面试邀请token访问时以token绑定的角色为准，候选人的token不能以面试官身份操作。
+func interviewRole(c *gin.Context, interview *model.InterviewDo, userID string) (model.InterviewRoleCode, bool) {
+	if interview.Creator != userID && interview.Interviewer != userID && interview.Candidate != userID {
+		return 0, false
+	}
+	if val, ok := c.Get(model.InterviewRoleContextKey); ok {
+		return val.(model.InterviewRoleCode), true
+	}
+	if interview.Candidate == userID {
+		return model.InterviewRoleCodeCandidate, true
+	}
+	return model.InterviewRoleCodeInterviewer, true
+}
+
 func (h *InterviewApiHandler) GetInterview(c *gin.Context) {
 	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
 	requestID := xl.ReqId
 	userID := c.GetString(model.UserIDContextKey)
 	interviewID := c.Param("interviewId")
 
-	interview, err := h.Interview.GetInterviewByID(xl, interviewID)
+	interview, err := h.Interview.GetInterviewByID(c.Request.Context(), xl, interviewID)
 	if err != nil {
 		serverErr, ok := err.(*errors.ServerError)
 		if ok {
@@ -493,7 +517,15 @@ func (h *InterviewApiHandler) GetInterview(c *gin.Context) {
 		c.JSON(http.StatusOK, resp)
 		return
 	}
-	interviewResp, err := h.makeGetInterviewResponse(xl, interview, userID)
+	role, ok := interviewRole(c, interview, userID)
+	if !ok {
+		xl.Infof("user %s is not a member of interview %s", userID, interviewID)
+		responseErr := model.NewResponseErrorNoSuchInterview()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	interviewResp, err := h.makeGetInterviewResponse(c.Request.Context(), xl, interview, role)
 	if err != nil {
 		xl.Errorf("failed to get make get room response, error %v", err)
 		responseErr := model.NewResponseErrorInternal()
@@ -527,11 +559,11 @@ func (h *InterviewApiHandler) UpdateInterview(c *gin.Context) {
 	}
 	if err := args.Validate(); err != nil {
 		xl.Infof("form valdation error:%v", err)
-		responseErr := model.NewResponseErrorValidation(err)
+		responseErr := model.NewResponseErrorValidation(form.Localize(err, model.ContextLanguage(c)))
 		model.NewFailResponse(*responseErr).WithRequestID(requestID).Send(c)
 		return
 	}
-	interview, err := h.Interview.GetInterviewByID(xl, interviewID)
+	interview, err := h.Interview.GetInterviewByID(c.Request.Context(), xl, interviewID)
 	if err != nil {
 		// todo
 		serverErr, ok := err.(*errors.ServerError)
@@ -601,7 +633,7 @@ func (h *InterviewApiHandler) UpdateInterview(c *gin.Context) {
 	if args.CandidateName != interview.CandidateName {
 		interview.CandidateName = args.CandidateName
 	}
-	candidateByPhone, candidateByPhoneErr := h.Account.GetAccountByPhone(xl, args.CandidatePhone)
+	candidateByPhone, candidateByPhoneErr := h.Account.GetAccountByPhone(c.Request.Context(), xl, args.CandidatePhone)
 	if candidateByPhoneErr != nil {
 		if candidateByPhoneErr.Error() == "not found" {
 			xl.Infof("candidate's phone number %s not found, create new account", args.CandidatePhone)
@@ -611,7 +643,7 @@ func (h *InterviewApiHandler) UpdateInterview(c *gin.Context) {
 				Phone:    args.CandidatePhone,
 				Avatar:   h.generateInitialAvatar(),
 			}
-			createErr := h.Account.CreateAccount(xl, newAccount)
+			createErr := h.Account.CreateAccount(c.Request.Context(), xl, newAccount)
 			if createErr != nil {
 				xl.Errorf("failed to craete candidate's account, error %v", err)
 				responseErr := model.NewResponseErrorInternal()
@@ -632,7 +664,7 @@ func (h *InterviewApiHandler) UpdateInterview(c *gin.Context) {
 	interviewerByPhone := &model.AccountDo{}
 	interviewerName := ""
 	if args.InterviewerName != "" && args.InterviewerPhone != "" {
-		interviewerByArgPhone, err := h.Account.GetAccountByPhone(xl, args.InterviewerPhone)
+		interviewerByArgPhone, err := h.Account.GetAccountByPhone(c.Request.Context(), xl, args.InterviewerPhone)
 		if err != nil {
 			if err.Error() == "not found" {
 				xl.Infof("interviewer's phone number %s not found, create new account", args.InterviewerPhone)
@@ -642,7 +674,7 @@ func (h *InterviewApiHandler) UpdateInterview(c *gin.Context) {
 					Phone:    args.InterviewerPhone,
 					Avatar:   h.generateInitialAvatar(),
 				}
-				createErr := h.Account.CreateAccount(xl, newAccount)
+				createErr := h.Account.CreateAccount(c.Request.Context(), xl, newAccount)
 				if createErr != nil {
 					xl.Errorf("failed to craete interviewer's account, error %v", err)
 					responseErr := model.NewResponseErrorInternal()
@@ -662,7 +694,7 @@ func (h *InterviewApiHandler) UpdateInterview(c *gin.Context) {
 		interviewerByPhone = interviewerByArgPhone
 		interviewerName = args.InterviewerName
 	} else {
-		userInfo, userInfoErr := h.Account.GetAccountByID(xl, userID)
+		userInfo, userInfoErr := h.Account.GetAccountByID(c.Request.Context(), xl, userID)
 		if userInfoErr != nil {
 			xl.Errorf("get interviewer's account by phone number failed, error %v", err)
 			responseErr := model.NewResponseErrorInternal()
@@ -675,8 +707,10 @@ func (h *InterviewApiHandler) UpdateInterview(c *gin.Context) {
 	}
 	interview.InterviewerName = interviewerName
 	interview.Interviewer = interviewerByPhone.ID
+	// 面试信息变更后，已发出的邀请链接全部失效，需重新分享。
+	interview.TokenVersion++
 
-	interview, err = h.Interview.UpdateInterview(xl, interview.ID, interview)
+	interview, err = h.Interview.UpdateInterview(c.Request.Context(), xl, interview.ID, interview)
 	if err != nil {
 		xl.Errorf("failed to update room, error %v", err)
 		responseErr := model.NewResponseErrorInternal()
@@ -701,7 +735,14 @@ func (h *InterviewApiHandler) EndInterview(c *gin.Context) {
 	userID := c.GetString(model.UserIDContextKey)
 	interviewID := c.Param("interviewId")
 
-	interview, err := h.changeInterviewStatus(xl, userID, interviewID, model.InterviewStatusCodeStart, model.InterviewStatusCodeEnd)
+	if val, ok := c.Get(model.InterviewRoleContextKey); ok && val.(model.InterviewRoleCode) != model.InterviewRoleCodeInterviewer {
+		xl.Infof("user %s try to end interview %s with a candidate token", userID, interviewID)
+		responseErr := model.NewResponseErrorUnauthorized()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	interview, err := h.changeInterviewStatus(c.Request.Context(), xl, userID, interviewID, model.InterviewStatusCodeStart, model.InterviewStatusCodeEnd, false)
 	if err != nil {
 		xl.Errorf("failed to change Interview Status room %s, error %v", interviewID, err)
 		responseErr := model.NewResponseErrorInternal()
@@ -709,7 +750,7 @@ func (h *InterviewApiHandler) EndInterview(c *gin.Context) {
 		c.JSON(http.StatusOK, resp)
 		return
 	}
-	h.kickOtherUsers(xl, interview.ID)
+	h.kickOtherUsers(c.Request.Context(), xl, interview.ID)
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
 		Message: string(model.ResponseStatusMessageSuccess),
@@ -721,8 +762,9 @@ func (h *InterviewApiHandler) EndInterview(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
-func (h *InterviewApiHandler) changeInterviewStatus(xl *xlog.Logger, userID string, interviewID string, from model.InterviewStatusCode, to model.InterviewStatusCode) (*model.InterviewDo, error) {
-	interview, err := h.Interview.GetInterviewByID(xl, interviewID)
+// changeInterviewStatus 变更面试状态，revokeTokens为true时同时吊销已发出的面试邀请token。
+func (h *InterviewApiHandler) changeInterviewStatus(ctx context.Context, xl *xlog.Logger, userID string, interviewID string, from model.InterviewStatusCode, to model.InterviewStatusCode, revokeTokens bool) (*model.InterviewDo, error) {
+	interview, err := h.Interview.GetInterviewByID(ctx, xl, interviewID)
 	if err != nil {
 		serverErr, ok := err.(*errors.ServerError)
 		if !ok {
@@ -746,7 +788,10 @@ func (h *InterviewApiHandler) changeInterviewStatus(xl *xlog.Logger, userID stri
 	}
 
 	interview.Status = int(to)
-	interview, err = h.Interview.UpdateInterview(xl, interview.ID, interview)
+	if revokeTokens {
+		interview.TokenVersion++
+	}
+	interview, err = h.Interview.UpdateInterview(ctx, xl, interview.ID, interview)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update room, error %v", err)
 	}
@@ -759,7 +804,7 @@ func (h *InterviewApiHandler) CancelInterview(c *gin.Context) {
 	userID := c.GetString(model.UserIDContextKey)
 	interviewID := c.Param("interviewId")
 
-	interview, err := h.changeInterviewStatus(xl, userID, interviewID, model.InterviewStatusCodeInit, model.InterviewStatusCodeEnd)
+	interview, err := h.changeInterviewStatus(c.Request.Context(), xl, userID, interviewID, model.InterviewStatusCodeInit, model.InterviewStatusCodeEnd, true)
 	if err != nil {
 		xl.Errorf("failed to change Interview Status room %s, error %v", interviewID, err)
 		responseErr := model.NewResponseErrorInternal()
@@ -784,7 +829,7 @@ func (h *InterviewApiHandler) JoinInterview(c *gin.Context) {
 	userID := c.GetString(model.UserIDContextKey)
 	interviewID := c.Param("interviewId")
 
-	userInfo, userInfoErr := h.Account.GetAccountByID(xl, userID)
+	userInfo, userInfoErr := h.Account.GetAccountByID(c.Request.Context(), xl, userID)
 	if userInfoErr != nil {
 		xl.Errorf("failed to get account info for user %s, Candidate of room %s", userID, interviewID)
 		responseErr := model.NewResponseErrorInternal()
@@ -793,7 +838,7 @@ func (h *InterviewApiHandler) JoinInterview(c *gin.Context) {
 		return
 	}
 
-	interview, err := h.Interview.GetInterviewByID(xl, interviewID)
+	interview, err := h.Interview.GetInterviewByID(c.Request.Context(), xl, interviewID)
 	if err != nil {
 		serverErr, ok := err.(*errors.ServerError)
 		if !ok {
@@ -819,7 +864,8 @@ func (h *InterviewApiHandler) JoinInterview(c *gin.Context) {
 		}
 	}
 
-	if interview.Creator != userID && interview.Interviewer != userID && interview.Candidate != userID {
+	role, ok := interviewRole(c, interview, userID)
+	if !ok {
 		xl.Infof("user %s try to update room %s, no permission", userID, interviewID)
 		responseErr := model.NewResponseErrorNoSuchInterview()
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
@@ -827,12 +873,13 @@ func (h *InterviewApiHandler) JoinInterview(c *gin.Context) {
 		return
 	}
 
-	interviewResp, err := h.makeGetInterviewResponse(xl, interview, userID)
+	interviewResp, err := h.makeGetInterviewResponse(c.Request.Context(), xl, interview, role)
 	if err != nil {
 		xl.Errorf("failed to make get room response for room %s", interview.ID)
 		responseErr := model.NewResponseErrorNoSuchInterview()
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
 		c.JSON(http.StatusOK, resp)
+		return
 	}
 	permission := ""
 	switch interviewResp.RoleCode {
@@ -842,7 +889,7 @@ func (h *InterviewApiHandler) JoinInterview(c *gin.Context) {
 		permission = "user"
 	}
 
-	onlineUserDos, allUserDos, joinInterviewErr := h.Interview.JoinInterview(xl, userID, interviewID)
+	onlineUserDos, allUserDos, joinInterviewErr := h.Interview.JoinInterview(c.Request.Context(), xl, userID, interviewID)
 	if joinInterviewErr != nil {
 		xl.Errorf("failed to get current room %s, error %v", interviewID, err)
 		responseErr := model.NewResponseErrorInternal()
@@ -853,7 +900,7 @@ func (h *InterviewApiHandler) JoinInterview(c *gin.Context) {
 
 	onlineUserList := make([]model.UserInfoResponse, len(onlineUserDos))
 	for index, interviewUserDo := range onlineUserDos {
-		accountDo, err := h.Account.GetAccountByID(xl, interviewUserDo.UserID)
+		accountDo, err := h.Account.GetAccountByID(c.Request.Context(), xl, interviewUserDo.UserID)
 		if err != nil {
 			xl.Errorf("failed to make userInfo response for room %s", interview.ID)
 			continue
@@ -870,7 +917,7 @@ func (h *InterviewApiHandler) JoinInterview(c *gin.Context) {
 
 	allUserList := make([]model.UserInfoResponse, len(allUserDos))
 	for index, interviewUserDo := range allUserDos {
-		accountDo, err := h.Account.GetAccountByID(xl, interviewUserDo.UserID)
+		accountDo, err := h.Account.GetAccountByID(c.Request.Context(), xl, interviewUserDo.UserID)
 		if err != nil {
 			xl.Errorf("failed to make userInfo response for room %s", interview.ID)
 			continue
@@ -919,7 +966,7 @@ func (h *InterviewApiHandler) LeaveInterview(c *gin.Context) {
 	requestID := xl.ReqId
 	userID := c.GetString(model.UserIDContextKey)
 	interviewID := c.Param("interviewId")
-	interview, err := h.Interview.GetInterviewByID(xl, interviewID)
+	interview, err := h.Interview.GetInterviewByID(c.Request.Context(), xl, interviewID)
 	if err != nil {
 		serverErr, ok := err.(*errors.ServerError)
 		if !ok {
@@ -953,7 +1000,7 @@ func (h *InterviewApiHandler) LeaveInterview(c *gin.Context) {
 		return
 	}
 
-	leavelErr := h.Interview.LeaveInterview(xl, userID, interviewID)
+	leavelErr := h.Interview.LeaveInterview(c.Request.Context(), xl, userID, interviewID)
 	if leavelErr != nil {
 		xl.Infof("error when leaving room, error: %v", err)
 		responseErr := model.NewResponseErrorInternal()
@@ -976,7 +1023,7 @@ func (h *InterviewApiHandler) HeartBeat(c *gin.Context) {
 	userID := c.GetString(model.UserIDContextKey)
 	interviewID := c.Param("interviewId")
 
-	interviewUserDos, onlineInterviewUsersErr := h.Interview.OnlineInterviewUsers(xl, userID, interviewID)
+	interviewUserDos, onlineInterviewUsersErr := h.Interview.OnlineInterviewUsers(c.Request.Context(), xl, userID, interviewID)
 	if onlineInterviewUsersErr != nil {
 		xl.Errorf("failed to get current room %s, error %v", interviewID, onlineInterviewUsersErr)
 		responseErr := model.NewResponseErrorInternal()
@@ -986,12 +1033,12 @@ func (h *InterviewApiHandler) HeartBeat(c *gin.Context) {
 	}
 
 	// mark heartbeat time
-	h.Interview.HeartBeat(xl, userID, interviewID)
+	h.Interview.HeartBeat(c.Request.Context(), xl, userID, interviewID)
 
 	onlineUserList := make([]model.UserInfoResponse, len(interviewUserDos))
 	for index, interviewUserDo := range interviewUserDos {
 		interviewUserId := interviewUserDo.UserID
-		accountDo, err := h.Account.GetAccountByID(xl, interviewUserId)
+		accountDo, err := h.Account.GetAccountByID(c.Request.Context(), xl, interviewUserId)
 		if err != nil {
 			xl.Errorf("failed to make userInfo response for room %s", interviewID)
 			continue
@@ -1006,7 +1053,7 @@ func (h *InterviewApiHandler) HeartBeat(c *gin.Context) {
 		onlineUserList[index] = userInfo
 	}
 
-	interview, err := h.Interview.GetInterviewByID(xl, interviewID)
+	interview, err := h.Interview.GetInterviewByID(c.Request.Context(), xl, interviewID)
 	if err != nil {
 		serverErr, ok := err.(*errors.ServerError)
 		if !ok {
@@ -1059,12 +1106,16 @@ func (h *InterviewApiHandler) generateNicknameByPhone(phone string) string {
 	return namePrefix + phone[len(phone)-4:]
 }
 
+// generateInitialAvatar 从DefaultAvatarURLs中随机选取新用户的头像，DefaultAvatarURLs为空时使用配置中可重新加载的default_avatars。
 func (h *InterviewApiHandler) generateInitialAvatar() string {
-	if len(h.DefaultAvatarURLs) == 0 {
+	avatars := h.DefaultAvatarURLs
+	if len(avatars) == 0 {
+		avatars = utils.Reloadable().DefaultAvatars
+	}
+	if len(avatars) == 0 {
 		return ""
 	}
-	index := rand.Intn(len(h.DefaultAvatarURLs))
-	return h.DefaultAvatarURLs[index]
+	return avatars[rand.Intn(len(avatars))]
 }
 
 // generateUserID 生成新的用户ID。
@@ -1079,27 +1130,55 @@ func (h *InterviewApiHandler) generateUserID() string {
 	return id
 }
 
+// InterviewUrlFromId 返回面试官与应聘者各自的面试入口链接，仅面试创建者与面试官可获取。
 func (h *InterviewApiHandler) InterviewUrlFromId(c *gin.Context) {
 	interviewID := c.Param("interviewId")
 	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
-	interview, _ := h.Interview.GetInterviewByID(xl, interviewID)
-	interviewer, _ := json.Marshal(map[string]string{
-		"userId": interview.Interviewer,
-	})
-	candidate, _ := json.Marshal(map[string]string{
-		"userId": interview.Candidate,
-	})
+	requestID := xl.ReqId
+	userID := c.GetString(model.UserIDContextKey)
+	interview, err := h.Interview.GetInterviewByID(c.Request.Context(), xl, interviewID)
+	if err != nil {
+		xl.Infof("failed to get interview %s, error %v", interviewID, err)
+		responseErr := model.NewResponseErrorNoSuchInterview()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	if interview.Creator != userID && interview.Interviewer != userID {
+		xl.Infof("user %s try to get entrance urls of interview %s, no permission", userID, interviewID)
+		responseErr := model.NewResponseErrorUnauthorized()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	interviewerToken, err := h.InterviewToken(interview, interview.Interviewer)
+	if err != nil {
+		xl.Errorf("failed to sign interview token for interviewer of interview %s, error %v", interviewID, err)
+		responseErr := model.NewResponseErrorInternal()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	candidateToken, err := h.InterviewToken(interview, interview.Candidate)
+	if err != nil {
+		xl.Errorf("failed to sign interview token for candidate of interview %s, error %v", interviewID, err)
+		responseErr := model.NewResponseErrorInternal()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
 	resp := model.NewSuccessResponse(map[string]string{
-		"interviewer": h.FrontendUrlHost + "/meeting-entrance/" + interviewID + "?interviewToken=" + base64.StdEncoding.EncodeToString([]byte(interviewer)),
-		"candidate":   h.FrontendUrlHost + "/meeting-entrance/" + interviewID + "?interviewToken=" + base64.StdEncoding.EncodeToString([]byte(candidate)),
-	})
+		"interviewer": h.interviewEntranceURL(interviewID, interviewerToken),
+		"candidate":   h.interviewEntranceURL(interviewID, candidateToken),
+	}).WithRequestID(requestID)
 	c.JSON(http.StatusOK, resp)
 }
 
-func (h *InterviewApiHandler) InterviewToken(id string) string {
-	payload := map[string]string{
-		"userId": id,
-	}
-	val, _ := json.Marshal(payload)
-	return base64.StdEncoding.EncodeToString(val)
+// InterviewToken 为面试成员签发只对该面试有效的邀请token。
+func (h *InterviewApiHandler) InterviewToken(interview *model.InterviewDo, userID string) (string, error) {
+	return h.interviewToken.Sign(interview, userID)
+}
+
+func (h *InterviewApiHandler) interviewEntranceURL(interviewID string, interviewToken string) string {
+	return h.FrontendUrlHost + "/meeting-entrance/" + interviewID + "?interviewToken=" + interviewToken
 }
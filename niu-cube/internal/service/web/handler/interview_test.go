@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+)
+
+func TestInterviewRole(t *testing.T) {
+	interview := &model.InterviewDo{
+		ID:          "interview-1",
+		Creator:     "creator",
+		Interviewer: "interviewer",
+		Candidate:   "candidate",
+	}
+	cases := []struct {
+		name      string
+		userID    string
+		tokenRole *model.InterviewRoleCode
+		wantRole  model.InterviewRoleCode
+		wantOK    bool
+	}{
+		{name: "creator", userID: "creator", wantRole: model.InterviewRoleCodeInterviewer, wantOK: true},
+		{name: "interviewer", userID: "interviewer", wantRole: model.InterviewRoleCodeInterviewer, wantOK: true},
+		{name: "candidate", userID: "candidate", wantRole: model.InterviewRoleCodeCandidate, wantOK: true},
+		{name: "stranger", userID: "stranger"},
+		{name: "stranger with token role", userID: "stranger", tokenRole: roleCode(model.InterviewRoleCodeInterviewer)},
+		{name: "candidate token", userID: "interviewer", tokenRole: roleCode(model.InterviewRoleCodeCandidate), wantRole: model.InterviewRoleCodeCandidate, wantOK: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			if tc.tokenRole != nil {
+				c.Set(model.InterviewRoleContextKey, *tc.tokenRole)
+			}
+			role, ok := interviewRole(c, interview, tc.userID)
+			if ok != tc.wantOK || role != tc.wantRole {
+				t.Fatalf("interviewRole = (%d, %v), want (%d, %v)", role, ok, tc.wantRole, tc.wantOK)
+			}
+		})
+	}
+}
+
+func roleCode(role model.InterviewRoleCode) *model.InterviewRoleCode {
+	return &role
+}
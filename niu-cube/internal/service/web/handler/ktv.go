@@ -2,7 +2,6 @@ package handler
 
 import (
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/qiniu/x/xlog"
@@ -65,28 +64,39 @@ func (k *KtvApiHandler) ListSong(context *gin.Context) {
 		return
 	}
 	var roomId string
-	var pageNum int
-	var pageSize int
 	if roomId0, ok := input["roomId"].(string); ok {
 		roomId = roomId0
 	} else {
 		roomId = "-1"
 		xl.Infof("miss roomId: %s in the request parameters.", roomId)
 	}
-	if pageNum0, ok := input["pageNum"].(int); ok {
-		pageNum = pageNum0
-	} else {
-		pageNum = 1
+	// 分页参数在请求体中给出，请求体中没有时使用query中的参数。
+	page := model.ContextPageQuery(context)
+	pageNum, pageSize, pageCursor := page.PageNum, page.PageSize, context.Query("cursor")
+	if pageNum0, ok := input["pageNum"].(float64); ok {
+		pageNum = int(pageNum0)
 	}
-	if pageSize0, ok := input["pageSize"].(int); ok {
-		pageSize = pageSize0
-	} else {
-		pageSize = 10
+	if pageSize0, ok := input["pageSize"].(float64); ok {
+		pageSize = int(pageSize0)
 	}
-	list, total, count, err := k.songDao.ListAll(xl, pageNum, pageSize)
-	flag := false
-	if pageNum*pageSize >= total {
-		flag = true
+	if pageCursor0, ok := input["cursor"].(string); ok {
+		pageCursor = pageCursor0
+	}
+	page, err = model.NewPageQuery(pageNum, pageSize, pageCursor)
+	if err != nil {
+		xl.Infof("invalid cursor in body, error: %v", err)
+		responseErr := model.NewResponseErrorBadRequest()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
+		context.JSON(http.StatusOK, resp)
+		return
+	}
+	list, pageRes, err := k.songDao.ListAll(context.Request.Context(), xl, page)
+	if err != nil {
+		xl.Errorf("list song failed, error: %v", err)
+		responseErr := model.NewResponseErrorDatabase(err)
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
+		context.JSON(http.StatusOK, resp)
+		return
 	}
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
@@ -99,15 +109,17 @@ func (k *KtvApiHandler) ListSong(context *gin.Context) {
 			NextPageNum    int            `json:"nextPageNum"`
 			PageSize       int            `json:"pageSize"`
 			EndPage        bool           `json:"endPage"`
+			NextCursor     string         `json:"nextCursor"`
 			List           []model.SongDo `json:"list"`
 		}{
-			Total:          total,
+			Total:          pageRes.Total,
 			NextId:         "",
-			Cnt:            count,
-			CurrentPageNum: pageNum,
-			NextPageNum:    pageNum + 1,
-			PageSize:       pageSize,
-			EndPage:        flag,
+			Cnt:            pageRes.Count,
+			CurrentPageNum: page.PageNum,
+			NextPageNum:    page.PageNum + 1,
+			PageSize:       page.PageSize,
+			EndPage:        pageRes.EndPage,
+			NextCursor:     pageRes.NextCursor,
 			List:           list,
 		},
 		RequestID: requestId,
@@ -146,7 +158,11 @@ func (k *KtvApiHandler) SongDemanded(context *gin.Context) {
 	} else {
 		pageSize = 10
 	}
-	list, total, count, err := k.roomUserSongDao.ListByRoomId(xl, roomId, pageNum, pageSize)
+	list, total, count, err := k.roomUserSongDao.ListByRoomId(context.Request.Context(), xl, roomId, pageNum, pageSize)
+	if err != nil {
+		writeDatabaseError(context, xl, err)
+		return
+	}
 	flag := false
 	if pageNum*pageSize >= total {
 		flag = true
@@ -157,7 +173,14 @@ func (k *KtvApiHandler) SongDemanded(context *gin.Context) {
 	}
 	l := make([]TmpResponse, 0, count)
 	for _, val := range list {
-		song, _ := k.songDao.Select(xl, val.SongId)
+		song, err := k.songDao.Select(context.Request.Context(), xl, val.SongId)
+		if err == mgo.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			writeDatabaseError(context, xl, err)
+			return
+		}
 		l = append(l, TmpResponse{
 			SongDo:   *song,
 			Demander: val.UserId,
@@ -233,7 +256,7 @@ func (k *KtvApiHandler) SongOperation(context *gin.Context) {
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	roomUserSong, err := k.roomUserSongDao.SelectByRoomIdSongId(xl, roomId, songId)
+	roomUserSong, err := k.roomUserSongDao.SelectByRoomIdSongId(context.Request.Context(), xl, roomId, songId)
 	if err != nil && err == mgo.ErrNotFound {
 		roomUserSong = &model.RoomUserSongDo{
 			RoomId: roomId,
@@ -241,7 +264,7 @@ func (k *KtvApiHandler) SongOperation(context *gin.Context) {
 			SongId: songId,
 			Status: model.SongAvailable,
 		}
-		_, _ = k.roomUserSongDao.Insert(xl, roomUserSong)
+		_, _ = k.roomUserSongDao.Insert(context.Request.Context(), xl, roomUserSong)
 	}
 	if roomUserSong.UserId != userId {
 		resp := &model.Response{
@@ -259,7 +282,7 @@ func (k *KtvApiHandler) SongOperation(context *gin.Context) {
 	} else if op == "delete" {
 		roomUserSong.Status = model.RoomUserSongUnavailable
 	}
-	_ = k.roomUserSongDao.Update(xl, roomUserSong)
+	_ = k.roomUserSongDao.Update(context.Request.Context(), xl, roomUserSong)
 	resp := &model.Response{
 		Code:      int(model.ResponseStatusCodeSuccess),
 		Message:   string(model.ResponseStatusMessageSuccess),
@@ -303,7 +326,7 @@ func (k *KtvApiHandler) SongInfo(context *gin.Context) {
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	song, err := k.songDao.Select(xl, songId)
+	song, err := k.songDao.Select(context.Request.Context(), xl, songId)
 	if err != nil && err == mgo.ErrNotFound {
 		song = nil
 	}
@@ -323,7 +346,7 @@ func (k *KtvApiHandler) SongInfo(context *gin.Context) {
 func (k *KtvApiHandler) AddSongs(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
-	userId := context.GetString(model.UserIDContextKey)
+	userId := model.ContextCallerID(context)
 	xl.Info("user:[%s] try to add song.", userId)
 	var input map[string]interface{}
 	err := context.Bind(&input)
@@ -348,9 +371,9 @@ func (k *KtvApiHandler) AddSongs(context *gin.Context) {
 					Lyrics:           song["lyrics"].(string),
 					Status:           model.SongAvailable,
 				}
-				_, err := k.songDao.SelectByNameAndAuthor(xl, songDo.Name, songDo.Author)
+				_, err := k.songDao.SelectByNameAndAuthor(context.Request.Context(), xl, songDo.Name, songDo.Author)
 				if err == mgo.ErrNotFound {
-					_, _ = k.songDao.Insert(xl, &songDo)
+					_, _ = k.songDao.Insert(context.Request.Context(), xl, &songDo)
 				}
 			}
 		}
@@ -367,7 +390,7 @@ func (k *KtvApiHandler) AddSongs(context *gin.Context) {
 func (k *KtvApiHandler) UpdateSong(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
-	userId := context.GetString(model.UserIDContextKey)
+	userId := model.ContextCallerID(context)
 	xl.Info("user:[%s] try to update song.", userId)
 	var input map[string]interface{}
 	err := context.Bind(&input)
@@ -380,7 +403,7 @@ func (k *KtvApiHandler) UpdateSong(context *gin.Context) {
 	}
 	if song0, ok := input["song"].(map[string]interface{}); ok {
 		songId := song0["songId"].(string)
-		songDo, err := k.songDao.Select(xl, songId)
+		songDo, err := k.songDao.Select(context.Request.Context(), xl, songId)
 		if err != nil {
 			if err == mgo.ErrNotFound {
 				resp := &model.Response{
@@ -426,7 +449,7 @@ func (k *KtvApiHandler) UpdateSong(context *gin.Context) {
 		if song0["lyrics"] != nil {
 			songDo.Lyrics = song0["lyrics"].(string)
 		}
-		_ = k.songDao.Update(xl, songDo)
+		_ = k.songDao.Update(context.Request.Context(), xl, songDo)
 	}
 	resp := &model.Response{
 		Code:      int(model.ResponseStatusCodeSuccess),
@@ -440,7 +463,7 @@ func (k *KtvApiHandler) UpdateSong(context *gin.Context) {
 func (k *KtvApiHandler) DeleteSong(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
-	userId := context.GetString(model.UserIDContextKey)
+	userId := model.ContextCallerID(context)
 	xl.Info("user:[%s] try to delete song.", userId)
 	var input map[string]interface{}
 	err := context.Bind(&input)
@@ -452,10 +475,10 @@ func (k *KtvApiHandler) DeleteSong(context *gin.Context) {
 		return
 	}
 	if songId, ok := input["songId"].(string); ok {
-		songDo, _ := k.songDao.Select(xl, songId)
+		songDo, _ := k.songDao.Select(context.Request.Context(), xl, songId)
 		if songDo != nil {
 			songDo.Status = model.SongUnavailable
-			_ = k.songDao.Update(xl, songDo)
+			_ = k.songDao.Update(context.Request.Context(), xl, songDo)
 		}
 	}
 	resp := &model.Response{
@@ -472,13 +495,34 @@ func (k *KtvApiHandler) ListAllSong(context *gin.Context) {
 	requestId := xl.ReqId
 	userId := context.GetString(model.UserIDContextKey)
 	xl.Info("user:[%s] try to list songs.", userId)
-	pageSize, _ := strconv.Atoi(context.DefaultQuery("pageSize", "10"))
-	pageNum, _ := strconv.Atoi(context.DefaultQuery("pageNum", "1"))
-	songDos, _, _, _ := k.songDao.ListAll(xl, pageNum, pageSize)
+	page := model.ContextPageQuery(context)
+	songDos, pageRes, err := k.songDao.ListAll(context.Request.Context(), xl, page)
+	if err != nil {
+		writeDatabaseError(context, xl, err)
+		return
+	}
 	resp := &model.Response{
-		Code:      int(model.ResponseStatusCodeSuccess),
-		Message:   string(model.ResponseStatusMessageSuccess),
-		Data:      songDos,
+		Code:    int(model.ResponseStatusCodeSuccess),
+		Message: string(model.ResponseStatusMessageSuccess),
+		Data: struct {
+			Total          int            `json:"total"`
+			Cnt            int            `json:"cnt"`
+			CurrentPageNum int            `json:"currentPageNum"`
+			NextPageNum    int            `json:"nextPageNum"`
+			PageSize       int            `json:"pageSize"`
+			EndPage        bool           `json:"endPage"`
+			NextCursor     string         `json:"nextCursor"`
+			List           []model.SongDo `json:"list"`
+		}{
+			Total:          pageRes.Total,
+			Cnt:            pageRes.Count,
+			CurrentPageNum: page.PageNum,
+			NextPageNum:    page.PageNum + 1,
+			PageSize:       page.PageSize,
+			EndPage:        pageRes.EndPage,
+			NextCursor:     pageRes.NextCursor,
+			List:           songDos,
+		},
 		RequestID: requestId,
 	}
 	context.JSON(http.StatusOK, resp)
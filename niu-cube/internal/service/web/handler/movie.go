@@ -65,12 +65,14 @@ func NewMovieApiHandler(xl *xlog.Logger, config *utils.MongoConfig) *MovieApiHan
 func (m *MovieApiHandler) ListMovie(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
-	pageSize, _ := strconv.Atoi(context.DefaultQuery("pageSize", "10"))
-	pageNum, _ := strconv.Atoi(context.DefaultQuery("pageNum", "1"))
-	list, total, _ := m.movieDao.ListAll(xl, pageNum, pageSize)
-	flag := false
-	if pageNum*pageSize >= total {
-		flag = true
+	page := model.ContextPageQuery(context)
+	list, pageRes, err := m.movieDao.ListAll(context.Request.Context(), xl, page)
+	if err != nil {
+		xl.Errorf("list movie failed, error: %v", err)
+		responseErr := model.NewResponseErrorDatabase(err)
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestId)
+		context.JSON(http.StatusOK, resp)
+		return
 	}
 	resp := &model.Response{
 		Code:    int(model.ResponseStatusCodeSuccess),
@@ -83,15 +85,17 @@ func (m *MovieApiHandler) ListMovie(context *gin.Context) {
 			NextPageNum    int             `json:"nextPageNum"`
 			PageSize       int             `json:"pageSize"`
 			EndPage        bool            `json:"endPage"`
+			NextCursor     string          `json:"nextCursor"`
 			List           []model.MovieDo `json:"list"`
 		}{
-			Total:          total,
+			Total:          pageRes.Total,
 			NextId:         "",
 			Cnt:            len(list),
-			CurrentPageNum: pageNum,
-			NextPageNum:    pageNum + 1,
-			PageSize:       pageSize,
-			EndPage:        flag,
+			CurrentPageNum: page.PageNum,
+			NextPageNum:    page.PageNum + 1,
+			PageSize:       page.PageSize,
+			EndPage:        pageRes.EndPage,
+			NextCursor:     pageRes.NextCursor,
 			List:           list,
 		},
 		RequestID: requestId,
@@ -105,7 +109,11 @@ func (m *MovieApiHandler) MovieDemanded(context *gin.Context) {
 	roomId := context.DefaultQuery("roomId", "no-room-id")
 	pageSize, _ := strconv.Atoi(context.DefaultQuery("pageSize", "10"))
 	pageNum, _ := strconv.Atoi(context.DefaultQuery("pageNum", "1"))
-	list, total, _ := m.roomUserMovieDao.ListByRoomId(xl, roomId, pageNum, pageSize)
+	list, total, err := m.roomUserMovieDao.ListByRoomId(context.Request.Context(), xl, roomId, pageNum, pageSize)
+	if err != nil {
+		writeDatabaseError(context, xl, err)
+		return
+	}
 	flag := false
 	if pageNum*pageSize >= total {
 		flag = true
@@ -116,7 +124,14 @@ func (m *MovieApiHandler) MovieDemanded(context *gin.Context) {
 	}
 	l := make([]TmpResponse, 0, len(list))
 	for _, val := range list {
-		song, _ := m.movieDao.Select(xl, val.MovieId)
+		song, err := m.movieDao.Select(context.Request.Context(), xl, val.MovieId)
+		if err == mgo.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			writeDatabaseError(context, xl, err)
+			return
+		}
 		l = append(l, TmpResponse{
 			MovieDo:  *song,
 			Demander: val.UserId,
@@ -193,11 +208,11 @@ func (m *MovieApiHandler) MovieOperation(context *gin.Context) {
 		return
 	}
 	isRoomMaster := false
-	roomDo, err := m.baseRoomDao.Select(xl, roomId)
+	roomDo, err := m.baseRoomDao.Select(context.Request.Context(), xl, roomId)
 	if roomDo.Creator == userId {
 		isRoomMaster = true
 	}
-	roomUserMovieDo, err := m.roomUserMovieDao.SelectByRoomIdMovieId(xl, roomId, movieId)
+	roomUserMovieDo, err := m.roomUserMovieDao.SelectByRoomIdMovieId(context.Request.Context(), xl, roomId, movieId)
 	if err != nil && err == mgo.ErrNotFound {
 		roomUserMovieDo = &model.RoomUserMovieDo{
 			RoomId:          roomId,
@@ -208,7 +223,7 @@ func (m *MovieApiHandler) MovieOperation(context *gin.Context) {
 			CurrentSchedule: 0,
 			Status:          model.RoomUserMovieAvailable,
 		}
-		_ = m.roomUserMovieDao.Insert(xl, roomUserMovieDo)
+		_ = m.roomUserMovieDao.Insert(context.Request.Context(), xl, roomUserMovieDo)
 	}
 	if roomUserMovieDo.UserId != userId {
 		resp := &model.Response{
@@ -226,7 +241,7 @@ func (m *MovieApiHandler) MovieOperation(context *gin.Context) {
 	} else if op == "delete" {
 		roomUserMovieDo.Status = model.RoomUserSongUnavailable
 	}
-	_ = m.roomUserMovieDao.Update(xl, roomUserMovieDo)
+	_ = m.roomUserMovieDao.Update(context.Request.Context(), xl, roomUserMovieDo)
 	resp := &model.Response{
 		Code:      int(model.ResponseStatusCodeSuccess),
 		Message:   string(model.ResponseStatusMessageSuccess),
@@ -240,7 +255,7 @@ func (m *MovieApiHandler) MovieInfo(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
 	movieId := context.DefaultQuery("movieId", "no-movie-id")
-	movieDo, err := m.movieDao.Select(xl, movieId)
+	movieDo, err := m.movieDao.Select(context.Request.Context(), xl, movieId)
 	if err != nil && err == mgo.ErrNotFound {
 		movieDo = nil
 	}
@@ -290,15 +305,15 @@ func (m *MovieApiHandler) MovieSwitch(context *gin.Context) {
 		context.JSON(http.StatusOK, resp)
 		return
 	}
-	roomUserMovieDo, _ := m.roomUserMovieDao.SelectByRoomIdPlaying(xl, roomId)
+	roomUserMovieDo, _ := m.roomUserMovieDao.SelectByRoomIdPlaying(context.Request.Context(), xl, roomId)
 	if roomUserMovieDo != nil {
 		roomUserMovieDo.Playing = false
-		_ = m.roomUserMovieDao.Update(xl, roomUserMovieDo)
+		_ = m.roomUserMovieDao.Update(context.Request.Context(), xl, roomUserMovieDo)
 	}
-	roomUserMovieDo, _ = m.roomUserMovieDao.SelectByRoomIdMovieId(xl, roomId, movieId)
+	roomUserMovieDo, _ = m.roomUserMovieDao.SelectByRoomIdMovieId(context.Request.Context(), xl, roomId, movieId)
 	if roomUserMovieDo == nil {
 		isRoomMaster := false
-		roomDo, _ := m.baseRoomDao.Select(xl, roomId)
+		roomDo, _ := m.baseRoomDao.Select(context.Request.Context(), xl, roomId)
 		if roomDo.Creator == userId {
 			isRoomMaster = true
 		}
@@ -311,10 +326,10 @@ func (m *MovieApiHandler) MovieSwitch(context *gin.Context) {
 			Playing:         true,
 			Status:          model.RoomUserMovieAvailable,
 		}
-		_ = m.roomUserMovieDao.Insert(xl, roomUserMovieDo)
+		_ = m.roomUserMovieDao.Insert(context.Request.Context(), xl, roomUserMovieDo)
 	} else {
 		roomUserMovieDo.Playing = true
-		_ = m.roomUserMovieDao.Update(xl, roomUserMovieDo)
+		_ = m.roomUserMovieDao.Update(context.Request.Context(), xl, roomUserMovieDo)
 	}
 	resp := &model.Response{
 		Code:      int(model.ResponseStatusCodeSuccess),
@@ -328,7 +343,7 @@ func (m *MovieApiHandler) MovieSwitch(context *gin.Context) {
 func (m *MovieApiHandler) AddMovies(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
-	userId := context.GetString(model.UserIDContextKey)
+	userId := model.ContextCallerID(context)
 	xl.Info("user:[%s] try to add movie.", userId)
 	var input map[string]interface{}
 	err := context.Bind(&input)
@@ -369,9 +384,9 @@ func (m *MovieApiHandler) AddMovies(context *gin.Context) {
 					ReleaseTime: time.UnixMilli(int64(movie["releaseTime"].(float64))),
 					Status:      model.MovieAvailable,
 				}
-				_, err := m.movieDao.SelectByNameDirector(xl, movieDo.Name, movieDo.Director)
+				_, err := m.movieDao.SelectByNameDirector(context.Request.Context(), xl, movieDo.Name, movieDo.Director)
 				if err == mgo.ErrNotFound {
-					_ = m.movieDao.Insert(xl, &movieDo)
+					_ = m.movieDao.Insert(context.Request.Context(), xl, &movieDo)
 				}
 			}
 		}
@@ -388,7 +403,7 @@ func (m *MovieApiHandler) AddMovies(context *gin.Context) {
 func (m *MovieApiHandler) UpdateMovie(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
-	userId := context.GetString(model.UserIDContextKey)
+	userId := model.ContextCallerID(context)
 	xl.Info("user:[%s] try to update movie.", userId)
 	var input map[string]interface{}
 	err := context.Bind(&input)
@@ -401,7 +416,7 @@ func (m *MovieApiHandler) UpdateMovie(context *gin.Context) {
 	}
 	if movie0, ok := input["movie"].(map[string]interface{}); ok {
 		movieId := movie0["movieId"].(string)
-		movieDo, err := m.movieDao.Select(xl, movieId)
+		movieDo, err := m.movieDao.Select(context.Request.Context(), xl, movieId)
 		if err != nil {
 			if err == mgo.ErrNotFound {
 				resp := &model.Response{
@@ -467,7 +482,7 @@ func (m *MovieApiHandler) UpdateMovie(context *gin.Context) {
 		if movie0["releaseTime"] != nil {
 			movieDo.ReleaseTime = time.UnixMilli(int64(movie0["releaseTime"].(float64)))
 		}
-		_ = m.movieDao.Update(xl, movieDo)
+		_ = m.movieDao.Update(context.Request.Context(), xl, movieDo)
 	}
 	resp := &model.Response{
 		Code:      int(model.ResponseStatusCodeSuccess),
@@ -481,7 +496,7 @@ func (m *MovieApiHandler) UpdateMovie(context *gin.Context) {
 func (m *MovieApiHandler) DeleteMovie(context *gin.Context) {
 	xl := context.MustGet(model.XLogKey).(*xlog.Logger)
 	requestId := xl.ReqId
-	userId := context.GetString(model.UserIDContextKey)
+	userId := model.ContextCallerID(context)
 	xl.Info("user:[%s] try to delete movie.", userId)
 	var input map[string]interface{}
 	err := context.Bind(&input)
@@ -493,10 +508,10 @@ func (m *MovieApiHandler) DeleteMovie(context *gin.Context) {
 		return
 	}
 	if movieId, ok := input["movieId"].(string); ok {
-		movieDo, _ := m.movieDao.Select(xl, movieId)
+		movieDo, _ := m.movieDao.Select(context.Request.Context(), xl, movieId)
 		if movieDo != nil {
 			movieDo.Status = model.MovieUnavailable
-			_ = m.movieDao.Update(xl, movieDo)
+			_ = m.movieDao.Update(context.Request.Context(), xl, movieDo)
 		}
 	}
 	resp := &model.Response{
@@ -513,13 +528,34 @@ func (m *MovieApiHandler) ListAllMovie(context *gin.Context) {
 	requestId := xl.ReqId
 	userId := context.GetString(model.UserIDContextKey)
 	xl.Info("user:[%s] try to list movies.", userId)
-	pageSize, _ := strconv.Atoi(context.DefaultQuery("pageSize", "10"))
-	pageNum, _ := strconv.Atoi(context.DefaultQuery("pageNum", "1"))
-	movieDos, _, _ := m.movieDao.ListAll(xl, pageNum, pageSize)
+	page := model.ContextPageQuery(context)
+	movieDos, pageRes, err := m.movieDao.ListAll(context.Request.Context(), xl, page)
+	if err != nil {
+		writeDatabaseError(context, xl, err)
+		return
+	}
 	resp := &model.Response{
-		Code:      int(model.ResponseStatusCodeSuccess),
-		Message:   string(model.ResponseStatusMessageSuccess),
-		Data:      movieDos,
+		Code:    int(model.ResponseStatusCodeSuccess),
+		Message: string(model.ResponseStatusMessageSuccess),
+		Data: struct {
+			Total          int             `json:"total"`
+			Cnt            int             `json:"cnt"`
+			CurrentPageNum int             `json:"currentPageNum"`
+			NextPageNum    int             `json:"nextPageNum"`
+			PageSize       int             `json:"pageSize"`
+			EndPage        bool            `json:"endPage"`
+			NextCursor     string          `json:"nextCursor"`
+			List           []model.MovieDo `json:"list"`
+		}{
+			Total:          pageRes.Total,
+			Cnt:            pageRes.Count,
+			CurrentPageNum: page.PageNum,
+			NextPageNum:    page.PageNum + 1,
+			PageSize:       page.PageSize,
+			EndPage:        pageRes.EndPage,
+			NextCursor:     pageRes.NextCursor,
+			List:           movieDos,
+		},
 		RequestID: requestId,
 	}
 	context.JSON(http.StatusOK, resp)
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/metrics"
 	"github.com/solutions/niu-cube/internal/common/utils"
 	"io"
 	"io/ioutil"
@@ -72,12 +73,12 @@ func NewTokenService(cfg *utils.Config) *TokenService {
 	if err != nil {
 		panic(err)
 	}
+	client := metrics.NewHTTPClient("pandora", 0)
+	client.Jar = jar
 	return &TokenService{
 		PandoraConfig: &cfg.PandoraConfig,
-		Client: &http.Client{
-			Jar: jar,
-		},
-		logger: xlog.New("token-service"),
+		Client:        client,
+		logger:        xlog.New("token-service"),
 	}
 }
 
@@ -16,14 +16,13 @@ import (
 )
 
 type RepairApiHandler struct {
-	AppConfigService  db.AppConfigInterface
-	Account           AccountInterface
-	Repair            db.RepairInterface
-	weixin            *cloud.WeixinService
-	RTC               *cloud.RTCService
-	DefaultAvatarURLs []string
-	RequestUrlHost    string
-	FrontendUrlHost   string
+	AppConfigService db.AppConfigInterface
+	Account          AccountInterface
+	Repair           db.RepairInterface
+	weixin           *cloud.WeixinService
+	RTC              *cloud.RTCService
+	RequestUrlHost   string
+	FrontendUrlHost  string
 }
 
 const (
@@ -48,7 +47,6 @@ func NewRepairApiHandler(conf utils.Config) *RepairApiHandler {
 	if err != nil {
 		panic(err)
 	}
-	i.DefaultAvatarURLs = conf.DefaultAvatars
 	i.RequestUrlHost = conf.RequestUrlHost
 	i.FrontendUrlHost = conf.FrontendUrlHost
 	return i
@@ -72,7 +70,7 @@ func (r *RepairApiHandler) CreateRoom(c *gin.Context) {
 	}
 	if err := args.Validate(); err != nil {
 		xl.Infof("form validate error:%v", err)
-		responseErr := model.NewResponseErrorValidation(err)
+		responseErr := model.NewResponseErrorValidation(form.Localize(err, model.ContextLanguage(c)))
 		model.NewFailResponse(*responseErr).WithRequestID(requestID).Send(c)
 		return
 	}
@@ -83,7 +81,7 @@ func (r *RepairApiHandler) CreateRoom(c *gin.Context) {
 	xl.Infof("roomTitle : %s,role: %s", roomTitle, role)
 
 	// 创建rtc房间
-	userInfo, userInfoErr := r.Account.GetAccountByID(xl, userID)
+	userInfo, userInfoErr := r.Account.GetAccountByID(c.Request.Context(), xl, userID)
 	if userInfoErr != nil {
 		xl.Errorf("failed to get account info for user %s, ", userID)
 		responseErr := model.NewResponseErrorInternal()
@@ -106,7 +104,7 @@ func (r *RepairApiHandler) CreateRoom(c *gin.Context) {
 		Updator:    userID,
 	}
 	// 创建七牛IM群ID
-	qiniuImGroupId, err := r.AppConfigService.GetGroupId(xl, roomID)
+	qiniuImGroupId, err := r.AppConfigService.GetGroupId(c.Request.Context(), xl, roomID)
 	if err != nil {
 		xl.Errorf("failed to craete qiniu's im group, error %v", err)
 		responseErr := model.NewResponseErrorInternal()
@@ -115,7 +113,7 @@ func (r *RepairApiHandler) CreateRoom(c *gin.Context) {
 		return
 	}
 	repairRoom.QiniuIMGroupId = qiniuImGroupId
-	_, err = r.Repair.CreateRoom(xl, repairRoom)
+	_, err = r.Repair.CreateRoom(c.Request.Context(), xl, repairRoom)
 	if err != nil {
 		responseErr := model.NewResponseErrorInternal()
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
@@ -134,7 +132,7 @@ func (r *RepairApiHandler) CreateRoom(c *gin.Context) {
 		UpdateTime:        time.Now(),
 		LastHeartBeatTime: time.Now(),
 	}
-	_, err = r.Repair.CreateRoomUser(xl, repairRoomUser)
+	_, err = r.Repair.CreateRoomUser(c.Request.Context(), xl, repairRoomUser)
 
 	if err != nil {
 		responseErr := model.NewResponseErrorInternal()
@@ -198,14 +196,14 @@ func (r *RepairApiHandler) JoinRoom(c *gin.Context) {
 	}
 	if err := args.Validate(); err != nil {
 		xl.Infof("form validate error:%v", err)
-		responseErr := model.NewResponseErrorValidation(err)
+		responseErr := model.NewResponseErrorValidation(form.Localize(err, model.ContextLanguage(c)))
 		model.NewFailResponse(*responseErr).WithRequestID(requestID).Send(c)
 		return
 	}
 	roomId := args.RoomId
 	role := args.Role
 	xl.Infof("roomId : %s, role: %s", roomId, role)
-	repairRoom, allUserDos, joinRoomErr := r.Repair.JoinRoom(xl, userID, roomId, role)
+	repairRoom, allUserDos, joinRoomErr := r.Repair.JoinRoom(c.Request.Context(), xl, userID, roomId, role)
 	// 数据库插入失败
 	if joinRoomErr != nil {
 		xl.Infof("joinRoomErr error:%v", joinRoomErr)
@@ -215,7 +213,7 @@ func (r *RepairApiHandler) JoinRoom(c *gin.Context) {
 	}
 	allUserList := make([]model.RepairUserInfoResponse, len(allUserDos))
 	for index, userDo := range allUserDos {
-		accountDo, err := r.Account.GetAccountByID(xl, userDo.UserID)
+		accountDo, err := r.Account.GetAccountByID(c.Request.Context(), xl, userDo.UserID)
 		if err != nil {
 			xl.Errorf("failed to make userInfo response for room %s", roomId)
 			continue
@@ -233,7 +231,7 @@ func (r *RepairApiHandler) JoinRoom(c *gin.Context) {
 	// rtc相关的操作
 	roomToken := r.RTC.GenerateRTCRoomToken(roomId, userID, ADMIN)
 	// 构建返回值
-	userInfo, userInfoErr := r.Account.GetAccountByID(xl, userID)
+	userInfo, userInfoErr := r.Account.GetAccountByID(c.Request.Context(), xl, userID)
 	if userInfoErr != nil {
 		xl.Errorf("failed to get account info for user %s, ", userID)
 		responseErr := model.NewResponseErrorInternal()
@@ -294,7 +292,7 @@ func (r *RepairApiHandler) LeaveRoom(c *gin.Context) {
 		return
 	}
 	// 设置roomUser
-	err := r.Repair.LeaveRoom(xl, userID, roomID)
+	err := r.Repair.LeaveRoom(c.Request.Context(), xl, userID, roomID)
 	if err != nil {
 		xl.Errorf("LeaveRoom fail, roomID:%s, userID:%s", roomID, userID)
 		responseErr := model.NewResponseErrorInternal()
@@ -329,7 +327,7 @@ func (r *RepairApiHandler) ListRoom(c *gin.Context) {
 	}
 
 	// 1.1 从room中查看所有存在的房间
-	repairRoomDos, total, err := r.Repair.ListRoomsByPage(xl, userID, pageNum, pageSize)
+	repairRoomDos, total, err := r.Repair.ListRoomsByPage(c.Request.Context(), xl, userID, pageNum, pageSize)
 
 	if err != nil {
 		xl.Errorf("ListRoom fail, userID:%s", userID)
@@ -349,7 +347,7 @@ func (r *RepairApiHandler) ListRoom(c *gin.Context) {
 		repairRoomResponse.Title = repairRoom.Title
 		repairRoomResponse.Status = repairRoom.Status
 		options := []model.RepairRoomOptionResponse{}
-		containStaff, _ := r.Repair.ContainStaff(repairRoom.RoomId)
+		containStaff, _ := r.Repair.ContainStaff(c.Request.Context(), repairRoom.RoomId)
 
 		if !containStaff {
 			options = append(options, model.RepairRoomOptionResponse{
@@ -408,7 +406,7 @@ func (r *RepairApiHandler) HeartBeat(c *gin.Context) {
 	}
 
 	// 设置roomUser
-	err := r.Repair.HeartBeat(xl, userID, roomID)
+	err := r.Repair.HeartBeat(c.Request.Context(), xl, userID, roomID)
 	if err != nil {
 		xl.Errorf("HeartBeat fail, roomID:%s, userID:%s", roomID, userID)
 		responseErr := model.NewResponseErrorInternal()
@@ -444,7 +442,7 @@ func (r *RepairApiHandler) GetRoomInfo(c *gin.Context) {
 		return
 	}
 
-	repairRoom, allUserDos, joinRoomErr := r.Repair.GetRoomContent(xl, userID, roomId)
+	repairRoom, allUserDos, joinRoomErr := r.Repair.GetRoomContent(c.Request.Context(), xl, userID, roomId)
 	if joinRoomErr != nil {
 		xl.Infof("joinRoomErr error:%v", joinRoomErr)
 		responseErr := model.NewResponseError(model.ResponseErrorGetRoomContent, "GetRoomContent fail")
@@ -454,7 +452,7 @@ func (r *RepairApiHandler) GetRoomInfo(c *gin.Context) {
 
 	allUserList := make([]model.RepairUserInfoResponse, len(allUserDos))
 	for index, userDo := range allUserDos {
-		accountDo, err := r.Account.GetAccountByID(xl, userDo.UserID)
+		accountDo, err := r.Account.GetAccountByID(c.Request.Context(), xl, userDo.UserID)
 		if err != nil {
 			xl.Errorf("failed to make userInfo response for room %s", roomId)
 			continue
@@ -471,7 +469,7 @@ func (r *RepairApiHandler) GetRoomInfo(c *gin.Context) {
 	}
 
 	// 构建返回值
-	userInfo, userInfoErr := r.Account.GetAccountByID(xl, userID)
+	userInfo, userInfoErr := r.Account.GetAccountByID(c.Request.Context(), xl, userID)
 	if userInfoErr != nil {
 		xl.Errorf("failed to get account info for user %s, ", userID)
 		responseErr := model.NewResponseErrorInternal()
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+	"gopkg.in/mgo.v2"
+)
+
+type RoleInterface interface {
+	GetAccountByID(ctx context.Context, xl *xlog.Logger, id string) (*model.AccountDo, error)
+
+	// GrantRole 授予账号角色
+	GrantRole(ctx context.Context, xl *xlog.Logger, id string, role model.Role) error
+
+	// RevokeRole 撤销账号角色
+	RevokeRole(ctx context.Context, xl *xlog.Logger, id string, role model.Role) error
+}
+
+// RoleApiHandler 管理员维护账号角色。
+type RoleApiHandler struct {
+	Account RoleInterface
+}
+
+func NewRoleApiHandler(account RoleInterface) *RoleApiHandler {
+	return &RoleApiHandler{Account: account}
+}
+
+// ListRoles 列出所有角色及其权限。
+func (h *RoleApiHandler) ListRoles(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	list := make([]model.RoleResponse, 0, len(model.AllRoles))
+	for _, role := range model.AllRoles {
+		permissions := model.RolePermissions[role]
+		if role == model.RoleAdmin {
+			permissions = []model.Permission{"*"}
+		}
+		list = append(list, model.RoleResponse{Role: role, Permissions: permissions})
+	}
+	c.JSON(http.StatusOK, model.NewSuccessResponse(model.RoleListResponse{List: list}).WithRequestID(xl.ReqId))
+}
+
+// GetAccountRoles 查询账号拥有的角色。
+func (h *RoleApiHandler) GetAccountRoles(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	account, ok := h.fetchAccount(c, xl)
+	if !ok {
+		return
+	}
+	roles := account.Roles
+	if roles == nil {
+		roles = make([]model.Role, 0)
+	}
+	res := model.NewSuccessResponse(model.AccountRolesResponse{
+		AccountID: account.ID,
+		Roles:     roles,
+	}).WithRequestID(requestID)
+	c.JSON(http.StatusOK, res)
+}
+
+// GrantRole 授予账号角色。
+func (h *RoleApiHandler) GrantRole(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	args := model.GrantRoleArgs{}
+	err := c.Bind(&args)
+	if err != nil || !model.IsValidRole(args.Role) || args.Role == model.RoleUser {
+		xl.Infof("GrantRole: invalid role %q, error %v", args.Role, err)
+		responseErr := model.NewResponseErrorBadRequest()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	account, ok := h.fetchAccount(c, xl)
+	if !ok {
+		return
+	}
+	err = h.Account.GrantRole(c.Request.Context(), xl, account.ID, args.Role)
+	if err != nil {
+		responseErr := model.NewResponseErrorInternal()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	xl.Infof("user %s granted role %s to account %s", c.GetString(model.UserIDContextKey), args.Role, account.ID)
+	c.JSON(http.StatusOK, model.NewSuccessResponse(nil).WithRequestID(requestID))
+}
+
+// RevokeRole 撤销账号角色。
+func (h *RoleApiHandler) RevokeRole(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	requestID := xl.ReqId
+	role := model.Role(c.Param("role"))
+	if !model.IsValidRole(role) {
+		responseErr := model.NewResponseErrorBadRequest()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	account, ok := h.fetchAccount(c, xl)
+	if !ok {
+		return
+	}
+	err := h.Account.RevokeRole(c.Request.Context(), xl, account.ID, role)
+	if err != nil {
+		responseErr := model.NewResponseErrorInternal()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	xl.Infof("user %s revoked role %s from account %s", c.GetString(model.UserIDContextKey), role, account.ID)
+	c.JSON(http.StatusOK, model.NewSuccessResponse(nil).WithRequestID(requestID))
+}
+
+func (h *RoleApiHandler) fetchAccount(c *gin.Context, xl *xlog.Logger) (*model.AccountDo, bool) {
+	accountID := c.Param("accountId")
+	account, err := h.Account.GetAccountByID(c.Request.Context(), xl, accountID)
+	if err != nil {
+		responseErr := model.NewResponseErrorInternal()
+		if err == mgo.ErrNotFound {
+			responseErr = model.NewResponseErrorNoSuchUser()
+		}
+		resp := model.NewFailResponse(*responseErr).WithRequestID(xl.ReqId)
+		c.JSON(http.StatusOK, resp)
+		return nil, false
+	}
+	return account, true
+}
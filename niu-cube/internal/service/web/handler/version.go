@@ -30,14 +30,14 @@ func (v *VersionHandlerApi) CreateVersion(c *gin.Context) {
 	err := c.Bind(&versionForm)
 	if err != nil {
 		xl.Errorf("form binding error:%v", err)
-		respErr := model.NewResponseErrorValidation(err)
+		respErr := model.NewResponseErrorValidation(form2.Localize(err, model.ContextLanguage(c)))
 		model.NewFailResponse(*respErr).WithRequestID(xl.ReqId).Send(c)
 		return
 	}
 	err = versionForm.Validate()
 	if err != nil {
 		xl.Errorf("form valdiation error:%v", err)
-		respErr := model.NewResponseErrorValidation(err)
+		respErr := model.NewResponseErrorValidation(form2.Localize(err, model.ContextLanguage(c)))
 		model.NewFailResponse(*respErr).WithRequestID(xl.ReqId).Send(c)
 		return
 	}
@@ -53,7 +53,7 @@ func (v *VersionHandlerApi) CreateVersion(c *gin.Context) {
 	err = v.versionService.Create(xl, version)
 	if err != nil {
 		xl.Errorf("version service creating error:%v", err)
-		respErr := model.NewResponseErrorValidation(err)
+		respErr := model.NewResponseErrorValidation(form2.Localize(err, model.ContextLanguage(c)))
 		model.NewFailResponse(*respErr).WithRequestID(xl.ReqId).Send(c)
 		return
 	}
@@ -74,7 +74,7 @@ func (v *VersionHandlerApi) GetOrListVersion(c *gin.Context) {
 	err := c.Bind(&filterForm)
 	if err != nil {
 		xl.Errorf("form binding error:%v", err)
-		respErr := model.NewResponseErrorValidation(err)
+		respErr := model.NewResponseErrorValidation(form2.Localize(err, model.ContextLanguage(c)))
 		model.NewFailResponse(*respErr).WithRequestID(xl.ReqId).Send(c)
 		return
 	}
@@ -86,7 +86,7 @@ func (v *VersionHandlerApi) GetOrListVersion(c *gin.Context) {
 		versions, totalCnt, err := v.versionService.GetPageByMap(v.xl, filter, pageNum, pageSize)
 		if err != nil {
 			xl.Errorf("version service get error:%v", err)
-			respErr := model.NewResponseErrorValidation(err)
+			respErr := model.NewResponseErrorValidation(form2.Localize(err, model.ContextLanguage(c)))
 			model.NewFailResponse(*respErr).WithRequestID(xl.ReqId).Send(c)
 			return
 		}
@@ -115,7 +115,7 @@ func (v *VersionHandlerApi) GetOrListVersion(c *gin.Context) {
 		version, err := v.versionService.GetOneByMap(v.xl, filter)
 		if err != nil {
 			xl.Errorf("version service get error:%v", err)
-			respErr := model.NewResponseErrorValidation(err)
+			respErr := model.NewResponseErrorValidation(form2.Localize(err, model.ContextLanguage(c)))
 			model.NewFailResponse(*respErr).WithRequestID(xl.ReqId).Send(c)
 			return
 		}
@@ -130,14 +130,14 @@ func (v *VersionHandlerApi) DeleteVersion(c *gin.Context) {
 	versionId := c.Param("versionId")
 	if versionId == "" {
 		xl.Errorf("no versionId found error:%v", form2.ErrVersionIdNeeded)
-		respErr := model.NewResponseErrorValidation(form2.ErrVersionIdNeeded)
+		respErr := model.NewResponseErrorValidation(form2.Localize(form2.ErrVersionIdNeeded, model.ContextLanguage(c)))
 		model.NewFailResponse(*respErr).WithRequestID(xl.ReqId).Send(c)
 		return
 	}
 	err := v.versionService.Delete(xl, versionId)
 	if err != nil {
 		xl.Errorf("version service creating error:%v", err)
-		respErr := model.NewResponseErrorValidation(err)
+		respErr := model.NewResponseErrorValidation(form2.Localize(err, model.ContextLanguage(c)))
 		model.NewFailResponse(*respErr).WithRequestID(xl.ReqId).Send(c)
 		return
 	}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiniu/x/xlog"
+	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+	service "github.com/solutions/niu-cube/internal/service/db"
+)
+
+// fakeApiKeys 按key原文返回API key，并按service.IPAllowed校验白名单。
+type fakeApiKeys struct {
+	keys   map[string]*model.ApiKeyDo
+	lastIP string
+}
+
+func (f *fakeApiKeys) Authenticate(ctx context.Context, xl *xlog.Logger, key string, ip string) (*model.ApiKeyDo, error) {
+	f.lastIP = ip
+	apiKey, ok := f.keys[key]
+	if !ok {
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenInvalid, Summary: "api key not found"}
+	}
+	if !service.IPAllowed(apiKey.AllowedIPs, ip) {
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorUserNoPermission, Summary: "ip not allowed"}
+	}
+	return apiKey, nil
+}
+
+func withFakeApiKeys(t *testing.T) *fakeApiKeys {
+	t.Helper()
+	fake := &fakeApiKeys{keys: map[string]*model.ApiKeyDo{
+		"song.secret": {ID: "song", Permissions: []model.Permission{model.PermissionSongManage}},
+		"office.secret": {
+			ID:          "office",
+			Permissions: []model.Permission{model.PermissionSongManage},
+			AllowedIPs:  []string{"10.0.0.0/8"},
+		},
+	}}
+	origin := apiKeyService
+	apiKeyService = fake
+	t.Cleanup(func() { apiKeyService = origin })
+	return fake
+}
+
+// withApiKey 设置请求的X-Api-Key与直连地址。
+func withApiKey(key string, remoteAddr string, forwardedFor string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		c.Request.Header.Set(model.ApiKeyHeader, key)
+		c.Request.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			c.Request.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+	}
+}
+
+func TestAllowApiKey(t *testing.T) {
+	withFakeApiKeys(t)
+	cases := []struct {
+		name       string
+		setup      func(c *gin.Context)
+		permission model.Permission
+		wantPass   bool
+		wantCode   int
+	}{
+		{
+			name:       "granted permission",
+			setup:      withApiKey("song.secret", "192.0.2.1:1234", ""),
+			permission: model.PermissionSongManage,
+			wantPass:   true,
+		},
+		{
+			name:       "permission out of scope",
+			setup:      withApiKey("song.secret", "192.0.2.1:1234", ""),
+			permission: model.PermissionExamManage,
+			wantCode:   model.ResponseErrorPermissionDenied,
+		},
+		{
+			name:       "unknown key",
+			setup:      withApiKey("other.secret", "192.0.2.1:1234", ""),
+			permission: model.PermissionSongManage,
+			wantCode:   model.ResponseErrorBadToken,
+		},
+		{
+			name:       "ip in allowlist",
+			setup:      withApiKey("office.secret", "10.1.2.3:1234", ""),
+			permission: model.PermissionSongManage,
+			wantPass:   true,
+		},
+		{
+			name:       "ip not in allowlist",
+			setup:      withApiKey("office.secret", "192.0.2.1:1234", ""),
+			permission: model.PermissionSongManage,
+			wantCode:   model.ResponseErrorPermissionDenied,
+		},
+		{
+			name:       "spoofed forwarded ip from untrusted peer",
+			setup:      withApiKey("office.secret", "192.0.2.1:1234", "10.1.2.3"),
+			permission: model.PermissionSongManage,
+			wantCode:   model.ResponseErrorPermissionDenied,
+		},
+		{
+			name:       "forwarded ip from trusted proxy",
+			setup:      withApiKey("office.secret", "127.0.0.1:1234", "10.1.2.3"),
+			permission: model.PermissionSongManage,
+			wantPass:   true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			passed, resp := runMiddleware(t, tc.setup, AllowApiKey(tc.permission))
+			if passed != tc.wantPass {
+				t.Fatalf("passed = %v, want %v, response %+v", passed, tc.wantPass, resp)
+			}
+			if !tc.wantPass && resp.Code != tc.wantCode {
+				t.Fatalf("code = %d, want %d", resp.Code, tc.wantCode)
+			}
+		})
+	}
+}
+
+func TestAllowApiKeyIsNotAccount(t *testing.T) {
+	withFakeApiKeys(t)
+	var userSet, userIDSet bool
+	var callerID string
+	_, _ = runMiddleware(t, withApiKey("song.secret", "192.0.2.1:1234", ""), func(c *gin.Context) {
+		AllowApiKey(model.PermissionSongManage)(c)
+		_, userSet = c.Get(model.UserContextKey)
+		_, userIDSet = c.Get(model.UserIDContextKey)
+		callerID = model.ContextCallerID(c)
+	})
+	if userSet || userIDSet {
+		t.Fatalf("api key caller set as account")
+	}
+	if callerID != model.ApiKeyAccountPrefix+"song" {
+		t.Fatalf("caller id = %q", callerID)
+	}
+}
+
+func TestAuthenticateRejectsApiKey(t *testing.T) {
+	fake := withFakeApiKeys(t)
+	fake.lastIP = "unused"
+	passed, resp := runMiddleware(t, withApiKey("song.secret", "192.0.2.1:1234", ""), Authenticate)
+	if passed {
+		t.Fatalf("api key passed Authenticate")
+	}
+	if resp.Code != model.ResponseErrorPermissionDenied {
+		t.Fatalf("code = %d, want %d", resp.Code, model.ResponseErrorPermissionDenied)
+	}
+	if fake.lastIP != "unused" {
+		t.Fatalf("api key authenticated by Authenticate")
+	}
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+)
+
+// rawJSONKeys V2响应中值为用户提交或导出的任意JSON的字段，只转换字段名，不转换其中的键名。
+var rawJSONKeys = map[string]bool{
+	"value":       true,
+	"biz_extra":   true,
+	"collections": true,
+	"removed":     true,
+	"anonymized":  true,
+}
+
+// v2Writer 把处理函数写入的响应转换为V2的返回格式，gin的JSON响应体一次写入。
+type v2Writer struct {
+	gin.ResponseWriter
+	c       *gin.Context
+	written bool
+}
+
+func (w *v2Writer) Write(b []byte) (int, error) {
+	if !w.written {
+		w.written = true
+		if status, body, ok := w.convert(b); ok {
+			w.ResponseWriter.WriteHeader(status)
+			if _, err := w.ResponseWriter.Write(body); err != nil {
+				return 0, err
+			}
+			return len(b), nil
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *v2Writer) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// convert 返回V2响应的HTTP状态码与响应体，响应不是JSON对象时返回false，原样写入。
+func (w *v2Writer) convert(body []byte) (int, []byte, bool) {
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		return 0, nil, false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil || fields == nil {
+		return 0, nil, false
+	}
+	resp := newV2Response(fields, w.Status())
+	if resp.RequestID == "" {
+		if xl, ok := w.c.Get(model.XLogKey); ok {
+			resp.RequestID = xl.(*xlog.Logger).ReqId
+		}
+	}
+	res, err := json.Marshal(resp)
+	if err != nil {
+		return 0, nil, false
+	}
+	status := model.ResponseHTTPStatus(resp.Code)
+	if resp.Code == int(model.ResponseStatusCodeSuccess) && w.Status() >= http.StatusBadRequest {
+		status = w.Status()
+	}
+	return status, res, true
+}
+
+// newV2Response 把model.Response、直接返回的model.ResponseError或其他JSON对象转换为统一的返回格式，
+// status为处理函数设置的HTTP状态码。
+func newV2Response(fields map[string]interface{}, status int) *model.Response {
+	code, ok := fields["code"].(json.Number)
+	if !ok {
+		// 不是model.Response的返回体整体作为data，处理函数返回错误状态码时按内部错误处理。
+		if status >= http.StatusBadRequest {
+			responseErr := model.NewResponseErrorInternal()
+			return model.NewFailResponse(*responseErr)
+		}
+		return model.NewSuccessResponse(camelCaseJSON(fields))
+	}
+	resp := &model.Response{}
+	if n, err := code.Int64(); err == nil {
+		resp.Code = int(n)
+	} else {
+		resp.Code = model.ResponseErrorInternal
+	}
+	resp.Message, _ = fields["message"].(string)
+	resp.Data = camelCaseJSON(fields["data"])
+	if requestID, ok := fields["requestId"].(string); ok {
+		resp.RequestID = requestID
+	} else if requestID, ok := fields["requestID"].(string); ok {
+		resp.RequestID = requestID
+	}
+	return resp
+}
+
+// camelCaseJSON 把JSON对象中下划线连接的字段名转换为驼峰形式，如applet_qrcode转换为appletQrcode。
+func camelCaseJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if !rawJSONKeys[key] {
+				item = camelCaseJSON(item)
+			}
+			res[camelCase(key)] = item
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			res = append(res, camelCaseJSON(item))
+		}
+		return res
+	}
+	return value
+}
+
+// camelCase 转换下划线连接的字段名，以下划线开头的字段名（如_id）保持不变。
+func camelCase(key string) string {
+	if !strings.Contains(key, "_") || strings.HasPrefix(key, "_") {
+		return key
+	}
+	parts := strings.Split(key, "_")
+	var b strings.Builder
+	b.WriteString(parts[0])
+	for _, part := range parts[1:] {
+		if part == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]))
+		b.WriteString(part[1:])
+	}
+	return b.String()
+}
+
+// APIV2 把V2接口的响应转换为统一的返回格式：响应体为{"code","message","data","requestId"}，
+// data中的字段名使用驼峰形式，HTTP状态码由错误码通过model.ResponseHTTPStatus得到。
+func APIV2(c *gin.Context) {
+	c.Writer = &v2Writer{ResponseWriter: c.Writer, c: c}
+	c.Next()
+}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiniu/x/xlog"
+
+	"github.com/solutions/niu-cube/internal/protodef/model"
+)
+
+func v2Request(t *testing.T, handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handlers = append([]gin.HandlerFunc{func(c *gin.Context) {
+		c.Set(model.XLogKey, xlog.New("reqid"))
+	}, APIV2}, handlers...)
+	router.GET("/v2/test", handlers...)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v2/test", nil))
+	return recorder
+}
+
+func TestAPIV2(t *testing.T) {
+	cases := []struct {
+		name       string
+		handlers   []gin.HandlerFunc
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name: "success",
+			handlers: []gin.HandlerFunc{func(c *gin.Context) {
+				data := map[string]interface{}{
+					"applet_qrcode": "qr",
+					"roomId":        "r",
+					"list":          []interface{}{map[string]interface{}{"app_name": "cube", "value": map[string]int{"user_key": 1}}},
+					"collections":   map[string]interface{}{"base_room": []interface{}{map[string]interface{}{"_id": "1", "room_id": "r"}}},
+				}
+				c.JSON(http.StatusOK, model.NewSuccessResponse(data))
+			}},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"code":0,"message":"success","data":{"appletQrcode":"qr","collections":{"base_room":[{"_id":"1","room_id":"r"}]},"list":[{"appName":"cube","value":{"user_key":1}}],"roomId":"r"},"requestId":"reqid"}`,
+		},
+		{
+			name: "not logged in",
+			handlers: []gin.HandlerFunc{func(c *gin.Context) {
+				responseErr := model.NewResponseErrorNotLoggedIn()
+				c.JSON(http.StatusOK, model.NewFailResponse(*responseErr).WithRequestID("client"))
+				c.Abort()
+			}},
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   `{"code":401001,"message":"not logged in","data":null,"requestId":"client"}`,
+		},
+		{
+			name: "validation",
+			handlers: []gin.HandlerFunc{func(c *gin.Context) {
+				c.JSON(http.StatusOK, model.NewResponseError(model.ResponseErrorValidation, "title: cannot be blank."))
+			}},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"code":401005,"message":"title: cannot be blank.","data":null,"requestId":"reqid"}`,
+		},
+		{
+			name: "rate limited",
+			handlers: []gin.HandlerFunc{func(c *gin.Context) {
+				responseErr := model.NewResponseErrorRateLimited()
+				c.JSON(http.StatusTooManyRequests, model.NewFailResponse(*responseErr))
+			}},
+			wantStatus: http.StatusTooManyRequests,
+			wantBody:   `{"code":429006,"message":"too many requests, try again later","data":null,"requestId":"reqid"}`,
+		},
+		{
+			name: "plain json",
+			handlers: []gin.HandlerFunc{func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"upload_token": "t"})
+			}},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"code":0,"message":"success","data":{"uploadToken":"t"},"requestId":"reqid"}`,
+		},
+		{
+			name: "plain json error",
+			handlers: []gin.HandlerFunc{func(c *gin.Context) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "boom"})
+			}},
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   `{"code":500000,"message":"internal server error","data":null,"requestId":"reqid"}`,
+		},
+		{
+			name: "not json",
+			handlers: []gin.HandlerFunc{func(c *gin.Context) {
+				c.String(http.StatusOK, "#EXTM3U")
+			}},
+			wantStatus: http.StatusOK,
+			wantBody:   "#EXTM3U",
+		},
+	}
+	for _, c := range cases {
+		resp := v2Request(t, c.handlers...)
+		if resp.Code != c.wantStatus || resp.Body.String() != c.wantBody {
+			t.Errorf("%s: status %d, body %s, want %d %s", c.name, resp.Code, resp.Body.String(), c.wantStatus, c.wantBody)
+		}
+	}
+}
+
+func TestResponseHTTPStatus(t *testing.T) {
+	cases := map[int]int{
+		0:                                     http.StatusOK,
+		model.ResponseErrorBadRequest:         http.StatusBadRequest,
+		model.ResponseErrorTokenExpired:       http.StatusUnauthorized,
+		model.ResponseErrorPermissionDenied:   http.StatusForbidden,
+		model.ResponseErrorNoSuchRoom:         http.StatusNotFound,
+		model.ResponseErrorPhoneUsed:          http.StatusConflict,
+		model.ResponseErrorExamDuplicateEntry: http.StatusConflict,
+		model.ResponseErrorExternalService:    http.StatusBadGateway,
+		model.ResponseErrorTimeout:            http.StatusGatewayTimeout,
+		123:                                   http.StatusInternalServerError,
+	}
+	for code, want := range cases {
+		if got := model.ResponseHTTPStatus(code); got != want {
+			t.Errorf("ResponseHTTPStatus(%d) = %d, want %d", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+)
+
+// AuditLogWriter 保存审计日志，Write不能阻塞请求。
+type AuditLogWriter interface {
+	Write(record *model.AuditLogDo)
+}
+
+// auditLogWriter 为nil时不记录审计日志。
+var auditLogWriter AuditLogWriter
+
+// SetAuditLogWriter 设置审计日志的写入方式，writer为nil时不记录审计日志。
+func SetAuditLogWriter(writer AuditLogWriter) {
+	auditLogWriter = writer
+}
+
+// AuditResource 记录请求操作的资源。路由参数与以Id结尾的查询参数会自动记录，
+// 请求体中的资源ID（如房间ID）需要处理函数调用本函数记录。
+func AuditResource(c *gin.Context, resourceType, id string) {
+	if id == "" {
+		return
+	}
+	var resources []model.AuditResource
+	if val, ok := c.Get(model.AuditResourcesContextKey); ok {
+		resources = val.([]model.AuditResource)
+	}
+	for _, resource := range resources {
+		if resource.Type == resourceType && resource.ID == id {
+			return
+		}
+	}
+	c.Set(model.AuditResourcesContextKey, append(resources, model.AuditResource{Type: resourceType, ID: id}))
+}
+
+// AuditLog 请求处理完成后记录审计日志：调用者、路由、状态码、耗时、客户端IP与操作的资源。
+// 处理函数可以通过context中的Action补充操作描述。
+func AuditLog(c *gin.Context) {
+	method := c.Request.Method
+	route := c.FullPath()
+	matched, _ := defaultActionManager.MatchRoute(method, route)
+	action := *matched
+	c.Set(model.ActionLogContentKey, &action)
+	start := time.Now()
+	c.Next()
+
+	val, _ := c.Get(model.ActionLogContentKey)
+	msg := val.(*Action).With(c)
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+	record := &model.AuditLogDo{
+		Time:      start,
+		Method:    method,
+		Route:     route,
+		Status:    c.Writer.Status(),
+		LatencyMs: time.Since(start).Milliseconds(),
+		ClientIP:  ClientIP(c),
+		UserPhone: msg.userPhone,
+		Msg:       msg.String(),
+		Resources: auditResources(c),
+	}
+	if val, ok := c.Get(model.XLogKey); ok {
+		xl := val.(*xlog.Logger)
+		record.RequestID = xl.ReqId
+		xl.Debugf("audit: %s %s %d %s", method, route, record.Status, record.Msg)
+	}
+	if val, ok := c.Get(model.ApiKeyContextKey); ok {
+		record.ApiKeyID = val.(*model.ApiKeyDo).ID
+	} else {
+		record.UserID = c.GetString(model.UserIDContextKey)
+	}
+	if writer := auditLogWriter; writer != nil {
+		writer.Write(record)
+	}
+}
+
+// auditResources 返回路由参数、以Id结尾的查询参数，以及处理函数通过AuditResource记录的资源。
+func auditResources(c *gin.Context) []model.AuditResource {
+	for _, param := range c.Params {
+		AuditResource(c, param.Key, param.Value)
+	}
+	for key, values := range c.Request.URL.Query() {
+		if strings.HasSuffix(key, "Id") && len(values) > 0 {
+			AuditResource(c, key, values[0])
+		}
+	}
+	if val, ok := c.Get(model.AuditResourcesContextKey); ok {
+		return val.([]model.AuditResource)
+	}
+	return nil
+}
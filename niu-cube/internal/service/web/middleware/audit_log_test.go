@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+)
+
+type fakeAuditLogWriter struct {
+	records []*model.AuditLogDo
+}
+
+func (w *fakeAuditLogWriter) Write(record *model.AuditLogDo) {
+	w.records = append(w.records, record)
+}
+
+func TestAuditLog(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	writer := &fakeAuditLogWriter{}
+	SetAuditLogWriter(writer)
+	defer SetAuditLogWriter(nil)
+
+	router := gin.New()
+	router.POST("/v1/rooms/:roomId/leave", func(c *gin.Context) {
+		c.Set(model.XLogKey, xlog.New("req-audit"))
+	}, AuditLog, func(c *gin.Context) {
+		c.Set(model.UserIDContextKey, "user-1")
+		c.Set(model.UserPhoneContextKey, "13800000000")
+		AuditResource(c, "micId", "mic-1")
+		c.JSON(http.StatusForbidden, model.NewSuccessResponse(nil))
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v1/rooms/room-1/leave?interviewId=iv-1&pageNum=2", nil)
+	req.RemoteAddr = "192.0.2.10:1234"
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(writer.records) != 1 {
+		t.Fatalf("got %d records, want 1", len(writer.records))
+	}
+	record := writer.records[0]
+	if record.RequestID != "req-audit" || record.UserID != "user-1" || record.UserPhone != "13800000000" {
+		t.Errorf("caller = %q %q %q", record.RequestID, record.UserID, record.UserPhone)
+	}
+	if record.Method != http.MethodPost || record.Route != "/v1/rooms/:roomId/leave" || record.Status != http.StatusForbidden {
+		t.Errorf("request = %s %s %d", record.Method, record.Route, record.Status)
+	}
+	if record.ClientIP != "192.0.2.10" {
+		t.Errorf("client ip = %q", record.ClientIP)
+	}
+	resources := map[string]string{}
+	for _, resource := range record.Resources {
+		resources[resource.Type] = resource.ID
+	}
+	want := map[string]string{"roomId": "room-1", "interviewId": "iv-1", "micId": "mic-1"}
+	if len(resources) != len(want) {
+		t.Errorf("resources = %v, want %v", resources, want)
+	}
+	for k, v := range want {
+		if resources[k] != v {
+			t.Errorf("resource %s = %q, want %q", k, resources[k], v)
+		}
+	}
+}
+
+func TestAuditLogApiKey(t *testing.T) {
+	writer := &fakeAuditLogWriter{}
+	SetAuditLogWriter(writer)
+	defer SetAuditLogWriter(nil)
+
+	runMiddleware(t, func(c *gin.Context) {
+		c.Set(model.ApiKeyContextKey, &model.ApiKeyDo{ID: "key-1", Name: "cms"})
+	}, AuditLog)
+	if len(writer.records) != 1 {
+		t.Fatalf("got %d records, want 1", len(writer.records))
+	}
+	record := writer.records[0]
+	if record.ApiKeyID != "key-1" || record.UserID != "" {
+		t.Errorf("caller = api key %q user %q", record.ApiKeyID, record.UserID)
+	}
+	if record.Route != "/test" || record.Status != http.StatusOK {
+		t.Errorf("request = %s %d", record.Route, record.Status)
+	}
+}
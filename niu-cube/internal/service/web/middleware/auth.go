@@ -1,8 +1,8 @@
 package middleware
 
 import (
-	"encoding/base64"
-	"encoding/json"
+	"context"
+	errors2 "github.com/solutions/niu-cube/internal/protodef/errors"
 	model "github.com/solutions/niu-cube/internal/protodef/model"
 	"net/http"
 	"net/url"
@@ -19,17 +19,81 @@ func Authenticate(c *gin.Context) {
 	// TODO 获取UA信息
 	FetchUserAgent(xl, requestID, c)
 
+	// API key不对应账号，只能访问通过AllowApiKey声明了权限的接口。
+	if c.GetHeader(model.ApiKeyHeader) != "" {
+		xl.Infof("%s %s: api key is not accepted", c.Request.Method, c.Request.URL.Path)
+		responseErr := model.NewResponseErrorPermissionDenied()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID).WithErrorMessage("api key is not accepted by this api")
+		c.JSON(http.StatusOK, resp)
+		c.Abort()
+		return
+	}
 	// 优先根据Authorization:Bearer <token>校验。
 	FetchTokenFromHeader(xl, requestID, c)
 }
 
+// ApiKeyAuthenticator 校验API key原文与调用方IP，由service.ApiKeyService实现。
+type ApiKeyAuthenticator interface {
+	Authenticate(ctx context.Context, xl *xlog.Logger, key string, ip string) (*model.ApiKeyDo, error)
+}
+
+// AllowApiKey 校验登录账号或X-Api-Key，并要求具备所有给定权限，代替Authenticate与RequirePermission。
+// 只有使用该中间件的接口接受API key；通过API key访问时不设置当前用户，处理函数需使用model.ContextCallerID记录调用方。
+func AllowApiKey(permissions ...model.Permission) gin.HandlerFunc {
+	if len(permissions) == 0 {
+		panic("AllowApiKey requires at least one permission")
+	}
+	requirePermission := RequirePermission(permissions...)
+	return func(c *gin.Context) {
+		xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+		requestID := xl.ReqId
+		if c.GetHeader(model.ApiKeyHeader) == "" {
+			Authenticate(c)
+		} else {
+			FetchUserAgent(xl, requestID, c)
+			FetchApiKeyFromHeader(xl, requestID, c)
+		}
+		if c.IsAborted() {
+			return
+		}
+		requirePermission(c)
+	}
+}
+
+// FetchApiKeyFromHeader 根据X-Api-Key校验服务端调用。API key不对应账号，只具备创建时授予的权限。
+// IP白名单使用ClientIP得到的请求方IP，只信任可信代理转发的地址。
+func FetchApiKeyFromHeader(xl *xlog.Logger, requestID string, c *gin.Context) {
+	apiKey, err := apiKeyService.Authenticate(c.Request.Context(), xl, c.GetHeader(model.ApiKeyHeader), ClientIP(c))
+	if err != nil {
+		xl.Infof("%s %s: reject api key, error %v", c.Request.Method, c.Request.URL.Path, err)
+		responseErr := model.NewResponseErrorBadToken()
+		if serverErr, ok := err.(*errors2.ServerError); ok {
+			switch serverErr.Code {
+			case errors2.ServerErrorTokenExpired:
+				responseErr = model.NewResponseErrorTokenExpired()
+			case errors2.ServerErrorUserNoPermission:
+				responseErr = model.NewResponseErrorPermissionDenied()
+			}
+		} else {
+			responseErr = model.NewResponseErrorInternal()
+		}
+		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+		c.JSON(http.StatusOK, resp)
+		c.Abort()
+		return
+	}
+	c.Set(model.ApiKeyContextKey, apiKey)
+	c.Set(model.TokenSourceContextKey, model.TokenSourceFromApiKey)
+}
+
+// AfapAuthenticate 优先根据面试邀请token校验，没有邀请token时根据Authorization:Bearer <token>校验。
+// 邀请token只对路径中interviewId对应的面试有效，篡改、过期、已吊销或用于其他面试的token直接拒绝。
 func AfapAuthenticate(c *gin.Context) {
 	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
 	requestID := xl.ReqId
 	// 无登录状态用户根据interviewToken进行获取用户ID
 	interviewToken := c.PostForm("interviewToken")
-	val, err := url.QueryUnescape(interviewToken)
-	if err != nil {
+	if val, err := url.QueryUnescape(interviewToken); err == nil {
 		interviewToken = val
 	}
 
@@ -41,32 +105,58 @@ func AfapAuthenticate(c *gin.Context) {
 		interviewToken = c.Query("interviewToken")
 	}
 	if interviewToken != "" {
-		xl.Debugf("fetch interviewToken success. inteviewToken: %s", interviewToken)
-		decodedInterviewParams, err := base64.StdEncoding.DecodeString(interviewToken)
-		if err == nil {
-			interviewTokenArgs := model.InterviewTokenArgs{}
-			err = json.Unmarshal([]byte(decodedInterviewParams), &interviewTokenArgs)
-			if err == nil && interviewTokenArgs.UserID != "" {
-				userID := interviewTokenArgs.UserID
-				user, _ := accountService.GetAccountByID(xl, interviewTokenArgs.UserID)
-				c.Set(model.UserContextKey, *user)
-				c.Set(model.UserIDContextKey, userID)
-				c.Set(model.TokenSourceContextKey, model.TokenSourceFromInterviewToken)
-				xl.Debugf("fetch interviewToken success. inteviewToken: %s, userID: %s", interviewToken, userID)
-			} else {
-				xl.Debugf("fetch interviewToken fail. inteviewToken: %s", interviewToken)
+		interviewID := c.Param("interviewId")
+		args, err := verifyInterviewToken(c.Request.Context(), xl, interviewToken, interviewID)
+		if err != nil {
+			xl.Infof("%s %s: reject interviewToken for interview %s, error %v", c.Request.Method, c.Request.URL.Path, interviewID, err)
+			responseErr := model.NewResponseErrorBadToken()
+			if serverErr, ok := err.(*errors2.ServerError); ok && serverErr.Code == errors2.ServerErrorTokenExpired {
+				responseErr = model.NewResponseErrorTokenExpired()
 			}
-		} else {
-			xl.Debugf("Decode interviewToken fail. inteviewToken: %s", interviewToken)
+			resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+			c.JSON(http.StatusOK, resp)
+			c.Abort()
+			return
+		}
+		user, err := accountService.GetAccountByID(c.Request.Context(), xl, args.UserID)
+		if err != nil {
+			xl.Infof("account %s of interviewToken not found, error %v", args.UserID, err)
+			responseErr := model.NewResponseErrorNoSuchUser()
+			resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+			c.JSON(http.StatusOK, resp)
+			c.Abort()
+			return
 		}
+		c.Set(model.UserContextKey, *user)
+		c.Set(model.UserIDContextKey, args.UserID)
+		c.Set(model.InterviewRoleContextKey, model.InterviewRoleCode(args.Role))
+		c.Set(model.TokenSourceContextKey, model.TokenSourceFromInterviewToken)
+		xl.Debugf("fetch interviewToken success. interviewId: %s, userID: %s", interviewID, args.UserID)
+		return
 	}
 
-	_, exist := c.Get(model.UserIDContextKey)
-	if !exist {
-		// 根据Authorization:Bearer <token>校验。
-		FetchTokenFromHeader(xl, requestID, c)
-	}
+	// 根据Authorization:Bearer <token>校验。
+	FetchTokenFromHeader(xl, requestID, c)
+}
 
+// verifyInterviewToken 校验面试邀请token的签名、有效期，以及是否仍对interviewID对应的面试有效。
+func verifyInterviewToken(ctx context.Context, xl *xlog.Logger, token string, interviewID string) (*model.InterviewTokenArgs, error) {
+	args, err := interviewTokenService.Parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if interviewID == "" || args.InterviewID != interviewID {
+		return nil, &errors2.ServerError{Code: errors2.ServerErrorTokenInvalid, Summary: "interview token issued for another interview"}
+	}
+	interview, err := interviewService.GetInterviewByID(ctx, xl, interviewID)
+	if err != nil {
+		return nil, err
+	}
+	err = interviewTokenService.Check(args, interview)
+	if err != nil {
+		return nil, err
+	}
+	return args, nil
 }
 
 func FetchTokenFromHeader(xl *xlog.Logger, requestID string, c *gin.Context) {
@@ -83,22 +173,46 @@ func FetchTokenFromHeader(xl *xlog.Logger, requestID string, c *gin.Context) {
 		return
 	}
 	token := strings.TrimPrefix(authHeader, "Bearer ")
-	id, err := accountService.GetIDByToken(xl, token)
+	// 只校验签名、有效期与吊销列表，不访问数据库。
+	claims, err := accountService.ParseLoginToken(xl, token)
 
 	if err != nil {
 		xl.Debugf("%s %s: request unauthorized, error %v", c.Request.Method, c.Request.URL.Path, err)
 		responseErr := model.NewResponseErrorBadToken()
+		if serverErr, ok := err.(*errors2.ServerError); ok && serverErr.Code == errors2.ServerErrorTokenExpired {
+			responseErr = model.NewResponseErrorTokenExpired()
+		}
 		resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
 		c.JSON(http.StatusOK, resp)
 		c.Abort()
 		return
 	}
-	user, _ := accountService.GetAccountByID(xl, id)
-	c.Set(model.UserContextKey, *user)
+	// 只根据token中的声明确定当前用户，需要账号详情时再通过model.ContextAccount按需加载。
+	id := claims.UserID
+	c.Set(model.AccountLoaderContextKey, model.AccountLoader(func() (*model.AccountDo, error) {
+		return accountService.GetAccountByID(c.Request.Context(), xl, id)
+	}))
 	c.Set(model.UserIDContextKey, id)
+	c.Set(model.UserPhoneContextKey, claims.Phone)
+	c.Set(model.SessionIDContextKey, claims.SessionID)
 	c.Set(model.TokenSourceContextKey, model.TokenSourceFromHeader)
 }
 
+// FetchDeviceInfo 根据User-Agent与设备ID头部获取当前请求的设备信息。
+func FetchDeviceInfo(xl *xlog.Logger, requestID string, c *gin.Context) model.DeviceInfo {
+	FetchUserAgent(xl, requestID, c)
+	deviceType := string(model.UANoneMobile)
+	if val, ok := c.Get(model.UAContextKey); ok {
+		deviceType = string(val.(model.UAValue))
+	}
+	return model.DeviceInfo{
+		DeviceID:   c.GetHeader(model.DeviceIDHeader),
+		DeviceType: deviceType,
+		UserAgent:  c.GetHeader("User-Agent"),
+		IP:         ClientIP(c),
+	}
+}
+
 func FetchUserAgent(xl *xlog.Logger, requestID string, c *gin.Context) {
 	uaHeader := c.GetHeader("User-Agent")
 	mobileKeywords := []string{"Silk/", "Kindle",
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultTrustedProxies 未配置trusted_proxies时信任的代理。服务只监听本机地址，由本机的反向代理转发请求。
+var DefaultTrustedProxies = []string{"127.0.0.1", "::1"}
+
+var trustedProxies = mustParseProxies(DefaultTrustedProxies)
+
+// ParseTrustedProxies 解析可信代理的IP或CIDR列表。
+func ParseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %v", proxy, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func mustParseProxies(proxies []string) []*net.IPNet {
+	nets, err := ParseTrustedProxies(proxies)
+	if err != nil {
+		panic(err)
+	}
+	return nets
+}
+
+// SetTrustedProxies 设置可信代理，为空时使用DefaultTrustedProxies。
+func SetTrustedProxies(proxies []string) error {
+	if len(proxies) == 0 {
+		proxies = DefaultTrustedProxies
+	}
+	nets, err := ParseTrustedProxies(proxies)
+	if err != nil {
+		return err
+	}
+	trustedProxies = nets
+	return nil
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	for _, ipNet := range trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP 返回请求方的IP，用于限流、IP白名单等安全相关的判断。
+// 只有直连地址是可信代理时才读取X-Forwarded-For，并从右向左跳过可信代理，取第一个不可信的地址，
+// 请求方自行填写在左侧的地址不会被采用；gin的c.ClientIP()取最左侧的地址，可被伪造。
+func ClientIP(c *gin.Context) string {
+	remoteAddr := strings.TrimSpace(c.Request.RemoteAddr)
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		remoteAddr = host
+	}
+	remoteIP := net.ParseIP(remoteAddr)
+	if remoteIP == nil || !isTrustedProxy(remoteIP) {
+		return remoteAddr
+	}
+	forwarded := strings.Split(strings.Join(c.Request.Header.Values("X-Forwarded-For"), ","), ",")
+	clientIP := ""
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if ip == nil {
+			break
+		}
+		clientIP = ip.String()
+		if !isTrustedProxy(ip) {
+			return clientIP
+		}
+	}
+	if clientIP != "" {
+		return clientIP
+	}
+	if ip := net.ParseIP(strings.TrimSpace(c.GetHeader("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return remoteAddr
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestClientIP(t *testing.T) {
+	defer func() { _ = SetTrustedProxies(nil) }()
+	if err := SetTrustedProxies([]string{"127.0.0.1", "10.0.0.0/8"}); err != nil {
+		t.Fatalf("SetTrustedProxies: %v", err)
+	}
+	cases := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		want       string
+	}{
+		{name: "direct", remoteAddr: "1.2.3.4:5678", want: "1.2.3.4"},
+		{name: "untrusted peer forwarded header ignored", remoteAddr: "1.2.3.4:5678", forwarded: []string{"9.9.9.9"}, want: "1.2.3.4"},
+		{name: "trusted proxy", remoteAddr: "127.0.0.1:5678", forwarded: []string{"1.2.3.4"}, want: "1.2.3.4"},
+		{name: "spoofed left entries", remoteAddr: "127.0.0.1:5678", forwarded: []string{"9.9.9.9, 1.2.3.4"}, want: "1.2.3.4"},
+		{name: "proxy chain", remoteAddr: "127.0.0.1:5678", forwarded: []string{"9.9.9.9, 1.2.3.4", "10.1.1.1"}, want: "1.2.3.4"},
+		{name: "invalid entry stops walk", remoteAddr: "127.0.0.1:5678", forwarded: []string{"1.2.3.4, garbage, 10.1.1.1"}, want: "10.1.1.1"},
+		{name: "real ip header", remoteAddr: "127.0.0.1:5678", realIP: "1.2.3.4", want: "1.2.3.4"},
+		{name: "no header", remoteAddr: "127.0.0.1:5678", want: "127.0.0.1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.Request.RemoteAddr = tc.remoteAddr
+			for _, value := range tc.forwarded {
+				c.Request.Header.Add("X-Forwarded-For", value)
+			}
+			if tc.realIP != "" {
+				c.Request.Header.Set("X-Real-IP", tc.realIP)
+			}
+			if got := ClientIP(c); got != tc.want {
+				t.Fatalf("ClientIP = %s, want %s", got, tc.want)
+			}
+		})
+	}
+	if err := SetTrustedProxies([]string{"not-an-ip"}); err == nil {
+		t.Fatalf("SetTrustedProxies should reject invalid entries")
+	}
+}
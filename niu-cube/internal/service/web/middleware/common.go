@@ -4,11 +4,8 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/qiniu/x/xlog"
-	"github.com/solutions/niu-cube/internal/common/utils"
 	"github.com/solutions/niu-cube/internal/protodef/model"
-	"github.com/solutions/niu-cube/internal/service/db/dao"
-	"gopkg.in/mgo.v2"
-	"log"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -34,23 +31,17 @@ func FetchPageInfo(c *gin.Context) {
 	}
 	c.Set(model.PageNumContextKey, pageNum)
 	c.Set(model.PageSizeContextKey, pageSize)
-}
-
-func ActionLogMiddleware() gin.HandlerFunc {
-	// all action divide into 2 kind
-	// before Login 、 after Login
-	return func(c *gin.Context) {
-		path := c.FullPath()
-		method := c.Request.Method
-		actionLog, ok := defaultActionManager.MatchRoute(method, path)
-		c.Set(model.ActionLogContentKey, actionLog)
-		c.Next()
-		val, _ := c.Get(model.ActionLogContentKey)
-		fromContext := val.(*Action)
-		log.Printf("match: %v log: %v", ok, fromContext.With(c))
-		record := fromContext.genRecord()
-		defaultActionManager.Save(record)
+	// 支持游标分页的列表通过model.ContextPageQuery读取分页参数，pageSize不超过model.MaxPageSize。
+	page, err := model.NewPageQuery(pageNum, pageSize, c.Query("cursor"))
+	if err != nil {
+		xl.Infof("FetchPageInfo.cursor invalid, error %v", err)
+		responseErr := model.NewResponseErrorBadRequest()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(xl.ReqId)
+		c.JSON(http.StatusOK, resp)
+		c.Abort()
+		return
 	}
+	c.Set(model.PageQueryContextKey, page)
 }
 
 var methodMsg = map[string]string{
@@ -91,22 +82,9 @@ func NewAction(method string, subject string, msg string) *Action {
 	return &Action{method: method, subject: subject, msg: msg}
 }
 
-type ActionRecord struct {
-	Msg       string    `json:"msg"`
-	UserPhone string    `json:"user_phone"`
-	Time      time.Time `json:"time"`
-	Method    string    `json:"method"`
-	Subject   string    `json:"subject"`
-}
-
-func NewActionRecord(msg string, userPhone string, method string, subject string) *ActionRecord {
-	return &ActionRecord{Msg: msg, UserPhone: userPhone, Time: time.Now(), Method: method, Subject: subject}
-}
-
 type ActionManager struct {
-	Actions    []*Action
-	actionColl *mgo.Collection
-	xl         *xlog.Logger
+	Actions []*Action
+	xl      *xlog.Logger
 }
 
 func NewActionManager(actions map[Action]string) *ActionManager {
@@ -134,21 +112,6 @@ func (am *ActionManager) MatchRoute(method, path string) (*Action, bool) {
 	return NewAction(method, subject, "default"), true
 }
 
-func (am *ActionManager) Save(action *ActionRecord) {
-	if am.actionColl == nil {
-		client, err := mgo.Dial(utils.DefaultConf.Mongo.URI)
-		if err != nil {
-			am.xl.Fatalf("err connect mongo:%s", err)
-		} else {
-			am.actionColl = client.DB(utils.DefaultConf.Mongo.Database).C(dao.ActionCollection)
-		}
-	}
-	err := am.actionColl.Insert(action)
-	if err != nil {
-		xl.Errorf("failed save action:%s", action)
-	}
-}
-
 func (a Action) String() string {
 	methodStr := ""
 	if a.method != "ALL" {
@@ -158,16 +121,23 @@ func (a Action) String() string {
 }
 
 func (a *Action) With(c *gin.Context) Action {
-	val, ok := c.Get(model.UserContextKey)
-	if !ok {
+	if val, ok := c.Get(model.ApiKeyContextKey); ok {
+		apiKey := val.(*model.ApiKeyDo)
+		a.userInfo = fmt.Sprintf("api key %s(%s) from %s", apiKey.Name, apiKey.ID, ClientIP(c))
 		return *a
 	}
-	user, ok := val.(model.AccountDo)
-	if !ok {
+	// 不为记录日志加载账号，手机号优先取已加载的账号，其次取登录token中的手机号。
+	phone := c.GetString(model.UserPhoneContextKey)
+	if val, ok := c.Get(model.UserContextKey); ok {
+		if user, ok := val.(model.AccountDo); ok {
+			phone = user.Phone
+		}
+	}
+	if phone == "" {
 		return *a
 	}
-	a.userInfo = fmt.Sprintf("user %s", user.Phone)
-	a.userPhone = user.Phone
+	a.userInfo = fmt.Sprintf("user %s", phone)
+	a.userPhone = phone
 	return *a
 }
 
@@ -179,11 +149,6 @@ func (a *Action) Msg(msg string) {
 	a.msg = msg
 }
 
-func (a *Action) genRecord() *ActionRecord {
-	r := NewActionRecord(a.String(), a.userPhone, a.method, a.subject)
-	return r
-}
-
 // /v1/leaveInterview/:interviewId -> leaveInterview
 // /v1/ie/:roomId/create 0> ie create
 // parsePath skip first path item && skip param,may return nil
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiniu/x/xlog"
+
+	"github.com/solutions/niu-cube/internal/protodef/model"
+)
+
+func TestFetchPageInfo(t *testing.T) {
+	cursor := &model.PageCursor{Time: time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC), ID: "room-1"}
+	cases := []struct {
+		name     string
+		query    string
+		wantPage model.PageQuery
+		wantCode int
+	}{
+		{
+			name:     "default",
+			wantPage: model.PageQuery{PageNum: 1, PageSize: model.DefaultPageSize},
+		},
+		{
+			name:     "offset",
+			query:    "?pageNum=3&pageSize=20",
+			wantPage: model.PageQuery{PageNum: 3, PageSize: 20},
+		},
+		{
+			name:     "page size capped",
+			query:    "?pageNum=0&pageSize=1000",
+			wantPage: model.PageQuery{PageNum: 1, PageSize: model.MaxPageSize},
+		},
+		{
+			name:     "cursor",
+			query:    "?pageSize=5&cursor=" + cursor.Encode(),
+			wantPage: model.PageQuery{PageNum: 1, PageSize: 5, Cursor: cursor},
+		},
+		{
+			name:     "invalid cursor",
+			query:    "?cursor=not-a-cursor",
+			wantCode: model.ResponseErrorBadRequest,
+		},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, c := range cases {
+		var page model.PageQuery
+		router := gin.New()
+		router.GET("/test", func(ctx *gin.Context) {
+			ctx.Set(model.XLogKey, xlog.New("test"))
+		}, FetchPageInfo, func(ctx *gin.Context) {
+			page = model.ContextPageQuery(ctx)
+			ctx.JSON(http.StatusOK, model.NewSuccessResponse(nil))
+		})
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/test"+c.query, nil))
+		resp := model.Response{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: invalid response %q, error %v", c.name, recorder.Body.String(), err)
+		}
+		if resp.Code != c.wantCode {
+			t.Errorf("%s: code = %d, want %d", c.name, resp.Code, c.wantCode)
+		}
+		if c.wantCode == 0 && !reflect.DeepEqual(page, c.wantPage) {
+			t.Errorf("%s: page = %+v, want %+v", c.name, page, c.wantPage)
+		}
+	}
+}
+
+func TestPageCursorFilter(t *testing.T) {
+	start := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	got := model.PageCursorFilter(
+		model.PageSortField{Name: "status", Desc: true, Value: 2},
+		model.PageSortField{Name: "startTime", Value: start},
+		model.PageSortField{Name: "_id", Value: "interview-1"},
+	)
+	want := map[string]interface{}{"$or": []interface{}{
+		map[string]interface{}{"status": map[string]interface{}{"$lt": 2}},
+		map[string]interface{}{"status": 2, "startTime": map[string]interface{}{"$gt": start}},
+		map[string]interface{}{"status": 2, "startTime": start, "_id": map[string]interface{}{"$gt": "interview-1"}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PageCursorFilter() = %v, want %v", got, want)
+	}
+}
+
+func TestNewPageResult(t *testing.T) {
+	ids := []string{"a", "b", "c"}
+	last := func(i int) *model.PageCursor {
+		return &model.PageCursor{ID: ids[i]}
+	}
+	page := model.PageQuery{PageNum: 1, PageSize: 2}
+	res := model.NewPageResult(page, 3, last)
+	if res.EndPage || res.Count != 2 {
+		t.Fatalf("NewPageResult() = %+v, want 2 records and a next page", res)
+	}
+	next, err := model.DecodePageCursor(res.NextCursor)
+	if err != nil || next.ID != "b" {
+		t.Errorf("next cursor = %+v, %v, want ID b", next, err)
+	}
+	if res := model.NewPageResult(page, 2, last); !res.EndPage || res.NextCursor != "" || res.Count != 2 {
+		t.Errorf("NewPageResult() = %+v, want the last page", res)
+	}
+}
@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+
+	"github.com/solutions/niu-cube/internal/common/utils"
+)
+
+var (
+	// DefaultCORSAllowHeaders 未配置时允许的请求头部。
+	DefaultCORSAllowHeaders = []string{"Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "Accept", "Origin", "Cache-Control", "X-Requested-With", "X-Device-Id", "X-Api-Key", "X-Reqid"}
+	// DefaultCORSAllowMethods 未配置时允许的请求方法。
+	DefaultCORSAllowMethods = []string{"POST", "OPTIONS", "GET", "PUT", "DELETE", "HEAD"}
+	// DefaultCORSExposeHeaders 未配置时允许浏览器读取的响应头部。
+	DefaultCORSExposeHeaders = []string{"X-Reqid", "Retry-After"}
+)
+
+// DefaultCORSMaxAge 未配置时预检请求结果的缓存时间。
+const DefaultCORSMaxAge = 12 * time.Hour
+
+// corsGroup 路径前缀及其跨域策略。
+type corsGroup struct {
+	prefix  string
+	handler gin.HandlerFunc
+}
+
+// NewCORS 按配置返回跨域访问中间件，需要通过router.Use全局使用，预检请求不匹配任何路由，只有全局中间件能处理。
+// 来源不在允许列表中的跨域请求返回403，配置不合法时返回错误。
+func NewCORS(conf *utils.CORSConfig) (gin.HandlerFunc, error) {
+	if conf == nil {
+		conf = &utils.CORSConfig{}
+	}
+	defaultPolicy := conf.CORSPolicy
+	defaultHandler, err := newCORSHandler(defaultPolicy)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]corsGroup, 0, len(conf.Groups))
+	for prefix, policy := range conf.Groups {
+		if policy == nil {
+			continue
+		}
+		handler, err := newCORSHandler(mergeCORSPolicy(defaultPolicy, *policy))
+		if err != nil {
+			return nil, fmt.Errorf("cors group %s: %v", prefix, err)
+		}
+		groups = append(groups, corsGroup{prefix: "/" + strings.Trim(prefix, "/"), handler: handler})
+	}
+	// 前缀最长的策略优先。
+	sort.Slice(groups, func(i, j int) bool {
+		return len(groups[i].prefix) > len(groups[j].prefix)
+	})
+	return func(c *gin.Context) {
+		requestPath := c.Request.URL.Path
+		for _, group := range groups {
+			if requestPath == group.prefix || strings.HasPrefix(requestPath, group.prefix+"/") {
+				group.handler(c)
+				return
+			}
+		}
+		defaultHandler(c)
+	}, nil
+}
+
+// mergeCORSPolicy 返回以policy覆盖defaultPolicy的策略，policy中未配置的字段使用defaultPolicy的值。
+func mergeCORSPolicy(defaultPolicy, policy utils.CORSPolicy) utils.CORSPolicy {
+	if len(policy.AllowOrigins) == 0 {
+		policy.AllowOrigins = defaultPolicy.AllowOrigins
+	}
+	if len(policy.AllowHeaders) == 0 {
+		policy.AllowHeaders = defaultPolicy.AllowHeaders
+	}
+	if len(policy.AllowMethods) == 0 {
+		policy.AllowMethods = defaultPolicy.AllowMethods
+	}
+	if len(policy.ExposeHeaders) == 0 {
+		policy.ExposeHeaders = defaultPolicy.ExposeHeaders
+	}
+	if policy.MaxAgeSecond == 0 {
+		policy.MaxAgeSecond = defaultPolicy.MaxAgeSecond
+	}
+	return policy
+}
+
+func newCORSHandler(policy utils.CORSPolicy) (gin.HandlerFunc, error) {
+	config := cors.Config{
+		AllowHeaders:     policy.AllowHeaders,
+		AllowMethods:     policy.AllowMethods,
+		ExposeHeaders:    policy.ExposeHeaders,
+		MaxAge:           time.Duration(policy.MaxAgeSecond) * time.Second,
+		AllowCredentials: policy.AllowCredentials,
+	}
+	if len(config.AllowHeaders) == 0 {
+		config.AllowHeaders = DefaultCORSAllowHeaders
+	}
+	if len(config.AllowMethods) == 0 {
+		config.AllowMethods = DefaultCORSAllowMethods
+	}
+	if len(config.ExposeHeaders) == 0 {
+		config.ExposeHeaders = DefaultCORSExposeHeaders
+	}
+	if config.MaxAge <= 0 {
+		config.MaxAge = DefaultCORSMaxAge
+	}
+	origins := policy.AllowOrigins
+	if len(origins) == 0 {
+		origins = []string{"*"}
+	}
+	patterns := make([]string, 0)
+	for _, origin := range origins {
+		switch {
+		case origin == "*":
+			if policy.AllowCredentials {
+				return nil, fmt.Errorf("allow_credentials can not be used with origin *")
+			}
+			config.AllowAllOrigins = true
+		case strings.Contains(origin, "*"):
+			if _, err := path.Match(origin, ""); err != nil {
+				return nil, fmt.Errorf("invalid origin pattern %q: %v", origin, err)
+			}
+			patterns = append(patterns, origin)
+		default:
+			config.AllowOrigins = append(config.AllowOrigins, origin)
+		}
+	}
+	if config.AllowAllOrigins {
+		config.AllowOrigins = nil
+	} else if len(patterns) > 0 {
+		// 通配符只匹配来源中不含"/"的部分，如https://*.example.com不匹配https://evil.com/.example.com。
+		config.AllowOriginFunc = func(origin string) bool {
+			for _, pattern := range patterns {
+				if matched, _ := path.Match(pattern, origin); matched {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return cors.New(config), nil
+}
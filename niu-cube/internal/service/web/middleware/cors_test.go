@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/solutions/niu-cube/internal/common/utils"
+)
+
+func newCORSRouter(t *testing.T, conf *utils.CORSConfig) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	handler, err := NewCORS(conf)
+	if err != nil {
+		t.Fatalf("NewCORS() = %v", err)
+	}
+	router := gin.New()
+	router.Use(handler)
+	ok := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	router.GET("/v1/appConfig", ok)
+	router.GET("/v1/admin/apiKeys", ok)
+	router.GET("/v1/administrators", ok)
+	return router
+}
+
+func corsRequest(router *gin.Engine, method, target, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Origin", origin)
+	if method == http.MethodOptions {
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestCORSDefault(t *testing.T) {
+	router := newCORSRouter(t, nil)
+	resp := corsRequest(router, http.MethodOptions, "/v1/appConfig", "https://any.example.com")
+	if resp.Code != http.StatusNoContent {
+		t.Fatalf("preflight status = %d", resp.Code)
+	}
+	header := resp.Header()
+	if header.Get("Access-Control-Allow-Origin") != "*" || header.Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("allow origin %q, credentials %q", header.Get("Access-Control-Allow-Origin"), header.Get("Access-Control-Allow-Credentials"))
+	}
+	if header.Get("Access-Control-Allow-Headers") == "" || header.Get("Access-Control-Max-Age") != "43200" {
+		t.Errorf("allow headers %q, max age %q", header.Get("Access-Control-Allow-Headers"), header.Get("Access-Control-Max-Age"))
+	}
+}
+
+func TestCORSGroups(t *testing.T) {
+	router := newCORSRouter(t, &utils.CORSConfig{
+		CORSPolicy: utils.CORSPolicy{AllowOrigins: []string{"*"}},
+		Groups: map[string]*utils.CORSPolicy{
+			"/v1/admin/": {
+				AllowOrigins:     []string{"https://console.example.com", "https://*.console.example.com"},
+				AllowCredentials: true,
+				MaxAgeSecond:     600,
+			},
+		},
+	})
+	cases := []struct {
+		name        string
+		method      string
+		target      string
+		origin      string
+		wantStatus  int
+		wantOrigin  string
+		credentials bool
+	}{
+		{name: "public", method: http.MethodGet, target: "/v1/appConfig", origin: "https://other.com", wantStatus: http.StatusOK, wantOrigin: "*"},
+		{name: "admin console", method: http.MethodGet, target: "/v1/admin/apiKeys", origin: "https://console.example.com", wantStatus: http.StatusOK, wantOrigin: "https://console.example.com", credentials: true},
+		{name: "admin preflight", method: http.MethodOptions, target: "/v1/admin/apiKeys", origin: "https://console.example.com", wantStatus: http.StatusNoContent, wantOrigin: "https://console.example.com", credentials: true},
+		{name: "admin pattern", method: http.MethodGet, target: "/v1/admin/apiKeys", origin: "https://staging.console.example.com", wantStatus: http.StatusOK, wantOrigin: "https://staging.console.example.com", credentials: true},
+		{name: "admin other origin", method: http.MethodOptions, target: "/v1/admin/apiKeys", origin: "https://other.com", wantStatus: http.StatusForbidden},
+		{name: "pattern crosses path", method: http.MethodGet, target: "/v1/admin/apiKeys", origin: "https://evil.com/.console.example.com", wantStatus: http.StatusForbidden},
+		// 前缀按路径段匹配，/v1/administrators不属于/v1/admin。
+		{name: "prefix segment", method: http.MethodGet, target: "/v1/administrators", origin: "https://other.com", wantStatus: http.StatusOK, wantOrigin: "*"},
+	}
+	for _, c := range cases {
+		resp := corsRequest(router, c.method, c.target, c.origin)
+		if resp.Code != c.wantStatus {
+			t.Errorf("%s: status = %d, want %d", c.name, resp.Code, c.wantStatus)
+			continue
+		}
+		if origin := resp.Header().Get("Access-Control-Allow-Origin"); origin != c.wantOrigin {
+			t.Errorf("%s: allow origin = %q, want %q", c.name, origin, c.wantOrigin)
+		}
+		if credentials := resp.Header().Get("Access-Control-Allow-Credentials") == "true"; credentials != c.credentials {
+			t.Errorf("%s: allow credentials = %v, want %v", c.name, credentials, c.credentials)
+		}
+	}
+	resp := corsRequest(router, http.MethodOptions, "/v1/admin/apiKeys", "https://console.example.com")
+	if resp.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("admin max age = %q", resp.Header().Get("Access-Control-Max-Age"))
+	}
+}
+
+func TestCORSInvalidConfig(t *testing.T) {
+	confs := []*utils.CORSConfig{
+		{CORSPolicy: utils.CORSPolicy{AllowOrigins: []string{"*"}, AllowCredentials: true}},
+		{CORSPolicy: utils.CORSPolicy{AllowOrigins: []string{"console.example.com"}}},
+		{CORSPolicy: utils.CORSPolicy{AllowOrigins: []string{"https://*[.example.com"}}},
+		// 组策略未配置来源时使用默认的"*"，不能允许携带凭证。
+		{Groups: map[string]*utils.CORSPolicy{"/v1/admin": {AllowCredentials: true}}},
+	}
+	for _, conf := range confs {
+		if _, err := NewCORS(conf); err == nil {
+			t.Errorf("NewCORS(%+v) succeeded, want error", conf)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+	"github.com/tidwall/gjson"
+)
+
+// localizeWriter 在写入错误响应时把错误信息翻译为请求使用的语言，gin的JSON响应体一次写入。
+type localizeWriter struct {
+	gin.ResponseWriter
+	c       *gin.Context
+	written bool
+}
+
+func (w *localizeWriter) Write(b []byte) (int, error) {
+	if !w.written {
+		w.written = true
+		if body, ok := w.localize(b); ok {
+			if _, err := w.ResponseWriter.Write(body); err != nil {
+				return 0, err
+			}
+			return len(b), nil
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *localizeWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// localize 替换响应体中code非0的message，不改变其他字段及顺序，不需要翻译时返回false。
+func (w *localizeWriter) localize(body []byte) ([]byte, bool) {
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		return nil, false
+	}
+	code := gjson.GetBytes(body, "code")
+	message := gjson.GetBytes(body, "message")
+	if code.Type != gjson.Number || code.Int() == 0 || message.Type != gjson.String || message.Index == 0 {
+		return nil, false
+	}
+	localized := model.LocalizeResponseMessage(model.ContextLanguage(w.c), int(code.Int()), message.String())
+	if localized == message.String() {
+		return nil, false
+	}
+	raw, err := json.Marshal(localized)
+	if err != nil {
+		return nil, false
+	}
+	res := make([]byte, 0, len(body)+len(raw)-len(message.Raw))
+	res = append(res, body[:message.Index]...)
+	res = append(res, raw...)
+	res = append(res, body[message.Index+len(message.Raw):]...)
+	return res, true
+}
+
+// Localize 把错误响应中错误码的标准错误信息翻译为model.ContextLanguage确定的语言，
+// 处理函数通过WithErrorMessage等给出的具体错误信息不翻译。
+func Localize(c *gin.Context) {
+	c.Writer = &localizeWriter{ResponseWriter: c.Writer, c: c}
+	c.Next()
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/solutions/niu-cube/internal/common/i18n"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+)
+
+func localizeRequest(t *testing.T, acceptLanguage string, setup func(c *gin.Context), handler gin.HandlerFunc) string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/test", func(c *gin.Context) {
+		if setup != nil {
+			setup(c)
+		}
+	}, Localize, handler)
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	if acceptLanguage != "" {
+		req.Header.Set("Accept-Language", acceptLanguage)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder.Body.String()
+}
+
+func sendError(responseErr *model.ResponseError) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		model.NewFailResponse(*responseErr).WithRequestID("reqid").Send(c)
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	cases := []struct {
+		name           string
+		acceptLanguage string
+		setup          func(c *gin.Context)
+		handler        gin.HandlerFunc
+		want           string
+	}{
+		{
+			name:    "default language",
+			handler: sendError(model.NewResponseErrorPermissionDenied()),
+			want:    `{"code":403001,"message":"permission denied","data":null,"requestId":"reqid"}`,
+		},
+		{
+			name:           "accept language",
+			acceptLanguage: "zh-CN,zh;q=0.9,en;q=0.8",
+			handler:        sendError(model.NewResponseErrorPermissionDenied()),
+			want:           `{"code":403001,"message":"权限不足","data":null,"requestId":"reqid"}`,
+		},
+		{
+			name:           "account locale",
+			acceptLanguage: "zh-CN",
+			setup:          withAccount(model.AccountDo{ID: "user", Locale: i18n.English}),
+			handler: func(c *gin.Context) {
+				resp := &model.Response{Code: model.ResponseErrorExamTimeNotMatch, Message: "考试时间不匹配", Data: map[string]bool{"result": false}}
+				c.JSON(http.StatusOK, resp)
+			},
+			want: `{"code":401011,"message":"the exam is not in progress","data":{"result":false},"requestId":""}`,
+		},
+		{
+			name:           "detailed message",
+			acceptLanguage: "en",
+			handler:        sendError(model.NewResponseErrorValidation(errors.New("title: 不能为空."))),
+			want:           `{"code":401005,"message":"title: 不能为空.","data":null,"requestId":"reqid"}`,
+		},
+		{
+			name:           "success",
+			acceptLanguage: "zh-CN",
+			handler: func(c *gin.Context) {
+				c.JSON(http.StatusOK, model.NewSuccessResponse(nil))
+			},
+			want: `{"code":0,"message":"success","data":null,"requestId":""}`,
+		},
+		{
+			name:           "not json",
+			acceptLanguage: "zh-CN",
+			handler: func(c *gin.Context) {
+				c.String(http.StatusOK, `{"code":403001,"message":"permission denied"}`)
+			},
+			want: `{"code":403001,"message":"permission denied"}`,
+		},
+	}
+	for _, c := range cases {
+		if got := localizeRequest(t, c.acceptLanguage, c.setup, c.handler); got != c.want {
+			t.Errorf("%s: body = %s, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestLocalizeDefaultLanguage(t *testing.T) {
+	if err := i18n.SetDefault(i18n.Chinese); err != nil {
+		t.Fatal(err)
+	}
+	defer i18n.SetDefault("")
+	got := localizeRequest(t, "fr", nil, sendError(model.NewResponseErrorNotLoggedIn()))
+	if want := `{"code":401001,"message":"未登录","data":null,"requestId":"reqid"}`; got != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solutions/niu-cube/internal/common/metrics"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+	"github.com/tidwall/gjson"
+)
+
+// responseHeadSize 为取得业务错误码保留的响应体前缀长度，model.Response的code字段位于最前。
+const responseHeadSize = 64
+
+// responseCodeWriter 保留响应体的前缀，用于解析业务错误码。
+type responseCodeWriter struct {
+	gin.ResponseWriter
+	head []byte
+}
+
+func (w *responseCodeWriter) keep(b []byte) {
+	if n := responseHeadSize - len(w.head); n > 0 {
+		if len(b) < n {
+			n = len(b)
+		}
+		w.head = append(w.head, b[:n]...)
+	}
+}
+
+func (w *responseCodeWriter) Write(b []byte) (int, error) {
+	w.keep(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseCodeWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// responseCode 返回响应体中的业务错误码，响应不是model.Response时返回none。
+func (w *responseCodeWriter) responseCode() string {
+	code := gjson.GetBytes(w.head, "code")
+	if code.Type != gjson.Number {
+		return "none"
+	}
+	return code.Raw
+}
+
+// Metrics 记录每个请求的耗时与业务错误码，路由使用c.FullPath()的模板，未匹配的路由记为unmatched。
+// 房间类型取自处理函数设置的RoomTypeContextKey或查询参数type。
+func Metrics(c *gin.Context) {
+	start := time.Now()
+	writer := &responseCodeWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	roomType := c.GetString(model.RoomTypeContextKey)
+	if roomType == "" {
+		roomType = c.Query("type")
+	}
+	roomType = metrics.RoomTypeLabel(roomType)
+	scene := metrics.SceneOfRoute(route)
+	status := strconv.Itoa(c.Writer.Status())
+	metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route, status, scene, roomType).Observe(time.Since(start).Seconds())
+	metrics.HTTPResponses.WithLabelValues(route, writer.responseCode(), scene, roomType).Inc()
+}
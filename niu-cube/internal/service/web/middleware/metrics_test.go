@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/solutions/niu-cube/internal/common/metrics"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+)
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Metrics)
+	router.GET("/v1/ktv/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, model.NewSuccessResponse(map[string]string{"a": "b"}))
+	})
+	router.POST("/v1/base/test", func(c *gin.Context) {
+		c.Set(model.RoomTypeContextKey, "movie")
+		resp := model.NewFailResponse(*model.NewResponseErrorNoSuchRoom())
+		c.JSON(http.StatusOK, resp)
+	})
+	router.GET("/v1/exam/text", func(c *gin.Context) {
+		c.String(http.StatusOK, "plain")
+	})
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/v1/ktv/test?type=ktv", nil),
+		httptest.NewRequest(http.MethodPost, "/v1/base/test", nil),
+		httptest.NewRequest(http.MethodGet, "/v1/exam/text?type=unknownType", nil),
+		httptest.NewRequest(http.MethodGet, "/v1/not/exist", nil),
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	cases := []struct {
+		labels []string
+	}{
+		{[]string{"/v1/ktv/test", "0", "ktv", "ktv"}},
+		{[]string{"/v1/base/test", "404004", "base", "movie"}},
+		{[]string{"/v1/exam/text", "none", "exam", metrics.RoomTypeOther}},
+		{[]string{"unmatched", "none", metrics.SceneOther, ""}},
+	}
+	for _, tc := range cases {
+		if got := testutil.ToFloat64(metrics.HTTPResponses.WithLabelValues(tc.labels...)); got != 1 {
+			t.Errorf("responses %v = %v, want 1", tc.labels, got)
+		}
+	}
+}
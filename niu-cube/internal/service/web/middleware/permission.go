@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/utils"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+)
+
+// RequirePermission 要求当前账号同时具备所有给定权限，需放在Authenticate之后。
+func RequirePermission(permissions ...model.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+		requestID := xl.ReqId
+		// 通过API key访问时只检查key被授予的权限。
+		var apiKey *model.ApiKeyDo
+		var user model.AccountDo
+		if val, ok := c.Get(model.ApiKeyContextKey); ok {
+			apiKey = val.(*model.ApiKeyDo)
+		} else {
+			account, ok, err := model.ContextAccount(c)
+			if !ok || err != nil {
+				xl.Infof("%s %s: no authenticated user for permission check, error %v", c.Request.Method, c.Request.URL.Path, err)
+				responseErr := model.NewResponseErrorNotLoggedIn()
+				if err != nil {
+					responseErr = model.NewResponseErrorNoSuchUser()
+				}
+				resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+				c.JSON(http.StatusOK, resp)
+				c.Abort()
+				return
+			}
+			user = account
+		}
+		for _, permission := range permissions {
+			if apiKey != nil && !apiKey.HasPermission(permission) {
+				xl.Infof("api key %s with permissions %v lacks permission %s", apiKey.ID, apiKey.Permissions, permission)
+				responseErr := model.NewResponseErrorPermissionDenied()
+				resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+				c.JSON(http.StatusOK, resp)
+				c.Abort()
+				return
+			}
+			if apiKey == nil && !HasPermission(user, permission) {
+				xl.Infof("user %s with roles %v lacks permission %s", user.ID, user.Roles, permission)
+				responseErr := model.NewResponseErrorPermissionDenied()
+				resp := model.NewFailResponse(*responseErr).WithRequestID(requestID)
+				c.JSON(http.StatusOK, resp)
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// HasPermission 判断账号是否具备某项权限，配置在admin_phones中的手机号视为admin。
+func HasPermission(user model.AccountDo, permission model.Permission) bool {
+	if isAdminPhone(user.Phone) {
+		return true
+	}
+	return user.HasPermission(permission)
+}
+
+func isAdminPhone(phone string) bool {
+	if phone == "" {
+		return false
+	}
+	for _, adminPhone := range utils.DefaultConf.AdminPhones {
+		if adminPhone == phone {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/utils"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+)
+
+// runMiddleware 依次执行setup与被测中间件，返回中间件之后的处理函数是否执行以及响应体。
+func runMiddleware(t *testing.T, setup func(c *gin.Context), handler gin.HandlerFunc) (bool, model.Response) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	router := gin.New()
+	passed := false
+	router.GET("/test", func(c *gin.Context) {
+		c.Set(model.XLogKey, xlog.New("test"))
+		setup(c)
+	}, handler, func(c *gin.Context) {
+		passed = true
+		c.JSON(http.StatusOK, model.NewSuccessResponse(nil))
+	})
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/test", nil))
+	resp := model.Response{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response %q, error %v", recorder.Body.String(), err)
+	}
+	return passed, resp
+}
+
+func withAccount(account model.AccountDo) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		c.Set(model.UserContextKey, account)
+		c.Set(model.UserIDContextKey, account.ID)
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	utils.DefaultConf.AdminPhones = []string{"13800000000"}
+	defer func() { utils.DefaultConf.AdminPhones = nil }()
+
+	cases := []struct {
+		name        string
+		setup       func(c *gin.Context)
+		permissions []model.Permission
+		wantPass    bool
+		wantCode    int
+	}{
+		{
+			name:        "not logged in",
+			setup:       func(c *gin.Context) {},
+			permissions: []model.Permission{model.PermissionExamReview},
+			wantCode:    model.ResponseErrorNotLoggedIn,
+		},
+		{
+			name:        "plain user",
+			setup:       withAccount(model.AccountDo{ID: "u1"}),
+			permissions: []model.Permission{model.PermissionExamReview},
+			wantCode:    model.ResponseErrorPermissionDenied,
+		},
+		{
+			name:        "role grants permission",
+			setup:       withAccount(model.AccountDo{ID: "u2", Roles: []model.Role{model.RoleExaminer}}),
+			permissions: []model.Permission{model.PermissionExamReview},
+			wantPass:    true,
+		},
+		{
+			name:        "role lacks one of permissions",
+			setup:       withAccount(model.AccountDo{ID: "u3", Roles: []model.Role{model.RoleExaminer}}),
+			permissions: []model.Permission{model.PermissionExamReview, model.PermissionExamManage},
+			wantCode:    model.ResponseErrorPermissionDenied,
+		},
+		{
+			name:        "admin role",
+			setup:       withAccount(model.AccountDo{ID: "u4", Roles: []model.Role{model.RoleAdmin}}),
+			permissions: []model.Permission{model.PermissionRoleManage, model.PermissionSystemMaintain},
+			wantPass:    true,
+		},
+		{
+			name:        "admin phone",
+			setup:       withAccount(model.AccountDo{ID: "u5", Phone: "13800000000"}),
+			permissions: []model.Permission{model.PermissionRoleManage},
+			wantPass:    true,
+		},
+		{
+			name: "account loaded lazily",
+			setup: func(c *gin.Context) {
+				c.Set(model.UserIDContextKey, "u6")
+				c.Set(model.AccountLoaderContextKey, model.AccountLoader(func() (*model.AccountDo, error) {
+					return &model.AccountDo{ID: "u6", Roles: []model.Role{model.RoleContentEditor}}, nil
+				}))
+			},
+			permissions: []model.Permission{model.PermissionSongManage},
+			wantPass:    true,
+		},
+		{
+			name: "account of token deleted",
+			setup: func(c *gin.Context) {
+				c.Set(model.UserIDContextKey, "u7")
+				c.Set(model.AccountLoaderContextKey, model.AccountLoader(func() (*model.AccountDo, error) {
+					return nil, errors.New("not found")
+				}))
+			},
+			permissions: []model.Permission{model.PermissionSongManage},
+			wantCode:    model.ResponseErrorNoSuchUser,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			passed, resp := runMiddleware(t, tc.setup, RequirePermission(tc.permissions...))
+			if passed != tc.wantPass {
+				t.Fatalf("passed = %v, want %v, response %+v", passed, tc.wantPass, resp)
+			}
+			if !tc.wantPass && resp.Code != tc.wantCode {
+				t.Fatalf("code = %d, want %d", resp.Code, tc.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/utils"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+)
+
+const (
+	// RateLimitKeyUser 按用户ID或API key限流，未登录时按客户端IP。
+	RateLimitKeyUser = "user"
+	// RateLimitKeyIP 按客户端IP限流。
+	RateLimitKeyIP = "ip"
+
+	// RateLimitGroupHeartbeat 房间、检修与面试的心跳。
+	RateLimitGroupHeartbeat = "heartbeat"
+	// RateLimitGroupEventLog 考试作弊事件上报。
+	RateLimitGroupEventLog = "event_log"
+	// RateLimitGroupSongOperation 点歌与取消点歌。
+	RateLimitGroupSongOperation = "song_operation"
+	// RateLimitGroupSmsCode 发送短信验证码。发送数量另有按手机号与IP的每日限额，这里只防止短时间内的大量请求。
+	RateLimitGroupSmsCode = "sms_code"
+
+	// rateLimitIdleTimeout 令牌桶超过该时长未使用时回收。
+	rateLimitIdleTimeout = 10 * time.Minute
+)
+
+// DefaultRateLimitRules 未配置时各路由组使用的限流规则。
+var DefaultRateLimitRules = map[string]utils.RateLimitRule{
+	RateLimitGroupHeartbeat:     {RatePerSecond: 1, Burst: 5, Key: RateLimitKeyUser},
+	RateLimitGroupEventLog:      {RatePerSecond: 5, Burst: 20, Key: RateLimitKeyUser},
+	RateLimitGroupSongOperation: {RatePerSecond: 2, Burst: 10, Key: RateLimitKeyUser},
+	RateLimitGroupSmsCode:       {RatePerSecond: 0.2, Burst: 5, Key: RateLimitKeyIP},
+}
+
+// RateLimitStore 保存令牌桶状态。默认使用进程内存，多实例部署时可替换为共享存储。
+type RateLimitStore interface {
+	// Take 从key对应的令牌桶取一个令牌，返回是否允许，拒绝时同时返回需要等待的时长。
+	Take(key string, rule utils.RateLimitRule, now time.Time) (bool, time.Duration)
+}
+
+type tokenBucket struct {
+	tokens   float64
+	lastTime time.Time
+}
+
+// MemoryRateLimitStore 进程内的令牌桶存储，定期回收长时间未使用的令牌桶。
+type MemoryRateLimitStore struct {
+	lock      sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*tokenBucket)}
+}
+
+func (s *MemoryRateLimitStore) Take(key string, rule utils.RateLimitRule, now time.Time) (bool, time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if now.Sub(s.lastSweep) > rateLimitIdleTimeout {
+		for k, bucket := range s.buckets {
+			if now.Sub(bucket.lastTime) > rateLimitIdleTimeout {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	burst := float64(rule.Burst)
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, lastTime: now}
+		s.buckets[key] = bucket
+	} else if elapsed := now.Sub(bucket.lastTime).Seconds(); elapsed > 0 {
+		bucket.tokens = math.Min(burst, bucket.tokens+elapsed*rule.RatePerSecond)
+		bucket.lastTime = now
+	}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / rule.RatePerSecond * float64(time.Second))
+	return false, wait
+}
+
+// RateLimiter 按路由组的规则对请求限流。
+type RateLimiter struct {
+	store RateLimitStore
+	rules map[string]utils.RateLimitRule
+}
+
+var rateLimiter = mustNewRateLimiter(nil, NewMemoryRateLimitStore())
+
+// NewRateLimiter 合并配置与默认规则创建限流器，conf为空时使用默认规则。
+func NewRateLimiter(conf *utils.RateLimitConfig, store RateLimitStore) (*RateLimiter, error) {
+	rules := make(map[string]utils.RateLimitRule, len(DefaultRateLimitRules))
+	if conf != nil && conf.Disabled {
+		return &RateLimiter{store: store, rules: rules}, nil
+	}
+	for group, rule := range DefaultRateLimitRules {
+		rules[group] = rule
+	}
+	if conf != nil {
+		for group, rule := range conf.Groups {
+			if rule == nil {
+				continue
+			}
+			if rule.Key == "" {
+				rule.Key = RateLimitKeyUser
+			}
+			if rule.Key != RateLimitKeyUser && rule.Key != RateLimitKeyIP {
+				return nil, fmt.Errorf("invalid rate limit key %q of group %s", rule.Key, group)
+			}
+			if rule.Burst <= 0 {
+				rule.Burst = int(math.Ceil(rule.RatePerSecond))
+			}
+			rules[group] = *rule
+		}
+	}
+	return &RateLimiter{store: store, rules: rules}, nil
+}
+
+func mustNewRateLimiter(conf *utils.RateLimitConfig, store RateLimitStore) *RateLimiter {
+	limiter, err := NewRateLimiter(conf, store)
+	if err != nil {
+		panic(err)
+	}
+	return limiter
+}
+
+// SetRateLimit 设置限流配置与存储，store为空时使用进程内存。
+func SetRateLimit(conf *utils.RateLimitConfig, store RateLimitStore) error {
+	if store == nil {
+		store = NewMemoryRateLimitStore()
+	}
+	limiter, err := NewRateLimiter(conf, store)
+	if err != nil {
+		return err
+	}
+	rateLimiter = limiter
+	return nil
+}
+
+// RateLimit 按路由组的规则限流，按用户限流时需放在Authenticate之后。
+// 被拒绝的请求返回ResponseErrorRateLimited与Retry-After头部。
+func RateLimit(group string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limiter := rateLimiter
+		rule, ok := limiter.rules[group]
+		if !ok || rule.RatePerSecond <= 0 {
+			c.Next()
+			return
+		}
+		key := group + ":" + rateLimitKey(c, rule.Key)
+		allowed, wait := limiter.store.Take(key, rule, time.Now())
+		if allowed {
+			c.Next()
+			return
+		}
+		xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+		xl.Infof("%s %s: rate limited by %s, retry after %v", c.Request.Method, c.Request.URL.Path, key, wait)
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		responseErr := model.NewResponseErrorRateLimited()
+		resp := model.NewFailResponse(*responseErr).WithRequestID(xl.ReqId)
+		c.JSON(http.StatusTooManyRequests, resp)
+		c.Abort()
+	}
+}
+
+// rateLimitKey 返回请求在限流维度上的标识。
+func rateLimitKey(c *gin.Context, keyType string) string {
+	if keyType == RateLimitKeyUser {
+		if val, ok := c.Get(model.ApiKeyContextKey); ok {
+			return "apikey:" + val.(*model.ApiKeyDo).ID
+		}
+		if userID := c.GetString(model.UserIDContextKey); userID != "" {
+			return "user:" + userID
+		}
+	}
+	return "ip:" + ClientIP(c)
+}
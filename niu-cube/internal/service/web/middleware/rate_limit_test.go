@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/utils"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	rule := utils.RateLimitRule{RatePerSecond: 2, Burst: 3}
+	now := time.Unix(1600000000, 0)
+	for i := 0; i < 3; i++ {
+		if ok, _ := store.Take("a", rule, now); !ok {
+			t.Fatalf("request %d within burst rejected", i)
+		}
+	}
+	ok, wait := store.Take("a", rule, now)
+	if ok {
+		t.Fatalf("request over burst allowed")
+	}
+	if wait != 500*time.Millisecond {
+		t.Fatalf("wait = %v, want 500ms", wait)
+	}
+	if ok, _ := store.Take("b", rule, now); !ok {
+		t.Fatalf("other key rejected")
+	}
+	if ok, _ := store.Take("a", rule, now.Add(500*time.Millisecond)); !ok {
+		t.Fatalf("request after refill rejected")
+	}
+	if ok, _ := store.Take("a", rule, now.Add(500*time.Millisecond)); ok {
+		t.Fatalf("second request after single refill allowed")
+	}
+	// 长时间未使用后令牌桶回满，但不超过容量。
+	later := now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if ok, _ := store.Take("a", rule, later); !ok {
+			t.Fatalf("request %d after idle rejected", i)
+		}
+	}
+	if ok, _ := store.Take("a", rule, later); ok {
+		t.Fatalf("bucket refilled over burst")
+	}
+	if len(store.buckets) != 1 {
+		t.Fatalf("idle buckets not swept, %d left", len(store.buckets))
+	}
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	limiter, err := NewRateLimiter(nil, NewMemoryRateLimitStore())
+	if err != nil {
+		t.Fatalf("NewRateLimiter: %v", err)
+	}
+	if limiter.rules[RateLimitGroupHeartbeat] != DefaultRateLimitRules[RateLimitGroupHeartbeat] {
+		t.Fatalf("default rule not used")
+	}
+	limiter, err = NewRateLimiter(&utils.RateLimitConfig{Groups: map[string]*utils.RateLimitRule{
+		RateLimitGroupHeartbeat: {RatePerSecond: 2.5},
+	}}, NewMemoryRateLimitStore())
+	if err != nil {
+		t.Fatalf("NewRateLimiter: %v", err)
+	}
+	if rule := limiter.rules[RateLimitGroupHeartbeat]; rule.Burst != 3 || rule.Key != RateLimitKeyUser {
+		t.Fatalf("rule = %+v", rule)
+	}
+	if _, ok := limiter.rules[RateLimitGroupSmsCode]; !ok {
+		t.Fatalf("unconfigured group lost its default rule")
+	}
+	limiter, _ = NewRateLimiter(&utils.RateLimitConfig{Disabled: true}, NewMemoryRateLimitStore())
+	if len(limiter.rules) != 0 {
+		t.Fatalf("disabled limiter has rules %v", limiter.rules)
+	}
+	_, err = NewRateLimiter(&utils.RateLimitConfig{Groups: map[string]*utils.RateLimitRule{
+		RateLimitGroupHeartbeat: {RatePerSecond: 1, Key: "phone"},
+	}}, NewMemoryRateLimitStore())
+	if err == nil {
+		t.Fatalf("invalid key accepted")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	origin := rateLimiter
+	defer func() { rateLimiter = origin }()
+	err := SetRateLimit(&utils.RateLimitConfig{Groups: map[string]*utils.RateLimitRule{
+		"test":    {RatePerSecond: 0.001, Burst: 1},
+		"test_ip": {RatePerSecond: 0.001, Burst: 1, Key: RateLimitKeyIP},
+	}}, nil)
+	if err != nil {
+		t.Fatalf("SetRateLimit: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(model.XLogKey, xlog.New("test"))
+		if userID := c.GetHeader("X-Test-User"); userID != "" {
+			c.Set(model.UserIDContextKey, userID)
+		}
+	})
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, model.NewSuccessResponse(nil)) }
+	router.GET("/user", RateLimit("test"), ok)
+	router.GET("/ip", RateLimit("test_ip"), ok)
+	router.GET("/unlimited", RateLimit("unknown"), ok)
+	serve := func(path, userID, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		if userID != "" {
+			req.Header.Set("X-Test-User", userID)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	if rec := serve("/user", "u1", "192.0.2.1:1"); rec.Code != http.StatusOK {
+		t.Fatalf("first request: status %d", rec.Code)
+	}
+	rec := serve("/user", "u1", "192.0.2.2:1")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request of same user: status %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "1000" {
+		t.Fatalf("Retry-After = %q", rec.Header().Get("Retry-After"))
+	}
+	if rec := serve("/user", "u2", "192.0.2.1:1"); rec.Code != http.StatusOK {
+		t.Fatalf("other user on same ip: status %d", rec.Code)
+	}
+	if rec := serve("/user", "", "192.0.2.3:1"); rec.Code != http.StatusOK {
+		t.Fatalf("anonymous request: status %d", rec.Code)
+	}
+	if rec := serve("/user", "", "192.0.2.3:1"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("anonymous request from same ip: status %d", rec.Code)
+	}
+	if rec := serve("/ip", "u3", "192.0.2.4:1"); rec.Code != http.StatusOK {
+		t.Fatalf("ip group first request: status %d", rec.Code)
+	}
+	if rec := serve("/ip", "u4", "192.0.2.4:1"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("ip group other user on same ip: status %d", rec.Code)
+	}
+	for i := 0; i < 5; i++ {
+		if rec := serve("/unlimited", "u1", "192.0.2.1:1"); rec.Code != http.StatusOK {
+			t.Fatalf("unlimited group: status %d", rec.Code)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/tracing"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Trace 为每个请求创建span，请求头中带有追踪上下文时作为其子span。
+// 需要在addRequestID之后使用，span上下文与请求ID保存在c.Request的context中，处理函数以c.Request.Context()调用DAO与外部服务时作为其子span。
+func Trace(c *gin.Context) {
+	xl := c.MustGet(model.XLogKey).(*xlog.Logger)
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	ctx := tracing.ContextWithRequestID(c.Request.Context(), xl.ReqId)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(c.Request.Header))
+	ctx, span := tracing.Tracer().Start(ctx, c.Request.Method+" "+route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(c.Request.Method),
+			semconv.HTTPRouteKey.String(route),
+			semconv.HTTPClientIPKey.String(ClientIP(c)),
+			tracing.RequestIDAttribute(xl.ReqId),
+		))
+	defer span.End()
+	c.Request = c.Request.WithContext(ctx)
+	c.Writer.Header().Set(model.RequestIDHeader, xl.ReqId)
+
+	c.Next()
+
+	status := c.Writer.Status()
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	if len(c.Errors) > 0 {
+		span.RecordError(c.Errors.Last())
+	}
+}
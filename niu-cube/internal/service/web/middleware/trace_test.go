@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiniu/x/reqid"
+	"github.com/qiniu/x/xlog"
+	"github.com/solutions/niu-cube/internal/common/tracing"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTrace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	xl := xlog.New("req-trace")
+	router := gin.New()
+	router.GET("/v1/rooms/:roomId", func(c *gin.Context) {
+		c.Set(model.XLogKey, xl)
+	}, Trace, func(c *gin.Context) {
+		// 处理函数中以请求的context创建的span是请求span的子span，context中带有请求ID。
+		tracing.StartDAO(c.Request.Context(), "BaseRoomDaoService.Select").End()
+		if id, ok := reqid.FromContext(c.Request.Context()); !ok || id != "req-trace" {
+			t.Errorf("request id in context = %q", id)
+		}
+		c.JSON(http.StatusOK, model.NewSuccessResponse(nil))
+	})
+	req := httptest.NewRequest(http.MethodGet, "/v1/rooms/room-1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	if resp.Header().Get(model.RequestIDHeader) != "req-trace" {
+		t.Errorf("response request id = %q", resp.Header().Get(model.RequestIDHeader))
+	}
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	dao, server := spans[0], spans[1]
+	if server.Name() != "GET /v1/rooms/:roomId" || server.SpanKind() != trace.SpanKindServer {
+		t.Errorf("server span = %s %v", server.Name(), server.SpanKind())
+	}
+	if server.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("server span not continued from the upstream trace: %v", server.Parent())
+	}
+	if dao.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("dao span parent = %v, want %v", dao.Parent().SpanID(), server.SpanContext().SpanID())
+	}
+}
@@ -1,18 +1,19 @@
 package middleware
 
 import (
-	"github.com/gin-gonic/gin"
 	"github.com/qiniu/x/xlog"
 	"github.com/solutions/niu-cube/internal/common/utils"
-	model "github.com/solutions/niu-cube/internal/protodef/model"
 	service "github.com/solutions/niu-cube/internal/service/db"
-	"net/http"
 )
 
 var (
 	versionService *service.VersionService
 	accountService *service.AccountService
-	xl             = xlog.New("Middleware")
+	// interviewService 与 interviewTokenService 用于校验面试邀请token。
+	interviewService      *service.InterviewService
+	interviewTokenService *service.InterviewTokenService
+	apiKeyService         ApiKeyAuthenticator
+	xl                    = xlog.New("Middleware")
 )
 
 func InitMiddleware(conf utils.Config) {
@@ -22,29 +23,14 @@ func InitMiddleware(conf utils.Config) {
 	if err != nil {
 		xl.Fatalf("error creating account service err:%v", err)
 	}
-	return
-}
-
-func VersionGate() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		userId := c.GetString(model.UserIDContextKey)
-		user, err := accountService.GetAccountByID(xl, userId)
-		if err != nil {
-			xl.Infof("user %v not exists err:%v", userId, err)
-			// TODO: 文档记录 使用goto替代重复代码
-			resp := model.NewResponseErrorUnauthorized()
-			c.JSON(http.StatusUnauthorized, resp)
-			c.Abort()
-			return
-		}
-		if _, ok := utils.DefaultConf.SMS.FixedCodes[user.Phone]; !ok {
-			xl.Infof("user %v with phone %v permission denied ", userId, user.Phone)
-			// TODO: 文档记录
-			resp := model.NewResponseErrorUnauthorized()
-			c.JSON(http.StatusUnauthorized, resp)
-			c.Abort()
-			return
-		}
-		c.Next()
+	interviewService, err = service.NewInterviewService(*conf.Mongo, xl)
+	if err != nil {
+		xl.Fatalf("error creating interview service err:%v", err)
 	}
+	interviewTokenService = service.NewInterviewTokenService(conf)
+	apiKeyService, err = service.NewApiKeyService(*conf.Mongo, xl)
+	if err != nil {
+		xl.Fatalf("error creating api key service err:%v", err)
+	}
+	return
 }
@@ -30,68 +30,241 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"math/rand"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/solutions/niu-cube/internal/common/mongodb"
+	"github.com/solutions/niu-cube/internal/common/tracing"
 	"github.com/solutions/niu-cube/internal/common/utils"
+	"github.com/solutions/niu-cube/internal/protodef/model"
+	"github.com/solutions/niu-cube/internal/service/db"
 	"github.com/solutions/niu-cube/internal/service/task"
 	"github.com/solutions/niu-cube/internal/service/web"
+	"github.com/solutions/niu-cube/internal/service/web/handler"
 
-	"github.com/jasonlvhit/gocron"
 	"github.com/qiniu/x/log"
+	"github.com/qiniu/x/xlog"
 )
 
 var (
 	configFilePath = "niu-cube.conf"
 )
 
+// defaultShutdownTimeout 未配置时停止服务等待请求与任务完成的最长时间。
+const defaultShutdownTimeout = 30 * time.Second
+
 func main() {
 	fmt.Println(time.Now())
 	flag.StringVar(&configFilePath, "f", configFilePath, "configuration file to run niu-cube server")
+	checkConfig := flag.Bool("check-config", false, "validate the configuration file and environment overrides, then exit")
 	flag.Parse()
 
+	if flag.Arg(0) == "migrate" {
+		if err := migrate(configFilePath, flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *checkConfig {
+		if err := checkConfigFile(configFilePath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println("config ok")
+		return
+	}
 	utils.InitConf(configFilePath)
 	log.SetOutputLevel(utils.DefaultConf.DebugLevel)
 	rand.Seed(time.Now().UnixNano())
+	shutdownTracing, err := tracing.Init(utils.DefaultConf.Tracing)
+	if err != nil {
+		log.Fatalf("failed to init tracing, error %v", err)
+	}
+	// 定时任务与接口依赖的索引和数据迁移需要在启动前通过migrate up完成，有未执行的迁移时不启动。
+	if err = checkMigrations(utils.DefaultConf.Mongo); err != nil {
+		log.Fatalf("failed to check migrations, error %v", err)
+	}
 	// 启动定时任务
+	scheduler := task.NewScheduler()
 	go func() {
-		interviewTask, _ := task.NewInterviewTask(utils.DefaultConf.Mongo.URI, utils.DefaultConf.Mongo.Database)
+		interviewTask, _ := task.NewInterviewTask(utils.DefaultConf.Mongo)
 		heartBeatKickTask := task.NewHeartBeatTask(utils.DefaultConf)
 		recordTaskManager := task.NewRecordTask(utils.DefaultConf)
 		repairTask, _ := task.NewRepairTask(utils.DefaultConf)
 		baseRoomTask, _ := task.NewBaseRoomTaskService(utils.DefaultConf)
-		_ = gocron.Every(1).Hours().Do(interviewTask.TaskForModifyInterviewStatus)
-		_ = gocron.Every(1).Minutes().Do(baseRoomTask.StartIdleRoomTask)
-		_ = gocron.Every(3).Seconds().Do(recordTaskManager.Start)
-		_ = gocron.Every(3).Seconds().Do(heartBeatKickTask.Start)
-		_ = gocron.Every(5).Seconds().Do(repairTask.Start)
-		_ = gocron.Every(5).Seconds().Do(baseRoomTask.StartTimeoutUserTask)
-		<-gocron.Start()
+		_ = scheduler.Do(scheduler.Every(1).Hours(), "interview_status", interviewTask.TaskForModifyInterviewStatus)
+		_ = scheduler.Do(scheduler.Every(1).Minutes(), "base_room_idle", baseRoomTask.StartIdleRoomTask)
+		_ = scheduler.Do(scheduler.Every(3).Seconds(), "record", recordTaskManager.Start)
+		_ = scheduler.Do(scheduler.Every(3).Seconds(), "heartbeat", heartBeatKickTask.Start)
+		_ = scheduler.Do(scheduler.Every(5).Seconds(), "repair", repairTask.Start)
+		_ = scheduler.Do(scheduler.Every(5).Seconds(), "base_room_user_timeout", baseRoomTask.StartTimeoutUserTask)
+		scheduler.Start()
 	}()
 	// 启动 gin HTTP server。
 	r, err := web.NewRouter(&utils.DefaultConf)
 	if err != nil {
 		log.Fatalf("failed to create gin HTTP server, error %v", err)
 	}
+	server := &http.Server{
+		Addr:    fmt.Sprintf("127.0.0.1:%d", utils.DefaultConf.ListenPort),
+		Handler: r,
+	}
 
 	errch := make(chan error, 1)
 	go func() {
-		httpServerErr := r.Run(fmt.Sprintf("127.0.0.1:%d", utils.DefaultConf.ListenPort))
+		httpServerErr := server.ListenAndServe()
 		errch <- httpServerErr
 	}()
 
 	qC := make(chan os.Signal, 1)
-	signal.Notify(qC, syscall.SIGINT, syscall.SIGTERM)
-	select {
-	case s := <-qC:
-		log.Info(s.String())
-	case err = <-errch:
-		log.Error("db stopped, error", err.Error())
+	signal.Notify(qC, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for {
+		select {
+		case s := <-qC:
+			log.Info(s.String())
+			if s == syscall.SIGHUP {
+				reloadConfig(configFilePath)
+				continue
+			}
+			shutdown(server, scheduler, shutdownTracing, utils.DefaultConf.Shutdown)
+		case err = <-errch:
+			log.Error("db stopped, error", err.Error())
+		}
+		return
+	}
+}
+
+// checkConfigFile 校验配置文件与环境变量覆盖后的配置，以及RTC、IM、短信服务所需的配置项，返回发现的全部问题。
+func checkConfigFile(configFilePath string) error {
+	conf, err := utils.LoadConfig(configFilePath)
+	if err != nil {
+		return err
 	}
+	errs := utils.ConfigErrors{}
+	for _, check := range []func(conf *utils.Config) error{handler.CheckRTCConfig, handler.CheckIMConfig, handler.CheckSMSConfig} {
+		if err := check(conf); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
 
+// migrate 执行migrate子命令：up创建声明的索引并执行未执行过的数据迁移，status列出全部迁移的执行状态。
+func migrate(configFilePath string, args []string) error {
+	if len(args) != 1 || (args[0] != "up" && args[0] != "status") {
+		return fmt.Errorf("usage: niu-cube [-f niu-cube.conf] migrate up|status")
+	}
+	conf, err := utils.LoadConfig(configFilePath)
+	if err != nil {
+		return err
+	}
+	runner, err := db.NewMigrationRunner(*conf.Mongo, nil)
+	if err != nil {
+		return err
+	}
+	if args[0] == "up" {
+		done, err := runner.Up(nil)
+		for _, migration := range done {
+			fmt.Printf("applied %d %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		return nil
+	}
+	statuses, err := runner.Status(nil)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.Applied {
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Printf("%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	return nil
+}
+
+// checkMigrations 检查数据迁移是否都已执行，有未执行的迁移时返回错误，提示先执行migrate up。
+func checkMigrations(conf *utils.MongoConfig) error {
+	runner, err := db.NewMigrationRunner(*conf, nil)
+	if err != nil {
+		return err
+	}
+	statuses, err := runner.Status(nil)
+	if err != nil {
+		return err
+	}
+	pending := make([]string, 0)
+	for _, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, fmt.Sprintf("%d %s", status.Version, status.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("pending migrations [%s], run \"niu-cube migrate up\" first", strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// reloadConfig 重新加载头像、欢迎页、解决方案列表等配置项，配置有误时保留当前配置。
+func reloadConfig(configFilePath string) {
+	if err := utils.ReloadConf(configFilePath); err != nil {
+		log.Errorf("failed to reload config, keep the current config, error %v", err)
+		return
+	}
+	log.Info("config reloaded")
+}
+
+// shutdown 依次停止接收新请求、等待进行中的请求完成、停止定时任务并等待执行中的任务结束，最后导出剩余的span。
+// 超过配置的等待时间后不再等待，仍在执行的任务标记为interrupted，重启后重新执行。
+func shutdown(server *http.Server, scheduler *task.Scheduler, shutdownTracing func(ctx context.Context) error, conf *utils.ShutdownConfig) {
+	timeout := defaultShutdownTimeout
+	var drainDelay time.Duration
+	if conf != nil {
+		if conf.TimeoutSecond > 0 {
+			timeout = time.Duration(conf.TimeoutSecond) * time.Second
+		}
+		drainDelay = time.Duration(conf.DrainDelaySecond) * time.Second
+	}
+	web.Drain()
+	if drainDelay > 0 {
+		log.Infof("draining, wait %v before closing listener", drainDelay)
+		time.Sleep(drainDelay)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Errorf("failed to wait for in-flight requests, error %v", err)
+	}
+	if err := web.Close(ctx); err != nil {
+		log.Errorf("failed to flush audit log, error %v", err)
+	}
+	if err := scheduler.Stop(ctx); err != nil {
+		log.Errorf("failed to wait for scheduled tasks, error %v", err)
+	}
+	if err := model.StopTasks(ctx, xlog.New("shutdown")); err != nil {
+		log.Errorf("failed to wait for running tasks, error %v", err)
+	}
+	mongodb.CloseAll(ctx)
+	if err := shutdownTracing(ctx); err != nil {
+		log.Errorf("failed to flush spans, error %v", err)
+	}
+	log.Info("niu-cube stopped")
 }